@@ -0,0 +1,212 @@
+// Package aot translates the bytecode of Java methods into Go source.
+//
+// The generated package registers every translated method with vm.RegisterCompiledMethod,
+// so a VM built with the package runs the Go code instead of interpreting the method.
+// The Go code keeps primitives and references of the operand stack in Go variables,
+// and executes the instructions which need the VM (field access, invoke, allocation, ...)
+// through their ICs on the method's own frame.
+package aot
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// Output is the result of Translate
+type Output struct {
+	// Source is the formatted Go source file
+	Source []byte
+	// Compiled are the locations of the translated methods
+	Compiled []string
+	// Skipped are the locations of the methods which cannot be translated, and the reasons
+	Skipped map[string]string
+}
+
+// Translate translates the methods of the classes into a Go source file of package pkg
+func Translate(pkg string, classes []*jcls.Class) (*Output, error) {
+	f := &fileTranslator{
+		icVars: make(map[string]string),
+	}
+	out := &Output{
+		Skipped: make(map[string]string),
+	}
+	var funcs bytes.Buffer
+	var inits []string
+	for _, class := range classes {
+		for _, method := range class.Methods {
+			if method.Code == nil {
+				continue
+			}
+			location := class.Name() + "." + method.Name() + method.Desc().String()
+			fn := fmt.Sprintf("m%d_%s", len(out.Compiled), identifier(method.Name()))
+			var buf bytes.Buffer
+			if err := newMethodTranslator(f, class, method).translate(fn, &buf); err != nil {
+				out.Skipped[location] = err.Error()
+				continue
+			}
+			funcs.Write(buf.Bytes())
+			out.Compiled = append(out.Compiled, location)
+			inits = append(inits, fmt.Sprintf("\tjvm.RegisterCompiledMethod(%q, %#08x, %s)\n", location, method.Code.Checksum, fn))
+		}
+	}
+
+	var src bytes.Buffer
+	fmt.Fprintf(&src, "// Code generated by gova aot; DO NOT EDIT.\n\npackage %s\n\n", pkg)
+	src.WriteString(fileHeader)
+	if len(f.icDecls) > 0 {
+		src.WriteString("var (\n")
+		for _, decl := range f.icDecls {
+			src.WriteString(decl)
+		}
+		src.WriteString(")\n\n")
+	}
+	src.WriteString("func init() {\n")
+	for _, line := range inits {
+		src.WriteString(line)
+	}
+	if len(inits) == 0 {
+		src.WriteString("\t_ = jvm.RegisterCompiledMethod\n")
+	}
+	src.WriteString("}\n\n")
+	src.Write(funcs.Bytes())
+
+	formatted, err := format.Source(src.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("aot: format generated source: %w", err)
+	}
+	out.Source = formatted
+	return out, nil
+}
+
+// fileTranslator holds the declarations shared by the methods in the generated file
+type fileTranslator struct {
+	icVars  map[string]string
+	icDecls []string
+}
+
+// icVar returns the package level variable which holds the IC
+func (f *fileTranslator) icVar(ic ir.IC) string {
+	value := fmt.Sprintf("%#v", ic)
+	if name, ok := f.icVars[value]; ok {
+		return name
+	}
+	name := fmt.Sprintf("ic%d", len(f.icDecls))
+	f.icVars[value] = name
+	f.icDecls = append(f.icDecls, fmt.Sprintf("\t%s ir.IC = %s\n", name, value))
+	return name
+}
+
+// identifier replaces the characters which are not allowed in Go identifiers, e.g. '<' in "<init>"
+func identifier(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '_' || 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+// fileHeader is the imports and the helpers used by the generated methods
+const fileHeader = `import (
+	"math"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+// exec executes the IC in the frame s, and runs the invoked method until it returns
+func exec(vm ir.VM, s ir.Stack, ic ir.IC) error {
+	if err := ic.Execute(vm); err != nil {
+		return err
+	}
+	if vm.GetStack() != s {
+		return vm.RunStack()
+	}
+	return nil
+}
+
+func f2i(v float32) int32 { return d2i(float64(v)) }
+func f2l(v float32) int64 { return d2l(float64(v)) }
+
+func d2i(v float64) int32 {
+	switch {
+	case v != v:
+		return 0
+	case v >= math.MaxInt32:
+		return math.MaxInt32
+	case v <= math.MinInt32:
+		return math.MinInt32
+	}
+	return int32(v)
+}
+
+func d2l(v float64) int64 {
+	switch {
+	case v != v:
+		return 0
+	case v >= math.MaxInt64:
+		return math.MaxInt64
+	case v <= math.MinInt64:
+		return math.MinInt64
+	}
+	return int64(v)
+}
+
+// fcmp compares two floating point numbers, nan is the result if either is NaN
+func fcmp(a, b float64, nan int32) int32 {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	case a == b:
+		return 0
+	}
+	return nan
+}
+
+func lcmp(a, b int64) int32 {
+	switch {
+	case a > b:
+		return 1
+	case a < b:
+		return -1
+	}
+	return 0
+}
+
+func idiv(a, b int32) (int32, error) {
+	if b == 0 {
+		return 0, errs.DivideByZero
+	}
+	return a / b, nil
+}
+
+func irem(a, b int32) (int32, error) {
+	if b == 0 {
+		return 0, errs.DivideByZero
+	}
+	return a % b, nil
+}
+
+func ldiv(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, errs.DivideByZero
+	}
+	return a / b, nil
+}
+
+func lrem(a, b int64) (int64, error) {
+	if b == 0 {
+		return 0, errs.DivideByZero
+	}
+	return a % b, nil
+}
+
+`
@@ -0,0 +1,135 @@
+package aot
+
+import (
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	irparser "github.com/LiterMC/wasm-jdk/ir/parser"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func codeMethod(t *testing.T, name, typ string, code []byte) *jcls.Method {
+	t.Helper()
+	insts, err := irparser.ParseInsts(code)
+	if err != nil {
+		t.Fatalf("%s: cannot parse code: %v", name, err)
+	}
+	md, err := desc.ParseMethodDesc(typ)
+	if err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	m := jcls.NewMethod(jcls.AccPublic|jcls.AccStatic, name, md, nil)
+	m.Code = &jcls.AttrCode{MaxStack: 8, MaxLocals: 4, Code: &insts[0], Insts: insts}
+	return m
+}
+
+// typeCheck checks the generated source compiles against the vm packages
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, "gen.go", src, 0)
+	if err != nil {
+		t.Fatalf("cannot parse generated source: %v\n%s", err, src)
+	}
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check("gen", fset, []*ast.File{file}, nil); err != nil {
+		t.Fatalf("generated source does not type check: %v\n%s", err, src)
+	}
+}
+
+func TestTranslateClassFile(t *testing.T) {
+	fd, err := os.Open("../jcls/testdata/Test.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	class, err := jcls.ParseClass(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := Translate("gen", []*jcls.Class{class})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Skipped) != 0 {
+		t.Errorf("unexpected skipped methods: %v", out.Skipped)
+	}
+	for _, location := range []string{
+		"Test.<init>()V",
+		"Test.testPrivateVoidMethod()V",
+		"Test.testPublicFinalAddMethod(IJ)J",
+		"Test.main([Ljava/lang/String;)V",
+	} {
+		if !strings.Contains(string(out.Source), `jvm.RegisterCompiledMethod("`+location+`"`) {
+			t.Errorf("%s is not registered", location)
+		}
+	}
+	typeCheck(t, out.Source)
+}
+
+func TestTranslateCode(t *testing.T) {
+	// static int sum(int n) { int s = 0; for (int i = 0; i < n; i++) s += i; return s; }
+	sum := codeMethod(t, "sum", "(I)I", []byte{
+		0x03, 0x3c, 0x03, 0x3d, // iconst_0; istore_1; iconst_0; istore_2
+		0x1c, 0x1a, 0xa2, 0x00, 0x0d, // 4: iload_2; iload_0; if_icmpge 19
+		0x1b, 0x1c, 0x60, 0x3c, // iload_1; iload_2; iadd; istore_1
+		0x84, 0x02, 0x01, // iinc 2, 1
+		0xa7, 0xff, 0xf4, // goto 4
+		0x1b, 0xac, // 19: iload_1; ireturn
+	})
+	// static int sw(int k) { switch (k) { case 0: return 1; case 1: return 2; default: return -1; } }
+	sw := codeMethod(t, "sw", "(I)I", []byte{
+		0x1a,             // iload_0
+		0xaa, 0x00, 0x00, // tableswitch
+		0x00, 0x00, 0x00, 0x1b, // default: 28
+		0x00, 0x00, 0x00, 0x00, // low: 0
+		0x00, 0x00, 0x00, 0x01, // high: 1
+		0x00, 0x00, 0x00, 0x17, // 0: 24
+		0x00, 0x00, 0x00, 0x19, // 1: 26
+		0x04, 0xac, 0x05, 0xac, 0x02, 0xac, // iconst_1; ireturn; iconst_2; ireturn; iconst_m1; ireturn
+	})
+	// static long mix(long a, int b)
+	mix := codeMethod(t, "mix", "(JI)J", []byte{
+		0x1e, 0x1c, 0x79, // lload_0; iload_2; lshl
+		0x06, 0x7d, // iconst_3; lushr
+		0x5c, 0x0a, 0x61, 0x83, // dup2; lconst_1; ladd; lxor
+		0x1e, 0x1c, 0x85, 0x71, 0x61, // lload_0; iload_2; i2l; lrem; ladd
+		0x8a, 0x8e, 0x85, 0xad, // l2d; d2i; i2l; lreturn
+	})
+	// static int shuffle(int a, int b)
+	shuffle := codeMethod(t, "shuffle", "(II)I", []byte{
+		0x1a, 0x1b, 0x5a, 0x64, // iload_0; iload_1; dup_x1; isub
+		0x5f, 0x68, 0xac, // swap; imul; ireturn
+	})
+	// static int abs(int a) { return a < 0 ? -a : a; }
+	abs := codeMethod(t, "abs", "(I)I", []byte{
+		0x1a, 0x9c, 0x00, 0x08, // iload_0; ifge 9
+		0x1a, 0x74, 0xa7, 0x00, 0x04, // iload_0; ineg; goto 10
+		0x1a, 0xac, // 9: iload_0; 10: ireturn
+	})
+	caught := codeMethod(t, "caught", "()V", []byte{0xb1})
+	caught.Code.Exceptions = []jcls.ExceptionHandlers{{Class: "java/lang/Throwable", Node: caught.Code.Code}}
+
+	class := jcls.NewClass(jcls.AccPublic, "Code", "java/lang/Object", nil, nil, []*jcls.Method{sum, sw, mix, shuffle, abs, caught}, nil)
+	out, err := Translate("gen", []*jcls.Class{class})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(out.Compiled, " "), "Code.sum(I)I Code.sw(I)I Code.mix(JI)J Code.shuffle(II)I Code.abs(I)I"; got != want {
+		t.Errorf("compiled %q, want %q", got, want)
+	}
+	if !strings.Contains(string(out.Source), "jvm.Safepoint(vm)\n\tgoto L4") {
+		t.Errorf("the back edge of sum does not poll the safepoint")
+	}
+	if reason := out.Skipped["Code.caught()V"]; !strings.Contains(reason, "exception handlers") {
+		t.Errorf("unexpected skip reason for caught: %q", reason)
+	}
+	typeCheck(t, out.Source)
+}
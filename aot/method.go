@@ -0,0 +1,1071 @@
+package aot
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/ir/cfg"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/ops"
+)
+
+// kind is the type of an operand stack entry or a local variable in the generated code
+type kind byte
+
+const (
+	kindInt    kind = 'I'
+	kindLong   kind = 'J'
+	kindFloat  kind = 'F'
+	kindDouble kind = 'D'
+	kindRef    kind = 'A'
+)
+
+func kindOf(d *desc.Desc) kind {
+	switch d.Type() {
+	case desc.Boolean, desc.Byte, desc.Char, desc.Short, desc.Int:
+		return kindInt
+	case desc.Long:
+		return kindLong
+	case desc.Float:
+		return kindFloat
+	case desc.Double:
+		return kindDouble
+	case desc.Class, desc.Array:
+		return kindRef
+	}
+	return 0
+}
+
+func (k kind) goType() string {
+	switch k {
+	case kindInt:
+		return "int32"
+	case kindLong:
+		return "int64"
+	case kindFloat:
+		return "float32"
+	case kindDouble:
+		return "float64"
+	}
+	return "ir.Ref"
+}
+
+// stackMethod is the suffix of the ir.Stack methods which access values of the kind
+func (k kind) stackMethod() string {
+	switch k {
+	case kindInt:
+		return "Int32"
+	case kindLong:
+		return "Int64"
+	case kindFloat:
+		return "Float32"
+	case kindDouble:
+		return "Float64"
+	}
+	return "Ref"
+}
+
+// wide reports whether the kind takes two slots
+func (k kind) wide() bool {
+	return k == kindLong || k == kindDouble
+}
+
+// methodTranslator translates the bytecode of a method into the body of a Go function.
+// The operand stack entries and the local variables are Go variables named by their kind and index,
+// e.g. sI2 is the int at depth 2, and lA0 is the reference in local variable 0,
+// so the stack depth and the entry kinds before each instruction are computed first.
+type methodTranslator struct {
+	file   *fileTranslator
+	class  *jcls.Class
+	method *jcls.Method
+	code   *jcls.AttrCode
+
+	// states are the stack entry kinds before each instruction, nil if the instruction is unreachable
+	states  [][]kind
+	graph   *cfg.Graph
+	targets map[int32]bool
+	vars    map[string]kind
+
+	stack []kind
+	out   *bytes.Buffer
+}
+
+func newMethodTranslator(file *fileTranslator, class *jcls.Class, method *jcls.Method) *methodTranslator {
+	return &methodTranslator{
+		file:    file,
+		class:   class,
+		method:  method,
+		code:    method.Code,
+		states:  make([][]kind, len(method.Code.Insts)),
+		targets: make(map[int32]bool),
+		vars:    make(map[string]kind),
+	}
+}
+
+// translate writes the Go function named fn, or returns why the method cannot be compiled
+func (t *methodTranslator) translate(fn string, w *bytes.Buffer) error {
+	if len(t.code.Exceptions) > 0 {
+		return fmt.Errorf("exception handlers are not supported")
+	}
+	if err := t.analyze(); err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	t.out = &body
+	for i := range t.code.Insts {
+		node := &t.code.Insts[i]
+		state := t.states[i]
+		if state == nil {
+			continue
+		}
+		if t.targets[node.Index] {
+			fmt.Fprintf(&body, "L%d:\n", node.Offset)
+		}
+		fmt.Fprintf(&body, "\t// %04x: %s\n", node.Offset, node.IC.Op())
+		t.stack = slices.Clone(state)
+		if err := t.inst(node); err != nil {
+			return err
+		}
+	}
+
+	var params bytes.Buffer
+	slot := (uint16)(0)
+	if !t.method.IsStatic() {
+		fmt.Fprintf(&params, "\t%s = s.GetVarRef(0)\n", t.local(kindRef, 0))
+		slot++
+	}
+	for _, in := range t.method.Desc().Inputs {
+		k := kindOf(in)
+		fmt.Fprintf(&params, "\t%s = s.GetVar%s(%d)\n", t.local(k, slot), k.stackMethod(), slot)
+		slot += in.Type().Slot()
+	}
+
+	fmt.Fprintf(w, "// %s.%s%s\n", t.class.Name(), t.method.Name(), t.method.Desc())
+	fmt.Fprintf(w, "func %s(vm ir.VM) error {\n", fn)
+	w.WriteString("\ts := vm.GetStack()\n")
+	w.WriteString("\tvar err error\n")
+	names := make([]string, 0, len(t.vars))
+	for name := range t.vars {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "\tvar %s %s\n", name, t.vars[name].goType())
+	}
+	w.WriteString("\t_ = err\n")
+	for _, name := range names {
+		fmt.Fprintf(w, "\t_ = %s\n", name)
+	}
+	w.Write(params.Bytes())
+	w.Write(body.Bytes())
+	w.WriteString("}\n\n")
+	return nil
+}
+
+// analyze computes the stack state before each reachable instruction,
+// by propagating the states along the edges of the control flow graph
+func (t *methodTranslator) analyze() error {
+	g, err := cfg.New(t.code)
+	if err != nil {
+		return err
+	}
+	t.graph = g
+	t.out = new(bytes.Buffer)
+	t.states[0] = []kind{}
+	work := []*cfg.Block{g.Entry()}
+	for len(work) > 0 {
+		b := work[len(work)-1]
+		work = work[:len(work)-1]
+		t.stack = slices.Clone(t.states[b.First().Index])
+		for i := range b.Insts {
+			node := &b.Insts[i]
+			t.states[node.Index] = slices.Clone(t.stack)
+			if err := t.inst(node); err != nil {
+				return fmt.Errorf("%04x %s: %w", node.Offset, node.IC.Op(), err)
+			}
+		}
+		for _, e := range b.Succs {
+			target := e.To.First()
+			if e.Kind != cfg.EdgeFallthrough {
+				t.targets[target.Index] = true
+			}
+			if state := t.states[target.Index]; state != nil {
+				if !slices.Equal(state, t.stack) {
+					return fmt.Errorf("%04x: inconsistent stack at %04x", b.Last().Offset, target.Offset)
+				}
+				continue
+			}
+			t.states[target.Index] = slices.Clone(t.stack)
+			work = append(work, e.To)
+		}
+	}
+	return nil
+}
+
+// branchTarget returns the target of the taken branch of the if instruction
+func (t *methodTranslator) branchTarget(node *ir.ICNode) *ir.ICNode {
+	for _, e := range t.graph.BlockOf(node).Succs {
+		if e.Kind == cfg.EdgeBranch {
+			return e.To.First()
+		}
+	}
+	return nil
+}
+
+// conditional reports whether the instruction is an if instruction
+func conditional(op ops.Op) bool {
+	return (op >= ops.Ifeq && op <= ops.If_acmpne) || op == ops.Ifnull || op == ops.Ifnonnull
+}
+
+func (t *methodTranslator) emit(format string, args ...any) {
+	t.out.WriteByte('\t')
+	fmt.Fprintf(t.out, format, args...)
+	t.out.WriteByte('\n')
+}
+
+func (t *methodTranslator) local(k kind, i uint16) string {
+	name := fmt.Sprintf("l%c%d", k, i)
+	t.vars[name] = k
+	return name
+}
+
+func (t *methodTranslator) slot(k kind, depth int) string {
+	name := fmt.Sprintf("s%c%d", k, depth)
+	t.vars[name] = k
+	return name
+}
+
+func (t *methodTranslator) push(k kind) string {
+	name := t.slot(k, len(t.stack))
+	t.stack = append(t.stack, k)
+	return name
+}
+
+var errStackUnderflow = fmt.Errorf("stack underflow")
+
+func (t *methodTranslator) pop(k kind) (string, error) {
+	n := len(t.stack) - 1
+	if n < 0 {
+		return "", errStackUnderflow
+	}
+	if k != 0 && t.stack[n] != k {
+		return "", fmt.Errorf("expect %c on the stack, got %c", k, t.stack[n])
+	}
+	name := t.slot(t.stack[n], n)
+	t.stack = t.stack[:n]
+	return name, nil
+}
+
+func (t *methodTranslator) pop2(k kind) (a, b string, err error) {
+	if b, err = t.pop(k); err != nil {
+		return
+	}
+	a, err = t.pop(k)
+	return
+}
+
+// constant returns the constant pool entry at the index
+func (t *methodTranslator) constant(i uint16) (jcls.ConstantInfo, error) {
+	if i == 0 || (int)(i) > len(t.class.ConstPool) || t.class.ConstPool[i-1] == nil {
+		return nil, fmt.Errorf("invalid constant index %d", i)
+	}
+	return t.class.ConstPool[i-1], nil
+}
+
+func (t *methodTranslator) memberDesc(i uint16) (string, error) {
+	c, err := t.constant(i)
+	if err != nil {
+		return "", err
+	}
+	switch c := c.(type) {
+	case *jcls.ConstantRef:
+		return c.NameAndType.Desc, nil
+	case *jcls.ConstantDynamics:
+		return c.NameAndType.Desc, nil
+	}
+	return "", fmt.Errorf("constant %d is not a member reference", i)
+}
+
+func (t *methodTranslator) fieldKind(i uint16) (kind, error) {
+	s, err := t.memberDesc(i)
+	if err != nil {
+		return 0, err
+	}
+	d, err := desc.ParseDesc(s)
+	if err != nil {
+		return 0, err
+	}
+	return kindOf(d), nil
+}
+
+// invokeKinds returns the kinds of the arguments and the result of the invoked method,
+// the result kind is zero for void methods
+func (t *methodTranslator) invokeKinds(i uint16, receiver bool) ([]kind, kind, error) {
+	s, err := t.memberDesc(i)
+	if err != nil {
+		return nil, 0, err
+	}
+	md, err := desc.ParseMethodDesc(s)
+	if err != nil {
+		return nil, 0, err
+	}
+	var in []kind
+	if receiver {
+		in = append(in, kindRef)
+	}
+	for _, d := range md.Inputs {
+		in = append(in, kindOf(d))
+	}
+	return in, kindOf(md.Output), nil
+}
+
+func (t *methodTranslator) ldcKind(i uint16) (kind, error) {
+	c, err := t.constant(i)
+	if err != nil {
+		return 0, err
+	}
+	switch c := c.(type) {
+	case *jcls.ConstantInteger:
+		return kindInt, nil
+	case *jcls.ConstantFloat:
+		return kindFloat, nil
+	case *jcls.ConstantLong:
+		return kindLong, nil
+	case *jcls.ConstantDouble:
+		return kindDouble, nil
+	case *jcls.ConstantDynamics:
+		return t.fieldKind(i)
+	case *jcls.ConstantString, *jcls.ConstantClass, *jcls.ConstantMethodType, *jcls.ConstantMethodHandle:
+		return kindRef, nil
+	default:
+		return 0, fmt.Errorf("unexpected constant %s", c)
+	}
+}
+
+// exec executes the IC on the frame with the operands spilled onto the frame's stack,
+// and reloads the result into the stack variables
+func (t *methodTranslator) exec(ic ir.IC, in []kind, out kind) error {
+	n := len(t.stack) - len(in)
+	if n < 0 {
+		return errStackUnderflow
+	}
+	for i, k := range in {
+		if have := t.stack[n+i]; have != k {
+			return fmt.Errorf("expect %c on the stack, got %c", k, have)
+		}
+		t.emit("s.Push%s(%s)", k.stackMethod(), t.slot(k, n+i))
+	}
+	t.stack = t.stack[:n]
+	t.emit("if err = exec(vm, s, %s); err != nil {\n\t\treturn err\n\t}", t.file.icVar(ic))
+	if out != 0 {
+		t.emit("%s = s.Pop%s()", t.push(out), out.stackMethod())
+	}
+	return nil
+}
+
+// shuffle rearranges the top entries of the stack for the dup and swap instructions.
+// order lists the new top entries as indexes into the old top n entries.
+func (t *methodTranslator) shuffle(n int, order ...int) {
+	base := len(t.stack) - n
+	old := slices.Clone(t.stack[base:])
+	t.stack = t.stack[:base]
+	var lhs, rhs []string
+	for _, i := range order {
+		depth := len(t.stack)
+		name := t.push(old[i])
+		if i != depth-base {
+			lhs = append(lhs, name)
+			rhs = append(rhs, t.slot(old[i], base+i))
+		}
+	}
+	if len(lhs) > 0 {
+		t.emit("%s = %s", strings.Join(lhs, ", "), strings.Join(rhs, ", "))
+	}
+}
+
+// entries returns the number of entries which make up the top n slots of the stack, or 0 if it splits a wide entry
+func (t *methodTranslator) entries(slots int) int {
+	n := 0
+	for i := len(t.stack) - 1; i >= 0 && slots > 0; i-- {
+		if t.stack[i].wide() {
+			slots -= 2
+		} else {
+			slots--
+		}
+		n++
+	}
+	if slots != 0 {
+		return 0
+	}
+	return n
+}
+
+// dupX duplicates the top n slots and inserts them under the m slots below them
+func (t *methodTranslator) dupX(n, m int) error {
+	top := t.entries(n)
+	all := t.entries(n + m)
+	if top == 0 || all == 0 {
+		return fmt.Errorf("invalid stack for %d slots dup", n)
+	}
+	order := make([]int, 0, all+top)
+	for i := all - top; i < all; i++ {
+		order = append(order, i)
+	}
+	for i := range all {
+		order = append(order, i)
+	}
+	t.shuffle(all, order...)
+	return nil
+}
+
+// inst translates a single instruction, and updates the stack state
+func (t *methodTranslator) inst(node *ir.ICNode) error {
+	ic := node.IC
+	if w, ok := ic.(*ir.ICwide); ok {
+		unwrapped, err := unwrapWide(w)
+		if err != nil {
+			return err
+		}
+		ic = unwrapped
+	}
+	if op, k, index, ok := localAccess(ic); ok {
+		switch op {
+		case ops.Iload:
+			t.emit("%s = %s", t.push(k), t.local(k, index))
+		case ops.Istore:
+			v, err := t.pop(k)
+			if err != nil {
+				return err
+			}
+			t.emit("%s = %s", t.local(k, index), v)
+		}
+		return nil
+	}
+	if k, v, ok := constValue(ic); ok {
+		t.emit("%s = %s", t.push(k), v)
+		return nil
+	}
+	if k, op, ok := binaryOp(ic); ok {
+		return t.binary(k, op)
+	}
+	if conditional(ic.Op()) {
+		return t.cond(node, ic, t.branchTarget(node))
+	}
+
+	switch ic := ic.(type) {
+	case *ir.ICnop:
+	case *ir.ICiinc:
+		t.emit("%s += %d", t.local(kindInt, ic.Index), ic.Const)
+
+	case *ir.ICpop:
+		if _, err := t.pop(0); err != nil {
+			return err
+		}
+	case *ir.ICpop2:
+		n := t.entries(2)
+		if n == 0 {
+			return fmt.Errorf("invalid stack for pop2")
+		}
+		t.stack = t.stack[:len(t.stack)-n]
+	case *ir.ICdup:
+		return t.dupX(1, 0)
+	case *ir.ICdup_x1:
+		return t.dupX(1, 1)
+	case *ir.ICdup_x2:
+		return t.dupX(1, 2)
+	case *ir.ICdup2:
+		return t.dupX(2, 0)
+	case *ir.ICdup2_x1:
+		return t.dupX(2, 1)
+	case *ir.ICdup2_x2:
+		return t.dupX(2, 2)
+	case *ir.ICswap:
+		if len(t.stack) < 2 {
+			return errStackUnderflow
+		}
+		t.shuffle(2, 1, 0)
+
+	case *ir.ICineg:
+		return t.unary(kindInt, kindInt, "-%s")
+	case *ir.IClneg:
+		return t.unary(kindLong, kindLong, "-%s")
+	case *ir.ICfneg:
+		return t.unary(kindFloat, kindFloat, "-%s")
+	case *ir.ICdneg:
+		return t.unary(kindDouble, kindDouble, "-%s")
+	case *ir.ICi2l:
+		return t.unary(kindInt, kindLong, "int64(%s)")
+	case *ir.ICi2f:
+		return t.unary(kindInt, kindFloat, "float32(%s)")
+	case *ir.ICi2d:
+		return t.unary(kindInt, kindDouble, "float64(%s)")
+	case *ir.ICl2i:
+		return t.unary(kindLong, kindInt, "int32(%s)")
+	case *ir.ICl2f:
+		return t.unary(kindLong, kindFloat, "float32(%s)")
+	case *ir.ICl2d:
+		return t.unary(kindLong, kindDouble, "float64(%s)")
+	case *ir.ICf2i:
+		return t.unary(kindFloat, kindInt, "f2i(%s)")
+	case *ir.ICf2l:
+		return t.unary(kindFloat, kindLong, "f2l(%s)")
+	case *ir.ICf2d:
+		return t.unary(kindFloat, kindDouble, "float64(%s)")
+	case *ir.ICd2i:
+		return t.unary(kindDouble, kindInt, "d2i(%s)")
+	case *ir.ICd2l:
+		return t.unary(kindDouble, kindLong, "d2l(%s)")
+	case *ir.ICd2f:
+		return t.unary(kindDouble, kindFloat, "float32(%s)")
+	case *ir.ICi2b:
+		return t.unary(kindInt, kindInt, "int32(int8(%s))")
+	case *ir.ICi2c:
+		return t.unary(kindInt, kindInt, "int32(uint16(%s))")
+	case *ir.ICi2s:
+		return t.unary(kindInt, kindInt, "int32(int16(%s))")
+
+	case *ir.IClcmp:
+		return t.compare(kindLong, "lcmp(%s, %s)")
+	case *ir.ICfcmpl:
+		return t.compare(kindFloat, "fcmp(float64(%s), float64(%s), -1)")
+	case *ir.ICfcmpg:
+		return t.compare(kindFloat, "fcmp(float64(%s), float64(%s), 1)")
+	case *ir.ICdcmpl:
+		return t.compare(kindDouble, "fcmp(%s, %s, -1)")
+	case *ir.ICdcmpg:
+		return t.compare(kindDouble, "fcmp(%s, %s, 1)")
+
+	case *ir.ICgoto:
+		t.emit("%s", t.jump(node, ic.Node))
+	case *ir.ICgoto_w:
+		t.emit("%s", t.jump(node, ic.Node))
+	case *ir.ICtableswitch:
+		key, err := t.pop(kindInt)
+		if err != nil {
+			return err
+		}
+		t.emit("switch %s {", key)
+		for i, n := range ic.Nodes {
+			t.emit("case %d:\n\t\t%s", ic.Low+(int32)(i), t.jump(node, n))
+		}
+		t.emit("}\n\t%s", t.jump(node, ic.DefaultNode))
+	case *ir.IClookupswitch:
+		key, err := t.pop(kindInt)
+		if err != nil {
+			return err
+		}
+		t.emit("switch %s {", key)
+		for _, e := range ic.Indexes {
+			t.emit("case %d:\n\t\t%s", e.K, t.jump(node, e.N))
+		}
+		t.emit("}\n\t%s", t.jump(node, ic.DefaultNode))
+
+	case *ir.ICireturn:
+		return t.ret(kindInt)
+	case *ir.IClreturn:
+		return t.ret(kindLong)
+	case *ir.ICfreturn:
+		return t.ret(kindFloat)
+	case *ir.ICdreturn:
+		return t.ret(kindDouble)
+	case *ir.ICareturn:
+		return t.ret(kindRef)
+	case *ir.ICreturn:
+		t.emit("return nil")
+	case *ir.ICathrow:
+		// the throwable unwinds the frame, so it must not run through exec
+		v, err := t.pop(kindRef)
+		if err != nil {
+			return err
+		}
+		t.emit("s.PushRef(%s)", v)
+		t.emit("return %s.Execute(vm)", t.file.icVar(ic))
+
+	case *ir.ICgetfield:
+		k, err := t.fieldKind(ic.Field)
+		if err != nil {
+			return err
+		}
+		return t.exec(ic, []kind{kindRef}, k)
+	case *ir.ICputfield:
+		k, err := t.fieldKind(ic.Field)
+		if err != nil {
+			return err
+		}
+		return t.exec(ic, []kind{kindRef, k}, 0)
+	case *ir.ICgetstatic:
+		k, err := t.fieldKind(ic.Field)
+		if err != nil {
+			return err
+		}
+		return t.exec(ic, nil, k)
+	case *ir.ICputstatic:
+		k, err := t.fieldKind(ic.Field)
+		if err != nil {
+			return err
+		}
+		return t.exec(ic, []kind{k}, 0)
+	case *ir.ICinvokestatic:
+		return t.invoke(ic, ic.Method, false)
+	case *ir.ICinvokespecial:
+		return t.invoke(ic, ic.Method, true)
+	case *ir.ICinvokevirtual:
+		return t.invoke(ic, ic.Method, true)
+	case *ir.ICinvokeinterface:
+		return t.invoke(ic, ic.Method, true)
+	case *ir.ICinvokedynamic:
+		return t.invoke(ic, ic.Method, false)
+	case *ir.ICldc:
+		return t.ldc(ic, (uint16)(ic.Index))
+	case *ir.ICldc_w:
+		return t.ldc(ic, ic.Index)
+	case *ir.ICldc2_w:
+		return t.ldc(ic, ic.Index)
+
+	case *ir.ICnew:
+		return t.exec(ic, nil, kindRef)
+	case *ir.ICnewarray, *ir.ICanewarray:
+		return t.exec(ic, []kind{kindInt}, kindRef)
+	case *ir.ICmultianewarray:
+		in := make([]kind, ic.Dimensions)
+		for i := range in {
+			in[i] = kindInt
+		}
+		return t.exec(ic, in, kindRef)
+	case *ir.ICarraylength:
+		return t.exec(ic, []kind{kindRef}, kindInt)
+	case *ir.ICcheckcast:
+		return t.exec(ic, []kind{kindRef}, kindRef)
+	case *ir.ICinstanceof:
+		return t.exec(ic, []kind{kindRef}, kindInt)
+	case *ir.ICmonitorenter, *ir.ICmonitorexit:
+		return t.exec(ic, []kind{kindRef}, 0)
+	case *ir.ICiaload, *ir.ICbaload, *ir.ICcaload, *ir.ICsaload:
+		return t.exec(ic, []kind{kindRef, kindInt}, kindInt)
+	case *ir.IClaload:
+		return t.exec(ic, []kind{kindRef, kindInt}, kindLong)
+	case *ir.ICfaload:
+		return t.exec(ic, []kind{kindRef, kindInt}, kindFloat)
+	case *ir.ICdaload:
+		return t.exec(ic, []kind{kindRef, kindInt}, kindDouble)
+	case *ir.ICaaload:
+		return t.exec(ic, []kind{kindRef, kindInt}, kindRef)
+	case *ir.ICiastore, *ir.ICbastore, *ir.ICcastore, *ir.ICsastore:
+		return t.exec(ic, []kind{kindRef, kindInt, kindInt}, 0)
+	case *ir.IClastore:
+		return t.exec(ic, []kind{kindRef, kindInt, kindLong}, 0)
+	case *ir.ICfastore:
+		return t.exec(ic, []kind{kindRef, kindInt, kindFloat}, 0)
+	case *ir.ICdastore:
+		return t.exec(ic, []kind{kindRef, kindInt, kindDouble}, 0)
+	case *ir.ICaastore:
+		return t.exec(ic, []kind{kindRef, kindInt, kindRef}, 0)
+	default:
+		return fmt.Errorf("unsupported instruction")
+	}
+	return nil
+}
+
+// jump returns the statement which jumps from the instruction to the target,
+// backward jumps poll the safepoint since they may form a loop.
+func (t *methodTranslator) jump(from, to *ir.ICNode) string {
+	if to.Offset <= from.Offset {
+		return fmt.Sprintf("jvm.Safepoint(vm)\n\tgoto L%d", to.Offset)
+	}
+	return fmt.Sprintf("goto L%d", to.Offset)
+}
+
+func (t *methodTranslator) unary(in, out kind, format string) error {
+	v, err := t.pop(in)
+	if err != nil {
+		return err
+	}
+	t.emit("%s = "+format, t.push(out), v)
+	return nil
+}
+
+func (t *methodTranslator) compare(k kind, format string) error {
+	a, b, err := t.pop2(k)
+	if err != nil {
+		return err
+	}
+	t.emit("%s = "+format, t.push(kindInt), a, b)
+	return nil
+}
+
+func (t *methodTranslator) binary(k kind, op string) error {
+	var (
+		a, b string
+		err  error
+	)
+	if op == "<<" || op == ">>" || op == ">>>" {
+		if b, err = t.pop(kindInt); err == nil {
+			a, err = t.pop(k)
+		}
+	} else {
+		a, b, err = t.pop2(k)
+	}
+	if err != nil {
+		return err
+	}
+	r := t.push(k)
+	shiftMask := 0x1f
+	if k == kindLong {
+		shiftMask = 0x3f
+	}
+	switch op {
+	case "/", "%":
+		if k == kindInt || k == kindLong {
+			fn := map[string]string{"/": "div", "%": "rem"}[op]
+			if k == kindInt {
+				fn = "i" + fn
+			} else {
+				fn = "l" + fn
+			}
+			t.emit("if %s, err = %s(%s, %s); err != nil {\n\t\treturn err\n\t}", r, fn, a, b)
+			return nil
+		}
+		if op == "%" {
+			t.emit("%s = %s(math.Mod(float64(%s), float64(%s)))", r, k.goType(), a, b)
+			return nil
+		}
+	case "<<", ">>":
+		t.emit("%s = %s %s (uint32(%s) & %#x)", r, a, op, b, shiftMask)
+		return nil
+	case ">>>":
+		u := map[kind]string{kindInt: "uint32", kindLong: "uint64"}[k]
+		t.emit("%s = %s(%s(%s) >> (uint32(%s) & %#x))", r, k.goType(), u, a, b, shiftMask)
+		return nil
+	}
+	if k == kindFloat || k == kindDouble {
+		// the conversion prevents fusing the operation with others, which Java does not allow
+		t.emit("%s = %s(%s %s %s)", r, k.goType(), a, op, b)
+	} else {
+		t.emit("%s = %s %s %s", r, a, op, b)
+	}
+	return nil
+}
+
+func (t *methodTranslator) cond(node *ir.ICNode, ic ir.IC, target *ir.ICNode) error {
+	var (
+		cond string
+		err  error
+		a, b string
+	)
+	switch ic.(type) {
+	case *ir.ICifeq, *ir.ICifne, *ir.ICiflt, *ir.ICifge, *ir.ICifgt, *ir.ICifle:
+		if a, err = t.pop(kindInt); err != nil {
+			return err
+		}
+		b = "0"
+	case *ir.ICif_icmpeq, *ir.ICif_icmpne, *ir.ICif_icmplt, *ir.ICif_icmpge, *ir.ICif_icmpgt, *ir.ICif_icmple:
+		if a, b, err = t.pop2(kindInt); err != nil {
+			return err
+		}
+	case *ir.ICif_acmpeq, *ir.ICif_acmpne:
+		if a, b, err = t.pop2(kindRef); err != nil {
+			return err
+		}
+	case *ir.ICifnull, *ir.ICifnonnull:
+		if a, err = t.pop(kindRef); err != nil {
+			return err
+		}
+		b = "nil"
+	}
+	switch ic.(type) {
+	case *ir.ICifeq, *ir.ICif_icmpeq, *ir.ICif_acmpeq, *ir.ICifnull:
+		cond = "=="
+	case *ir.ICifne, *ir.ICif_icmpne, *ir.ICif_acmpne, *ir.ICifnonnull:
+		cond = "!="
+	case *ir.ICiflt, *ir.ICif_icmplt:
+		cond = "<"
+	case *ir.ICifge, *ir.ICif_icmpge:
+		cond = ">="
+	case *ir.ICifgt, *ir.ICif_icmpgt:
+		cond = ">"
+	case *ir.ICifle, *ir.ICif_icmple:
+		cond = "<="
+	}
+	t.emit("if %s %s %s {\n\t\t%s\n\t}", a, cond, b, t.jump(node, target))
+	return nil
+}
+
+func (t *methodTranslator) ret(k kind) error {
+	v, err := t.pop(k)
+	if err != nil {
+		return err
+	}
+	t.emit("s.Push%s(%s)", k.stackMethod(), v)
+	t.emit("return nil")
+	return nil
+}
+
+func (t *methodTranslator) invoke(ic ir.IC, index uint16, receiver bool) error {
+	in, out, err := t.invokeKinds(index, receiver)
+	if err != nil {
+		return err
+	}
+	return t.exec(ic, in, out)
+}
+
+func (t *methodTranslator) ldc(ic ir.IC, index uint16) error {
+	k, err := t.ldcKind(index)
+	if err != nil {
+		return err
+	}
+	return t.exec(ic, nil, k)
+}
+
+func unwrapWide(w *ir.ICwide) (ir.IC, error) {
+	switch w.OpCode {
+	case ops.Iload:
+		return &ir.ICiload{Index: w.Index}, nil
+	case ops.Lload:
+		return &ir.IClload{Index: w.Index}, nil
+	case ops.Fload:
+		return &ir.ICfload{Index: w.Index}, nil
+	case ops.Dload:
+		return &ir.ICdload{Index: w.Index}, nil
+	case ops.Aload:
+		return &ir.ICaload{Index: w.Index}, nil
+	case ops.Istore:
+		return &ir.ICistore{Index: w.Index}, nil
+	case ops.Lstore:
+		return &ir.IClstore{Index: w.Index}, nil
+	case ops.Fstore:
+		return &ir.ICfstore{Index: w.Index}, nil
+	case ops.Dstore:
+		return &ir.ICdstore{Index: w.Index}, nil
+	case ops.Astore:
+		return &ir.ICastore{Index: w.Index}, nil
+	case ops.Iinc:
+		return &ir.ICiinc{Index: w.Index, Const: (int16)(w.Const)}, nil
+	}
+	return nil, fmt.Errorf("unsupported wide instruction %s", w.OpCode)
+}
+
+// localAccess reports the local variable loaded or stored by the instruction.
+// op is ops.Iload for loads and ops.Istore for stores.
+func localAccess(ic ir.IC) (op ops.Op, k kind, index uint16, ok bool) {
+	load := func(k kind, i uint16) (ops.Op, kind, uint16, bool) { return ops.Iload, k, i, true }
+	store := func(k kind, i uint16) (ops.Op, kind, uint16, bool) { return ops.Istore, k, i, true }
+	switch ic := ic.(type) {
+	case *ir.ICiload:
+		return load(kindInt, ic.Index)
+	case *ir.ICiload_0:
+		return load(kindInt, 0)
+	case *ir.ICiload_1:
+		return load(kindInt, 1)
+	case *ir.ICiload_2:
+		return load(kindInt, 2)
+	case *ir.ICiload_3:
+		return load(kindInt, 3)
+	case *ir.IClload:
+		return load(kindLong, ic.Index)
+	case *ir.IClload_0:
+		return load(kindLong, 0)
+	case *ir.IClload_1:
+		return load(kindLong, 1)
+	case *ir.IClload_2:
+		return load(kindLong, 2)
+	case *ir.IClload_3:
+		return load(kindLong, 3)
+	case *ir.ICfload:
+		return load(kindFloat, ic.Index)
+	case *ir.ICfload_0:
+		return load(kindFloat, 0)
+	case *ir.ICfload_1:
+		return load(kindFloat, 1)
+	case *ir.ICfload_2:
+		return load(kindFloat, 2)
+	case *ir.ICfload_3:
+		return load(kindFloat, 3)
+	case *ir.ICdload:
+		return load(kindDouble, ic.Index)
+	case *ir.ICdload_0:
+		return load(kindDouble, 0)
+	case *ir.ICdload_1:
+		return load(kindDouble, 1)
+	case *ir.ICdload_2:
+		return load(kindDouble, 2)
+	case *ir.ICdload_3:
+		return load(kindDouble, 3)
+	case *ir.ICaload:
+		return load(kindRef, ic.Index)
+	case *ir.ICaload_0:
+		return load(kindRef, 0)
+	case *ir.ICaload_1:
+		return load(kindRef, 1)
+	case *ir.ICaload_2:
+		return load(kindRef, 2)
+	case *ir.ICaload_3:
+		return load(kindRef, 3)
+	case *ir.ICistore:
+		return store(kindInt, ic.Index)
+	case *ir.ICistore_0:
+		return store(kindInt, 0)
+	case *ir.ICistore_1:
+		return store(kindInt, 1)
+	case *ir.ICistore_2:
+		return store(kindInt, 2)
+	case *ir.ICistore_3:
+		return store(kindInt, 3)
+	case *ir.IClstore:
+		return store(kindLong, ic.Index)
+	case *ir.IClstore_0:
+		return store(kindLong, 0)
+	case *ir.IClstore_1:
+		return store(kindLong, 1)
+	case *ir.IClstore_2:
+		return store(kindLong, 2)
+	case *ir.IClstore_3:
+		return store(kindLong, 3)
+	case *ir.ICfstore:
+		return store(kindFloat, ic.Index)
+	case *ir.ICfstore_0:
+		return store(kindFloat, 0)
+	case *ir.ICfstore_1:
+		return store(kindFloat, 1)
+	case *ir.ICfstore_2:
+		return store(kindFloat, 2)
+	case *ir.ICfstore_3:
+		return store(kindFloat, 3)
+	case *ir.ICdstore:
+		return store(kindDouble, ic.Index)
+	case *ir.ICdstore_0:
+		return store(kindDouble, 0)
+	case *ir.ICdstore_1:
+		return store(kindDouble, 1)
+	case *ir.ICdstore_2:
+		return store(kindDouble, 2)
+	case *ir.ICdstore_3:
+		return store(kindDouble, 3)
+	case *ir.ICastore:
+		return store(kindRef, ic.Index)
+	case *ir.ICastore_0:
+		return store(kindRef, 0)
+	case *ir.ICastore_1:
+		return store(kindRef, 1)
+	case *ir.ICastore_2:
+		return store(kindRef, 2)
+	case *ir.ICastore_3:
+		return store(kindRef, 3)
+	}
+	return 0, 0, 0, false
+}
+
+// constValue returns the Go expression of the constant pushed by the instruction
+func constValue(ic ir.IC) (kind, string, bool) {
+	switch ic := ic.(type) {
+	case *ir.ICaconst_null:
+		return kindRef, "nil", true
+	case *ir.ICiconst_m1:
+		return kindInt, "-1", true
+	case *ir.ICiconst_0:
+		return kindInt, "0", true
+	case *ir.ICiconst_1:
+		return kindInt, "1", true
+	case *ir.ICiconst_2:
+		return kindInt, "2", true
+	case *ir.ICiconst_3:
+		return kindInt, "3", true
+	case *ir.ICiconst_4:
+		return kindInt, "4", true
+	case *ir.ICiconst_5:
+		return kindInt, "5", true
+	case *ir.ICbipush:
+		return kindInt, fmt.Sprint(ic.Value), true
+	case *ir.ICsipush:
+		return kindInt, fmt.Sprint(ic.Value), true
+	case *ir.IClconst_0:
+		return kindLong, "0", true
+	case *ir.IClconst_1:
+		return kindLong, "1", true
+	case *ir.ICfconst_0:
+		return kindFloat, "0", true
+	case *ir.ICfconst_1:
+		return kindFloat, "1", true
+	case *ir.ICfconst_2:
+		return kindFloat, "2", true
+	case *ir.ICdconst_0:
+		return kindDouble, "0", true
+	case *ir.ICdconst_1:
+		return kindDouble, "1", true
+	}
+	return 0, "", false
+}
+
+// binaryOp returns the operand kind and the Go operator of an arithmetic instruction
+func binaryOp(ic ir.IC) (kind, string, bool) {
+	switch ic.(type) {
+	case *ir.ICiadd:
+		return kindInt, "+", true
+	case *ir.ICisub:
+		return kindInt, "-", true
+	case *ir.ICimul:
+		return kindInt, "*", true
+	case *ir.ICidiv:
+		return kindInt, "/", true
+	case *ir.ICirem:
+		return kindInt, "%", true
+	case *ir.ICiand:
+		return kindInt, "&", true
+	case *ir.ICior:
+		return kindInt, "|", true
+	case *ir.ICixor:
+		return kindInt, "^", true
+	case *ir.ICishl:
+		return kindInt, "<<", true
+	case *ir.ICishr:
+		return kindInt, ">>", true
+	case *ir.ICiushr:
+		return kindInt, ">>>", true
+	case *ir.ICladd:
+		return kindLong, "+", true
+	case *ir.IClsub:
+		return kindLong, "-", true
+	case *ir.IClmul:
+		return kindLong, "*", true
+	case *ir.ICldiv:
+		return kindLong, "/", true
+	case *ir.IClrem:
+		return kindLong, "%", true
+	case *ir.ICland:
+		return kindLong, "&", true
+	case *ir.IClor:
+		return kindLong, "|", true
+	case *ir.IClxor:
+		return kindLong, "^", true
+	case *ir.IClshl:
+		return kindLong, "<<", true
+	case *ir.IClshr:
+		return kindLong, ">>", true
+	case *ir.IClushr:
+		return kindLong, ">>>", true
+	case *ir.ICfadd:
+		return kindFloat, "+", true
+	case *ir.ICfsub:
+		return kindFloat, "-", true
+	case *ir.ICfmul:
+		return kindFloat, "*", true
+	case *ir.ICfdiv:
+		return kindFloat, "/", true
+	case *ir.ICfrem:
+		return kindFloat, "%", true
+	case *ir.ICdadd:
+		return kindDouble, "+", true
+	case *ir.ICdsub:
+		return kindDouble, "-", true
+	case *ir.ICdmul:
+		return kindDouble, "*", true
+	case *ir.ICddiv:
+		return kindDouble, "/", true
+	case *ir.ICdrem:
+		return kindDouble, "%", true
+	}
+	return 0, "", false
+}
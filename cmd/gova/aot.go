@@ -0,0 +1,182 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/aot"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+const aotUsage = `Usage: gova aot [flags] <class files, directories or jars> ...
+
+Translate the methods of the classes into a Go source file.
+Import the generated package into the program which runs the VM,
+then the VM executes the translated methods instead of interpreting them.
+
+Flags:
+`
+
+func runAOT(args []string) {
+	flags := flag.NewFlagSet("aot", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), aotUsage)
+		flags.PrintDefaults()
+	}
+	output := flags.String("o", "aot_gen.go", "the output Go source file")
+	pkg := flags.String("pkg", "aotgen", "the package name of the generated source")
+	entry := flags.String("entry", "", "only translate the classes reachable from this class")
+	flags.Parse(args)
+	if flags.NArg() == 0 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	classes := make(map[string]*jcls.Class)
+	for _, path := range flags.Args() {
+		if err := loadClasses(path, classes); err != nil {
+			fmt.Fprintln(os.Stderr, "gova aot:", err)
+			os.Exit(1)
+		}
+	}
+	var names []string
+	if *entry != "" {
+		names = reachableClasses(classes, strings.ReplaceAll(*entry, ".", "/"))
+		if len(names) == 0 {
+			fmt.Fprintf(os.Stderr, "gova aot: entry class %s is not found\n", *entry)
+			os.Exit(1)
+		}
+	} else {
+		for name := range classes {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+	list := make([]*jcls.Class, len(names))
+	for i, name := range names {
+		list[i] = classes[name]
+	}
+
+	out, err := aot.Translate(*pkg, list)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "gova aot:", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(*output, out.Source, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "gova aot:", err)
+		os.Exit(1)
+	}
+	skipped := make([]string, 0, len(out.Skipped))
+	for location := range out.Skipped {
+		skipped = append(skipped, location)
+	}
+	slices.Sort(skipped)
+	for _, location := range skipped {
+		fmt.Fprintf(os.Stderr, "skipped %s: %s\n", location, out.Skipped[location])
+	}
+	fmt.Fprintf(os.Stderr, "translated %d methods of %d classes into %s, skipped %d methods\n", len(out.Compiled), len(list), *output, len(skipped))
+}
+
+// loadClasses parses the class file, the class files under the directory, or the class files in the jar
+func loadClasses(path string, classes map[string]*jcls.Class) error {
+	stat, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return fs.WalkDir(os.DirFS(path), ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() || !strings.HasSuffix(name, ".class") {
+				return err
+			}
+			data, err := os.ReadFile(filepath.Join(path, name))
+			if err != nil {
+				return err
+			}
+			return addClass(filepath.Join(path, name), data, classes)
+		})
+	}
+	switch filepath.Ext(path) {
+	case ".jar", ".zip":
+		zr, err := zip.OpenReader(path)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		for _, f := range zr.File {
+			if !strings.HasSuffix(f.Name, ".class") || strings.HasSuffix(f.Name, "module-info.class") {
+				continue
+			}
+			r, err := f.Open()
+			if err != nil {
+				return err
+			}
+			data, err := io.ReadAll(r)
+			r.Close()
+			if err != nil {
+				return err
+			}
+			if err := addClass(path+"!/"+f.Name, data, classes); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return addClass(path, data, classes)
+}
+
+func addClass(path string, data []byte, classes map[string]*jcls.Class) error {
+	class, err := jcls.ParseClass(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	if _, ok := classes[class.Name()]; ok {
+		return fmt.Errorf("%s: class %s is duplicated", path, class.Name())
+	}
+	classes[class.Name()] = class
+	return nil
+}
+
+// reachableClasses returns the names of the classes which are referenced from the entry class directly or indirectly.
+// Classes outside of the given set are ignored, they will be interpreted.
+func reachableClasses(classes map[string]*jcls.Class, entry string) []string {
+	if classes[entry] == nil {
+		return nil
+	}
+	seen := map[string]bool{entry: true}
+	queue := []string{entry}
+	for len(queue) > 0 {
+		class := classes[queue[0]]
+		queue = queue[1:]
+		for _, c := range class.ConstPool {
+			ref, ok := c.(*jcls.ConstantClass)
+			if !ok {
+				continue
+			}
+			name := strings.TrimLeft(ref.Name, "[")
+			if name != ref.Name {
+				name = strings.TrimSuffix(strings.TrimPrefix(name, "L"), ";")
+			}
+			if !seen[name] && classes[name] != nil {
+				seen[name] = true
+				queue = append(queue, name)
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	return names
+}
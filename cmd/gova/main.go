@@ -17,6 +17,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "aot" {
+		runAOT(os.Args[2:])
+		return
+	}
 	class := os.Args[1]
 	method := "main"
 	class = strings.ReplaceAll(class, ".", "/")
@@ -40,6 +44,10 @@ func main() {
 		Loader:      cl,
 		EntryClass:  class,
 		EntryMethod: "main([Ljava/lang/String;)V",
+		Trace:       os.Getenv("GOVA_TRACE") != "",
+		// the classpath is loaded by the boot loader together with the modules,
+		// so VerifyRemote would not verify any of the application classes
+		Verify: jvm.VerifyAll,
 	})
 
 	fmt.Println("Loading native library ...")
@@ -56,10 +64,7 @@ func main() {
 		vm.GetStack().SetVarRef(0, arr)
 	}
 	fmt.Println("Running ...")
-	for vm.Running() {
-		if err := vm.Step(); err != nil {
-			fmt.Println("VM error:", err)
-			break
-		}
+	if err := vm.Run(); err != nil {
+		fmt.Println("VM error:", err)
 	}
 }
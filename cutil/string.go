@@ -4,8 +4,21 @@ import (
 	"unsafe"
 )
 
+// Pointer converts the native address which Java passes as a long to a pointer
+func Pointer(address int64) unsafe.Pointer {
+	return unsafe.Add(nil, address)
+}
+
+// Bytes returns n bytes of the native memory at the address, or nil if the address is 0
+func Bytes(address int64, n int) []byte {
+	if address == 0 || n <= 0 {
+		return nil
+	}
+	return unsafe.Slice((*byte)(Pointer(address)), n)
+}
+
 func GoString(address int64) string {
-	ptr := unsafe.Pointer((uintptr)(address))
+	ptr := Pointer(address)
 	leng := 0
 	for *(*byte)(unsafe.Add(ptr, leng)) != 0 {
 		leng++
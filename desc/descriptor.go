@@ -303,7 +303,7 @@ type MethodDesc struct {
 }
 
 func ParseMethodDesc(o string) (*MethodDesc, error) {
-	if o[0] != Method {
+	if len(o) == 0 || o[0] != Method {
 		return nil, fmt.Errorf("%w: %s", ErrInvalid, o)
 	}
 	s := o[1:]
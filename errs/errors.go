@@ -6,6 +6,7 @@ import (
 )
 
 var (
+	AbstractMethodError            = errors.New("AbstractMethodError")
 	ArrayIndexOutOfBoundsException = errors.New("ArrayIndexOutOfBoundsException")
 	BootstrapMethodError           = errors.New("BootstrapMethodError")
 	CloneNotSupportedException     = errors.New("CloneNotSupportedException")
@@ -0,0 +1,71 @@
+package errs
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ThrowError asks the VM to throw a new instance of Class
+// with Message as the detail message.
+// Natives can return it from their callback to throw an exception.
+type ThrowError struct {
+	// Class is the internal name of the throwable class, e.g. java/lang/IllegalArgumentException
+	Class string
+	// Message is the detail message, empty means null
+	Message string
+}
+
+func Throw(class string, message string) *ThrowError {
+	return &ThrowError{
+		Class:   class,
+		Message: message,
+	}
+}
+
+func Throwf(class string, format string, args ...any) *ThrowError {
+	return Throw(class, fmt.Sprintf(format, args...))
+}
+
+func (e *ThrowError) Error() string {
+	if e.Message == "" {
+		return e.Class
+	}
+	return e.Class + ": " + e.Message
+}
+
+var sentinelClasses = map[error]string{
+	AbstractMethodError:            "java/lang/AbstractMethodError",
+	ArrayIndexOutOfBoundsException: "java/lang/ArrayIndexOutOfBoundsException",
+	BootstrapMethodError:           "java/lang/BootstrapMethodError",
+	CloneNotSupportedException:     "java/lang/CloneNotSupportedException",
+	IllegalMonitorStateException:   "java/lang/IllegalMonitorStateException",
+	IncompatibleClassChangeError:   "java/lang/IncompatibleClassChangeError",
+	InterruptedException:           "java/lang/InterruptedException",
+	NegativeArraySizeException:     "java/lang/NegativeArraySizeException",
+	NoSuchFieldError:               "java/lang/NoSuchFieldError",
+	NoSuchMethodError:              "java/lang/NoSuchMethodError",
+	NullPointerException:           "java/lang/NullPointerException",
+}
+
+// AsThrowError converts errors defined in this package to the ThrowError
+// which describes the equivalent Java throwable.
+// It returns false if the error does not represent a Java throwable.
+func AsThrowError(err error) (*ThrowError, bool) {
+	if class, ok := sentinelClasses[err]; ok {
+		return Throw(class, ""), true
+	}
+	switch e := err.(type) {
+	case *ThrowError:
+		return e, true
+	case *ClassCastException:
+		return Throwf("java/lang/ClassCastException", "class %s cannot be cast to class %s", e.Have, e.Want), true
+	case *ClassNotFoundException:
+		return Throw("java/lang/ClassNotFoundException", strings.ReplaceAll(e.Class, "/", ".")), true
+	case *UnsatisfiedLinkError:
+		return Throw("java/lang/UnsatisfiedLinkError", e.Name+" is not found"), true
+	}
+	return nil, false
+}
+
+// DivideByZero is returned by integer division and remainder when the divisor is zero
+var DivideByZero = Throw("java/lang/ArithmeticException", "/ by zero")
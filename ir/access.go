@@ -0,0 +1,19 @@
+package ir
+
+import (
+	"github.com/LiterMC/wasm-jdk/errs"
+)
+
+// checkProtectedReceiver checks the receiver of the protected instance member declared in the class c,
+// which is accessed by the class d from another runtime package, see JVMS 4.10.1.8 and 5.4.4.
+// The receiver must be the class d or its subclass, arrays are exempted to allow the access of Object.clone.
+func checkProtectedReceiver(d, c Class, receiver Ref) error {
+	if receiver == nil || d.IsSameRuntimePackage(c) || !c.IsAssignableFrom(d) {
+		return nil
+	}
+	rc := receiver.Class()
+	if rc.ArrayDim() != 0 || d.IsAssignableFrom(rc) {
+		return nil
+	}
+	return errs.Throwf("java/lang/IllegalAccessError", "class %s tried to access protected member of class %s through receiver %s", d.Name(), c.Name(), rc.Name())
+}
@@ -0,0 +1,301 @@
+// Package cfg builds the control flow graph of a method's code.
+//
+// The graph splits the instructions into basic blocks,
+// connects them with the jump, switch, fall through and exception edges,
+// and computes the dominator tree and the natural loops.
+package cfg
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/ops"
+)
+
+type EdgeKind uint8
+
+const (
+	// EdgeFallthrough continues to the next instruction, including the not taken branch of if instructions
+	EdgeFallthrough EdgeKind = iota
+	// EdgeBranch is the taken branch of if instructions, or the target of goto
+	EdgeBranch
+	// EdgeCase is a case of tableswitch or lookupswitch, see Edge.Key
+	EdgeCase
+	// EdgeDefault is the default target of tableswitch or lookupswitch
+	EdgeDefault
+	// EdgeException goes to the exception handler which covers the block, see Edge.Handler
+	EdgeException
+)
+
+func (k EdgeKind) String() string {
+	switch k {
+	case EdgeFallthrough:
+		return "fallthrough"
+	case EdgeBranch:
+		return "branch"
+	case EdgeCase:
+		return "case"
+	case EdgeDefault:
+		return "default"
+	case EdgeException:
+		return "exception"
+	}
+	return fmt.Sprintf("EdgeKind(%d)", k)
+}
+
+type Edge struct {
+	From, To *Block
+	Kind     EdgeKind
+	// Key is the case value of EdgeCase
+	Key int32
+	// Handler is the exception handler of EdgeException
+	Handler *jcls.ExceptionHandlers
+}
+
+func (e *Edge) String() string {
+	return fmt.Sprintf("%s -> %s (%s)", e.From, e.To, e.Kind)
+}
+
+// Block is a basic block, which is only entered at its first instruction,
+// and only leaves at its last instruction or by exceptions.
+type Block struct {
+	// Index is the index in Graph.Blocks
+	Index int
+	// Insts are the instructions of the block, they are a part of jcls.AttrCode.Insts
+	Insts []ir.ICNode
+	Succs []*Edge
+	Preds []*Edge
+	// Idom is the immediate dominator, it is nil for the entry block and the unreachable blocks
+	Idom *Block
+	// Loop is the innermost loop which contains the block, or nil
+	Loop *Loop
+
+	// rpo is the index in the reverse postorder, or -1 if the block is unreachable
+	rpo int
+}
+
+// First returns the first instruction of the block
+func (b *Block) First() *ir.ICNode {
+	return &b.Insts[0]
+}
+
+// Last returns the last instruction of the block
+func (b *Block) Last() *ir.ICNode {
+	return &b.Insts[len(b.Insts)-1]
+}
+
+// Reachable reports whether the block can be reached from the entry block
+func (b *Block) Reachable() bool {
+	return b.rpo >= 0
+}
+
+func (b *Block) String() string {
+	return fmt.Sprintf("B%d@%04x", b.Index, b.First().Offset)
+}
+
+// Graph is the control flow graph of a method's code
+type Graph struct {
+	Code *jcls.AttrCode
+	// Blocks are all basic blocks in bytecode order, Blocks[0] is the entry block
+	Blocks []*Block
+	// Loops are the natural loops, outer loops come before their inner loops
+	Loops []*Loop
+
+	// blockOf maps the instruction index to its block
+	blockOf []*Block
+	// postorder is the reachable blocks in the postorder of the depth first search from the entry
+	postorder []*Block
+}
+
+// New builds the control flow graph of the code.
+// It requires the code's Insts, which are produced by parser.ParseInsts.
+func New(code *jcls.AttrCode) (*Graph, error) {
+	insts := code.Insts
+	if len(insts) == 0 {
+		return nil, fmt.Errorf("cfg: code has no instructions")
+	}
+	indexes := make(map[int32]int, len(insts))
+	for i := range insts {
+		indexes[insts[i].Offset] = i
+	}
+	// at returns the instruction index at the bytecode offset;
+	// the end offset of an exception range may be the code length.
+	at := func(offset int32, end bool) (int, error) {
+		if i, ok := indexes[offset]; ok {
+			return i, nil
+		}
+		if end && offset > insts[len(insts)-1].Offset {
+			return len(insts), nil
+		}
+		return 0, fmt.Errorf("cfg: offset 0x%04x is not at an instruction", offset)
+	}
+
+	leaders := make([]bool, len(insts)+1)
+	leaders[0] = true
+	leaders[len(insts)] = true
+	targets := make([][]int, len(insts))
+	for i := range insts {
+		node := &insts[i]
+		if j, ok := node.IC.(ir.ICJumpable); ok {
+			for _, off := range j.Offsets() {
+				t, err := at(node.Offset+off, false)
+				if err != nil {
+					return nil, err
+				}
+				targets[i] = append(targets[i], t)
+				leaders[t] = true
+			}
+			leaders[i+1] = true
+		} else if !fallsThrough(node.IC.Op()) {
+			leaders[i+1] = true
+		}
+	}
+	handlers := make([][2]int, len(code.Exceptions))
+	for i, e := range code.Exceptions {
+		start, err := at((int32)(e.Start), false)
+		if err != nil {
+			return nil, err
+		}
+		end, err := at((int32)(e.End), true)
+		if err != nil {
+			return nil, err
+		}
+		handler, err := at((int32)(e.Handler), false)
+		if err != nil {
+			return nil, err
+		}
+		handlers[i] = [2]int{start, end}
+		leaders[start] = true
+		leaders[end] = true
+		leaders[handler] = true
+	}
+
+	g := &Graph{
+		Code:    code,
+		blockOf: make([]*Block, len(insts)),
+	}
+	for i := 0; i < len(insts); {
+		j := i + 1
+		for !leaders[j] {
+			j++
+		}
+		b := &Block{
+			Index: len(g.Blocks),
+			Insts: insts[i:j:j],
+			rpo:   -1,
+		}
+		g.Blocks = append(g.Blocks, b)
+		for k := i; k < j; k++ {
+			g.blockOf[k] = b
+		}
+		i = j
+	}
+
+	for _, b := range g.Blocks {
+		last := b.Last()
+		op := last.IC.Op()
+		for k, t := range targets[last.Index] {
+			e := &Edge{From: b, To: g.blockOf[t], Kind: EdgeBranch}
+			switch ic := last.IC.(type) {
+			case *ir.ICtableswitch:
+				if k == 0 {
+					e.Kind = EdgeDefault
+				} else {
+					e.Kind = EdgeCase
+					e.Key = ic.Low + (int32)(k-1)
+				}
+			case *ir.IClookupswitch:
+				if k == 0 {
+					e.Kind = EdgeDefault
+				} else {
+					e.Kind = EdgeCase
+					e.Key = ic.Indexes[k-1].K
+				}
+			}
+			g.addEdge(e)
+		}
+		if fallsThrough(op) {
+			next := (int)(last.Index) + 1
+			if next >= len(insts) {
+				return nil, fmt.Errorf("cfg: instruction %s at 0x%04x falls off the end of the code", op, last.Offset)
+			}
+			g.addEdge(&Edge{From: b, To: g.blockOf[next], Kind: EdgeFallthrough})
+		}
+		first := (int)(b.First().Index)
+		for i, r := range handlers {
+			if r[0] <= first && first < r[1] {
+				e := &code.Exceptions[i]
+				h, _ := at((int32)(e.Handler), false)
+				g.addEdge(&Edge{From: b, To: g.blockOf[h], Kind: EdgeException, Handler: e})
+			}
+		}
+	}
+
+	g.computeOrder()
+	g.computeDominators()
+	g.findLoops()
+	return g, nil
+}
+
+func (g *Graph) addEdge(e *Edge) {
+	e.From.Succs = append(e.From.Succs, e)
+	e.To.Preds = append(e.To.Preds, e)
+}
+
+// fallsThrough reports whether the instruction may continue to the next instruction
+func fallsThrough(op ops.Op) bool {
+	switch op {
+	case ops.Goto, ops.Goto_w, ops.Tableswitch, ops.Lookupswitch, ops.Athrow, ops.Ret,
+		ops.Ireturn, ops.Lreturn, ops.Freturn, ops.Dreturn, ops.Areturn, ops.Return:
+		return false
+	}
+	return true
+}
+
+// Entry returns the entry block
+func (g *Graph) Entry() *Block {
+	return g.Blocks[0]
+}
+
+// BlockOf returns the block which contains the instruction
+func (g *Graph) BlockOf(node *ir.ICNode) *Block {
+	return g.blockOf[node.Index]
+}
+
+// ReversePostorder returns the reachable blocks in reverse postorder,
+// where every block comes before its successors except through back edges.
+func (g *Graph) ReversePostorder() []*Block {
+	blocks := slices.Clone(g.postorder)
+	slices.Reverse(blocks)
+	return blocks
+}
+
+func (g *Graph) computeOrder() {
+	visited := make([]bool, len(g.Blocks))
+	type frame struct {
+		b    *Block
+		next int
+	}
+	stack := []frame{{b: g.Entry()}}
+	visited[0] = true
+	for len(stack) > 0 {
+		top := &stack[len(stack)-1]
+		if top.next < len(top.b.Succs) {
+			to := top.b.Succs[top.next].To
+			top.next++
+			if !visited[to.Index] {
+				visited[to.Index] = true
+				stack = append(stack, frame{b: to})
+			}
+			continue
+		}
+		g.postorder = append(g.postorder, top.b)
+		stack = stack[:len(stack)-1]
+	}
+	n := len(g.postorder)
+	for i, b := range g.postorder {
+		b.rpo = n - 1 - i
+	}
+}
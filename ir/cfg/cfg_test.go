@@ -0,0 +1,198 @@
+package cfg
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/ir/parser"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func newGraph(t *testing.T, code []byte, handlers ...jcls.ExceptionHandlers) *Graph {
+	t.Helper()
+	insts, err := parser.ParseInsts(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g, err := New(&jcls.AttrCode{Code: &insts[0], Insts: insts, Exceptions: handlers})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g
+}
+
+// describe formats the blocks as "offsets -> successors" with the immediate dominators
+func describe(g *Graph) string {
+	var sb strings.Builder
+	for _, b := range g.Blocks {
+		fmt.Fprintf(&sb, "B%d[", b.Index)
+		for i := range b.Insts {
+			if i > 0 {
+				sb.WriteByte(' ')
+			}
+			fmt.Fprintf(&sb, "%d", b.Insts[i].Offset)
+		}
+		sb.WriteString("]")
+		for _, e := range b.Succs {
+			fmt.Fprintf(&sb, " %s:B%d", e.Kind, e.To.Index)
+			if e.Kind == EdgeCase {
+				fmt.Fprintf(&sb, "=%d", e.Key)
+			}
+		}
+		if b.Idom != nil {
+			fmt.Fprintf(&sb, " idom:B%d", b.Idom.Index)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func TestLoop(t *testing.T) {
+	// static int sum(int n) { int s = 0; for (int i = 0; i < n; i++) s += i; return s; }
+	g := newGraph(t, []byte{
+		0x03, 0x3c, 0x03, 0x3d, // iconst_0; istore_1; iconst_0; istore_2
+		0x1c, 0x1a, 0xa2, 0x00, 0x0d, // 4: iload_2; iload_0; if_icmpge 19
+		0x1b, 0x1c, 0x60, 0x3c, // iload_1; iload_2; iadd; istore_1
+		0x84, 0x02, 0x01, // iinc 2, 1
+		0xa7, 0xff, 0xf4, // goto 4
+		0x1b, 0xac, // 19: iload_1; ireturn
+	})
+	want := `B0[0 1 2 3] fallthrough:B1
+B1[4 5 6] branch:B3 fallthrough:B2 idom:B0
+B2[9 10 11 12 13 16] branch:B1 idom:B1
+B3[19 20] idom:B1
+`
+	if got := describe(g); got != want {
+		t.Errorf("blocks:\n%s\nwant:\n%s", got, want)
+	}
+	if len(g.Loops) != 1 {
+		t.Fatalf("found %d loops, want 1", len(g.Loops))
+	}
+	l := g.Loops[0]
+	if l.Header != g.Blocks[1] || len(l.Blocks) != 2 || l.Blocks[1] != g.Blocks[2] || len(l.BackEdges) != 1 || l.BackEdges[0].From != g.Blocks[2] {
+		t.Errorf("unexpected loop: header %s, blocks %v, back edges %v", l.Header, l.Blocks, l.BackEdges)
+	}
+	if exits := l.Exits(); len(exits) != 1 || exits[0].To != g.Blocks[3] {
+		t.Errorf("unexpected loop exits %v", exits)
+	}
+	if !g.Dominates(g.Blocks[1], g.Blocks[3]) || g.Dominates(g.Blocks[2], g.Blocks[3]) {
+		t.Errorf("unexpected dominance")
+	}
+}
+
+func TestNestedLoops(t *testing.T) {
+	// for (int i = 0; i < n; i++) for (int j = 0; j < n; j++) {}
+	g := newGraph(t, []byte{
+		0x03, 0x3c, // iconst_0; istore_1
+		0x1b, 0x1a, 0xa2, 0x00, 0x16, // 2: iload_1; iload_0; if_icmpge 26
+		0x03, 0x3d, // iconst_0; istore_2
+		0x1c, 0x1a, 0xa2, 0x00, 0x09, // 9: iload_2; iload_0; if_icmpge 20
+		0x84, 0x02, 0x01, // iinc 2, 1
+		0xa7, 0xff, 0xf8, // goto 9
+		0x84, 0x01, 0x01, // 20: iinc 1, 1
+		0xa7, 0xff, 0xeb, // goto 2
+		0xb1, // 26: return
+	})
+	if len(g.Loops) != 2 {
+		t.Fatalf("found %d loops, want 2", len(g.Loops))
+	}
+	outer, inner := g.Loops[0], g.Loops[1]
+	if outer.Header != g.Blocks[1] || outer.Depth != 1 || outer.Parent != nil || len(outer.Blocks) != 5 {
+		t.Errorf("unexpected outer loop: header %s, depth %d, blocks %v", outer.Header, outer.Depth, outer.Blocks)
+	}
+	if inner.Header != g.Blocks[3] || inner.Depth != 2 || inner.Parent != outer || len(inner.Blocks) != 2 {
+		t.Errorf("unexpected inner loop: header %s, depth %d, blocks %v", inner.Header, inner.Depth, inner.Blocks)
+	}
+	for i, want := range []*Loop{nil, outer, outer, inner, inner, outer, nil} {
+		if got := g.Blocks[i].Loop; got != want {
+			t.Errorf("B%d is in loop %v, want %v", i, got, want)
+		}
+	}
+
+	dot := g.DOT("nested")
+	for _, s := range []string{
+		`digraph "nested" {`,
+		`B3 [label="B3 (loop depth 2)\l0009: iload_2\l000a: iload_0\l000b: if_icmpge\l" style=bold];`,
+		`B4 -> B3 [label="T" color=red];`,
+		`B1 -> B2;`,
+	} {
+		if !strings.Contains(dot, s) {
+			t.Errorf("DOT output does not contain %q:\n%s", s, dot)
+		}
+	}
+}
+
+func TestSwitch(t *testing.T) {
+	// switch (k) { case 0: return 1; case 1: return 2; default: return -1; }
+	g := newGraph(t, []byte{
+		0x1a,             // iload_0
+		0xaa, 0x00, 0x00, // tableswitch
+		0x00, 0x00, 0x00, 0x1b, // default: 28
+		0x00, 0x00, 0x00, 0x00, // low: 0
+		0x00, 0x00, 0x00, 0x01, // high: 1
+		0x00, 0x00, 0x00, 0x17, // 0: 24
+		0x00, 0x00, 0x00, 0x19, // 1: 26
+		0x04, 0xac, 0x05, 0xac, 0x02, 0xac, // iconst_1; ireturn; iconst_2; ireturn; iconst_m1; ireturn
+	})
+	want := `B0[0 1] default:B3 case:B1=0 case:B2=1
+B1[24 25] idom:B0
+B2[26 27] idom:B0
+B3[28 29] idom:B0
+`
+	if got := describe(g); got != want {
+		t.Errorf("blocks:\n%s\nwant:\n%s", got, want)
+	}
+	if len(g.Loops) != 0 {
+		t.Errorf("found %d loops, want 0", len(g.Loops))
+	}
+}
+
+func TestException(t *testing.T) {
+	g := newGraph(t, []byte{
+		0x01, 0xbf, // aconst_null; athrow
+		0x4b, 0xb1, // 2: astore_0; return
+		0xb1, // 4: return
+	}, jcls.ExceptionHandlers{Start: 0, End: 2, Handler: 2})
+	want := `B0[0 1] exception:B1
+B1[2 3] idom:B0
+B2[4]
+`
+	if got := describe(g); got != want {
+		t.Errorf("blocks:\n%s\nwant:\n%s", got, want)
+	}
+	if g.Blocks[2].Reachable() || !g.Blocks[1].Reachable() {
+		t.Errorf("unexpected reachability")
+	}
+	if dot := g.DOT("exception"); !strings.Contains(dot, `B0 -> B1 [style=dashed label="any"];`) {
+		t.Errorf("DOT output does not contain the exception edge:\n%s", dot)
+	}
+}
+
+func TestClassFile(t *testing.T) {
+	fd, err := os.Open("../../jcls/testdata/Test.class")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fd.Close()
+	class, err := jcls.ParseClass(fd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range class.Methods {
+		if m.Code == nil {
+			continue
+		}
+		g, err := New(m.Code)
+		if err != nil {
+			t.Errorf("%s: %v", m.Name(), err)
+			continue
+		}
+		for _, b := range g.Blocks {
+			if !b.Reachable() {
+				t.Errorf("%s: block %s is unreachable", m.Name(), b)
+			}
+		}
+	}
+}
@@ -0,0 +1,168 @@
+package cfg
+
+import (
+	"slices"
+)
+
+// Loop is a natural loop, which is formed by the back edges to its header.
+// Irreducible cycles, which are entered at more than one block, are not loops.
+type Loop struct {
+	// Header is the only entry of the loop, it dominates all blocks of the loop
+	Header *Block
+	// Blocks are the blocks of the loop including the header and the inner loops' blocks, in bytecode order
+	Blocks []*Block
+	// BackEdges are the edges from inside the loop to the header
+	BackEdges []*Edge
+	// Parent is the innermost loop which contains this loop, or nil
+	Parent *Loop
+	// Depth is the nesting depth of the loop, which is 1 for the outermost loops
+	Depth int
+}
+
+// Contains reports whether the block is in the loop
+func (l *Loop) Contains(b *Block) bool {
+	_, ok := slices.BinarySearchFunc(l.Blocks, b.Index, func(x *Block, i int) int { return x.Index - i })
+	return ok
+}
+
+// Exits returns the edges which leave the loop
+func (l *Loop) Exits() []*Edge {
+	var exits []*Edge
+	for _, b := range l.Blocks {
+		for _, e := range b.Succs {
+			if !l.Contains(e.To) {
+				exits = append(exits, e)
+			}
+		}
+	}
+	return exits
+}
+
+// computeDominators computes the immediate dominators with the algorithm in
+// "A Simple, Fast Dominance Algorithm" by Cooper, Harvey and Kennedy.
+func (g *Graph) computeDominators() {
+	rpo := g.ReversePostorder()
+	entry := g.Entry()
+	// idoms are indexed by the reverse postorder, so the entry is 0
+	idoms := make([]int, len(rpo))
+	for i := range idoms {
+		idoms[i] = -1
+	}
+	idoms[0] = 0
+	intersect := func(a, b int) int {
+		for a != b {
+			for a > b {
+				a = idoms[a]
+			}
+			for b > a {
+				b = idoms[b]
+			}
+		}
+		return a
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, b := range rpo[1:] {
+			idom := -1
+			for _, e := range b.Preds {
+				p := e.From.rpo
+				if p < 0 || idoms[p] < 0 {
+					continue
+				}
+				if idom < 0 {
+					idom = p
+				} else {
+					idom = intersect(p, idom)
+				}
+			}
+			if idoms[b.rpo] != idom {
+				idoms[b.rpo] = idom
+				changed = true
+			}
+		}
+	}
+	for _, b := range rpo {
+		if b != entry {
+			b.Idom = rpo[idoms[b.rpo]]
+		}
+	}
+}
+
+// Dominates reports whether every path from the entry to b goes through a.
+// A block dominates itself, and unreachable blocks are not dominated by any block.
+func (g *Graph) Dominates(a, b *Block) bool {
+	if !a.Reachable() || !b.Reachable() {
+		return false
+	}
+	for ; b != nil; b = b.Idom {
+		if b == a {
+			return true
+		}
+		if b.rpo < a.rpo {
+			return false
+		}
+	}
+	return false
+}
+
+// findLoops finds the natural loops by their back edges, whose target dominates their source
+func (g *Graph) findLoops() {
+	headers := make(map[*Block]*Loop)
+	for _, b := range g.ReversePostorder() {
+		for _, e := range b.Preds {
+			if !g.Dominates(b, e.From) {
+				continue
+			}
+			l := headers[b]
+			if l == nil {
+				l = &Loop{Header: b}
+				headers[b] = l
+				g.Loops = append(g.Loops, l)
+			}
+			l.BackEdges = append(l.BackEdges, e)
+		}
+	}
+	for _, l := range g.Loops {
+		// walk backwards from the back edges' sources until the header
+		in := map[*Block]bool{l.Header: true}
+		var work []*Block
+		for _, e := range l.BackEdges {
+			if !in[e.From] {
+				in[e.From] = true
+				work = append(work, e.From)
+			}
+		}
+		for len(work) > 0 {
+			b := work[len(work)-1]
+			work = work[:len(work)-1]
+			for _, e := range b.Preds {
+				if p := e.From; p.Reachable() && !in[p] {
+					in[p] = true
+					work = append(work, p)
+				}
+			}
+		}
+		for b := range in {
+			l.Blocks = append(l.Blocks, b)
+		}
+		slices.SortFunc(l.Blocks, func(a, b *Block) int { return a.Index - b.Index })
+	}
+	// the loops are ordered by their headers in reverse postorder,
+	// so an outer loop is visited before the loops inside it
+	for i, l := range g.Loops {
+		for _, outer := range slices.Backward(g.Loops[:i]) {
+			if outer.Contains(l.Header) {
+				l.Parent = outer
+				break
+			}
+		}
+		if l.Parent != nil {
+			l.Depth = l.Parent.Depth + 1
+		} else {
+			l.Depth = 1
+		}
+		for _, b := range l.Blocks {
+			b.Loop = l
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package cfg
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// WriteDOT writes the graph in the Graphviz DOT language for debugging.
+// Blocks list their instructions, loop headers are drawn in bold,
+// exception edges are dashed, and back edges are red.
+func (g *Graph) WriteDOT(w io.Writer, name string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "digraph %s {\n", dotQuote(name))
+	bw.WriteString("\tnode [shape=box fontname=monospace];\n")
+	for _, b := range g.Blocks {
+		var label strings.Builder
+		fmt.Fprintf(&label, "B%d", b.Index)
+		if b.Loop != nil {
+			fmt.Fprintf(&label, " (loop depth %d)", b.Loop.Depth)
+		}
+		label.WriteString(`\l`)
+		for i := range b.Insts {
+			node := &b.Insts[i]
+			fmt.Fprintf(&label, "%04x: %s\\l", node.Offset, node.IC.Op())
+		}
+		var attrs string
+		if b.Loop != nil && b.Loop.Header == b {
+			attrs += " style=bold"
+		}
+		if !b.Reachable() {
+			attrs += " color=gray fontcolor=gray"
+		}
+		fmt.Fprintf(bw, "\tB%d [label=\"%s\"%s];\n", b.Index, label.String(), attrs)
+	}
+	for _, b := range g.Blocks {
+		for _, e := range b.Succs {
+			var attrs []string
+			switch e.Kind {
+			case EdgeBranch:
+				attrs = append(attrs, `label="T"`)
+			case EdgeCase:
+				attrs = append(attrs, fmt.Sprintf(`label="%d"`, e.Key))
+			case EdgeDefault:
+				attrs = append(attrs, `label="default"`)
+			case EdgeException:
+				class := e.Handler.Class
+				if class == "" {
+					class = "any"
+				}
+				attrs = append(attrs, "style=dashed", "label="+dotQuote(class))
+			}
+			if e.To.Loop != nil && e.To.Loop.Header == e.To && g.Dominates(e.To, e.From) {
+				attrs = append(attrs, "color=red")
+			}
+			fmt.Fprintf(bw, "\tB%d -> B%d", e.From.Index, e.To.Index)
+			if len(attrs) > 0 {
+				fmt.Fprintf(bw, " [%s]", strings.Join(attrs, " "))
+			}
+			bw.WriteString(";\n")
+		}
+	}
+	bw.WriteString("}\n")
+	return bw.Flush()
+}
+
+// DOT returns the graph in the Graphviz DOT language, see WriteDOT
+func (g *Graph) DOT(name string) string {
+	var sb strings.Builder
+	g.WriteDOT(&sb, name)
+	return sb.String()
+}
+
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
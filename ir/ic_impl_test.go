@@ -0,0 +1,415 @@
+package ir_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vm/vmtest"
+)
+
+type testVM struct {
+	ir.VM
+	stack *vm.Stack
+}
+
+func (v *testVM) GetStack() ir.Stack {
+	return v.stack
+}
+
+func execute(ic ir.IC, push func(ir.Stack)) (ir.Stack, error) {
+	v := &testVM{stack: new(vm.Stack)}
+	push(v.stack)
+	return v.stack, ic.Execute(v)
+}
+
+var (
+	nanF32 = float32(math.NaN())
+	nanF64 = math.NaN()
+	infF32 = float32(math.Inf(1))
+	infF64 = math.Inf(1)
+)
+
+func TestIntArithmetic(t *testing.T) {
+	var datas = []struct {
+		IC   ir.IC
+		A, B int32
+		R    int32
+		E    error
+	}{
+		{&ir.ICiadd{}, math.MaxInt32, 1, math.MinInt32, nil},
+		{&ir.ICisub{}, math.MinInt32, 1, math.MaxInt32, nil},
+		{&ir.ICimul{}, 0x10000, 0x10000, 0, nil},
+		{&ir.ICidiv{}, 7, -2, -3, nil},
+		{&ir.ICidiv{}, -7, 2, -3, nil},
+		{&ir.ICidiv{}, math.MinInt32, -1, math.MinInt32, nil},
+		{&ir.ICidiv{}, 1, 0, 0, errs.DivideByZero},
+		{&ir.ICirem{}, 7, -2, 1, nil},
+		{&ir.ICirem{}, -7, 2, -1, nil},
+		{&ir.ICirem{}, math.MinInt32, -1, 0, nil},
+		{&ir.ICirem{}, 1, 0, 0, errs.DivideByZero},
+		{&ir.ICishl{}, 1, 33, 2, nil},
+		{&ir.ICishl{}, 1, -1, math.MinInt32, nil},
+		{&ir.ICishr{}, -8, 33, -4, nil},
+		{&ir.ICiushr{}, -1, 28, 0xf, nil},
+		{&ir.ICiushr{}, -1, 60, 0xf, nil},
+		{&ir.ICiand{}, 0x0ff0, 0x00ff, 0x00f0, nil},
+		{&ir.ICior{}, 0x0ff0, 0x00ff, 0x0fff, nil},
+		{&ir.ICixor{}, 0x0ff0, 0x00ff, 0x0f0f, nil},
+	}
+	for _, d := range datas {
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			s.PushInt32(d.A)
+			s.PushInt32(d.B)
+		})
+		if err != d.E {
+			t.Errorf("%s %d, %d: error %v, want %v", d.IC.Op(), d.A, d.B, err, d.E)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if r := stack.PopInt32(); r != d.R {
+			t.Errorf("%s %d, %d: got %d, want %d", d.IC.Op(), d.A, d.B, r, d.R)
+		}
+	}
+}
+
+func TestLongArithmetic(t *testing.T) {
+	var datas = []struct {
+		IC   ir.IC
+		A, B int64
+		R    int64
+		E    error
+	}{
+		{&ir.ICladd{}, math.MaxInt64, 1, math.MinInt64, nil},
+		{&ir.IClsub{}, math.MinInt64, 1, math.MaxInt64, nil},
+		{&ir.IClmul{}, 1 << 32, 1 << 32, 0, nil},
+		{&ir.ICldiv{}, 7, -2, -3, nil},
+		{&ir.ICldiv{}, math.MinInt64, -1, math.MinInt64, nil},
+		{&ir.ICldiv{}, 1, 0, 0, errs.DivideByZero},
+		{&ir.IClrem{}, -7, 2, -1, nil},
+		{&ir.IClrem{}, math.MinInt64, -1, 0, nil},
+		{&ir.IClrem{}, 1, 0, 0, errs.DivideByZero},
+		{&ir.ICland{}, 0x0ff0, 0x00ff, 0x00f0, nil},
+		{&ir.IClor{}, 0x0ff0, 0x00ff, 0x0fff, nil},
+		{&ir.IClxor{}, 0x0ff0, 0x00ff, 0x0f0f, nil},
+	}
+	for _, d := range datas {
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			s.PushInt64(d.A)
+			s.PushInt64(d.B)
+		})
+		if err != d.E {
+			t.Errorf("%s %d, %d: error %v, want %v", d.IC.Op(), d.A, d.B, err, d.E)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if r := stack.PopInt64(); r != d.R {
+			t.Errorf("%s %d, %d: got %d, want %d", d.IC.Op(), d.A, d.B, r, d.R)
+		}
+	}
+}
+
+func TestLongShift(t *testing.T) {
+	var datas = []struct {
+		IC ir.IC
+		A  int64
+		B  int32
+		R  int64
+	}{
+		{&ir.IClshl{}, 1, 65, 2},
+		{&ir.IClshl{}, 1, 63, math.MinInt64},
+		{&ir.IClshl{}, 1, -1, math.MinInt64},
+		{&ir.IClshr{}, -8, 65, -4},
+		{&ir.IClshr{}, math.MinInt64, 63, -1},
+		{&ir.IClushr{}, -1, 60, 0xf},
+		{&ir.IClushr{}, -1, 124, 0xf},
+		{&ir.IClushr{}, math.MinInt64, 63, 1},
+	}
+	for _, d := range datas {
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			s.PushInt64(d.A)
+			s.PushInt32(d.B)
+		})
+		if err != nil {
+			t.Errorf("%s %d, %d: unexpected error %v", d.IC.Op(), d.A, d.B, err)
+			continue
+		}
+		if r := stack.PopInt64(); r != d.R {
+			t.Errorf("%s %d, %d: got %d, want %d", d.IC.Op(), d.A, d.B, r, d.R)
+		}
+	}
+}
+
+func TestFloatCompare(t *testing.T) {
+	var datas = []struct {
+		IC   ir.IC
+		A, B float64
+		R    int32
+	}{
+		{&ir.ICfcmpl{}, 1, 2, -1},
+		{&ir.ICfcmpl{}, 2, 1, 1},
+		{&ir.ICfcmpl{}, 0, math.Copysign(0, -1), 0},
+		{&ir.ICfcmpl{}, nanF64, 1, -1},
+		{&ir.ICfcmpl{}, 1, nanF64, -1},
+		{&ir.ICfcmpg{}, nanF64, 1, 1},
+		{&ir.ICfcmpg{}, 1, nanF64, 1},
+		{&ir.ICfcmpg{}, -infF64, infF64, -1},
+		{&ir.ICdcmpl{}, 1, 2, -1},
+		{&ir.ICdcmpl{}, nanF64, nanF64, -1},
+		{&ir.ICdcmpg{}, nanF64, nanF64, 1},
+		{&ir.ICdcmpg{}, infF64, infF64, 0},
+	}
+	for _, d := range datas {
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			switch d.IC.(type) {
+			case *ir.ICfcmpl, *ir.ICfcmpg:
+				s.PushFloat32((float32)(d.A))
+				s.PushFloat32((float32)(d.B))
+			default:
+				s.PushFloat64(d.A)
+				s.PushFloat64(d.B)
+			}
+		})
+		if err != nil {
+			t.Errorf("%s %v, %v: unexpected error %v", d.IC.Op(), d.A, d.B, err)
+			continue
+		}
+		if r := stack.PopInt32(); r != d.R {
+			t.Errorf("%s %v, %v: got %d, want %d", d.IC.Op(), d.A, d.B, r, d.R)
+		}
+	}
+}
+
+func TestFloatArithmetic(t *testing.T) {
+	var datas = []struct {
+		IC   ir.IC
+		A, B float64
+		R    float64
+	}{
+		{&ir.ICdadd{}, infF64, -infF64, nanF64},
+		{&ir.ICddiv{}, 1, 0, infF64},
+		{&ir.ICddiv{}, -1, 0, -infF64},
+		{&ir.ICddiv{}, 0, 0, nanF64},
+		{&ir.ICdrem{}, 5.5, 2, 1.5},
+		{&ir.ICdrem{}, -5.5, 2, -1.5},
+		{&ir.ICdrem{}, 1, 0, nanF64},
+		{&ir.ICdrem{}, 1, infF64, 1},
+		{&ir.ICfdiv{}, 1, 0, infF64},
+		{&ir.ICfrem{}, -5.5, 2, -1.5},
+		{&ir.ICfrem{}, 1, 0, nanF64},
+	}
+	for _, d := range datas {
+		var isFloat bool
+		switch d.IC.(type) {
+		case *ir.ICfadd, *ir.ICfsub, *ir.ICfmul, *ir.ICfdiv, *ir.ICfrem:
+			isFloat = true
+		}
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			if isFloat {
+				s.PushFloat32((float32)(d.A))
+				s.PushFloat32((float32)(d.B))
+			} else {
+				s.PushFloat64(d.A)
+				s.PushFloat64(d.B)
+			}
+		})
+		if err != nil {
+			t.Errorf("%s %v, %v: unexpected error %v", d.IC.Op(), d.A, d.B, err)
+			continue
+		}
+		var r float64
+		if isFloat {
+			r = (float64)(stack.PopFloat32())
+		} else {
+			r = stack.PopFloat64()
+		}
+		if r != d.R && !(r != r && d.R != d.R) {
+			t.Errorf("%s %v, %v: got %v, want %v", d.IC.Op(), d.A, d.B, r, d.R)
+		}
+	}
+}
+
+func TestFloatToInt(t *testing.T) {
+	var datas = []struct {
+		IC ir.IC
+		V  float64
+		R  int64
+	}{
+		{&ir.ICf2i{}, 1.9, 1},
+		{&ir.ICf2i{}, -1.9, -1},
+		{&ir.ICf2i{}, (float64)(nanF32), 0},
+		{&ir.ICf2i{}, (float64)(infF32), math.MaxInt32},
+		{&ir.ICf2i{}, -(float64)(infF32), math.MinInt32},
+		{&ir.ICf2i{}, 3e9, math.MaxInt32},
+		{&ir.ICf2i{}, -3e9, math.MinInt32},
+		{&ir.ICf2l{}, (float64)(nanF32), 0},
+		{&ir.ICf2l{}, 1e19, math.MaxInt64},
+		{&ir.ICf2l{}, -1e19, math.MinInt64},
+		{&ir.ICd2i{}, 2147483647.5, math.MaxInt32},
+		{&ir.ICd2i{}, -2147483648.5, math.MinInt32},
+		{&ir.ICd2i{}, nanF64, 0},
+		{&ir.ICd2i{}, 1e300, math.MaxInt32},
+		{&ir.ICd2l{}, -2.5, -2},
+		{&ir.ICd2l{}, nanF64, 0},
+		{&ir.ICd2l{}, infF64, math.MaxInt64},
+		{&ir.ICd2l{}, -infF64, math.MinInt64},
+		{&ir.ICd2l{}, 0x1p63, math.MaxInt64},
+		{&ir.ICd2l{}, -0x1p63, math.MinInt64},
+	}
+	for _, d := range datas {
+		var r int64
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			switch d.IC.(type) {
+			case *ir.ICf2i, *ir.ICf2l:
+				s.PushFloat32((float32)(d.V))
+			default:
+				s.PushFloat64(d.V)
+			}
+		})
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", d.IC.Op(), d.V, err)
+			continue
+		}
+		switch d.IC.(type) {
+		case *ir.ICf2i, *ir.ICd2i:
+			r = (int64)(stack.PopInt32())
+		default:
+			r = stack.PopInt64()
+		}
+		if r != d.R {
+			t.Errorf("%s %v: got %d, want %d", d.IC.Op(), d.V, r, d.R)
+		}
+	}
+}
+
+func TestIntConversion(t *testing.T) {
+	var datas = []struct {
+		IC ir.IC
+		V  int64
+		R  int64
+	}{
+		{&ir.ICi2b{}, 0xff, -1},
+		{&ir.ICi2b{}, 0x17f, 0x7f},
+		{&ir.ICi2c{}, -1, 0xffff},
+		{&ir.ICi2s{}, 0x18000, -0x8000},
+		{&ir.ICi2l{}, -1, -1},
+		{&ir.ICl2i{}, 0x1_8000_0000, math.MinInt32},
+	}
+	for _, d := range datas {
+		var r int64
+		stack, err := execute(d.IC, func(s ir.Stack) {
+			if _, ok := d.IC.(*ir.ICl2i); ok {
+				s.PushInt64(d.V)
+			} else {
+				s.PushInt32((int32)(d.V))
+			}
+		})
+		if err != nil {
+			t.Errorf("%s %v: unexpected error %v", d.IC.Op(), d.V, err)
+			continue
+		}
+		if _, ok := d.IC.(*ir.ICi2l); ok {
+			r = stack.PopInt64()
+		} else {
+			r = (int64)(stack.PopInt32())
+		}
+		if r != d.R {
+			t.Errorf("%s %v: got %d, want %d", d.IC.Op(), d.V, r, d.R)
+		}
+	}
+}
+
+func TestArrayLoadStore(t *testing.T) {
+	jvm := vmtest.NewVM(vm.Options{})
+	shorts := jvm.NewArray(desc.DescShortArray, 3)
+	longs := jvm.NewArray(desc.DescLongArray, 2)
+	longs.GetInt64Arr()[1] = 0x1_2345_6789
+
+	// a sentinel below the operands checks that exactly the operands are popped
+	const sentinel = 0x5a5a
+	run := func(ic ir.IC, push func(ir.Stack)) (ir.Stack, error) {
+		return execute(ic, func(s ir.Stack) {
+			s.PushInt32(sentinel)
+			push(s)
+		})
+	}
+	checkSentinel := func(op ir.IC, stack ir.Stack) {
+		t.Helper()
+		if v := stack.PopInt32(); v != sentinel {
+			t.Errorf("%s: popped %#x below the result, want the sentinel", op.Op(), v)
+		}
+	}
+
+	// sastore pops the value, the index and then the array
+	sastore := &ir.ICsastore{}
+	stack, err := run(sastore, func(s ir.Stack) {
+		s.PushRef(shorts)
+		s.PushInt32(1)
+		s.PushInt32(-2)
+	})
+	if err != nil {
+		t.Fatalf("sastore: unexpected error %v", err)
+	}
+	checkSentinel(sastore, stack)
+	if got := shorts.GetInt16Arr(); got[0] != 0 || got[1] != -2 || got[2] != 0 {
+		t.Errorf("sastore: array is %v, want [0 -2 0]", got)
+	}
+
+	saload := &ir.ICsaload{}
+	stack, err = run(saload, func(s ir.Stack) {
+		s.PushRef(shorts)
+		s.PushInt32(1)
+	})
+	if err != nil {
+		t.Fatalf("saload: unexpected error %v", err)
+	}
+	if v := stack.PopInt32(); v != -2 {
+		t.Errorf("saload: got %d, want -2", v)
+	}
+	checkSentinel(saload, stack)
+
+	// the index of laload is an int, not a long
+	laload := &ir.IClaload{}
+	stack, err = run(laload, func(s ir.Stack) {
+		s.PushRef(longs)
+		s.PushInt32(1)
+	})
+	if err != nil {
+		t.Fatalf("laload: unexpected error %v", err)
+	}
+	if v := stack.PopInt64(); v != 0x1_2345_6789 {
+		t.Errorf("laload: got %#x, want 0x123456789", v)
+	}
+	checkSentinel(laload, stack)
+
+	for _, d := range []struct {
+		IC   ir.IC
+		Arr  ir.Ref
+		I    int32
+		Push bool
+		E    error
+	}{
+		{&ir.ICsaload{}, shorts, 3, false, errs.ArrayIndexOutOfBoundsException},
+		{&ir.ICsaload{}, shorts, -1, false, errs.ArrayIndexOutOfBoundsException},
+		{&ir.ICsastore{}, shorts, 3, true, errs.ArrayIndexOutOfBoundsException},
+		{&ir.IClaload{}, longs, 2, false, errs.ArrayIndexOutOfBoundsException},
+	} {
+		_, err := run(d.IC, func(s ir.Stack) {
+			s.PushRef(d.Arr)
+			s.PushInt32(d.I)
+			if d.Push {
+				s.PushInt32(1)
+			}
+		})
+		if err != d.E {
+			t.Errorf("%s %d: error %v, want %v", d.IC.Op(), d.I, err, d.E)
+		}
+	}
+}
@@ -234,14 +234,19 @@ type ICgetfield struct {
 func (*ICgetfield) Op() ops.Op { return ops.Getfield }
 func (ic *ICgetfield) Execute(vm VM) error {
 	stack := vm.GetStack()
-	field := vm.GetCurrentClass().GetField(vm, ic.Field)
-	if field == nil {
-		return errs.NoSuchFieldError
+	current := vm.GetCurrentClass()
+	field, err := current.GetField(vm, ic.Field)
+	if err != nil {
+		return err
 	}
 	if field.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if field.IsProtected() {
+		if err := checkProtectedReceiver(current, field.GetDeclaringClass(), stack.PeekRef()); err != nil {
+			return err
+		}
+	}
 	return field.GetAndPush(stack)
 }
 
@@ -252,14 +257,16 @@ type ICgetstatic struct {
 func (*ICgetstatic) Op() ops.Op { return ops.Getstatic }
 func (ic *ICgetstatic) Execute(vm VM) error {
 	stack := vm.GetStack()
-	field := vm.GetCurrentClass().GetField(vm, ic.Field)
-	if field == nil {
-		return errs.NoSuchFieldError
+	field, err := vm.GetCurrentClass().GetField(vm, ic.Field)
+	if err != nil {
+		return err
 	}
 	if !field.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if err := vm.InitClass(field.GetDeclaringClass()); err != nil {
+		return err
+	}
 	return field.GetAndPush(stack)
 }
 
@@ -299,17 +306,14 @@ type ICinvokeinterface struct {
 
 func (*ICinvokeinterface) Op() ops.Op { return ops.Invokeinterface }
 func (ic *ICinvokeinterface) Execute(vm VM) error {
-	method := vm.GetCurrentClass().GetMethod(vm, ic.Method)
-	if method == nil {
-		return errs.NoSuchMethodError
+	method, err := vm.GetCurrentClass().GetMethod(vm, ic.Method)
+	if err != nil {
+		return err
 	}
 	if method.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
-	// TODO: use interface table instead
-	vm.InvokeVirtual(method)
-	return nil
+	return vm.InvokeVirtual(method)
 }
 
 type ICinvokespecial struct {
@@ -318,14 +322,20 @@ type ICinvokespecial struct {
 
 func (*ICinvokespecial) Op() ops.Op { return ops.Invokespecial }
 func (ic *ICinvokespecial) Execute(vm VM) error {
-	method := vm.GetCurrentClass().GetMethod(vm, ic.Method)
-	if method == nil {
-		return errs.NoSuchMethodError
+	current := vm.GetCurrentClass()
+	method, err := current.GetMethod(vm, ic.Method)
+	if err != nil {
+		return err
 	}
 	if method.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if method.IsProtected() && !method.IsConstructor() {
+		receiver := vm.GetStack().PeekRefAt(method.Desc().InputSlots())
+		if err := checkProtectedReceiver(current, method.GetDeclaringClass(), receiver); err != nil {
+			return err
+		}
+	}
 	vm.Invoke(method)
 	return nil
 }
@@ -336,14 +346,16 @@ type ICinvokestatic struct {
 
 func (*ICinvokestatic) Op() ops.Op { return ops.Invokestatic }
 func (ic *ICinvokestatic) Execute(vm VM) error {
-	method := vm.GetCurrentClass().GetMethod(vm, ic.Method)
-	if method == nil {
-		return errs.NoSuchMethodError
+	method, err := vm.GetCurrentClass().GetMethod(vm, ic.Method)
+	if err != nil {
+		return err
 	}
 	if !method.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if err := vm.InitClass(method.GetDeclaringClass()); err != nil {
+		return err
+	}
 	vm.InvokeStatic(method)
 	return nil
 }
@@ -354,17 +366,21 @@ type ICinvokevirtual struct {
 
 func (*ICinvokevirtual) Op() ops.Op { return ops.Invokevirtual }
 func (ic *ICinvokevirtual) Execute(vm VM) error {
-	method := vm.GetCurrentClass().GetMethod(vm, ic.Method)
-	if method == nil {
-		return errs.NoSuchMethodError
+	current := vm.GetCurrentClass()
+	method, err := current.GetMethod(vm, ic.Method)
+	if err != nil {
+		return err
 	}
 	if method.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
-	// TODO: use virtual method table
-	vm.InvokeVirtual(method)
-	return nil
+	if method.IsProtected() {
+		receiver := vm.GetStack().PeekRefAt(method.Desc().InputSlots())
+		if err := checkProtectedReceiver(current, method.GetDeclaringClass(), receiver); err != nil {
+			return err
+		}
+	}
+	return vm.InvokeVirtual(method)
 }
 
 type ICmultianewarray struct {
@@ -402,6 +418,9 @@ func (ic *ICnew) Execute(vm VM) error {
 	if err != nil {
 		return err
 	}
+	if err := vm.InitClass(class); err != nil {
+		return err
+	}
 	ref := vm.New(class)
 	vm.GetStack().PushRef(ref)
 	return nil
@@ -442,14 +461,20 @@ type ICputfield struct {
 func (*ICputfield) Op() ops.Op { return ops.Putfield }
 func (ic *ICputfield) Execute(vm VM) error {
 	stack := vm.GetStack()
-	field := vm.GetCurrentClass().GetField(vm, ic.Field)
-	if field == nil {
-		return errs.NoSuchFieldError
+	current := vm.GetCurrentClass()
+	field, err := current.GetField(vm, ic.Field)
+	if err != nil {
+		return err
 	}
 	if field.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if field.IsProtected() {
+		receiver := stack.PeekRefAt(field.Slot())
+		if err := checkProtectedReceiver(current, field.GetDeclaringClass(), receiver); err != nil {
+			return err
+		}
+	}
 	return field.PopAndSet(stack)
 }
 
@@ -460,14 +485,16 @@ type ICputstatic struct {
 func (*ICputstatic) Op() ops.Op { return ops.Putstatic }
 func (ic *ICputstatic) Execute(vm VM) error {
 	stack := vm.GetStack()
-	field := vm.GetCurrentClass().GetField(vm, ic.Field)
-	if field == nil {
-		return errs.NoSuchFieldError
+	field, err := vm.GetCurrentClass().GetField(vm, ic.Field)
+	if err != nil {
+		return err
 	}
 	if !field.IsStatic() {
 		return errs.IncompatibleClassChangeError
 	}
-	// TODO: access control
+	if err := vm.InitClass(field.GetDeclaringClass()); err != nil {
+		return err
+	}
 	field.PopAndSet(stack)
 	return nil
 }
@@ -45,8 +45,8 @@ type ICsaload struct{}
 func (*ICsaload) Op() ops.Op { return ops.Saload }
 func (*ICsaload) Execute(vm VM) error {
 	stack := vm.GetStack()
-	arr := stack.PopRef().GetInt16Arr()
 	index := stack.PopInt32()
+	arr := stack.PopRef().GetInt16Arr()
 	if arr == nil {
 		return errs.NullPointerException
 	}
@@ -62,9 +62,9 @@ type ICsastore struct{}
 func (*ICsastore) Op() ops.Op { return ops.Sastore }
 func (*ICsastore) Execute(vm VM) error {
 	stack := vm.GetStack()
-	arr := stack.PopRef().GetInt16Arr()
-	index := stack.PopInt32()
 	value := stack.PopInt16()
+	index := stack.PopInt32()
+	arr := stack.PopRef().GetInt16Arr()
 	if arr == nil {
 		return errs.NullPointerException
 	}
@@ -23,28 +23,48 @@ func (*ICd2i) Op() ops.Op { return ops.D2i }
 func (*ICd2i) Execute(vm VM) error {
 	stack := vm.GetStack()
 	value := stack.PopFloat64()
-	if value != value {
-		stack.PushInt32(0)
-	} else {
-		stack.PushInt32((int32)(value))
-	}
+	stack.PushInt32(d2i(value))
 	return nil
 }
 
+// d2i converts the value to int32 with JVMS semantics,
+// NaN becomes zero and the values out of range are saturated
+func d2i(value float64) int32 {
+	switch {
+	case value != value:
+		return 0
+	case value >= math.MaxInt32:
+		return math.MaxInt32
+	case value <= math.MinInt32:
+		return math.MinInt32
+	}
+	return (int32)(value)
+}
+
 type ICd2l struct{}
 
 func (*ICd2l) Op() ops.Op { return ops.D2l }
 func (*ICd2l) Execute(vm VM) error {
 	stack := vm.GetStack()
 	value := stack.PopFloat64()
-	if value != value {
-		stack.PushInt64(0)
-	} else {
-		stack.PushInt64((int64)(value))
-	}
+	stack.PushInt64(d2l(value))
 	return nil
 }
 
+// d2l converts the value to int64 with JVMS semantics,
+// NaN becomes zero and the values out of range are saturated
+func d2l(value float64) int64 {
+	switch {
+	case value != value:
+		return 0
+	case value >= 0x1p63:
+		return math.MaxInt64
+	case value <= -0x1p63:
+		return math.MinInt64
+	}
+	return (int64)(value)
+}
+
 type ICdadd struct{}
 
 func (*ICdadd) Op() ops.Op { return ops.Dadd }
@@ -23,11 +23,7 @@ func (*ICf2i) Op() ops.Op { return ops.F2i }
 func (*ICf2i) Execute(vm VM) error {
 	stack := vm.GetStack()
 	value := stack.PopFloat32()
-	if value != value {
-		stack.PushInt32(0)
-	} else {
-		stack.PushInt32((int32)(value))
-	}
+	stack.PushInt32(d2i((float64)(value)))
 	return nil
 }
 
@@ -37,11 +33,7 @@ func (*ICf2l) Op() ops.Op { return ops.F2l }
 func (*ICf2l) Execute(vm VM) error {
 	stack := vm.GetStack()
 	value := stack.PopFloat32()
-	if value != value {
-		stack.PushInt64(0)
-	} else {
-		stack.PushInt64((int64)(value))
-	}
+	stack.PushInt64(d2l((float64)(value)))
 	return nil
 }
 
@@ -185,6 +185,9 @@ func (*ICidiv) Execute(vm VM) error {
 	stack := vm.GetStack()
 	b := stack.PopInt32()
 	a := stack.PopInt32()
+	if b == 0 {
+		return errs.DivideByZero
+	}
 	stack.PushInt32(a / b)
 	return nil
 }
@@ -293,6 +296,9 @@ func (*ICirem) Execute(vm VM) error {
 	stack := vm.GetStack()
 	b := stack.PopInt32()
 	a := stack.PopInt32()
+	if b == 0 {
+		return errs.DivideByZero
+	}
 	stack.PushInt32(a % b)
 	return nil
 }
@@ -51,7 +51,7 @@ type IClaload struct{}
 func (*IClaload) Op() ops.Op { return ops.Laload }
 func (*IClaload) Execute(vm VM) error {
 	stack := vm.GetStack()
-	index := stack.PopInt64()
+	index := stack.PopInt32()
 	arr := stack.PopRef().GetInt64Arr()
 	if arr == nil {
 		return errs.NullPointerException
@@ -141,6 +141,9 @@ func (*ICldiv) Execute(vm VM) error {
 	stack := vm.GetStack()
 	b := stack.PopInt64()
 	a := stack.PopInt64()
+	if b == 0 {
+		return errs.DivideByZero
+	}
 	stack.PushInt64(a / b)
 	return nil
 }
@@ -236,6 +239,9 @@ func (*IClrem) Execute(vm VM) error {
 	stack := vm.GetStack()
 	b := stack.PopInt64()
 	a := stack.PopInt64()
+	if b == 0 {
+		return errs.DivideByZero
+	}
 	stack.PushInt64(a % b)
 	return nil
 }
@@ -332,7 +338,7 @@ func (*IClushr) Execute(vm VM) error {
 	stack := vm.GetStack()
 	b := stack.PopInt32()
 	a := stack.Pop64()
-	stack.Push64(a >> (b & 0x1f))
+	stack.Push64(a >> (b & 0x3f))
 	return nil
 }
 
@@ -22,16 +22,25 @@ type ICJumpable interface {
 
 type ICNode struct {
 	IC
-	Next   *ICNode
+	Next *ICNode
+	// Offset is the bytecode offset of the instruction
 	Offset int32
+	// Index is the index of the instruction in the method's contiguous instruction array
+	Index int32
 }
 
 type VM interface {
 	GetStack() Stack
 	Running() bool
+	// Step executes a single instruction, or the pending native method
 	Step() error
+	// Run executes the thread until its stack is empty
+	Run() error
+	// RunStack executes the thread until the current stack returns
 	RunStack() error
 
+	// InitClass initializes the class if it is not initialized yet
+	InitClass(Class) error
 	New(Class) Ref
 	NewString(string) Ref
 	// Alloc an array with the descriptor as the array's type
@@ -68,7 +77,7 @@ type VM interface {
 	LoadNativeMethod(Method, func(VM) error)
 	Invoke(Method)
 	InvokeStatic(Method)
-	InvokeVirtual(Method)
+	InvokeVirtual(Method) error
 	InvokeDynamic(uint16) error
 
 	Return()
@@ -122,6 +131,8 @@ type Stack interface {
 	PeekFloat32() float32
 	PeekFloat64() float64
 	PeekRef() Ref
+	// returns the reference under the top n slots
+	PeekRefAt(uint16) Ref
 	PeekPointer() unsafe.Pointer
 	Pop() uint32
 	Pop64() uint64
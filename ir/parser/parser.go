@@ -4,7 +4,6 @@ package parser
 import (
 	"bytes"
 	"fmt"
-	"io"
 
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/ops"
@@ -29,74 +28,69 @@ func GetICParser(b ops.Op) ICParser {
 	return parsers[b]
 }
 
+// ParseCode parses the bytecode and returns the first instruction.
+// The instructions are linked by ICNode.Next, and are stored contiguously, see ParseInsts.
 func ParseCode(buf []byte) (*ir.ICNode, error) {
-	r := bytes.NewReader(buf)
-	entry := new(ir.ICNode)
-
-	type jumpRequest struct {
-		i    int
-		node *ir.ICNode
+	insts, err := ParseInsts(buf)
+	if err != nil {
+		return nil, err
 	}
-	var (
-		b     byte
-		err   error
-		node  = entry
-		jumps = make(map[int32][]jumpRequest)
-	)
-	for {
-		if offset, err := r.Seek(0, io.SeekCurrent); err != nil {
-			return nil, err
-		} else {
-			node.Offset = (int32)(offset)
-		}
-		if jps, ok := jumps[node.Offset]; ok {
-			delete(jumps, node.Offset)
-			for _, req := range jps {
-				req.node.IC.(ir.ICJumpable).SetNode(req.i, node)
-			}
-		}
+	return &insts[0], nil
+}
 
-		if b, err = r.ReadByte(); err != nil {
-			return nil, err
-		}
+// ParseInsts parses the bytecode into a contiguous instruction array,
+// where the ICNode.Index of each instruction is its index in the array.
+// Jump targets are resolved to the nodes in the array.
+func ParseInsts(buf []byte) ([]ir.ICNode, error) {
+	r := bytes.NewReader(buf)
+	insts := make([]ir.ICNode, 0, len(buf)/2+1)
+	for r.Len() > 0 {
+		offset := (int32)(len(buf) - r.Len())
+		b, _ := r.ReadByte()
 		parser := GetICParser((ops.Op)(b))
 		if parser == nil {
-			return nil, fmt.Errorf("parser: unknown opcode 0x%02x at 0x%04x", b, node.Offset)
+			return nil, fmt.Errorf("parser: unknown opcode 0x%02x at 0x%04x", b, offset)
 		}
-
-		if node.IC, err = parser.Parse(r); err != nil {
+		ic, err := parser.Parse(r)
+		if err != nil {
 			return nil, err
 		}
+		insts = append(insts, ir.ICNode{
+			IC:     ic,
+			Offset: offset,
+			Index:  (int32)(len(insts)),
+		})
+	}
+	if len(insts) == 0 {
+		return nil, fmt.Errorf("parser: empty code")
+	}
+	// shrink the array to its length, since the nodes will live as long as the method
+	insts = append(make([]ir.ICNode, 0, len(insts)), insts...)
 
-		if j, ok := node.IC.(ir.ICJumpable); ok {
-			offsets := j.Offsets()
-			for i, off := range offsets {
-				if off == 0 {
-					j.SetNode(i, node)
-				} else {
-					offs := node.Offset + off
-					jumps[offs] = append(jumps[offs], jumpRequest{i, node})
-				}
-			}
+	// indexes maps the bytecode offset to the instruction index, or -1 if it is not at an instruction
+	indexes := make([]int32, len(buf))
+	for i := range indexes {
+		indexes[i] = -1
+	}
+	for i := range insts {
+		indexes[insts[i].Offset] = (int32)(i)
+	}
+	for i := range insts {
+		node := &insts[i]
+		if i+1 < len(insts) {
+			node.Next = &insts[i+1]
 		}
-
-		if r.Len() == 0 {
-			break
+		j, ok := node.IC.(ir.ICJumpable)
+		if !ok {
+			continue
 		}
-		n := new(ir.ICNode)
-		node.Next = n
-		node = n
-	}
-	for node = entry; node != nil; node = node.Next {
-		if jps, ok := jumps[node.Offset]; ok {
-			delete(jumps, node.Offset)
-			for _, req := range jps {
-				req.node.IC.(ir.ICJumpable).SetNode(req.i, node)
+		for k, off := range j.Offsets() {
+			target := (int64)(node.Offset) + (int64)(off)
+			if target < 0 || target >= (int64)(len(indexes)) || indexes[target] == -1 {
+				return nil, fmt.Errorf("parser: jump target 0x%04x at 0x%04x is not at an instruction", target, node.Offset)
 			}
+			j.SetNode(k, &insts[indexes[target]])
 		}
 	}
-	if len(jumps) > 0 {
-		return nil, fmt.Errorf("parser: unknown jump nodes")
-	}
-	return entry, nil
+	return insts, nil
 }
@@ -243,6 +243,9 @@ func (*ParserIClookupswitch) Parse(br *bytes.Reader) (ir.IC, error) {
 	if err != nil {
 		return nil, err
 	}
+	if indexCount < 0 || (int64)(indexCount)*8 > (int64)(br.Len()) {
+		return nil, fmt.Errorf("parser: lookupswitch: illegal case count %d", indexCount)
+	}
 	ic.Indexes = make([]ir.CaseEntry, indexCount)
 	for i := range indexCount {
 		var entry ir.CaseEntry
@@ -277,7 +280,13 @@ func (*ParserICtableswitch) Parse(br *bytes.Reader) (ir.IC, error) {
 	if ic.High, err = readInt32(br); err != nil {
 		return nil, err
 	}
-	indexCount := ic.High - ic.Low + 1
+	if ic.High < ic.Low {
+		return nil, fmt.Errorf("parser: tableswitch: low %d is greater than high %d", ic.Low, ic.High)
+	}
+	indexCount := (int64)(ic.High) - (int64)(ic.Low) + 1
+	if indexCount*4 > (int64)(br.Len()) {
+		return nil, fmt.Errorf("parser: tableswitch: illegal case count %d", indexCount)
+	}
 	ic.OffsetList = make([]int32, indexCount)
 	for i := range indexCount {
 		if ic.OffsetList[i], err = readInt32(br); err != nil {
@@ -415,13 +424,13 @@ func (*ParserICinvokedynamic) Parse(br *bytes.Reader) (ir.IC, error) {
 		return nil, err
 	}
 	if b != 0 {
-		panic("ir.invokedynamic: operands [2] must be 0")
+		return nil, fmt.Errorf("parser: invokedynamic: operands [2] must be 0")
 	}
 	if b, err = br.ReadByte(); err != nil {
 		return nil, err
 	}
 	if b != 0 {
-		panic("ir.invokedynamic: operands [3] must be 0")
+		return nil, fmt.Errorf("parser: invokedynamic: operands [3] must be 0")
 	}
 	return ic, nil
 }
@@ -443,7 +452,7 @@ func (*ParserICinvokeinterface) Parse(br *bytes.Reader) (ir.IC, error) {
 		return nil, err
 	}
 	if b != 0 {
-		panic("ir.invokeinterface: operands [3] must be 0")
+		return nil, fmt.Errorf("parser: invokeinterface: operands [3] must be 0")
 	}
 	return ic, nil
 }
@@ -497,7 +506,7 @@ func (*ParserICmultianewarray) Parse(br *bytes.Reader) (ir.IC, error) {
 		return nil, err
 	}
 	if ic.Dimensions < 1 {
-		panic("ir.multianewarray: dimensions is less than 1")
+		return nil, fmt.Errorf("parser: multianewarray: dimensions is less than 1")
 	}
 	return ic, nil
 }
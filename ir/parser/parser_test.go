@@ -2,8 +2,29 @@ package parser_test
 
 import (
 	"testing"
+
+	"github.com/LiterMC/wasm-jdk/ir/parser"
 )
 
 func TestTODO(t *testing.T) {
 	//
 }
+
+func FuzzParseCode(f *testing.F) {
+	f.Add([]byte{0x1a, 0xac})                                                                   // iload_0; ireturn
+	f.Add([]byte{0x1a, 0x99, 0x00, 0x04, 0x00, 0xb1})                                           // iload_0; ifeq +4; nop; return
+	f.Add([]byte{0xc4, 0x84, 0x01, 0x00, 0x00, 0x01, 0xb1})                                     // wide iinc 256 1; return
+	f.Add([]byte{0x1a, 0xaa, 0, 0, 0, 0, 0, 0x10, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0x10, 0xb1}) // iload_0; tableswitch; return
+	f.Add([]byte{0x1a, 0xab, 0, 0, 0, 0, 0, 0x0c, 0, 0, 0, 0, 0xb1})                            // iload_0; lookupswitch; return
+	f.Fuzz(func(t *testing.T, code []byte) {
+		entry, err := parser.ParseCode(code)
+		if err != nil {
+			return
+		}
+		for node := entry; node != nil; node = node.Next {
+			if node.IC == nil {
+				t.Fatalf("missing instruction at %d", node.Offset)
+			}
+		}
+	})
+}
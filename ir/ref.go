@@ -46,14 +46,15 @@ type Class interface {
 	IsInterface() bool
 	IsAssignableFrom(Class) bool
 	IsInstance(Ref) bool
+	IsSameRuntimePackage(Class) bool
 
 	GetAndPushConst(VM, uint16, Stack) error
 	GetAttr(string) Attribute
 	GetFields() iter.Seq[Field]
-	GetField(VM, uint16) Field
+	GetField(VM, uint16) (Field, error)
 	GetFieldByName(string) Field
 	GetMethods() iter.Seq[Method]
-	GetMethod(VM, uint16) Method
+	GetMethod(VM, uint16) (Method, error)
 	GetMethodByName(string) Method
 	GetMethodByNameAndType(name, typ string) Method
 }
@@ -61,9 +62,12 @@ type Class interface {
 type Field interface {
 	Name() string
 	Offset() int64
+	// returns the number of operand stack slots the field value takes
+	Slot() uint16
 	GetDeclaringClass() Class
 	Modifiers() int32
 	IsPublic() bool
+	IsProtected() bool
 	IsStatic() bool
 
 	AsRef(VM) Ref
@@ -82,6 +86,7 @@ type Method interface {
 	GetDeclaringClass() Class
 	Modifiers() int32
 	IsPublic() bool
+	IsProtected() bool
 	IsStatic() bool
 	IsConstructor() bool
 
@@ -39,6 +39,8 @@ const (
 	AccInterface AccessFlag = 0x0200
 	// Declared abstract; must not be instantiated.
 	AccAbstract AccessFlag = 0x0400
+	// Declared strictfp; floating-point mode is FP-strict.
+	AccStrict AccessFlag = 0x0800
 	// Declared synthetic; not present in the source code.
 	AccSynthetic AccessFlag = 0x1000
 	// Declared as an annotation interface.
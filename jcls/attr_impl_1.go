@@ -2,7 +2,9 @@ package jcls
 
 import (
 	"bytes"
+	"encoding/binary"
 	"fmt"
+	"hash/crc32"
 	"slices"
 	"strings"
 
@@ -20,7 +22,15 @@ func (a *AttrConstantValue) Parse(r *bytes.Buffer, consts []ConstantInfo) error
 	if err != nil {
 		return err
 	}
-	a.Value = consts[ind-1]
+	if ind == 0 || (int)(ind) > len(consts) {
+		return formatError("Invalid constant pool index %d", ind)
+	}
+	switch v := consts[ind-1].(type) {
+	case *ConstantInteger, *ConstantFloat, *ConstantLong, *ConstantDouble, *ConstantString:
+		a.Value = v
+	default:
+		return formatError("ConstantValue: unexpected constant type %T at index %d", v, ind)
+	}
 	return nil
 }
 func (a *AttrConstantValue) String() string {
@@ -28,12 +38,18 @@ func (a *AttrConstantValue) String() string {
 }
 
 type AttrCode struct {
-	MaxStack    uint16
-	MaxLocals   uint16
-	Code        *ir.ICNode
+	MaxStack  uint16
+	MaxLocals uint16
+	Code      *ir.ICNode
+	// Insts are all instructions of Code in a contiguous array, indexed by ICNode.Index
+	Insts []ir.ICNode
+	// Checksum is the CRC-32 of the bytecode, the constants it refers to and the catch types,
+	// which identifies the code of ahead-of-time compiled methods
+	Checksum    uint32
 	Exceptions  []ExceptionHandlers
 	Attrs       []ir.Attribute
 	LineNumbers []*LineNumberEntry
+	StackMap    *AttrStackMapTable
 }
 
 type ExceptionHandlers struct {
@@ -41,6 +57,8 @@ type ExceptionHandlers struct {
 	End     uint16
 	Handler uint16
 	Class   string
+	// Node is the handler's ICNode
+	Node *ir.ICNode
 }
 
 func (*AttrCode) Name() string { return "Code" }
@@ -60,9 +78,17 @@ func (a *AttrCode) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 	if size, err = readUint32(r); err != nil {
 		return err
 	}
-	if a.Code, err = parser.ParseCode(r.Next((int)(size))); err != nil {
-		return err
+	if size == 0 || size >= 65536 {
+		return formatError("Code: invalid code length %d", size)
+	}
+	if (int)(size) > r.Len() {
+		return formatError("Code: code length %d exceeds the attribute", size)
+	}
+	code := r.Next((int)(size))
+	if a.Insts, err = parser.ParseInsts(code); err != nil {
+		return formatError("Code: %v", err)
 	}
+	a.Code = &a.Insts[0]
 
 	if n, err = readUint16(r); err != nil {
 		return err
@@ -85,10 +111,33 @@ func (a *AttrCode) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 		if n == 0 {
 			e.Class = "java/lang/Throwable"
 		} else {
-			e.Class = consts[n-1].(*ConstantClass).Name
+			class, err := constantAt[*ConstantClass](consts, n)
+			if err != nil {
+				return err
+			}
+			e.Class = class.Name
+		}
+		if e.Start >= e.End || (uint32)(e.End) > size {
+			return formatError("Code: illegal exception range [%d, %d)", e.Start, e.End)
+		}
+		startOk := false
+		for c := a.Code; c != nil; c = c.Next {
+			if c.Offset == (int32)(e.Start) {
+				startOk = true
+			}
+			if c.Offset == (int32)(e.Handler) {
+				e.Node = c
+			}
+		}
+		if !startOk {
+			return formatError("Code: exception range start %d is not at an instruction", e.Start)
+		}
+		if e.Node == nil {
+			return formatError("Code: exception handler %d is not at an instruction", e.Handler)
 		}
 		a.Exceptions[i] = e
 	}
+	a.Checksum = codeChecksum(code, a.Insts, a.Exceptions, consts)
 
 	if n, err = readUint16(r); err != nil {
 		return err
@@ -99,9 +148,13 @@ func (a *AttrCode) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 		if at, err = ParseAttr(r, consts); err != nil {
 			return err
 		}
-		if table, ok := at.(*AttrLineNumberTable); ok {
-			a.LineNumbers = append(a.LineNumbers, table.Items...)
-		} else {
+		switch at := at.(type) {
+		case *AttrLineNumberTable:
+			a.LineNumbers = append(a.LineNumbers, at.Items...)
+		case *AttrStackMapTable:
+			a.StackMap = at
+			a.Attrs = append(a.Attrs, at)
+		default:
 			a.Attrs = append(a.Attrs, at)
 		}
 	}
@@ -114,6 +167,68 @@ func (a *AttrCode) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 	return nil
 }
 
+// codeChecksum hashes the constants referred by the instructions along with the bytecode,
+// since the ahead-of-time compiled code depends on them as well as the constant pool indexes.
+func codeChecksum(code []byte, insts []ir.ICNode, exceptions []ExceptionHandlers, consts []ConstantInfo) uint32 {
+	var b []byte
+	for i := range insts {
+		ind, tags := constantOperand(insts[i].IC, 0)
+		if tags == nil || ind == 0 || (int)(ind) > len(consts) {
+			continue
+		}
+		b = appendConstantKey(b, consts[ind-1])
+	}
+	for _, e := range exceptions {
+		b = append(b, e.Class...)
+		b = append(b, 0)
+	}
+	return crc32.Update(crc32.ChecksumIEEE(code), crc32.IEEETable, b)
+}
+
+// appendConstantKey appends the tag and the content of the constant
+func appendConstantKey(b []byte, c ConstantInfo) []byte {
+	if c == nil {
+		return append(b, 0)
+	}
+	b = append(b, (byte)(c.Tag()))
+	switch c := c.(type) {
+	case *ConstantClass:
+		b = append(b, c.Name...)
+	case *ConstantRef:
+		b = appendRefKey(b, c)
+	case *ConstantString:
+		b = append(b, c.Utf8...)
+	case *ConstantInteger:
+		b = binary.BigEndian.AppendUint32(b, c.Value)
+	case *ConstantFloat:
+		b = binary.BigEndian.AppendUint32(b, c.Value)
+	case *ConstantLong:
+		b = binary.BigEndian.AppendUint64(b, c.Value)
+	case *ConstantDouble:
+		b = binary.BigEndian.AppendUint64(b, c.Value)
+	case *ConstantMethodHandle:
+		b = append(b, (byte)(c.Kind))
+		b = appendRefKey(b, c.Ref)
+	case *ConstantMethodType:
+		b = append(b, c.Desc...)
+	case *ConstantDynamics:
+		b = binary.BigEndian.AppendUint16(b, c.BootstrapMethod)
+		b = append(b, c.NameAndType.Name...)
+		b = append(b, 0)
+		b = append(b, c.NameAndType.Desc...)
+	}
+	return append(b, 0)
+}
+
+func appendRefKey(b []byte, c *ConstantRef) []byte {
+	b = append(b, (byte)(c.ConstTag))
+	b = append(b, c.Class.Name...)
+	b = append(b, 0)
+	b = append(b, c.NameAndType.Name...)
+	b = append(b, 0)
+	return append(b, c.NameAndType.Desc...)
+}
+
 func (a *AttrCode) String() string {
 	return fmt.Sprintf("%#v", a.Code)
 }
@@ -185,7 +300,11 @@ func (a *AttrExceptions) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
-		a.Exceptions[i] = consts[n-1].(*ConstantClass).Name
+		class, err := constantAt[*ConstantClass](consts, n)
+		if err != nil {
+			return err
+		}
+		a.Exceptions[i] = class.Name
 	}
 	return nil
 }
@@ -209,18 +328,26 @@ func (a *AttrInnerClasses) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
-		c.Class = consts[n-1].(*ConstantClass)
+		if c.Class, err = constantAt[*ConstantClass](consts, n); err != nil {
+			return err
+		}
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
 		if n != 0 {
-			c.OuterClass = consts[n-1].(*ConstantClass)
+			if c.OuterClass, err = constantAt[*ConstantClass](consts, n); err != nil {
+				return err
+			}
 		}
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
 		if n != 0 {
-			c.Name = consts[n-1].(*ConstantUtf8).Value
+			name, err := constantAt[*ConstantUtf8](consts, n)
+			if err != nil {
+				return err
+			}
+			c.Name = name.Value
 		}
 		if n, err = readUint16(r); err != nil {
 			return err
@@ -256,16 +383,23 @@ func (a *AttrEnclosingMethod) Parse(r *bytes.Buffer, consts []ConstantInfo) erro
 	if err != nil {
 		return err
 	}
-	a.Class = consts[n-1].(*ConstantClass)
+	if a.Class, err = constantAt[*ConstantClass](consts, n); err != nil {
+		return err
+	}
 	if n, err = readUint16(r); err != nil {
 		return err
 	}
 	if n != 0 {
-		a.Method = consts[n-1].(*ConstantNameAndType)
+		if a.Method, err = constantAt[*ConstantNameAndType](consts, n); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 func (a *AttrEnclosingMethod) String() string {
+	if a.Method == nil {
+		return a.Class.Name
+	}
 	return a.Class.Name + "." + a.Method.String()
 }
 
@@ -279,13 +413,228 @@ func (a *AttrSourceFile) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 	if err != nil {
 		return err
 	}
-	a.Value = consts[ind-1].(*ConstantUtf8).Value
+	value, err := constantAt[*ConstantUtf8](consts, ind)
+	if err != nil {
+		return err
+	}
+	a.Value = value.Value
 	return nil
 }
 func (a *AttrSourceFile) String() string {
 	return a.Value
 }
 
+type AttrNestHost struct {
+	Host string
+}
+
+func (*AttrNestHost) Name() string { return "NestHost" }
+func (a *AttrNestHost) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
+	ind, err := readUint16(r)
+	if err != nil {
+		return err
+	}
+	host, err := constantAt[*ConstantClass](consts, ind)
+	if err != nil {
+		return err
+	}
+	a.Host = host.Name
+	return nil
+}
+func (a *AttrNestHost) String() string {
+	return a.Host
+}
+
+type AttrNestMembers struct {
+	Members []string
+}
+
+func (*AttrNestMembers) Name() string { return "NestMembers" }
+func (a *AttrNestMembers) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
+	n, err := readUint16(r)
+	if err != nil {
+		return err
+	}
+	a.Members = make([]string, n)
+	for i := range n {
+		if n, err = readUint16(r); err != nil {
+			return err
+		}
+		member, err := constantAt[*ConstantClass](consts, n)
+		if err != nil {
+			return err
+		}
+		a.Members[i] = member.Name
+	}
+	return nil
+}
+func (a *AttrNestMembers) String() string {
+	return fmt.Sprint(a.Members)
+}
+
+type AttrStackMapTable struct {
+	Frames []*StackMapFrame
+}
+
+type StackMapFrameKind uint8
+
+const (
+	FrameSame StackMapFrameKind = iota
+	FrameSameLocals1StackItem
+	FrameChop
+	FrameAppend
+	FrameFull
+)
+
+// StackMapFrame is an entry of the StackMapTable attribute, see JVMS 4.7.4
+type StackMapFrame struct {
+	Kind        StackMapFrameKind
+	OffsetDelta uint16
+	// Chop is the number of the last locals which are absent in a chop frame
+	Chop uint8
+	// Locals are the appended locals of an append frame, or all locals of a full frame
+	Locals []VerificationType
+	Stack  []VerificationType
+}
+
+type VerificationTag uint8
+
+const (
+	VerifyTop VerificationTag = iota
+	VerifyInteger
+	VerifyFloat
+	VerifyDouble
+	VerifyLong
+	VerifyNull
+	VerifyUninitializedThis
+	VerifyObject
+	VerifyUninitialized
+)
+
+type VerificationType struct {
+	Tag VerificationTag
+	// Class is the class name of VerifyObject
+	Class string
+	// Offset is the offset of the new instruction which created the VerifyUninitialized value
+	Offset uint16
+}
+
+func (*AttrStackMapTable) Name() string { return "StackMapTable" }
+func (a *AttrStackMapTable) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
+	n, err := readUint16(r)
+	if err != nil {
+		return err
+	}
+	a.Frames = make([]*StackMapFrame, n)
+	for i := range n {
+		f := new(StackMapFrame)
+		typ, err := readUint8(r)
+		if err != nil {
+			return err
+		}
+		switch {
+		case typ < 64:
+			f.Kind = FrameSame
+			f.OffsetDelta = (uint16)(typ)
+		case typ < 128:
+			f.Kind = FrameSameLocals1StackItem
+			f.OffsetDelta = (uint16)(typ - 64)
+			f.Stack = make([]VerificationType, 1)
+			if err = parseVerificationType(r, consts, &f.Stack[0]); err != nil {
+				return err
+			}
+		case typ < 247:
+			return formatError("StackMapTable: reserved frame type %d", typ)
+		case typ == 247:
+			f.Kind = FrameSameLocals1StackItem
+			if f.OffsetDelta, err = readUint16(r); err != nil {
+				return err
+			}
+			f.Stack = make([]VerificationType, 1)
+			if err = parseVerificationType(r, consts, &f.Stack[0]); err != nil {
+				return err
+			}
+		case typ < 251:
+			f.Kind = FrameChop
+			f.Chop = 251 - typ
+			if f.OffsetDelta, err = readUint16(r); err != nil {
+				return err
+			}
+		case typ == 251:
+			f.Kind = FrameSame
+			if f.OffsetDelta, err = readUint16(r); err != nil {
+				return err
+			}
+		case typ < 255:
+			f.Kind = FrameAppend
+			if f.OffsetDelta, err = readUint16(r); err != nil {
+				return err
+			}
+			f.Locals = make([]VerificationType, typ-251)
+			for j := range f.Locals {
+				if err = parseVerificationType(r, consts, &f.Locals[j]); err != nil {
+					return err
+				}
+			}
+		default:
+			f.Kind = FrameFull
+			if f.OffsetDelta, err = readUint16(r); err != nil {
+				return err
+			}
+			if f.Locals, err = parseVerificationTypes(r, consts); err != nil {
+				return err
+			}
+			if f.Stack, err = parseVerificationTypes(r, consts); err != nil {
+				return err
+			}
+		}
+		a.Frames[i] = f
+	}
+	return nil
+}
+
+func parseVerificationTypes(r *bytes.Buffer, consts []ConstantInfo) ([]VerificationType, error) {
+	n, err := readUint16(r)
+	if err != nil {
+		return nil, err
+	}
+	types := make([]VerificationType, n)
+	for i := range types {
+		if err = parseVerificationType(r, consts, &types[i]); err != nil {
+			return nil, err
+		}
+	}
+	return types, nil
+}
+
+func parseVerificationType(r *bytes.Buffer, consts []ConstantInfo, t *VerificationType) error {
+	tag, err := readUint8(r)
+	if err != nil {
+		return err
+	}
+	t.Tag = (VerificationTag)(tag)
+	switch t.Tag {
+	case VerifyTop, VerifyInteger, VerifyFloat, VerifyDouble, VerifyLong, VerifyNull, VerifyUninitializedThis:
+	case VerifyObject:
+		ind, err := readUint16(r)
+		if err != nil {
+			return err
+		}
+		class, err := constantAt[*ConstantClass](consts, ind)
+		if err != nil {
+			return err
+		}
+		t.Class = class.Name
+	case VerifyUninitialized:
+		if t.Offset, err = readUint16(r); err != nil {
+			return err
+		}
+	default:
+		return formatError("StackMapTable: unknown verification type %d", tag)
+	}
+	return nil
+}
+
 type AttrBootstrapMethods struct {
 	Methods []*BootstrapMethod
 }
@@ -307,7 +656,9 @@ func (a *AttrBootstrapMethods) Parse(r *bytes.Buffer, consts []ConstantInfo) err
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
-		m.Method = consts[n-1].(*ConstantMethodHandle)
+		if m.Method, err = constantAt[*ConstantMethodHandle](consts, n); err != nil {
+			return err
+		}
 		if n, err = readUint16(r); err != nil {
 			return err
 		}
@@ -316,6 +667,9 @@ func (a *AttrBootstrapMethods) Parse(r *bytes.Buffer, consts []ConstantInfo) err
 			if n, err = readUint16(r); err != nil {
 				return err
 			}
+			if n == 0 || (int)(n) > len(consts) || consts[n-1] == nil {
+				return formatError("BootstrapMethods: invalid argument index %d", n)
+			}
 			m.Args[i] = consts[n-1]
 		}
 		a.Methods[i] = m
@@ -346,4 +700,7 @@ func init() {
 	RegisterAttr(func() ParsableAttribute { return new(AttrEnclosingMethod) })
 	RegisterAttr(func() ParsableAttribute { return new(AttrSourceFile) })
 	RegisterAttr(func() ParsableAttribute { return new(AttrBootstrapMethods) })
+	RegisterAttr(func() ParsableAttribute { return new(AttrNestHost) })
+	RegisterAttr(func() ParsableAttribute { return new(AttrNestMembers) })
+	RegisterAttr(func() ParsableAttribute { return new(AttrStackMapTable) })
 }
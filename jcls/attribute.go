@@ -28,15 +28,23 @@ func ParseAttr(r io.Reader, consts []ConstantInfo) (ir.Attribute, error) {
 	if err != nil {
 		return nil, err
 	}
-	name := consts[nameInd-1].(*ConstantUtf8).Value
+	nameUtf8, err := constantAt[*ConstantUtf8](consts, nameInd)
+	if err != nil {
+		return nil, err
+	}
+	name := nameUtf8.Value
 	size, err := readUint32(r)
 	if err != nil {
 		return nil, err
 	}
-	data := make([]byte, size)
-	if _, err = io.ReadFull(r, data); err != nil {
+	// do not trust the size before the data is actually read
+	data, err := io.ReadAll(io.LimitReader(r, (int64)(size)))
+	if err != nil {
 		return nil, err
 	}
+	if (uint32)(len(data)) != size {
+		return nil, formatError("Truncated attribute %s", name)
+	}
 	var a ParsableAttribute
 	newer := attributeFactories[name]
 	if newer == nil {
@@ -46,9 +54,13 @@ func ParseAttr(r io.Reader, consts []ConstantInfo) (ir.Attribute, error) {
 	} else {
 		a = newer()
 	}
-	if err = a.Parse(bytes.NewBuffer(data), consts); err != nil {
+	buf := bytes.NewBuffer(data)
+	if err = a.Parse(buf, consts); err != nil {
 		return nil, err
 	}
+	if buf.Len() != 0 {
+		return nil, formatError("Attribute %s length %d does not match its content", name, size)
+	}
 	return a, nil
 }
 
@@ -60,7 +72,7 @@ type AttributeRaw struct {
 func (a *AttributeRaw) Name() string { return a.AName }
 func (a *AttributeRaw) Parse(r *bytes.Buffer, consts []ConstantInfo) error {
 	if r.Len() > 0 {
-		a.Data = r.Bytes()
+		a.Data = r.Next(r.Len())
 	}
 	return nil
 }
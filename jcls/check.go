@@ -0,0 +1,406 @@
+package jcls
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+)
+
+// The supported class file versions, see JVMS 4.1
+const (
+	MinMajorVersion uint16 = 45
+	MaxMajorVersion uint16 = 66 // Java 22
+)
+
+func formatError(format string, args ...any) error {
+	return errs.Throwf("java/lang/ClassFormatError", format, args...)
+}
+
+// constantAt returns the constant at the index in the constant pool, which must have the type T
+func constantAt[T ConstantInfo](consts []ConstantInfo, ind uint16) (T, error) {
+	var zero T
+	if ind == 0 || (int)(ind) > len(consts) {
+		return zero, formatError("Invalid constant pool index %d", ind)
+	}
+	c, ok := consts[ind-1].(T)
+	if !ok {
+		return zero, formatError("Unexpected constant type %T at index %d, expect %T", consts[ind-1], ind, zero)
+	}
+	return c, nil
+}
+
+func checkVersion(major, minor uint16) error {
+	if major < MinMajorVersion || major > MaxMajorVersion {
+		return errs.Throwf("java/lang/UnsupportedClassVersionError",
+			"Unsupported class file major version %d, this VM only recognizes versions from %d to %d", major, MinMajorVersion, MaxMajorVersion)
+	}
+	if major >= 56 && minor != 0 {
+		if minor == 0xffff {
+			return errs.Throwf("java/lang/UnsupportedClassVersionError", "Preview features are not supported (class file version %d.%d)", major, minor)
+		}
+		return errs.Throwf("java/lang/UnsupportedClassVersionError", "Unsupported class file version %d.%d", major, minor)
+	}
+	return nil
+}
+
+// isUnqualifiedName reports whether the name is a valid field or method name, see JVMS 4.2.2
+func isUnqualifiedName(name string, method bool) bool {
+	if name == "" {
+		return false
+	}
+	if method && (name == "<init>" || name == "<clinit>") {
+		return true
+	}
+	for i := 0; i < len(name); i++ {
+		switch name[i] {
+		case '.', ';', '[', '/':
+			return false
+		case '<', '>':
+			if method {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// isBinaryName reports whether the name is a valid class or interface name in internal form, see JVMS 4.2.1
+func isBinaryName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if !isUnqualifiedName(part, false) {
+			return false
+		}
+	}
+	return true
+}
+
+// isClassName reports whether the name of a CONSTANT_Class_info is valid,
+// which is either a binary name or an array descriptor
+func isClassName(name string) bool {
+	if name != "" && name[0] == '[' {
+		d, err := desc.ParseDesc(name)
+		return err == nil && isFieldDesc(d)
+	}
+	return isBinaryName(name)
+}
+
+// isFieldDesc reports whether the descriptor is a valid field type, see JVMS 4.3.2
+func isFieldDesc(d *desc.Desc) bool {
+	if d.ArrDim > 255 || d.EndType == desc.Void {
+		return false
+	}
+	return d.EndType != desc.Class || isBinaryName(d.Class)
+}
+
+// isMethodDesc reports whether the descriptor is a valid method descriptor, see JVMS 4.3.3
+func isMethodDesc(d *desc.MethodDesc) bool {
+	if d.InputSlots() > 255 {
+		return false
+	}
+	for _, in := range d.Inputs {
+		if !isFieldDesc(in) {
+			return false
+		}
+	}
+	return d.Output.EndType == desc.Void && d.Output.ArrDim == 0 || isFieldDesc(d.Output)
+}
+
+func parseFieldDesc(s string) (*desc.Desc, error) {
+	d, err := desc.ParseDesc(s)
+	if err != nil || !isFieldDesc(d) {
+		return nil, formatError("Invalid field descriptor %q", s)
+	}
+	return d, nil
+}
+
+func parseMethodDesc(s string) (*desc.MethodDesc, error) {
+	d, err := desc.ParseMethodDesc(s)
+	if err != nil || !isMethodDesc(d) {
+		return nil, formatError("Invalid method descriptor %q", s)
+	}
+	return d, nil
+}
+
+// checkConstant checks the resolved constant's names and descriptors, see JVMS 4.4
+func checkConstant(c ConstantInfo, major uint16) error {
+	switch c := c.(type) {
+	case *ConstantClass:
+		if !isClassName(c.Name) {
+			return formatError("Illegal class name %q", c.Name)
+		}
+	case *ConstantRef:
+		nt := c.NameAndType
+		if c.ConstTag == TagFieldref {
+			if !isUnqualifiedName(nt.Name, false) {
+				return formatError("Illegal field name %q", nt.Name)
+			}
+			if _, err := parseFieldDesc(nt.Desc); err != nil {
+				return err
+			}
+			return nil
+		}
+		if nt.Name == "<clinit>" || !isUnqualifiedName(nt.Name, true) {
+			return formatError("Illegal method name %q", nt.Name)
+		}
+		md, err := parseMethodDesc(nt.Desc)
+		if err != nil {
+			return err
+		}
+		if nt.Name == "<init>" && (md.Output.EndType != desc.Void || md.Output.ArrDim != 0) {
+			return formatError("Method <init> must return void: %s", nt.Desc)
+		}
+	case *ConstantMethodHandle:
+		ref := c.Ref
+		switch c.Kind {
+		case RefGetField, RefGetStatic, RefPutField, RefPutStatic:
+			if ref.ConstTag != TagFieldref {
+				return formatError("Method handle %s must refer to a field", c.Kind)
+			}
+		case RefInvokeVirtual, RefNewInvokeSpecial:
+			if ref.ConstTag != TagMethodref {
+				return formatError("Method handle %s must refer to a class method", c.Kind)
+			}
+		case RefInvokeStatic, RefInvokeSpecial:
+			if ref.ConstTag != TagMethodref && (major < 52 || ref.ConstTag != TagInterfaceMethodref) {
+				return formatError("Method handle %s must refer to a method", c.Kind)
+			}
+		case RefInvokeInterface:
+			if ref.ConstTag != TagInterfaceMethodref {
+				return formatError("Method handle %s must refer to an interface method", c.Kind)
+			}
+		}
+		if ref.ConstTag != TagFieldref {
+			name := ref.NameAndType.Name
+			if (c.Kind == RefNewInvokeSpecial) != (name == "<init>") {
+				return formatError("Method handle %s cannot refer to %s", c.Kind, name)
+			}
+		}
+	case *ConstantMethodType:
+		if _, err := parseMethodDesc(c.Desc); err != nil {
+			return err
+		}
+	case *ConstantDynamics:
+		nt := c.NameAndType
+		if c.ConstTag == TagDynamic {
+			if !isUnqualifiedName(nt.Name, false) {
+				return formatError("Illegal dynamic constant name %q", nt.Name)
+			}
+			if _, err := parseFieldDesc(nt.Desc); err != nil {
+				return err
+			}
+		} else {
+			if !isUnqualifiedName(nt.Name, false) {
+				return formatError("Illegal invokedynamic name %q", nt.Name)
+			}
+			if _, err := parseMethodDesc(nt.Desc); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkClassFlags checks the access flags of the class, see JVMS 4.1
+func checkClassFlags(c *Class) error {
+	flags := c.AccessFlags
+	if flags.Has(AccModule) {
+		return formatError("%s is a module-info and cannot be loaded as a class", c.ThisSym.Name)
+	}
+	if flags.Has(AccInterface) {
+		if !flags.Has(AccAbstract) || flags.Has(AccFinal) || (c.Major >= 49 && flags.Has(AccSuper|AccEnum)) {
+			return formatError("Illegal class modifiers in class %s: 0x%04x", c.ThisSym.Name, (uint16)(flags))
+		}
+	} else if flags.Has(AccAnnotation) || (flags.Has(AccFinal) && flags.Has(AccAbstract)) {
+		return formatError("Illegal class modifiers in class %s: 0x%04x", c.ThisSym.Name, (uint16)(flags))
+	}
+	return nil
+}
+
+// accessCount returns the number of public, private and protected flags
+func accessCount(flags AccessFlag) int {
+	n := 0
+	for _, f := range []AccessFlag{AccPublic, AccPrivate, AccProtected} {
+		if flags.Has(f) {
+			n++
+		}
+	}
+	return n
+}
+
+// checkField checks the access flags of the field, see JVMS 4.5
+func checkField(c *Class, f *Field) error {
+	flags := f.AccessFlags
+	ok := accessCount(flags) <= 1 && !(flags.Has(AccFinal) && flags.Has(AccVolatile))
+	if c.IsInterface() {
+		const want = AccPublic | AccStatic | AccFinal
+		ok = ok && flags&want == want && flags&^(want|AccSynthetic) == 0
+	}
+	if !ok {
+		return formatError("Illegal field modifiers in class %s: 0x%04x", c.ThisSym.Name, (uint16)(flags))
+	}
+	return nil
+}
+
+// checkMethod checks the access flags and the code of the method, see JVMS 4.6
+func checkMethod(c *Class, m *Method) error {
+	flags := m.AccessFlags
+	if m.name == "<clinit>" {
+		if c.Major >= 51 && !flags.Has(AccStatic) {
+			return formatError("Method <clinit> is not static in class %s", c.ThisSym.Name)
+		}
+		if len(m.desc.Inputs) != 0 || m.desc.Output.EndType != desc.Void || m.desc.Output.ArrDim != 0 {
+			return formatError("Method <clinit> has illegal signature %s in class %s", m.desc, c.ThisSym.Name)
+		}
+	} else {
+		ok := accessCount(flags) <= 1
+		if c.IsInterface() {
+			if c.Major < 52 {
+				ok = ok && flags&(AccPublic|AccAbstract) == AccPublic|AccAbstract
+			} else {
+				ok = ok && accessCount(flags&(AccPublic|AccPrivate)) == 1
+			}
+			ok = ok && !flags.Has(AccProtected|AccFinal|AccSynchronized|AccNative)
+		}
+		if flags.Has(AccAbstract) {
+			ok = ok && !flags.Has(AccPrivate|AccStatic|AccFinal|AccSynchronized|AccNative)
+			if c.Major >= 46 && c.Major <= 60 {
+				ok = ok && !flags.Has(AccStrict)
+			}
+		}
+		if m.name == "<init>" {
+			ok = ok && !c.IsInterface() && !flags.Has(AccStatic|AccFinal|AccSynchronized|AccBridge|AccNative|AccAbstract)
+			ok = ok && m.desc.Output.EndType == desc.Void && m.desc.Output.ArrDim == 0
+		}
+		if !ok {
+			return formatError("Method %s%s in class %s has illegal modifiers: 0x%04x", m.name, m.desc, c.ThisSym.Name, (uint16)(flags))
+		}
+	}
+	if flags.Has(AccAbstract | AccNative) {
+		if m.Code != nil {
+			return formatError("Code attribute in native or abstract method %s%s in class %s", m.name, m.desc, c.ThisSym.Name)
+		}
+	} else if m.Code == nil {
+		return formatError("Absent Code attribute in method %s%s in class %s", m.name, m.desc, c.ThisSym.Name)
+	} else if err := checkCode(c, m); err != nil {
+		return err
+	}
+	return nil
+}
+
+var (
+	fieldTags         = []ConstTag{TagFieldref}
+	classTags         = []ConstTag{TagClass}
+	methodTags        = []ConstTag{TagMethodref}
+	anyMethodTags     = []ConstTag{TagMethodref, TagInterfaceMethodref}
+	interfaceTags     = []ConstTag{TagInterfaceMethodref}
+	invokeDynamicTags = []ConstTag{TagInvokeDynamic}
+	loadableTags      = []ConstTag{TagInteger, TagFloat, TagString, TagClass, TagMethodHandle, TagMethodType, TagDynamic}
+	loadableWideTags  = []ConstTag{TagLong, TagDouble, TagDynamic}
+)
+
+// constantOperand returns the constant pool index which the instruction refers to,
+// and the tags the constant may have in the class file of the major version.
+// It returns nil tags if the instruction does not refer to the constant pool.
+func constantOperand(ic ir.IC, major uint16) (ind uint16, tags []ConstTag) {
+	switch ic := ic.(type) {
+	case *ir.ICgetfield:
+		return ic.Field, fieldTags
+	case *ir.ICputfield:
+		return ic.Field, fieldTags
+	case *ir.ICgetstatic:
+		return ic.Field, fieldTags
+	case *ir.ICputstatic:
+		return ic.Field, fieldTags
+	case *ir.ICinvokevirtual:
+		return ic.Method, methodTags
+	case *ir.ICinvokespecial:
+		if major >= 52 {
+			return ic.Method, anyMethodTags
+		}
+		return ic.Method, methodTags
+	case *ir.ICinvokestatic:
+		if major >= 52 {
+			return ic.Method, anyMethodTags
+		}
+		return ic.Method, methodTags
+	case *ir.ICinvokeinterface:
+		return ic.Method, interfaceTags
+	case *ir.ICinvokedynamic:
+		return ic.Method, invokeDynamicTags
+	case *ir.ICnew:
+		return ic.Class, classTags
+	case *ir.ICanewarray:
+		return ic.Class, classTags
+	case *ir.ICmultianewarray:
+		return ic.ArrClass, classTags
+	case *ir.ICcheckcast:
+		return ic.Class, classTags
+	case *ir.ICinstanceof:
+		return ic.Class, classTags
+	case *ir.ICldc:
+		return (uint16)(ic.Index), loadableTags
+	case *ir.ICldc_w:
+		return ic.Index, loadableTags
+	case *ir.ICldc2_w:
+		return ic.Index, loadableWideTags
+	}
+	return 0, nil
+}
+
+// checkCode checks the constant pool operands of the instructions, see JVMS 4.9.1
+func checkCode(c *Class, m *Method) error {
+	for i := range m.Code.Insts {
+		node := &m.Code.Insts[i]
+		ind, tags := constantOperand(node.IC, c.Major)
+		if tags == nil {
+			continue
+		}
+		if ind == 0 || (int)(ind) > len(c.ConstPool) || c.ConstPool[ind-1] == nil || !slices.Contains(tags, c.ConstPool[ind-1].Tag()) {
+			return formatError("Illegal constant pool index %d in method %s%s at offset %d in class %s", ind, m.name, m.desc, node.Offset, c.ThisSym.Name)
+		}
+		if d, ok := c.ConstPool[ind-1].(*ConstantDynamics); ok && d.ConstTag == TagDynamic {
+			// long and double dynamic constants can only be loaded by ldc2_w
+			wide := d.NameAndType.Desc == "J" || d.NameAndType.Desc == "D"
+			if _, ldc2 := node.IC.(*ir.ICldc2_w); wide != ldc2 {
+				return formatError("Illegal type of dynamic constant at index %d in method %s%s at offset %d in class %s", ind, m.name, m.desc, node.Offset, c.ThisSym.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// checkBootstrapMethods checks the BootstrapMethods attribute, and the bootstrap method indexes of the dynamic constants, see JVMS 4.7.23
+func checkBootstrapMethods(c *Class) error {
+	var bootstraps *AttrBootstrapMethods
+	for _, a := range c.Attrs {
+		if a.Name() != "BootstrapMethods" {
+			continue
+		}
+		if bootstraps != nil {
+			return formatError("Multiple BootstrapMethods attributes in class %s", c.ThisSym.Name)
+		}
+		var ok bool
+		if bootstraps, ok = a.(*AttrBootstrapMethods); !ok {
+			return formatError("Malformed BootstrapMethods attribute in class %s", c.ThisSym.Name)
+		}
+	}
+	for i, v := range c.ConstPool {
+		d, ok := v.(*ConstantDynamics)
+		if !ok {
+			continue
+		}
+		if bootstraps == nil {
+			return formatError("Absent BootstrapMethods attribute for the dynamic constant at index %d in class %s", i+1, c.ThisSym.Name)
+		}
+		if (int)(d.BootstrapMethod) >= len(bootstraps.Methods) {
+			return formatError("Invalid bootstrap method index %d at constant pool index %d in class %s", d.BootstrapMethod, i+1, c.ThisSym.Name)
+		}
+	}
+	return nil
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 
 	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 )
 
@@ -27,7 +28,24 @@ type Class struct {
 	ThisDesc *desc.Desc
 }
 
+// ParseClass parses and checks the format of a class file.
+// The returned error is always an *errs.ThrowError,
+// which is either a ClassFormatError or an UnsupportedClassVersionError.
 func ParseClass(r io.Reader) (*Class, error) {
+	c, err := parseClass(r)
+	if err != nil {
+		if _, ok := err.(*errs.ThrowError); ok {
+			return nil, err
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return nil, formatError("Truncated class file")
+		}
+		return nil, formatError("%v", err)
+	}
+	return c, nil
+}
+
+func parseClass(r io.Reader) (*Class, error) {
 	var (
 		c     = new(Class)
 		n     uint16
@@ -38,7 +56,7 @@ func ParseClass(r io.Reader) (*Class, error) {
 		return nil, err
 	}
 	if magic != ClassMagic {
-		return nil, fmt.Errorf("Unexpected class header 0x%08x", magic)
+		return nil, formatError("Unexpected class header 0x%08x", magic)
 	}
 	if c.Minor, err = readUint16(r); err != nil {
 		return nil, err
@@ -46,10 +64,16 @@ func ParseClass(r io.Reader) (*Class, error) {
 	if c.Major, err = readUint16(r); err != nil {
 		return nil, err
 	}
+	if err = checkVersion(c.Major, c.Minor); err != nil {
+		return nil, err
+	}
 
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
+	if n == 0 {
+		return nil, formatError("Illegal constant pool size 0")
+	}
 	n--
 	c.ConstPool = make([]ConstantInfo, n)
 	for i := (uint16)(0); i < n; i++ {
@@ -59,12 +83,23 @@ func ParseClass(r io.Reader) (*Class, error) {
 		}
 		c.ConstPool[i] = v
 		if v.IsWide() {
-			i++
+			if i++; i >= n {
+				return nil, formatError("Constant at index %d takes two slots but the constant pool ends", i)
+			}
+		}
+	}
+	for _, v := range c.ConstPool {
+		if v != nil {
+			if err = v.Resolve(c.ConstPool); err != nil {
+				return nil, err
+			}
 		}
 	}
 	for _, v := range c.ConstPool {
 		if v != nil {
-			v.Resolve(c.ConstPool)
+			if err = checkConstant(v, c.Major); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -76,13 +111,31 @@ func ParseClass(r io.Reader) (*Class, error) {
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
-	c.ThisSym = c.ConstPool[n-1].(*ConstantClass)
+	if c.ThisSym, err = constantAt[*ConstantClass](c.ConstPool, n); err != nil {
+		return nil, err
+	}
+	if c.ThisSym.Name[0] == '[' {
+		return nil, formatError("Illegal class name %q", c.ThisSym.Name)
+	}
+	if err = checkClassFlags(c); err != nil {
+		return nil, err
+	}
 
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
 	if n != 0 {
-		c.SuperSym = c.ConstPool[n-1].(*ConstantClass)
+		if c.SuperSym, err = constantAt[*ConstantClass](c.ConstPool, n); err != nil {
+			return nil, err
+		}
+		if c.SuperSym.Name[0] == '[' {
+			return nil, formatError("Illegal superclass name %q", c.SuperSym.Name)
+		}
+		if c.IsInterface() && c.SuperSym.Name != "java/lang/Object" {
+			return nil, formatError("Interface %s must extend java/lang/Object", c.ThisSym.Name)
+		}
+	} else if c.ThisSym.Name != "java/lang/Object" {
+		return nil, formatError("Class %s has no superclass", c.ThisSym.Name)
 	}
 
 	if n, err = readUint16(r); err != nil {
@@ -93,27 +146,51 @@ func ParseClass(r io.Reader) (*Class, error) {
 		if n, err = readUint16(r); err != nil {
 			return nil, err
 		}
-		c.InterfacesSym[i] = c.ConstPool[n-1].(*ConstantClass)
+		if c.InterfacesSym[i], err = constantAt[*ConstantClass](c.ConstPool, n); err != nil {
+			return nil, err
+		}
 	}
 
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
 	c.Fields = make([]*Field, n)
+	fieldSet := make(map[string]struct{}, n)
 	for i := range n {
-		if c.Fields[i], err = ParseField(r, c.ConstPool); err != nil {
+		f, err := ParseField(r, c.ConstPool)
+		if err != nil {
 			return nil, err
 		}
+		if err = checkField(c, f); err != nil {
+			return nil, err
+		}
+		key := f.name + " " + f.Desc.String()
+		if _, ok := fieldSet[key]; ok {
+			return nil, formatError("Duplicate field %s in class %s", key, c.ThisSym.Name)
+		}
+		fieldSet[key] = struct{}{}
+		c.Fields[i] = f
 	}
 
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
 	c.Methods = make([]*Method, n)
+	methodSet := make(map[string]struct{}, n)
 	for i := range n {
-		if c.Methods[i], err = ParseMethod(r, c.ConstPool); err != nil {
+		m, err := ParseMethod(r, c.ConstPool)
+		if err != nil {
+			return nil, err
+		}
+		if err = checkMethod(c, m); err != nil {
 			return nil, err
 		}
+		key := m.name + m.desc.String()
+		if _, ok := methodSet[key]; ok {
+			return nil, formatError("Duplicate method %s in class %s", key, c.ThisSym.Name)
+		}
+		methodSet[key] = struct{}{}
+		c.Methods[i] = m
 	}
 
 	if n, err = readUint16(r); err != nil {
@@ -125,6 +202,12 @@ func ParseClass(r io.Reader) (*Class, error) {
 			return nil, err
 		}
 	}
+	if err = checkBootstrapMethods(c); err != nil {
+		return nil, err
+	}
+	if _, err = readUint8(r); err != io.EOF {
+		return nil, formatError("Extra bytes at the end of class file %s", c.ThisSym.Name)
+	}
 
 	c.ThisDesc = &desc.Desc{
 		EndType: desc.Class,
@@ -1,10 +1,12 @@
 package jcls_test
 
 import (
-	"testing"
-
+	"bytes"
 	"os"
+	"slices"
+	"testing"
 
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/jcls"
 )
 
@@ -20,3 +22,86 @@ func TestParseClass(t *testing.T) {
 	}
 	t.Logf("class: %v", class)
 }
+
+func TestParseClassMalformed(t *testing.T) {
+	data, err := os.ReadFile("testdata/Test.class")
+	if err != nil {
+		t.Fatalf("Cannot read file: %v", err)
+	}
+	patch := func(off int, b ...byte) []byte {
+		buf := slices.Clone(data)
+		copy(buf[off:], b)
+		return buf
+	}
+	datas := []struct {
+		name  string
+		data  []byte
+		class string
+	}{
+		{"magic", patch(0, 0xca, 0xfe, 0xd0, 0x0d), "java/lang/ClassFormatError"},
+		{"truncated", data[:len(data)/2], "java/lang/ClassFormatError"},
+		{"extra", append(slices.Clone(data), 0), "java/lang/ClassFormatError"},
+		{"emptyPool", patch(8, 0, 0), "java/lang/ClassFormatError"},
+		{"badTag", patch(10, 2), "java/lang/ClassFormatError"},
+		{"operandIndex", patch(1286, 0xff, 0xff), "java/lang/ClassFormatError"}, // getstatic in testPrivateVoidMethod
+		{"operandTag", patch(1286, 0, 1), "java/lang/ClassFormatError"},
+		{"bootstrapIndex", patch(440, 0, 9), "java/lang/ClassFormatError"}, // the second invokedynamic constant
+		{"oldVersion", patch(6, 0, 44), "java/lang/UnsupportedClassVersionError"},
+		{"newVersion", patch(6, 0, 99), "java/lang/UnsupportedClassVersionError"},
+		{"preview", patch(4, 0xff, 0xff), "java/lang/UnsupportedClassVersionError"},
+	}
+	for _, d := range datas {
+		_, err := jcls.ParseClass(bytes.NewReader(d.data))
+		if te, ok := err.(*errs.ThrowError); !ok || te.Class != d.class {
+			t.Errorf("%s: got %v, want %s", d.name, err, d.class)
+		}
+	}
+}
+
+func FuzzParseClass(f *testing.F) {
+	data, err := os.ReadFile("testdata/Test.class")
+	if err != nil {
+		f.Fatalf("Cannot read file: %v", err)
+	}
+	f.Add(data)
+	for n := 8; n < len(data); n *= 2 {
+		f.Add(data[:n])
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		class, err := jcls.ParseClass(bytes.NewReader(data))
+		if err != nil {
+			if _, ok := err.(*errs.ThrowError); !ok {
+				t.Fatalf("unexpected error type %T: %v", err, err)
+			}
+			return
+		}
+		_ = class.String()
+	})
+}
+
+func TestCodeChecksum(t *testing.T) {
+	data, err := os.ReadFile("testdata/Test.class")
+	if err != nil {
+		t.Fatalf("Cannot read file: %v", err)
+	}
+	checksums := func(data []byte) map[string]uint32 {
+		class, err := jcls.ParseClass(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Cannot ParseClass: %v", err)
+		}
+		result := make(map[string]uint32)
+		for _, m := range class.Methods {
+			result[m.Name()] = m.Code.Checksum
+		}
+		return result
+	}
+	before := checksums(data)
+	// change the string constant loaded by main, which keeps the bytecode as is
+	patched := bytes.Replace(data, []byte("running testPrivateVoidMethod"), []byte("Running testPrivateVoidMethod"), 1)
+	after := checksums(patched)
+	for name, sum := range before {
+		if changed := after[name] != sum; changed != (name == "main") {
+			t.Errorf("checksum of %s: before 0x%08x, after 0x%08x", name, sum, after[name])
+		}
+	}
+}
@@ -1,10 +1,10 @@
 package jcls
 
 import (
-	"fmt"
 	"io"
 
 	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/mutf8"
 )
 
 // https://docs.oracle.com/javase/specs/jvms/se21/html/jvms-4.html#jvms-4.4
@@ -35,7 +35,7 @@ type ConstantInfo interface {
 	// If use two constant pool slots
 	IsWide() bool
 	Parse(io.Reader) error
-	Resolve([]ConstantInfo)
+	Resolve([]ConstantInfo) error
 }
 
 func ParseConstant(r io.Reader) (ConstantInfo, error) {
@@ -77,7 +77,7 @@ func ParseConstant(r io.Reader) (ConstantInfo, error) {
 	case TagPackage:
 		c = &ConstantPackage{}
 	default:
-		return nil, fmt.Errorf("Unexpected constant tag %d", t)
+		return nil, formatError("Unexpected constant tag %d", t)
 	}
 	if err = c.Parse(r); err != nil {
 		return nil, err
@@ -99,8 +99,13 @@ func (c *ConstantClass) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantClass) Resolve(infos []ConstantInfo) {
-	c.Name = infos[c.NameInd-1].(*ConstantUtf8).Value
+func (c *ConstantClass) Resolve(infos []ConstantInfo) error {
+	name, err := constantAt[*ConstantUtf8](infos, c.NameInd)
+	if err != nil {
+		return err
+	}
+	c.Name = name.Value
+	return nil
 }
 func (c *ConstantClass) String() string {
 	return "Class: " + c.Name
@@ -126,9 +131,15 @@ func (c *ConstantRef) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantRef) Resolve(infos []ConstantInfo) {
-	c.Class = infos[c.ClassInd-1].(*ConstantClass)
-	c.NameAndType = infos[c.NameAndTypeInd-1].(*ConstantNameAndType)
+func (c *ConstantRef) Resolve(infos []ConstantInfo) error {
+	var err error
+	if c.Class, err = constantAt[*ConstantClass](infos, c.ClassInd); err != nil {
+		return err
+	}
+	if c.NameAndType, err = constantAt[*ConstantNameAndType](infos, c.NameAndTypeInd); err != nil {
+		return err
+	}
+	return nil
 }
 func (c *ConstantRef) String() string {
 	return c.Class.Name + "." + c.NameAndType.String()
@@ -148,8 +159,13 @@ func (c *ConstantString) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantString) Resolve(infos []ConstantInfo) {
-	c.Utf8 = infos[c.Utf8Ind-1].(*ConstantUtf8).Value
+func (c *ConstantString) Resolve(infos []ConstantInfo) error {
+	utf8, err := constantAt[*ConstantUtf8](infos, c.Utf8Ind)
+	if err != nil {
+		return err
+	}
+	c.Utf8 = utf8.Value
+	return nil
 }
 func (c *ConstantString) String() string {
 	return c.Utf8
@@ -168,7 +184,7 @@ func (c *ConstantInteger) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantInteger) Resolve(infos []ConstantInfo) {}
+func (c *ConstantInteger) Resolve(infos []ConstantInfo) error { return nil }
 
 type ConstantFloat struct {
 	Value uint32
@@ -183,7 +199,7 @@ func (c *ConstantFloat) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantFloat) Resolve(infos []ConstantInfo) {}
+func (c *ConstantFloat) Resolve(infos []ConstantInfo) error { return nil }
 
 type ConstantLong struct {
 	Value uint64
@@ -198,7 +214,7 @@ func (c *ConstantLong) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantLong) Resolve(infos []ConstantInfo) {}
+func (c *ConstantLong) Resolve(infos []ConstantInfo) error { return nil }
 
 type ConstantDouble struct {
 	Value uint64
@@ -213,7 +229,7 @@ func (c *ConstantDouble) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantDouble) Resolve(infos []ConstantInfo) {}
+func (c *ConstantDouble) Resolve(infos []ConstantInfo) error { return nil }
 
 type ConstantNameAndType struct {
 	NameInd uint16
@@ -234,12 +250,20 @@ func (c *ConstantNameAndType) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantNameAndType) Resolve(infos []ConstantInfo) {
-	c.Name = infos[c.NameInd-1].(*ConstantUtf8).Value
-	c.Desc = infos[c.DescInd-1].(*ConstantUtf8).Value
+func (c *ConstantNameAndType) Resolve(infos []ConstantInfo) error {
+	name, err := constantAt[*ConstantUtf8](infos, c.NameInd)
+	if err != nil {
+		return err
+	}
+	descriptor, err := constantAt[*ConstantUtf8](infos, c.DescInd)
+	if err != nil {
+		return err
+	}
+	c.Name, c.Desc = name.Value, descriptor.Value
+	return nil
 }
 func (c *ConstantNameAndType) String() string {
-	if c.Desc[0] == '(' {
+	if len(c.Desc) > 0 && c.Desc[0] == '(' {
 		return c.Name + c.Desc
 	}
 	return c.Name + " " + c.Desc
@@ -263,10 +287,12 @@ func (c *ConstantUtf8) Parse(r io.Reader) error {
 	if _, err = io.ReadFull(r, buf); err != nil {
 		return err
 	}
-	c.Value = (string)(buf)
+	if c.Value, err = mutf8.Decode(buf); err != nil {
+		return formatError("Illegal UTF8 string in constant pool")
+	}
 	return nil
 }
-func (c *ConstantUtf8) Resolve(infos []ConstantInfo) {}
+func (c *ConstantUtf8) Resolve(infos []ConstantInfo) error { return nil }
 
 func (c *ConstantUtf8) AsDesc() (*desc.Desc, error) {
 	if c.desc == nil {
@@ -342,13 +368,20 @@ func (c *ConstantMethodHandle) Parse(r io.Reader) error {
 		return err
 	}
 	c.Kind = (MethodKind)(b)
+	if c.Kind < RefGetField || c.Kind > RefInvokeInterface {
+		return formatError("Invalid method handle kind %d", b)
+	}
 	if c.RefInd, err = readUint16(r); err != nil {
 		return err
 	}
 	return nil
 }
-func (c *ConstantMethodHandle) Resolve(infos []ConstantInfo) {
-	c.Ref = infos[c.RefInd-1].(*ConstantRef)
+func (c *ConstantMethodHandle) Resolve(infos []ConstantInfo) error {
+	var err error
+	if c.Ref, err = constantAt[*ConstantRef](infos, c.RefInd); err != nil {
+		return err
+	}
+	return nil
 }
 func (c *ConstantMethodHandle) String() string {
 	return c.Kind.String() + ": " + c.Ref.String()
@@ -368,8 +401,13 @@ func (c *ConstantMethodType) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantMethodType) Resolve(infos []ConstantInfo) {
-	c.Desc = infos[c.DescInd-1].(*ConstantUtf8).Value
+func (c *ConstantMethodType) Resolve(infos []ConstantInfo) error {
+	descriptor, err := constantAt[*ConstantUtf8](infos, c.DescInd)
+	if err != nil {
+		return err
+	}
+	c.Desc = descriptor.Value
+	return nil
 }
 
 type ConstantDynamics struct {
@@ -391,8 +429,12 @@ func (c *ConstantDynamics) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantDynamics) Resolve(infos []ConstantInfo) {
-	c.NameAndType = infos[c.NameAndTypeInd-1].(*ConstantNameAndType)
+func (c *ConstantDynamics) Resolve(infos []ConstantInfo) error {
+	var err error
+	if c.NameAndType, err = constantAt[*ConstantNameAndType](infos, c.NameAndTypeInd); err != nil {
+		return err
+	}
+	return nil
 }
 
 type ConstantModule struct {
@@ -409,8 +451,13 @@ func (c *ConstantModule) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantModule) Resolve(infos []ConstantInfo) {
-	c.Name = infos[c.NameInd-1].(*ConstantUtf8).Value
+func (c *ConstantModule) Resolve(infos []ConstantInfo) error {
+	name, err := constantAt[*ConstantUtf8](infos, c.NameInd)
+	if err != nil {
+		return err
+	}
+	c.Name = name.Value
+	return nil
 }
 
 type ConstantPackage struct {
@@ -427,6 +474,11 @@ func (c *ConstantPackage) Parse(r io.Reader) error {
 	}
 	return nil
 }
-func (c *ConstantPackage) Resolve(infos []ConstantInfo) {
-	c.Name = infos[c.NameInd-1].(*ConstantUtf8).Value
+func (c *ConstantPackage) Resolve(infos []ConstantInfo) error {
+	name, err := constantAt[*ConstantUtf8](infos, c.NameInd)
+	if err != nil {
+		return err
+	}
+	c.Name = name.Value
+	return nil
 }
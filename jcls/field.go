@@ -26,13 +26,23 @@ func ParseField(r io.Reader, consts []ConstantInfo) (*Field, error) {
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
-	f.name = consts[n-1].(*ConstantUtf8).Value
+	name, err := constantAt[*ConstantUtf8](consts, n)
+	if err != nil {
+		return nil, err
+	}
+	if f.name = name.Value; !isUnqualifiedName(f.name, false) {
+		return nil, formatError("Illegal field name %q", f.name)
+	}
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
-	if f.Desc, err = consts[n-1].(*ConstantUtf8).AsDesc(); err != nil {
+	descriptor, err := constantAt[*ConstantUtf8](consts, n)
+	if err != nil {
 		return nil, err
 	}
+	if f.Desc, err = descriptor.AsDesc(); err != nil || !isFieldDesc(f.Desc) {
+		return nil, formatError("Invalid field descriptor %q", descriptor.Value)
+	}
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
@@ -45,6 +55,15 @@ func ParseField(r io.Reader, consts []ConstantInfo) (*Field, error) {
 	return f, nil
 }
 
+func NewField(flags AccessFlag, name string, descriptor *desc.Desc, attrs []ir.Attribute) *Field {
+	f := new(Field)
+	f.AccessFlags = flags
+	f.name = name
+	f.Desc = descriptor
+	f.Attrs = attrs
+	return f
+}
+
 func (f *Field) Name() string {
 	return f.name
 }
@@ -57,6 +76,10 @@ func (f *Field) IsPublic() bool {
 	return f.AccessFlags.Has(AccPublic)
 }
 
+func (f *Field) IsProtected() bool {
+	return f.AccessFlags.Has(AccProtected)
+}
+
 func (f *Field) IsStatic() bool {
 	return f.AccessFlags.Has(AccStatic)
 }
@@ -28,13 +28,23 @@ func ParseMethod(r io.Reader, consts []ConstantInfo) (*Method, error) {
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
-	m.name = consts[n-1].(*ConstantUtf8).Value
+	name, err := constantAt[*ConstantUtf8](consts, n)
+	if err != nil {
+		return nil, err
+	}
+	if m.name = name.Value; !isUnqualifiedName(m.name, true) {
+		return nil, formatError("Illegal method name %q", m.name)
+	}
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
-	if m.desc, err = consts[n-1].(*ConstantUtf8).AsMethodDesc(); err != nil {
+	descriptor, err := constantAt[*ConstantUtf8](consts, n)
+	if err != nil {
 		return nil, err
 	}
+	if m.desc, err = descriptor.AsMethodDesc(); err != nil || !isMethodDesc(m.desc) {
+		return nil, formatError("Invalid method descriptor %q", descriptor.Value)
+	}
 	if n, err = readUint16(r); err != nil {
 		return nil, err
 	}
@@ -80,6 +90,10 @@ func (m *Method) IsPublic() bool {
 	return m.AccessFlags.Has(AccPublic)
 }
 
+func (m *Method) IsProtected() bool {
+	return m.AccessFlags.Has(AccProtected)
+}
+
 func (m *Method) IsStatic() bool {
 	return m.AccessFlags.Has(AccStatic)
 }
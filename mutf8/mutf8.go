@@ -0,0 +1,125 @@
+// Package mutf8 converts between Go strings, the modified UTF-8 used by class files
+// and the UTF-16 code units used by Java strings.
+//
+// Java strings may contain unpaired surrogates which cannot be encoded in UTF-8.
+// They are kept in Go strings using the generalized UTF-8 (WTF-8) encoding,
+// so a Java string can always be converted to a Go string and back without loss.
+package mutf8
+
+import (
+	"errors"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrInvalid = errors.New("mutf8: invalid modified UTF-8 sequence")
+
+// Decode decodes modified UTF-8 bytes, see JVMS 4.4.7
+func Decode(b []byte) (string, error) {
+	ascii := true
+	for _, c := range b {
+		if c == 0 {
+			return "", ErrInvalid
+		}
+		if c >= 0x80 {
+			ascii = false
+		}
+	}
+	if ascii {
+		return (string)(b), nil
+	}
+	units := make([]uint16, 0, len(b))
+	for i := 0; i < len(b); {
+		c := b[i]
+		switch {
+		case c < 0x80:
+			units = append(units, (uint16)(c))
+			i++
+		case c&0xe0 == 0xc0:
+			if i+1 >= len(b) || b[i+1]&0xc0 != 0x80 {
+				return "", ErrInvalid
+			}
+			units = append(units, (uint16)(c&0x1f)<<6|(uint16)(b[i+1]&0x3f))
+			i += 2
+		case c&0xf0 == 0xe0:
+			if i+2 >= len(b) || b[i+1]&0xc0 != 0x80 || b[i+2]&0xc0 != 0x80 {
+				return "", ErrInvalid
+			}
+			units = append(units, (uint16)(c&0x0f)<<12|(uint16)(b[i+1]&0x3f)<<6|(uint16)(b[i+2]&0x3f))
+			i += 3
+		default:
+			return "", ErrInvalid
+		}
+	}
+	return FromUTF16(units), nil
+}
+
+// Encode encodes the string to modified UTF-8 bytes
+func Encode(s string) []byte {
+	units := ToUTF16(s)
+	buf := make([]byte, 0, len(units))
+	for _, u := range units {
+		switch {
+		case u != 0 && u < 0x80:
+			buf = append(buf, (byte)(u))
+		case u < 0x800:
+			buf = append(buf, 0xc0|(byte)(u>>6), 0x80|(byte)(u&0x3f))
+		default:
+			buf = append(buf, 0xe0|(byte)(u>>12), 0x80|(byte)(u>>6&0x3f), 0x80|(byte)(u&0x3f))
+		}
+	}
+	return buf
+}
+
+// FromUTF16 converts UTF-16 code units to a string.
+// Unpaired surrogates are encoded as WTF-8.
+func FromUTF16(units []uint16) string {
+	buf := make([]byte, 0, len(units))
+	for i := 0; i < len(units); i++ {
+		u := units[i]
+		if u < 0x80 {
+			buf = append(buf, (byte)(u))
+			continue
+		}
+		if utf16.IsSurrogate((rune)(u)) {
+			if u < 0xdc00 && i+1 < len(units) {
+				if r := utf16.DecodeRune((rune)(u), (rune)(units[i+1])); r != utf8.RuneError {
+					buf = utf8.AppendRune(buf, r)
+					i++
+					continue
+				}
+			}
+			buf = append(buf, 0xe0|(byte)(u>>12), 0x80|(byte)(u>>6&0x3f), 0x80|(byte)(u&0x3f))
+			continue
+		}
+		buf = utf8.AppendRune(buf, (rune)(u))
+	}
+	return (string)(buf)
+}
+
+// ToUTF16 converts a string to UTF-16 code units.
+// WTF-8 encoded surrogates are converted back, and other invalid bytes become U+FFFD.
+func ToUTF16(s string) []uint16 {
+	units := make([]uint16, 0, len(s))
+	for i := 0; i < len(s); {
+		if c := s[i]; c < 0x80 {
+			units = append(units, (uint16)(c))
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 && i+2 < len(s) && s[i] == 0xed && s[i+1]&0xe0 == 0xa0 && s[i+2]&0xc0 == 0x80 {
+			units = append(units, 0xd000|(uint16)(s[i+1]&0x3f)<<6|(uint16)(s[i+2]&0x3f))
+			i += 3
+			continue
+		}
+		if r >= 0x10000 {
+			r1, r2 := utf16.EncodeRune(r)
+			units = append(units, (uint16)(r1), (uint16)(r2))
+		} else {
+			units = append(units, (uint16)(r))
+		}
+		i += size
+	}
+	return units
+}
@@ -0,0 +1,67 @@
+package mutf8_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/mutf8"
+)
+
+func TestDecode(t *testing.T) {
+	datas := []struct {
+		in  []byte
+		out string
+		ok  bool
+	}{
+		{[]byte("hello"), "hello", true},
+		{[]byte{}, "", true},
+		{[]byte{'a', 0xc0, 0x80, 'b'}, "a\x00b", true},
+		{[]byte{0xc3, 0xa9}, "é", true},
+		{[]byte{0xe4, 0xb8, 0xad}, "中", true},
+		{[]byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}, "😀", true},
+		{[]byte{0xed, 0xa0, 0xbd}, "\xed\xa0\xbd", true},
+		{[]byte{0}, "", false},
+		{[]byte{0xf0, 0x9f, 0x98, 0x80}, "", false},
+		{[]byte{0xe4, 0xb8}, "", false},
+		{[]byte{0xc3, 0x29}, "", false},
+	}
+	for _, d := range datas {
+		s, err := mutf8.Decode(d.in)
+		if d.ok != (err == nil) {
+			t.Errorf("Decode(% x): got error %v, want ok=%v", d.in, err, d.ok)
+			continue
+		}
+		if d.ok {
+			if s != d.out {
+				t.Errorf("Decode(% x): got %q, want %q", d.in, s, d.out)
+			}
+			if b := mutf8.Encode(s); !slices.Equal(b, d.in) {
+				t.Errorf("Encode(%q): got % x, want % x", s, b, d.in)
+			}
+		}
+	}
+}
+
+func TestUTF16(t *testing.T) {
+	datas := []struct {
+		str   string
+		units []uint16
+	}{
+		{"abc", []uint16{'a', 'b', 'c'}},
+		{"ÿ中", []uint16{0xff, 0x4e2d}},
+		{"😀!", []uint16{0xd83d, 0xde00, '!'}},
+		{"\xed\xb8\x80a", []uint16{0xde00, 'a'}},
+		{"\xed\xa0\xbd", []uint16{0xd83d}},
+	}
+	for _, d := range datas {
+		if units := mutf8.ToUTF16(d.str); !slices.Equal(units, d.units) {
+			t.Errorf("ToUTF16(%q): got %x, want %x", d.str, units, d.units)
+		}
+		if s := mutf8.FromUTF16(d.units); s != d.str {
+			t.Errorf("FromUTF16(%x): got %q, want %q", d.units, s, d.str)
+		}
+	}
+	if units := mutf8.ToUTF16("a\xffb"); !slices.Equal(units, []uint16{'a', 0xfffd, 'b'}) {
+		t.Errorf("ToUTF16 with invalid byte: got %x", units)
+	}
+}
@@ -6,6 +6,7 @@ import (
 
 type VMHelper interface {
 	JClass_javaLangCloneable() ir.Class
+	JClass_javaLangInvokeResolvedMethodName() ir.Class
 	JClass_javaLangReflectMethod() ir.Class
 	JField_javaLangClass_classData() ir.Field
 	JField_javaLangReflectMethod_clazz() ir.Field
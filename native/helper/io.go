@@ -0,0 +1,40 @@
+package helper
+
+import (
+	"errors"
+	"io/fs"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+)
+
+// GetFD returns the fd field of java.io.FileDescriptor
+func GetFD(fdObj ir.Ref) int32 {
+	return *(*int32)(fdObj.Class().GetFieldByName("fd").GetPointer(fdObj))
+}
+
+// SetFD sets the fd field of java.io.FileDescriptor
+func SetFD(fdObj ir.Ref, fd int32) {
+	*(*int32)(fdObj.Class().GetFieldByName("fd").GetPointer(fdObj)) = fd
+}
+
+// Strerror formats the error like C strerror, e.g. "No such file or directory"
+func Strerror(err error) string {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		err = pe.Err
+	}
+	msg := err.Error()
+	r, n := utf8.DecodeRuneInString(msg)
+	return string(unicode.ToUpper(r)) + msg[n:]
+}
+
+// IOException converts the error to IOException, the Java exceptions are returned as is
+func IOException(err error) error {
+	if _, ok := errs.AsThrowError(err); ok {
+		return err
+	}
+	return errs.Throw("java/io/IOException", Strerror(err))
+}
@@ -6,6 +6,8 @@ import (
 	_ "github.com/LiterMC/wasm-jdk/native/java/lang/invoke"
 	_ "github.com/LiterMC/wasm-jdk/native/java/lang/ref"
 	_ "github.com/LiterMC/wasm-jdk/native/java/lang/reflect"
+	_ "github.com/LiterMC/wasm-jdk/native/java/net"
+	_ "github.com/LiterMC/wasm-jdk/native/java/nio"
 	_ "github.com/LiterMC/wasm-jdk/native/java/security"
 	_ "github.com/LiterMC/wasm-jdk/native/java/util/concurrent/atomic"
 	_ "github.com/LiterMC/wasm-jdk/native/jdk/internal_/loader"
@@ -13,5 +15,7 @@ import (
 	_ "github.com/LiterMC/wasm-jdk/native/jdk/internal_/perf"
 	_ "github.com/LiterMC/wasm-jdk/native/jdk/internal_/reflect"
 	_ "github.com/LiterMC/wasm-jdk/native/jdk/internal_/util"
+	_ "github.com/LiterMC/wasm-jdk/native/jdk/net"
+	_ "github.com/LiterMC/wasm-jdk/native/sun/nio/ch"
 	_ "github.com/LiterMC/wasm-jdk/native/sun/nio/fs"
 )
@@ -1,17 +1,36 @@
 package java_io
 
 import (
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
 )
 
 func init() {
 	native.RegisterDefaultNative("java/io/FileDescriptor.initIDs()V", FileDescriptor_initIDs)
 	native.RegisterDefaultNative("java/io/FileDescriptor.getHandle(I)J", FileDescriptor_getHandle)
 	native.RegisterDefaultNative("java/io/FileDescriptor.getAppend(I)Z", FileDescriptor_getAppend)
+	native.RegisterDefaultNative("java/io/FileDescriptor.sync0()V", FileDescriptor_sync0)
+	native.RegisterDefaultNative("java/io/FileDescriptor.close0()V", FileDescriptor_close0)
+	native.RegisterDefaultNative("java/io/FileCleanable.cleanupClose0(IJ)V", FileCleanable_cleanupClose0)
 }
 
 // private native void sync0() throws SyncFailedException;
+func FileDescriptor_sync0(vm ir.VM) error {
+	fd := helper.GetFD(vm.GetStack().GetVarRef(0))
+	f, err := vm.(*jvm.VM).Files().Get(fd)
+	if err != nil {
+		return errs.Throw("java/io/SyncFailedException", "sync failed")
+	}
+	if s, ok := f.(interface{ Sync() error }); ok {
+		if err := s.Sync(); err != nil {
+			return errs.Throw("java/io/SyncFailedException", "sync failed")
+		}
+	}
+	return nil
+}
 
 // private static native void initIDs();
 func FileDescriptor_initIDs(vm ir.VM) error {
@@ -34,3 +53,28 @@ func FileDescriptor_getAppend(vm ir.VM) error {
 }
 
 // private native void close0() throws IOException;
+func FileDescriptor_close0(vm ir.VM) error {
+	this := vm.GetStack().GetVarRef(0)
+	fd := helper.GetFD(this)
+	if fd == -1 {
+		return nil
+	}
+	// the descriptor is released even if closing fails, like close(2)
+	helper.SetFD(this, -1)
+	if err := vm.(*jvm.VM).Files().Close(fd); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
+
+// private static native void cleanupClose0(int fd, long handle) throws IOException;
+func FileCleanable_cleanupClose0(vm ir.VM) error {
+	fd := vm.GetStack().GetVarInt32(0)
+	if fd == -1 {
+		return nil
+	}
+	if err := vm.(*jvm.VM).Files().Close(fd); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
@@ -1,14 +1,189 @@
 package java_io
 
 import (
+	"io"
+	"io/fs"
+	"os"
+
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
 )
 
 func init() {
 	native.RegisterDefaultNative("java/io/FileInputStream.initIDs()V", FileInputStream_initIDs)
+	native.RegisterDefaultNative("java/io/FileInputStream.open0(Ljava/lang/String;)V", FileInputStream_open0)
+	native.RegisterDefaultNative("java/io/FileInputStream.read0()I", FileInputStream_read0)
+	native.RegisterDefaultNative("java/io/FileInputStream.readBytes([BII)I", FileInputStream_readBytes)
+	native.RegisterDefaultNative("java/io/FileInputStream.length0()J", FileInputStream_length0)
+	native.RegisterDefaultNative("java/io/FileInputStream.position0()J", FileInputStream_position0)
+	native.RegisterDefaultNative("java/io/FileInputStream.skip0(J)J", FileInputStream_skip0)
+	native.RegisterDefaultNative("java/io/FileInputStream.available0()I", FileInputStream_available0)
 }
 
 func FileInputStream_initIDs(vm ir.VM) error {
 	return nil
 }
+
+// private native void open0(String name) throws FileNotFoundException;
+func FileInputStream_open0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	name, err := pathOf(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	return openFile(vm, this, name, os.O_RDONLY)
+}
+
+// private native int read0() throws IOException;
+func FileInputStream_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	var buf [1]byte
+	n, err := readBytes(vm, f, buf[:])
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		stack.PushInt32(-1)
+	} else {
+		stack.PushInt32((int32)(buf[0]))
+	}
+	return nil
+}
+
+// private native int readBytes(byte[] b, int off, int len) throws IOException;
+func FileInputStream_readBytes(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	arr := stack.GetVarRef(1)
+	off := stack.GetVarInt32(2)
+	length := stack.GetVarInt32(3)
+	if err := checkBounds(arr, off, length); err != nil {
+		return err
+	}
+	if length == 0 {
+		stack.PushInt32(0)
+		return nil
+	}
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	n, err := readBytes(vm, f, arr.GetByteArr()[off:off+length])
+	if err != nil {
+		return err
+	}
+	stack.PushInt32(n)
+	return nil
+}
+
+// private native long length0() throws IOException;
+func FileInputStream_length0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	st, ok := f.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return errs.Throw("java/io/IOException", "Illegal seek")
+	}
+	stat, err := st.Stat()
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt64(stat.Size())
+	return nil
+}
+
+// private native long position0() throws IOException;
+func FileInputStream_position0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	s, ok := f.(io.Seeker)
+	if !ok {
+		return errs.Throw("java/io/IOException", "Illegal seek")
+	}
+	pos, err := s.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt64(pos)
+	return nil
+}
+
+// private native long skip0(long n) throws IOException;
+func FileInputStream_skip0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	n := stack.GetVarInt64(1)
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	if s, ok := f.(io.Seeker); ok {
+		if cur, err := s.Seek(0, io.SeekCurrent); err == nil {
+			end, err := s.Seek(n, io.SeekCurrent)
+			if err != nil {
+				return helper.IOException(err)
+			}
+			stack.PushInt64(end - cur)
+			return nil
+		}
+	}
+	// pipes and the standard input cannot seek, so the bytes are read and discarded
+	var (
+		buf     [8192]byte
+		skipped int64
+	)
+	for skipped < n {
+		m, err := readBytes(vm, f, buf[:min(n-skipped, (int64)(len(buf)))])
+		if err != nil {
+			return err
+		}
+		if m < 0 {
+			break
+		}
+		skipped += (int64)(m)
+	}
+	stack.PushInt64(skipped)
+	return nil
+}
+
+// private native int available0() throws IOException;
+func FileInputStream_available0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	var n int64
+	switch f := f.(type) {
+	case interface{ Len() int }:
+		n = (int64)(f.Len())
+	case vfs.File:
+		stat, err := f.Stat()
+		if err != nil {
+			return helper.IOException(err)
+		}
+		if stat.Mode().IsRegular() {
+			pos, err := f.Seek(0, io.SeekCurrent)
+			if err != nil {
+				return helper.IOException(err)
+			}
+			n = max(stat.Size()-pos, 0)
+		}
+	}
+	stack.PushInt32((int32)(min(n, 0x7fffffff)))
+	return nil
+}
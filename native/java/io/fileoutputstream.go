@@ -1,14 +1,68 @@
 package java_io
 
 import (
+	"os"
+
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
 )
 
 func init() {
 	native.RegisterDefaultNative("java/io/FileOutputStream.initIDs()V", FileOutputStream_initIDs)
+	native.RegisterDefaultNative("java/io/FileOutputStream.open0(Ljava/lang/String;Z)V", FileOutputStream_open0)
+	native.RegisterDefaultNative("java/io/FileOutputStream.write(IZ)V", FileOutputStream_write)
+	native.RegisterDefaultNative("java/io/FileOutputStream.writeBytes([BIIZ)V", FileOutputStream_writeBytes)
 }
 
 func FileOutputStream_initIDs(vm ir.VM) error {
 	return nil
 }
+
+// private native void open0(String name, boolean append) throws FileNotFoundException;
+func FileOutputStream_open0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	name, err := pathOf(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	flag := os.O_WRONLY | os.O_CREATE
+	if stack.GetVarInt32(2) != 0 {
+		flag |= os.O_APPEND
+	} else {
+		flag |= os.O_TRUNC
+	}
+	return openFile(vm, this, name, flag)
+}
+
+// private native void write(int b, boolean append) throws IOException;
+func FileOutputStream_write(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	b := stack.GetVarInt32(1)
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	return writeBytes(vm, f, []byte{(byte)(b)})
+}
+
+// private native void writeBytes(byte[] b, int off, int len, boolean append) throws IOException;
+func FileOutputStream_writeBytes(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	arr := stack.GetVarRef(1)
+	off := stack.GetVarInt32(2)
+	length := stack.GetVarInt32(3)
+	if err := checkBounds(arr, off, length); err != nil {
+		return err
+	}
+	if length == 0 {
+		return nil
+	}
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	return writeBytes(vm, f, arr.GetByteArr()[off:off+length])
+}
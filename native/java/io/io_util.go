@@ -0,0 +1,130 @@
+package java_io
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+var errStreamClosed = errs.Throw("java/io/IOException", "Stream Closed")
+
+// fdObject returns the FileDescriptor in the stream's fd field
+func fdObject(vm ir.VM, stream ir.Ref) ir.Ref {
+	return vm.PtrToRef(*(*unsafe.Pointer)(stream.Class().GetFieldByName("fd").GetPointer(stream)))
+}
+
+// streamFile returns the open file of the stream, e.g. FileInputStream or FileOutputStream
+func streamFile(vm ir.VM, stream ir.Ref) (io.Closer, error) {
+	fdObj := fdObject(vm, stream)
+	if fdObj == nil {
+		return nil, errStreamClosed
+	}
+	fd := helper.GetFD(fdObj)
+	if fd == -1 {
+		return nil, errStreamClosed
+	}
+	f, err := vm.(*jvm.VM).Files().Get(fd)
+	if err != nil {
+		return nil, helper.IOException(err)
+	}
+	return f, nil
+}
+
+// openFile opens the file in the VM filesystem and stores the descriptor into the stream's FileDescriptor
+func openFile(vm ir.VM, stream ir.Ref, name string, flag int) error {
+	f, err := vm.(*jvm.VM).FS().OpenFile(name, flag, 0666)
+	if err == nil {
+		var stat fs.FileInfo
+		if stat, err = f.Stat(); err == nil && stat.IsDir() {
+			err = errors.New("is a directory")
+		}
+		if err != nil {
+			f.Close()
+		}
+	}
+	if err != nil {
+		return errs.Throwf("java/io/FileNotFoundException", "%s (%s)", name, helper.Strerror(err))
+	}
+	fd := vm.(*jvm.VM).Files().Add(f)
+	helper.SetFD(fdObject(vm, stream), fd)
+	return nil
+}
+
+// checkBounds checks the range of the byte array like io_util.c does
+func checkBounds(arr ir.Ref, off, n int32) error {
+	if arr == nil {
+		return errs.NullPointerException
+	}
+	if off < 0 || n < 0 || (int64)(off)+(int64)(n) > (int64)(arr.Len()) {
+		return errs.Throw("java/lang/IndexOutOfBoundsException", "")
+	}
+	return nil
+}
+
+// readBytes reads into the byte array, and returns -1 at the end of the file.
+// The thread blocks in a safe region, since reading the standard input may never return,
+// so it reads into a Go buffer there, and the Java array is only written after leaving the region.
+func readBytes(vm ir.VM, f io.Closer, buf []byte) (int32, error) {
+	r, ok := f.(io.Reader)
+	if !ok {
+		return 0, errs.Throw("java/io/IOException", "Bad file descriptor")
+	}
+	tmp := make([]byte, len(buf))
+	n, err := readBlocking(vm.(*jvm.VM), r, tmp)
+	if n > 0 {
+		copy(buf, tmp[:n])
+	}
+	return n, err
+}
+
+func readBlocking(vm *jvm.VM, r io.Reader, buf []byte) (int32, error) {
+	vm.EnterSafeRegion()
+	defer vm.LeaveSafeRegion()
+	for {
+		n, err := r.Read(buf)
+		if n > 0 || len(buf) == 0 {
+			return (int32)(n), nil
+		}
+		if err == io.EOF {
+			return -1, nil
+		}
+		if err != nil {
+			return 0, helper.IOException(err)
+		}
+	}
+}
+
+// writeBytes writes the byte array, which is copied before entering the safe region
+func writeBytes(vm ir.VM, f io.Closer, buf []byte) error {
+	w, ok := f.(io.Writer)
+	if !ok {
+		return errs.Throw("java/io/IOException", "Bad file descriptor")
+	}
+	buf = slices.Clone(buf)
+	vm.(*jvm.VM).EnterSafeRegion()
+	defer vm.(*jvm.VM).LeaveSafeRegion()
+	if _, err := w.Write(buf); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
+
+// pathOf converts the Java path to the host path
+func pathOf(vm ir.VM, name ir.Ref) (string, error) {
+	if name == nil {
+		return "", errs.NullPointerException
+	}
+	path := vm.GetString(name)
+	if strings.IndexByte(path, 0) >= 0 {
+		return "", errs.Throwf("java/io/FileNotFoundException", "%s (Invalid file path)", path)
+	}
+	return path, nil
+}
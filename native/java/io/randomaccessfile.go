@@ -0,0 +1,218 @@
+package java_io
+
+import (
+	"io"
+	"os"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+)
+
+func init() {
+	native.RegisterDefaultNative("java/io/RandomAccessFile.initIDs()V", RandomAccessFile_initIDs)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.open0(Ljava/lang/String;I)V", RandomAccessFile_open0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.read0()I", RandomAccessFile_read0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.readBytes0([BII)I", RandomAccessFile_readBytes0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.write0(I)V", RandomAccessFile_write0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.writeBytes0([BII)V", RandomAccessFile_writeBytes0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.getFilePointer()J", RandomAccessFile_getFilePointer)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.seek0(J)V", RandomAccessFile_seek0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.length0()J", RandomAccessFile_length0)
+	native.RegisterDefaultNative("java/io/RandomAccessFile.setLength0(J)V", RandomAccessFile_setLength0)
+}
+
+// The modes of RandomAccessFile.open0
+const (
+	raf_O_RDONLY = 1
+	raf_O_RDWR   = 2
+	raf_O_SYNC   = 4
+	raf_O_DSYNC  = 8
+)
+
+func RandomAccessFile_initIDs(vm ir.VM) error {
+	return nil
+}
+
+// private native void open0(String name, int mode) throws FileNotFoundException;
+func RandomAccessFile_open0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	name, err := pathOf(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	mode := stack.GetVarInt32(2)
+	flag := os.O_RDONLY
+	if mode&raf_O_RDWR != 0 {
+		flag = os.O_RDWR | os.O_CREATE
+		if mode&(raf_O_SYNC|raf_O_DSYNC) != 0 {
+			flag |= os.O_SYNC
+		}
+	}
+	return openFile(vm, this, name, flag)
+}
+
+// randomAccessFile returns the open file of the RandomAccessFile, which must be seekable
+func randomAccessFile(vm ir.VM, this ir.Ref) (vfs.File, error) {
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := f.(vfs.File)
+	if !ok {
+		return nil, errs.Throw("java/io/IOException", "Illegal seek")
+	}
+	return file, nil
+}
+
+// private native int read0() throws IOException;
+func RandomAccessFile_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	var buf [1]byte
+	n, err := readBytes(vm, f, buf[:])
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		stack.PushInt32(-1)
+	} else {
+		stack.PushInt32((int32)(buf[0]))
+	}
+	return nil
+}
+
+// private native int readBytes0(byte[] b, int off, int len) throws IOException;
+func RandomAccessFile_readBytes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	arr := stack.GetVarRef(1)
+	off := stack.GetVarInt32(2)
+	length := stack.GetVarInt32(3)
+	if err := checkBounds(arr, off, length); err != nil {
+		return err
+	}
+	if length == 0 {
+		stack.PushInt32(0)
+		return nil
+	}
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	n, err := readBytes(vm, f, arr.GetByteArr()[off:off+length])
+	if err != nil {
+		return err
+	}
+	stack.PushInt32(n)
+	return nil
+}
+
+// private native void write0(int b) throws IOException;
+func RandomAccessFile_write0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := streamFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	return writeBytes(vm, f, []byte{(byte)(stack.GetVarInt32(1))})
+}
+
+// private native void writeBytes0(byte[] b, int off, int len) throws IOException;
+func RandomAccessFile_writeBytes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	arr := stack.GetVarRef(1)
+	off := stack.GetVarInt32(2)
+	length := stack.GetVarInt32(3)
+	if err := checkBounds(arr, off, length); err != nil {
+		return err
+	}
+	if length == 0 {
+		return nil
+	}
+	f, err := streamFile(vm, this)
+	if err != nil {
+		return err
+	}
+	return writeBytes(vm, f, arr.GetByteArr()[off:off+length])
+}
+
+// public native long getFilePointer() throws IOException;
+func RandomAccessFile_getFilePointer(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := randomAccessFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt64(pos)
+	return nil
+}
+
+// private native void seek0(long pos) throws IOException;
+func RandomAccessFile_seek0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	pos := stack.GetVarInt64(1)
+	f, err := randomAccessFile(vm, this)
+	if err != nil {
+		return err
+	}
+	if pos < 0 {
+		return errs.Throw("java/io/IOException", "Negative seek offset")
+	}
+	if _, err := f.Seek(pos, io.SeekStart); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
+
+// private native long length0() throws IOException;
+func RandomAccessFile_length0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := randomAccessFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt64(stat.Size())
+	return nil
+}
+
+// private native void setLength0(long newLength) throws IOException;
+func RandomAccessFile_setLength0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	newLength := stack.GetVarInt64(1)
+	f, err := randomAccessFile(vm, this)
+	if err != nil {
+		return err
+	}
+	cur, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	if err := f.Truncate(newLength); err != nil {
+		return helper.IOException(err)
+	}
+	// the file pointer stays unless it is beyond the new end, like RandomAccessFile.c
+	if cur > newLength {
+		if _, err := f.Seek(newLength, io.SeekStart); err != nil {
+			return helper.IOException(err)
+		}
+	}
+	return nil
+}
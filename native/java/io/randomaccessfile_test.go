@@ -0,0 +1,126 @@
+package java_io
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vm/vmtest"
+)
+
+// newRandomAccessFile creates a RandomAccessFile whose FileDescriptor is not open yet
+func newRandomAccessFile(t *testing.T, vm *jvm.VM, loader *vmtest.Loader) ir.Ref {
+	t.Helper()
+	cls := loader.LoadedClass("java/io/RandomAccessFile")
+	if cls == nil {
+		cls = loader.Define("java/io/RandomAccessFile", "java/lang/Object", "fd Ljava/io/FileDescriptor;")
+	}
+	fdCls, err := loader.LoadClass("java/io/FileDescriptor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fdObj := vm.New(fdCls)
+	helper.SetFD(fdObj, -1)
+	raf := vm.New(cls)
+	*(*unsafe.Pointer)(cls.GetFieldByName("fd").GetPointer(raf)) = vm.RefToPtr(fdObj)
+	return raf
+}
+
+func readFile(t *testing.T, fsys vfs.FS, name string) string {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRandomAccessFile(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	loader := vmtest.NewLoader()
+	vm := vmtest.NewVM(jvm.Options{Loader: loader, FS: fsys})
+	stack := vm.GetStack()
+	call := func(fn func(ir.VM) error, args ...any) {
+		t.Helper()
+		if err := vmtest.Call(vm, fn, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	raf := newRandomAccessFile(t, vm, loader)
+	err := vmtest.Call(vm, RandomAccessFile_open0, raf, vm.NewString("/data"), (int32)(raf_O_RDONLY))
+	if err == nil || !strings.Contains(err.Error(), "/data (No such file or directory)") {
+		t.Errorf("open0 of a missing file returned %v, want FileNotFoundException", err)
+	}
+	call(RandomAccessFile_open0, raf, vm.NewString("/data"), (int32)(raf_O_RDWR))
+
+	arr := vm.NewArray(desc.DescByteArray, 16)
+	buf := arr.GetByteArr()
+	copy(buf, "_hello world_")
+	call(RandomAccessFile_writeBytes0, raf, arr, (int32)(1), (int32)(11))
+	call(RandomAccessFile_write0, raf, (int32)('!'))
+	if got := readFile(t, fsys, "/data"); got != "hello world!" {
+		t.Errorf("file is %q, want %q", got, "hello world!")
+	}
+	call(RandomAccessFile_getFilePointer, raf)
+	if pos := stack.PopInt64(); pos != 12 {
+		t.Errorf("getFilePointer returned %d, want 12", pos)
+	}
+
+	call(RandomAccessFile_seek0, raf, (int64)(6))
+	call(RandomAccessFile_read0, raf)
+	if b := stack.PopInt32(); b != 'w' {
+		t.Errorf("read0 returned %q, want 'w'", b)
+	}
+	clear(buf)
+	call(RandomAccessFile_readBytes0, raf, arr, (int32)(2), (int32)(14))
+	if n := stack.PopInt32(); n != 5 {
+		t.Errorf("readBytes0 returned %d, want 5", n)
+	}
+	if got := string(buf[2:7]); got != "orld!" {
+		t.Errorf("readBytes0 read %q, want %q", got, "orld!")
+	}
+	call(RandomAccessFile_readBytes0, raf, arr, (int32)(0), (int32)(16))
+	if n := stack.PopInt32(); n != -1 {
+		t.Errorf("readBytes0 at the end returned %d, want -1", n)
+	}
+	if err := vmtest.Call(vm, RandomAccessFile_seek0, raf, (int64)(-1)); err == nil {
+		t.Error("seek0 to a negative offset succeeded")
+	}
+
+	// the file pointer moves to the new end if it is beyond it
+	call(RandomAccessFile_setLength0, raf, (int64)(5))
+	call(RandomAccessFile_length0, raf)
+	if size := stack.PopInt64(); size != 5 {
+		t.Errorf("length0 returned %d, want 5", size)
+	}
+	call(RandomAccessFile_getFilePointer, raf)
+	if pos := stack.PopInt64(); pos != 5 {
+		t.Errorf("getFilePointer after setLength0 returned %d, want 5", pos)
+	}
+	call(RandomAccessFile_read0, raf)
+	if b := stack.PopInt32(); b != -1 {
+		t.Errorf("read0 at the end returned %d, want -1", b)
+	}
+	call(RandomAccessFile_seek0, raf, (int64)(1))
+	call(RandomAccessFile_setLength0, raf, (int64)(8))
+	call(RandomAccessFile_getFilePointer, raf)
+	if pos := stack.PopInt64(); pos != 1 {
+		t.Errorf("getFilePointer after extending by setLength0 returned %d, want 1", pos)
+	}
+	if got := readFile(t, fsys, "/data"); got != "hello\x00\x00\x00" {
+		t.Errorf("file is %q after setLength0, want %q", got, "hello\x00\x00\x00")
+	}
+}
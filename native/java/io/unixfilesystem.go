@@ -1,14 +1,288 @@
 package java_io
 
 import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/desc"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
 )
 
 func init() {
 	native.RegisterDefaultNative("java/io/UnixFileSystem.initIDs()V", UnixFileSystem_initIDs)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.canonicalize0(Ljava/lang/String;)Ljava/lang/String;", UnixFileSystem_canonicalize0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.getBooleanAttributes0(Ljava/io/File;)I", UnixFileSystem_getBooleanAttributes0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.checkAccess0(Ljava/io/File;I)Z", UnixFileSystem_checkAccess0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.getLastModifiedTime0(Ljava/io/File;)J", UnixFileSystem_getLastModifiedTime0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.getLength0(Ljava/io/File;)J", UnixFileSystem_getLength0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.setPermission0(Ljava/io/File;IZZ)Z", UnixFileSystem_setPermission0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.createFileExclusively0(Ljava/lang/String;)Z", UnixFileSystem_createFileExclusively0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.delete0(Ljava/io/File;)Z", UnixFileSystem_delete0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.list0(Ljava/io/File;)[Ljava/lang/String;", UnixFileSystem_list0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.createDirectory0(Ljava/io/File;)Z", UnixFileSystem_createDirectory0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.rename0(Ljava/io/File;Ljava/io/File;)Z", UnixFileSystem_rename0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.setLastModifiedTime0(Ljava/io/File;J)Z", UnixFileSystem_setLastModifiedTime0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.setReadOnly0(Ljava/io/File;)Z", UnixFileSystem_setReadOnly0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.getSpace0(Ljava/io/File;I)J", UnixFileSystem_getSpace0)
+	native.RegisterDefaultNative("java/io/UnixFileSystem.getNameMax0(Ljava/lang/String;)J", UnixFileSystem_getNameMax0)
 }
 
+// The constants of java.io.FileSystem
+const (
+	BA_EXISTS    = 0x01
+	BA_REGULAR   = 0x02
+	BA_DIRECTORY = 0x04
+	BA_HIDDEN    = 0x08
+
+	ACCESS_READ    = 0x04
+	ACCESS_WRITE   = 0x02
+	ACCESS_EXECUTE = 0x01
+)
+
 func UnixFileSystem_initIDs(vm ir.VM) error {
 	return nil
 }
+
+func getVFS(vm ir.VM) vfs.FS {
+	return vm.(*jvm.VM).FS()
+}
+
+// filePath returns the path field of the java.io.File
+func filePath(vm ir.VM, file ir.Ref) string {
+	return vm.GetString(vm.PtrToRef(*(*unsafe.Pointer)(file.Class().GetFieldByName("path").GetPointer(file))))
+}
+
+func pushBool(stack ir.Stack, v bool) {
+	if v {
+		stack.PushInt32(1)
+	} else {
+		stack.PushInt32(0)
+	}
+}
+
+// private native String canonicalize0(String path) throws IOException;
+func UnixFileSystem_canonicalize0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name, err := pathOf(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	// like canonicalize_md.c, the longest existing prefix is resolved, and the rest is appended
+	p := path.Clean(name)
+	rest := ""
+	for {
+		if real, err := vfs.Realpath(getVFS(vm), p); err == nil {
+			p = path.Join(real, rest)
+			break
+		}
+		dir, base := path.Split(p)
+		if dir == "" || base == "" {
+			p = path.Join(p, rest)
+			break
+		}
+		rest = path.Join(base, rest)
+		p = path.Clean(dir)
+	}
+	stack.PushRef(vm.NewString(p))
+	return nil
+}
+
+// private native int getBooleanAttributes0(File f);
+func UnixFileSystem_getBooleanAttributes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	stat, err := getVFS(vm).Stat(filePath(vm, stack.GetVarRef(1)))
+	var attrs int32
+	if err == nil {
+		attrs |= BA_EXISTS
+		if stat.Mode().IsRegular() {
+			attrs |= BA_REGULAR
+		}
+		if stat.IsDir() {
+			attrs |= BA_DIRECTORY
+		}
+	}
+	stack.PushInt32(attrs)
+	return nil
+}
+
+// private native boolean checkAccess0(File f, int access);
+func UnixFileSystem_checkAccess0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := filePath(vm, stack.GetVarRef(1))
+	access := stack.GetVarInt32(2)
+	var mode uint32
+	if access&ACCESS_READ != 0 {
+		mode |= vfs.AccessRead
+	}
+	if access&ACCESS_WRITE != 0 {
+		mode |= vfs.AccessWrite
+	}
+	if access&ACCESS_EXECUTE != 0 {
+		mode |= vfs.AccessExecute
+	}
+	pushBool(stack, getVFS(vm).Access(name, mode) == nil)
+	return nil
+}
+
+// private native long getLastModifiedTime0(File f);
+func UnixFileSystem_getLastModifiedTime0(vm ir.VM) error {
+	stack := vm.GetStack()
+	var millis int64
+	if stat, err := getVFS(vm).Stat(filePath(vm, stack.GetVarRef(1))); err == nil {
+		millis = stat.ModTime().UnixMilli()
+	}
+	stack.PushInt64(millis)
+	return nil
+}
+
+// private native long getLength0(File f);
+func UnixFileSystem_getLength0(vm ir.VM) error {
+	stack := vm.GetStack()
+	var size int64
+	if stat, err := getVFS(vm).Stat(filePath(vm, stack.GetVarRef(1))); err == nil {
+		size = stat.Size()
+	}
+	stack.PushInt64(size)
+	return nil
+}
+
+// private native boolean setPermission0(File f, int access, boolean enable, boolean owneronly);
+func UnixFileSystem_setPermission0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := filePath(vm, stack.GetVarRef(1))
+	access := (fs.FileMode)(stack.GetVarInt32(2)) & 07
+	enable := stack.GetVarInt32(3) != 0
+	owneronly := stack.GetVarInt32(4) != 0
+	bits := access << 6
+	if !owneronly {
+		bits = access * 0111
+	}
+	fsys := getVFS(vm)
+	stat, err := fsys.Stat(name)
+	if err == nil {
+		mode := stat.Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+		if enable {
+			mode |= bits
+		} else {
+			mode &^= bits
+		}
+		err = fsys.Chmod(name, mode)
+	}
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native boolean createFileExclusively0(String path) throws IOException;
+func UnixFileSystem_createFileExclusively0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name, err := pathOf(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	f, err := getVFS(vm).OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0666)
+	if err != nil {
+		if errors.Is(err, syscall.EEXIST) {
+			pushBool(stack, false)
+			return nil
+		}
+		return helper.IOException(err)
+	}
+	f.Close()
+	pushBool(stack, true)
+	return nil
+}
+
+// private native boolean delete0(File f);
+func UnixFileSystem_delete0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := filePath(vm, stack.GetVarRef(1))
+	fsys := getVFS(vm)
+	stat, err := fsys.Lstat(name)
+	if err == nil {
+		if stat.IsDir() {
+			err = fsys.Rmdir(name)
+		} else {
+			err = fsys.Unlink(name)
+		}
+	}
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native String[] list0(File f);
+func UnixFileSystem_list0(vm ir.VM) error {
+	stack := vm.GetStack()
+	entries, err := getVFS(vm).ReadDir(filePath(vm, stack.GetVarRef(1)))
+	if err != nil {
+		stack.PushRef(nil)
+		return nil
+	}
+	arr := vm.NewArray(desc.DescStringArray, (int32)(len(entries)))
+	refs := arr.GetRefArr()
+	for i, entry := range entries {
+		refs[i] = vm.RefToPtr(vm.NewString(entry.Name()))
+	}
+	stack.PushRef(arr)
+	return nil
+}
+
+// private native boolean createDirectory0(File f);
+func UnixFileSystem_createDirectory0(vm ir.VM) error {
+	stack := vm.GetStack()
+	err := getVFS(vm).Mkdir(filePath(vm, stack.GetVarRef(1)), 0777)
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native boolean rename0(File f1, File f2);
+func UnixFileSystem_rename0(vm ir.VM) error {
+	stack := vm.GetStack()
+	err := getVFS(vm).Rename(filePath(vm, stack.GetVarRef(1)), filePath(vm, stack.GetVarRef(2)))
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native boolean setLastModifiedTime0(File f, long time);
+func UnixFileSystem_setLastModifiedTime0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := filePath(vm, stack.GetVarRef(1))
+	millis := stack.GetVarInt64(2)
+	err := getVFS(vm).Chtimes(name, time.Time{}, time.UnixMilli(millis))
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native boolean setReadOnly0(File f);
+func UnixFileSystem_setReadOnly0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := filePath(vm, stack.GetVarRef(1))
+	fsys := getVFS(vm)
+	stat, err := fsys.Stat(name)
+	if err == nil {
+		err = fsys.Chmod(name, stat.Mode()&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)&^0222)
+	}
+	pushBool(stack, err == nil)
+	return nil
+}
+
+// private native long getSpace0(File f, int t);
+func UnixFileSystem_getSpace0(vm ir.VM) error {
+	// the VFS does not report the space, 0 means unknown
+	vm.GetStack().PushInt64(0)
+	return nil
+}
+
+// private native long getNameMax0(String path);
+func UnixFileSystem_getNameMax0(vm ir.VM) error {
+	vm.GetStack().PushInt64(255)
+	return nil
+}
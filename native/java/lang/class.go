@@ -75,7 +75,9 @@ func Class_forName0(vm ir.VM) error {
 		return &errs.ClassNotFoundException{Class: classPath, Cause: err}
 	}
 	if initialize {
-		class.(*jvm.Class).InitBeforeUse(vm.(*jvm.VM))
+		if err := vm.InitClass(class); err != nil {
+			return err
+		}
 	}
 	_ = caller
 	stack.PushRef(class.AsRef(vm))
@@ -385,6 +387,9 @@ func Class_getDeclaredMethods0(vm ir.VM) error {
 	publicOnly := stack.GetVar(1) != 0
 	methods := make([]unsafe.Pointer, 0)
 	for method := range this.GetMethods() {
+		if method.GetDeclaringClass() != this {
+			continue
+		}
 		if !method.IsConstructor() && method.Name() != "<clinit>" {
 			if !publicOnly || method.IsPublic() {
 				methods = append(methods, vm.RefToPtr(method.AsRef(vm)))
@@ -404,7 +409,7 @@ func Class_getDeclaredConstructors0(vm ir.VM) error {
 	publicOnly := stack.GetVar(1) != 0
 	constructors := make([]unsafe.Pointer, 0)
 	for method := range this.GetMethods() {
-		if method.IsConstructor() {
+		if method.IsConstructor() && method.GetDeclaringClass() == this {
 			if !publicOnly || method.IsPublic() {
 				constructors = append(constructors, vm.RefToPtr(method.AsRef(vm)))
 			}
@@ -89,7 +89,9 @@ func ClassLoader_defineClass0(vm ir.VM) error {
 	}
 
 	if initialize {
-		class.InitBeforeUse(vm.(*jvm.VM))
+		if err := vm.InitClass(class); err != nil {
+			return err
+		}
 	}
 
 	_ = lookup
@@ -4,10 +4,7 @@ import (
 	jvm "github.com/LiterMC/wasm-jdk/vm"
 )
 
-type ResolvedMethodNameData struct {
-	VMTarget *jvm.Method
-	VMHolder *jvm.Class
-}
+type ResolvedMethodNameData = jvm.ResolvedMethodNameData
 
 type MemberName struct {
 	// private Class<?> clazz;       // class in which the member is defined
@@ -24,4 +21,5 @@ type MemberName struct {
 
 type MemberNameData struct {
 	VMIndex int64
+	Field   *jvm.Field
 }
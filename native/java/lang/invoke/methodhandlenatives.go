@@ -1,7 +1,12 @@
 package java_lang_invoke
 
 import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
 	"github.com/LiterMC/wasm-jdk/native"
 	"github.com/LiterMC/wasm-jdk/native/helper"
 	jvm "github.com/LiterMC/wasm-jdk/vm"
@@ -41,6 +46,14 @@ func MethodHandleNatives_registerNatives(vm ir.VM) error {
 	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.init(Ljava/lang/invoke/MemberName;Ljava/lang/Object;)V", MethodHandleNatives_init)
 	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.expand(Ljava/lang/invoke/MemberName;)V", MethodHandleNatives_expand)
 	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.resolve(Ljava/lang/invoke/MemberName;Ljava/lang/Class;IZ)Ljava/lang/invoke/MemberName;", MethodHandleNatives_resolve)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.objectFieldOffset(Ljava/lang/invoke/MemberName;)J", MethodHandleNatives_objectFieldOffset)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.staticFieldOffset(Ljava/lang/invoke/MemberName;)J", MethodHandleNatives_staticFieldOffset)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.staticFieldBase(Ljava/lang/invoke/MemberName;)Ljava/lang/Object;", MethodHandleNatives_staticFieldBase)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.getMemberVMInfo(Ljava/lang/invoke/MemberName;)Ljava/lang/Object;", MethodHandleNatives_getMemberVMInfo)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.setCallSiteTargetNormal(Ljava/lang/invoke/CallSite;Ljava/lang/invoke/MethodHandle;)V", MethodHandleNatives_setCallSiteTarget)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.setCallSiteTargetVolatile(Ljava/lang/invoke/CallSite;Ljava/lang/invoke/MethodHandle;)V", MethodHandleNatives_setCallSiteTarget)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.clearCallSiteContext(Ljava/lang/invoke/MethodHandleNatives$CallSiteContext;)V", MethodHandleNatives_clearCallSiteContext)
+	native.LoadNative(vm, "java/lang/invoke/MethodHandleNatives.getNamedCon(I[Ljava/lang/Object;)I", MethodHandleNatives_getNamedCon)
 	return nil
 }
 
@@ -53,29 +66,98 @@ func MethodHandleNatives_init(vm ir.VM) error {
 	data := (*MemberName)(self.Data())
 	vmHelper := vm.(helper.VMHelper)
 	if refClassName == "java/lang/reflect/Field" {
-		panic("TODO")
-	}
-	if refClassName == "java/lang/reflect/Method" {
+		field := jvm.ReflectionTarget(ref).(*jvm.Field)
+		data.Clazz = field.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		flags := field.Modifiers()
+		flags |= MN_IS_FIELD
+		if field.IsStatic() {
+			flags |= REF_getStatic << MN_REFERENCE_KIND_SHIFT
+		} else {
+			flags |= REF_getField << MN_REFERENCE_KIND_SHIFT
+		}
+		data.Flags = flags
+		*self.UserData() = &MemberNameData{
+			VMIndex: field.Offset(),
+			Field:   field,
+		}
+	} else if refClassName == "java/lang/reflect/Method" {
+		method := jvm.ReflectionTarget(ref).(*jvm.Method)
 		data.Clazz = *(**jvm.Ref)(vmHelper.JField_javaLangReflectMethod_clazz().GetPointer(ref))
 		flags := *(*int32)(vmHelper.JField_javaLangReflectMethod_modifiers().GetPointer(ref))
 		flags |= MN_IS_METHOD
-		flags |= REF_invokeVirtual << MN_REFERENCE_KIND_SHIFT
+		switch {
+		case method.IsStatic():
+			flags |= REF_invokeStatic << MN_REFERENCE_KIND_SHIFT
+		case method.AccessFlags.Has(jcls.AccPrivate):
+			flags |= REF_invokeSpecial << MN_REFERENCE_KIND_SHIFT
+		case method.GetDeclaringClass().IsInterface():
+			flags |= REF_invokeInterface << MN_REFERENCE_KIND_SHIFT
+		default:
+			flags |= REF_invokeVirtual << MN_REFERENCE_KIND_SHIFT
+		}
 		data.Flags = flags
+		data.Method = newResolvedMethodName(vm, method)
 	} else if refClassName == "java/lang/reflect/Constructor" {
-		panic("TODO")
+		method := jvm.ReflectionTarget(ref).(*jvm.Method)
+		data.Clazz = method.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		flags := method.Modifiers()
+		flags |= MN_IS_CONSTRUCTOR
+		flags |= REF_invokeSpecial << MN_REFERENCE_KIND_SHIFT
+		data.Flags = flags
+		data.Method = newResolvedMethodName(vm, method)
 	}
-	_ = self
 	return nil
 }
 
+func newResolvedMethodName(vm ir.VM, method *jvm.Method) *jvm.Ref {
+	ref := vm.New(vm.(helper.VMHelper).JClass_javaLangInvokeResolvedMethodName())
+	*ref.UserData() = &ResolvedMethodNameData{
+		VMTarget: method,
+		VMHolder: method.GetDeclaringClass().(*jvm.Class),
+	}
+	return ref.(*jvm.Ref)
+}
+
 // static native void expand(MemberName self);
 func MethodHandleNatives_expand(vm ir.VM) error {
 	stack := vm.GetStack()
 	self := stack.GetVarRef(0)
-	if true {
-		panic("TODO")
+	data := (*MemberName)(self.Data())
+	switch {
+	case data.Flags&(MN_IS_METHOD|MN_IS_CONSTRUCTOR) != 0:
+		if data.Method == nil {
+			return errs.Throw("java/lang/IllegalArgumentException", "MemberName is not resolved")
+		}
+		method := (*data.Method.UserData()).(*ResolvedMethodNameData).VMTarget
+		if data.Clazz == nil {
+			data.Clazz = method.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		}
+		if data.Name == nil {
+			data.Name = vm.GetStringInternOrNew(method.Name()).(*jvm.Ref)
+		}
+		if data.Type == nil {
+			mt, err := vm.(*jvm.VM).NewMethodType(method.Desc().String())
+			if err != nil {
+				return err
+			}
+			data.Type = mt.(*jvm.Ref)
+		}
+	case data.Flags&MN_IS_FIELD != 0:
+		udata, ok := (*self.UserData()).(*MemberNameData)
+		if !ok || udata.Field == nil {
+			return errs.Throw("java/lang/IllegalArgumentException", "MemberName is not resolved")
+		}
+		field := udata.Field
+		if data.Clazz == nil {
+			data.Clazz = field.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		}
+		if data.Name == nil {
+			data.Name = vm.GetStringInternOrNew(field.Name()).(*jvm.Ref)
+		}
+		if data.Type == nil {
+			data.Type = field.Type().AsRef(vm).(*jvm.Ref)
+		}
 	}
-	_ = self
 	return nil
 }
 
@@ -83,69 +165,196 @@ func MethodHandleNatives_expand(vm ir.VM) error {
 func MethodHandleNatives_resolve(vm ir.VM) error {
 	stack := vm.GetStack()
 	self := stack.GetVarRef(0)
-	// caller := stack.GetVarRef(1)
-	// lookupMode := stack.GetVarInt32(2)
-	// speculativeResolve := stack.GetVar(3) != 0
+	speculativeResolve := stack.GetVar(3) != 0
+	if err := resolveMemberName(vm.(*jvm.VM), self); err != nil {
+		if speculativeResolve {
+			stack.PushRef(nil)
+			return nil
+		}
+		return err
+	}
 	stack.PushRef(self)
 	return nil
 }
 
-// static native long objectFieldOffset(MemberName self);  // e.g., returns vmindex
-func MethodHandleNatives_objectFieldOffset(vm ir.VM) error {
-	stack := vm.GetStack()
-	self := stack.GetVarRef(0)
-	udata := self.UserData()
-	if *udata == nil {
-		data := (*MemberName)(self.Data())
-		class := vm.GetClass(data.Clazz)
-		name := vm.GetString(data.Name)
-		field := class.GetFieldByName(name)
-		if field.IsStatic() {
-			panic("field is static")
+func resolveMemberName(vm *jvm.VM, self ir.Ref) error {
+	data := (*MemberName)(self.Data())
+	if data.Method != nil || *self.UserData() != nil {
+		return nil
+	}
+	if data.Clazz == nil || data.Name == nil || data.Type == nil {
+		return errs.Throw("java/lang/IllegalArgumentException", "MemberName is not complete")
+	}
+	class := (*data.Clazz.UserData()).(*jvm.Class)
+	name := vm.GetString(data.Name)
+	kind := (data.Flags >> MN_REFERENCE_KIND_SHIFT) & MN_REFERENCE_KIND_MASK
+	switch {
+	case data.Flags&MN_IS_FIELD != 0:
+		field, _ := class.GetFieldByName(name).(*jvm.Field)
+		if field == nil || field.Desc.String() != (*data.Type.UserData()).(*jvm.Class).Desc().String() {
+			return errs.Throwf("java/lang/NoSuchFieldError", "%s.%s", class.Name(), name)
+		}
+		if wantStatic := kind == REF_getStatic || kind == REF_putStatic; field.IsStatic() != wantStatic {
+			return errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected static field %v: %s.%s", wantStatic, class.Name(), name)
 		}
-		*udata = &MemberNameData{
+		data.Clazz = field.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		data.Flags = field.Modifiers() | MN_IS_FIELD | kind<<MN_REFERENCE_KIND_SHIFT
+		*self.UserData() = &MemberNameData{
 			VMIndex: field.Offset(),
+			Field:   field,
+		}
+	case data.Flags&(MN_IS_METHOD|MN_IS_CONSTRUCTOR) != 0:
+		var typ string
+		if data.Type.Class().Name() == "java/lang/String" {
+			typ = vm.GetString(data.Type)
+		} else {
+			typ = vm.MethodTypeDesc(data.Type).String()
+		}
+		var (
+			method *jvm.Method
+			err    error
+		)
+		if data.Flags&MN_IS_CONSTRUCTOR != 0 {
+			method, _ = class.GetMethodByNameAndType("<init>", typ).(*jvm.Method)
+			if method == nil || method.GetDeclaringClass() != class {
+				return errs.Throwf("java/lang/NoSuchMethodError", "'%s.<init>%s'", class.Name(), typ)
+			}
+		} else if method, err = class.ResolveMethod(name, typ); err != nil {
+			return err
 		}
+		if method.IsStatic() != (kind == REF_invokeStatic) {
+			return errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected static method %v: '%s'", kind == REF_invokeStatic, method.Location())
+		}
+		switch {
+		case kind == REF_invokeInterface && !method.GetDeclaringClass().IsInterface():
+			kind = REF_invokeVirtual
+		case kind == REF_invokeVirtual && method.GetDeclaringClass().IsInterface():
+			kind = REF_invokeInterface
+		}
+		flags := method.Modifiers() | kind<<MN_REFERENCE_KIND_SHIFT
+		if method.IsConstructor() {
+			flags |= MN_IS_CONSTRUCTOR
+		} else {
+			flags |= MN_IS_METHOD
+		}
+		data.Clazz = method.GetDeclaringClass().AsRef(vm).(*jvm.Ref)
+		data.Flags = flags
+		data.Method = newResolvedMethodName(vm, method)
+	default:
+		return errs.Throwf("java/lang/LinkageError", "cannot resolve %s.%s", class.Name(), name)
 	}
-	vmindex := (*udata).(*MemberNameData).VMIndex
-	stack.PushInt64(vmindex)
 	return nil
 }
 
-// static native long staticFieldOffset(MemberName self);  // e.g., returns vmindex
-func MethodHandleNatives_staticFieldOffset(vm ir.VM) error {
-	stack := vm.GetStack()
-	self := stack.GetVarRef(0)
-	udata := self.UserData()
-	if *udata == nil {
+// memberField returns the field of the resolved MemberName
+func memberField(vm ir.VM, self ir.Ref, static bool) (*jvm.Field, error) {
+	udata, ok := (*self.UserData()).(*MemberNameData)
+	if !ok || udata.Field == nil {
 		data := (*MemberName)(self.Data())
-		class := vm.GetClass(data.Clazz)
+		if data.Clazz == nil || data.Name == nil {
+			return nil, errs.Throw("java/lang/IllegalArgumentException", "MemberName is not resolved")
+		}
+		class := (*data.Clazz.UserData()).(*jvm.Class)
 		name := vm.GetString(data.Name)
-		field := class.GetFieldByName(name)
-		if !field.IsStatic() {
-			panic("field is not static")
+		field, _ := class.GetFieldByName(name).(*jvm.Field)
+		if field == nil {
+			return nil, errs.Throwf("java/lang/NoSuchFieldError", "%s.%s", class.Name(), name)
 		}
-		*udata = &MemberNameData{
+		udata = &MemberNameData{
 			VMIndex: field.Offset(),
+			Field:   field,
 		}
+		*self.UserData() = udata
+	}
+	if udata.Field.IsStatic() != static {
+		return nil, errs.Throwf("java/lang/IllegalArgumentException", "Expected static field %v: %s", static, udata.Field.Name())
+	}
+	return udata.Field, nil
+}
+
+// static native long objectFieldOffset(MemberName self);  // e.g., returns vmindex
+func MethodHandleNatives_objectFieldOffset(vm ir.VM) error {
+	stack := vm.GetStack()
+	field, err := memberField(vm, stack.GetVarRef(0), false)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(field.Offset())
+	return nil
+}
+
+// static native long staticFieldOffset(MemberName self);  // e.g., returns vmindex
+func MethodHandleNatives_staticFieldOffset(vm ir.VM) error {
+	stack := vm.GetStack()
+	field, err := memberField(vm, stack.GetVarRef(0), true)
+	if err != nil {
+		return err
 	}
-	vmindex := (*udata).(*MemberNameData).VMIndex
-	stack.PushInt64(vmindex)
+	stack.PushInt64(field.Offset())
 	return nil
 }
 
 // static native Object staticFieldBase(MemberName self);  // e.g., returns clazz
 func MethodHandleNatives_staticFieldBase(vm ir.VM) error {
+	stack := vm.GetStack()
+	field, err := memberField(vm, stack.GetVarRef(0), true)
+	if err != nil {
+		return err
+	}
+	stack.PushRef(field.GetDeclaringClass().(*jvm.Class).StaticBase())
+	return nil
+}
+
+// static native Object getMemberVMInfo(MemberName self);  // returns {vmindex,vmtarget}
+func MethodHandleNatives_getMemberVMInfo(vm ir.VM) error {
 	stack := vm.GetStack()
 	self := stack.GetVarRef(0)
 	data := (*MemberName)(self.Data())
-	stack.PushRef(data.Clazz)
+	longClass, err := vm.GetClassByName("java/lang/Long")
+	if err != nil {
+		return err
+	}
+	vmindex := vm.New(longClass)
+	info := vm.NewObjectArray(vm.GetObjectClass(), 2)
+	infoArr := info.GetRefArr()
+	if data.Flags&MN_IS_FIELD != 0 {
+		kind := (data.Flags >> MN_REFERENCE_KIND_SHIFT) & MN_REFERENCE_KIND_MASK
+		field, err := memberField(vm, self, kind == REF_getStatic || kind == REF_putStatic)
+		if err != nil {
+			return err
+		}
+		*(*int64)(longClass.GetFieldByName("value").GetPointer(vmindex)) = field.Offset()
+		infoArr[1] = vm.RefToPtr(data.Clazz)
+	} else {
+		*(*int64)(longClass.GetFieldByName("value").GetPointer(vmindex)) = -1
+		infoArr[1] = vm.RefToPtr(self)
+	}
+	infoArr[0] = vm.RefToPtr(vmindex)
+	stack.PushRef(info)
 	return nil
 }
 
-// static native Object getMemberVMInfo(MemberName self);  // returns {vmindex,vmtarget}
 // static native void setCallSiteTargetNormal(CallSite site, MethodHandle target);
 // static native void setCallSiteTargetVolatile(CallSite site, MethodHandle target);
-// static native void copyOutBootstrapArguments(Class<?> caller, int[] indexInfo, int start, int end, Object[] buf, int pos, boolean resolve, Object ifNotAvailable);
+func MethodHandleNatives_setCallSiteTarget(vm ir.VM) error {
+	stack := vm.GetStack()
+	site := stack.GetVarRef(0)
+	target := stack.GetVarRef(1)
+	field := site.Class().GetFieldByName("target")
+	atomic.StorePointer((*unsafe.Pointer)(field.GetPointer(site)), vm.RefToPtr(target))
+	return nil
+}
+
 // private static native void clearCallSiteContext(CallSiteContext context);
+func MethodHandleNatives_clearCallSiteContext(vm ir.VM) error {
+	return nil
+}
+
 // private static native int getNamedCon(int which, Object[] name);
+func MethodHandleNatives_getNamedCon(vm ir.VM) error {
+	// no constants are exported to verify against the Java side
+	vm.GetStack().PushInt32(0)
+	return nil
+}
+
+// static native void copyOutBootstrapArguments(Class<?> caller, int[] indexInfo, int start, int end, Object[] buf, int pos, boolean resolve, Object ifNotAvailable);
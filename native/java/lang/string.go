@@ -3,10 +3,12 @@ package java_lang
 import (
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
+	misc "github.com/LiterMC/wasm-jdk/native/jdk/internal_/misc"
 )
 
 func init() {
 	native.RegisterDefaultNative("java/lang/String.intern()Ljava/lang/String;", String_intern)
+	native.RegisterDefaultNative("java/lang/StringUTF16.isBigEndian()Z", StringUTF16_isBigEndian)
 }
 
 // public native String intern();
@@ -17,3 +19,15 @@ func String_intern(vm ir.VM) error {
 	stack.PushRef(ref)
 	return nil
 }
+
+// private static native boolean isBigEndian();
+func StringUTF16_isBigEndian(vm ir.VM) error {
+	stack := vm.GetStack()
+	// the VM stores UTF16 strings in native byte order
+	if misc.BigEndian {
+		stack.PushInt32(1)
+	} else {
+		stack.PushInt32(0)
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package java_lang
 
 import (
+	"sync/atomic"
 	"time"
 	"unsafe"
 
@@ -27,22 +28,24 @@ func System_registerNatives(vm ir.VM) error {
 
 // private static native void setIn0(InputStream in);
 func System_setIn0(vm ir.VM) error {
-	stream := vm.GetStack().GetVarRef(0)
-	_ = stream
-	return nil
+	return setStdStream(vm, "in")
 }
 
 // private static native void setOut0(PrintStream out);
 func System_setOut0(vm ir.VM) error {
-	stream := vm.GetStack().GetVarRef(0)
-	_ = stream
-	return nil
+	return setStdStream(vm, "out")
 }
 
 // private static native void setErr0(PrintStream err);
 func System_setErr0(vm ir.VM) error {
+	return setStdStream(vm, "err")
+}
+
+// setStdStream stores the argument into the final static field of System
+func setStdStream(vm ir.VM, name string) error {
 	stream := vm.GetStack().GetVarRef(0)
-	_ = stream
+	field := vm.GetCurrentClass().GetFieldByName(name)
+	atomic.StorePointer((*unsafe.Pointer)(field.GetPointer(nil)), vm.RefToPtr(stream))
 	return nil
 }
 
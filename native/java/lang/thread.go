@@ -98,8 +98,7 @@ func Thread_yield0(vm ir.VM) error {
 // private static native void sleep0(long nanos) throws InterruptedException;
 func Thread_sleep0(vm ir.VM) error {
 	nanos := vm.GetStack().GetVarInt64(0)
-	time.Sleep(time.Nanosecond * (time.Duration)(nanos))
-	return nil
+	return vm.(*jvm.VM).Sleep(time.Nanosecond * (time.Duration)(nanos))
 }
 
 // private native void start0();
@@ -109,11 +108,8 @@ func Thread_start0(vm ir.VM) error {
 	go func() {
 		println("*** thread", this, "started on", sub)
 		defer println("*** thread", this, "finished on", sub)
-		for sub.Running() {
-			err := sub.Step()
-			if err != nil {
-				panic(err)
-			}
+		if err := sub.Run(); err != nil {
+			panic(err)
 		}
 	}()
 	return nil
@@ -0,0 +1,135 @@
+package java_net
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func init() {
+	native.RegisterDefaultNative("java/net/InetAddress.init()V", InetAddress_init)
+	native.RegisterDefaultNative("java/net/InetAddress.isIPv4Available()Z", InetAddress_isIPv4Available)
+	native.RegisterDefaultNative("java/net/InetAddress.isIPv6Supported()Z", InetAddress_isIPv6Supported)
+	native.RegisterDefaultNative("java/net/InetAddressImplFactory.isIPv6Supported()Z", InetAddress_isIPv6Supported)
+	native.RegisterDefaultNative("java/net/Inet4Address.init()V", Inet4Address_init)
+	native.RegisterDefaultNative("java/net/Inet6Address.init()V", Inet6Address_init)
+}
+
+func InetAddress_init(vm ir.VM) error {
+	return nil
+}
+
+func Inet4Address_init(vm ir.VM) error {
+	return nil
+}
+
+func Inet6Address_init(vm ir.VM) error {
+	return nil
+}
+
+// static native boolean isIPv4Available();
+func InetAddress_isIPv4Available(vm ir.VM) error {
+	vm.GetStack().PushInt32(1)
+	return nil
+}
+
+// static native boolean isIPv6Supported();
+func InetAddress_isIPv6Supported(vm ir.VM) error {
+	pushBool(vm.GetStack(), vnet.IPv6Available(vm.(*jvm.VM).Network()))
+	return nil
+}
+
+func pushBool(stack ir.Stack, v bool) {
+	if v {
+		stack.PushInt32(1)
+	} else {
+		stack.PushInt32(0)
+	}
+}
+
+func getRefField(vm ir.VM, ref ir.Ref, name string) ir.Ref {
+	field := ref.Class().GetFieldByName(name)
+	if field == nil {
+		return nil
+	}
+	return vm.PtrToRef(*(*unsafe.Pointer)(field.GetPointer(ref)))
+}
+
+// InetAddressOf returns the address of the InetAddress, which is read from its holder
+func InetAddressOf(vm ir.VM, ref ir.Ref) (netip.Addr, error) {
+	if ref == nil {
+		return netip.Addr{}, errs.NullPointerException
+	}
+	if holder6 := getRefField(vm, ref, "holder6"); holder6 != nil {
+		if ipaddress := getRefField(vm, holder6, "ipaddress"); ipaddress != nil {
+			if addr, ok := netip.AddrFromSlice(ipaddress.GetByteArr()); ok {
+				return addr, nil
+			}
+		}
+	}
+	holder := getRefField(vm, ref, "holder")
+	if holder == nil {
+		return netip.Addr{}, errs.NullPointerException
+	}
+	address := *(*int32)(holder.Class().GetFieldByName("address").GetPointer(holder))
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], (uint32)(address))
+	return netip.AddrFrom4(b), nil
+}
+
+// NewInetAddress creates an Inet4Address or Inet6Address with InetAddress.getByAddress,
+// the IPv4-mapped IPv6 addresses are converted to Inet4Address.
+// host is the name which the address is resolved from, or empty.
+func NewInetAddress(vm ir.VM, addr netip.Addr, host string) (ir.Ref, error) {
+	cls, err := vm.GetClassByName("java/net/InetAddress")
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.InitClass(cls); err != nil {
+		return nil, err
+	}
+	raw := addr.AsSlice()
+	arr := vm.NewArray(desc.DescByteArray, (int32)(len(raw)))
+	copy(arr.GetByteArr(), raw)
+	stack := vm.GetStack()
+	if host == "" {
+		stack.PushRef(nil)
+	} else {
+		stack.PushRef(vm.NewString(host))
+	}
+	stack.PushRef(arr)
+	vm.InvokeStatic(cls.GetMethodByNameAndType("getByAddress", "(Ljava/lang/String;[B)Ljava/net/InetAddress;"))
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	return stack.PopRef(), nil
+}
+
+// NewInetSocketAddress creates an InetSocketAddress of the address and the port
+func NewInetSocketAddress(vm ir.VM, addr netip.AddrPort) (ir.Ref, error) {
+	inetAddr, err := NewInetAddress(vm, addr.Addr(), "")
+	if err != nil {
+		return nil, err
+	}
+	cls, err := vm.GetClassByName("java/net/InetSocketAddress")
+	if err != nil {
+		return nil, err
+	}
+	ref := vm.New(cls)
+	stack := vm.GetStack()
+	stack.PushRef(ref)
+	stack.PushRef(inetAddr)
+	stack.PushInt32((int32)(addr.Port()))
+	vm.Invoke(cls.GetMethodByNameAndType("<init>", "(Ljava/net/InetAddress;I)V"))
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
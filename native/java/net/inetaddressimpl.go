@@ -0,0 +1,180 @@
+package java_net
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+func init() {
+	native.RegisterDefaultNative("java/net/Inet4AddressImpl.getLocalHostName()Ljava/lang/String;", InetAddressImpl_getLocalHostName)
+	native.RegisterDefaultNative("java/net/Inet4AddressImpl.lookupAllHostAddr(Ljava/lang/String;)[Ljava/net/InetAddress;", Inet4AddressImpl_lookupAllHostAddr)
+	native.RegisterDefaultNative("java/net/Inet4AddressImpl.getHostByAddr([B)Ljava/lang/String;", InetAddressImpl_getHostByAddr)
+	native.RegisterDefaultNative("java/net/Inet4AddressImpl.isReachable0([BI[BI)Z", Inet4AddressImpl_isReachable0)
+	native.RegisterDefaultNative("java/net/Inet6AddressImpl.getLocalHostName()Ljava/lang/String;", InetAddressImpl_getLocalHostName)
+	native.RegisterDefaultNative("java/net/Inet6AddressImpl.lookupAllHostAddr(Ljava/lang/String;I)[Ljava/net/InetAddress;", Inet6AddressImpl_lookupAllHostAddr)
+	native.RegisterDefaultNative("java/net/Inet6AddressImpl.getHostByAddr([B)Ljava/lang/String;", InetAddressImpl_getHostByAddr)
+	native.RegisterDefaultNative("java/net/Inet6AddressImpl.isReachable0([BII[BII)Z", Inet6AddressImpl_isReachable0)
+}
+
+// The characteristics of java.net.spi.InetAddressResolver.LookupPolicy
+const (
+	lookupIPv4      = 1 << 0
+	lookupIPv6      = 1 << 1
+	lookupIPv4First = 1 << 2
+	lookupIPv6First = 1 << 3
+)
+
+// lookupTimeout limits the name resolution, like the resolver of the system does
+const lookupTimeout = 30 * time.Second
+
+// public native String getLocalHostName() throws UnknownHostException;
+func InetAddressImpl_getLocalHostName(vm ir.VM) error {
+	name, err := vm.(*jvm.VM).Network().Hostname()
+	if err != nil || name == "" {
+		name = "localhost"
+	}
+	vm.GetStack().PushRef(vm.NewString(name))
+	return nil
+}
+
+// private native InetAddress[] lookupAllHostAddr(String hostname) throws UnknownHostException;
+func Inet4AddressImpl_lookupAllHostAddr(vm ir.VM) error {
+	stack := vm.GetStack()
+	return lookupAllHostAddr(vm, stack.GetVarRef(1), lookupIPv4)
+}
+
+// public native InetAddress[] lookupAllHostAddr(String hostname, int characteristics) throws UnknownHostException;
+func Inet6AddressImpl_lookupAllHostAddr(vm ir.VM) error {
+	stack := vm.GetStack()
+	return lookupAllHostAddr(vm, stack.GetVarRef(1), stack.GetVarInt32(2))
+}
+
+func lookupAllHostAddr(vm ir.VM, hostRef ir.Ref, characteristics int32) error {
+	if hostRef == nil {
+		return errs.NullPointerException
+	}
+	host := vm.GetString(hostRef)
+	network := "ip"
+	switch characteristics & (lookupIPv4 | lookupIPv6) {
+	case lookupIPv4:
+		network = "ip4"
+	case lookupIPv6:
+		network = "ip6"
+	}
+	jv := vm.(*jvm.VM)
+	jv.EnterSafeRegion()
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	addrs, err := jv.Network().LookupNetIP(ctx, network, host)
+	cancel()
+	jv.LeaveSafeRegion()
+	if err != nil || len(addrs) == 0 {
+		return errs.Throw("java/net/UnknownHostException", host+": Name or service not known")
+	}
+	for i, addr := range addrs {
+		addrs[i] = addr.Unmap()
+	}
+	addrs = slices.Compact(addrs)
+	switch {
+	case characteristics&lookupIPv4First != 0:
+		slices.SortStableFunc(addrs, func(a, b netip.Addr) int { return boolCmp(a.Is4(), b.Is4()) })
+	case characteristics&lookupIPv6First != 0:
+		slices.SortStableFunc(addrs, func(a, b netip.Addr) int { return boolCmp(a.Is6(), b.Is6()) })
+	}
+	cls, err := vm.GetClassByName("java/net/InetAddress")
+	if err != nil {
+		return err
+	}
+	arr := vm.NewObjectArray(cls, (int32)(len(addrs)))
+	refs := arr.GetRefArr()
+	for i, addr := range addrs {
+		ref, err := NewInetAddress(vm, addr, host)
+		if err != nil {
+			return err
+		}
+		refs[i] = vm.RefToPtr(ref)
+	}
+	stack := vm.GetStack()
+	stack.PushRef(arr)
+	return nil
+}
+
+// boolCmp orders true before false
+func boolCmp(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case a:
+		return -1
+	}
+	return 1
+}
+
+// public native String getHostByAddr(byte[] addr) throws UnknownHostException;
+func InetAddressImpl_getHostByAddr(vm ir.VM) error {
+	stack := vm.GetStack()
+	arr := stack.GetVarRef(1)
+	if arr == nil {
+		return errs.NullPointerException
+	}
+	addr, ok := netip.AddrFromSlice(arr.GetByteArr())
+	if !ok {
+		return errs.Throw("java/net/UnknownHostException", "")
+	}
+	jv := vm.(*jvm.VM)
+	jv.EnterSafeRegion()
+	ctx, cancel := context.WithTimeout(context.Background(), lookupTimeout)
+	names, err := jv.Network().LookupAddr(ctx, addr)
+	cancel()
+	jv.LeaveSafeRegion()
+	if err != nil || len(names) == 0 {
+		return errs.Throw("java/net/UnknownHostException", addr.String())
+	}
+	stack.PushRef(vm.NewString(strings.TrimSuffix(names[0], ".")))
+	return nil
+}
+
+// private native boolean isReachable0(byte[] addr, int timeout, byte[] ifaddr, int ttl) throws IOException;
+func Inet4AddressImpl_isReachable0(vm ir.VM) error {
+	stack := vm.GetStack()
+	return isReachable(vm, stack.GetVarRef(1), stack.GetVarInt32(2))
+}
+
+// private native boolean isReachable0(byte[] addr, int scope, int timeout, byte[] inf, int ttl, int if_scope) throws IOException;
+func Inet6AddressImpl_isReachable0(vm ir.VM) error {
+	stack := vm.GetStack()
+	return isReachable(vm, stack.GetVarRef(1), stack.GetVarInt32(3))
+}
+
+// isReachable connects to the echo port, since ICMP is not available to the network.
+// The host is reachable if the connection is established or refused, which is the fallback of the JDK without raw sockets.
+func isReachable(vm ir.VM, arr ir.Ref, timeout int32) error {
+	if arr == nil {
+		return errs.NullPointerException
+	}
+	addr, ok := netip.AddrFromSlice(arr.GetByteArr())
+	if !ok {
+		pushBool(vm.GetStack(), false)
+		return nil
+	}
+	jv := vm.(*jvm.VM)
+	jv.EnterSafeRegion()
+	ctx, cancel := context.WithTimeout(context.Background(), (time.Duration)(timeout)*time.Millisecond)
+	conn, err := jv.Network().DialTCP(ctx, netip.AddrPort{}, netip.AddrPortFrom(addr.Unmap(), 7))
+	cancel()
+	jv.LeaveSafeRegion()
+	if err == nil {
+		conn.Close()
+	}
+	pushBool(vm.GetStack(), err == nil || errors.Is(err, syscall.ECONNREFUSED))
+	return nil
+}
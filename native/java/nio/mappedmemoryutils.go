@@ -0,0 +1,41 @@
+package java_nio
+
+import (
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	sun_nio_ch "github.com/LiterMC/wasm-jdk/native/sun/nio/ch"
+)
+
+func init() {
+	native.RegisterDefaultNative("java/nio/MappedMemoryUtils.isLoaded0(JJJ)Z", MappedMemoryUtils_isLoaded0)
+	native.RegisterDefaultNative("java/nio/MappedMemoryUtils.load0(JJ)V", MappedMemoryUtils_load0)
+	native.RegisterDefaultNative("java/nio/MappedMemoryUtils.unload0(JJ)V", MappedMemoryUtils_unload0)
+	native.RegisterDefaultNative("java/nio/MappedMemoryUtils.force0(Ljava/io/FileDescriptor;JJ)V", MappedMemoryUtils_force0)
+}
+
+// private static native boolean isLoaded0(long address, long length, long pageCount);
+func MappedMemoryUtils_isLoaded0(vm ir.VM) error {
+	// the mappings are read into the memory when they are mapped
+	vm.GetStack().PushInt32(1)
+	return nil
+}
+
+// private static native void load0(long address, long length);
+func MappedMemoryUtils_load0(vm ir.VM) error {
+	return nil
+}
+
+// private static native void unload0(long address, long length);
+func MappedMemoryUtils_unload0(vm ir.VM) error {
+	return nil
+}
+
+// private static native void force0(FileDescriptor fd, long address, long length) throws IOException;
+func MappedMemoryUtils_force0(vm ir.VM) error {
+	stack := vm.GetStack()
+	if err := sun_nio_ch.ForceMapping(stack.GetVarInt64(1), stack.GetVarInt64(3)); err != nil {
+		return errs.Throwf("java/io/IOException", "msync failed: %v", err)
+	}
+	return nil
+}
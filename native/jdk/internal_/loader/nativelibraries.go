@@ -20,7 +20,7 @@ func NativeLibraries_load(vm ir.VM) error {
 	throwExceptionIfFail := stack.GetVar(3) != 0
 	_ = isBuiltin
 	if false && throwExceptionIfFail {
-		return &errs.UnsatisfiedLinkError{name}
+		return &errs.UnsatisfiedLinkError{Name: name}
 	}
 	stack.Push(0)
 	return nil
@@ -582,11 +582,8 @@ func Unsafe_copySwapMemory0(vm ir.VM) error {
 // private native long objectFieldOffset0(Field f);
 func Unsafe_objectFieldOffset0(vm ir.VM) error {
 	stack := vm.GetStack()
-	field := stack.GetVarRef(1)
-	if true {
-		_ = field
-		panic("TODO: Unsafe.objectFieldOffset0")
-	}
+	field := jvm.ReflectionTarget(stack.GetVarRef(1)).(*jvm.Field)
+	stack.PushInt64(field.Offset())
 	return nil
 }
 
@@ -603,22 +600,16 @@ func Unsafe_objectFieldOffset1(vm ir.VM) error {
 // private native long staticFieldOffset0(Field f);
 func Unsafe_staticFieldOffset0(vm ir.VM) error {
 	stack := vm.GetStack()
-	field := stack.GetVarRef(1)
-	if true {
-		_ = field
-		panic("TODO: Unsafe.staticFieldOffset0")
-	}
+	field := jvm.ReflectionTarget(stack.GetVarRef(1)).(*jvm.Field)
+	stack.PushInt64(field.Offset())
 	return nil
 }
 
 // private native Object staticFieldBase0(Field f);
 func Unsafe_staticFieldBase0(vm ir.VM) error {
 	stack := vm.GetStack()
-	field := stack.GetVarRef(1)
-	if true {
-		_ = field
-		panic("TODO: Unsafe.staticFieldBase0")
-	}
+	field := jvm.ReflectionTarget(stack.GetVarRef(1)).(*jvm.Field)
+	stack.PushRef(field.GetDeclaringClass().(*jvm.Class).StaticBase())
 	return nil
 }
 
@@ -638,8 +629,7 @@ func Unsafe_shouldBeInitialized0(vm ir.VM) error {
 func Unsafe_ensureClassInitialized0(vm ir.VM) error {
 	stack := vm.GetStack()
 	class := (*stack.GetVarRef(1).UserData()).(*jvm.Class)
-	class.InitBeforeUse(vm.(*jvm.VM))
-	return nil
+	return vm.InitClass(class)
 }
 
 // private native int arrayBaseOffset0(Class<?> arrayClass);
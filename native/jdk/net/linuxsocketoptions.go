@@ -0,0 +1,19 @@
+package jdk_net
+
+import (
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+)
+
+func init() {
+	// the extended socket options, e.g. TCP_KEEPIDLE, are not supported by the transport,
+	// so jdk.net.ExtendedSocketOptions only reports the standard ones
+	native.RegisterDefaultNative("jdk/net/LinuxSocketOptions.keepAliveOptionsSupported0()Z", LinuxSocketOptions_unsupported)
+	native.RegisterDefaultNative("jdk/net/LinuxSocketOptions.quickAckSupported0()Z", LinuxSocketOptions_unsupported)
+	native.RegisterDefaultNative("jdk/net/LinuxSocketOptions.incomingNapiIdSupported0()Z", LinuxSocketOptions_unsupported)
+}
+
+func LinuxSocketOptions_unsupported(vm ir.VM) error {
+	vm.GetStack().PushInt32(0)
+	return nil
+}
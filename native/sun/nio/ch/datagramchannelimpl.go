@@ -0,0 +1,80 @@
+package sun_nio_ch
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/DatagramChannelImpl.disconnect0(Ljava/io/FileDescriptor;Z)V", DatagramChannelImpl_disconnect0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramChannelImpl.receive0(Ljava/io/FileDescriptor;JIJZ)I", DatagramChannelImpl_receive0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramChannelImpl.send0(Ljava/io/FileDescriptor;JIJI)I", DatagramChannelImpl_send0)
+}
+
+// private static native void disconnect0(FileDescriptor fd, boolean isIPv6) throws IOException;
+func DatagramChannelImpl_disconnect0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	if err := s.Disconnect(); err != nil {
+		return socketException(err)
+	}
+	return nil
+}
+
+// private static native int receive0(FileDescriptor fd, long address, int len, long senderAddress, boolean connected) throws IOException;
+func DatagramChannelImpl_receive0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	senderAddress := stack.GetVarInt64(4)
+	n, err := blocking(vm, func() (int, error) {
+		n, from, err := s.ReadFrom(buf)
+		if err == nil {
+			putSockAddr(senderAddress, from)
+		}
+		return n, err
+	})
+	if errors.Is(err, syscall.EAGAIN) {
+		stack.PushInt32(IOS_UNAVAILABLE)
+		return nil
+	}
+	if err != nil {
+		return datagramException(err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
+
+// private static native int send0(FileDescriptor fd, long address, int len, long targetAddress, int targetAddressLen) throws IOException;
+func DatagramChannelImpl_send0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	target, err := getSockAddr(stack.GetVarInt64(4), stack.GetVarInt32(6))
+	if err != nil {
+		return socketException(err)
+	}
+	n, err := blocking(vm, func() (int, error) { return s.WriteTo(buf, target) })
+	if errors.Is(err, syscall.EAGAIN) {
+		stack.PushInt32(IOS_UNAVAILABLE)
+		return nil
+	}
+	if err != nil {
+		return datagramException(err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
@@ -0,0 +1,148 @@
+package sun_nio_ch
+
+import (
+	"encoding/binary"
+	"errors"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.eventSize()I", EPoll_eventSize)
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.eventsOffset()I", EPoll_eventsOffset)
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.dataOffset()I", EPoll_dataOffset)
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.create()I", EPoll_create)
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.ctl(IIII)I", EPoll_ctl)
+	native.RegisterDefaultNative("sun/nio/ch/EPoll.wait(IJII)I", EPoll_wait)
+}
+
+// The layout of struct epoll_event, which is packed on x86_64
+const (
+	epollEventSize    = 12
+	epollEventsOffset = 0
+	epollDataOffset   = 4
+)
+
+// The opcodes of epoll_ctl(2)
+const (
+	EPOLL_CTL_ADD = 1
+	EPOLL_CTL_DEL = 2
+	EPOLL_CTL_MOD = 3
+)
+
+// The errno values of Linux which EPoll.ctl returns
+const (
+	linuxEPERM  = 1
+	linuxENOENT = 2
+	linuxEBADF  = 9
+	linuxEEXIST = 17
+	linuxEINVAL = 22
+)
+
+func EPoll_eventSize(vm ir.VM) error {
+	vm.GetStack().PushInt32(epollEventSize)
+	return nil
+}
+
+func EPoll_eventsOffset(vm ir.VM) error {
+	vm.GetStack().PushInt32(epollEventsOffset)
+	return nil
+}
+
+func EPoll_dataOffset(vm ir.VM) error {
+	vm.GetStack().PushInt32(epollDataOffset)
+	return nil
+}
+
+// static native int create() throws IOException;
+func EPoll_create(vm ir.VM) error {
+	vm.GetStack().PushInt32(vm.(*jvm.VM).Files().Add(vnet.NewPoller()))
+	return nil
+}
+
+func getPoller(vm ir.VM, epfd int32) (*vnet.Poller, error) {
+	f, err := vm.(*jvm.VM).Files().Get(epfd)
+	if err != nil {
+		return nil, err
+	}
+	p, ok := f.(*vnet.Poller)
+	if !ok {
+		return nil, syscall.EINVAL
+	}
+	return p, nil
+}
+
+// static native int ctl(int epfd, int opcode, int fd, int events);
+func EPoll_ctl(vm ir.VM) error {
+	stack := vm.GetStack()
+	stack.PushInt32(epollCtl(vm, stack.GetVarInt32(0), stack.GetVarInt32(1), stack.GetVarInt32(2), (uint32)(stack.GetVarInt32(3))))
+	return nil
+}
+
+// epollCtl returns the errno of Linux, or 0 on success
+func epollCtl(vm ir.VM, epfd, opcode, fd int32, events uint32) int32 {
+	p, err := getPoller(vm, epfd)
+	if err != nil {
+		if err == syscall.EINVAL {
+			return linuxEINVAL
+		}
+		return linuxEBADF
+	}
+	switch opcode {
+	case EPOLL_CTL_ADD:
+		f, err := vm.(*jvm.VM).Files().Get(fd)
+		if err != nil {
+			return linuxEBADF
+		}
+		pf, ok := f.(vnet.Pollable)
+		if !ok {
+			// the regular files are not supported by epoll
+			return linuxEPERM
+		}
+		err = p.Add(fd, pf, events)
+		if errors.Is(err, syscall.EEXIST) {
+			return linuxEEXIST
+		}
+		if err != nil {
+			return linuxEBADF
+		}
+	case EPOLL_CTL_MOD:
+		if err := p.Modify(fd, events); err != nil {
+			return linuxENOENT
+		}
+	case EPOLL_CTL_DEL:
+		if err := p.Delete(fd); err != nil {
+			return linuxENOENT
+		}
+	default:
+		return linuxEINVAL
+	}
+	return 0
+}
+
+// static native int wait(int epfd, long pollAddress, int numfds, int timeout) throws IOException;
+func EPoll_wait(vm ir.VM) error {
+	stack := vm.GetStack()
+	p, err := getPoller(vm, stack.GetVarInt32(0))
+	if err != nil {
+		return helper.IOException(err)
+	}
+	address := stack.GetVarInt64(1)
+	events := make([]vnet.PollEvent, max(stack.GetVarInt32(3), 0))
+	timeout := timeoutOf((int64)(stack.GetVarInt32(4)))
+	n, _ := blocking(vm, func() (int, error) { return p.Wait(events, timeout), nil })
+	mem := cutil.Bytes(address, (int)(n)*epollEventSize)
+	for i, e := range events[:n] {
+		b := mem[i*epollEventSize:]
+		binary.NativeEndian.PutUint32(b[epollEventsOffset:], e.Events)
+		binary.NativeEndian.PutUint64(b[epollDataOffset:], (uint64)(e.FD))
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
@@ -0,0 +1,143 @@
+package sun_nio_ch
+
+import (
+	"encoding/binary"
+	"sync"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/EventFD.eventfd0()I", EventFD_eventfd0)
+	native.RegisterDefaultNative("sun/nio/ch/EventFD.set0(I)I", EventFD_set0)
+}
+
+// eventFD emulates eventfd(2), which wakes up the selectors.
+// It is readable while the counter is not zero, and a read takes the counter.
+type eventFD struct {
+	vnet.Notifier
+
+	mux      sync.Mutex
+	cond     sync.Cond
+	count    uint64
+	nonblock bool
+	closed   bool
+}
+
+var (
+	_ nonblocker    = (*eventFD)(nil)
+	_ drainable     = (*eventFD)(nil)
+	_ vnet.Pollable = (*eventFD)(nil)
+)
+
+func newEventFD() *eventFD {
+	e := new(eventFD)
+	e.cond.L = &e.mux
+	return e
+}
+
+func (e *eventFD) SetNonblock(nonblock bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.nonblock = nonblock
+}
+
+// Len returns the number of bytes which can be read
+func (e *eventFD) Len() int {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.count == 0 {
+		return 0
+	}
+	return 8
+}
+
+func (e *eventFD) Read(b []byte) (int, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if len(b) < 8 {
+		return 0, syscall.EINVAL
+	}
+	for e.count == 0 {
+		if e.closed {
+			return 0, syscall.EBADF
+		}
+		if e.nonblock {
+			return 0, syscall.EAGAIN
+		}
+		e.cond.Wait()
+	}
+	binary.NativeEndian.PutUint64(b, e.count)
+	e.count = 0
+	e.Notify()
+	return 8, nil
+}
+
+// Write adds the 8-byte integer to the counter, it never blocks
+func (e *eventFD) Write(b []byte) (int, error) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if len(b) < 8 {
+		return 0, syscall.EINVAL
+	}
+	if e.closed {
+		return 0, syscall.EBADF
+	}
+	e.count += binary.NativeEndian.Uint64(b)
+	e.cond.Broadcast()
+	e.Notify()
+	return 8, nil
+}
+
+func (e *eventFD) Poll() uint32 {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	if e.closed {
+		return vnet.POLLNVAL
+	}
+	if e.count != 0 {
+		return vnet.POLLIN | vnet.POLLOUT
+	}
+	return vnet.POLLOUT
+}
+
+func (e *eventFD) Close() error {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+	e.closed = true
+	e.cond.Broadcast()
+	e.Notify()
+	return nil
+}
+
+// private static native int eventfd0() throws IOException;
+func EventFD_eventfd0(vm ir.VM) error {
+	vm.GetStack().PushInt32(vm.(*jvm.VM).Files().Add(newEventFD()))
+	return nil
+}
+
+// private static native int set0(int efd) throws IOException;
+func EventFD_set0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return helper.IOException(err)
+	}
+	e, ok := f.(*eventFD)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	var one [8]byte
+	binary.NativeEndian.PutUint64(one[:], 1)
+	n, err := e.Write(one[:])
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
@@ -0,0 +1,65 @@
+package sun_nio_ch
+
+import (
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+func init() {
+	// the class libraries which do not declare the mapping and transfer natives on the dispatcher declare them here
+	native.RegisterDefaultNative("sun/nio/ch/FileChannelImpl.initIDs()J", FileChannelImpl_initIDs)
+	native.RegisterDefaultNative("sun/nio/ch/FileChannelImpl.map0(IJJZ)J", FileChannelImpl_map0)
+	native.RegisterDefaultNative("sun/nio/ch/FileChannelImpl.unmap0(JJ)I", FileChannelImpl_unmap0)
+	native.RegisterDefaultNative("sun/nio/ch/FileChannelImpl.transferTo0(Ljava/io/FileDescriptor;JJLjava/io/FileDescriptor;)J", FileChannelImpl_transferTo0)
+	native.RegisterDefaultNative("sun/nio/ch/FileChannelImpl.maxDirectTransferSize0()I", FileChannelImpl_maxDirectTransferSize0)
+}
+
+// private static native long initIDs();
+func FileChannelImpl_initIDs(vm ir.VM) error {
+	// it returns the allocation granularity
+	vm.GetStack().PushInt64(allocationGranularity)
+	return nil
+}
+
+// private native long map0(int prot, long position, long length, boolean isSync) throws IOException;
+func FileChannelImpl_map0(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	fdObj := vm.PtrToRef(*(*unsafe.Pointer)(this.Class().GetFieldByName("fd").GetPointer(this)))
+	if fdObj == nil {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	address, err := mapFile(vm, helper.GetFD(fdObj), stack.GetVarInt32(1), stack.GetVarInt64(2), stack.GetVarInt64(4))
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(address)
+	return nil
+}
+
+// private static native int unmap0(long address, long length);
+func FileChannelImpl_unmap0(vm ir.VM) error {
+	stack := vm.GetStack()
+	if err := unmapFile(vm, stack.GetVarInt64(0)); err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt32(0)
+	return nil
+}
+
+// private native long transferTo0(FileDescriptor src, long position, long count, FileDescriptor dst);
+func FileChannelImpl_transferTo0(vm ir.VM) error {
+	vm.GetStack().PushInt64(IOS_UNSUPPORTED)
+	return nil
+}
+
+// private static native int maxDirectTransferSize0();
+func FileChannelImpl_maxDirectTransferSize0(vm ir.VM) error {
+	// the limit of sendfile(2) on Linux
+	vm.GetStack().PushInt32(0x7ffff000)
+	return nil
+}
@@ -0,0 +1,382 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"io/fs"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+func init() {
+	// the natives are declared by UnixFileDispatcherImpl on the class libraries which split it from FileDispatcherImpl
+	for _, cls := range []string{"sun/nio/ch/FileDispatcherImpl", "sun/nio/ch/UnixFileDispatcherImpl"} {
+		native.RegisterDefaultNative(cls+".init()V", FileDispatcherImpl_init)
+		native.RegisterDefaultNative(cls+".read0(Ljava/io/FileDescriptor;JI)I", FileDispatcherImpl_read0)
+		native.RegisterDefaultNative(cls+".pread0(Ljava/io/FileDescriptor;JIJ)I", FileDispatcherImpl_pread0)
+		native.RegisterDefaultNative(cls+".readv0(Ljava/io/FileDescriptor;JI)J", FileDispatcherImpl_readv0)
+		native.RegisterDefaultNative(cls+".write0(Ljava/io/FileDescriptor;JI)I", FileDispatcherImpl_write0)
+		native.RegisterDefaultNative(cls+".pwrite0(Ljava/io/FileDescriptor;JIJ)I", FileDispatcherImpl_pwrite0)
+		native.RegisterDefaultNative(cls+".writev0(Ljava/io/FileDescriptor;JI)J", FileDispatcherImpl_writev0)
+		native.RegisterDefaultNative(cls+".seek0(Ljava/io/FileDescriptor;J)J", FileDispatcherImpl_seek0)
+		native.RegisterDefaultNative(cls+".force0(Ljava/io/FileDescriptor;Z)I", FileDispatcherImpl_force0)
+		native.RegisterDefaultNative(cls+".truncate0(Ljava/io/FileDescriptor;J)I", FileDispatcherImpl_truncate0)
+		native.RegisterDefaultNative(cls+".size0(Ljava/io/FileDescriptor;)J", FileDispatcherImpl_size0)
+		native.RegisterDefaultNative(cls+".lock0(Ljava/io/FileDescriptor;ZJJZ)I", FileDispatcherImpl_lock0)
+		native.RegisterDefaultNative(cls+".release0(Ljava/io/FileDescriptor;JJ)V", FileDispatcherImpl_release0)
+		native.RegisterDefaultNative(cls+".closeIntFD(I)V", FileDispatcherImpl_closeIntFD)
+		native.RegisterDefaultNative(cls+".allocationGranularity0()J", FileDispatcherImpl_allocationGranularity0)
+		native.RegisterDefaultNative(cls+".map0(Ljava/io/FileDescriptor;IJJZ)J", FileDispatcherImpl_map0)
+		native.RegisterDefaultNative(cls+".unmap0(JJ)I", FileChannelImpl_unmap0)
+		native.RegisterDefaultNative(cls+".maxDirectTransferSize0()I", FileChannelImpl_maxDirectTransferSize0)
+		native.RegisterDefaultNative(cls+".transferTo0(Ljava/io/FileDescriptor;JJLjava/io/FileDescriptor;Z)J", FileDispatcherImpl_transferTo0)
+		native.RegisterDefaultNative(cls+".transferFrom0(Ljava/io/FileDescriptor;Ljava/io/FileDescriptor;JJZ)J", FileDispatcherImpl_transferFrom0)
+	}
+}
+
+// The return values of FileDispatcher.lock0
+const (
+	FD_NO_LOCK     = -1
+	FD_LOCKED      = 0
+	FD_RET_EX_LOCK = 1
+	FD_INTERRUPTED = 2
+)
+
+func FileDispatcherImpl_init(vm ir.VM) error {
+	return nil
+}
+
+// static native int read0(FileDescriptor fd, long address, int len) throws IOException;
+func FileDispatcherImpl_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	r, ok := f.(io.Reader)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	n, err := blocking(vm, func() (int, error) { return r.Read(buf) })
+	res, err := convertReturn(n, err, true)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// static native int pread0(FileDescriptor fd, long address, int len, long position) throws IOException;
+func FileDispatcherImpl_pread0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		return helper.IOException(syscall.ESPIPE)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	pos := stack.GetVarInt64(4)
+	n, err := blocking(vm, func() (int, error) { return r.ReadAt(buf, pos) })
+	res, err := convertReturn(n, err, true)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// static native long readv0(FileDescriptor fd, long address, int len) throws IOException;
+func FileDispatcherImpl_readv0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	r, ok := f.(io.Reader)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	vecs := iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3))
+	total, err := blocking(vm, func() (int, error) {
+		total := 0
+		for _, v := range vecs {
+			buf := cutil.Bytes((int64)(v.base), (int)(v.len))
+			n, err := r.Read(buf)
+			total += n
+			// stop at a short read like readv(2), the rest would block or be at the end
+			if err != nil || n < len(buf) {
+				if total > 0 {
+					err = nil
+				}
+				return total, err
+			}
+		}
+		return total, nil
+	})
+	res, err := convertReturn(total, err, true)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+// static native int write0(FileDescriptor fd, long address, int len) throws IOException;
+func FileDispatcherImpl_write0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	n, err := blocking(vm, func() (int, error) { return w.Write(buf) })
+	res, err := convertReturn(n, err, false)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// static native int pwrite0(FileDescriptor fd, long address, int len, long position) throws IOException;
+func FileDispatcherImpl_pwrite0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.WriterAt)
+	if !ok {
+		return helper.IOException(syscall.ESPIPE)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	pos := stack.GetVarInt64(4)
+	n, err := blocking(vm, func() (int, error) { return w.WriteAt(buf, pos) })
+	res, err := convertReturn(n, err, false)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// static native long writev0(FileDescriptor fd, long address, int len) throws IOException;
+func FileDispatcherImpl_writev0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	vecs := iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3))
+	total, err := blocking(vm, func() (int, error) {
+		total := 0
+		for _, v := range vecs {
+			n, err := w.Write(cutil.Bytes((int64)(v.base), (int)(v.len)))
+			total += n
+			if err != nil {
+				if total > 0 {
+					err = nil
+				}
+				return total, err
+			}
+		}
+		return total, nil
+	})
+	res, err := convertReturn(total, err, false)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+// static native long seek0(FileDescriptor fd, long offset) throws IOException;
+func FileDispatcherImpl_seek0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	s, ok := f.(io.Seeker)
+	if !ok {
+		return helper.IOException(syscall.ESPIPE)
+	}
+	// a negative offset queries the current position
+	offset := stack.GetVarInt64(1)
+	var pos int64
+	if offset < 0 {
+		pos, err = s.Seek(0, io.SeekCurrent)
+	} else {
+		pos, err = s.Seek(offset, io.SeekStart)
+	}
+	if err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt64(pos)
+	return nil
+}
+
+// static native int force0(FileDescriptor fd, boolean metaData) throws IOException;
+func FileDispatcherImpl_force0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	if s, ok := f.(interface{ Sync() error }); ok {
+		if _, err := blocking(vm, func() (int, error) { return 0, s.Sync() }); err != nil {
+			return helper.IOException(err)
+		}
+	}
+	stack.PushInt32(0)
+	return nil
+}
+
+// static native int truncate0(FileDescriptor fd, long size) throws IOException;
+func FileDispatcherImpl_truncate0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	t, ok := f.(interface{ Truncate(int64) error })
+	if !ok {
+		return helper.IOException(syscall.EINVAL)
+	}
+	if err := t.Truncate(stack.GetVarInt64(1)); err != nil {
+		return helper.IOException(err)
+	}
+	stack.PushInt32(0)
+	return nil
+}
+
+// static native long size0(FileDescriptor fd) throws IOException;
+func FileDispatcherImpl_size0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	var size int64
+	// the standard streams and pipes are empty like the character devices
+	if st, ok := f.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		stat, err := st.Stat()
+		if err != nil {
+			return helper.IOException(err)
+		}
+		size = stat.Size()
+	}
+	stack.PushInt64(size)
+	return nil
+}
+
+// static native int lock0(FileDescriptor fd, boolean blocking, long pos, long size, boolean shared) throws IOException;
+func FileDispatcherImpl_lock0(vm ir.VM) error {
+	stack := vm.GetStack()
+	fdObj := stack.GetVarRef(0)
+	wait := stack.GetVarInt32(1) != 0
+	pos := stack.GetVarInt64(2)
+	size := stack.GetVarInt64(4)
+	shared := stack.GetVarInt32(6) != 0
+	f, err := fdFile(vm, fdObj)
+	if err != nil {
+		return err
+	}
+	key, err := lockKeyOf(f)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	l := &fileLock{
+		owner:  vm.(*jvm.VM).Files(),
+		pos:    pos,
+		size:   size,
+		shared: shared,
+	}
+	var locked bool
+	if wait {
+		vm.(*jvm.VM).EnterSafeRegion()
+		locked = fileLocks.lock(key, l, true)
+		vm.(*jvm.VM).LeaveSafeRegion()
+	} else {
+		locked = fileLocks.lock(key, l, false)
+	}
+	if locked {
+		stack.PushInt32(FD_LOCKED)
+	} else {
+		stack.PushInt32(FD_NO_LOCK)
+	}
+	return nil
+}
+
+// static native void release0(FileDescriptor fd, long pos, long size) throws IOException;
+func FileDispatcherImpl_release0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	key, err := lockKeyOf(f)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	fileLocks.release(key, vm.(*jvm.VM).Files(), stack.GetVarInt64(1), stack.GetVarInt64(3))
+	return nil
+}
+
+// static native void closeIntFD(int fd) throws IOException;
+func FileDispatcherImpl_closeIntFD(vm ir.VM) error {
+	if err := vm.(*jvm.VM).Files().Close(vm.GetStack().GetVarInt32(0)); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
+
+// static native long allocationGranularity0();
+func FileDispatcherImpl_allocationGranularity0(vm ir.VM) error {
+	vm.GetStack().PushInt64(allocationGranularity)
+	return nil
+}
+
+// static native long map0(FileDescriptor fd, int prot, long position, long length, boolean isSync) throws IOException;
+func FileDispatcherImpl_map0(vm ir.VM) error {
+	stack := vm.GetStack()
+	fdObj := stack.GetVarRef(0)
+	if fdObj == nil {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	address, err := mapFile(vm, helper.GetFD(fdObj), stack.GetVarInt32(1), stack.GetVarInt64(2), stack.GetVarInt64(4))
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(address)
+	return nil
+}
+
+// static native long transferTo0(FileDescriptor src, long position, long count, FileDescriptor dst, boolean append);
+func FileDispatcherImpl_transferTo0(vm ir.VM) error {
+	// FileChannelImpl falls back to copying through buffers
+	vm.GetStack().PushInt64(IOS_UNSUPPORTED)
+	return nil
+}
+
+// static native long transferFrom0(FileDescriptor src, FileDescriptor dst, long position, long count, boolean append);
+func FileDispatcherImpl_transferFrom0(vm ir.VM) error {
+	vm.GetStack().PushInt64(IOS_UNSUPPORTED)
+	return nil
+}
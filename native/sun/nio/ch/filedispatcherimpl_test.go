@@ -0,0 +1,329 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vm/vmtest"
+)
+
+// openFD opens the file of the filesystem in the VM, and returns its FileDescriptor
+func openFD(t *testing.T, vm *jvm.VM, name string) ir.Ref {
+	t.Helper()
+	f, err := vm.FS().OpenFile(name, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return vmtest.NewFD(vm, f)
+}
+
+func writeFile(t *testing.T, fsys vfs.FS, name string, data string) {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func readFile(t *testing.T, fsys vfs.FS, name string) string {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// call calls the native and pops its int result
+func call(t *testing.T, vm *jvm.VM, fn func(ir.VM) error, args ...any) int32 {
+	t.Helper()
+	if err := vmtest.Call(vm, fn, args...); err != nil {
+		t.Fatal(err)
+	}
+	return vm.GetStack().PopInt32()
+}
+
+// call64 calls the native and pops its long result
+func call64(t *testing.T, vm *jvm.VM, fn func(ir.VM) error, args ...any) int64 {
+	t.Helper()
+	if err := vmtest.Call(vm, fn, args...); err != nil {
+		t.Fatal(err)
+	}
+	return vm.GetStack().PopInt64()
+}
+
+func TestPreadPwrite(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	vm := vmtest.NewVM(jvm.Options{FS: fsys})
+	writeFile(t, fsys, "/data", "0123456789")
+	fd := openFD(t, vm, "/data")
+
+	address := cutil.AllocMemory(16)
+	defer cutil.FreeMemory(address)
+	buf := cutil.Bytes((int64)(address), 16)
+
+	copy(buf, "abc")
+	if n := call(t, vm, FileDispatcherImpl_pwrite0, fd, (int64)(address), (int32)(3), (int64)(4)); n != 3 {
+		t.Errorf("pwrite0 returned %d, want 3", n)
+	}
+	if got := readFile(t, fsys, "/data"); got != "0123abc789" {
+		t.Errorf("file is %q after pwrite0, want %q", got, "0123abc789")
+	}
+	// writing beyond the end extends the file
+	copy(buf, "xy")
+	if n := call(t, vm, FileDispatcherImpl_pwrite0, fd, (int64)(address), (int32)(2), (int64)(12)); n != 2 {
+		t.Errorf("pwrite0 returned %d, want 2", n)
+	}
+	if got := readFile(t, fsys, "/data"); got != "0123abc789\x00\x00xy" {
+		t.Errorf("file is %q after pwrite0 beyond the end, want %q", got, "0123abc789\x00\x00xy")
+	}
+
+	clear(buf)
+	if n := call(t, vm, FileDispatcherImpl_pread0, fd, (int64)(address), (int32)(16), (int64)(2)); n != 12 {
+		t.Errorf("pread0 returned %d, want 12", n)
+	}
+	if got := string(buf[:12]); got != "23abc789\x00\x00xy" {
+		t.Errorf("pread0 read %q, want %q", got, "23abc789\x00\x00xy")
+	}
+	if n := call(t, vm, FileDispatcherImpl_pread0, fd, (int64)(address), (int32)(16), (int64)(14)); n != IOS_EOF {
+		t.Errorf("pread0 at the end returned %d, want IOS_EOF", n)
+	}
+
+	// the positional operations do not move the file position
+	if pos := call64(t, vm, FileDispatcherImpl_seek0, fd, (int64)(-1)); pos != 0 {
+		t.Errorf("position is %d, want 0", pos)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	vm := vmtest.NewVM(jvm.Options{FS: fsys})
+	writeFile(t, fsys, "/data", "0123456789")
+	fd := openFD(t, vm, "/data")
+
+	if r := call(t, vm, FileDispatcherImpl_truncate0, fd, (int64)(4)); r != 0 {
+		t.Errorf("truncate0 returned %d, want 0", r)
+	}
+	if size := call64(t, vm, FileDispatcherImpl_size0, fd); size != 4 {
+		t.Errorf("size0 returned %d, want 4", size)
+	}
+	if got := readFile(t, fsys, "/data"); got != "0123" {
+		t.Errorf("file is %q after truncate0, want %q", got, "0123")
+	}
+	if r := call(t, vm, FileDispatcherImpl_truncate0, fd, (int64)(6)); r != 0 {
+		t.Errorf("truncate0 returned %d, want 0", r)
+	}
+	if got := readFile(t, fsys, "/data"); got != "0123\x00\x00" {
+		t.Errorf("file is %q after extending by truncate0, want %q", got, "0123\x00\x00")
+	}
+}
+
+func TestMapForce(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	vm := vmtest.NewVM(jvm.Options{FS: fsys})
+	writeFile(t, fsys, "/data", "hello world")
+	fd := openFD(t, vm, "/data")
+
+	address := call64(t, vm, FileDispatcherImpl_map0, fd, (int32)(MAP_RW), (int64)(0), (int64)(16), false)
+	mem := cutil.Bytes(address, 16)
+	if got := string(mem); got != "hello world\x00\x00\x00\x00\x00" {
+		t.Fatalf("mapped memory is %q, want the file followed by zeros", got)
+	}
+
+	copy(mem[6:], "WORLD!!!")
+	if got := readFile(t, fsys, "/data"); got != "hello world" {
+		t.Errorf("file is %q before force, want %q", got, "hello world")
+	}
+	if err := ForceMapping(address, 16); err != nil {
+		t.Fatal(err)
+	}
+	// the bytes beyond the end of the file are discarded
+	if got := readFile(t, fsys, "/data"); got != "hello WORLD" {
+		t.Errorf("file is %q after force, want %q", got, "hello WORLD")
+	}
+
+	// the mapping is readable through the file, and stays valid after the descriptor is closed
+	address2 := cutil.AllocMemory(5)
+	defer cutil.FreeMemory(address2)
+	if n := call(t, vm, FileDispatcherImpl_pread0, fd, (int64)(address2), (int32)(5), (int64)(6)); n != 5 {
+		t.Errorf("pread0 returned %d, want 5", n)
+	}
+	if got := string(cutil.Bytes((int64)(address2), 5)); got != "WORLD" {
+		t.Errorf("pread0 read %q, want %q", got, "WORLD")
+	}
+	if err := vmtest.Call(vm, FileDispatcherImpl_closeIntFD, helper.GetFD(fd)); err != nil {
+		t.Fatal(err)
+	}
+	copy(mem, "HELLO")
+	if r := call(t, vm, FileChannelImpl_unmap0, address, (int64)(16)); r != 0 {
+		t.Errorf("unmap0 returned %d, want 0", r)
+	}
+	if got := readFile(t, fsys, "/data"); got != "HELLO WORLD" {
+		t.Errorf("file is %q after unmap, want %q", got, "HELLO WORLD")
+	}
+}
+
+func TestMapPrivate(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	vm := vmtest.NewVM(jvm.Options{FS: fsys})
+	writeFile(t, fsys, "/data", "hello world")
+	fd := openFD(t, vm, "/data")
+
+	for _, prot := range []int32{MAP_PV, MAP_RO} {
+		address := call64(t, vm, FileDispatcherImpl_map0, fd, prot, (int64)(6), (int64)(5), false)
+		mem := cutil.Bytes(address, 5)
+		if got := string(mem); got != "world" {
+			t.Fatalf("mapped memory is %q, want %q", got, "world")
+		}
+		copy(mem, "WORLD")
+		if err := ForceMapping(address, 5); err != nil {
+			t.Fatal(err)
+		}
+		if r := call(t, vm, FileChannelImpl_unmap0, address, (int64)(5)); r != 0 {
+			t.Errorf("unmap0 returned %d, want 0", r)
+		}
+		if got := readFile(t, fsys, "/data"); got != "hello world" {
+			t.Errorf("file is %q after changing the mapping %d, want it unchanged", got, prot)
+		}
+	}
+}
+
+func TestLockOverlap(t *testing.T) {
+	fsys := vfs.NewMemFS()
+	writeFile(t, fsys, "/data", "")
+	// the locks of the same VM never conflict, like the POSIX locks of a process
+	vm1 := vmtest.NewVM(jvm.Options{FS: fsys})
+	vm2 := vmtest.NewVM(jvm.Options{FS: fsys})
+	fd1 := openFD(t, vm1, "/data")
+	fd2 := openFD(t, vm2, "/data")
+
+	// the locks are kept in the process, so the test releases them for the other tests
+	type held struct {
+		vm        *jvm.VM
+		fd        ir.Ref
+		pos, size int64
+	}
+	var locks []held
+	defer func() {
+		for _, l := range locks {
+			vmtest.Call(l.vm, FileDispatcherImpl_release0, l.fd, l.pos, l.size)
+		}
+	}()
+	lock := func(vm *jvm.VM, fd ir.Ref, pos, size int64, shared bool) int32 {
+		t.Helper()
+		r := call(t, vm, FileDispatcherImpl_lock0, fd, false, pos, size, shared)
+		if r == FD_LOCKED {
+			locks = append(locks, held{vm, fd, pos, size})
+		}
+		return r
+	}
+	if r := lock(vm1, fd1, 0, 10, false); r != FD_LOCKED {
+		t.Fatalf("lock0 returned %d, want FD_LOCKED", r)
+	}
+	if r := lock(vm1, fd1, 5, 10, false); r != FD_LOCKED {
+		t.Errorf("overlapping lock0 of the same VM returned %d, want FD_LOCKED", r)
+	}
+	if r := lock(vm2, fd2, 5, 10, false); r != FD_NO_LOCK {
+		t.Errorf("overlapping lock0 returned %d, want FD_NO_LOCK", r)
+	}
+	if r := lock(vm2, fd2, 9, 1, true); r != FD_NO_LOCK {
+		t.Errorf("shared lock0 overlapping an exclusive lock returned %d, want FD_NO_LOCK", r)
+	}
+	if r := lock(vm2, fd2, 15, 10, false); r != FD_LOCKED {
+		t.Errorf("adjacent lock0 returned %d, want FD_LOCKED", r)
+	}
+
+	if r := lock(vm1, fd1, 50, 10, true); r != FD_LOCKED {
+		t.Fatalf("shared lock0 returned %d, want FD_LOCKED", r)
+	}
+	if r := lock(vm2, fd2, 55, 10, true); r != FD_LOCKED {
+		t.Errorf("overlapping shared lock0 returned %d, want FD_LOCKED", r)
+	}
+
+	// a lock is released by its exact range
+	if err := vmtest.Call(vm1, FileDispatcherImpl_release0, fd1, (int64)(0), (int64)(10)); err != nil {
+		t.Fatal(err)
+	}
+	if r := lock(vm2, fd2, 5, 10, false); r != FD_NO_LOCK {
+		t.Errorf("lock0 overlapping the remaining lock returned %d, want FD_NO_LOCK", r)
+	}
+	if err := vmtest.Call(vm1, FileDispatcherImpl_release0, fd1, (int64)(5), (int64)(10)); err != nil {
+		t.Fatal(err)
+	}
+	if r := lock(vm2, fd2, 5, 10, false); r != FD_LOCKED {
+		t.Errorf("lock0 after the release returned %d, want FD_LOCKED", r)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	vm := vmtest.NewVM(jvm.Options{FS: vfs.NewMemFS()})
+	fds := call64(t, vm, IOUtil_makePipe, false)
+	rfd, wfd := (int32)(fds>>32), (int32)(fds)
+
+	if r := call(t, vm, IOUtil_drain1, rfd); r != 0 {
+		t.Errorf("drain1 of the empty pipe returned %d, want 0", r)
+	}
+	address := cutil.AllocMemory(4)
+	defer cutil.FreeMemory(address)
+	rfdObj, err := vm.Files().Get(rfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fd := vmtest.NewFD(vm, rfdObj)
+	// the read end is non-blocking
+	if n := call(t, vm, FileDispatcherImpl_read0, fd, (int64)(address), (int32)(4)); n != IOS_UNAVAILABLE {
+		t.Errorf("read0 of the empty pipe returned %d, want IOS_UNAVAILABLE", n)
+	}
+
+	for _, b := range []int32{'a', 'b', 'c'} {
+		if n := call(t, vm, IOUtil_write1, wfd, b); n != 1 {
+			t.Errorf("write1 returned %d, want 1", n)
+		}
+	}
+	if n := call(t, vm, FileDispatcherImpl_read0, fd, (int64)(address), (int32)(1)); n != 1 {
+		t.Errorf("read0 returned %d, want 1", n)
+	}
+	if got := cutil.Bytes((int64)(address), 1)[0]; got != 'a' {
+		t.Errorf("read0 read %q, want 'a'", got)
+	}
+	if r := call(t, vm, IOUtil_drain1, rfd); r != 1 {
+		t.Errorf("drain1 returned %d, want 1", r)
+	}
+	if r := call(t, vm, IOUtil_drain, rfd); r == 0 {
+		t.Error("drain returned false, want true")
+	}
+	if r := call(t, vm, IOUtil_drain, rfd); r != 0 {
+		t.Error("drain of the empty pipe returned true, want false")
+	}
+
+	// switching to the blocking mode makes read0 wait for the writer
+	if err := vmtest.Call(vm, IOUtil_configureBlocking, fd, true); err != nil {
+		t.Fatal(err)
+	}
+	w, err := vm.Files().Get(wfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go w.(io.Writer).Write([]byte("z"))
+	if n := call(t, vm, FileDispatcherImpl_read0, fd, (int64)(address), (int32)(4)); n != 1 {
+		t.Errorf("blocking read0 returned %d, want 1", n)
+	}
+	if got := cutil.Bytes((int64)(address), 1)[0]; got != 'z' {
+		t.Errorf("blocking read0 read %q, want 'z'", got)
+	}
+}
@@ -0,0 +1,41 @@
+package sun_nio_ch
+
+import (
+	"hash/fnv"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/FileKey.initIDs()V", FileKey_initIDs)
+	native.RegisterDefaultNative("sun/nio/ch/FileKey.init(Ljava/io/FileDescriptor;)V", FileKey_init)
+}
+
+func FileKey_initIDs(vm ir.VM) error {
+	return nil
+}
+
+// private native void init(FileDescriptor fd) throws IOException;
+func FileKey_init(vm ir.VM) error {
+	stack := vm.GetStack()
+	this := stack.GetVarRef(0)
+	f, err := fdFile(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	key, err := lockKeyOf(f)
+	if err != nil {
+		return helper.IOException(err)
+	}
+	if key.name != "" {
+		// the filesystems without inode numbers are keyed by the names
+		h := fnv.New64a()
+		h.Write([]byte(key.name))
+		key.ino = h.Sum64()
+	}
+	*(*int64)(this.Class().GetFieldByName("st_dev").GetPointer(this)) = (int64)(key.dev)
+	*(*int64)(this.Class().GetFieldByName("st_ino").GetPointer(this)) = (int64)(key.ino)
+	return nil
+}
@@ -0,0 +1,121 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	"sync"
+
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+// fileLocks are the record locks of FileChannel.lock.
+// They are advisory and kept in the process, so they exclude the other VMs sharing the filesystem,
+// while FileChannelImpl checks the overlapping locks in the same VM itself.
+var fileLocks = newLockTable()
+
+// lockKey identifies the locked file
+type lockKey struct {
+	dev, ino uint64
+	// name is used by the filesystems which do not report the inode numbers
+	name string
+}
+
+func lockKeyOf(f io.Closer) (lockKey, error) {
+	st, ok := f.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		return lockKey{}, jvm.ErrBadFD
+	}
+	stat, err := st.Stat()
+	if err != nil {
+		return lockKey{}, err
+	}
+	if attr := vfs.AttrOf(stat); attr.Ino != 0 {
+		return lockKey{dev: attr.Dev, ino: attr.Ino}, nil
+	}
+	if named, ok := f.(interface{ Name() string }); ok {
+		return lockKey{name: named.Name()}, nil
+	}
+	return lockKey{}, jvm.ErrBadFD
+}
+
+// fileLock is a locked range, whose owner is the descriptor table of the VM like the process of POSIX locks
+type fileLock struct {
+	owner  *jvm.FileTable
+	pos    int64
+	size   int64
+	shared bool
+}
+
+func (l *fileLock) end() int64 {
+	// the size is Long.MAX_VALUE when the lock is up to the end of the file
+	if l.size > math.MaxInt64-l.pos {
+		return math.MaxInt64
+	}
+	return l.pos + l.size
+}
+
+func (l *fileLock) conflicts(o *fileLock) bool {
+	if l.owner == o.owner || (l.shared && o.shared) {
+		return false
+	}
+	return l.pos < o.end() && o.pos < l.end()
+}
+
+type lockTable struct {
+	mux   sync.Mutex
+	cond  sync.Cond
+	locks map[lockKey][]*fileLock
+}
+
+func newLockTable() *lockTable {
+	t := &lockTable{
+		locks: make(map[lockKey][]*fileLock),
+	}
+	t.cond.L = &t.mux
+	return t
+}
+
+// lock acquires the lock, it waits for the conflicting locks to be released if wait is true,
+// otherwise it returns false when there are conflicts.
+func (t *lockTable) lock(key lockKey, l *fileLock, wait bool) bool {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	for t.hasConflict(key, l) {
+		if !wait {
+			return false
+		}
+		t.cond.Wait()
+	}
+	t.locks[key] = append(t.locks[key], l)
+	return true
+}
+
+func (t *lockTable) hasConflict(key lockKey, l *fileLock) bool {
+	for _, o := range t.locks[key] {
+		if l.conflicts(o) {
+			return true
+		}
+	}
+	return false
+}
+
+// release releases the lock of the owner which has exactly the range, FileChannelImpl releases the ranges it locked
+func (t *lockTable) release(key lockKey, owner *jvm.FileTable, pos, size int64) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	locks := t.locks[key]
+	for i, o := range locks {
+		if o.owner == owner && o.pos == pos && o.size == size {
+			locks = append(locks[:i], locks[i+1:]...)
+			break
+		}
+	}
+	if len(locks) == 0 {
+		delete(t.locks, key)
+	} else {
+		t.locks[key] = locks
+	}
+	t.cond.Broadcast()
+}
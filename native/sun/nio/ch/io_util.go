@@ -0,0 +1,71 @@
+package sun_nio_ch
+
+import (
+	"errors"
+	"io"
+	"syscall"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+// The return values of sun.nio.ch.IOStatus
+const (
+	IOS_EOF              = -1
+	IOS_UNAVAILABLE      = -2
+	IOS_INTERRUPTED      = -3
+	IOS_UNSUPPORTED      = -4
+	IOS_THROWN           = -5
+	IOS_UNSUPPORTED_CASE = -6
+)
+
+// fdFile returns the open file of the FileDescriptor
+func fdFile(vm ir.VM, fdObj ir.Ref) (io.Closer, error) {
+	if fdObj == nil {
+		return nil, errs.NullPointerException
+	}
+	f, err := vm.(*jvm.VM).Files().Get(helper.GetFD(fdObj))
+	if err != nil {
+		return nil, helper.IOException(err)
+	}
+	return f, nil
+}
+
+// iovec is struct iovec of C, which IOUtil builds in the native memory for the vectored operations
+type iovec struct {
+	base uintptr
+	len  uintptr
+}
+
+func iovecs(address int64, n int32) []iovec {
+	if address == 0 || n <= 0 {
+		return nil
+	}
+	return unsafe.Slice((*iovec)(cutil.Pointer(address)), n)
+}
+
+// convertReturn converts the result of a read or write to the value which the dispatchers return,
+// which is the number of bytes, or an IOStatus constant.
+func convertReturn(n int, err error, reading bool) (int64, error) {
+	if n > 0 || err == nil {
+		return (int64)(n), nil
+	}
+	if reading && err == io.EOF {
+		return IOS_EOF, nil
+	}
+	if errors.Is(err, syscall.EAGAIN) {
+		return IOS_UNAVAILABLE, nil
+	}
+	return 0, helper.IOException(err)
+}
+
+// blocking runs the operation in a safe region, since it may block on the file, pipe or terminal
+func blocking(vm ir.VM, op func() (int, error)) (int, error) {
+	vm.(*jvm.VM).EnterSafeRegion()
+	defer vm.(*jvm.VM).LeaveSafeRegion()
+	return op()
+}
@@ -0,0 +1,183 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"math"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.initIDs()V", IOUtil_initIDs)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.randomBytes([B)Z", IOUtil_randomBytes)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.makePipe(Z)J", IOUtil_makePipe)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.write1(IB)I", IOUtil_write1)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.drain(I)Z", IOUtil_drain)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.drain1(I)I", IOUtil_drain1)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.configureBlocking(Ljava/io/FileDescriptor;Z)V", IOUtil_configureBlocking)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.fdVal(Ljava/io/FileDescriptor;)I", IOUtil_fdVal)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.setfdVal(Ljava/io/FileDescriptor;I)V", IOUtil_setfdVal)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.fdLimit()I", IOUtil_fdLimit)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.iovMax()I", IOUtil_iovMax)
+	native.RegisterDefaultNative("sun/nio/ch/IOUtil.writevMax()J", IOUtil_writevMax)
+}
+
+func IOUtil_initIDs(vm ir.VM) error {
+	return nil
+}
+
+// static native boolean randomBytes(byte[] someBytes);
+func IOUtil_randomBytes(vm ir.VM) error {
+	// it is not implemented on unix either
+	vm.GetStack().PushInt32(0)
+	return nil
+}
+
+// static native long makePipe(boolean blocking) throws IOException;
+func IOUtil_makePipe(vm ir.VM) error {
+	stack := vm.GetStack()
+	r, w := newPipe()
+	if stack.GetVarInt32(0) == 0 {
+		r.SetNonblock(true)
+	}
+	files := vm.(*jvm.VM).Files()
+	rfd := files.Add(r)
+	wfd := files.Add(w)
+	// the read end is in the high 32 bits, and the write end is in the low 32 bits
+	stack.PushInt64((int64)(rfd)<<32 | (int64)(wfd))
+	return nil
+}
+
+// static native int write1(int fd, byte b) throws IOException;
+func IOUtil_write1(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return helper.IOException(err)
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	n, err := w.Write([]byte{(byte)(stack.GetVarInt32(1))})
+	res, err := convertReturn(n, err, false)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// drainable is the read end of a pipe, which reports the number of buffered bytes
+type drainable interface {
+	io.Reader
+	Len() int
+}
+
+// static native boolean drain(int fd) throws IOException;
+func IOUtil_drain(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return helper.IOException(err)
+	}
+	r, ok := f.(drainable)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	drained := false
+	var buf [128]byte
+	for r.Len() > 0 {
+		n, err := r.Read(buf[:])
+		if n > 0 {
+			drained = true
+		}
+		if err != nil {
+			break
+		}
+	}
+	pushBool(stack, drained)
+	return nil
+}
+
+// static native int drain1(int fd) throws IOException;
+func IOUtil_drain1(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return helper.IOException(err)
+	}
+	r, ok := f.(drainable)
+	if !ok {
+		return helper.IOException(jvm.ErrBadFD)
+	}
+	if r.Len() == 0 {
+		stack.PushInt32(0)
+		return nil
+	}
+	var buf [1]byte
+	n, err := r.Read(buf[:])
+	if n == 0 && err != nil && err != io.EOF {
+		return helper.IOException(err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
+
+// public static native void configureBlocking(FileDescriptor fd, boolean blocking) throws IOException;
+func IOUtil_configureBlocking(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	// the files which never block, e.g. regular files, ignore the mode like on unix
+	if nb, ok := f.(nonblocker); ok {
+		nb.SetNonblock(stack.GetVarInt32(1) == 0)
+	}
+	return nil
+}
+
+// public static native int fdVal(FileDescriptor fd);
+func IOUtil_fdVal(vm ir.VM) error {
+	stack := vm.GetStack()
+	stack.PushInt32(helper.GetFD(stack.GetVarRef(0)))
+	return nil
+}
+
+// static native void setfdVal(FileDescriptor fd, int value);
+func IOUtil_setfdVal(vm ir.VM) error {
+	stack := vm.GetStack()
+	helper.SetFD(stack.GetVarRef(0), stack.GetVarInt32(1))
+	return nil
+}
+
+// static native int fdLimit();
+func IOUtil_fdLimit(vm ir.VM) error {
+	// the descriptor table grows as needed
+	vm.GetStack().PushInt32(math.MaxInt32)
+	return nil
+}
+
+// static native int iovMax();
+func IOUtil_iovMax(vm ir.VM) error {
+	vm.GetStack().PushInt32(1024)
+	return nil
+}
+
+// static native long writevMax();
+func IOUtil_writevMax(vm ir.VM) error {
+	vm.GetStack().PushInt64(math.MaxInt32)
+	return nil
+}
+
+func pushBool(stack ir.Stack, v bool) {
+	if v {
+		stack.PushInt32(1)
+	} else {
+		stack.PushInt32(0)
+	}
+}
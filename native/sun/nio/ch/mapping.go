@@ -0,0 +1,160 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	"sync"
+	"syscall"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jdk_internal_misc "github.com/LiterMC/wasm-jdk/native/jdk/internal_/misc"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+// The protections of map0
+const (
+	MAP_RO = 0
+	MAP_RW = 1
+	MAP_PV = 2
+)
+
+// allocationGranularity is the alignment of the mapped positions, which is the page size of Unsafe
+var allocationGranularity = (int64)(jdk_internal_misc.PageSize)
+
+// mapping emulates a memory mapped region of a file, since the filesystems cannot be mapped by the host.
+// The region is read into the native memory when it is mapped,
+// and the changes are written back by MappedByteBuffer.force and when it is unmapped.
+type mapping struct {
+	files *jvm.FileTable
+	// fd is a duplicate of the mapped descriptor, so the file stays open after the channel is closed like mmap
+	fd       int32
+	file     io.Closer
+	position int64
+	mem      []byte
+	writable bool
+}
+
+var (
+	mappingMux sync.Mutex
+	mappings   = make(map[uintptr]*mapping)
+)
+
+// mapFile maps the region of the file, and returns the address of the memory
+func mapFile(vm ir.VM, fd int32, prot int32, position int64, length int64) (int64, error) {
+	files := vm.(*jvm.VM).Files()
+	f, err := files.Get(fd)
+	if err != nil {
+		return 0, helper.IOException(err)
+	}
+	r, ok := f.(io.ReaderAt)
+	if !ok {
+		return 0, helper.IOException(syscall.ENODEV)
+	}
+	if position < 0 || length <= 0 || length > math.MaxInt32 {
+		return 0, helper.IOException(syscall.EINVAL)
+	}
+	dup, err := files.Dup(fd)
+	if err != nil {
+		return 0, helper.IOException(err)
+	}
+	address := cutil.AllocMemory((int)(length))
+	mem := cutil.Bytes((int64)(address), (int)(length))
+	_, err = blocking(vm, func() (int, error) {
+		// the memory beyond the end of the file stays zero
+		n, err := r.ReadAt(mem, position)
+		if err == io.EOF {
+			err = nil
+		}
+		return n, err
+	})
+	if err != nil {
+		cutil.FreeMemory(address)
+		files.Close(dup)
+		return 0, helper.IOException(err)
+	}
+	mappingMux.Lock()
+	mappings[address] = &mapping{
+		files:    files,
+		fd:       dup,
+		file:     f,
+		position: position,
+		mem:      mem,
+		writable: prot == MAP_RW,
+	}
+	mappingMux.Unlock()
+	return (int64)(address), nil
+}
+
+// writeBack writes the bytes of the region back to the file.
+// The bytes beyond the end of the file are discarded, since the mapping does not extend the file.
+func (m *mapping) writeBack(off, n int) error {
+	if !m.writable {
+		return nil
+	}
+	w, ok := m.file.(io.WriterAt)
+	if !ok {
+		return syscall.EBADF
+	}
+	if st, ok := m.file.(interface{ Stat() (fs.FileInfo, error) }); ok {
+		stat, err := st.Stat()
+		if err != nil {
+			return err
+		}
+		if limit := stat.Size() - m.position - (int64)(off); limit < (int64)(n) {
+			if limit <= 0 {
+				return nil
+			}
+			n = (int)(limit)
+		}
+	}
+	_, err := w.WriteAt(m.mem[off:off+n], m.position+(int64)(off))
+	return err
+}
+
+// unmapFile writes back and releases the mapping at the address
+func unmapFile(vm ir.VM, address int64) error {
+	mappingMux.Lock()
+	m, ok := mappings[(uintptr)(address)]
+	delete(mappings, (uintptr)(address))
+	mappingMux.Unlock()
+	if !ok {
+		return syscall.EINVAL
+	}
+	_, err := blocking(vm, func() (int, error) { return 0, m.writeBack(0, len(m.mem)) })
+	m.files.Close(m.fd)
+	cutil.FreeMemory((uintptr)(address))
+	return err
+}
+
+// ForceMapping writes the changes in the memory range of the mappings back to the files and syncs them,
+// for MappedByteBuffer.force. The range may start before the mapping, since it is aligned to the pages.
+func ForceMapping(address, length int64) error {
+	start := (uintptr)(address)
+	end := start + (uintptr)(length)
+	mappingMux.Lock()
+	var forced []*mapping
+	for base, m := range mappings {
+		if base < end && start < base+(uintptr)(len(m.mem)) {
+			forced = append(forced, m)
+		}
+	}
+	mappingMux.Unlock()
+	for _, m := range forced {
+		base := (uintptr)(unsafe.Pointer(unsafe.SliceData(m.mem)))
+		off := max(start, base) - base
+		n := min(end, base+(uintptr)(len(m.mem))) - base - off
+		if err := m.writeBack((int)(off), (int)(n)); err != nil {
+			return err
+		}
+		if s, ok := m.file.(interface{ Sync() error }); ok && m.writable {
+			if err := s.Sync(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package sun_nio_ch
+
+import (
+	"encoding/binary"
+	"net/netip"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.AFINET()I", NativeSocketAddress_AFINET)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.AFINET6()I", NativeSocketAddress_AFINET6)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.sizeofSockAddr4()I", NativeSocketAddress_sizeofSockAddr4)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.sizeofSockAddr6()I", NativeSocketAddress_sizeofSockAddr6)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.sizeofFamily()I", NativeSocketAddress_sizeofFamily)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetFamily()I", NativeSocketAddress_offsetFamily)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin4Port()I", NativeSocketAddress_offsetSin4Port)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin4Addr()I", NativeSocketAddress_offsetSin4Addr)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin6Port()I", NativeSocketAddress_offsetSin6Port)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin6Addr()I", NativeSocketAddress_offsetSin6Addr)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin6ScopeId()I", NativeSocketAddress_offsetSin6ScopeId)
+	native.RegisterDefaultNative("sun/nio/ch/NativeSocketAddress.offsetSin6FlowInfo()I", NativeSocketAddress_offsetSin6FlowInfo)
+}
+
+// The layout of struct sockaddr_in and sockaddr_in6 on Linux
+const (
+	AF_INET  = 2
+	AF_INET6 = 10
+
+	sizeofSockAddr4    = 16
+	sizeofSockAddr6    = 28
+	sizeofFamily       = 2
+	offsetFamily       = 0
+	offsetSin4Port     = 2
+	offsetSin4Addr     = 4
+	offsetSin6Port     = 2
+	offsetSin6FlowInfo = 4
+	offsetSin6Addr     = 8
+	offsetSin6ScopeId  = 24
+)
+
+// putSockAddr writes the address as sockaddr_in or sockaddr_in6 to the native memory,
+// the family is in the native byte order, and the port is in the network byte order.
+func putSockAddr(address int64, addr netip.AddrPort) {
+	ip := addr.Addr().Unmap()
+	if ip.Is4() {
+		b := cutil.Bytes(address, sizeofSockAddr4)
+		clear(b)
+		binary.NativeEndian.PutUint16(b[offsetFamily:], AF_INET)
+		binary.BigEndian.PutUint16(b[offsetSin4Port:], addr.Port())
+		a := ip.As4()
+		copy(b[offsetSin4Addr:], a[:])
+		return
+	}
+	b := cutil.Bytes(address, sizeofSockAddr6)
+	clear(b)
+	binary.NativeEndian.PutUint16(b[offsetFamily:], AF_INET6)
+	binary.BigEndian.PutUint16(b[offsetSin6Port:], addr.Port())
+	a := ip.As16()
+	copy(b[offsetSin6Addr:], a[:])
+}
+
+// getSockAddr reads the sockaddr_in or sockaddr_in6 of the length from the native memory
+func getSockAddr(address int64, length int32) (netip.AddrPort, error) {
+	if length < sizeofFamily {
+		return netip.AddrPort{}, syscall.EINVAL
+	}
+	b := cutil.Bytes(address, (int)(length))
+	switch binary.NativeEndian.Uint16(b[offsetFamily:]) {
+	case AF_INET:
+		if length < sizeofSockAddr4 {
+			return netip.AddrPort{}, syscall.EINVAL
+		}
+		addr := netip.AddrFrom4(([4]byte)(b[offsetSin4Addr:]))
+		return netip.AddrPortFrom(addr, binary.BigEndian.Uint16(b[offsetSin4Port:])), nil
+	case AF_INET6:
+		if length < sizeofSockAddr6 {
+			return netip.AddrPort{}, syscall.EINVAL
+		}
+		addr := netip.AddrFrom16(([16]byte)(b[offsetSin6Addr:])).Unmap()
+		return netip.AddrPortFrom(addr, binary.BigEndian.Uint16(b[offsetSin6Port:])), nil
+	}
+	return netip.AddrPort{}, syscall.EAFNOSUPPORT
+}
+
+func NativeSocketAddress_AFINET(vm ir.VM) error {
+	vm.GetStack().PushInt32(AF_INET)
+	return nil
+}
+
+func NativeSocketAddress_AFINET6(vm ir.VM) error {
+	vm.GetStack().PushInt32(AF_INET6)
+	return nil
+}
+
+func NativeSocketAddress_sizeofSockAddr4(vm ir.VM) error {
+	vm.GetStack().PushInt32(sizeofSockAddr4)
+	return nil
+}
+
+func NativeSocketAddress_sizeofSockAddr6(vm ir.VM) error {
+	vm.GetStack().PushInt32(sizeofSockAddr6)
+	return nil
+}
+
+func NativeSocketAddress_sizeofFamily(vm ir.VM) error {
+	vm.GetStack().PushInt32(sizeofFamily)
+	return nil
+}
+
+func NativeSocketAddress_offsetFamily(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetFamily)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin4Port(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin4Port)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin4Addr(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin4Addr)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin6Port(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin6Port)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin6Addr(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin6Addr)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin6ScopeId(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin6ScopeId)
+	return nil
+}
+
+func NativeSocketAddress_offsetSin6FlowInfo(vm ir.VM) error {
+	vm.GetStack().PushInt32(offsetSin6FlowInfo)
+	return nil
+}
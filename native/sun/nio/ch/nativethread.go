@@ -0,0 +1,29 @@
+package sun_nio_ch
+
+import (
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/NativeThread.init()V", NativeThread_init)
+	native.RegisterDefaultNative("sun/nio/ch/NativeThread.current0()J", NativeThread_current0)
+	native.RegisterDefaultNative("sun/nio/ch/NativeThread.signal(J)V", NativeThread_signal)
+}
+
+func NativeThread_init(vm ir.VM) error {
+	return nil
+}
+
+// private static native long current0();
+func NativeThread_current0(vm ir.VM) error {
+	// the blocked threads are woken up by preClose0 instead of signals,
+	// so any id other than 0 and -1, which mean no thread and a virtual thread, is enough
+	vm.GetStack().PushInt64(1)
+	return nil
+}
+
+// private static native void signal(long tid) throws IOException;
+func NativeThread_signal(vm ir.VM) error {
+	return nil
+}
@@ -0,0 +1,510 @@
+package sun_nio_ch
+
+import (
+	"errors"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	java_net "github.com/LiterMC/wasm-jdk/native/java/net"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/Net.initIDs()V", Net_initIDs)
+	native.RegisterDefaultNative("sun/nio/ch/Net.isIPv6Available0()Z", Net_isIPv6Available0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.isReusePortAvailable0()Z", Net_true)
+	native.RegisterDefaultNative("sun/nio/ch/Net.isExclusiveBindAvailable()I", Net_isExclusiveBindAvailable)
+	native.RegisterDefaultNative("sun/nio/ch/Net.shouldSetBothIPv4AndIPv6Options0()Z", Net_true)
+	native.RegisterDefaultNative("sun/nio/ch/Net.canIPv6SocketJoinIPv4Group0()Z", Net_true)
+	native.RegisterDefaultNative("sun/nio/ch/Net.canJoin6WithIPv4Group0()Z", Net_true)
+	native.RegisterDefaultNative("sun/nio/ch/Net.canUseIPv6OptionsWithIPv4LocalAddress0()Z", Net_true)
+	native.RegisterDefaultNative("sun/nio/ch/Net.shouldShutdownWriteBeforeClose0()Z", Net_false)
+	native.RegisterDefaultNative("sun/nio/ch/Net.socket0(ZZZZ)I", Net_socket0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.bind0(Ljava/io/FileDescriptor;ZZLjava/net/InetAddress;I)V", Net_bind0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.listen(Ljava/io/FileDescriptor;I)V", Net_listen)
+	native.RegisterDefaultNative("sun/nio/ch/Net.connect0(ZLjava/io/FileDescriptor;Ljava/net/InetAddress;I)I", Net_connect0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.accept(Ljava/io/FileDescriptor;Ljava/io/FileDescriptor;[Ljava/net/InetSocketAddress;)I", Net_accept)
+	native.RegisterDefaultNative("sun/nio/ch/Net.shutdown(Ljava/io/FileDescriptor;I)V", Net_shutdown)
+	native.RegisterDefaultNative("sun/nio/ch/Net.localPort(Ljava/io/FileDescriptor;)I", Net_localPort)
+	native.RegisterDefaultNative("sun/nio/ch/Net.localInetAddress(Ljava/io/FileDescriptor;)Ljava/net/InetAddress;", Net_localInetAddress)
+	native.RegisterDefaultNative("sun/nio/ch/Net.remotePort(Ljava/io/FileDescriptor;)I", Net_remotePort)
+	native.RegisterDefaultNative("sun/nio/ch/Net.remoteInetAddress(Ljava/io/FileDescriptor;)Ljava/net/InetAddress;", Net_remoteInetAddress)
+	native.RegisterDefaultNative("sun/nio/ch/Net.getIntOption0(Ljava/io/FileDescriptor;ZII)I", Net_getIntOption0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.setIntOption0(Ljava/io/FileDescriptor;ZIIIZ)V", Net_setIntOption0)
+	native.RegisterDefaultNative("sun/nio/ch/Net.poll(Ljava/io/FileDescriptor;IJ)I", Net_poll)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollConnect(Ljava/io/FileDescriptor;J)Z", Net_pollConnect)
+	native.RegisterDefaultNative("sun/nio/ch/Net.available(Ljava/io/FileDescriptor;)I", Net_available)
+	native.RegisterDefaultNative("sun/nio/ch/Net.sendOOB(Ljava/io/FileDescriptor;B)I", Net_sendOOB)
+	native.RegisterDefaultNative("sun/nio/ch/Net.discardOOB(Ljava/io/FileDescriptor;)Z", Net_false)
+	native.RegisterDefaultNative("sun/nio/ch/Net.joinOrDrop4(ZLjava/io/FileDescriptor;III)I", Net_unsupportedMembership)
+	native.RegisterDefaultNative("sun/nio/ch/Net.blockOrUnblock4(ZLjava/io/FileDescriptor;III)I", Net_unsupportedMembership)
+	native.RegisterDefaultNative("sun/nio/ch/Net.joinOrDrop6(ZLjava/io/FileDescriptor;[BI[B)I", Net_unsupportedMembership)
+	native.RegisterDefaultNative("sun/nio/ch/Net.blockOrUnblock6(ZLjava/io/FileDescriptor;[BI[B)I", Net_unsupportedMembership)
+	native.RegisterDefaultNative("sun/nio/ch/Net.setInterface4(Ljava/io/FileDescriptor;I)V", Net_setInterface)
+	native.RegisterDefaultNative("sun/nio/ch/Net.getInterface4(Ljava/io/FileDescriptor;)I", Net_getInterface)
+	native.RegisterDefaultNative("sun/nio/ch/Net.setInterface6(Ljava/io/FileDescriptor;I)V", Net_setInterface)
+	native.RegisterDefaultNative("sun/nio/ch/Net.getInterface6(Ljava/io/FileDescriptor;)I", Net_getInterface)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollinValue()S", Net_pollinValue)
+	native.RegisterDefaultNative("sun/nio/ch/Net.polloutValue()S", Net_polloutValue)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollerrValue()S", Net_pollerrValue)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollhupValue()S", Net_pollhupValue)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollnvalValue()S", Net_pollnvalValue)
+	native.RegisterDefaultNative("sun/nio/ch/Net.pollconnValue()S", Net_pollconnValue)
+}
+
+// The levels and the options of getsockopt(2) on Linux, which SocketOptionRegistry is generated with
+const (
+	SOL_SOCKET   = 1
+	IPPROTO_IP   = 0
+	IPPROTO_TCP  = 6
+	IPPROTO_IPV6 = 41
+
+	SO_REUSEADDR = 2
+	SO_BROADCAST = 6
+	SO_SNDBUF    = 7
+	SO_RCVBUF    = 8
+	SO_KEEPALIVE = 9
+	SO_OOBINLINE = 10
+	SO_LINGER    = 13
+	SO_REUSEPORT = 15
+
+	TCP_NODELAY = 1
+
+	IP_TOS            = 1
+	IP_MULTICAST_TTL  = 33
+	IP_MULTICAST_LOOP = 34
+
+	IPV6_MULTICAST_HOPS = 18
+	IPV6_MULTICAST_LOOP = 19
+	IPV6_TCLASS         = 67
+)
+
+// The how of shutdown(2)
+const (
+	SHUT_RD   = 0
+	SHUT_WR   = 1
+	SHUT_RDWR = 2
+)
+
+type sockOpt struct {
+	level, opt int32
+}
+
+var socketOptions = map[sockOpt]vnet.Option{
+	{SOL_SOCKET, SO_REUSEADDR}:          vnet.OptReuseAddr,
+	{SOL_SOCKET, SO_REUSEPORT}:          vnet.OptReusePort,
+	{SOL_SOCKET, SO_BROADCAST}:          vnet.OptBroadcast,
+	{SOL_SOCKET, SO_SNDBUF}:             vnet.OptSendBuffer,
+	{SOL_SOCKET, SO_RCVBUF}:             vnet.OptRecvBuffer,
+	{SOL_SOCKET, SO_KEEPALIVE}:          vnet.OptKeepAlive,
+	{SOL_SOCKET, SO_OOBINLINE}:          vnet.OptOOBInline,
+	{SOL_SOCKET, SO_LINGER}:             vnet.OptLinger,
+	{IPPROTO_TCP, TCP_NODELAY}:          vnet.OptNoDelay,
+	{IPPROTO_IP, IP_TOS}:                vnet.OptTOS,
+	{IPPROTO_IP, IP_MULTICAST_TTL}:      vnet.OptMulticastTTL,
+	{IPPROTO_IP, IP_MULTICAST_LOOP}:     vnet.OptMulticastLoop,
+	{IPPROTO_IPV6, IPV6_TCLASS}:         vnet.OptTOS,
+	{IPPROTO_IPV6, IPV6_MULTICAST_HOPS}: vnet.OptMulticastTTL,
+	{IPPROTO_IPV6, IPV6_MULTICAST_LOOP}: vnet.OptMulticastLoop,
+}
+
+// socketException converts the error to the exception which handleSocketError of the JDK throws
+func socketException(err error) error {
+	if _, ok := errs.AsThrowError(err); ok {
+		return err
+	}
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		return errs.Throw("java/net/SocketException", helper.Strerror(err))
+	}
+	msg := helper.Strerror(errno)
+	switch errno {
+	case syscall.ECONNREFUSED, syscall.ETIMEDOUT, syscall.ENOTCONN:
+		return errs.Throw("java/net/ConnectException", msg)
+	case syscall.EHOSTUNREACH:
+		return errs.Throw("java/net/NoRouteToHostException", msg)
+	case syscall.EADDRINUSE, syscall.EADDRNOTAVAIL, syscall.EACCES:
+		return errs.Throw("java/net/BindException", msg)
+	}
+	return errs.Throw("java/net/SocketException", msg)
+}
+
+// fdSocket returns the socket of the FileDescriptor
+func fdSocket(vm ir.VM, fdObj ir.Ref) (*vnet.Socket, error) {
+	f, err := fdFile(vm, fdObj)
+	if err != nil {
+		return nil, err
+	}
+	s, ok := f.(*vnet.Socket)
+	if !ok {
+		return nil, socketException(syscall.ENOTSOCK)
+	}
+	return s, nil
+}
+
+// timeoutOf converts the timeout in milliseconds, where a negative one waits forever
+func timeoutOf(millis int64) time.Duration {
+	if millis < 0 {
+		return -1
+	}
+	return (time.Duration)(millis) * time.Millisecond
+}
+
+func Net_initIDs(vm ir.VM) error {
+	return nil
+}
+
+func Net_true(vm ir.VM) error {
+	vm.GetStack().PushInt32(1)
+	return nil
+}
+
+func Net_false(vm ir.VM) error {
+	vm.GetStack().PushInt32(0)
+	return nil
+}
+
+// private static native boolean isIPv6Available0();
+func Net_isIPv6Available0(vm ir.VM) error {
+	pushBool(vm.GetStack(), vnet.IPv6Available(vm.(*jvm.VM).Network()))
+	return nil
+}
+
+// private static native int isExclusiveBindAvailable();
+func Net_isExclusiveBindAvailable(vm ir.VM) error {
+	// exclusive bind is only on Windows
+	vm.GetStack().PushInt32(-1)
+	return nil
+}
+
+// private static native int socket0(boolean preferIPv6, boolean stream, boolean reuse, boolean fastLoopback);
+func Net_socket0(vm ir.VM) error {
+	stack := vm.GetStack()
+	network := vm.(*jvm.VM).Network()
+	ipv6 := stack.GetVarInt32(0) != 0 && vnet.IPv6Available(network)
+	s := vnet.NewSocket(network, stack.GetVarInt32(1) != 0, ipv6)
+	if stack.GetVarInt32(2) != 0 {
+		s.SetOption(vnet.OptReuseAddr, 1)
+	}
+	stack.PushInt32(vm.(*jvm.VM).Files().Add(s))
+	return nil
+}
+
+// private static native void bind0(FileDescriptor fd, boolean preferIPv6, boolean useExclBind, InetAddress addr, int port) throws IOException;
+func Net_bind0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	addr, err := java_net.InetAddressOf(vm, stack.GetVarRef(3))
+	if err != nil {
+		return err
+	}
+	if err := s.Bind(netip.AddrPortFrom(addr, (uint16)(stack.GetVarInt32(4)))); err != nil {
+		return socketException(err)
+	}
+	return nil
+}
+
+// static native void listen(FileDescriptor fd, int backlog) throws IOException;
+func Net_listen(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	if err := s.Listen((int)(stack.GetVarInt32(1))); err != nil {
+		return socketException(err)
+	}
+	return nil
+}
+
+// private static native int connect0(boolean preferIPv6, FileDescriptor fd, InetAddress remote, int remotePort) throws IOException;
+func Net_connect0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(1))
+	if err != nil {
+		return err
+	}
+	addr, err := java_net.InetAddressOf(vm, stack.GetVarRef(2))
+	if err != nil {
+		return err
+	}
+	raddr := netip.AddrPortFrom(addr, (uint16)(stack.GetVarInt32(3)))
+	_, err = blocking(vm, func() (int, error) { return 0, s.Connect(raddr) })
+	if err == syscall.EINPROGRESS {
+		stack.PushInt32(IOS_UNAVAILABLE)
+		return nil
+	}
+	if err != nil {
+		return socketException(err)
+	}
+	stack.PushInt32(1)
+	return nil
+}
+
+// static native int accept(FileDescriptor fd, FileDescriptor newfd, InetSocketAddress[] isaa) throws IOException;
+func Net_accept(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	newfd, isaa := stack.GetVarRef(1), stack.GetVarRef(2)
+	var ns *vnet.Socket
+	_, err = blocking(vm, func() (n int, err error) {
+		ns, err = s.Accept()
+		return
+	})
+	if errors.Is(err, syscall.EAGAIN) {
+		stack.PushInt32(IOS_UNAVAILABLE)
+		return nil
+	}
+	if err != nil {
+		return socketException(err)
+	}
+	isa, err := java_net.NewInetSocketAddress(vm, ns.RemoteAddr())
+	if err != nil {
+		ns.Close()
+		return err
+	}
+	helper.SetFD(newfd, vm.(*jvm.VM).Files().Add(ns))
+	isaa.GetRefArr()[0] = vm.RefToPtr(isa)
+	stack.PushInt32(1)
+	return nil
+}
+
+// static native void shutdown(FileDescriptor fd, int how) throws IOException;
+func Net_shutdown(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	how := stack.GetVarInt32(1)
+	err = s.Shutdown(how == SHUT_RD || how == SHUT_RDWR, how == SHUT_WR || how == SHUT_RDWR)
+	if err != nil && err != syscall.ENOTCONN {
+		return socketException(err)
+	}
+	return nil
+}
+
+// private static native int localPort(FileDescriptor fd) throws IOException;
+func Net_localPort(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(s.LocalAddr().Port()))
+	return nil
+}
+
+// private static native InetAddress localInetAddress(FileDescriptor fd) throws IOException;
+func Net_localInetAddress(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	ref, err := java_net.NewInetAddress(vm, s.LocalAddr().Addr(), "")
+	if err != nil {
+		return err
+	}
+	stack.PushRef(ref)
+	return nil
+}
+
+// private static native int remotePort(FileDescriptor fd) throws IOException;
+func Net_remotePort(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	raddr := s.RemoteAddr()
+	if !raddr.IsValid() {
+		return socketException(syscall.ENOTCONN)
+	}
+	stack.PushInt32((int32)(raddr.Port()))
+	return nil
+}
+
+// private static native InetAddress remoteInetAddress(FileDescriptor fd) throws IOException;
+func Net_remoteInetAddress(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	raddr := s.RemoteAddr()
+	if !raddr.IsValid() {
+		return socketException(syscall.ENOTCONN)
+	}
+	ref, err := java_net.NewInetAddress(vm, raddr.Addr(), "")
+	if err != nil {
+		return err
+	}
+	stack.PushRef(ref)
+	return nil
+}
+
+// private static native int getIntOption0(FileDescriptor fd, boolean mayNeedConversion, int level, int opt) throws IOException;
+func Net_getIntOption0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	opt, ok := socketOptions[sockOpt{stack.GetVarInt32(2), stack.GetVarInt32(3)}]
+	if !ok {
+		return socketException(syscall.ENOPROTOOPT)
+	}
+	// SO_LINGER is -1 if it is disabled, which is also how getIntOption0 reports it
+	value, err := s.GetOption(opt)
+	if err != nil {
+		return socketException(err)
+	}
+	stack.PushInt32((int32)(value))
+	return nil
+}
+
+// private static native void setIntOption0(FileDescriptor fd, boolean mayNeedConversion, int level, int opt, int arg, boolean isIPv6) throws IOException;
+func Net_setIntOption0(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	opt, ok := socketOptions[sockOpt{stack.GetVarInt32(2), stack.GetVarInt32(3)}]
+	if !ok {
+		return socketException(syscall.ENOPROTOOPT)
+	}
+	value := (int)(stack.GetVarInt32(4))
+	if opt == vnet.OptLinger && value < 0 {
+		value = -1
+	}
+	if err := s.SetOption(opt, value); err != nil {
+		return socketException(err)
+	}
+	return nil
+}
+
+// static native int poll(FileDescriptor fd, int events, long timeout) throws IOException;
+func Net_poll(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	p, ok := f.(vnet.Pollable)
+	if !ok {
+		// the files which cannot be polled, e.g. regular files, are always ready
+		stack.PushInt32(stack.GetVarInt32(1))
+		return nil
+	}
+	events := (uint32)(stack.GetVarInt32(1))
+	timeout := timeoutOf(stack.GetVarInt64(2))
+	ready, _ := blocking(vm, func() (int, error) {
+		return (int)(vnet.Wait(p, events, timeout)), nil
+	})
+	stack.PushInt32((int32)(ready))
+	return nil
+}
+
+// static native boolean pollConnect(FileDescriptor fd, long timeout) throws IOException;
+func Net_pollConnect(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	timeout := timeoutOf(stack.GetVarInt64(1))
+	ready, _ := blocking(vm, func() (int, error) {
+		return (int)(vnet.Wait(s, vnet.POLLOUT, timeout)), nil
+	})
+	if ready == 0 {
+		stack.PushInt32(0)
+		return nil
+	}
+	connected, err := s.FinishConnect()
+	if err != nil {
+		return socketException(err)
+	}
+	pushBool(stack, connected)
+	return nil
+}
+
+// static native int available(FileDescriptor fd) throws IOException;
+func Net_available(vm ir.VM) error {
+	stack := vm.GetStack()
+	s, err := fdSocket(vm, stack.GetVarRef(0))
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(s.Available()))
+	return nil
+}
+
+// static native int sendOOB(FileDescriptor fd, byte data) throws IOException;
+func Net_sendOOB(vm ir.VM) error {
+	// the transport has no urgent data
+	return socketException(syscall.EOPNOTSUPP)
+}
+
+// Net_unsupportedMembership implements joinOrDrop4, blockOrUnblock4, joinOrDrop6 and blockOrUnblock6,
+// IOS_UNAVAILABLE makes the callers throw UnsupportedOperationException since the transport has no multicast.
+func Net_unsupportedMembership(vm ir.VM) error {
+	vm.GetStack().PushInt32(IOS_UNAVAILABLE)
+	return nil
+}
+
+// static native void setInterface4(FileDescriptor fd, int interf) throws IOException;
+func Net_setInterface(vm ir.VM) error {
+	return nil
+}
+
+// static native int getInterface4(FileDescriptor fd) throws IOException;
+func Net_getInterface(vm ir.VM) error {
+	// any interface
+	vm.GetStack().PushInt32(0)
+	return nil
+}
+
+func Net_pollinValue(vm ir.VM) error {
+	vm.GetStack().PushInt32(vnet.POLLIN)
+	return nil
+}
+
+func Net_polloutValue(vm ir.VM) error {
+	vm.GetStack().PushInt32(vnet.POLLOUT)
+	return nil
+}
+
+func Net_pollerrValue(vm ir.VM) error {
+	vm.GetStack().PushInt32(vnet.POLLERR)
+	return nil
+}
+
+func Net_pollhupValue(vm ir.VM) error {
+	vm.GetStack().PushInt32(vnet.POLLHUP)
+	return nil
+}
+
+func Net_pollnvalValue(vm ir.VM) error {
+	vm.GetStack().PushInt32(vnet.POLLNVAL)
+	return nil
+}
+
+func Net_pollconnValue(vm ir.VM) error {
+	// the connection is established when the socket becomes writable, like on Linux
+	vm.GetStack().PushInt32(vnet.POLLOUT)
+	return nil
+}
@@ -0,0 +1,162 @@
+package sun_nio_ch
+
+import (
+	"io"
+	"sync"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+// pipe is the in-memory pipe of IOUtil.makePipe, which works without the pipes of the host, e.g. on wasm.
+// Writes never block, the buffer grows instead,
+// which is enough for its use of waking up selectors and Pipe.open.
+// Both ends are pollable, so the pipe can be registered to EPoll.
+type pipe struct {
+	vnet.Notifier
+
+	mux    sync.Mutex
+	cond   sync.Cond
+	buf    []byte
+	rdDone bool
+	wrDone bool
+}
+
+// nonblocker is implemented by the files which IOUtil.configureBlocking can switch to non-blocking mode,
+// where the operations which would block return EAGAIN.
+type nonblocker interface {
+	SetNonblock(nonblock bool)
+}
+
+func newPipe() (*pipeReader, *pipeWriter) {
+	p := new(pipe)
+	p.cond.L = &p.mux
+	return &pipeReader{p: p}, &pipeWriter{p: p}
+}
+
+// changed wakes the blocked reader and the watchers, the caller holds mux
+func (p *pipe) changed() {
+	p.cond.Broadcast()
+	p.Notify()
+}
+
+type pipeReader struct {
+	p        *pipe
+	nonblock bool
+}
+
+var (
+	_ nonblocker    = (*pipeReader)(nil)
+	_ vnet.Pollable = (*pipeReader)(nil)
+	_ vnet.Pollable = (*pipeWriter)(nil)
+)
+
+func (r *pipeReader) SetNonblock(nonblock bool) {
+	r.p.mux.Lock()
+	defer r.p.mux.Unlock()
+	r.nonblock = nonblock
+}
+
+// Len returns the number of buffered bytes
+func (r *pipeReader) Len() int {
+	r.p.mux.Lock()
+	defer r.p.mux.Unlock()
+	return len(r.p.buf)
+}
+
+func (r *pipeReader) Read(b []byte) (int, error) {
+	p := r.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for len(p.buf) == 0 {
+		if p.rdDone {
+			return 0, syscall.EBADF
+		}
+		if p.wrDone {
+			return 0, io.EOF
+		}
+		if r.nonblock {
+			return 0, syscall.EAGAIN
+		}
+		p.cond.Wait()
+	}
+	n := copy(b, p.buf)
+	p.buf = p.buf[n:]
+	return n, nil
+}
+
+func (r *pipeReader) Poll() uint32 {
+	p := r.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	switch {
+	case p.rdDone:
+		return vnet.POLLNVAL
+	case p.wrDone:
+		return vnet.POLLIN | vnet.POLLHUP
+	case len(p.buf) > 0:
+		return vnet.POLLIN
+	}
+	return 0
+}
+
+func (r *pipeReader) Watch(ch chan<- struct{})   { r.p.Watch(ch) }
+func (r *pipeReader) Unwatch(ch chan<- struct{}) { r.p.Unwatch(ch) }
+
+func (r *pipeReader) Close() error {
+	p := r.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.rdDone = true
+	p.buf = nil
+	p.changed()
+	return nil
+}
+
+// pipeWriter never blocks, so it is the same in non-blocking mode
+type pipeWriter struct {
+	p *pipe
+}
+
+func (w *pipeWriter) Write(b []byte) (int, error) {
+	p := w.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.rdDone {
+		return 0, syscall.EPIPE
+	}
+	if p.wrDone {
+		return 0, syscall.EBADF
+	}
+	p.buf = append(p.buf, b...)
+	p.changed()
+	return len(b), nil
+}
+
+func (w *pipeWriter) Poll() uint32 {
+	p := w.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	switch {
+	case p.wrDone:
+		return vnet.POLLNVAL
+	case p.rdDone:
+		return vnet.POLLOUT | vnet.POLLERR
+	}
+	return vnet.POLLOUT
+}
+
+func (w *pipeWriter) Watch(ch chan<- struct{})   { w.p.Watch(ch) }
+func (w *pipeWriter) Unwatch(ch chan<- struct{}) { w.p.Unwatch(ch) }
+
+func (w *pipeWriter) Close() error {
+	p := w.p
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	p.wrDone = true
+	p.changed()
+	return nil
+}
@@ -0,0 +1,249 @@
+package sun_nio_ch
+
+import (
+	"errors"
+	"io"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func init() {
+	native.RegisterDefaultNative("sun/nio/ch/SocketDispatcher.read0(Ljava/io/FileDescriptor;JI)I", SocketDispatcher_read0)
+	native.RegisterDefaultNative("sun/nio/ch/SocketDispatcher.readv0(Ljava/io/FileDescriptor;JI)J", SocketDispatcher_readv0)
+	native.RegisterDefaultNative("sun/nio/ch/SocketDispatcher.write0(Ljava/io/FileDescriptor;JI)I", SocketDispatcher_write0)
+	native.RegisterDefaultNative("sun/nio/ch/SocketDispatcher.writev0(Ljava/io/FileDescriptor;JI)J", SocketDispatcher_writev0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramDispatcher.read0(Ljava/io/FileDescriptor;JI)I", DatagramDispatcher_read0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramDispatcher.readv0(Ljava/io/FileDescriptor;JI)J", DatagramDispatcher_readv0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramDispatcher.write0(Ljava/io/FileDescriptor;JI)I", DatagramDispatcher_write0)
+	native.RegisterDefaultNative("sun/nio/ch/DatagramDispatcher.writev0(Ljava/io/FileDescriptor;JI)J", DatagramDispatcher_writev0)
+	// the natives are declared by FileDispatcherImpl on the class libraries without UnixDispatcher
+	for _, cls := range []string{"sun/nio/ch/UnixDispatcher", "sun/nio/ch/FileDispatcherImpl"} {
+		native.RegisterDefaultNative(cls+".close0(Ljava/io/FileDescriptor;)V", UnixDispatcher_close0)
+		native.RegisterDefaultNative(cls+".preClose0(Ljava/io/FileDescriptor;)V", UnixDispatcher_preClose0)
+	}
+	native.RegisterDefaultNative("sun/nio/ch/UnixDispatcher.init()V", UnixDispatcher_init)
+}
+
+// readSocket reads from the socket at most once, so a blocking read does not wait for more than the available bytes.
+// The vectors are filled in order, and a datagram is received as a whole.
+func readSocket(vm ir.VM, fdObj ir.Ref, vecs []iovec) (int, error) {
+	s, err := fdSocket(vm, fdObj)
+	if err != nil {
+		return 0, err
+	}
+	size := 0
+	for _, v := range vecs {
+		size += (int)(v.len)
+	}
+	var buf []byte
+	if len(vecs) == 1 {
+		buf = cutil.Bytes((int64)(vecs[0].base), (int)(vecs[0].len))
+	} else {
+		buf = make([]byte, size)
+	}
+	n, err := blocking(vm, func() (int, error) { return s.Read(buf) })
+	if len(vecs) > 1 {
+		rest := buf[:n]
+		for _, v := range vecs {
+			rest = rest[copy(cutil.Bytes((int64)(v.base), (int)(v.len)), rest):]
+		}
+	}
+	return n, err
+}
+
+// writeSocket writes the vectors with a single write, so a datagram is not split
+func writeSocket(vm ir.VM, fdObj ir.Ref, vecs []iovec) (int, error) {
+	s, err := fdSocket(vm, fdObj)
+	if err != nil {
+		return 0, err
+	}
+	var buf []byte
+	if len(vecs) == 1 {
+		buf = cutil.Bytes((int64)(vecs[0].base), (int)(vecs[0].len))
+	} else {
+		for _, v := range vecs {
+			buf = append(buf, cutil.Bytes((int64)(v.base), (int)(v.len))...)
+		}
+	}
+	return blocking(vm, func() (int, error) { return s.Write(buf) })
+}
+
+// socketReturn converts the result of a socket read or write like convertReturn,
+// the errors are thrown with the exception of exc if they are not the special ones.
+func socketReturn(n int, err error, reading bool, exc func(error) error) (int64, error) {
+	if n > 0 || err == nil {
+		return (int64)(n), nil
+	}
+	if reading && err == io.EOF {
+		return IOS_EOF, nil
+	}
+	if errors.Is(err, syscall.EAGAIN) {
+		return IOS_UNAVAILABLE, nil
+	}
+	return 0, exc(err)
+}
+
+func streamException(err error) error {
+	if errors.Is(err, syscall.ECONNRESET) {
+		return errs.Throw("sun/net/ConnectionResetException", "Connection reset")
+	}
+	if errors.Is(err, syscall.EPIPE) {
+		return errs.Throw("java/io/IOException", "Broken pipe")
+	}
+	return helper.IOException(err)
+}
+
+func datagramException(err error) error {
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return errs.Throw("java/net/PortUnreachableException", "ICMP Port Unreachable")
+	}
+	return helper.IOException(err)
+}
+
+func singleVec(stack ir.Stack) []iovec {
+	return []iovec{{base: (uintptr)(stack.GetVarInt64(1)), len: (uintptr)(stack.GetVarInt32(3))}}
+}
+
+// private static native int read0(FileDescriptor fd, long address, int len) throws IOException;
+func SocketDispatcher_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := readSocket(vm, stack.GetVarRef(0), singleVec(stack))
+	res, err := socketReturn(n, err, true, streamException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// private static native long readv0(FileDescriptor fd, long address, int len) throws IOException;
+func SocketDispatcher_readv0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := readSocket(vm, stack.GetVarRef(0), iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3)))
+	res, err := socketReturn(n, err, true, streamException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+// static native int write0(FileDescriptor fd, long address, int len) throws IOException;
+func SocketDispatcher_write0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := writeSocket(vm, stack.GetVarRef(0), singleVec(stack))
+	res, err := socketReturn(n, err, false, streamException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// static native long writev0(FileDescriptor fd, long address, int len) throws IOException;
+func SocketDispatcher_writev0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := writeSocket(vm, stack.GetVarRef(0), iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3)))
+	res, err := socketReturn(n, err, false, streamException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+// private static native int read0(FileDescriptor fd, long address, int len) throws IOException;
+func DatagramDispatcher_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := readSocket(vm, stack.GetVarRef(0), singleVec(stack))
+	res, err := socketReturn(n, err, true, datagramException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// private static native long readv0(FileDescriptor fd, long address, int len) throws IOException;
+func DatagramDispatcher_readv0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := readSocket(vm, stack.GetVarRef(0), iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3)))
+	res, err := socketReturn(n, err, true, datagramException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+// private static native int write0(FileDescriptor fd, long address, int len) throws IOException;
+func DatagramDispatcher_write0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := writeSocket(vm, stack.GetVarRef(0), singleVec(stack))
+	res, err := socketReturn(n, err, false, datagramException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt32((int32)(res))
+	return nil
+}
+
+// private static native long writev0(FileDescriptor fd, long address, int len) throws IOException;
+func DatagramDispatcher_writev0(vm ir.VM) error {
+	stack := vm.GetStack()
+	n, err := writeSocket(vm, stack.GetVarRef(0), iovecs(stack.GetVarInt64(1), stack.GetVarInt32(3)))
+	res, err := socketReturn(n, err, false, datagramException)
+	if err != nil {
+		return err
+	}
+	stack.PushInt64(res)
+	return nil
+}
+
+func UnixDispatcher_init(vm ir.VM) error {
+	return nil
+}
+
+// static native void close0(FileDescriptor fd) throws IOException;
+func UnixDispatcher_close0(vm ir.VM) error {
+	fdObj := vm.GetStack().GetVarRef(0)
+	if fdObj == nil {
+		return errs.NullPointerException
+	}
+	fd := helper.GetFD(fdObj)
+	if fd == -1 {
+		return nil
+	}
+	helper.SetFD(fdObj, -1)
+	if err := vm.(*jvm.VM).Files().Close(fd); err != nil {
+		return helper.IOException(err)
+	}
+	return nil
+}
+
+// static native void preClose0(FileDescriptor fd) throws IOException;
+//
+// The JDK replaces the descriptor with a closed socket to wake up the blocked threads,
+// the pollable files are closed here instead, which wakes them up with EBADF.
+// The descriptor is released by close0 later.
+func UnixDispatcher_preClose0(vm ir.VM) error {
+	fdObj := vm.GetStack().GetVarRef(0)
+	if fdObj == nil {
+		return errs.NullPointerException
+	}
+	f, err := vm.(*jvm.VM).Files().Get(helper.GetFD(fdObj))
+	if err != nil {
+		return nil
+	}
+	if _, ok := f.(vnet.Pollable); ok {
+		f.Close()
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package sun_nio_fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"syscall"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+// The errno values of Linux, which sun.nio.fs.UnixConstants is generated with
+const (
+	EPERM        = 1
+	ENOENT       = 2
+	ESRCH        = 3
+	EINTR        = 4
+	EIO          = 5
+	ENXIO        = 6
+	E2BIG        = 7
+	ENOEXEC      = 8
+	EBADF        = 9
+	ECHILD       = 10
+	EAGAIN       = 11
+	ENOMEM       = 12
+	EACCES       = 13
+	EFAULT       = 14
+	EBUSY        = 16
+	EEXIST       = 17
+	EXDEV        = 18
+	ENODEV       = 19
+	ENOTDIR      = 20
+	EISDIR       = 21
+	EINVAL       = 22
+	ENFILE       = 23
+	EMFILE       = 24
+	ENOTTY       = 25
+	EFBIG        = 27
+	ENOSPC       = 28
+	ESPIPE       = 29
+	EROFS        = 30
+	EMLINK       = 31
+	EPIPE        = 32
+	ERANGE       = 34
+	ENAMETOOLONG = 36
+	ENOSYS       = 38
+	ENOTEMPTY    = 39
+	ELOOP        = 40
+	ENODATA      = 61
+	EOVERFLOW    = 75
+	ENOTSUP      = 95
+)
+
+var (
+	// linuxErrnos maps the errno of the host to the one of Linux
+	linuxErrnos = make(map[syscall.Errno]int32)
+	// hostErrnos maps the errno of Linux to the one of the host for the messages
+	hostErrnos = make(map[int32]syscall.Errno)
+)
+
+func init() {
+	// the errno values are not distinct on every platform, e.g. vfs.ENOATTR is ENOENT on wasip1, so the first one wins
+	for _, e := range []struct {
+		host  syscall.Errno
+		linux int32
+	}{
+		{syscall.EPERM, EPERM},
+		{syscall.ENOENT, ENOENT},
+		{syscall.ESRCH, ESRCH},
+		{syscall.EINTR, EINTR},
+		{syscall.EIO, EIO},
+		{syscall.ENXIO, ENXIO},
+		{syscall.E2BIG, E2BIG},
+		{syscall.ENOEXEC, ENOEXEC},
+		{syscall.EBADF, EBADF},
+		{syscall.ECHILD, ECHILD},
+		{syscall.EAGAIN, EAGAIN},
+		{syscall.ENOMEM, ENOMEM},
+		{syscall.EACCES, EACCES},
+		{syscall.EFAULT, EFAULT},
+		{syscall.EBUSY, EBUSY},
+		{syscall.EEXIST, EEXIST},
+		{syscall.EXDEV, EXDEV},
+		{syscall.ENODEV, ENODEV},
+		{syscall.ENOTDIR, ENOTDIR},
+		{syscall.EISDIR, EISDIR},
+		{syscall.EINVAL, EINVAL},
+		{syscall.ENFILE, ENFILE},
+		{syscall.EMFILE, EMFILE},
+		{syscall.ENOTTY, ENOTTY},
+		{syscall.EFBIG, EFBIG},
+		{syscall.ENOSPC, ENOSPC},
+		{syscall.ESPIPE, ESPIPE},
+		{syscall.EROFS, EROFS},
+		{syscall.EMLINK, EMLINK},
+		{syscall.EPIPE, EPIPE},
+		{syscall.ERANGE, ERANGE},
+		{syscall.ENAMETOOLONG, ENAMETOOLONG},
+		{syscall.ENOSYS, ENOSYS},
+		{syscall.ENOTEMPTY, ENOTEMPTY},
+		{syscall.ELOOP, ELOOP},
+		{syscall.EOVERFLOW, EOVERFLOW},
+		{syscall.ENOTSUP, ENOTSUP},
+		{syscall.EOPNOTSUPP, ENOTSUP},
+		{vfs.ENOATTR, ENODATA},
+	} {
+		if _, ok := linuxErrnos[e.host]; !ok {
+			linuxErrnos[e.host] = e.linux
+		}
+		if _, ok := hostErrnos[e.linux]; !ok {
+			hostErrnos[e.linux] = e.host
+		}
+	}
+}
+
+// errnoOf returns the errno of Linux which the error represents
+func errnoOf(err error) int32 {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if e, ok := linuxErrnos[errno]; ok {
+			return e
+		}
+		return EIO
+	}
+	switch {
+	case errors.Is(err, jvm.ErrBadFD), errors.Is(err, fs.ErrClosed):
+		return EBADF
+	case errors.Is(err, fs.ErrNotExist):
+		return ENOENT
+	case errors.Is(err, fs.ErrExist):
+		return EEXIST
+	case errors.Is(err, fs.ErrPermission):
+		return EACCES
+	case errors.Is(err, fs.ErrInvalid):
+		return EINVAL
+	}
+	return EIO
+}
+
+// strerror returns the message of the errno of Linux like strerror(3)
+func strerror(errno int32) string {
+	e, ok := hostErrnos[errno]
+	if !ok {
+		return fmt.Sprintf("Unknown error %d", errno)
+	}
+	return helper.Strerror(e)
+}
+
+// throwUnixException throws sun.nio.fs.UnixException with the errno of the error
+func throwUnixException(vm ir.VM, err error) error {
+	return throwErrno(vm, errnoOf(err))
+}
+
+func throwErrno(vm ir.VM, errno int32) error {
+	cls, err := vm.GetClassByName("sun/nio/fs/UnixException")
+	if err != nil {
+		return err
+	}
+	ref := vm.New(cls)
+	stack := vm.GetStack()
+	stack.PushRef(ref)
+	stack.PushInt32(errno)
+	vm.Invoke(cls.GetMethodByNameAndType("<init>", "(I)V"))
+	if err := vm.RunStack(); err != nil {
+		return err
+	}
+	vm.Throw(ref)
+	return nil
+}
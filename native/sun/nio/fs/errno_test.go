@@ -0,0 +1,62 @@
+package sun_nio_fs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+)
+
+func TestErrnoOf(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want int32
+	}{
+		{syscall.ENOENT, ENOENT},
+		{syscall.EACCES, EACCES},
+		{syscall.ENOTEMPTY, ENOTEMPTY},
+		{syscall.EOPNOTSUPP, ENOTSUP},
+		{vfs.ENOATTR, ENODATA},
+		{&fs.PathError{Op: "open", Path: "/a", Err: syscall.EEXIST}, EEXIST},
+		{&os.LinkError{Op: "rename", Old: "/a", New: "/b", Err: syscall.EXDEV}, EXDEV},
+		{fmt.Errorf("wrapped: %w", syscall.ENOTDIR), ENOTDIR},
+		{syscall.Errno(0xffff), EIO},
+		{jvm.ErrBadFD, EBADF},
+		{fs.ErrClosed, EBADF},
+		{fs.ErrNotExist, ENOENT},
+		{fs.ErrExist, EEXIST},
+		{fs.ErrPermission, EACCES},
+		{fs.ErrInvalid, EINVAL},
+		{&fs.PathError{Op: "stat", Path: "/a", Err: fs.ErrNotExist}, ENOENT},
+		{errors.New("unknown"), EIO},
+	} {
+		if got := errnoOf(tt.err); got != tt.want {
+			t.Errorf("errnoOf(%#v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestStrerror(t *testing.T) {
+	for _, tt := range []struct {
+		errno int32
+		want  string
+	}{
+		{ENOENT, "No such file or directory"},
+		{EACCES, "Permission denied"},
+		{EEXIST, "File exists"},
+		{ENOTDIR, "Not a directory"},
+		{ENOTEMPTY, "Directory not empty"},
+		{ELOOP, "Too many levels of symbolic links"},
+		{0xffff, "Unknown error 65535"},
+		{-1, "Unknown error -1"},
+	} {
+		if got := strerror(tt.errno); got != tt.want {
+			t.Errorf("strerror(%d) = %q, want %q", tt.errno, got, tt.want)
+		}
+	}
+}
@@ -1,159 +1,533 @@
 package sun_nio_fs
 
 import (
-	"errors"
+	"io"
 	"io/fs"
 	"os"
+	"os/user"
+	"path"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/LiterMC/wasm-jdk/cutil"
 	"github.com/LiterMC/wasm-jdk/desc"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/native"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
 )
 
 func init() {
 	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getcwd()[B", UnixNativeDispatcher_getcwd)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.dup(I)I", UnixNativeDispatcher_dup)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.open0(JII)I", UnixNativeDispatcher_open0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.openat0(IJII)I", UnixNativeDispatcher_openat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.close0(I)V", UnixNativeDispatcher_close0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rewind(J)V", UnixNativeDispatcher_rewind)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getlinelen(J)I", UnixNativeDispatcher_getlinelen)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.link0(JJ)V", UnixNativeDispatcher_link0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.unlink0(J)V", UnixNativeDispatcher_unlink0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.unlinkat0(IJI)V", UnixNativeDispatcher_unlinkat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.mknod0(JIJ)V", UnixNativeDispatcher_mknod0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rename0(JJ)V", UnixNativeDispatcher_rename0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.renameat0(IJIJ)V", UnixNativeDispatcher_renameat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.mkdir0(JI)V", UnixNativeDispatcher_mkdir0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rmdir0(J)V", UnixNativeDispatcher_rmdir0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.readlink0(J)[B", UnixNativeDispatcher_readlink0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.realpath0(J)[B", UnixNativeDispatcher_realpath0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.symlink0(JJ)V", UnixNativeDispatcher_symlink0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.dup(I)I", UnixNativeDispatcher_dup)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.open0(JII)I", UnixNativeDispatcher_open0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.openat0(IJII)I", UnixNativeDispatcher_openat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.close0(I)V", UnixNativeDispatcher_close0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rewind(J)V", UnixNativeDispatcher_rewind)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getlinelen(J)I", UnixNativeDispatcher_getlinelen)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.link0(JJ)V", UnixNativeDispatcher_link0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.unlink0(J)V", UnixNativeDispatcher_unlink0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.unlinkat0(IJI)V", UnixNativeDispatcher_unlinkat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.mknod0(JIJ)V", UnixNativeDispatcher_mknod0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rename0(JJ)V", UnixNativeDispatcher_rename0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.renameat0(IJIJ)V", UnixNativeDispatcher_renameat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.mkdir0(JI)V", UnixNativeDispatcher_mkdir0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.rmdir0(J)V", UnixNativeDispatcher_rmdir0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.readlink0(J)[B", UnixNativeDispatcher_readlink0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.realpath0(J)[B", UnixNativeDispatcher_realpath0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.symlink0(JJ)V", UnixNativeDispatcher_symlink0)
 	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.stat0(JLsun/nio/fs/UnixFileAttributes;)I", UnixNativeDispatcher_stat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lstat0(JLsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_lstat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fstat0(ILsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_fstat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fstatat0(IJILsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_fstatat0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.chown0(JII)V", UnixNativeDispatcher_chown0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lchown0(JII)V", UnixNativeDispatcher_lchown0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fchown0(III)V", UnixNativeDispatcher_fchown0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.chmod0(JI)V", UnixNativeDispatcher_chmod0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fchmod0(II)V", UnixNativeDispatcher_fchmod0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.utimes0(JJJ)V", UnixNativeDispatcher_utimes0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.futimes0(IJJ)V", UnixNativeDispatcher_futimes0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.futimens0(IJJ)V", UnixNativeDispatcher_futimens0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lutimes0(JJJ)V", UnixNativeDispatcher_lutimes0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.opendir0(J)J", UnixNativeDispatcher_opendir0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fdopendir(I)J", UnixNativeDispatcher_fdopendir)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.closedir(J)V", UnixNativeDispatcher_closedir)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.readdir0(J)[B", UnixNativeDispatcher_readdir0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.read0(IJI)I", UnixNativeDispatcher_read0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.write0(IJI)I", UnixNativeDispatcher_write0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.access0(JI)I", UnixNativeDispatcher_access0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getpwuid(I)[B", UnixNativeDispatcher_getpwuid)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getgrgid(I)[B", UnixNativeDispatcher_getgrgid)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getpwnam0(J)I", UnixNativeDispatcher_getpwnam0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getgrnam0(J)I", UnixNativeDispatcher_getgrnam0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.statvfs0(JLsun/nio/fs/UnixFileStoreAttributes;)V", UnixNativeDispatcher_statvfs0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.strerror(I)[B", UnixNativeDispatcher_strerror)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fgetxattr0(IJJI)I", UnixNativeDispatcher_fgetxattr0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fsetxattr0(IJJI)V", UnixNativeDispatcher_fsetxattr0)
-	// native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fremovexattr0(IJ)V", UnixNativeDispatcher_fremovexattr0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lstat0(JLsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_lstat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fstat0(ILsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_fstat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fstatat0(IJILsun/nio/fs/UnixFileAttributes;)V", UnixNativeDispatcher_fstatat0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.chown0(JII)V", UnixNativeDispatcher_chown0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lchown0(JII)V", UnixNativeDispatcher_lchown0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fchown0(III)V", UnixNativeDispatcher_fchown0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.chmod0(JI)V", UnixNativeDispatcher_chmod0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fchmod0(II)V", UnixNativeDispatcher_fchmod0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.utimes0(JJJ)V", UnixNativeDispatcher_utimes0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.futimes0(IJJ)V", UnixNativeDispatcher_futimes0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.futimens0(IJJ)V", UnixNativeDispatcher_futimens0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.lutimes0(JJJ)V", UnixNativeDispatcher_lutimes0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.opendir0(J)J", UnixNativeDispatcher_opendir0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fdopendir(I)J", UnixNativeDispatcher_fdopendir)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.closedir(J)V", UnixNativeDispatcher_closedir)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.readdir0(J)[B", UnixNativeDispatcher_readdir0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.read0(IJI)I", UnixNativeDispatcher_read0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.write0(IJI)I", UnixNativeDispatcher_write0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.access0(JI)I", UnixNativeDispatcher_access0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getpwuid(I)[B", UnixNativeDispatcher_getpwuid)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getgrgid(I)[B", UnixNativeDispatcher_getgrgid)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getpwnam0(J)I", UnixNativeDispatcher_getpwnam0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.getgrnam0(J)I", UnixNativeDispatcher_getgrnam0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.statvfs0(JLsun/nio/fs/UnixFileStoreAttributes;)V", UnixNativeDispatcher_statvfs0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.strerror(I)[B", UnixNativeDispatcher_strerror)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fgetxattr0(IJJI)I", UnixNativeDispatcher_fgetxattr0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fsetxattr0(IJJI)V", UnixNativeDispatcher_fsetxattr0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.fremovexattr0(IJ)V", UnixNativeDispatcher_fremovexattr0)
+	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.flistxattr(IJI)I", UnixNativeDispatcher_flistxattr)
 	native.RegisterDefaultNative("sun/nio/fs/UnixNativeDispatcher.init()I", UnixNativeDispatcher_init)
 }
 
+// The flags of Linux x86_64, which sun.nio.fs.UnixConstants is generated with
+const (
+	O_ACCMODE   = 03
+	O_RDONLY    = 00
+	O_WRONLY    = 01
+	O_RDWR      = 02
+	O_CREAT     = 0100
+	O_EXCL      = 0200
+	O_TRUNC     = 01000
+	O_APPEND    = 02000
+	O_DSYNC     = 010000
+	O_DIRECTORY = 0200000
+	O_NOFOLLOW  = 0400000
+	O_SYNC      = 04010000
+
+	AT_FDCWD            = -100
+	AT_SYMLINK_NOFOLLOW = 0x100
+	AT_REMOVEDIR        = 0x200
+
+	S_IFMT   = 0170000
+	S_IFSOCK = 0140000
+	S_IFLNK  = 0120000
+	S_IFREG  = 0100000
+	S_IFBLK  = 0060000
+	S_IFDIR  = 0040000
+	S_IFCHR  = 0020000
+	S_IFIFO  = 0010000
+
+	S_ISUID = 04000
+	S_ISGID = 02000
+	S_ISVTX = 01000
+)
+
+// The capabilities returned by init
+const (
+	SUPPORTS_OPENAT    = 1 << 1
+	SUPPORTS_FUTIMES   = 1 << 2
+	SUPPORTS_FUTIMENS  = 1 << 3
+	SUPPORTS_LUTIMES   = 1 << 4
+	SUPPORTS_XATTR     = 1 << 5
+	SUPPORTS_BIRTHTIME = 1 << 16
+)
+
+func getVFS(vm ir.VM) vfs.FS {
+	return vm.(*jvm.VM).FS()
+}
+
+func newByteArray(vm ir.VM, s string) ir.Ref {
+	arr := vm.NewArray(desc.DescByteArray, (int32)(len(s)))
+	copy(arr.GetByteArr(), s)
+	return arr
+}
+
+// fdName returns the name which the descriptor is opened with,
+// the operations on descriptors are done through the filesystem with it.
+func fdName(vm ir.VM, fd int32) (string, error) {
+	f, err := vm.(*jvm.VM).Files().Get(fd)
+	if err != nil {
+		return "", err
+	}
+	named, ok := f.(interface{ Name() string })
+	if !ok {
+		return "", syscall.EBADF
+	}
+	return named.Name(), nil
+}
+
+// fdFile returns the open file of the descriptor, the standard streams are not files of the filesystem
+func fdFile(vm ir.VM, fd int32) (vfs.File, error) {
+	f, err := vm.(*jvm.VM).Files().Get(fd)
+	if err != nil {
+		return nil, err
+	}
+	file, ok := f.(vfs.File)
+	if !ok {
+		return nil, syscall.ENOTSUP
+	}
+	return file, nil
+}
+
+// atPath resolves the path against the directory descriptor like the *at syscalls
+func atPath(vm ir.VM, dfd int32, p string) (string, error) {
+	if dfd == AT_FDCWD || path.IsAbs(p) {
+		return p, nil
+	}
+	dir, err := fdName(vm, dfd)
+	if err != nil {
+		return "", err
+	}
+	return path.Join(dir, p), nil
+}
+
+// openFile opens the file with the open(2) flags of Linux, and returns the descriptor
+func openFile(vm ir.VM, name string, flags int32, mode int32) (int32, error) {
+	fsys := getVFS(vm)
+	if flags&O_NOFOLLOW != 0 {
+		// the filesystems always follow links, so the last element is checked before opening
+		if stat, err := fsys.Lstat(name); err == nil && stat.Mode().Type() == fs.ModeSymlink {
+			return 0, syscall.ELOOP
+		}
+	}
+	var flag int
+	switch flags & O_ACCMODE {
+	case O_WRONLY:
+		flag = os.O_WRONLY
+	case O_RDWR:
+		flag = os.O_RDWR
+	default:
+		flag = os.O_RDONLY
+	}
+	if flags&O_CREAT != 0 {
+		flag |= os.O_CREATE
+	}
+	if flags&O_EXCL != 0 {
+		flag |= os.O_EXCL
+	}
+	if flags&O_TRUNC != 0 {
+		flag |= os.O_TRUNC
+	}
+	if flags&O_APPEND != 0 {
+		flag |= os.O_APPEND
+	}
+	if flags&O_DSYNC != 0 {
+		flag |= os.O_SYNC
+	}
+	f, err := fsys.OpenFile(name, flag, stModeToFileMode(mode))
+	if err != nil {
+		return 0, err
+	}
+	if flags&O_DIRECTORY != 0 {
+		stat, err := f.Stat()
+		if err == nil && !stat.IsDir() {
+			err = syscall.ENOTDIR
+		}
+		if err != nil {
+			f.Close()
+			return 0, err
+		}
+	}
+	return vm.(*jvm.VM).Files().Add(f), nil
+}
+
 // static native byte[] getcwd();
 func UnixNativeDispatcher_getcwd(vm ir.VM) error {
 	stack := vm.GetStack()
-	path, err := os.Getwd()
+	path, err := vfs.Getwd(getVFS(vm))
 	if err != nil {
 		stack.PushRef(nil)
 		return nil
 	}
-	pathRef := vm.NewArray(desc.DescByteArray, (int32)(len(path)))
-	copy(pathRef.GetByteArr(), path)
-	stack.PushRef(pathRef)
+	stack.PushRef(newByteArray(vm, path))
 	return nil
 }
 
 // static native int dup(int filedes) throws UnixException;
+func UnixNativeDispatcher_dup(vm ir.VM) error {
+	stack := vm.GetStack()
+	fd, err := vm.(*jvm.VM).Files().Dup(stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt32(fd)
+	return nil
+}
+
 // private static native int open0(long pathAddress, int flags, int mode) throws UnixException;
+func UnixNativeDispatcher_open0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	fd, err := openFile(vm, name, stack.GetVarInt32(2), stack.GetVarInt32(3))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt32(fd)
+	return nil
+}
+
 // private static native int openat0(int dfd, long pathAddress, int flags, int mode) throws UnixException;
+func UnixNativeDispatcher_openat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name, err := atPath(vm, stack.GetVarInt32(0), cutil.GoString(stack.GetVarInt64(1)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	fd, err := openFile(vm, name, stack.GetVarInt32(3), stack.GetVarInt32(4))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt32(fd)
+	return nil
+}
+
 // private static native void close0(int fd) throws UnixException;
+func UnixNativeDispatcher_close0(vm ir.VM) error {
+	if err := vm.(*jvm.VM).Files().Close(vm.GetStack().GetVarInt32(0)); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
+// The DIR and FILE streams are the descriptors plus one, since 0 is NULL for Java
+func streamOf(fd int32) int64 {
+	return (int64)(fd) + 1
+}
+
+func streamFD(stream int64) int32 {
+	return (int32)(stream - 1)
+}
+
 // static native void rewind(long stream) throws UnixException;
+func UnixNativeDispatcher_rewind(vm ir.VM) error {
+	f, err := vm.(*jvm.VM).Files().Get(streamFD(vm.GetStack().GetVarInt64(0)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	s, ok := f.(io.Seeker)
+	if !ok {
+		return throwErrno(vm, ESPIPE)
+	}
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // static native int getlinelen(long stream) throws UnixException;
+func UnixNativeDispatcher_getlinelen(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(streamFD(stack.GetVarInt64(0)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	r, ok := f.(io.Reader)
+	if !ok {
+		return throwErrno(vm, EBADF)
+	}
+	// the length includes the newline like getline(3), and it is -1 at the end of the stream
+	var (
+		b [1]byte
+		n int32
+	)
+	for {
+		k, err := r.Read(b[:])
+		if k > 0 {
+			n++
+			if b[0] == '\n' {
+				break
+			}
+			continue
+		}
+		if err == io.EOF {
+			if n == 0 {
+				n = -1
+			}
+			break
+		}
+		if err != nil {
+			return throwUnixException(vm, err)
+		}
+	}
+	stack.PushInt32(n)
+	return nil
+}
+
 // private static native void link0(long existingAddress, long newAddress) throws UnixException;
+func UnixNativeDispatcher_link0(vm ir.VM) error {
+	stack := vm.GetStack()
+	existing := cutil.GoString(stack.GetVarInt64(0))
+	newName := cutil.GoString(stack.GetVarInt64(2))
+	if err := getVFS(vm).Link(existing, newName); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void unlink0(long pathAddress) throws UnixException;
+func UnixNativeDispatcher_unlink0(vm ir.VM) error {
+	name := cutil.GoString(vm.GetStack().GetVarInt64(0))
+	if err := getVFS(vm).Unlink(name); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void unlinkat0(int dfd, long pathAddress, int flag) throws UnixException;
+func UnixNativeDispatcher_unlinkat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name, err := atPath(vm, stack.GetVarInt32(0), cutil.GoString(stack.GetVarInt64(1)))
+	if err == nil {
+		if stack.GetVarInt32(3)&AT_REMOVEDIR != 0 {
+			err = getVFS(vm).Rmdir(name)
+		} else {
+			err = getVFS(vm).Unlink(name)
+		}
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void mknod0(long pathAddress, int mode, long dev) throws UnixException;
+func UnixNativeDispatcher_mknod0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	mode := stack.GetVarInt32(2)
+	switch mode & S_IFMT {
+	case 0, S_IFREG:
+		f, err := getVFS(vm).OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, stModeToFileMode(mode))
+		if err == nil {
+			err = f.Close()
+		}
+		if err != nil {
+			return throwUnixException(vm, err)
+		}
+		return nil
+	default:
+		// the filesystems only have regular files, directories and symbolic links
+		return throwErrno(vm, EPERM)
+	}
+}
+
 // private static native void rename0(long fromAddress, long toAddress) throws UnixException;
+func UnixNativeDispatcher_rename0(vm ir.VM) error {
+	stack := vm.GetStack()
+	from := cutil.GoString(stack.GetVarInt64(0))
+	to := cutil.GoString(stack.GetVarInt64(2))
+	if err := getVFS(vm).Rename(from, to); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void renameat0(int fromfd, long fromAddress, int tofd, long toAddress) throws UnixException;
+func UnixNativeDispatcher_renameat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	from, err := atPath(vm, stack.GetVarInt32(0), cutil.GoString(stack.GetVarInt64(1)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	to, err := atPath(vm, stack.GetVarInt32(3), cutil.GoString(stack.GetVarInt64(4)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	if err := getVFS(vm).Rename(from, to); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void mkdir0(long pathAddress, int mode) throws UnixException;
+func UnixNativeDispatcher_mkdir0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	if err := getVFS(vm).Mkdir(name, stModeToFileMode(stack.GetVarInt32(2))); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void rmdir0(long pathAddress) throws UnixException;
+func UnixNativeDispatcher_rmdir0(vm ir.VM) error {
+	name := cutil.GoString(vm.GetStack().GetVarInt64(0))
+	if err := getVFS(vm).Rmdir(name); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native byte[] readlink0(long pathAddress) throws UnixException;
+func UnixNativeDispatcher_readlink0(vm ir.VM) error {
+	stack := vm.GetStack()
+	target, err := getVFS(vm).Readlink(cutil.GoString(stack.GetVarInt64(0)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushRef(newByteArray(vm, target))
+	return nil
+}
+
 // private static native byte[] realpath0(long pathAddress) throws UnixException;
+func UnixNativeDispatcher_realpath0(vm ir.VM) error {
+	stack := vm.GetStack()
+	resolved, err := vfs.Realpath(getVFS(vm), cutil.GoString(stack.GetVarInt64(0)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushRef(newByteArray(vm, resolved))
+	return nil
+}
+
 // private static native void symlink0(long name1, long name2) throws UnixException;
+func UnixNativeDispatcher_symlink0(vm ir.VM) error {
+	stack := vm.GetStack()
+	target := cutil.GoString(stack.GetVarInt64(0))
+	name := cutil.GoString(stack.GetVarInt64(2))
+	if err := getVFS(vm).Symlink(target, name); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
+// setField stores the value into the int or long field, the fields which the class does not have are skipped
+func setField(ref ir.Ref, name string, value int64) {
+	field := ref.Class().GetFieldByName(name)
+	if field == nil {
+		return
+	}
+	if field.Slot() == 2 {
+		*(*int64)(field.GetPointer(ref)) = value
+	} else {
+		*(*int32)(field.GetPointer(ref)) = (int32)(value)
+	}
+}
 
-type jUnixFileAttributes struct {
-	st_mode           int32
-	st_ino            int64
-	st_dev            int64
-	st_rdev           int64
-	st_nlink          int32
-	st_uid            int32
-	st_gid            int32
-	st_size           int64
-	st_atime_sec      int64
-	st_atime_nsec     int64
-	st_mtime_sec      int64
-	st_mtime_nsec     int64
-	st_ctime_sec      int64
-	st_ctime_nsec     int64
-	st_birthtime_sec  int64
-	st_birthtime_nsec int64
+func setTimeField(ref ir.Ref, prefix string, t time.Time) {
+	setField(ref, prefix+"_sec", t.Unix())
+	setField(ref, prefix+"_nsec", (int64)(t.Nanosecond()))
+}
+
+// setAttrs fills UnixFileAttributes with the file info
+func setAttrs(attrs ir.Ref, info fs.FileInfo) {
+	a := vfs.AttrOf(info)
+	setField(attrs, "st_mode", (int64)(fileModeToStMode(info.Mode())))
+	setField(attrs, "st_ino", (int64)(a.Ino))
+	setField(attrs, "st_dev", (int64)(a.Dev))
+	setField(attrs, "st_rdev", (int64)(a.Rdev))
+	setField(attrs, "st_nlink", (int64)(a.Nlink))
+	setField(attrs, "st_uid", (int64)(a.Uid))
+	setField(attrs, "st_gid", (int64)(a.Gid))
+	setField(attrs, "st_size", info.Size())
+	setTimeField(attrs, "st_atime", a.Atime)
+	setTimeField(attrs, "st_mtime", info.ModTime())
+	setTimeField(attrs, "st_ctime", a.Ctime)
+	// the creation time is reported as supported, so the filesystems which do not record it report the modification time
+	btime := a.Btime
+	if btime.IsZero() {
+		btime = info.ModTime()
+	}
+	setTimeField(attrs, "st_birthtime", btime)
 }
 
 // private static native int stat0(long pathAddress, UnixFileAttributes attrs);
 func UnixNativeDispatcher_stat0(vm ir.VM) error {
 	stack := vm.GetStack()
-	pathAddress := stack.GetVarInt64(0)
-	attrsRef := stack.GetVarRef(2)
-	path := cutil.GoString(pathAddress)
-	stat, err := os.Stat(path)
+	name := cutil.GoString(stack.GetVarInt64(0))
+	attrs := stack.GetVarRef(2)
+	stat, err := getVFS(vm).Stat(name)
 	if err != nil {
-		var errno syscall.Errno
-		if errors.As(err, &errno) {
-			stack.PushInt32((int32)(errno))
-		} else {
-			stack.PushInt32(-1)
-		}
+		stack.PushInt32(errnoOf(err))
 		return nil
 	}
-	attrs := (*jUnixFileAttributes)(attrsRef.Data())
-	attrs.st_mode = fileModeToStMode(stat.Mode())
-	attrs.st_size = stat.Size()
-	attrs.st_mtime_sec = stat.ModTime().Unix()
-	attrs.st_mtime_nsec = (int64)(stat.ModTime().Nanosecond())
+	setAttrs(attrs, stat)
 	stack.PushInt32(0)
 	return nil
 }
 
 func fileModeToStMode(mode fs.FileMode) int32 {
-	const (
-		S_IFMT   = 0000370000
-		S_IFSOCK = 0000140000
-		S_IFLNK  = 0000120000
-		S_IFREG  = 0000100000
-		S_IFBLK  = 0000060000
-		S_IFDIR  = 0000040000
-		S_IFCHR  = 0000020000
-		S_IFIFO  = 0000010000
-
-		S_ISUID = 0004000
-		S_ISGID = 0002000
-		S_ISVTX = 0001000
-	)
 	var flags int32 = (int32)(mode & fs.ModePerm)
 
 	if mode&fs.ModeDir != 0 {
@@ -187,38 +561,482 @@ func fileModeToStMode(mode fs.FileMode) int32 {
 	return flags
 }
 
+// stModeToFileMode converts the permission bits of st_mode
+func stModeToFileMode(mode int32) fs.FileMode {
+	m := (fs.FileMode)(mode & 0777)
+	if mode&S_ISUID != 0 {
+		m |= fs.ModeSetuid
+	}
+	if mode&S_ISGID != 0 {
+		m |= fs.ModeSetgid
+	}
+	if mode&S_ISVTX != 0 {
+		m |= fs.ModeSticky
+	}
+	return m
+}
+
 // private static native void lstat0(long pathAddress, UnixFileAttributes attrs) throws UnixException;
+func UnixNativeDispatcher_lstat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	stat, err := getVFS(vm).Lstat(name)
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	setAttrs(stack.GetVarRef(2), stat)
+	return nil
+}
+
 // private static native void fstat0(int fd, UnixFileAttributes attrs) throws UnixException;
+func UnixNativeDispatcher_fstat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	attrs := stack.GetVarRef(1)
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	statter, ok := f.(interface{ Stat() (fs.FileInfo, error) })
+	if !ok {
+		// the standard streams look like terminals
+		setField(attrs, "st_mode", S_IFCHR|0620)
+		setField(attrs, "st_nlink", 1)
+		return nil
+	}
+	stat, err := statter.Stat()
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	setAttrs(attrs, stat)
+	return nil
+}
+
 // private static native void fstatat0(int dfd, long pathAddress, int flag, UnixFileAttributes attrs) throws UnixException;
+func UnixNativeDispatcher_fstatat0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name, err := atPath(vm, stack.GetVarInt32(0), cutil.GoString(stack.GetVarInt64(1)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	var stat fs.FileInfo
+	if stack.GetVarInt32(3)&AT_SYMLINK_NOFOLLOW != 0 {
+		stat, err = getVFS(vm).Lstat(name)
+	} else {
+		stat, err = getVFS(vm).Stat(name)
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	setAttrs(stack.GetVarRef(4), stat)
+	return nil
+}
+
 // private static native void chown0(long pathAddress, int uid, int gid) throws UnixException;
+func UnixNativeDispatcher_chown0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	if err := getVFS(vm).Chown(name, (int)(stack.GetVarInt32(2)), (int)(stack.GetVarInt32(3))); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void lchown0(long pathAddress, int uid, int gid) throws UnixException;
+func UnixNativeDispatcher_lchown0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	if err := getVFS(vm).Lchown(name, (int)(stack.GetVarInt32(2)), (int)(stack.GetVarInt32(3))); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // static native void fchown0(int fd, int uid, int gid) throws UnixException;
+func UnixNativeDispatcher_fchown0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		err = vfs.Fchown(f, (int)(stack.GetVarInt32(1)), (int)(stack.GetVarInt32(2)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void chmod0(long pathAddress, int mode) throws UnixException;
+func UnixNativeDispatcher_chmod0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	if err := getVFS(vm).Chmod(name, stModeToFileMode(stack.GetVarInt32(2))); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void fchmod0(int fd, int mode) throws UnixException;
+func UnixNativeDispatcher_fchmod0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		err = vfs.Fchmod(f, stModeToFileMode(stack.GetVarInt32(1)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void utimes0(long pathAddress, long times0, long times1) throws UnixException;
+func UnixNativeDispatcher_utimes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	atime := time.UnixMicro(stack.GetVarInt64(2))
+	mtime := time.UnixMicro(stack.GetVarInt64(4))
+	if err := getVFS(vm).Chtimes(name, atime, mtime); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void futimes0(int fd, long times0, long times1) throws UnixException;
+func UnixNativeDispatcher_futimes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		err = vfs.Fchtimes(f, time.UnixMicro(stack.GetVarInt64(1)), time.UnixMicro(stack.GetVarInt64(3)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void futimens0(int fd, long times0, long times1) throws UnixException;
+func UnixNativeDispatcher_futimens0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		err = vfs.Fchtimes(f, time.Unix(0, stack.GetVarInt64(1)), time.Unix(0, stack.GetVarInt64(3)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void lutimes0(long pathAddress, long times0, long times1) throws UnixException;
+func UnixNativeDispatcher_lutimes0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	fsys := getVFS(vm)
+	stat, err := fsys.Lstat(name)
+	if err == nil {
+		if stat.Mode().Type() == fs.ModeSymlink {
+			// the filesystems cannot change the times of links, which is why SUPPORTS_LUTIMES is not reported
+			return throwErrno(vm, ENOSYS)
+		}
+		err = fsys.Chtimes(name, time.UnixMicro(stack.GetVarInt64(2)), time.UnixMicro(stack.GetVarInt64(4)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native long opendir0(long pathAddress) throws UnixException;
+func UnixNativeDispatcher_opendir0(vm ir.VM) error {
+	stack := vm.GetStack()
+	fd, err := openFile(vm, cutil.GoString(stack.GetVarInt64(0)), O_RDONLY|O_DIRECTORY, 0)
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt64(streamOf(fd))
+	return nil
+}
+
 // static native long fdopendir(int dfd) throws UnixException;
+func UnixNativeDispatcher_fdopendir(vm ir.VM) error {
+	stack := vm.GetStack()
+	dfd := stack.GetVarInt32(0)
+	f, err := vm.(*jvm.VM).Files().Get(dfd)
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	dir, ok := f.(vfs.File)
+	if !ok {
+		return throwErrno(vm, ENOTDIR)
+	}
+	stat, err := dir.Stat()
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	if !stat.IsDir() {
+		return throwErrno(vm, ENOTDIR)
+	}
+	// the stream owns the descriptor, closedir closes it
+	stack.PushInt64(streamOf(dfd))
+	return nil
+}
+
 // static native void closedir(long dir) throws UnixException;
+func UnixNativeDispatcher_closedir(vm ir.VM) error {
+	if err := vm.(*jvm.VM).Files().Close(streamFD(vm.GetStack().GetVarInt64(0))); err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // static native byte[] readdir0(long dir) throws UnixException;
+func UnixNativeDispatcher_readdir0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(streamFD(stack.GetVarInt64(0)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	dir, ok := f.(vfs.File)
+	if !ok {
+		return throwErrno(vm, EBADF)
+	}
+	entries, err := dir.ReadDir(1)
+	if len(entries) == 0 {
+		if err == nil || err == io.EOF {
+			stack.PushRef(nil)
+			return nil
+		}
+		return throwUnixException(vm, err)
+	}
+	stack.PushRef(newByteArray(vm, entries[0].Name()))
+	return nil
+}
+
 // private static native int read0(int fildes, long buf, int nbyte) throws UnixException;
+func UnixNativeDispatcher_read0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	r, ok := f.(io.Reader)
+	if !ok {
+		return throwErrno(vm, EBADF)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	vm.(*jvm.VM).EnterSafeRegion()
+	n, err := r.Read(buf)
+	vm.(*jvm.VM).LeaveSafeRegion()
+	if n == 0 && err != nil && err != io.EOF {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
+
 // private static native int write0(int fildes, long buf, int nbyte) throws UnixException;
+func UnixNativeDispatcher_write0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := vm.(*jvm.VM).Files().Get(stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	w, ok := f.(io.Writer)
+	if !ok {
+		return throwErrno(vm, EBADF)
+	}
+	buf := cutil.Bytes(stack.GetVarInt64(1), (int)(stack.GetVarInt32(3)))
+	vm.(*jvm.VM).EnterSafeRegion()
+	n, err := w.Write(buf)
+	vm.(*jvm.VM).LeaveSafeRegion()
+	if n == 0 && err != nil {
+		return throwUnixException(vm, err)
+	}
+	stack.PushInt32((int32)(n))
+	return nil
+}
+
 // private static native int access0(long pathAddress, int amode);
+func UnixNativeDispatcher_access0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	// the modes of Linux are the same as the ones of vfs.FS.Access
+	if err := getVFS(vm).Access(name, (uint32)(stack.GetVarInt32(2))); err != nil {
+		stack.PushInt32(errnoOf(err))
+		return nil
+	}
+	stack.PushInt32(0)
+	return nil
+}
+
+// The users and groups are looked up on the host, root is always known
+// since the filesystems without owners, e.g. MemFS, report the files are owned by root.
+
 // static native byte[] getpwuid(int uid) throws UnixException;
+func UnixNativeDispatcher_getpwuid(vm ir.VM) error {
+	stack := vm.GetStack()
+	uid := stack.GetVarInt32(0)
+	name := ""
+	if u, err := user.LookupId(strconv.Itoa((int)(uid))); err == nil {
+		name = u.Username
+	} else if uid == 0 {
+		name = "root"
+	} else {
+		return throwErrno(vm, ENOENT)
+	}
+	stack.PushRef(newByteArray(vm, name))
+	return nil
+}
+
 // static native byte[] getgrgid(int gid) throws UnixException;
+func UnixNativeDispatcher_getgrgid(vm ir.VM) error {
+	stack := vm.GetStack()
+	gid := stack.GetVarInt32(0)
+	name := ""
+	if g, err := user.LookupGroupId(strconv.Itoa((int)(gid))); err == nil {
+		name = g.Name
+	} else if gid == 0 {
+		name = "root"
+	} else {
+		return throwErrno(vm, ENOENT)
+	}
+	stack.PushRef(newByteArray(vm, name))
+	return nil
+}
+
 // private static native int getpwnam0(long nameAddress) throws UnixException;
+func UnixNativeDispatcher_getpwnam0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	var uid int32 = -1
+	if u, err := user.Lookup(name); err == nil {
+		if id, err := strconv.ParseInt(u.Uid, 10, 32); err == nil {
+			uid = (int32)(id)
+		}
+	} else if name == "root" {
+		uid = 0
+	}
+	stack.PushInt32(uid)
+	return nil
+}
+
 // private static native int getgrnam0(long nameAddress) throws UnixException;
+func UnixNativeDispatcher_getgrnam0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	var gid int32 = -1
+	if g, err := user.LookupGroup(name); err == nil {
+		if id, err := strconv.ParseInt(g.Gid, 10, 32); err == nil {
+			gid = (int32)(id)
+		}
+	} else if name == "root" {
+		gid = 0
+	}
+	stack.PushInt32(gid)
+	return nil
+}
+
 // private static native void statvfs0(long pathAddress, UnixFileStoreAttributes attrs) throws UnixException;
+func UnixNativeDispatcher_statvfs0(vm ir.VM) error {
+	stack := vm.GetStack()
+	name := cutil.GoString(stack.GetVarInt64(0))
+	attrs := stack.GetVarRef(2)
+	info, err := vfs.Statfs(getVFS(vm), name)
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	setField(attrs, "f_frsize", (int64)(info.BlockSize))
+	setField(attrs, "f_blocks", (int64)(info.Blocks))
+	setField(attrs, "f_bfree", (int64)(info.BlocksFree))
+	setField(attrs, "f_bavail", (int64)(info.BlocksAvail))
+	return nil
+}
+
 // static native byte[] strerror(int errnum);
+func UnixNativeDispatcher_strerror(vm ir.VM) error {
+	stack := vm.GetStack()
+	stack.PushRef(newByteArray(vm, strerror(stack.GetVarInt32(0))))
+	return nil
+}
+
 // private static native int fgetxattr0(int filedes, long nameAddress, long valueAddress, int valueLen) throws UnixException;
+func UnixNativeDispatcher_fgetxattr0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	value, err := vfs.Fgetxattr(f, cutil.GoString(stack.GetVarInt64(1)))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	// a zero length queries the size of the value
+	if valueLen := stack.GetVarInt32(5); valueLen != 0 {
+		if (int64)(len(value)) > (int64)(valueLen) {
+			return throwErrno(vm, ERANGE)
+		}
+		copy(cutil.Bytes(stack.GetVarInt64(3), (int)(valueLen)), value)
+	}
+	stack.PushInt32((int32)(len(value)))
+	return nil
+}
+
 // private static native void fsetxattr0(int filedes, long nameAddress, long valueAddress, int valueLen) throws UnixException;
+func UnixNativeDispatcher_fsetxattr0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		// copy the value out of the native memory, which Java frees after the call
+		value := append([]byte{}, cutil.Bytes(stack.GetVarInt64(3), (int)(stack.GetVarInt32(5)))...)
+		err = vfs.Fsetxattr(f, cutil.GoString(stack.GetVarInt64(1)), value)
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // private static native void fremovexattr0(int filedes, long nameAddress) throws UnixException;
+func UnixNativeDispatcher_fremovexattr0(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err == nil {
+		err = vfs.Fremovexattr(f, cutil.GoString(stack.GetVarInt64(1)))
+	}
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	return nil
+}
+
 // static native int flistxattr(int filedes, long listAddress, int size) throws UnixException;
+func UnixNativeDispatcher_flistxattr(vm ir.VM) error {
+	stack := vm.GetStack()
+	f, err := fdFile(vm, stack.GetVarInt32(0))
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	attrs, err := vfs.Flistxattr(f)
+	if err != nil {
+		return throwUnixException(vm, err)
+	}
+	// the list is the names terminated by NUL
+	var list []byte
+	for _, attr := range attrs {
+		list = append(list, attr...)
+		list = append(list, 0)
+	}
+	if size := stack.GetVarInt32(3); size != 0 {
+		if (int64)(len(list)) > (int64)(size) {
+			return throwErrno(vm, ERANGE)
+		}
+		copy(cutil.Bytes(stack.GetVarInt64(1), (int)(size)), list)
+	}
+	stack.PushInt32((int32)(len(list)))
+	return nil
+}
 
 // private static native int init();
 func UnixNativeDispatcher_init(vm ir.VM) error {
-	vm.GetStack().PushInt32(0)
+	// the host files cannot change their times through the descriptor, so Java sets them by the path with utimes
+	vm.GetStack().PushInt32(SUPPORTS_OPENAT | SUPPORTS_XATTR | SUPPORTS_BIRTHTIME)
 	return nil
 }
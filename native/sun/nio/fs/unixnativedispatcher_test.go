@@ -0,0 +1,215 @@
+package sun_nio_fs
+
+import (
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/LiterMC/wasm-jdk/cutil"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vm/vmtest"
+)
+
+// newTestVM creates a VM on a memory filesystem with the tree:
+//
+//	/dir/a, /dir/b, /dir/sub/
+//	/link -> /dir/a
+//	/dirlink -> dir
+func newTestVM(t *testing.T) (*jvm.VM, *vmtest.Loader, vfs.FS) {
+	t.Helper()
+	fsys := vfs.NewMemFS()
+	for _, err := range []error{
+		fsys.Mkdir("/dir", 0755),
+		fsys.Mkdir("/dir/sub", 0755),
+		fsys.Symlink("/dir/a", "/link"),
+		fsys.Symlink("dir", "/dirlink"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"/dir/a", "/dir/b"} {
+		f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE, 0640)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := f.Write([]byte("content")); err != nil {
+			t.Fatal(err)
+		}
+		f.Close()
+	}
+	loader := vmtest.NewLoader()
+	return vmtest.NewVM(jvm.Options{Loader: loader, FS: fsys}), loader, fsys
+}
+
+// cString allocates the string in the native memory like NativeBuffer does, and returns its address
+func cString(t *testing.T, s string) int64 {
+	address := cutil.AllocMemory(len(s) + 1)
+	t.Cleanup(func() { cutil.FreeMemory(address) })
+	buf := cutil.Bytes((int64)(address), len(s)+1)
+	copy(buf, s)
+	buf[len(s)] = 0
+	return (int64)(address)
+}
+
+// callBytes calls the native and pops its byte array result, which is returned as a string
+func callBytes(t *testing.T, vm *jvm.VM, fn func(ir.VM) error, args ...any) (string, bool) {
+	t.Helper()
+	if err := vmtest.Call(vm, fn, args...); err != nil {
+		t.Fatal(err)
+	}
+	arr := vm.GetStack().PopRef()
+	if arr == nil {
+		return "", false
+	}
+	return string(arr.GetByteArr()), true
+}
+
+func newFileAttributes(vm *jvm.VM, loader *vmtest.Loader) ir.Ref {
+	cls := loader.LoadedClass("sun/nio/fs/UnixFileAttributes")
+	if cls == nil {
+		cls = loader.Define("sun/nio/fs/UnixFileAttributes", "java/lang/Object",
+			"st_mode I", "st_ino J", "st_dev J", "st_rdev J", "st_nlink I", "st_uid I", "st_gid I", "st_size J",
+			"st_atime_sec J", "st_atime_nsec J", "st_mtime_sec J", "st_mtime_nsec J",
+			"st_ctime_sec J", "st_ctime_nsec J", "st_birthtime_sec J", "st_birthtime_nsec J")
+	}
+	return vm.New(cls)
+}
+
+func getField(ref ir.Ref, name string) int64 {
+	field := ref.Class().GetFieldByName(name)
+	if field.Slot() == 2 {
+		return *(*int64)(field.GetPointer(ref))
+	}
+	return (int64)(*(*int32)(field.GetPointer(ref)))
+}
+
+func TestStat(t *testing.T) {
+	vm, loader, fsys := newTestVM(t)
+	mtime := time.Unix(1700000000, 123456789)
+	if err := fsys.Chtimes("/dir/a", mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Chown("/dir/a", 1000, 100); err != nil {
+		t.Fatal(err)
+	}
+	info, err := fsys.Stat("/dir/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	attr := vfs.AttrOf(info)
+
+	attrs := newFileAttributes(vm, loader)
+	if err := vmtest.Call(vm, UnixNativeDispatcher_stat0, cString(t, "/link"), attrs); err != nil {
+		t.Fatal(err)
+	}
+	if errno := vm.GetStack().PopInt32(); errno != 0 {
+		t.Fatalf("stat0 returned %d, want 0", errno)
+	}
+	for _, tt := range []struct {
+		field string
+		want  int64
+	}{
+		{"st_mode", S_IFREG | 0640},
+		{"st_ino", (int64)(attr.Ino)},
+		{"st_dev", (int64)(attr.Dev)},
+		{"st_nlink", 1},
+		{"st_uid", 1000},
+		{"st_gid", 100},
+		{"st_size", 7},
+		{"st_mtime_sec", 1700000000},
+		{"st_mtime_nsec", 123456789},
+		{"st_atime_sec", 1700000000},
+		{"st_ctime_sec", attr.Ctime.Unix()},
+		{"st_birthtime_sec", attr.Btime.Unix()},
+	} {
+		if got := getField(attrs, tt.field); got != tt.want {
+			t.Errorf("%s is %d, want %d", tt.field, got, tt.want)
+		}
+	}
+
+	// lstat0 does not follow the link
+	attrs = newFileAttributes(vm, loader)
+	if err := vmtest.Call(vm, UnixNativeDispatcher_lstat0, cString(t, "/link"), attrs); err != nil {
+		t.Fatal(err)
+	}
+	if mode := getField(attrs, "st_mode"); mode&S_IFMT != S_IFLNK {
+		t.Errorf("st_mode of the link is %o, want a symbolic link", mode)
+	}
+	if size := getField(attrs, "st_size"); size != (int64)(len("/dir/a")) {
+		t.Errorf("st_size of the link is %d, want the length of the target", size)
+	}
+
+	// stat0 returns the errno instead of throwing it
+	if err := vmtest.Call(vm, UnixNativeDispatcher_stat0, cString(t, "/dir/missing"), attrs); err != nil {
+		t.Fatal(err)
+	}
+	if errno := vm.GetStack().PopInt32(); errno != ENOENT {
+		t.Errorf("stat0 of a missing file returned %d, want ENOENT", errno)
+	}
+}
+
+func TestReaddir(t *testing.T) {
+	vm, _, _ := newTestVM(t)
+	if err := vmtest.Call(vm, UnixNativeDispatcher_opendir0, cString(t, "/dirlink")); err != nil {
+		t.Fatal(err)
+	}
+	dir := vm.GetStack().PopInt64()
+	var names []string
+	for {
+		name, ok := callBytes(t, vm, UnixNativeDispatcher_readdir0, dir)
+		if !ok {
+			break
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	if want := []string{"a", "b", "sub"}; !slices.Equal(names, want) {
+		t.Errorf("readdir0 returned %q, want %q", names, want)
+	}
+	if _, ok := callBytes(t, vm, UnixNativeDispatcher_readdir0, dir); ok {
+		t.Error("readdir0 after the end returned an entry")
+	}
+	if err := vmtest.Call(vm, UnixNativeDispatcher_closedir, dir); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vm.Files().Get(streamFD(dir)); err == nil {
+		t.Error("the descriptor of the directory is open after closedir")
+	}
+}
+
+func TestRealpathReadlink(t *testing.T) {
+	vm, _, _ := newTestVM(t)
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/dir/a", "/dir/a"},
+		{"/link", "/dir/a"},
+		{"/dirlink/sub/../b", "/dir/b"},
+		{"//dir/./sub/", "/dir/sub"},
+	} {
+		if got, _ := callBytes(t, vm, UnixNativeDispatcher_realpath0, cString(t, tt.path)); got != tt.want {
+			t.Errorf("realpath0(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+	for _, tt := range []struct {
+		path, want string
+	}{
+		{"/link", "/dir/a"},
+		{"/dirlink", "dir"},
+	} {
+		if got, _ := callBytes(t, vm, UnixNativeDispatcher_readlink0, cString(t, tt.path)); got != tt.want {
+			t.Errorf("readlink0(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestStrerrorNative(t *testing.T) {
+	vm, _, _ := newTestVM(t)
+	if got, _ := callBytes(t, vm, UnixNativeDispatcher_strerror, (int32)(ENOENT)); got != "No such file or directory" {
+		t.Errorf("strerror(ENOENT) = %q, want %q", got, "No such file or directory")
+	}
+}
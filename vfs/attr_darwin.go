@@ -0,0 +1,24 @@
+package vfs
+
+import (
+	"syscall"
+	"time"
+)
+
+func hostAttr(sys any) (Attr, bool) {
+	st, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return Attr{}, false
+	}
+	return Attr{
+		Dev:   (uint64)(st.Dev),
+		Ino:   st.Ino,
+		Rdev:  (uint64)(st.Rdev),
+		Nlink: (uint64)(st.Nlink),
+		Uid:   (int)(st.Uid),
+		Gid:   (int)(st.Gid),
+		Atime: time.Unix(st.Atimespec.Sec, st.Atimespec.Nsec),
+		Ctime: time.Unix(st.Ctimespec.Sec, st.Ctimespec.Nsec),
+		Btime: time.Unix(st.Birthtimespec.Sec, st.Birthtimespec.Nsec),
+	}, true
+}
@@ -0,0 +1,23 @@
+package vfs
+
+import (
+	"syscall"
+	"time"
+)
+
+func hostAttr(sys any) (Attr, bool) {
+	st, ok := sys.(*syscall.Stat_t)
+	if !ok {
+		return Attr{}, false
+	}
+	return Attr{
+		Dev:   (uint64)(st.Dev),
+		Ino:   (uint64)(st.Ino),
+		Rdev:  (uint64)(st.Rdev),
+		Nlink: (uint64)(st.Nlink),
+		Uid:   (int)(st.Uid),
+		Gid:   (int)(st.Gid),
+		Atime: time.Unix((int64)(st.Atim.Sec), (int64)(st.Atim.Nsec)),
+		Ctime: time.Unix((int64)(st.Ctim.Sec), (int64)(st.Ctim.Nsec)),
+	}, true
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package vfs
+
+func hostAttr(sys any) (Attr, bool) {
+	return Attr{}, false
+}
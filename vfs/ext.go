@@ -0,0 +1,170 @@
+package vfs
+
+import (
+	"io/fs"
+	"syscall"
+	"time"
+)
+
+// XattrFS is implemented by the filesystems which support extended attributes
+type XattrFS interface {
+	FS
+	// Getxattr returns the value of the attribute, or ENOATTR if it does not exist
+	Getxattr(name, attr string) ([]byte, error)
+	Setxattr(name, attr string, data []byte) error
+	Removexattr(name, attr string) error
+	Listxattr(name string) ([]string, error)
+}
+
+// Getxattr gets the extended attribute of the file, it returns ENOTSUP if fsys does not implement XattrFS
+func Getxattr(fsys FS, name, attr string) ([]byte, error) {
+	if x, ok := fsys.(XattrFS); ok {
+		return x.Getxattr(name, attr)
+	}
+	return nil, pathError("getxattr", name, syscall.ENOTSUP)
+}
+
+// Setxattr sets the extended attribute of the file, it returns ENOTSUP if fsys does not implement XattrFS
+func Setxattr(fsys FS, name, attr string, data []byte) error {
+	if x, ok := fsys.(XattrFS); ok {
+		return x.Setxattr(name, attr, data)
+	}
+	return pathError("setxattr", name, syscall.ENOTSUP)
+}
+
+// Removexattr removes the extended attribute of the file, it returns ENOTSUP if fsys does not implement XattrFS
+func Removexattr(fsys FS, name, attr string) error {
+	if x, ok := fsys.(XattrFS); ok {
+		return x.Removexattr(name, attr)
+	}
+	return pathError("removexattr", name, syscall.ENOTSUP)
+}
+
+// Listxattr lists the extended attributes of the file, it returns ENOTSUP if fsys does not implement XattrFS
+func Listxattr(fsys FS, name string) ([]string, error) {
+	if x, ok := fsys.(XattrFS); ok {
+		return x.Listxattr(name)
+	}
+	return nil, pathError("listxattr", name, syscall.ENOTSUP)
+}
+
+// FSInfo is the statistics of a filesystem like statvfs(3), the sizes are in blocks
+type FSInfo struct {
+	BlockSize   uint64
+	Blocks      uint64
+	BlocksFree  uint64
+	BlocksAvail uint64
+}
+
+// StatfsFS is implemented by the filesystems which report their space
+type StatfsFS interface {
+	FS
+	// Statfs returns the statistics of the filesystem which has the file
+	Statfs(name string) (FSInfo, error)
+}
+
+// Statfs returns the statistics of the filesystem which has the file.
+// If fsys does not implement StatfsFS, it checks the file exists and returns zero FSInfo, which means unknown.
+func Statfs(fsys FS, name string) (FSInfo, error) {
+	if s, ok := fsys.(StatfsFS); ok {
+		return s.Statfs(name)
+	}
+	if _, err := fsys.Stat(name); err != nil {
+		return FSInfo{}, err
+	}
+	return FSInfo{}, nil
+}
+
+// XattrFile is implemented by the open files which support extended attributes, like fgetxattr(2)
+type XattrFile interface {
+	File
+	// Getxattr returns the value of the attribute, or ENOATTR if it does not exist
+	Getxattr(attr string) ([]byte, error)
+	Setxattr(attr string, data []byte) error
+	Removexattr(attr string) error
+	Listxattr() ([]string, error)
+}
+
+// Fgetxattr gets the extended attribute of the open file, it returns ENOTSUP if f does not implement XattrFile
+func Fgetxattr(f File, attr string) ([]byte, error) {
+	if x, ok := f.(XattrFile); ok {
+		return x.Getxattr(attr)
+	}
+	return nil, pathError("fgetxattr", f.Name(), syscall.ENOTSUP)
+}
+
+// Fsetxattr sets the extended attribute of the open file, it returns ENOTSUP if f does not implement XattrFile
+func Fsetxattr(f File, attr string, data []byte) error {
+	if x, ok := f.(XattrFile); ok {
+		return x.Setxattr(attr, data)
+	}
+	return pathError("fsetxattr", f.Name(), syscall.ENOTSUP)
+}
+
+// Fremovexattr removes the extended attribute of the open file, it returns ENOTSUP if f does not implement XattrFile
+func Fremovexattr(f File, attr string) error {
+	if x, ok := f.(XattrFile); ok {
+		return x.Removexattr(attr)
+	}
+	return pathError("fremovexattr", f.Name(), syscall.ENOTSUP)
+}
+
+// Flistxattr lists the extended attributes of the open file, it returns ENOTSUP if f does not implement XattrFile
+func Flistxattr(f File) ([]string, error) {
+	if x, ok := f.(XattrFile); ok {
+		return x.Listxattr()
+	}
+	return nil, pathError("flistxattr", f.Name(), syscall.ENOTSUP)
+}
+
+// The methods of the open files which change their attributes, e.g. *os.File has Chmod and Chown
+type (
+	chmodFile interface {
+		Chmod(mode fs.FileMode) error
+	}
+	chownFile interface {
+		Chown(uid, gid int) error
+	}
+	chtimesFile interface {
+		Chtimes(atime, mtime time.Time) error
+	}
+)
+
+// Fchmod changes the permission bits of the open file like FS.Chmod, it returns ENOTSUP if f has no Chmod method
+func Fchmod(f File, mode fs.FileMode) error {
+	if c, ok := f.(chmodFile); ok {
+		return c.Chmod(mode)
+	}
+	return pathError("fchmod", f.Name(), syscall.ENOTSUP)
+}
+
+// Fchown changes the owner of the open file like FS.Chown, it returns ENOTSUP if f has no Chown method
+func Fchown(f File, uid, gid int) error {
+	if c, ok := f.(chownFile); ok {
+		return c.Chown(uid, gid)
+	}
+	return pathError("fchown", f.Name(), syscall.ENOTSUP)
+}
+
+// Fchtimes changes the times of the open file like FS.Chtimes, it returns ENOTSUP if f has no Chtimes method
+func Fchtimes(f File, atime, mtime time.Time) error {
+	if c, ok := f.(chtimesFile); ok {
+		return c.Chtimes(atime, mtime)
+	}
+	return pathError("futimens", f.Name(), syscall.ENOTSUP)
+}
+
+// WorkdirFS is implemented by the filesystems which have a working directory for the relative names
+type WorkdirFS interface {
+	FS
+	Getwd() (string, error)
+}
+
+// Getwd returns the working directory of the filesystem.
+// If fsys does not implement WorkdirFS, it returns "/", since the relative names are resolved from the root.
+func Getwd(fsys FS) (string, error) {
+	if w, ok := fsys.(WorkdirFS); ok {
+		return w.Getwd()
+	}
+	return "/", nil
+}
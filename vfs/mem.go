@@ -0,0 +1,937 @@
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// maxSymlinks is the limit of symbolic links followed while resolving a name, like Linux's
+const maxSymlinks = 40
+
+// maxMemFileSize is the size limit of the files in MemFS, which is the length limit of Java arrays
+const maxMemFileSize = math.MaxInt32
+
+// memDevs allocates the device numbers of MemFS, so files of different MemFS have different keys
+var memDevs atomic.Uint64
+
+// MemFS is a filesystem in memory.
+// Permissions are checked against the owner bits only in Access, since there are no users.
+// Symbolic links are resolved inside the MemFS, where absolute targets start from its root.
+type MemFS struct {
+	mux     sync.RWMutex
+	dev     uint64
+	root    *memNode
+	lastIno uint64
+}
+
+var (
+	_ XattrFS   = (*MemFS)(nil)
+	_ XattrFile = (*memFile)(nil)
+)
+
+type memNode struct {
+	mode  fs.FileMode
+	ino   uint64
+	nlink uint64
+	uid   int
+	gid   int
+	atime time.Time
+	mtime time.Time
+	ctime time.Time
+	btime time.Time
+	// xattrs are the extended attributes
+	xattrs map[string][]byte
+
+	// data is the content of a regular file
+	data []byte
+	// target is the target of a symbolic link
+	target string
+	// children are the entries of a directory
+	children map[string]*memNode
+}
+
+// NewMemFS returns an empty MemFS, its root directory has permission 0755
+func NewMemFS() *MemFS {
+	m := &MemFS{
+		dev: memDevs.Add(1),
+	}
+	m.root = m.newNode(fs.ModeDir | 0755)
+	return m
+}
+
+// newNode must be called with the lock held except in NewMemFS
+func (m *MemFS) newNode(mode fs.FileMode) *memNode {
+	m.lastIno++
+	now := time.Now()
+	n := &memNode{
+		mode:  mode,
+		ino:   m.lastIno,
+		nlink: 1,
+		atime: now,
+		mtime: now,
+		ctime: now,
+		btime: now,
+	}
+	if mode.IsDir() {
+		n.nlink = 2
+		n.children = make(map[string]*memNode)
+	}
+	return n
+}
+
+func (n *memNode) touch() {
+	now := time.Now()
+	n.mtime = now
+	n.ctime = now
+}
+
+func (n *memNode) size() int64 {
+	switch n.mode.Type() {
+	case 0:
+		return (int64)(len(n.data))
+	case fs.ModeSymlink:
+		return (int64)(len(n.target))
+	}
+	return 0
+}
+
+// resolve walks to the name, it follows the symbolic links in the parent directories, and in the last element if follow is set.
+// It returns the parent directory, the base name and the node which is nil if it does not exist.
+// The parent is nil for the root directory.
+func (m *MemFS) resolve(name string, follow bool) (dir *memNode, base string, node *memNode, err error) {
+	p := cleanPath(name)
+	links := 0
+walk:
+	dir = nil
+	node = m.root
+	elems := strings.Split(p[1:], "/")
+	if p == "/" {
+		return nil, "", m.root, nil
+	}
+	for i, e := range elems {
+		if !node.mode.IsDir() {
+			return nil, "", nil, syscall.ENOTDIR
+		}
+		dir, base = node, e
+		node = dir.children[e]
+		last := i == len(elems)-1
+		if node == nil {
+			if last {
+				return dir, base, nil, nil
+			}
+			return nil, "", nil, syscall.ENOENT
+		}
+		if node.mode.Type() == fs.ModeSymlink && (follow || !last) {
+			if links++; links > maxSymlinks {
+				return nil, "", nil, syscall.ELOOP
+			}
+			target := node.target
+			if !path.IsAbs(target) {
+				target = path.Join("/", path.Join(elems[:i]...), target)
+			}
+			p = path.Join(target, path.Join(elems[i+1:]...))
+			goto walk
+		}
+	}
+	return
+}
+
+// lookup resolves the existing file
+func (m *MemFS) lookup(op, name string, follow bool) (*memNode, error) {
+	_, _, n, err := m.resolve(name, follow)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: op, Path: name, Err: err}
+	}
+	return n, nil
+}
+
+func (m *MemFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	dir, base, n, err := m.resolve(name, true)
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if n == nil {
+		if flag&os.O_CREATE == 0 {
+			return nil, pathError("open", name, syscall.ENOENT)
+		}
+		n = m.newNode(perm & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky))
+		dir.children[base] = n
+		dir.touch()
+	} else if flag&(os.O_CREATE|os.O_EXCL) == os.O_CREATE|os.O_EXCL {
+		return nil, pathError("open", name, syscall.EEXIST)
+	} else if n.mode.IsDir() && isWrite(flag) {
+		return nil, pathError("open", name, syscall.EISDIR)
+	} else if flag&os.O_TRUNC != 0 && isWrite(flag) && n.mode.IsRegular() {
+		n.data = nil
+		n.touch()
+	}
+	return &memFile{
+		fs:   m,
+		node: n,
+		name: name,
+		flag: flag,
+	}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("stat", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return m.fileInfo(path.Base(cleanPath(name)), n), nil
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("lstat", name, false)
+	if err != nil {
+		return nil, err
+	}
+	return m.fileInfo(path.Base(cleanPath(name)), n), nil
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("readdirent", name, true)
+	if err != nil {
+		return nil, err
+	}
+	if !n.mode.IsDir() {
+		return nil, pathError("readdirent", name, syscall.ENOTDIR)
+	}
+	return m.dirEntries(n), nil
+}
+
+func (m *MemFS) dirEntries(n *memNode) []fs.DirEntry {
+	entries := make([]fs.DirEntry, 0, len(n.children))
+	for name, child := range n.children {
+		entries = append(entries, fs.FileInfoToDirEntry(m.fileInfo(name, child)))
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int {
+		return strings.Compare(a.Name(), b.Name())
+	})
+	return entries
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	dir, base, n, err := m.resolve(name, false)
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	if n != nil {
+		return pathError("mkdir", name, syscall.EEXIST)
+	}
+	dir.children[base] = m.newNode(fs.ModeDir | perm&(fs.ModePerm|fs.ModeSetgid|fs.ModeSticky))
+	dir.nlink++
+	dir.touch()
+	return nil
+}
+
+func (m *MemFS) Rename(oldname, newname string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	oldDir, oldBase, n, err := m.resolve(oldname, false)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	newDir, newBase, target, err := m.resolve(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	if oldDir == nil || newDir == nil {
+		return linkError("rename", oldname, newname, syscall.EBUSY)
+	}
+	if target == n {
+		return nil
+	}
+	if n.mode.IsDir() {
+		if n.contains(newDir) {
+			return linkError("rename", oldname, newname, syscall.EINVAL)
+		}
+		if target != nil {
+			if !target.mode.IsDir() {
+				return linkError("rename", oldname, newname, syscall.ENOTDIR)
+			}
+			if len(target.children) > 0 {
+				return linkError("rename", oldname, newname, syscall.ENOTEMPTY)
+			}
+			newDir.nlink--
+		}
+		oldDir.nlink--
+		newDir.nlink++
+	} else if target != nil && target.mode.IsDir() {
+		return linkError("rename", oldname, newname, syscall.EISDIR)
+	}
+	if target != nil {
+		target.nlink--
+		target.ctime = time.Now()
+	}
+	delete(oldDir.children, oldBase)
+	newDir.children[newBase] = n
+	oldDir.touch()
+	newDir.touch()
+	n.ctime = time.Now()
+	return nil
+}
+
+// contains reports whether the directory is n or in n
+func (n *memNode) contains(dir *memNode) bool {
+	if n == dir {
+		return true
+	}
+	for _, child := range n.children {
+		if child.mode.IsDir() && child.contains(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *MemFS) Unlink(name string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	dir, base, n, err := m.resolve(name, false)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &fs.PathError{Op: "unlink", Path: name, Err: err}
+	}
+	if n.mode.IsDir() {
+		return pathError("unlink", name, syscall.EISDIR)
+	}
+	delete(dir.children, base)
+	dir.touch()
+	n.nlink--
+	n.ctime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Rmdir(name string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	dir, base, n, err := m.resolve(name, false)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &fs.PathError{Op: "rmdir", Path: name, Err: err}
+	}
+	if !n.mode.IsDir() {
+		return pathError("rmdir", name, syscall.ENOTDIR)
+	}
+	if dir == nil {
+		return pathError("rmdir", name, syscall.EBUSY)
+	}
+	if len(n.children) > 0 {
+		return pathError("rmdir", name, syscall.ENOTEMPTY)
+	}
+	delete(dir.children, base)
+	dir.nlink--
+	dir.touch()
+	n.nlink = 0
+	return nil
+}
+
+func (m *MemFS) Link(oldname, newname string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	_, _, n, err := m.resolve(oldname, false)
+	if err == nil && n == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	if n.mode.IsDir() {
+		return linkError("link", oldname, newname, syscall.EPERM)
+	}
+	dir, base, target, err := m.resolve(newname, false)
+	if err != nil {
+		return &os.LinkError{Op: "link", Old: oldname, New: newname, Err: err}
+	}
+	if target != nil {
+		return linkError("link", oldname, newname, syscall.EEXIST)
+	}
+	dir.children[base] = n
+	dir.touch()
+	n.nlink++
+	n.ctime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Symlink(target, name string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	dir, base, n, err := m.resolve(name, false)
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: target, New: name, Err: err}
+	}
+	if n != nil {
+		return linkError("symlink", target, name, syscall.EEXIST)
+	}
+	n = m.newNode(fs.ModeSymlink | 0777)
+	n.target = target
+	dir.children[base] = n
+	dir.touch()
+	return nil
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("readlink", name, false)
+	if err != nil {
+		return "", err
+	}
+	if n.mode.Type() != fs.ModeSymlink {
+		return "", pathError("readlink", name, syscall.EINVAL)
+	}
+	return n.target, nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n, err := m.lookup("chmod", name, true)
+	if err != nil {
+		return err
+	}
+	n.chmod(mode)
+	return nil
+}
+
+func (n *memNode) chmod(mode fs.FileMode) {
+	n.mode = n.mode.Type() | mode&(fs.ModePerm|fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)
+	n.ctime = time.Now()
+}
+
+func (m *MemFS) Chown(name string, uid, gid int) error {
+	return m.chown("chown", name, uid, gid, true)
+}
+
+func (m *MemFS) Lchown(name string, uid, gid int) error {
+	return m.chown("lchown", name, uid, gid, false)
+}
+
+func (m *MemFS) chown(op, name string, uid, gid int, follow bool) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n, err := m.lookup(op, name, follow)
+	if err != nil {
+		return err
+	}
+	n.chown(uid, gid)
+	return nil
+}
+
+func (n *memNode) chown(uid, gid int) {
+	if uid != -1 {
+		n.uid = uid
+	}
+	if gid != -1 {
+		n.gid = gid
+	}
+	n.ctime = time.Now()
+}
+
+func (m *MemFS) Chtimes(name string, atime, mtime time.Time) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n, err := m.lookup("chtimes", name, true)
+	if err != nil {
+		return err
+	}
+	n.chtimes(atime, mtime)
+	return nil
+}
+
+func (n *memNode) chtimes(atime, mtime time.Time) {
+	if !atime.IsZero() {
+		n.atime = atime
+	}
+	if !mtime.IsZero() {
+		n.mtime = mtime
+	}
+	n.ctime = time.Now()
+}
+
+func (m *MemFS) Access(name string, mode uint32) error {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("access", name, true)
+	if err != nil {
+		return err
+	}
+	if (uint32)(n.mode.Perm()>>6)&mode != mode {
+		return pathError("access", name, syscall.EACCES)
+	}
+	return nil
+}
+
+func (m *MemFS) Getxattr(name, attr string) ([]byte, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("getxattr", name, true)
+	if err != nil {
+		return nil, err
+	}
+	data, errno := n.getxattr(attr)
+	if errno != 0 {
+		return nil, pathError("getxattr", name, errno)
+	}
+	return data, nil
+}
+
+func (n *memNode) getxattr(attr string) ([]byte, syscall.Errno) {
+	data, ok := n.xattrs[attr]
+	if !ok {
+		return nil, ENOATTR
+	}
+	return slices.Clone(data), 0
+}
+
+func (m *MemFS) Setxattr(name, attr string, data []byte) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n, err := m.lookup("setxattr", name, true)
+	if err != nil {
+		return err
+	}
+	if errno := n.setxattr(attr, data); errno != 0 {
+		return pathError("setxattr", name, errno)
+	}
+	return nil
+}
+
+func (n *memNode) setxattr(attr string, data []byte) syscall.Errno {
+	if attr == "" {
+		return syscall.EINVAL
+	}
+	if n.xattrs == nil {
+		n.xattrs = make(map[string][]byte)
+	}
+	n.xattrs[attr] = slices.Clone(data)
+	n.ctime = time.Now()
+	return 0
+}
+
+func (m *MemFS) Removexattr(name, attr string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	n, err := m.lookup("removexattr", name, true)
+	if err != nil {
+		return err
+	}
+	if errno := n.removexattr(attr); errno != 0 {
+		return pathError("removexattr", name, errno)
+	}
+	return nil
+}
+
+func (n *memNode) removexattr(attr string) syscall.Errno {
+	if _, ok := n.xattrs[attr]; !ok {
+		return ENOATTR
+	}
+	delete(n.xattrs, attr)
+	n.ctime = time.Now()
+	return 0
+}
+
+func (m *MemFS) Listxattr(name string) ([]string, error) {
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	n, err := m.lookup("listxattr", name, true)
+	if err != nil {
+		return nil, err
+	}
+	return n.listxattr(), nil
+}
+
+func (n *memNode) listxattr() []string {
+	attrs := make([]string, 0, len(n.xattrs))
+	for attr := range n.xattrs {
+		attrs = append(attrs, attr)
+	}
+	slices.Sort(attrs)
+	return attrs
+}
+
+func (m *MemFS) fileInfo(name string, n *memNode) *memFileInfo {
+	return &memFileInfo{
+		name:  name,
+		size:  n.size(),
+		mode:  n.mode,
+		mtime: n.mtime,
+		attr: Attr{
+			Dev:   m.dev,
+			Ino:   n.ino,
+			Nlink: n.nlink,
+			Uid:   n.uid,
+			Gid:   n.gid,
+			Atime: n.atime,
+			Ctime: n.ctime,
+			Btime: n.btime,
+		},
+	}
+}
+
+// memFileInfo is a snapshot of the attributes
+type memFileInfo struct {
+	name  string
+	size  int64
+	mode  fs.FileMode
+	mtime time.Time
+	attr  Attr
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return i.size }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.mtime }
+func (i *memFileInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return &i.attr }
+
+// memFile is an open file of MemFS, it keeps the node even if the file is removed
+type memFile struct {
+	fs   *MemFS
+	node *memNode
+	name string
+	flag int
+
+	// mux guards the offset and the directory entries
+	mux     sync.Mutex
+	offset  int64
+	entries []fs.DirEntry
+	closed  bool
+}
+
+func (f *memFile) Name() string {
+	return f.name
+}
+
+// check returns the error for the operation if the file is closed, or cannot be read or written
+func (f *memFile) check(op string, write bool) error {
+	if f.closed {
+		return &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	if write && !isWrite(f.flag) || !write && f.flag&os.O_WRONLY != 0 {
+		return pathError(op, f.name, syscall.EBADF)
+	}
+	if f.node.mode.IsDir() && (op == "read" || op == "write") {
+		return pathError(op, f.name, syscall.EISDIR)
+	}
+	return nil
+}
+
+func (f *memFile) Read(b []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	n, err := f.readAt(b, f.offset)
+	f.offset += (int64)(n)
+	return n, err
+}
+
+func (f *memFile) ReadAt(b []byte, off int64) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if off < 0 {
+		return 0, pathError("read", f.name, syscall.EINVAL)
+	}
+	n, err := f.readAt(b, off)
+	if err == nil && n < len(b) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+func (f *memFile) readAt(b []byte, off int64) (int, error) {
+	if err := f.check("read", false); err != nil {
+		return 0, err
+	}
+	f.fs.mux.RLock()
+	defer f.fs.mux.RUnlock()
+	data := f.node.data
+	if off >= (int64)(len(data)) {
+		if len(b) == 0 {
+			return 0, nil
+		}
+		return 0, io.EOF
+	}
+	return copy(b, data[off:]), nil
+}
+
+func (f *memFile) Write(b []byte) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	n, err := f.writeAt(b, f.offset, f.flag&os.O_APPEND != 0)
+	f.offset = n
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (f *memFile) WriteAt(b []byte, off int64) (int, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.flag&os.O_APPEND != 0 {
+		return 0, &fs.PathError{Op: "writeat", Path: f.name, Err: os.ErrInvalid}
+	}
+	if off < 0 {
+		return 0, pathError("write", f.name, syscall.EINVAL)
+	}
+	if _, err := f.writeAt(b, off, false); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// writeAt writes b at the offset or the end of the file, and returns the offset after the written bytes
+func (f *memFile) writeAt(b []byte, off int64, appends bool) (int64, error) {
+	if err := f.check("write", true); err != nil {
+		return off, err
+	}
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	n := f.node
+	if appends {
+		off = (int64)(len(n.data))
+	}
+	if off > maxMemFileSize-(int64)(len(b)) {
+		return off, pathError("write", f.name, syscall.EFBIG)
+	}
+	end := off + (int64)(len(b))
+	if end > (int64)(len(n.data)) {
+		n.data = slices.Grow(n.data, (int)(end)-len(n.data))[:end]
+	}
+	copy(n.data[off:], b)
+	n.touch()
+	return end, nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		f.fs.mux.RLock()
+		offset += f.node.size()
+		f.fs.mux.RUnlock()
+	default:
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+	if offset < 0 {
+		return 0, pathError("seek", f.name, syscall.EINVAL)
+	}
+	f.offset = offset
+	if f.node.mode.IsDir() && offset == 0 {
+		f.entries = nil
+	}
+	return offset, nil
+}
+
+func (f *memFile) Close() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.closed = true
+	return nil
+}
+
+func (f *memFile) Stat() (fs.FileInfo, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "stat", Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mux.RLock()
+	defer f.fs.mux.RUnlock()
+	return f.fs.fileInfo(path.Base(cleanPath(f.name)), f.node), nil
+}
+
+// ReadDir takes a snapshot of the entries at the first call after opening or seeking to the start
+func (f *memFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return nil, &fs.PathError{Op: "readdirent", Path: f.name, Err: fs.ErrClosed}
+	}
+	if !f.node.mode.IsDir() {
+		return nil, pathError("readdirent", f.name, syscall.ENOTDIR)
+	}
+	if f.entries == nil {
+		f.fs.mux.RLock()
+		f.entries = f.fs.dirEntries(f.node)
+		f.fs.mux.RUnlock()
+		f.offset = 0
+	}
+	rest := f.entries[min(f.offset, (int64)(len(f.entries))):]
+	if n <= 0 {
+		f.offset += (int64)(len(rest))
+		return rest, nil
+	}
+	if len(rest) == 0 {
+		return nil, io.EOF
+	}
+	rest = rest[:min(n, len(rest))]
+	f.offset += (int64)(len(rest))
+	return rest, nil
+}
+
+func (f *memFile) Truncate(size int64) error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if err := f.check("truncate", true); err != nil {
+		return err
+	}
+	if size < 0 {
+		return pathError("truncate", f.name, syscall.EINVAL)
+	}
+	if size > maxMemFileSize {
+		return pathError("truncate", f.name, syscall.EFBIG)
+	}
+	f.fs.mux.Lock()
+	defer f.fs.mux.Unlock()
+	n := f.node
+	if size > (int64)(len(n.data)) {
+		n.data = slices.Grow(n.data, (int)(size)-len(n.data))[:size]
+	} else {
+		clear(n.data[size:])
+		n.data = n.data[:size]
+	}
+	n.touch()
+	return nil
+}
+
+func (f *memFile) Sync() error {
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	if f.closed {
+		return &fs.PathError{Op: "sync", Path: f.name, Err: fs.ErrClosed}
+	}
+	return nil
+}
+
+// lockNode locks the file and the filesystem to change the node, it returns the error for the operation if the file is closed.
+// The returned function unlocks them.
+func (f *memFile) lockNode(op string) (func(), error) {
+	f.mux.Lock()
+	if f.closed {
+		f.mux.Unlock()
+		return nil, &fs.PathError{Op: op, Path: f.name, Err: fs.ErrClosed}
+	}
+	f.fs.mux.Lock()
+	return func() {
+		f.fs.mux.Unlock()
+		f.mux.Unlock()
+	}, nil
+}
+
+func (f *memFile) Chmod(mode fs.FileMode) error {
+	unlock, err := f.lockNode("fchmod")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f.node.chmod(mode)
+	return nil
+}
+
+func (f *memFile) Chown(uid, gid int) error {
+	unlock, err := f.lockNode("fchown")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f.node.chown(uid, gid)
+	return nil
+}
+
+func (f *memFile) Chtimes(atime, mtime time.Time) error {
+	unlock, err := f.lockNode("futimens")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	f.node.chtimes(atime, mtime)
+	return nil
+}
+
+func (f *memFile) Getxattr(attr string) ([]byte, error) {
+	unlock, err := f.lockNode("fgetxattr")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	data, errno := f.node.getxattr(attr)
+	if errno != 0 {
+		return nil, pathError("fgetxattr", f.name, errno)
+	}
+	return data, nil
+}
+
+func (f *memFile) Setxattr(attr string, data []byte) error {
+	unlock, err := f.lockNode("fsetxattr")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if errno := f.node.setxattr(attr, data); errno != 0 {
+		return pathError("fsetxattr", f.name, errno)
+	}
+	return nil
+}
+
+func (f *memFile) Removexattr(attr string) error {
+	unlock, err := f.lockNode("fremovexattr")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if errno := f.node.removexattr(attr); errno != 0 {
+		return pathError("fremovexattr", f.name, errno)
+	}
+	return nil
+}
+
+func (f *memFile) Listxattr() ([]string, error) {
+	unlock, err := f.lockNode("flistxattr")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	return f.node.listxattr(), nil
+}
@@ -0,0 +1,296 @@
+package vfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// MountFS maps the directories onto other filesystems, like the mount table of Unix.
+// A name is served by the mount point which is its longest prefix,
+// and the filesystem receives the name relative to the mount point as an absolute name.
+//
+// The mount points do not need to exist in the parent filesystem, and they are not listed in its directories.
+// Symbolic links are resolved by the filesystem which has them, so they cannot point across mount points,
+// and renaming or linking across mount points fails with EXDEV.
+type MountFS struct {
+	mux    sync.RWMutex
+	mounts []mountPoint
+}
+
+var (
+	_ XattrFS   = (*MountFS)(nil)
+	_ StatfsFS  = (*MountFS)(nil)
+	_ XattrFile = (*mountFile)(nil)
+)
+
+type mountPoint struct {
+	// dir is the clean absolute path without the trailing slash, the root is empty
+	dir  string
+	fsys FS
+}
+
+// NewMountFS returns a MountFS which serves the names with root if no other filesystem is mounted on them
+func NewMountFS(root FS) *MountFS {
+	return &MountFS{
+		mounts: []mountPoint{{dir: "", fsys: root}},
+	}
+}
+
+func mountDir(dir string) string {
+	dir = cleanPath(dir)
+	if dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+// Mount mounts fsys on dir, it replaces the filesystem which is mounted on the same dir
+func (m *MountFS) Mount(dir string, fsys FS) {
+	dir = mountDir(dir)
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i, mp := range m.mounts {
+		if mp.dir == dir {
+			m.mounts[i].fsys = fsys
+			return
+		}
+	}
+	m.mounts = append(m.mounts, mountPoint{dir: dir, fsys: fsys})
+	// the longer directories are matched first
+	slices.SortStableFunc(m.mounts, func(a, b mountPoint) int {
+		return len(b.dir) - len(a.dir)
+	})
+}
+
+// Unmount removes the filesystem mounted on dir, and reports whether it was mounted.
+// The root filesystem cannot be unmounted.
+func (m *MountFS) Unmount(dir string) bool {
+	dir = mountDir(dir)
+	if dir == "" {
+		return false
+	}
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	for i, mp := range m.mounts {
+		if mp.dir == dir {
+			m.mounts = slices.Delete(m.mounts, i, i+1)
+			return true
+		}
+	}
+	return false
+}
+
+// resolve returns the filesystem serving the name and the name inside of it
+func (m *MountFS) resolve(name string) (FS, string) {
+	p := cleanPath(name)
+	m.mux.RLock()
+	defer m.mux.RUnlock()
+	for _, mp := range m.mounts {
+		if rest, ok := strings.CutPrefix(p, mp.dir); ok && (rest == "" || rest[0] == '/') {
+			if rest == "" {
+				rest = "/"
+			}
+			return mp.fsys, rest
+		}
+	}
+	panic("unreachable: the root is always mounted")
+}
+
+// resolve2 resolves the names of the operations on two files, which must be on the same filesystem
+func (m *MountFS) resolve2(op, oldname, newname string) (FS, string, string, error) {
+	oldfs, oldp := m.resolve(oldname)
+	newfs, newp := m.resolve(newname)
+	if oldfs != newfs {
+		return nil, "", "", linkError(op, oldname, newname, syscall.EXDEV)
+	}
+	return oldfs, oldp, newp, nil
+}
+
+// withName replaces the names in the errors of the filesystems with the names of MountFS
+func withName(err error, name string) error {
+	var pe *fs.PathError
+	if errors.As(err, &pe) {
+		return &fs.PathError{Op: pe.Op, Path: name, Err: pe.Err}
+	}
+	return err
+}
+
+func withNames(err error, oldname, newname string) error {
+	var le *os.LinkError
+	if errors.As(err, &le) {
+		return &os.LinkError{Op: le.Op, Old: oldname, New: newname, Err: le.Err}
+	}
+	return withName(err, oldname)
+}
+
+func (m *MountFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	fsys, p := m.resolve(name)
+	f, err := fsys.OpenFile(p, flag, perm)
+	if err != nil {
+		return nil, withName(err, name)
+	}
+	return &mountFile{
+		File: f,
+		name: name,
+	}, nil
+}
+
+func (m *MountFS) Stat(name string) (fs.FileInfo, error) {
+	fsys, p := m.resolve(name)
+	stat, err := fsys.Stat(p)
+	return stat, withName(err, name)
+}
+
+func (m *MountFS) Lstat(name string) (fs.FileInfo, error) {
+	fsys, p := m.resolve(name)
+	stat, err := fsys.Lstat(p)
+	return stat, withName(err, name)
+}
+
+func (m *MountFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	fsys, p := m.resolve(name)
+	entries, err := fsys.ReadDir(p)
+	return entries, withName(err, name)
+}
+
+func (m *MountFS) Mkdir(name string, perm fs.FileMode) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Mkdir(p, perm), name)
+}
+
+func (m *MountFS) Rename(oldname, newname string) error {
+	fsys, oldp, newp, err := m.resolve2("rename", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return withNames(fsys.Rename(oldp, newp), oldname, newname)
+}
+
+func (m *MountFS) Unlink(name string) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Unlink(p), name)
+}
+
+func (m *MountFS) Rmdir(name string) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Rmdir(p), name)
+}
+
+func (m *MountFS) Link(oldname, newname string) error {
+	fsys, oldp, newp, err := m.resolve2("link", oldname, newname)
+	if err != nil {
+		return err
+	}
+	return withNames(fsys.Link(oldp, newp), oldname, newname)
+}
+
+// Symlink stores the target as is, it is resolved by the filesystem of name
+func (m *MountFS) Symlink(target, name string) error {
+	fsys, p := m.resolve(name)
+	return withNames(fsys.Symlink(target, p), target, name)
+}
+
+func (m *MountFS) Readlink(name string) (string, error) {
+	fsys, p := m.resolve(name)
+	target, err := fsys.Readlink(p)
+	return target, withName(err, name)
+}
+
+func (m *MountFS) Chmod(name string, mode fs.FileMode) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Chmod(p, mode), name)
+}
+
+func (m *MountFS) Chown(name string, uid, gid int) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Chown(p, uid, gid), name)
+}
+
+func (m *MountFS) Lchown(name string, uid, gid int) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Lchown(p, uid, gid), name)
+}
+
+func (m *MountFS) Chtimes(name string, atime, mtime time.Time) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Chtimes(p, atime, mtime), name)
+}
+
+func (m *MountFS) Access(name string, mode uint32) error {
+	fsys, p := m.resolve(name)
+	return withName(fsys.Access(p, mode), name)
+}
+
+func (m *MountFS) Getxattr(name, attr string) ([]byte, error) {
+	fsys, p := m.resolve(name)
+	data, err := Getxattr(fsys, p, attr)
+	return data, withName(err, name)
+}
+
+func (m *MountFS) Setxattr(name, attr string, data []byte) error {
+	fsys, p := m.resolve(name)
+	return withName(Setxattr(fsys, p, attr, data), name)
+}
+
+func (m *MountFS) Removexattr(name, attr string) error {
+	fsys, p := m.resolve(name)
+	return withName(Removexattr(fsys, p, attr), name)
+}
+
+func (m *MountFS) Listxattr(name string) ([]string, error) {
+	fsys, p := m.resolve(name)
+	attrs, err := Listxattr(fsys, p)
+	return attrs, withName(err, name)
+}
+
+func (m *MountFS) Statfs(name string) (FSInfo, error) {
+	fsys, p := m.resolve(name)
+	info, err := Statfs(fsys, p)
+	return info, withName(err, name)
+}
+
+// mountFile reports the name of MountFS instead of the one inside the mounted filesystem
+type mountFile struct {
+	File
+	name string
+}
+
+func (f *mountFile) Name() string {
+	return f.name
+}
+
+func (f *mountFile) Chmod(mode fs.FileMode) error {
+	return withName(Fchmod(f.File, mode), f.name)
+}
+
+func (f *mountFile) Chown(uid, gid int) error {
+	return withName(Fchown(f.File, uid, gid), f.name)
+}
+
+func (f *mountFile) Chtimes(atime, mtime time.Time) error {
+	return withName(Fchtimes(f.File, atime, mtime), f.name)
+}
+
+func (f *mountFile) Getxattr(attr string) ([]byte, error) {
+	data, err := Fgetxattr(f.File, attr)
+	return data, withName(err, f.name)
+}
+
+func (f *mountFile) Setxattr(attr string, data []byte) error {
+	return withName(Fsetxattr(f.File, attr, data), f.name)
+}
+
+func (f *mountFile) Removexattr(attr string) error {
+	return withName(Fremovexattr(f.File, attr), f.name)
+}
+
+func (f *mountFile) Listxattr() ([]string, error) {
+	attrs, err := Flistxattr(f.File)
+	return attrs, withName(err, f.name)
+}
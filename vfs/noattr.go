@@ -0,0 +1,8 @@
+//go:build !freebsd && !openbsd && !dragonfly && !wasip1
+
+package vfs
+
+import "syscall"
+
+// ENOATTR is the error of getting or removing a missing extended attribute
+const ENOATTR = syscall.ENODATA
@@ -0,0 +1,8 @@
+//go:build freebsd || openbsd || dragonfly
+
+package vfs
+
+import "syscall"
+
+// ENOATTR is the error of getting or removing a missing extended attribute
+const ENOATTR = syscall.ENOATTR
@@ -0,0 +1,9 @@
+//go:build wasip1
+
+package vfs
+
+import "syscall"
+
+// ENOATTR is the error of getting or removing a missing extended attribute,
+// WASI does not have ENODATA
+const ENOATTR = syscall.ENOENT
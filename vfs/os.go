@@ -0,0 +1,126 @@
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// osFS is the host filesystem
+type osFS struct {
+	root string
+}
+
+// NewOSFS returns the host filesystem under the directory root.
+// If root is empty, names are host paths as is, and relative names are relative to the working directory of the process.
+// It does not sandbox the files, since symbolic links can point outside of root.
+func NewOSFS(root string) FS {
+	return &osFS{
+		root: root,
+	}
+}
+
+func (o *osFS) path(name string) string {
+	if o.root == "" {
+		return filepath.FromSlash(name)
+	}
+	return filepath.Join(o.root, filepath.FromSlash(cleanPath(name)))
+}
+
+func (o *osFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	f, err := os.OpenFile(o.path(name), flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	return &osFile{File: f, name: name}, nil
+}
+
+func (o *osFS) Stat(name string) (fs.FileInfo, error) {
+	return os.Stat(o.path(name))
+}
+
+func (o *osFS) Lstat(name string) (fs.FileInfo, error) {
+	return os.Lstat(o.path(name))
+}
+
+func (o *osFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(o.path(name))
+}
+
+func (o *osFS) Mkdir(name string, perm fs.FileMode) error {
+	return os.Mkdir(o.path(name), perm)
+}
+
+func (o *osFS) Rename(oldname, newname string) error {
+	return rename(o.path(oldname), o.path(newname))
+}
+
+func (o *osFS) Unlink(name string) error {
+	return unlink(o.path(name))
+}
+
+func (o *osFS) Rmdir(name string) error {
+	return rmdir(o.path(name))
+}
+
+func (o *osFS) Link(oldname, newname string) error {
+	return os.Link(o.path(oldname), o.path(newname))
+}
+
+func (o *osFS) Symlink(target, name string) error {
+	// the target is relative to the link or absolute on the host, so it is not mapped under root
+	return os.Symlink(filepath.FromSlash(target), o.path(name))
+}
+
+func (o *osFS) Readlink(name string) (string, error) {
+	target, err := os.Readlink(o.path(name))
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(target), nil
+}
+
+func (o *osFS) Chmod(name string, mode fs.FileMode) error {
+	return os.Chmod(o.path(name), mode)
+}
+
+func (o *osFS) Chown(name string, uid, gid int) error {
+	return os.Chown(o.path(name), uid, gid)
+}
+
+func (o *osFS) Lchown(name string, uid, gid int) error {
+	return os.Lchown(o.path(name), uid, gid)
+}
+
+func (o *osFS) Chtimes(name string, atime, mtime time.Time) error {
+	return os.Chtimes(o.path(name), atime, mtime)
+}
+
+// Getwd returns the working directory of the process if there is no root, otherwise "/"
+func (o *osFS) Getwd() (string, error) {
+	if o.root != "" {
+		return "/", nil
+	}
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+	return filepath.ToSlash(dir), nil
+}
+
+func (o *osFS) Access(name string, mode uint32) error {
+	return access(o.path(name), mode)
+}
+
+// osFile reports the name inside root instead of the host path,
+// since the natives use the name of an open file to access it through the filesystem again.
+// The promoted Chmod and Chown of *os.File are used by Fchmod and Fchown.
+type osFile struct {
+	*os.File
+	name string
+}
+
+func (f *osFile) Name() string {
+	return f.name
+}
@@ -0,0 +1,178 @@
+package vfs
+
+import (
+	"io/fs"
+	"runtime"
+	"strings"
+	"syscall"
+	"unsafe"
+)
+
+// the extended attributes and the statistics of the host filesystem are only supported on Linux
+var (
+	_ XattrFS   = (*osFS)(nil)
+	_ StatfsFS  = (*osFS)(nil)
+	_ XattrFile = (*osFile)(nil)
+)
+
+func (o *osFS) Getxattr(name, attr string) ([]byte, error) {
+	p := o.path(name)
+	for {
+		size, err := syscall.Getxattr(p, attr, nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "getxattr", Path: p, Err: err}
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Getxattr(p, attr, buf)
+		if err == syscall.ERANGE {
+			// the attribute grew after the size is read
+			continue
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "getxattr", Path: p, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
+
+func (o *osFS) Setxattr(name, attr string, data []byte) error {
+	p := o.path(name)
+	if err := syscall.Setxattr(p, attr, data, 0); err != nil {
+		return &fs.PathError{Op: "setxattr", Path: p, Err: err}
+	}
+	return nil
+}
+
+func (o *osFS) Removexattr(name, attr string) error {
+	p := o.path(name)
+	if err := syscall.Removexattr(p, attr); err != nil {
+		return &fs.PathError{Op: "removexattr", Path: p, Err: err}
+	}
+	return nil
+}
+
+func (o *osFS) Listxattr(name string) ([]string, error) {
+	p := o.path(name)
+	for {
+		size, err := syscall.Listxattr(p, nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "listxattr", Path: p, Err: err}
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := syscall.Listxattr(p, buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "listxattr", Path: p, Err: err}
+		}
+		return strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00"), nil
+	}
+}
+
+func (o *osFS) Statfs(name string) (FSInfo, error) {
+	p := o.path(name)
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(p, &st); err != nil {
+		return FSInfo{}, &fs.PathError{Op: "statfs", Path: p, Err: err}
+	}
+	return FSInfo{
+		BlockSize:   (uint64)(st.Frsize),
+		Blocks:      (uint64)(st.Blocks),
+		BlocksFree:  (uint64)(st.Bfree),
+		BlocksAvail: (uint64)(st.Bavail),
+	}, nil
+}
+
+func (f *osFile) Getxattr(attr string) ([]byte, error) {
+	for {
+		size, err := f.xattr(syscall.SYS_FGETXATTR, attr, nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "fgetxattr", Path: f.name, Err: err}
+		}
+		buf := make([]byte, size)
+		n, err := f.xattr(syscall.SYS_FGETXATTR, attr, buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "fgetxattr", Path: f.name, Err: err}
+		}
+		return buf[:n], nil
+	}
+}
+
+func (f *osFile) Setxattr(attr string, data []byte) error {
+	if _, err := f.xattr(syscall.SYS_FSETXATTR, attr, data); err != nil {
+		return &fs.PathError{Op: "fsetxattr", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+func (f *osFile) Removexattr(attr string) error {
+	if _, err := f.xattr(syscall.SYS_FREMOVEXATTR, attr, nil); err != nil {
+		return &fs.PathError{Op: "fremovexattr", Path: f.name, Err: err}
+	}
+	return nil
+}
+
+func (f *osFile) Listxattr() ([]string, error) {
+	for {
+		size, err := f.xattr(syscall.SYS_FLISTXATTR, "", nil)
+		if err != nil {
+			return nil, &fs.PathError{Op: "flistxattr", Path: f.name, Err: err}
+		}
+		if size == 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size)
+		n, err := f.xattr(syscall.SYS_FLISTXATTR, "", buf)
+		if err == syscall.ERANGE {
+			continue
+		}
+		if err != nil {
+			return nil, &fs.PathError{Op: "flistxattr", Path: f.name, Err: err}
+		}
+		return strings.Split(strings.TrimSuffix(string(buf[:n]), "\x00"), "\x00"), nil
+	}
+}
+
+// xattr calls the descriptor version of the xattr syscalls, which the syscall package does not wrap.
+// flistxattr has no attribute name, so its buffer is the second argument.
+func (f *osFile) xattr(trap uintptr, attr string, buf []byte) (int, error) {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var name *byte
+	if trap != syscall.SYS_FLISTXATTR {
+		if name, err = syscall.BytePtrFromString(attr); err != nil {
+			return 0, err
+		}
+	}
+	var data unsafe.Pointer
+	if len(buf) > 0 {
+		data = unsafe.Pointer(&buf[0])
+	}
+	var r uintptr
+	var errno syscall.Errno
+	err = conn.Control(func(fd uintptr) {
+		if trap == syscall.SYS_FLISTXATTR {
+			r, _, errno = syscall.Syscall(trap, fd, (uintptr)(data), (uintptr)(len(buf)))
+		} else {
+			r, _, errno = syscall.Syscall6(trap, fd, (uintptr)(unsafe.Pointer(name)), (uintptr)(data), (uintptr)(len(buf)), 0, 0)
+		}
+	})
+	runtime.KeepAlive(name)
+	runtime.KeepAlive(buf)
+	if err != nil {
+		return 0, err
+	}
+	if errno != 0 {
+		return 0, errno
+	}
+	return (int)(r), nil
+}
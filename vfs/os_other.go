@@ -0,0 +1,47 @@
+//go:build !unix
+
+package vfs
+
+import (
+	"os"
+	"syscall"
+)
+
+// unlink and rmdir check the file type before os.Remove, which removes both files and directories
+func unlink(name string) error {
+	stat, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if stat.IsDir() {
+		return pathError("unlink", name, syscall.EISDIR)
+	}
+	return os.Remove(name)
+}
+
+func rmdir(name string) error {
+	stat, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if !stat.IsDir() {
+		return pathError("rmdir", name, syscall.ENOTDIR)
+	}
+	return os.Remove(name)
+}
+
+// access approximates access(2) with the owner permission bits
+func access(name string, mode uint32) error {
+	stat, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	if (uint32)(stat.Mode().Perm()>>6)&mode != mode {
+		return pathError("access", name, syscall.EACCES)
+	}
+	return nil
+}
+
+func rename(oldname, newname string) error {
+	return os.Rename(oldname, newname)
+}
@@ -0,0 +1,38 @@
+//go:build unix
+
+package vfs
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+func unlink(name string) error {
+	if err := syscall.Unlink(name); err != nil {
+		return &fs.PathError{Op: "unlink", Path: name, Err: err}
+	}
+	return nil
+}
+
+func rmdir(name string) error {
+	if err := syscall.Rmdir(name); err != nil {
+		return &fs.PathError{Op: "rmdir", Path: name, Err: err}
+	}
+	return nil
+}
+
+func access(name string, mode uint32) error {
+	if err := syscall.Access(name, mode); err != nil {
+		return &fs.PathError{Op: "access", Path: name, Err: err}
+	}
+	return nil
+}
+
+// rename replaces an empty directory like rename(2), which os.Rename refuses
+func rename(oldname, newname string) error {
+	if err := syscall.Rename(oldname, newname); err != nil {
+		return &os.LinkError{Op: "rename", Old: oldname, New: newname, Err: err}
+	}
+	return nil
+}
@@ -0,0 +1,206 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"syscall"
+	"time"
+)
+
+// readOnlyFS serves the files of an fs.FS, which has no symbolic links
+type readOnlyFS struct {
+	fsys fs.FS
+}
+
+// NewReadOnlyFS returns a read only FS of fsys, e.g. an embed.FS or a zip.Reader.
+// The modifications fail with EROFS.
+func NewReadOnlyFS(fsys fs.FS) FS {
+	return &readOnlyFS{
+		fsys: fsys,
+	}
+}
+
+// path converts the name to the path of fs.FS
+func (r *readOnlyFS) path(name string) string {
+	p := cleanPath(name)
+	if p == "/" {
+		return "."
+	}
+	return p[1:]
+}
+
+// convertError replaces the errors of fs.FS, which may not be syscall.Errno
+func convertError(op, name string, err error) error {
+	var errno syscall.Errno
+	switch {
+	case errors.As(err, &errno):
+	case errors.Is(err, fs.ErrNotExist):
+		errno = syscall.ENOENT
+	case errors.Is(err, fs.ErrPermission):
+		errno = syscall.EACCES
+	case errors.Is(err, fs.ErrInvalid):
+		errno = syscall.EINVAL
+	default:
+		errno = syscall.EIO
+	}
+	return pathError(op, name, errno)
+}
+
+func (r *readOnlyFS) OpenFile(name string, flag int, perm fs.FileMode) (File, error) {
+	if isWrite(flag) || flag&(os.O_CREATE|os.O_TRUNC) != 0 {
+		return nil, pathError("open", name, syscall.EROFS)
+	}
+	f, err := r.fsys.Open(r.path(name))
+	if err != nil {
+		return nil, convertError("open", name, err)
+	}
+	return &readOnlyFile{
+		File: f,
+		name: name,
+	}, nil
+}
+
+func (r *readOnlyFS) Stat(name string) (fs.FileInfo, error) {
+	stat, err := fs.Stat(r.fsys, r.path(name))
+	if err != nil {
+		return nil, convertError("stat", name, err)
+	}
+	return stat, nil
+}
+
+func (r *readOnlyFS) Lstat(name string) (fs.FileInfo, error) {
+	stat, err := fs.Stat(r.fsys, r.path(name))
+	if err != nil {
+		return nil, convertError("lstat", name, err)
+	}
+	return stat, nil
+}
+
+func (r *readOnlyFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	entries, err := fs.ReadDir(r.fsys, r.path(name))
+	if err != nil {
+		return nil, convertError("readdirent", name, err)
+	}
+	return entries, nil
+}
+
+func (r *readOnlyFS) Mkdir(name string, perm fs.FileMode) error {
+	return pathError("mkdir", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Rename(oldname, newname string) error {
+	return linkError("rename", oldname, newname, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Unlink(name string) error {
+	return pathError("unlink", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Rmdir(name string) error {
+	return pathError("rmdir", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Link(oldname, newname string) error {
+	return linkError("link", oldname, newname, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Symlink(target, name string) error {
+	return linkError("symlink", target, name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Readlink(name string) (string, error) {
+	if _, err := r.Lstat(name); err != nil {
+		return "", err
+	}
+	return "", pathError("readlink", name, syscall.EINVAL)
+}
+
+func (r *readOnlyFS) Chmod(name string, mode fs.FileMode) error {
+	return pathError("chmod", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Chown(name string, uid, gid int) error {
+	return pathError("chown", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Lchown(name string, uid, gid int) error {
+	return pathError("lchown", name, syscall.EROFS)
+}
+
+func (r *readOnlyFS) Chtimes(name string, atime, mtime time.Time) error {
+	return pathError("chtimes", name, syscall.EROFS)
+}
+
+// Access checks the existence, and the execute permission with any execute bit
+func (r *readOnlyFS) Access(name string, mode uint32) error {
+	stat, err := r.Stat(name)
+	if err != nil {
+		return err
+	}
+	if mode&AccessWrite != 0 {
+		return pathError("access", name, syscall.EROFS)
+	}
+	if mode&AccessExecute != 0 && stat.Mode().Perm()&0111 == 0 {
+		return pathError("access", name, syscall.EACCES)
+	}
+	return nil
+}
+
+type readOnlyFile struct {
+	fs.File
+	name string
+}
+
+func (f *readOnlyFile) Name() string {
+	return f.name
+}
+
+func (f *readOnlyFile) Read(b []byte) (int, error) {
+	n, err := f.File.Read(b)
+	if err != nil && err != io.EOF {
+		err = convertError("read", f.name, err)
+	}
+	return n, err
+}
+
+func (f *readOnlyFile) Write(b []byte) (int, error) {
+	return 0, pathError("write", f.name, syscall.EBADF)
+}
+
+func (f *readOnlyFile) WriteAt(b []byte, off int64) (int, error) {
+	return 0, pathError("write", f.name, syscall.EBADF)
+}
+
+func (f *readOnlyFile) Seek(offset int64, whence int) (int64, error) {
+	s, ok := f.File.(io.Seeker)
+	if !ok {
+		return 0, pathError("seek", f.name, syscall.ESPIPE)
+	}
+	return s.Seek(offset, whence)
+}
+
+func (f *readOnlyFile) ReadAt(b []byte, off int64) (int, error) {
+	r, ok := f.File.(io.ReaderAt)
+	if !ok {
+		return 0, pathError("read", f.name, syscall.ESPIPE)
+	}
+	return r.ReadAt(b, off)
+}
+
+func (f *readOnlyFile) ReadDir(n int) ([]fs.DirEntry, error) {
+	d, ok := f.File.(fs.ReadDirFile)
+	if !ok {
+		return nil, pathError("readdirent", f.name, syscall.ENOTDIR)
+	}
+	return d.ReadDir(n)
+}
+
+func (f *readOnlyFile) Truncate(size int64) error {
+	return pathError("truncate", f.name, syscall.EINVAL)
+}
+
+func (f *readOnlyFile) Sync() error {
+	return nil
+}
@@ -0,0 +1,177 @@
+// Package vfs is the filesystem layer between the Java file natives and the storage.
+//
+// The VM accesses files only through the FS in vm.Options,
+// so the deployments without a real filesystem, e.g. wasm or sandboxes,
+// can provide files from memory, embedded archives or a mix of them with MountFS.
+package vfs
+
+import (
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FS is a POSIX-like filesystem.
+//
+// Names are slash separated paths. The implementations except the host one of NewOSFS
+// resolve them from their root, so relative names are treated as absolute.
+//
+// Errors are *fs.PathError, or *os.LinkError for the operations with two names,
+// which wrap a syscall.Errno, so the natives can report the errno to Java.
+type FS interface {
+	// OpenFile opens the file with the flags of os.OpenFile.
+	// Directories can be opened read only for ReadDir.
+	OpenFile(name string, flag int, perm fs.FileMode) (File, error)
+	// Stat follows symbolic links, and Lstat does not
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	// ReadDir returns the entries of the directory sorted by name
+	ReadDir(name string) ([]fs.DirEntry, error)
+	Mkdir(name string, perm fs.FileMode) error
+	// Rename replaces newname if it exists, like rename(2)
+	Rename(oldname, newname string) error
+	// Unlink removes a non-directory file, and Rmdir removes an empty directory
+	Unlink(name string) error
+	Rmdir(name string) error
+	// Link creates newname as a hard link to oldname
+	Link(oldname, newname string) error
+	// Symlink creates name as a symbolic link to target
+	Symlink(target, name string) error
+	Readlink(name string) (string, error)
+	// Chmod changes the permission bits, including the setuid, setgid and sticky bits
+	Chmod(name string, mode fs.FileMode) error
+	// Chown and Lchown leave the uid or gid unchanged if it is -1
+	Chown(name string, uid, gid int) error
+	Lchown(name string, uid, gid int) error
+	// Chtimes leaves the time unchanged if it is zero
+	Chtimes(name string, atime, mtime time.Time) error
+	// Access checks the permissions like access(2), mode is a combination of AccessRead, AccessWrite and AccessExecute,
+	// or AccessExist to check the existence.
+	Access(name string, mode uint32) error
+}
+
+// The modes of FS.Access
+const (
+	AccessExist   = 0
+	AccessExecute = 1
+	AccessWrite   = 2
+	AccessRead    = 4
+)
+
+// File is an open file of FS. *os.File implements it.
+type File interface {
+	io.Reader
+	io.Writer
+	io.Seeker
+	io.ReaderAt
+	io.WriterAt
+	io.Closer
+	// Name returns the name which the file is opened with
+	Name() string
+	Stat() (fs.FileInfo, error)
+	// ReadDir reads the entries of the directory like os.File.ReadDir
+	ReadDir(n int) ([]fs.DirEntry, error)
+	Truncate(size int64) error
+	Sync() error
+}
+
+// Attr is the attributes which fs.FileInfo does not have.
+// FileInfo.Sys returns *Attr for the files of MemFS.
+type Attr struct {
+	Dev   uint64
+	Ino   uint64
+	Rdev  uint64
+	Nlink uint64
+	Uid   int
+	Gid   int
+	Atime time.Time
+	Ctime time.Time
+	// Btime is the creation time, it is zero if the filesystem does not record it
+	Btime time.Time
+}
+
+// AttrOf returns the attributes of the file from FileInfo.Sys,
+// which is *Attr or the stat structure of the host.
+// The missing attributes are filled with the ones of a file with a single link and owned by root,
+// whose access and change time are its modification time.
+func AttrOf(info fs.FileInfo) Attr {
+	switch sys := info.Sys().(type) {
+	case *Attr:
+		return *sys
+	case Attr:
+		return sys
+	}
+	if attr, ok := hostAttr(info.Sys()); ok {
+		return attr
+	}
+	return Attr{
+		Nlink: 1,
+		Atime: info.ModTime(),
+		Ctime: info.ModTime(),
+	}
+}
+
+// cleanPath converts the name to an absolute slash separated path
+func cleanPath(name string) string {
+	return path.Clean("/" + name)
+}
+
+func pathError(op, name string, errno syscall.Errno) error {
+	return &fs.PathError{Op: op, Path: name, Err: errno}
+}
+
+func linkError(op, oldname, newname string, errno syscall.Errno) error {
+	return &os.LinkError{Op: op, Old: oldname, New: newname, Err: errno}
+}
+
+// isWrite reports whether the flag of OpenFile opens the file for writing
+func isWrite(flag int) bool {
+	return flag&(os.O_WRONLY|os.O_RDWR) != 0
+}
+
+// Realpath returns the absolute name of the file which has no symbolic links, like realpath(3).
+// The "." and ".." elements of name are removed before resolving the links.
+func Realpath(fsys FS, name string) (string, error) {
+	links := 0
+	resolved := "/"
+	rest := strings.Split(cleanPath(name)[1:], "/")
+	for len(rest) > 0 {
+		elem := rest[0]
+		rest = rest[1:]
+		if elem == "" {
+			continue
+		}
+		next := path.Join(resolved, elem)
+		stat, err := fsys.Lstat(next)
+		if err != nil {
+			return "", err
+		}
+		if stat.Mode().Type() == fs.ModeSymlink {
+			if links++; links > maxSymlinks {
+				return "", pathError("realpath", name, syscall.ELOOP)
+			}
+			target, err := fsys.Readlink(next)
+			if err != nil {
+				return "", err
+			}
+			// resolved has no links, so the ".." elements of the target can be removed lexically
+			if path.IsAbs(target) {
+				target = path.Clean(target)
+			} else {
+				target = path.Join(resolved, target)
+			}
+			rest = append(strings.Split(target[1:], "/"), rest...)
+			resolved = "/"
+			continue
+		}
+		if len(rest) > 0 && !stat.IsDir() {
+			return "", pathError("realpath", name, syscall.ENOTDIR)
+		}
+		resolved = next
+	}
+	return resolved, nil
+}
@@ -0,0 +1,369 @@
+package vfs
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"syscall"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func readFile(t *testing.T, fsys FS, name string) string {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func writeFile(t *testing.T, fsys FS, name string, data string) {
+	t.Helper()
+	f, err := fsys.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := io.WriteString(f, data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectErrno(t *testing.T, what string, err error, errno syscall.Errno) {
+	t.Helper()
+	if !errors.Is(err, errno) {
+		t.Errorf("%s: got error %v, want %v", what, err, errno)
+	}
+}
+
+func dirNames(t *testing.T, fsys FS, name string) []string {
+	t.Helper()
+	entries, err := fsys.ReadDir(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(entries))
+	for i, e := range entries {
+		names[i] = e.Name()
+	}
+	return names
+}
+
+// testPOSIX checks the semantics which both MemFS and the host filesystem have
+func testPOSIX(t *testing.T, fsys FS) {
+	if err := fsys.Mkdir("/d", 0755); err != nil {
+		t.Fatal(err)
+	}
+	expectErrno(t, "mkdir existing", fsys.Mkdir("/d", 0755), syscall.EEXIST)
+	expectErrno(t, "mkdir in missing dir", fsys.Mkdir("/x/y", 0755), syscall.ENOENT)
+
+	writeFile(t, fsys, "/d/a", "hello")
+	if got := readFile(t, fsys, "/d/a"); got != "hello" {
+		t.Errorf("read %q", got)
+	}
+	_, err := fsys.OpenFile("/d/a", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	expectErrno(t, "exclusive create", err, syscall.EEXIST)
+	_, err = fsys.OpenFile("/d/missing", os.O_RDONLY, 0)
+	expectErrno(t, "open missing", err, syscall.ENOENT)
+	_, err = fsys.OpenFile("/d", os.O_WRONLY, 0)
+	expectErrno(t, "open dir for writing", err, syscall.EISDIR)
+	_, err = fsys.Stat("/d/a/b")
+	expectErrno(t, "stat under file", err, syscall.ENOTDIR)
+
+	// append, seek, random access and truncate
+	f, err := fsys.OpenFile("/d/a", os.O_RDWR|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(f, " world")
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	if n, err := f.ReadAt(buf, 6); n != 5 || err != nil || string(buf) != "world" {
+		t.Errorf("ReadAt: %d %v %q", n, err, buf)
+	}
+	if err := f.Truncate(2); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := f.Stat(); err != nil || stat.Size() != 2 || !stat.Mode().IsRegular() {
+		t.Errorf("Stat after truncate: %v %v", stat, err)
+	}
+	f.Close()
+	if got := readFile(t, fsys, "/d/a"); got != "he" {
+		t.Errorf("read %q after truncate", got)
+	}
+
+	// links
+	if err := fsys.Symlink("a", "/d/s"); err != nil {
+		t.Fatal(err)
+	}
+	if target, err := fsys.Readlink("/d/s"); err != nil || target != "a" {
+		t.Errorf("readlink: %q %v", target, err)
+	}
+	if got := readFile(t, fsys, "/d/s"); got != "he" {
+		t.Errorf("read %q through symlink", got)
+	}
+	if stat, err := fsys.Lstat("/d/s"); err != nil || stat.Mode().Type() != fs.ModeSymlink {
+		t.Errorf("lstat symlink: %v %v", stat, err)
+	}
+	_, err = fsys.Readlink("/d/a")
+	expectErrno(t, "readlink regular file", err, syscall.EINVAL)
+	if err := fsys.Link("/d/a", "/d/h"); err != nil {
+		t.Fatal(err)
+	}
+	if got := dirNames(t, fsys, "/d"); !slices.Equal(got, []string{"a", "h", "s"}) {
+		t.Errorf("entries %v", got)
+	}
+
+	// removing
+	expectErrno(t, "unlink dir", fsys.Unlink("/d"), syscall.EISDIR)
+	expectErrno(t, "rmdir file", fsys.Rmdir("/d/a"), syscall.ENOTDIR)
+	expectErrno(t, "rmdir non-empty", fsys.Rmdir("/d"), syscall.ENOTEMPTY)
+	if err := fsys.Unlink("/d/a"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, fsys, "/d/h"); got != "he" {
+		t.Errorf("read %q from hard link", got)
+	}
+	_, err = fsys.Stat("/d/s")
+	expectErrno(t, "stat dangling symlink", err, syscall.ENOENT)
+
+	// renaming
+	if err := fsys.Mkdir("/e", 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("/d/h", "/e/h"); err != nil {
+		t.Fatal(err)
+	}
+	expectErrno(t, "rename dir into itself", fsys.Rename("/d", "/d/sub"), syscall.EINVAL)
+	expectErrno(t, "rename dir onto non-empty dir", fsys.Rename("/d", "/e"), syscall.ENOTEMPTY)
+	if err := fsys.Unlink("/d/s"); err != nil {
+		t.Fatal(err)
+	}
+	if err := fsys.Rename("/e", "/d"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, fsys, "/d/h"); got != "he" {
+		t.Errorf("read %q after renaming the dir", got)
+	}
+
+	// attributes
+	if err := fsys.Chmod("/d/h", 0400); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := fsys.Stat("/d/h"); err != nil || stat.Mode().Perm() != 0400 {
+		t.Errorf("mode after chmod: %v %v", stat, err)
+	}
+	if err := fsys.Access("/d/h", AccessRead); err != nil {
+		t.Errorf("access read: %v", err)
+	}
+	mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := fsys.Chtimes("/d/h", time.Time{}, mtime); err != nil {
+		t.Fatal(err)
+	}
+	if stat, err := fsys.Stat("/d/h"); err != nil || !stat.ModTime().Equal(mtime) {
+		t.Errorf("mtime after chtimes: %v %v", stat, err)
+	}
+	expectErrno(t, "access missing", fsys.Access("/d/missing", AccessExist), syscall.ENOENT)
+}
+
+func TestMemFS(t *testing.T) {
+	m := NewMemFS()
+	testPOSIX(t, m)
+
+	// owner bits are checked even for root
+	expectErrno(t, "access write", m.Access("/d/h", AccessWrite), syscall.EACCES)
+	stat, err := m.Stat("/d/h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr := AttrOf(stat); attr.Nlink != 1 || attr.Ino == 0 || attr.Dev != m.dev || attr.Btime.IsZero() {
+		t.Errorf("unexpected attributes %+v", attr)
+	}
+
+	if err := Setxattr(m, "/d/h", "user.k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Getxattr(m, "/d/h", "user.k"); err != nil || string(data) != "v" {
+		t.Errorf("getxattr: %q %v", data, err)
+	}
+	if attrs, err := Listxattr(m, "/d/h"); err != nil || !slices.Equal(attrs, []string{"user.k"}) {
+		t.Errorf("listxattr: %v %v", attrs, err)
+	}
+	m.Removexattr("/d/h", "user.k")
+	_, err = Getxattr(m, "/d/h", "user.k")
+	expectErrno(t, "getxattr removed", err, ENOATTR)
+
+	// a removed file is still accessible through the opened file
+	f, err := m.OpenFile("/d/h", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Unlink("/d/h")
+	if data, err := io.ReadAll(f); err != nil || string(data) != "he" {
+		t.Errorf("read %q %v from removed file", data, err)
+	}
+	// the attributes are changed through the descriptor instead of the name
+	if err := Fchmod(f, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Fsetxattr(f, "user.k", []byte("v")); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := Fgetxattr(f, "user.k"); err != nil || string(data) != "v" {
+		t.Errorf("fgetxattr: %q %v", data, err)
+	}
+	if stat, err := f.Stat(); err != nil || stat.Mode().Perm() != 0600 {
+		t.Errorf("mode after fchmod: %v %v", stat, err)
+	}
+	f.Close()
+	if err := Fchmod(f, 0644); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("fchmod after close: %v", err)
+	}
+	if _, err := f.Read(make([]byte, 1)); !errors.Is(err, fs.ErrClosed) {
+		t.Errorf("read after close: %v", err)
+	}
+
+	// the offsets and sizes beyond the limit are rejected instead of growing the data
+	f, err = m.OpenFile("/big", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(1<<63-2, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	_, err = f.Write([]byte("x"))
+	expectErrno(t, "write at huge offset", err, syscall.EFBIG)
+	_, err = f.WriteAt([]byte("x"), maxMemFileSize)
+	expectErrno(t, "write beyond the limit", err, syscall.EFBIG)
+	expectErrno(t, "huge truncate", f.Truncate(1<<62), syscall.EFBIG)
+	if stat, err := f.Stat(); err != nil || stat.Size() != 0 {
+		t.Errorf("size after the rejected writes: %v %v", stat, err)
+	}
+	f.Close()
+
+	m.Symlink("/loop", "/loop")
+	_, err = m.Stat("/loop")
+	expectErrno(t, "symlink loop", err, syscall.ELOOP)
+
+	// absolute targets and directory symlinks
+	m.Mkdir("/d/sub", 0755)
+	writeFile(t, m, "/d/sub/f", "x")
+	m.Symlink("/d/sub", "/link")
+	if got := readFile(t, m, "/link/../link/f"); got != "x" {
+		t.Errorf("read %q through directory symlink", got)
+	}
+	if real, err := Realpath(m, "/link/f"); err != nil || real != "/d/sub/f" {
+		t.Errorf("realpath: %q %v", real, err)
+	}
+}
+
+func TestOSFS(t *testing.T) {
+	o := NewOSFS(t.TempDir())
+	testPOSIX(t, o)
+	stat, err := o.Stat("/d/h")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attr := AttrOf(stat); attr.Nlink != 1 || attr.Atime.IsZero() {
+		t.Errorf("unexpected attributes %+v", attr)
+	}
+	f, err := o.OpenFile("/d/h", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if name := f.Name(); name != "/d/h" {
+		t.Errorf("name %q", name)
+	}
+	if dir, err := Getwd(o); err != nil || dir != "/" {
+		t.Errorf("getwd under root: %q %v", dir, err)
+	}
+	wd, _ := os.Getwd()
+	if dir, err := Getwd(NewOSFS("")); err != nil || dir != filepath.ToSlash(wd) {
+		t.Errorf("getwd of the host: %q %v", dir, err)
+	}
+}
+
+func TestReadOnlyFS(t *testing.T) {
+	r := NewReadOnlyFS(fstest.MapFS{
+		"a/b.txt": {Data: []byte("data"), Mode: 0444},
+	})
+	if got := readFile(t, r, "/a/b.txt"); got != "data" {
+		t.Errorf("read %q", got)
+	}
+	if stat, err := r.Stat("/"); err != nil || !stat.IsDir() {
+		t.Errorf("stat root: %v %v", stat, err)
+	}
+	if got := dirNames(t, r, "a"); !slices.Equal(got, []string{"b.txt"}) {
+		t.Errorf("entries %v", got)
+	}
+	_, err := r.OpenFile("/a/c", os.O_WRONLY|os.O_CREATE, 0644)
+	expectErrno(t, "create", err, syscall.EROFS)
+	_, err = r.Stat("/a/c")
+	expectErrno(t, "stat missing", err, syscall.ENOENT)
+	expectErrno(t, "unlink", r.Unlink("/a/b.txt"), syscall.EROFS)
+	expectErrno(t, "access write", r.Access("/a/b.txt", AccessWrite), syscall.EROFS)
+	_, err = Getxattr(r, "/a/b.txt", "user.k")
+	expectErrno(t, "getxattr", err, syscall.ENOTSUP)
+}
+
+func TestMountFS(t *testing.T) {
+	root, tmp := NewMemFS(), NewMemFS()
+	m := NewMountFS(root)
+	m.Mount("/tmp", tmp)
+	m.Mount("/usr/share", NewReadOnlyFS(fstest.MapFS{"doc": {Data: []byte("doc")}}))
+
+	writeFile(t, m, "/tmp/a", "a")
+	if _, err := tmp.Stat("/a"); err != nil {
+		t.Errorf("the file is not created in the mounted filesystem: %v", err)
+	}
+	if _, err := root.Stat("/tmp/a"); err == nil {
+		t.Errorf("the file is created in the root filesystem")
+	}
+	if got := readFile(t, m, "/usr/share/doc"); got != "doc" {
+		t.Errorf("read %q", got)
+	}
+	f, err := m.OpenFile("/tmp/a", os.O_RDONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.Name() != "/tmp/a" {
+		t.Errorf("opened file has name %q", f.Name())
+	}
+	f.Close()
+
+	err = m.Rename("/tmp/a", "/a")
+	expectErrno(t, "rename across mount points", err, syscall.EXDEV)
+	var le *os.LinkError
+	if !errors.As(err, &le) || le.Old != "/tmp/a" || le.New != "/a" {
+		t.Errorf("unexpected error %#v", err)
+	}
+	_, err = m.Stat("/tmp/missing")
+	var pe *fs.PathError
+	if !errors.As(err, &pe) || pe.Path != "/tmp/missing" {
+		t.Errorf("error does not have the name of MountFS: %v", err)
+	}
+
+	// /tmpx is not under /tmp
+	writeFile(t, m, "/tmpx", "x")
+	if _, err := root.Stat("/tmpx"); err != nil {
+		t.Errorf("/tmpx is not in the root filesystem: %v", err)
+	}
+	if !m.Unmount("/tmp") || m.Unmount("/tmp") || m.Unmount("/") {
+		t.Errorf("unexpected unmount results")
+	}
+	_, err = m.Stat("/tmp/a")
+	expectErrno(t, "stat after unmount", err, syscall.ENOENT)
+}
@@ -0,0 +1,113 @@
+package vm
+
+import (
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// IsSameRuntimePackage reports whether the classes are in the same runtime package, see JVMS 5.3
+func (c *Class) IsSameRuntimePackage(k ir.Class) bool {
+	return c.isSameRuntimePackage(k.(*Class))
+}
+
+// isAccessibleFrom reports whether the class is accessible to the class d, see JVMS 5.4.4
+func (c *Class) isAccessibleFrom(d *Class) bool {
+	for c.arrayDim > 0 {
+		c = c.elem
+	}
+	if c.arrayDim < 0 || c.AccessFlags.Has(jcls.AccPublic) {
+		return true
+	}
+	return c.isSameRuntimePackage(d)
+}
+
+// NestHost returns the nest host of the class, see JVMS 5.4.4.
+// A class whose NestHost attribute cannot be validated is the host of its own nest.
+func (c *Class) NestHost() ir.Class {
+	c.nestHostOnce.Do(func() {
+		c.nestHost = c
+		attr, ok := c.GetAttr("NestHost").(*jcls.AttrNestHost)
+		if !ok || c.loader == nil {
+			return
+		}
+		k, err := c.loader.LoadClass(attr.Host)
+		if err != nil {
+			return
+		}
+		host := k.(*Class)
+		if !host.isSameRuntimePackage(c) {
+			return
+		}
+		members, ok := host.GetAttr("NestMembers").(*jcls.AttrNestMembers)
+		if !ok {
+			return
+		}
+		for _, m := range members.Members {
+			if m == c.Name() {
+				c.nestHost = host
+				return
+			}
+		}
+	})
+	return c.nestHost
+}
+
+// checkClassAccess checks whether the class referenced by the class d is accessible
+func (d *Class) checkClassAccess(c *Class) error {
+	if !c.isAccessibleFrom(d) {
+		return errs.Throwf("java/lang/IllegalAccessError", "failed to access class %s from class %s", c.Name(), d.Name())
+	}
+	return nil
+}
+
+// canAccessMember reports whether the member declared in the class c with the access flags is accessible to the class d,
+// where t is the class in the member's symbolic reference, see JVMS 5.4.4
+func (d *Class) canAccessMember(t, c *Class, flags jcls.AccessFlag, static bool) bool {
+	switch {
+	case flags.Has(jcls.AccPublic):
+		return true
+	case flags.Has(jcls.AccPrivate):
+		return c == d || c.NestHost() == d.NestHost()
+	case c.isSameRuntimePackage(d):
+		return true
+	case flags.Has(jcls.AccProtected):
+		if !c.IsAssignableFrom(d) {
+			return false
+		}
+		return static || t.IsAssignableFrom(d) || d.IsAssignableFrom(t)
+	}
+	return false
+}
+
+func memberAccessName(flags jcls.AccessFlag) string {
+	switch {
+	case flags.Has(jcls.AccPrivate):
+		return "private"
+	case flags.Has(jcls.AccProtected):
+		return "protected"
+	}
+	return "package-private"
+}
+
+// checkFieldAccess checks whether the field referenced through the class t is accessible to the class d
+func (d *Class) checkFieldAccess(t *Class, f *Field) error {
+	if err := d.checkClassAccess(t); err != nil {
+		return err
+	}
+	if !d.canAccessMember(t, f.class, f.AccessFlags, f.IsStatic()) {
+		return errs.Throwf("java/lang/IllegalAccessError", "class %s tried to access %s field %s.%s", d.Name(), memberAccessName(f.AccessFlags), f.class.Name(), f.Name())
+	}
+	return nil
+}
+
+// checkMethodAccess checks whether the method referenced through the class t is accessible to the class d
+func (d *Class) checkMethodAccess(t *Class, m *Method) error {
+	if err := d.checkClassAccess(t); err != nil {
+		return err
+	}
+	if !d.canAccessMember(t, m.class, m.AccessFlags, m.IsStatic()) {
+		return errs.Throwf("java/lang/IllegalAccessError", "class %s tried to access %s method '%s'", d.Name(), memberAccessName(m.AccessFlags), m.Location())
+	}
+	return nil
+}
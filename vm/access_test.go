@@ -0,0 +1,69 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestMemberAccess(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	defineNest := func(name string, attr ir.Attribute, methods ...*jcls.Method) *Class {
+		c := LoadClass(jcls.NewClass(jcls.AccPublic, name, "java/lang/Object", nil, nil, methods, []ir.Attribute{attr}), l)
+		l.DefineClass(c)
+		return c
+	}
+	outer := defineNest("p/Outer", &jcls.AttrNestMembers{Members: []string{"p/Outer$Inner"}},
+		testMethod(jcls.AccPrivate, "secret", "()V"),
+	)
+	inner := defineNest("p/Outer$Inner", &jcls.AttrNestHost{Host: "p/Outer"})
+	fake := defineNest("p/Fake", &jcls.AttrNestHost{Host: "p/Outer"})
+	base := l.define(jcls.AccPublic, "p/Base", "java/lang/Object", nil,
+		testMethod(jcls.AccProtected, "prot", "()V"),
+		testMethod(jcls.AccProtected|jcls.AccStatic, "sprot", "()V"),
+		testMethod(0, "pkg", "()V"),
+	)
+	hidden := l.define(0, "p/Hidden", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic, "run", "()V"),
+	)
+	sub := l.define(jcls.AccPublic, "q/Sub", "p/Base", nil)
+	other := l.define(jcls.AccPublic, "q/Other", "p/Base", nil)
+	stranger := l.define(jcls.AccPublic, "q/Stranger", "java/lang/Object", nil)
+
+	if inner.NestHost() != outer || fake.NestHost() != fake {
+		t.Fatalf("nest host: got %s and %s, want p/Outer and p/Fake", inner.NestHost().Name(), fake.NestHost().Name())
+	}
+
+	secret := mustMethod(t, outer, "secret", "()V")
+	prot := mustMethod(t, base, "prot", "()V")
+	sprot := mustMethod(t, base, "sprot", "()V")
+	pkg := mustMethod(t, base, "pkg", "()V")
+	datas := []struct {
+		from   *Class
+		ref    *Class
+		method *Method
+		ok     bool
+	}{
+		{outer, outer, secret, true},
+		{inner, outer, secret, true},
+		{fake, outer, secret, false},
+		{base, base, pkg, true},
+		{outer, base, pkg, true},
+		{sub, base, pkg, false},
+		{sub, sub, prot, true},
+		{sub, base, prot, true},
+		{sub, other, prot, false},
+		{sub, other, sprot, true},
+		{stranger, base, prot, false},
+		{outer, hidden, mustMethod(t, hidden, "run", "()V"), true},
+		{sub, hidden, mustMethod(t, hidden, "run", "()V"), false},
+	}
+	for _, d := range datas {
+		err := d.from.checkMethodAccess(d.ref, d.method)
+		if d.ok != (err == nil) {
+			t.Errorf("access %s through %s from %s: got %v, want ok=%v", d.method.Location(), d.ref.Name(), d.from.Name(), err, d.ok)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package vm
+
+import (
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+type compiledMethod struct {
+	checksum uint32
+	code     NativeMethodCallback
+}
+
+// compiledMethods holds the methods translated by gova aot, keyed by their location
+var compiledMethods = make(map[string]compiledMethod)
+
+// RegisterCompiledMethod registers the ahead-of-time compiled code of the method at the location,
+// e.g. "java/lang/Math.max(II)I".
+// The code runs in the method's frame like a native method,
+// and is only used when the checksum matches the loaded bytecode, see jcls.AttrCode.Checksum.
+// It should be called by the init functions of the generated packages.
+func RegisterCompiledMethod(location string, checksum uint32, code NativeMethodCallback) {
+	if _, ok := compiledMethods[location]; ok {
+		panic("method " + location + " is already compiled")
+	}
+	compiledMethods[location] = compiledMethod{checksum: checksum, code: code}
+}
+
+// lookupCompiledMethod returns the ahead-of-time compiled code for the method,
+// or nil if there is none or it was compiled from different bytecode or constants
+func lookupCompiledMethod(m *Method) NativeMethodCallback {
+	if m.Code == nil || len(compiledMethods) == 0 {
+		return nil
+	}
+	cm, ok := compiledMethods[m.Location()]
+	if !ok || cm.checksum != m.Code.Checksum {
+		return nil
+	}
+	return cm.code
+}
+
+// enterMethod prepares the VM to execute the method in the new frame.
+// Native methods and ahead-of-time compiled methods run as vm.nextNative,
+// and others are interpreted from their first instruction.
+func (vm *VM) enterMethod(m *Method) {
+	if m.AccessFlags.Has(jcls.AccNative) {
+		if m.native == nil {
+			panic("native method " + m.Location() + " is not loaded")
+		}
+		vm.nextNative = m.native
+	} else if m.compiled != nil && (vm.opts == nil || !vm.opts.DisableAOT) {
+		vm.nextNative = m.compiled
+	} else {
+		vm.nextPc = m.Code.Code
+	}
+}
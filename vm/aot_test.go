@@ -0,0 +1,78 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// compiledFib is fib in the form which gova aot generates
+func compiledFib(calls *int) NativeMethodCallback {
+	invoke := &ir.ICinvokestatic{Method: 1}
+	return func(vm ir.VM) error {
+		*calls++
+		s := vm.GetStack()
+		n := s.GetVarInt32(0)
+		if n < 2 {
+			s.PushInt32(n)
+			return nil
+		}
+		var r int32
+		for _, k := range []int32{1, 2} {
+			s.PushInt32(n - k)
+			if err := invoke.Execute(vm); err != nil {
+				return err
+			}
+			if vm.GetStack() != s {
+				if err := vm.RunStack(); err != nil {
+					return err
+				}
+			}
+			r += s.PopInt32()
+		}
+		s.PushInt32(r)
+		return nil
+	}
+}
+
+func TestCompiledMethod(t *testing.T) {
+	const checksum = 0x1234
+	calls := 0
+	RegisterCompiledMethod("Fib.fib(I)I", checksum, compiledFib(&calls))
+	t.Cleanup(func() { delete(compiledMethods, "Fib.fib(I)I") })
+
+	datas := []struct {
+		name     string
+		checksum uint32
+		opts     *Options
+		compiled bool
+	}{
+		{"compiled", checksum, nil, true},
+		{"stale", 0, nil, false},
+		{"disabled", checksum, &Options{Verify: VerifyNone, DisableAOT: true}, false},
+	}
+	for _, d := range datas {
+		t.Run(d.name, func(t *testing.T) {
+			l := newTestLoader()
+			l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+			m := codeMethod(t, jcls.AccPublic|jcls.AccStatic, "fib", "(I)I", 1, fibCode)
+			m.Code.Checksum = d.checksum
+			fib := defineCodeClass(l, "Fib", "java/lang/Object", nil, []*jcls.Method{m}, fibConsts).GetMethodByDesc("fib", m.Desc()).(*Method)
+
+			calls = 0
+			vm := &VM{opts: d.opts, stack: &Stack{}}
+			vm.stack.PushInt32(10)
+			vm.InvokeStatic(fib)
+			if err := vm.RunStack(); err != nil {
+				t.Fatal(err)
+			}
+			if got := vm.stack.PopInt32(); got != 55 {
+				t.Errorf("fib(10) = %d, want 55", got)
+			}
+			if compiled := calls > 0; compiled != d.compiled {
+				t.Errorf("compiled code used: %v, want %v", compiled, d.compiled)
+			}
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// memberNameReferenceKindShift is the shift of the reference kind in MemberName.flags
+const memberNameReferenceKindShift = 24
+
+var callSiteDesc = &desc.Desc{
+	EndType: desc.Class,
+	Class:   "java/lang/invoke/CallSite",
+}
+
+// callSiteLinkage is the result of a call site linkage
+type callSiteLinkage struct {
+	callSite *Ref
+	// target is the cached target of a ConstantCallSite
+	target *Ref
+	// intrinsic executes the call site in Go if the bootstrap method is intrinsified
+	intrinsic func(vm *VM) error
+	// err is the error of a failed linkage, which is thrown again by later executions
+	err error
+}
+
+// linkCallSite links the call site.
+// The bootstrap method is only invoked until the first linkage completes, see JVMS 5.4.3.6
+func (vm *VM) linkCallSite(info *dynamicInfo) *callSiteLinkage {
+	if linkage := info.linkage.Load(); linkage != nil {
+		return linkage
+	}
+	// the first completed linkage wins if there are multiple VMs linking the call site
+	info.linkage.CompareAndSwap(nil, vm.bootstrapCallSite(info))
+	return info.linkage.Load()
+}
+
+// bootstrapCallSite invokes the bootstrap method of the call site, and checks the result.
+// The target is only cached if the call site is a ConstantCallSite.
+func (vm *VM) bootstrapCallSite(info *dynamicInfo) *callSiteLinkage {
+	if !vm.opts.DisableBootstrapIntrinsics {
+		if intrinsic, err := vm.linkIntrinsic(vm.stack.class, info); err != nil {
+			return &callSiteLinkage{err: vm.bootstrapMethodError(err)}
+		} else if intrinsic != nil {
+			return &callSiteLinkage{intrinsic: intrinsic}
+		}
+	}
+	name, typ := info.info.NameAndType.Name, info.info.NameAndType.Desc
+	mt, err := vm.NewMethodType(typ)
+	if err != nil {
+		return &callSiteLinkage{err: err}
+	}
+	result, err := vm.invokeBootstrap(vm.stack.class, info.bootstrap, name, mt.(*Ref))
+	if err == nil {
+		result, err = vm.convertArg(result, callSiteDesc)
+	}
+	if err != nil {
+		return &callSiteLinkage{err: vm.bootstrapMethodError(err)}
+	}
+	callSite := result.ref
+	if callSite == nil {
+		return &callSiteLinkage{err: vm.bootstrapMethodError(errs.NullPointerException)}
+	}
+	target, err := vm.callSiteTarget(callSite)
+	if err != nil {
+		return &callSiteLinkage{err: vm.bootstrapMethodError(err)}
+	}
+	targetType := *(**Ref)(vm.javaLangInvokeMethodHandle_type.GetPointer(target))
+	if !vm.methodTypeMatches(targetType, info.typ) {
+		return &callSiteLinkage{err: vm.bootstrapMethodError(errs.Throwf("java/lang/invoke/WrongMethodTypeException", "call site target should be of type %s", typ))}
+	}
+	if !vm.javaLangInvokeConstantCallSite.IsAssignableFrom(callSite.class) {
+		target = nil
+	}
+	return &callSiteLinkage{callSite: callSite, target: target}
+}
+
+// bootstrapMethodError wraps the error thrown during call site or dynamic constant resolution into BootstrapMethodError,
+// unless it is already an Error
+func (vm *VM) bootstrapMethodError(err error) error {
+	err = vm.toThrowableError(err)
+	te, ok := err.(*ThrowableError)
+	if !ok {
+		return err
+	}
+	errorClass, err := vm.loadClass("java/lang/Error")
+	if err != nil {
+		return err
+	}
+	if errorClass.IsAssignableFrom(te.Throwable.class) {
+		return te
+	}
+	cls, err := vm.loadClass("java/lang/BootstrapMethodError")
+	if err != nil {
+		return err
+	}
+	ref := vm.New(cls)
+	vm.stack.PushRef(ref)
+	vm.stack.PushRef(vm.NewString("bootstrap method initialization exception"))
+	vm.stack.PushRef(te.Throwable)
+	vm.Invoke(cls.GetMethodByNameAndType("<init>", "(Ljava/lang/String;Ljava/lang/Throwable;)V"))
+	if err := vm.RunStack(); err != nil {
+		return err
+	}
+	return vm.newThrowableError(ref.(*Ref))
+}
+
+// callSiteTarget invokes CallSite.getTarget
+func (vm *VM) callSiteTarget(callSite *Ref) (*Ref, error) {
+	vm.stack.PushRef(callSite)
+	if err := vm.InvokeVirtual(vm.javaLangInvokeCallSite_getTarget); err != nil {
+		return nil, err
+	}
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	target, _ := vm.stack.PopRef().(*Ref)
+	if target == nil {
+		return nil, errs.NullPointerException
+	}
+	return target, nil
+}
+
+// methodTypeMatches reports whether the MethodType object is the type of the method descriptor
+func (vm *VM) methodTypeMatches(mt *Ref, typ *desc.MethodDesc) bool {
+	if mt == nil {
+		return false
+	}
+	rtype := *(**Ref)(vm.javaLangInvokeMethodType_rtype.GetPointer(mt))
+	ptypes := (*(**Ref)(vm.javaLangInvokeMethodType_ptypes.GetPointer(mt))).GetRefArr()
+	if len(ptypes) != len(typ.Inputs) {
+		return false
+	}
+	if cls, err := vm.GetClassFromDesc(typ.Output); err != nil || rtype.userData != cls {
+		return false
+	}
+	for i, in := range typ.Inputs {
+		if cls, err := vm.GetClassFromDesc(in); err != nil || (*Ref)(ptypes[i]).userData != cls {
+			return false
+		}
+	}
+	return true
+}
+
+// invokeBootstrap invokes the bootstrap method with the lookup, name, type and static arguments,
+// and returns the result as JVMS 5.4.3.6 defined
+func (vm *VM) invokeBootstrap(caller *Class, bootstrap *jcls.BootstrapMethod, name string, typ *Ref) (jvalue, error) {
+	handle := bootstrap.Method
+	if vm.tracing() {
+		fmt.Println("\n==> invoking bootstrap " + bootstrap.String())
+		defer fmt.Println("   post invoke bootstrap " + bootstrap.String())
+	}
+	method, err := caller.resolveMethodHandleMethod(handle)
+	if err != nil {
+		return jvalue{}, err
+	}
+
+	args := make([]jvalue, 0, len(bootstrap.Args)+3)
+	args = append(args,
+		jvalue{typ: desc.Class, ref: vm.NewLookup().(*Ref)},
+		jvalue{typ: desc.Class, ref: vm.GetStringInternOrNew(name).(*Ref)},
+		jvalue{typ: desc.Class, ref: typ},
+	)
+	for _, arg := range bootstrap.Args {
+		v, err := caller.resolveLoadable(vm, arg)
+		if err != nil {
+			return jvalue{}, err
+		}
+		args = append(args, v)
+	}
+
+	n := 0
+	if handle.Kind == jcls.RefInvokeVirtual || handle.Kind == jcls.RefInvokeInterface || handle.Kind == jcls.RefInvokeSpecial {
+		// the lookup object is passed as the receiver
+		n = 1
+	}
+	params, err := vm.collectVarargs(method, args[n:])
+	if err != nil {
+		return jvalue{}, err
+	}
+	return vm.invokeMethodKind(method, handle.Kind, append(args[:n:n], params...))
+}
+
+// invokeMethodKind invokes the method as the reference kind, and returns the result.
+// The arguments include the receiver of instance methods, and are converted to the parameter types.
+// The result of void methods is null.
+func (vm *VM) invokeMethodKind(method *Method, kind jcls.MethodKind, args []jvalue) (jvalue, error) {
+	var this *Ref
+	switch kind {
+	case jcls.RefInvokeStatic:
+		if err := vm.InitClass(method.class); err != nil {
+			return jvalue{}, err
+		}
+	case jcls.RefNewInvokeSpecial:
+		if err := vm.InitClass(method.class); err != nil {
+			return jvalue{}, err
+		}
+		this = vm.New(method.class).(*Ref)
+	default:
+		if len(args) == 0 || !args[0].typ.IsRef() {
+			return jvalue{}, errs.Throwf("java/lang/invoke/WrongMethodTypeException", "missing receiver of %s", method.Location())
+		}
+		if this = args[0].ref; this == nil {
+			return jvalue{}, errs.NullPointerException
+		}
+		if !method.class.IsAssignableFrom(this.class) {
+			return jvalue{}, &errs.ClassCastException{Have: this.class.Name(), Want: method.class.Name()}
+		}
+		args = args[1:]
+	}
+
+	inputs := method.Desc().Inputs
+	if len(args) != len(inputs) {
+		return jvalue{}, errs.Throwf("java/lang/invoke/WrongMethodTypeException", "cannot invoke %s with %d arguments", method.Location(), len(args))
+	}
+	params := make([]jvalue, len(args))
+	for i, in := range inputs {
+		var err error
+		if params[i], err = vm.convertArg(args[i], in); err != nil {
+			return jvalue{}, err
+		}
+	}
+
+	stack := vm.stack
+	if this != nil {
+		stack.PushRef(this)
+	}
+	for _, v := range params {
+		v.push(stack)
+	}
+	switch kind {
+	case jcls.RefInvokeStatic:
+		vm.InvokeStatic(method)
+	case jcls.RefInvokeVirtual, jcls.RefInvokeInterface:
+		if err := vm.InvokeVirtual(method); err != nil {
+			return jvalue{}, err
+		}
+	default:
+		vm.Invoke(method)
+	}
+	if err := vm.RunStack(); err != nil {
+		return jvalue{}, err
+	}
+	if kind == jcls.RefNewInvokeSpecial {
+		return jvalue{typ: desc.Class, ref: this}, nil
+	}
+	out := method.Desc().Output
+	if out.Type() == desc.Void {
+		return jvalue{typ: desc.Class}, nil
+	}
+	return stack.popValue(out), nil
+}
+
+// resolveMethodHandleMethod resolves the method referenced by the method handle constant, see JVMS 5.4.3.5
+func (c *Class) resolveMethodHandleMethod(handle *jcls.ConstantMethodHandle) (*Method, error) {
+	switch handle.Kind {
+	case jcls.RefInvokeStatic, jcls.RefInvokeVirtual, jcls.RefInvokeInterface, jcls.RefInvokeSpecial, jcls.RefNewInvokeSpecial:
+	default:
+		return nil, errs.Throwf("java/lang/invoke/WrongMethodTypeException", "%s is not a method handle to a method", handle)
+	}
+	k, err := c.loader.LoadClass(handle.Ref.Class.Name)
+	if err != nil {
+		return nil, err
+	}
+	class := k.(*Class)
+	var method *Method
+	if handle.Ref.ConstTag == jcls.TagInterfaceMethodref {
+		method, err = class.resolveInterfaceMethod(handle.Ref.NameAndType.Name, handle.Ref.NameAndType.Desc)
+	} else {
+		method, err = class.resolveMethod(handle.Ref.NameAndType.Name, handle.Ref.NameAndType.Desc)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if (handle.Kind == jcls.RefNewInvokeSpecial) != method.IsConstructor() {
+		return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "%s cannot be used with %s", handle.Kind, method.Location())
+	}
+	if (handle.Kind == jcls.RefInvokeStatic) != method.IsStatic() {
+		if method.IsStatic() {
+			return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected non-static method '%s'", method.Location())
+		}
+		return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected static method '%s'", method.Location())
+	}
+	if err := c.checkMethodAccess(class, method); err != nil {
+		return nil, err
+	}
+	return method, nil
+}
+
+// resolveMethodHandleField resolves the field referenced by the method handle constant, see JVMS 5.4.3.5
+func (c *Class) resolveMethodHandleField(handle *jcls.ConstantMethodHandle) (*Field, error) {
+	k, err := c.loader.LoadClass(handle.Ref.Class.Name)
+	if err != nil {
+		return nil, err
+	}
+	class := k.(*Class)
+	name := handle.Ref.NameAndType.Name
+	field, err := class.resolveField(name, handle.Ref.NameAndType.Desc)
+	if err != nil {
+		return nil, err
+	}
+	wantStatic := handle.Kind == jcls.RefGetStatic || handle.Kind == jcls.RefPutStatic
+	if field.IsStatic() != wantStatic {
+		if wantStatic {
+			return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected static field %s.%s", class.Name(), name)
+		}
+		return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Expected non-static field %s.%s", class.Name(), name)
+	}
+	if err := c.checkFieldAccess(class, field); err != nil {
+		return nil, err
+	}
+	return field, nil
+}
+
+// collectVarargs collects the trailing arguments into an array if the method has variable arity,
+// and the arguments cannot be passed to the method directly
+func (vm *VM) collectVarargs(method *Method, args []jvalue) ([]jvalue, error) {
+	inputs := method.Desc().Inputs
+	n := len(inputs)
+	if !method.AccessFlags.Has(jcls.AccVarargs) || n == 0 || len(args) < n-1 {
+		return args, nil
+	}
+	if len(args) == n {
+		if last := args[n-1]; last.typ.IsRef() && last.ref != nil && last.ref.class.arrayDim > 0 {
+			return args, nil
+		}
+	}
+	arrDesc := inputs[n-1]
+	elem := arrDesc.Elem()
+	if !elem.Type().IsRef() {
+		return nil, errs.Throwf("java/lang/invoke/WrongMethodTypeException", "cannot collect arguments into %s", arrDesc)
+	}
+	rest := args[n-1:]
+	arr := vm.NewArray(arrDesc, (int32)(len(rest)))
+	refs := arr.GetRefArr()
+	for i, v := range rest {
+		v, err := vm.convertArg(v, elem)
+		if err != nil {
+			return nil, err
+		}
+		refs[i] = vm.RefToPtr(v.ref)
+	}
+	return append(args[:n-1:n-1], jvalue{typ: desc.Array, ref: arr.(*Ref)}), nil
+}
+
+// convertArg converts the value to the parameter's type, boxing or unboxing it if necessary
+func (vm *VM) convertArg(v jvalue, param *desc.Desc) (jvalue, error) {
+	t := param.Type()
+	if t.IsRef() {
+		ref, err := vm.box(v)
+		if err != nil {
+			return jvalue{}, err
+		}
+		if ref != nil {
+			cls, err := vm.GetClassFromDesc(param)
+			if err != nil {
+				return jvalue{}, err
+			}
+			if !cls.IsAssignableFrom(ref.class) {
+				return jvalue{}, &errs.ClassCastException{Have: ref.class.Name(), Want: cls.Name()}
+			}
+		}
+		return jvalue{typ: t, ref: ref}, nil
+	}
+	if v.typ.IsRef() {
+		return vm.unbox(v.ref, t)
+	}
+	if v.typ == t || (v.typ == desc.Int && t.Slot() == 1 && t != desc.Float) {
+		return jvalue{typ: t, val: v.val}, nil
+	}
+	if w, ok := widenPrimitive(v, t); ok {
+		return w, nil
+	}
+	return jvalue{}, errs.Throwf("java/lang/invoke/WrongMethodTypeException", "cannot convert %c to %c", v.typ, t)
+}
+
+// widenPrimitive converts the primitive value to a wider primitive type, see JLS 5.1.2
+func widenPrimitive(v jvalue, t desc.Type) (jvalue, bool) {
+	var (
+		i       int64
+		isInt   bool
+		isLong  = v.typ == desc.Long
+		isFloat = v.typ == desc.Float
+	)
+	switch v.typ {
+	case desc.Byte, desc.Short, desc.Int:
+		i, isInt = (int64)((int32)((uint32)(v.val))), true
+	case desc.Char:
+		i, isInt = (int64)((uint16)(v.val)), true
+	}
+	w := jvalue{typ: t}
+	switch {
+	case t == desc.Short && v.typ == desc.Byte, t == desc.Int && isInt:
+		w.val = (uint64)((uint32)(i))
+	case t == desc.Long && isInt:
+		w.val = (uint64)(i)
+	case t == desc.Float && isInt:
+		w.val = (uint64)(math.Float32bits((float32)(i)))
+	case t == desc.Float && isLong:
+		w.val = (uint64)(math.Float32bits((float32)((int64)(v.val))))
+	case t == desc.Double && isInt:
+		w.val = math.Float64bits((float64)(i))
+	case t == desc.Double && isLong:
+		w.val = math.Float64bits((float64)((int64)(v.val)))
+	case t == desc.Double && isFloat:
+		w.val = math.Float64bits((float64)(math.Float32frombits((uint32)(v.val))))
+	default:
+		return jvalue{}, false
+	}
+	return w, true
+}
+
+var boxTypes = map[desc.Type]struct{ class, unbox string }{
+	desc.Boolean: {"java/lang/Boolean", "booleanValue"},
+	desc.Byte:    {"java/lang/Byte", "byteValue"},
+	desc.Char:    {"java/lang/Character", "charValue"},
+	desc.Short:   {"java/lang/Short", "shortValue"},
+	desc.Int:     {"java/lang/Integer", "intValue"},
+	desc.Long:    {"java/lang/Long", "longValue"},
+	desc.Float:   {"java/lang/Float", "floatValue"},
+	desc.Double:  {"java/lang/Double", "doubleValue"},
+}
+
+// box returns the boxed object of the primitive value, or the value itself if it is a reference
+func (vm *VM) box(v jvalue) (*Ref, error) {
+	if v.typ.IsRef() {
+		return v.ref, nil
+	}
+	b := boxTypes[v.typ]
+	cls, err := vm.loadClass(b.class)
+	if err != nil {
+		return nil, err
+	}
+	if err := vm.InitClass(cls); err != nil {
+		return nil, err
+	}
+	v.push(vm.stack)
+	vm.InvokeStatic(cls.GetMethodByNameAndType("valueOf", "("+string(v.typ)+")L"+b.class+";"))
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	return vm.stack.PopRef().(*Ref), nil
+}
+
+// unbox returns the primitive value of the boxed object
+func (vm *VM) unbox(ref *Ref, typ desc.Type) (jvalue, error) {
+	if ref == nil {
+		return jvalue{}, errs.NullPointerException
+	}
+	b := boxTypes[typ]
+	cls, err := vm.loadClass(b.class)
+	if err != nil {
+		return jvalue{}, err
+	}
+	if !cls.IsAssignableFrom(ref.class) {
+		return jvalue{}, &errs.ClassCastException{Have: ref.class.Name(), Want: cls.Name()}
+	}
+	vm.stack.PushRef(ref)
+	vm.Invoke(cls.GetMethodByNameAndType(b.unbox, "()"+string(typ)))
+	if err := vm.RunStack(); err != nil {
+		return jvalue{}, err
+	}
+	v := jvalue{typ: typ}
+	if typ.Slot() == 2 {
+		v.val = vm.stack.Pop64()
+	} else {
+		v.val = (uint64)(vm.stack.Pop())
+	}
+	return v, nil
+}
+
+// invokeMethodHandle invokes the method handle with the arguments on the stack, which are described by the type.
+// The type must match the method handle's type.
+// Direct method handles invoke their target methods directly,
+// others are invoked through their LambdaForms.
+func (vm *VM) invokeMethodHandle(mh *Ref, typ *desc.MethodDesc) error {
+	m, kind, ok := vm.directMethodHandleTarget(mh)
+	if !ok {
+		return vm.invokeBasic(mh, typ)
+	}
+	switch kind {
+	case jcls.RefInvokeStatic:
+		if err := vm.InitClass(m.class); err != nil {
+			return err
+		}
+		vm.InvokeStatic(m)
+	case jcls.RefInvokeVirtual, jcls.RefInvokeInterface:
+		return vm.InvokeVirtual(m)
+	default:
+		if m.IsConstructor() {
+			if err := vm.InitClass(m.class); err != nil {
+				return err
+			}
+			// one for the constructor, and another one for the result
+			this := vm.New(m.class).(*Ref)
+			slots := (int)(m.Desc().InputSlots())
+			vm.stack.insertRef(slots, this)
+			vm.stack.insertRef(slots, this)
+		}
+		vm.Invoke(m)
+	}
+	return nil
+}
+
+// directMethodHandleTarget returns the target method and the reference kind of a DirectMethodHandle
+func (vm *VM) directMethodHandleTarget(mh *Ref) (*Method, jcls.MethodKind, bool) {
+	if !vm.javaLangInvokeDirectMethodHandle.IsAssignableFrom(mh.class) {
+		return nil, 0, false
+	}
+	return vm.memberNameTarget(*(**Ref)(vm.javaLangInvokeDirectMethodHandle_member.GetPointer(mh)))
+}
+
+// memberNameTarget returns the resolved method and the reference kind of a MemberName
+func (vm *VM) memberNameTarget(member *Ref) (*Method, jcls.MethodKind, bool) {
+	if member == nil {
+		return nil, 0, false
+	}
+	resolved := *(**Ref)(vm.javaLangInvokeMemberName_method.GetPointer(member))
+	if resolved == nil {
+		return nil, 0, false
+	}
+	data, ok := resolved.userData.(*ResolvedMethodNameData)
+	if !ok || data.VMTarget == nil {
+		return nil, 0, false
+	}
+	flags := *(*int32)(vm.javaLangInvokeMemberName_flags.GetPointer(member))
+	return data.VMTarget, (jcls.MethodKind)((flags >> memberNameReferenceKindShift) & 0xf), true
+}
@@ -0,0 +1,67 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func testMethodHandle(kind jcls.MethodKind, tag jcls.ConstTag, class, name, typ string) *jcls.ConstantMethodHandle {
+	return &jcls.ConstantMethodHandle{
+		Kind: kind,
+		Ref: &jcls.ConstantRef{
+			ConstTag:    tag,
+			Class:       &jcls.ConstantClass{Name: class},
+			NameAndType: &jcls.ConstantNameAndType{Name: name, Desc: typ},
+		},
+	}
+}
+
+func TestBootstrapMethodHandleResolution(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil,
+		testMethod(jcls.AccPublic, "<init>", "()V"),
+	)
+	boot := l.define(jcls.AccPublic, "Boot", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic, "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"),
+		testMethod(jcls.AccPublic, "<init>", "(Ljava/lang/Object;)V"),
+		testMethod(jcls.AccPublic, "virt", "(Ljava/lang/Object;)Ljava/lang/Object;"),
+	)
+	l.define(jcls.AccPublic|jcls.AccInterface|jcls.AccAbstract, "IBoot", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic, "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"),
+	)
+
+	var datas = []struct {
+		Handle *jcls.ConstantMethodHandle
+		Want   *Method
+		Class  string
+	}{
+		{testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "Boot", "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"), mustMethod(t, boot, "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"), ""},
+		{testMethodHandle(jcls.RefNewInvokeSpecial, jcls.TagMethodref, "Boot", "<init>", "(Ljava/lang/Object;)V"), mustMethod(t, boot, "<init>", "(Ljava/lang/Object;)V"), ""},
+		{testMethodHandle(jcls.RefInvokeVirtual, jcls.TagMethodref, "Boot", "virt", "(Ljava/lang/Object;)Ljava/lang/Object;"), mustMethod(t, boot, "virt", "(Ljava/lang/Object;)Ljava/lang/Object;"), ""},
+		{testMethodHandle(jcls.RefInvokeStatic, jcls.TagInterfaceMethodref, "IBoot", "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"), nil, ""},
+		{testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "Boot", "virt", "(Ljava/lang/Object;)Ljava/lang/Object;"), nil, "java/lang/IncompatibleClassChangeError"},
+		{testMethodHandle(jcls.RefInvokeVirtual, jcls.TagMethodref, "Boot", "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"), nil, "java/lang/IncompatibleClassChangeError"},
+		{testMethodHandle(jcls.RefInvokeSpecial, jcls.TagMethodref, "Boot", "<init>", "(Ljava/lang/Object;)V"), nil, "java/lang/IncompatibleClassChangeError"},
+		{testMethodHandle(jcls.RefInvokeStatic, jcls.TagInterfaceMethodref, "Boot", "bsm", "(Ljava/lang/Object;)Ljava/lang/Object;"), nil, "java/lang/IncompatibleClassChangeError"},
+		{testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "Boot", "missing", "()Ljava/lang/Object;"), nil, "java/lang/NoSuchMethodError"},
+		{testMethodHandle(jcls.RefGetStatic, jcls.TagFieldref, "Boot", "field", "Ljava/lang/Object;"), nil, "java/lang/invoke/WrongMethodTypeException"},
+	}
+	for _, d := range datas {
+		m, err := boot.resolveMethodHandleMethod(d.Handle)
+		if d.Class != "" {
+			if te, ok := err.(*errs.ThrowError); !ok || te.Class != d.Class {
+				t.Errorf("resolve %s: got %v, want %s", d.Handle, err, d.Class)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("resolve %s: unexpected error %v", d.Handle, err)
+			continue
+		}
+		if d.Want != nil && m != d.Want {
+			t.Errorf("resolve %s: got %s, want %s", d.Handle, m.Location(), d.Want.Location())
+		}
+	}
+}
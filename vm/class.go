@@ -18,7 +18,6 @@ import (
 type Class struct {
 	*jcls.Class
 	loader ir.ClassLoader
-	initVM atomic.Pointer[VM]
 
 	arrayDim   int // -1: primary type; 0: normal class; 1+: array class
 	elem       *Class
@@ -26,16 +25,32 @@ type Class struct {
 	interfaces []ir.Class
 	refType    reflect.Type
 	classRef   atomic.Pointer[Ref]
+	staticBase atomic.Pointer[Ref]
+
+	nestHostOnce sync.Once
+	nestHost     *Class
+
+	verifyOnce sync.Once
+	verifyErr  error
+
+	prepareOnce sync.Once
+	init        classInit
 
-	initFMOnce sync.Once
 	Fields     []Field
 	Methods    []Method
 	staticInit *Method
 	staticData unsafe.Pointer
 
-	loadedFieldAccesors map[uint16]func(ir.VM) *Field
-	loadedMethods       map[uint16]func(ir.VM) *Method
+	// fieldTable and methodTable include the members inherited from super classes
+	fieldTable  map[string]*Field
+	methodTable map[string]*Method
+	vtable      []*Method
+	itable      map[*Class][]*Method
+
+	loadedFieldAccesors map[uint16]func(ir.VM) (*Field, error)
+	loadedMethods       map[uint16]func(ir.VM) (*Method, error)
 	loadedDynamics      map[uint16]*dynamicInfo
+	loadedConstants     map[jcls.ConstantInfo]*constantInfo
 }
 
 var _ ir.Class = (*Class)(nil)
@@ -106,6 +121,7 @@ func LoadClass(cls *jcls.Class, loader ir.ClassLoader) *Class {
 		cm := &c.Methods[i]
 		cm.Method = m
 		cm.class = c
+		cm.compiled = lookupCompiledMethod(cm)
 		if cm.Name() == "<clinit>" {
 			if !cm.IsStatic() {
 				panic("class initalize method is not static")
@@ -113,6 +129,7 @@ func LoadClass(cls *jcls.Class, loader ir.ClassLoader) *Class {
 			c.staticInit = cm
 		}
 	}
+	c.link()
 	return c
 }
 
@@ -130,71 +147,6 @@ func (c *Class) NewArrayClass(dim int) *Class {
 	}
 }
 
-func (c *Class) ShouldInit() bool {
-	if c.arrayDim > 0 {
-		return c.elem.ShouldInit()
-	}
-	return c.initVM.Load() == nil
-}
-
-func (c *Class) InitBeforeUse(vm *VM) {
-	if c.arrayDim > 0 {
-		c.elem.InitBeforeUse(vm)
-		return
-	}
-	if c.arrayDim < 0 {
-		return
-	}
-	if c.initVM.Load() == vm {
-		return
-	}
-	c.initVM.CompareAndSwap(nil, vm)
-	c.initFMOnce.Do(c.initFM)
-}
-
-func (c *Class) initFM() {
-	fmt.Println("initing", c.Name())
-	defer fmt.Println("post init", c.Name())
-
-	ivm := c.initVM.Load()
-	if super, ok := c.super.(*Class); ok {
-		// fmt.Println("waiting", super.Name())
-		super.InitBeforeUse(ivm)
-	}
-
-	var err error
-	for i, f := range c.Class.Fields {
-		cf := &c.Fields[i]
-		if f.Desc.EndType == desc.Class {
-			if cf.typ, err = c.loader.LoadClass(f.Desc.Class); err != nil {
-				panic(err)
-			}
-		}
-	}
-
-	c.loadedFieldAccesors = make(map[uint16]func(ir.VM) *Field)
-	c.loadedMethods = make(map[uint16]func(ir.VM) *Method)
-	c.loadedDynamics = make(map[uint16]*dynamicInfo)
-	c.scanCodes()
-
-	if c.staticInit != nil {
-		fmt.Println("==> invoking " + c.Name() + ".<clinit>")
-		vm := c.initVM.Load()
-		prev := vm.stack
-		prev.pc = vm.nextPc
-		vm.stack = &Stack{
-			prev:   prev,
-			class:  c,
-			method: c.staticInit,
-		}
-		vm.nextPc = c.staticInit.Code.Code
-		err := vm.RunStack()
-		if err != nil {
-			panic(err)
-		}
-	}
-}
-
 func (c *Class) ArrayDim() int {
 	return c.arrayDim
 }
@@ -283,7 +235,7 @@ func (c *Class) IsAssignableFrom(k ir.Class) bool {
 	}
 	for k != nil {
 		for _, in := range k.Interfaces() {
-			if c == in {
+			if c == in || c.IsAssignableFrom(in) {
 				return true
 			}
 		}
@@ -300,27 +252,11 @@ func (c *Class) IsInstance(r ir.Ref) bool {
 }
 
 func (c *Class) GetAndPushConst(vm ir.VM, i uint16, s ir.Stack) error {
-	v := c.ConstPool[i-1]
-	switch v := v.(type) {
-	case *jcls.ConstantInteger:
-		s.Push(v.Value)
-	case *jcls.ConstantFloat:
-		s.Push(v.Value)
-	case *jcls.ConstantLong:
-		s.Push64(v.Value)
-	case *jcls.ConstantDouble:
-		s.Push64(v.Value)
-	case *jcls.ConstantString:
-		s.PushRef(vm.GetStringInternOrNew(v.Utf8))
-	case *jcls.ConstantClass:
-		class, err := vm.(*VM).GetClassByName(v.Name)
-		if err != nil {
-			return err
-		}
-		s.PushRef(class.AsRef(vm))
-	default:
-		return fmt.Errorf("Unexpected constant type %T", v)
+	v, err := c.resolveLoadable(vm.(*VM), c.ConstPool[i-1])
+	if err != nil {
+		return err
 	}
+	v.push(s)
 	return nil
 }
 
@@ -339,25 +275,20 @@ func (c *Class) GetFields() iter.Seq[ir.Field] {
 	return c.ForEachField
 }
 
-func (c *Class) GetField(vm ir.VM, i uint16) ir.Field {
-	c.InitBeforeUse(vm.(*VM))
-	return c.loadedFieldAccesors[i](vm)
+func (c *Class) GetField(vm ir.VM, i uint16) (ir.Field, error) {
+	c.prepare()
+	f, err := c.loadedFieldAccesors[i](vm)
+	if err != nil {
+		return nil, err
+	}
+	return f, nil
 }
 
 func (c *Class) GetFieldByName(name string) ir.Field {
-	x := c
-	for {
-		for i := range len(x.Fields) {
-			f := &x.Fields[i]
-			if f.Name() == name {
-				return f
-			}
-		}
-		if x.super == nil {
-			return nil
-		}
-		x = x.super.(*Class)
+	if f, ok := c.fieldTable[name]; ok {
+		return f
 	}
+	return nil
 }
 
 func (c *Class) ForEachMethod(yield func(ir.Method) bool) {
@@ -375,9 +306,13 @@ func (c *Class) GetMethods() iter.Seq[ir.Method] {
 	return c.ForEachMethod
 }
 
-func (c *Class) GetMethod(vm ir.VM, i uint16) ir.Method {
-	c.InitBeforeUse(vm.(*VM))
-	return c.loadedMethods[i](vm)
+func (c *Class) GetMethod(vm ir.VM, i uint16) (ir.Method, error) {
+	c.prepare()
+	m, err := c.loadedMethods[i](vm)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
 func (c *Class) GetMethodByName(location string) ir.Method {
@@ -400,22 +335,14 @@ func (c *Class) GetMethodByDesc(name string, dc *desc.MethodDesc) ir.Method {
 	if c.arrayDim > 0 {
 		return getArrayMethod(c, name)
 	}
-	x := c
-	for {
-		for i := range len(x.Methods) {
-			m := &x.Methods[i]
-			if m.Name() == name && m.Desc().EqInputs(dc) {
-				// if !m.Desc().Output.Eq(dc.Output) {
-				// 	panic("Methods " + name + dc.String() + " have same inputs but different output " + m.Desc().String())
-				// }
-				return m
-			}
-		}
-		if x.super == nil {
-			return nil
-		}
-		x = x.super.(*Class)
+	key := methodKey(name, dc)
+	if m, ok := c.methodTable[key]; ok {
+		return m
 	}
+	if m := selectMaximallySpecificMethod(maximallySpecificMethods(key, c.allInterfaces())); m != nil {
+		return m
+	}
+	return nil
 }
 
 func (c *Class) scanCodes() {
@@ -426,13 +353,13 @@ func (c *Class) scanCodes() {
 		for node := m.Code.Code; node != nil; node = node.Next {
 			switch ic := node.IC.(type) {
 			case *ir.ICgetfield:
-				c.loadFieldGetter(ic.Field, false)
+				c.loadFieldGetter(ic.Field)
 			case *ir.ICgetstatic:
-				c.loadFieldGetter(ic.Field, true)
+				c.loadFieldGetter(ic.Field)
 			case *ir.ICputfield:
-				c.loadFieldGetter(ic.Field, false)
+				c.loadFieldGetter(ic.Field)
 			case *ir.ICputstatic:
-				c.loadFieldGetter(ic.Field, true)
+				c.loadFieldGetter(ic.Field)
 			case *ir.ICinvokedynamic:
 				c.loadMethodDynamic(ic.Method)
 			case *ir.ICinvokeinterface:
@@ -448,7 +375,7 @@ func (c *Class) scanCodes() {
 	}
 }
 
-func (c *Class) loadFieldGetter(ind uint16, static bool) {
+func (c *Class) loadFieldGetter(ind uint16) {
 	if _, ok := c.loadedFieldAccesors[ind]; ok {
 		return
 	}
@@ -456,47 +383,30 @@ func (c *Class) loadFieldGetter(ind uint16, static bool) {
 	if !ok || ref.ConstTag != jcls.TagFieldref {
 		panic(fmt.Errorf("cannot load class: constant at %d is not a field ref", ind-1))
 	}
-	f := c.loadField(c.initVM.Load(), ref, false)
-	if f != nil {
-		if f.IsStatic() != static {
-			panic(fmt.Errorf("field status %v not match getfield/getstatic command", f.IsStatic()))
-		}
-		c.loadedFieldAccesors[ind] = func(ir.VM) *Field { return f }
+	if ref.Class.Name == c.Name() {
+		f, err := c.loadField(c, ref)
+		c.loadedFieldAccesors[ind] = func(ir.VM) (*Field, error) { return f, err }
 	} else {
-		c.loadedFieldAccesors[ind] = OnceApply(func(vm ir.VM) *Field {
-			return c.loadField(vm, ref, true)
+		c.loadedFieldAccesors[ind] = OnceApply2(func(vm ir.VM) (*Field, error) {
+			k, err := c.loader.LoadClass(ref.Class.Name)
+			if err != nil {
+				return nil, err
+			}
+			return c.loadField(k.(*Class), ref)
 		})
 	}
 }
 
-func (c *Class) loadField(vm ir.VM, ref *jcls.ConstantRef, canLoadClass bool) *Field {
-	var x *Class
-	if ref.Class.Name == c.Name() {
-		x = c
-	} else if canLoadClass {
-		k, err := c.loader.LoadClass(ref.Class.Name)
-		if err != nil {
-			panic(err)
-		}
-		x = k.(*Class)
-		x.InitBeforeUse(vm.(*VM))
-	} else {
-		return nil
-	}
-	for {
-		for i, f := range x.Class.Fields {
-			if f.Name() == ref.NameAndType.Name {
-				if f.Desc.String() != ref.NameAndType.Desc {
-					panic(fmt.Errorf("cannot load class: field %s is %s, but one operation requires %s", ref.NameAndType.Name, f.Desc.String(), ref.NameAndType.Desc))
-				}
-				return &x.Fields[i]
-			}
-		}
-		if x.super == nil {
-			return nil
-		}
-		x = x.super.(*Class)
+// loadField resolves the field reference through the class x, and checks whether the field is accessible
+func (c *Class) loadField(x *Class, ref *jcls.ConstantRef) (*Field, error) {
+	f, err := x.resolveField(ref.NameAndType.Name, ref.NameAndType.Desc)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.checkFieldAccess(x, f); err != nil {
+		return nil, err
 	}
+	return f, nil
 }
 
 func (c *Class) loadMethodGetter(ind uint16) {
@@ -510,61 +420,48 @@ func (c *Class) loadMethodGetter(ind uint16) {
 	if ref.Class.Name[0] == '[' {
 		if ref.Class.Name[len(ref.Class.Name)-1] == ';' {
 			// lazy load class
-			c.loadedMethods[ind] = OnceApply(func(vm ir.VM) *Method {
-				return vm.(*VM).getArrayMethodByName(ref)
+			c.loadedMethods[ind] = OnceApply2(func(vm ir.VM) (*Method, error) {
+				return vm.(*VM).getArrayMethodByName(ref), nil
 			})
 		} else {
 			arrayMethod := ((*VM)(nil)).getArrayMethodByName(ref)
-			c.loadedMethods[ind] = func(ir.VM) *Method {
-				return arrayMethod
+			c.loadedMethods[ind] = func(ir.VM) (*Method, error) {
+				return arrayMethod, nil
 			}
 		}
 	} else {
-		c.loadedMethods[ind] = OnceApply(func(vm ir.VM) *Method {
-			fmt.Println("loading method:", ind, ref)
+		c.loadedMethods[ind] = OnceApply2(func(vm ir.VM) (*Method, error) {
 			return c.loadMethod(vm, ref)
 		})
 	}
 }
 
-func (c *Class) loadMethod(vm ir.VM, ref *jcls.ConstantRef) *Method {
+func (c *Class) loadMethod(vm ir.VM, ref *jcls.ConstantRef) (*Method, error) {
 	k, err := c.loader.LoadClass(ref.Class.Name)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	x := k.(*Class)
-	x.InitBeforeUse(vm.(*VM))
-	method := x.loadMethod0(ref)
-	if method == nil {
-		panic(fmt.Errorf("cannot load class: missing method %s", ref))
+	var m *Method
+	if ref.ConstTag == jcls.TagInterfaceMethodref {
+		m, err = x.resolveInterfaceMethod(ref.NameAndType.Name, ref.NameAndType.Desc)
+	} else {
+		m, err = x.resolveMethod(ref.NameAndType.Name, ref.NameAndType.Desc)
 	}
-	return method
-}
-
-func (c *Class) loadMethod0(ref *jcls.ConstantRef) *Method {
-	for x := c; x != nil; x = x.super.(*Class) {
-		for i, m := range x.Class.Methods {
-			if m.Name() == ref.NameAndType.Name && m.Desc().String() == ref.NameAndType.Desc {
-				return &x.Methods[i]
-			}
-		}
-		if x.super == nil {
-			break
-		}
+	if err != nil {
+		return nil, err
 	}
-	for _, in := range c.interfaces {
-		m := in.(*Class).loadMethod0(ref)
-		if m != nil {
-			return m
-		}
+	if err := c.checkMethodAccess(x, m); err != nil {
+		return nil, err
 	}
-	return nil
+	return m, nil
 }
 
 type dynamicInfo struct {
 	info      *jcls.ConstantDynamics
 	bootstrap *jcls.BootstrapMethod
-	callSite  *Ref
+	typ       *desc.MethodDesc
+	linkage   atomic.Pointer[callSiteLinkage]
 }
 
 func (c *Class) loadMethodDynamic(ind uint16) {
@@ -572,17 +469,18 @@ func (c *Class) loadMethodDynamic(ind uint16) {
 	if !ok || info.ConstTag != jcls.TagInvokeDynamic {
 		panic(fmt.Errorf("cannot load class: constant at %d is not a invokedynamic", ind-1))
 	}
+	typ, err := desc.ParseMethodDesc(info.NameAndType.Desc)
+	if err != nil {
+		panic(err)
+	}
 	bootstrap := c.GetAttr("BootstrapMethods").(*jcls.AttrBootstrapMethods).Methods[info.BootstrapMethod]
 	c.loadedDynamics[ind] = &dynamicInfo{
 		info:      info,
 		bootstrap: bootstrap,
+		typ:       typ,
 	}
 }
 
-func (c *Class) invokeMethodDynamic(ref *jcls.ConstantDynamics) *Method {
-	panic(fmt.Errorf("TODO: Trying to invoke dynamic %#v %#v", ref, ref.NameAndType))
-}
-
 func j2goName(name string) string {
 	return strings.ReplaceAll(name, "$", "__")
 }
@@ -0,0 +1,257 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+type classInitState = int32
+
+const (
+	classUninitialized classInitState = iota
+	classInitializing
+	classInitialized
+	classErroneous
+)
+
+// classInit is the initialization lock and state of a class, see JVMS 5.5
+type classInit struct {
+	state atomic.Int32
+	mux   sync.Mutex
+	// thread is the VM which is initializing the class
+	thread *VM
+	// done will be closed when the initialization is completed or failed
+	done chan struct{}
+}
+
+func (c *Class) ShouldInit() bool {
+	return c.arrayDim == 0 && c.init.state.Load() != classInitialized
+}
+
+// initError is the panic value of InitBeforeUse.
+// It is recovered when it reaches the native method call, and thrown as the Java error it carries.
+type initError struct {
+	err error
+}
+
+func (e *initError) Error() string {
+	return e.err.Error()
+}
+
+func (e *initError) Unwrap() error {
+	return e.err
+}
+
+// InitBeforeUse initializes the class, and panics with *initError if the initialization failed.
+// Natives may call it through New and NewArray, where the error will be thrown to the caller.
+func (c *Class) InitBeforeUse(vm *VM) {
+	if err := c.Initialize(vm); err != nil {
+		panic(&initError{err})
+	}
+}
+
+// Initialize initializes the class with the procedure defined in JVMS 5.5.
+// Recursive request from the initializing VM returns immediately,
+// and requests from other VMs will wait until the initialization is done.
+//
+// If the initialization failed, the class will be marked as erroneous.
+// The first error is returned as is, and later calls will return NoClassDefFoundError.
+func (c *Class) Initialize(vm *VM) error {
+	if c.arrayDim != 0 {
+		return nil
+	}
+	ci := &c.init
+	if ci.state.Load() == classInitialized {
+		return nil
+	}
+	c.prepare()
+	if err := vm.verify(c); err != nil {
+		return err
+	}
+	for {
+		ci.mux.Lock()
+		switch ci.state.Load() {
+		case classInitialized:
+			ci.mux.Unlock()
+			return nil
+		case classErroneous:
+			ci.mux.Unlock()
+			return errs.Throw("java/lang/NoClassDefFoundError", "Could not initialize class "+strings.ReplaceAll(c.Name(), "/", "."))
+		case classInitializing:
+			if ci.thread == vm {
+				ci.mux.Unlock()
+				return nil
+			}
+			done := ci.done
+			ci.mux.Unlock()
+			// the initialization may take long, so the waiting thread must not hold stop-the-world operations
+			vm.EnterSafeRegion()
+			<-done
+			vm.LeaveSafeRegion()
+			continue
+		}
+		ci.state.Store(classInitializing)
+		ci.thread = vm
+		ci.done = make(chan struct{})
+		ci.mux.Unlock()
+		break
+	}
+
+	err := c.initialize0(vm)
+
+	ci.mux.Lock()
+	if err != nil {
+		ci.state.Store(classErroneous)
+	} else {
+		ci.state.Store(classInitialized)
+	}
+	ci.thread = nil
+	close(ci.done)
+	ci.mux.Unlock()
+	return err
+}
+
+func (c *Class) initialize0(vm *VM) error {
+	if vm.tracing() {
+		fmt.Println("initializing", c.Name())
+	}
+
+	if !c.IsInterface() {
+		if super, ok := c.super.(*Class); ok {
+			if err := super.Initialize(vm); err != nil {
+				return err
+			}
+		}
+		for _, in := range c.interfacesToInitialize() {
+			if err := in.Initialize(vm); err != nil {
+				return err
+			}
+		}
+	}
+
+	if c.staticInit == nil {
+		return nil
+	}
+	if vm.tracing() {
+		fmt.Println("==> invoking " + c.Name() + ".<clinit>")
+	}
+	prev := vm.stack
+	prev.nextPc = vm.nextPc
+	vm.stack = &Stack{
+		prev:   prev,
+		class:  c,
+		method: c.staticInit,
+	}
+	vm.nextPc = c.staticInit.Code.Code
+	err := vm.RunStack()
+	if err == nil {
+		return nil
+	}
+	te, ok := err.(*ThrowableError)
+	if !ok {
+		return err
+	}
+	errorClass, err := vm.loadClass("java/lang/Error")
+	if err != nil {
+		return err
+	}
+	if errorClass.IsAssignableFrom(te.Throwable.class) {
+		return te
+	}
+	return vm.newExceptionInInitializerError(te.Throwable)
+}
+
+func (vm *VM) newExceptionInInitializerError(thrown *Ref) error {
+	cls, err := vm.loadClass("java/lang/ExceptionInInitializerError")
+	if err != nil {
+		return err
+	}
+	constructor := cls.GetMethodByNameAndType("<init>", "(Ljava/lang/Throwable;)V")
+	if constructor == nil {
+		return fmt.Errorf("vm: %s does not have constructor (Ljava/lang/Throwable;)V", cls.Name())
+	}
+	ref := vm.New(cls)
+	vm.stack.PushRef(ref)
+	vm.stack.PushRef(thrown)
+	vm.Invoke(constructor)
+	if err := vm.RunStack(); err != nil {
+		return err
+	}
+	return vm.newThrowableError(ref.(*Ref))
+}
+
+// interfacesToInitialize returns the superinterfaces which declare non-abstract and non-static methods,
+// in the order they should be initialized before the class.
+func (c *Class) interfacesToInitialize() []*Class {
+	var (
+		result []*Class
+		seen   = make(map[*Class]struct{})
+		visit  func(in *Class)
+	)
+	visit = func(in *Class) {
+		if _, ok := seen[in]; ok {
+			return
+		}
+		seen[in] = struct{}{}
+		for _, s := range in.interfaces {
+			visit(s.(*Class))
+		}
+		if in.declaresDefaultMethod() {
+			result = append(result, in)
+		}
+	}
+	for _, in := range c.interfaces {
+		visit(in.(*Class))
+	}
+	return result
+}
+
+func (c *Class) declaresDefaultMethod() bool {
+	for i := range c.Methods {
+		if !c.Methods[i].AccessFlags.Has(jcls.AccAbstract | jcls.AccStatic) {
+			return true
+		}
+	}
+	return false
+}
+
+// prepare loads the field types and the symbols referenced by the codes.
+// It only runs once, and does not initialize the class.
+func (c *Class) prepare() {
+	c.prepareOnce.Do(c.prepare0)
+}
+
+func (c *Class) prepare0() {
+	var err error
+	for i, f := range c.Class.Fields {
+		cf := &c.Fields[i]
+		if f.Desc.EndType == desc.Class {
+			if cf.typ, err = c.loader.LoadClass(f.Desc.Class); err != nil {
+				panic(err)
+			}
+		}
+	}
+
+	c.loadedFieldAccesors = make(map[uint16]func(ir.VM) (*Field, error))
+	c.loadedMethods = make(map[uint16]func(ir.VM) (*Method, error))
+	c.loadedDynamics = make(map[uint16]*dynamicInfo)
+	c.loadedConstants = make(map[jcls.ConstantInfo]*constantInfo)
+	for _, v := range c.ConstPool {
+		switch v := v.(type) {
+		case *jcls.ConstantMethodHandle, *jcls.ConstantMethodType:
+			c.loadedConstants[v] = new(constantInfo)
+		case *jcls.ConstantDynamics:
+			if v.ConstTag == jcls.TagDynamic {
+				c.loadedConstants[v] = new(constantInfo)
+			}
+		}
+	}
+	c.scanCodes()
+}
@@ -0,0 +1,79 @@
+package vm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestClassInitializationOrder(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	const iflags = jcls.AccPublic | jcls.AccInterface | jcls.AccAbstract
+	i := l.define(iflags, "I", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccNative, "run", "()V"),
+	)
+	j := l.define(iflags, "J", "java/lang/Object", []string{"I"},
+		testMethod(jcls.AccPublic|jcls.AccAbstract, "size", "()I"),
+	)
+	k := l.define(iflags, "K", "java/lang/Object", []string{"J"},
+		testMethod(jcls.AccPublic|jcls.AccNative, "name", "()Ljava/lang/String;"),
+	)
+	a := l.define(jcls.AccPublic, "A", "java/lang/Object", []string{"K"})
+	b := l.define(jcls.AccPublic, "B", "A", nil)
+
+	order := a.interfacesToInitialize()
+	if len(order) != 2 || order[0] != i || order[1] != k {
+		t.Errorf("unexpected interfaces initialization order %v", order)
+	}
+
+	vm := new(VM)
+	if !b.ShouldInit() {
+		t.Errorf("B should be initialized before use")
+	}
+	if err := b.Initialize(vm); err != nil {
+		t.Fatalf("cannot initialize B: %v", err)
+	}
+	for _, c := range []*Class{a, b, i, k} {
+		if c.ShouldInit() {
+			t.Errorf("%s should be initialized", c.Name())
+		}
+	}
+	if !j.ShouldInit() {
+		t.Errorf("J does not declare default methods and should not be initialized")
+	}
+
+	if err := k.Initialize(vm); err != nil {
+		t.Fatalf("cannot initialize K: %v", err)
+	}
+	if !j.ShouldInit() {
+		t.Errorf("initializing interface K should not initialize its superinterfaces")
+	}
+}
+
+func TestInitFailureInNative(t *testing.T) {
+	vm := new(VM)
+	frame := new(Stack)
+	vm.stack = frame
+	failure := errors.New("initialization failed")
+	err := vm.callNative(func(vm ir.VM) error {
+		vm.(*VM).stack = &Stack{prev: frame}
+		panic(&initError{failure})
+	}, frame)
+	if err != failure {
+		t.Errorf("got error %v, want %v", err, failure)
+	}
+	if vm.stack != frame {
+		t.Errorf("the stack is not restored to the native frame")
+	}
+
+	defer func() {
+		if r := recover(); r != "other" {
+			t.Errorf("unexpected recovered value %v", r)
+		}
+	}()
+	vm.callNative(func(vm ir.VM) error { panic("other") }, frame)
+	t.Errorf("other panics should not be recovered")
+}
@@ -0,0 +1,444 @@
+package vm
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+)
+
+// defaultCompileThreshold is the number of invocations before a method's blocks are compiled
+const defaultCompileThreshold = 100
+
+// maxBlockLength limits the number of instructions translated into one block,
+// since every instruction adds a closure call to the Go stack
+const maxBlockLength = 256
+
+// compiledBlock is a straight-line run of decoded instructions translated into a chain of Go closures.
+// The closures operate on the interpreter's Stack, with operand stack slots resolved to fixed indexes,
+// so both tiers share the same frame and the interpreter may continue anywhere after the block.
+type compiledBlock struct {
+	// depth is the operand stack depth at the block entry.
+	// The JVM requires the depth to be the same every time an instruction is reached.
+	depth     int
+	exitDepth int
+	// last is the instruction reported as the frame's pc after the block
+	last *ir.ICNode
+	// run executes the block and returns the index of the next instruction
+	run func(s *Stack) int32
+}
+
+// noBlock marks an instruction where no block can be compiled
+var noBlock = new(compiledBlock)
+
+// compiledBlocks holds the blocks of a hot method, indexed by the index of their first instruction
+type compiledBlocks []atomic.Pointer[compiledBlock]
+
+// compileThreshold returns how many invocations are required before compiling, or -1 if the tier is disabled
+func (vm *VM) compileThreshold() int32 {
+	if vm.opts == nil || vm.opts.CompileThreshold == 0 {
+		return defaultCompileThreshold
+	}
+	if vm.opts.CompileThreshold < 0 {
+		return -1
+	}
+	return (int32)(vm.opts.CompileThreshold)
+}
+
+// hotBlocks counts the invocation if the frame is just entered,
+// and returns the method's block table once the method is hot
+func (m *Method) hotBlocks(vm *VM, s *Stack) compiledBlocks {
+	if b := m.blocks.Load(); b != nil {
+		return *b
+	}
+	if s.pc != nil {
+		return nil
+	}
+	threshold := vm.compileThreshold()
+	if threshold < 0 || m.calls.Add(1) < threshold {
+		return nil
+	}
+	b := make(compiledBlocks, len(m.decodedInsts()))
+	if !m.blocks.CompareAndSwap(nil, &b) {
+		return *m.blocks.Load()
+	}
+	return b
+}
+
+// block returns the block starting at pc, and compiles it with the current stack depth if it is not compiled yet.
+// It returns nil if the instruction should be interpreted.
+func (m *Method) block(blocks compiledBlocks, code []inst, pc int32, depth int) *compiledBlock {
+	b := blocks[pc].Load()
+	if b == nil {
+		b = compileBlock(code, pc, depth, (int)(m.Code.MaxStack), (int)(m.Code.MaxLocals))
+		// Racing threads compile the same block, so whichever store wins is fine.
+		blocks[pc].Store(b)
+	}
+	if b.run == nil || b.depth != depth {
+		return nil
+	}
+	return b
+}
+
+// runBlocks executes the compiled blocks from pc until an instruction which does not start a block,
+// and returns the index of that instruction.
+// The operand stack is extended to the max stack size while the blocks run,
+// and is cut back to the depth after the last block.
+// It returns early to park the thread when a back edge is taken while a safepoint is pending.
+func (m *Method) runBlocks(vm *VM, s *Stack, blocks compiledBlocks, code []inst, pc int32) int32 {
+	depth := len(s.stack)
+	b := m.block(blocks, code, pc, depth)
+	if b == nil {
+		return pc
+	}
+	maxLocals, maxStack := (int)(m.Code.MaxLocals), (int)(m.Code.MaxStack)
+	if n := maxLocals - len(s.vars); n > 0 {
+		s.vars = append(s.vars, make([]uint32, n)...)
+		s.varRefs = append(s.varRefs, make([]*Ref, n)...)
+	}
+	// the capacities of the slices may differ, since they are grown by append separately
+	if cap(s.stack) < maxStack {
+		s.stack = slices.Grow(s.stack, maxStack-len(s.stack))
+	}
+	if cap(s.stackRefs) < maxStack {
+		s.stackRefs = slices.Grow(s.stackRefs, maxStack-len(s.stackRefs))
+	}
+	s.stack = s.stack[:maxStack]
+	s.stackRefs = s.stackRefs[:maxStack]
+	park := false
+	for b != nil {
+		start := pc
+		pc = b.run(s)
+		depth = b.exitDepth
+		s.pc = b.last
+		if pc <= start && vm.safepointPending() {
+			// leave at the back edge, so the stack is cut back while the thread is parked
+			park = true
+			break
+		}
+		b = m.block(blocks, code, pc, depth)
+	}
+	clear(s.stackRefs[depth:])
+	s.stack = s.stack[:depth]
+	s.stackRefs = s.stackRefs[:depth]
+	if park {
+		vm.parkAtSafepoint()
+	}
+	return pc
+}
+
+// compileBlock translates the instructions from start until one which cannot be compiled, or a jump.
+// A single instruction block is not worth leaving the interpreter loop, so noBlock is returned instead.
+func compileBlock(code []inst, start int32, depth int, maxStack, maxLocals int) *compiledBlock {
+	b := &compiledBlock{depth: depth}
+	type step struct {
+		in    *inst
+		depth int
+	}
+	var steps []step
+	end := start
+	for end < (int32)(len(code)) && len(steps) < maxBlockLength {
+		in := &code[end]
+		delta, ok := compiledStackDelta(in.op)
+		if !ok || depth+delta < 0 || depth+delta > maxStack || !compiledVarFits(in, maxLocals) {
+			break
+		}
+		steps = append(steps, step{in, depth})
+		depth += delta
+		end++
+		if isCompiledJump(in.op) {
+			break
+		}
+	}
+	if len(steps) < 2 {
+		return noBlock
+	}
+	b.exitDepth = depth
+	b.last = steps[len(steps)-1].in.node
+
+	// build the chain from the last instruction, so every closure holds the next one
+	last := steps[len(steps)-1]
+	var next func(s *Stack) int32
+	if isCompiledJump(last.in.op) {
+		next = compileJump(last.in, last.depth, end)
+		steps = steps[:len(steps)-1]
+	} else {
+		next = func(*Stack) int32 { return end }
+	}
+	for _, st := range slices.Backward(steps) {
+		next = compileInst(st.in, st.depth, next)
+	}
+	b.run = next
+	return b
+}
+
+// compiledVarFits reports whether the local variables accessed by the instruction are in the frame,
+// since the compiled block does not grow the variables as the interpreter does
+func compiledVarFits(in *inst, maxLocals int) bool {
+	switch in.op {
+	case instLoad, instLoadRef, instStore, instStoreRef:
+		return (int)(in.a) < maxLocals
+	case instLoad64, instStore64:
+		return (int)(in.a)+1 < maxLocals
+	case instIinc:
+		return (int)(in.b) < maxLocals
+	}
+	return true
+}
+
+// compiledStackDelta returns the operand stack depth change of an instruction which can be compiled
+func compiledStackDelta(op instOp) (int, bool) {
+	switch op {
+	case instNop, instIinc, instIneg, instI2b, instI2c, instI2s, instGoto:
+		return 0, true
+	case instConst, instAconstNull, instLoad, instLoadRef, instDup, instI2l:
+		return 1, true
+	case instConst64, instLoad64:
+		return 2, true
+	case instStore, instStoreRef, instPop, instL2i,
+		instIadd, instIsub, instImul, instIand, instIor, instIxor, instIshl, instIshr, instIushr,
+		instIfeq, instIfne, instIflt, instIfge, instIfgt, instIfle, instIfnull, instIfnonnull:
+		return -1, true
+	case instStore64, instPop2, instLadd, instLsub, instLmul,
+		instIfIcmpeq, instIfIcmpne, instIfIcmplt, instIfIcmpge, instIfIcmpgt, instIfIcmple, instIfAcmpeq, instIfAcmpne:
+		return -2, true
+	case instLcmp:
+		return -3, true
+	}
+	return 0, false
+}
+
+func isCompiledJump(op instOp) bool {
+	return instGoto <= op && op <= instIfnonnull
+}
+
+// compileInst binds a non-jump instruction to the stack depth before it and to the rest of the block
+func compileInst(in *inst, d int, next func(s *Stack) int32) func(s *Stack) int32 {
+	a, b := in.a, in.b
+	switch in.op {
+	case instNop:
+		return next
+	case instConst:
+		v := (uint32)(a)
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = v, nil
+			return next(s)
+		}
+	case instConst64:
+		hi, lo := (uint32)(b), (uint32)(a)
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = hi, nil
+			s.stack[d+1], s.stackRefs[d+1] = lo, nil
+			return next(s)
+		}
+	case instAconstNull:
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = 0, nil
+			return next(s)
+		}
+	case instLoad:
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = s.vars[a], nil
+			return next(s)
+		}
+	case instLoad64:
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = s.vars[a], nil
+			s.stack[d+1], s.stackRefs[d+1] = s.vars[a+1], nil
+			return next(s)
+		}
+	case instLoadRef:
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = 0, s.varRefs[a]
+			return next(s)
+		}
+	case instStore:
+		return func(s *Stack) int32 {
+			s.vars[a], s.varRefs[a] = s.stack[d-1], nil
+			return next(s)
+		}
+	case instStore64:
+		return func(s *Stack) int32 {
+			s.vars[a], s.varRefs[a] = s.stack[d-2], nil
+			s.vars[a+1], s.varRefs[a+1] = s.stack[d-1], nil
+			return next(s)
+		}
+	case instStoreRef:
+		return func(s *Stack) int32 {
+			s.vars[a], s.varRefs[a] = 0, s.stackRefs[d-1]
+			return next(s)
+		}
+	case instIinc:
+		return func(s *Stack) int32 {
+			s.vars[b] = (uint32)((int32)(s.vars[b]) + a)
+			return next(s)
+		}
+	case instPop, instPop2:
+		return next
+	case instDup:
+		return func(s *Stack) int32 {
+			s.stack[d], s.stackRefs[d] = s.stack[d-1], s.stackRefs[d-1]
+			return next(s)
+		}
+	case instIadd:
+		return func(s *Stack) int32 {
+			s.stack[d-2] = (uint32)((int32)(s.stack[d-2]) + (int32)(s.stack[d-1]))
+			return next(s)
+		}
+	case instIsub:
+		return func(s *Stack) int32 {
+			s.stack[d-2] = (uint32)((int32)(s.stack[d-2]) - (int32)(s.stack[d-1]))
+			return next(s)
+		}
+	case instImul:
+		return func(s *Stack) int32 {
+			s.stack[d-2] = (uint32)((int32)(s.stack[d-2]) * (int32)(s.stack[d-1]))
+			return next(s)
+		}
+	case instIneg:
+		return func(s *Stack) int32 {
+			s.stack[d-1] = (uint32)(-(int32)(s.stack[d-1]))
+			return next(s)
+		}
+	case instIand:
+		return func(s *Stack) int32 {
+			s.stack[d-2] &= s.stack[d-1]
+			return next(s)
+		}
+	case instIor:
+		return func(s *Stack) int32 {
+			s.stack[d-2] |= s.stack[d-1]
+			return next(s)
+		}
+	case instIxor:
+		return func(s *Stack) int32 {
+			s.stack[d-2] ^= s.stack[d-1]
+			return next(s)
+		}
+	case instIshl:
+		return func(s *Stack) int32 {
+			s.stack[d-2] <<= s.stack[d-1] & 0x1f
+			return next(s)
+		}
+	case instIshr:
+		return func(s *Stack) int32 {
+			s.stack[d-2] = (uint32)((int32)(s.stack[d-2]) >> (s.stack[d-1] & 0x1f))
+			return next(s)
+		}
+	case instIushr:
+		return func(s *Stack) int32 {
+			s.stack[d-2] >>= s.stack[d-1] & 0x1f
+			return next(s)
+		}
+	case instLadd:
+		return func(s *Stack) int32 {
+			setLong(s, d-4, getLong(s, d-4)+getLong(s, d-2))
+			return next(s)
+		}
+	case instLsub:
+		return func(s *Stack) int32 {
+			setLong(s, d-4, getLong(s, d-4)-getLong(s, d-2))
+			return next(s)
+		}
+	case instLmul:
+		return func(s *Stack) int32 {
+			setLong(s, d-4, getLong(s, d-4)*getLong(s, d-2))
+			return next(s)
+		}
+	case instLcmp:
+		return func(s *Stack) int32 {
+			x, y := getLong(s, d-4), getLong(s, d-2)
+			var v int32
+			if x > y {
+				v = 1
+			} else if x < y {
+				v = -1
+			}
+			s.stack[d-4] = (uint32)(v)
+			return next(s)
+		}
+	case instI2l:
+		return func(s *Stack) int32 {
+			setLong(s, d-1, (int64)((int32)(s.stack[d-1])))
+			return next(s)
+		}
+	case instL2i:
+		return func(s *Stack) int32 {
+			s.stack[d-2] = s.stack[d-1]
+			return next(s)
+		}
+	case instI2b:
+		return func(s *Stack) int32 {
+			s.stack[d-1] = (uint32)((int32)((int8)(s.stack[d-1])))
+			return next(s)
+		}
+	case instI2c:
+		return func(s *Stack) int32 {
+			s.stack[d-1] = (uint32)((uint16)(s.stack[d-1]))
+			return next(s)
+		}
+	case instI2s:
+		return func(s *Stack) int32 {
+			s.stack[d-1] = (uint32)((int32)((int16)(s.stack[d-1])))
+			return next(s)
+		}
+	}
+	panic("vm: instruction cannot be compiled")
+}
+
+// compileJump binds the jump which ends the block, next is the index of the instruction after it
+func compileJump(in *inst, d int, next int32) func(s *Stack) int32 {
+	target := in.target
+	cond := func(ok bool) int32 {
+		if ok {
+			return target
+		}
+		return next
+	}
+	switch in.op {
+	case instGoto:
+		return func(*Stack) int32 { return target }
+	case instIfeq:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) == 0) }
+	case instIfne:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) != 0) }
+	case instIflt:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) < 0) }
+	case instIfge:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) >= 0) }
+	case instIfgt:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) > 0) }
+	case instIfle:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-1]) <= 0) }
+	case instIfIcmpeq:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) == (int32)(s.stack[d-1])) }
+	case instIfIcmpne:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) != (int32)(s.stack[d-1])) }
+	case instIfIcmplt:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) < (int32)(s.stack[d-1])) }
+	case instIfIcmpge:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) >= (int32)(s.stack[d-1])) }
+	case instIfIcmpgt:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) > (int32)(s.stack[d-1])) }
+	case instIfIcmple:
+		return func(s *Stack) int32 { return cond((int32)(s.stack[d-2]) <= (int32)(s.stack[d-1])) }
+	case instIfAcmpeq:
+		return func(s *Stack) int32 { return cond(s.stackRefs[d-2] == s.stackRefs[d-1]) }
+	case instIfAcmpne:
+		return func(s *Stack) int32 { return cond(s.stackRefs[d-2] != s.stackRefs[d-1]) }
+	case instIfnull:
+		return func(s *Stack) int32 { return cond(s.stackRefs[d-1] == nil) }
+	case instIfnonnull:
+		return func(s *Stack) int32 { return cond(s.stackRefs[d-1] != nil) }
+	}
+	panic("vm: instruction is not a jump")
+}
+
+func getLong(s *Stack, i int) int64 {
+	return (int64)((uint64)(s.stack[i])<<32 | (uint64)(s.stack[i+1]))
+}
+
+func setLong(s *Stack, i int, v int64) {
+	s.stack[i], s.stackRefs[i] = (uint32)((uint64)(v)>>32), nil
+	s.stack[i+1], s.stackRefs[i+1] = (uint32)(v), nil
+}
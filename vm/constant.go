@@ -0,0 +1,171 @@
+package vm
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// jvalue is a Java value with its type
+type jvalue struct {
+	typ desc.Type
+	val uint64
+	ref *Ref
+}
+
+func (v jvalue) push(s ir.Stack) {
+	switch v.typ {
+	case desc.Class, desc.Array:
+		s.PushRef(v.ref)
+	case desc.Long, desc.Double:
+		s.Push64(v.val)
+	default:
+		s.Push((uint32)(v.val))
+	}
+}
+
+// popValue pops a value of the type from the operand stack
+func (s *Stack) popValue(dc *desc.Desc) jvalue {
+	v := jvalue{typ: dc.Type()}
+	switch v.typ {
+	case desc.Class, desc.Array:
+		v.ref, _ = s.PopRef().(*Ref)
+	case desc.Long, desc.Double:
+		v.val = s.Pop64()
+	default:
+		v.val = (uint64)(s.Pop())
+	}
+	return v
+}
+
+// popValues pops the values of the types from the operand stack, and returns them in order
+func (s *Stack) popValues(types []*desc.Desc) []jvalue {
+	values := make([]jvalue, len(types))
+	for i := len(types) - 1; i >= 0; i-- {
+		values[i] = s.popValue(types[i])
+	}
+	return values
+}
+
+// resolveLoadable resolves the loadable constant as JVMS 5.1 defined
+func (c *Class) resolveLoadable(vm *VM, v jcls.ConstantInfo) (jvalue, error) {
+	switch v := v.(type) {
+	case *jcls.ConstantInteger:
+		return jvalue{typ: desc.Int, val: (uint64)(v.Value)}, nil
+	case *jcls.ConstantFloat:
+		return jvalue{typ: desc.Float, val: (uint64)(v.Value)}, nil
+	case *jcls.ConstantLong:
+		return jvalue{typ: desc.Long, val: v.Value}, nil
+	case *jcls.ConstantDouble:
+		return jvalue{typ: desc.Double, val: v.Value}, nil
+	case *jcls.ConstantString:
+		return jvalue{typ: desc.Class, ref: vm.GetStringInternOrNew(v.Utf8).(*Ref)}, nil
+	case *jcls.ConstantClass:
+		class, err := vm.GetClassByName(v.Name)
+		if err != nil {
+			return jvalue{}, err
+		}
+		return jvalue{typ: desc.Class, ref: class.AsRef(vm).(*Ref)}, nil
+	case *jcls.ConstantMethodHandle, *jcls.ConstantMethodType:
+		return c.resolveCachedConstant(vm, v)
+	case *jcls.ConstantDynamics:
+		if v.ConstTag == jcls.TagDynamic {
+			return c.resolveCachedConstant(vm, v)
+		}
+	}
+	return jvalue{}, fmt.Errorf("Unexpected constant type %T", v)
+}
+
+// constantInfo caches the resolution result of a constant which is resolved through Java code.
+// Failed resolution is cached as well, so later resolution will fail with the same error.
+type constantInfo struct {
+	mux      sync.Mutex
+	resolved bool
+	value    jvalue
+	err      error
+	// resolving is the VMs which are resolving the constant, it is used to detect circular dynamic constants
+	resolving []*VM
+}
+
+func (c *Class) resolveCachedConstant(vm *VM, v jcls.ConstantInfo) (jvalue, error) {
+	c.prepare()
+	info := c.loadedConstants[v]
+	if info == nil {
+		return jvalue{}, fmt.Errorf("vm: constant %v is not in the constant pool of %s", v, c.Name())
+	}
+	info.mux.Lock()
+	if info.resolved {
+		info.mux.Unlock()
+		return info.value, info.err
+	}
+	for _, r := range info.resolving {
+		if r == vm {
+			info.mux.Unlock()
+			return jvalue{}, errs.Throw("java/lang/StackOverflowError", "Circular dynamic constant")
+		}
+	}
+	info.resolving = append(info.resolving, vm)
+	info.mux.Unlock()
+
+	value, err := c.resolveConstant0(vm, v)
+
+	info.mux.Lock()
+	defer info.mux.Unlock()
+	for i, r := range info.resolving {
+		if r == vm {
+			info.resolving = append(info.resolving[:i], info.resolving[i+1:]...)
+			break
+		}
+	}
+	// the first completed resolution wins if there are multiple VMs resolving the constant
+	if !info.resolved {
+		info.resolved, info.value, info.err = true, value, err
+	}
+	return info.value, info.err
+}
+
+func (c *Class) resolveConstant0(vm *VM, v jcls.ConstantInfo) (jvalue, error) {
+	switch v := v.(type) {
+	case *jcls.ConstantMethodHandle:
+		ref, err := vm.NewMethodHandle(c, v)
+		if err != nil {
+			return jvalue{}, err
+		}
+		return jvalue{typ: desc.Class, ref: ref.(*Ref)}, nil
+	case *jcls.ConstantMethodType:
+		ref, err := vm.NewMethodType(v.Desc)
+		if err != nil {
+			return jvalue{}, err
+		}
+		return jvalue{typ: desc.Class, ref: ref.(*Ref)}, nil
+	case *jcls.ConstantDynamics:
+		return c.resolveDynamicConstant(vm, v)
+	}
+	panic("unreachable")
+}
+
+// resolveDynamicConstant invokes the bootstrap method of the dynamic constant,
+// and converts the result to the constant's type, see JVMS 5.4.3.6
+func (c *Class) resolveDynamicConstant(vm *VM, v *jcls.ConstantDynamics) (jvalue, error) {
+	dc, err := desc.ParseDesc(v.NameAndType.Desc)
+	if err != nil {
+		return jvalue{}, err
+	}
+	typ, err := vm.GetClassFromDesc(dc)
+	if err != nil {
+		return jvalue{}, err
+	}
+	bootstrap := c.GetAttr("BootstrapMethods").(*jcls.AttrBootstrapMethods).Methods[v.BootstrapMethod]
+	result, err := vm.invokeBootstrap(c, bootstrap, v.NameAndType.Name, typ.AsRef(vm).(*Ref))
+	if err == nil {
+		result, err = vm.convertArg(result, dc)
+	}
+	if err != nil {
+		return jvalue{}, vm.bootstrapMethodError(err)
+	}
+	return result, nil
+}
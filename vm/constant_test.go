@@ -0,0 +1,41 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestResolveCachedConstant(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	c := l.define(jcls.AccPublic, "A", "java/lang/Object", nil)
+	badType := &jcls.ConstantMethodType{Desc: "bad"}
+	condy := &jcls.ConstantDynamics{
+		ConstTag:    jcls.TagDynamic,
+		NameAndType: &jcls.ConstantNameAndType{Name: "c", Desc: "I"},
+	}
+	indy := &jcls.ConstantDynamics{
+		ConstTag:    jcls.TagInvokeDynamic,
+		NameAndType: &jcls.ConstantNameAndType{Name: "c", Desc: "()V"},
+	}
+	c.ConstPool = append(c.ConstPool, badType, condy, indy)
+	vm := new(VM)
+
+	_, err1 := c.resolveLoadable(vm, badType)
+	_, err2 := c.resolveLoadable(vm, badType)
+	if err1 == nil || err1 != err2 {
+		t.Errorf("resolve bad MethodType: got %v and %v, want the same cached error", err1, err2)
+	}
+
+	// pretend the VM is resolving the dynamic constant
+	c.loadedConstants[condy].resolving = []*VM{vm}
+	if _, err := c.resolveLoadable(vm, condy); err == nil || err.(*errs.ThrowError).Class != "java/lang/StackOverflowError" {
+		t.Errorf("resolve circular dynamic constant: got %v, want StackOverflowError", err)
+	}
+
+	if _, err := c.resolveLoadable(vm, indy); err == nil {
+		t.Errorf("resolve invokedynamic constant: expect error")
+	}
+}
@@ -29,6 +29,10 @@ func (f *Field) Type() ir.Class {
 	return f.typ
 }
 
+func (f *Field) Slot() uint16 {
+	return f.Desc.Type().Slot()
+}
+
 func (f *Field) GetDeclaringClass() ir.Class {
 	return f.class
 }
@@ -0,0 +1,164 @@
+package vm
+
+import (
+	"errors"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/LiterMC/wasm-jdk/vfs"
+)
+
+// ErrBadFD is returned when the file descriptor is not open
+var ErrBadFD = errors.New("Bad file descriptor")
+
+// FileTable is the file descriptor table of a VM, which is shared by all of its threads.
+// The entries are the files opened by natives such as FileInputStream.open0,
+// and natives access them through the interfaces they implement, e.g. io.Reader or io.Writer.
+// The descriptors 0, 1 and 2 are the standard streams, see Options.Stdin.
+type FileTable struct {
+	mux   sync.RWMutex
+	files []*fileEntry
+}
+
+// fileEntry is shared by the descriptors which are duplicated by Dup
+type fileEntry struct {
+	file io.Closer
+	// refs is the number of descriptors of the file, guarded by FileTable.mux
+	refs int
+}
+
+func newFileTable(opts *Options) *FileTable {
+	var (
+		stdin  io.Reader = os.Stdin
+		stdout io.Writer = os.Stdout
+		stderr io.Writer = os.Stderr
+	)
+	if opts != nil {
+		if opts.Stdin != nil {
+			stdin = opts.Stdin
+		}
+		if opts.Stdout != nil {
+			stdout = opts.Stdout
+		}
+		if opts.Stderr != nil {
+			stderr = opts.Stderr
+		}
+	}
+	return &FileTable{
+		files: []*fileEntry{
+			{file: stdReader{stdin}, refs: 1},
+			{file: stdWriter{stdout}, refs: 1},
+			{file: stdWriter{stderr}, refs: 1},
+		},
+	}
+}
+
+// stdReader and stdWriter wrap the standard streams,
+// closing them in Java must not close the streams of the embedding program.
+type stdReader struct{ io.Reader }
+
+func (stdReader) Close() error { return nil }
+
+// Len returns the number of bytes which can be read without blocking, for FileInputStream.available
+func (r stdReader) Len() int {
+	if l, ok := r.Reader.(interface{ Len() int }); ok {
+		return l.Len()
+	}
+	return 0
+}
+
+type stdWriter struct{ io.Writer }
+
+func (stdWriter) Close() error { return nil }
+
+func (w stdWriter) Sync() error {
+	if s, ok := w.Writer.(interface{ Sync() error }); ok {
+		return s.Sync()
+	}
+	return nil
+}
+
+func newFS(opts *Options) vfs.FS {
+	if opts != nil && opts.FS != nil {
+		return opts.FS
+	}
+	return vfs.NewOSFS("")
+}
+
+// FS returns the filesystem of the VM, see Options.FS
+func (vm *VM) FS() vfs.FS {
+	return vm.fs
+}
+
+// Files returns the file descriptor table of the VM
+func (vm *VM) Files() *FileTable {
+	return vm.files
+}
+
+// Add puts the file at the lowest free descriptor, and returns the descriptor
+func (t *FileTable) Add(f io.Closer) int32 {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.add(&fileEntry{file: f, refs: 1})
+}
+
+func (t *FileTable) add(e *fileEntry) int32 {
+	for i, g := range t.files {
+		if g == nil {
+			t.files[i] = e
+			return (int32)(i)
+		}
+	}
+	t.files = append(t.files, e)
+	return (int32)(len(t.files) - 1)
+}
+
+func (t *FileTable) get(fd int32) *fileEntry {
+	if fd < 0 || (int)(fd) >= len(t.files) {
+		return nil
+	}
+	return t.files[fd]
+}
+
+// Get returns the file at the descriptor, or ErrBadFD if it is not open
+func (t *FileTable) Get(fd int32) (io.Closer, error) {
+	t.mux.RLock()
+	defer t.mux.RUnlock()
+	e := t.get(fd)
+	if e == nil {
+		return nil, ErrBadFD
+	}
+	return e.file, nil
+}
+
+// Dup puts the file at the descriptor to the lowest free descriptor like dup(2).
+// The file is closed after all of its descriptors are closed.
+func (t *FileTable) Dup(fd int32) (int32, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	e := t.get(fd)
+	if e == nil {
+		return -1, ErrBadFD
+	}
+	e.refs++
+	return t.add(e), nil
+}
+
+// Close removes the file from the table, and closes it if it has no other descriptors
+func (t *FileTable) Close(fd int32) error {
+	t.mux.Lock()
+	e := t.get(fd)
+	if e == nil {
+		t.mux.Unlock()
+		return ErrBadFD
+	}
+	t.files[fd] = nil
+	e.refs--
+	last := e.refs == 0
+	t.mux.Unlock()
+	if !last {
+		return nil
+	}
+	return e.file.Close()
+}
@@ -0,0 +1,110 @@
+package vm
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+type closeCounter struct {
+	closed int
+}
+
+func (c *closeCounter) Close() error {
+	c.closed++
+	return nil
+}
+
+func TestFileTable(t *testing.T) {
+	var stdout bytes.Buffer
+	files := newFileTable(&Options{Stdin: strings.NewReader("input"), Stdout: &stdout})
+
+	in, err := files.Get(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := in.(interface{ Len() int }).Len(); n != 5 {
+		t.Errorf("stdin has %d bytes available, want 5", n)
+	}
+	out, err := files.Get(1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	io.WriteString(out.(io.Writer), "output")
+	if err := files.Close(1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := files.Get(1); !errors.Is(err, ErrBadFD) {
+		t.Errorf("got %v after closing stdout, want ErrBadFD", err)
+	}
+	if stdout.String() != "output" {
+		t.Errorf("stdout is %q", stdout.String())
+	}
+
+	a, b := new(closeCounter), new(closeCounter)
+	if fd := files.Add(a); fd != 1 {
+		t.Errorf("got fd %d, want the lowest free fd 1", fd)
+	}
+	if fd := files.Add(b); fd != 3 {
+		t.Errorf("got fd %d, want 3", fd)
+	}
+	if err := files.Close(3); err != nil || b.closed != 1 {
+		t.Errorf("closing fd 3: %v, closed %d times", err, b.closed)
+	}
+	if err := files.Close(3); !errors.Is(err, ErrBadFD) || b.closed != 1 {
+		t.Errorf("closing fd 3 twice: %v, closed %d times", err, b.closed)
+	}
+	dup, err := files.Dup(1)
+	if err != nil || dup != 3 {
+		t.Fatalf("dup fd 1: %d %v", dup, err)
+	}
+	if err := files.Close(1); err != nil || a.closed != 0 {
+		t.Errorf("closing fd 1 with a duplicate: %v, closed %d times", err, a.closed)
+	}
+	if err := files.Close(dup); err != nil || a.closed != 1 {
+		t.Errorf("closing the duplicate: %v, closed %d times", err, a.closed)
+	}
+	if err := files.Close(-1); !errors.Is(err, ErrBadFD) {
+		t.Errorf("closing fd -1: %v", err)
+	}
+}
+
+// TestNoProcessOutput checks that running code writes nothing to the process's stdout,
+// all the output of a VM goes through Options.Stdout.
+func TestNoProcessOutput(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	fib := defineCodeMethod(t, l, "Fib", "fib", "(I)I", 1, fibCode, fibConsts)
+	cell, _, setGet := defineCells(t, l)
+	for _, tier := range []testTier{tierStep, tierInterp} {
+		if _, err := invokeTestMethod(fib, tier, 5); err != nil {
+			t.Fatalf("%v: %v", tier, err)
+		}
+	}
+	if _, err := invokeSetGet(setGet, newObjectRef(cell), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	os.Stdout = stdout
+	w.Close()
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out) != 0 {
+		t.Errorf("the process's stdout got %q", out)
+	}
+}
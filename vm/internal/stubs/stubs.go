@@ -0,0 +1,106 @@
+// Package stubs declares the stub classes of the JDK, which let the tests create VMs without the class libraries.
+// The stubs only declare the members the VM looks up, and their methods are native without implementations.
+package stubs
+
+import (
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+type stub struct {
+	super   string
+	flags   jcls.AccessFlag
+	members []string
+}
+
+// stubs are the classes which NewVM preloads, see preloadClasses of the vm package,
+// and the classes used by the helpers of vmtest
+var stubs = map[string]stub{
+	"java/lang/Object":        {"", jcls.AccPublic, []string{"<init>()V", "toString()Ljava/lang/String;"}},
+	"java/lang/String":        {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"value [B", "coder B"}},
+	"java/lang/System":        {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"initPhase1()V", "initPhase2(ZZ)I", "initPhase3()V"}},
+	"java/lang/Class":         {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"classData Ljava/lang/Object;", "classLoader Ljava/lang/ClassLoader;", "componentType Ljava/lang/Class;"}},
+	"java/lang/ClassLoader":   {"java/lang/Object", jcls.AccPublic | jcls.AccAbstract, nil},
+	"java/lang/Cloneable":     {"java/lang/Object", jcls.AccPublic | jcls.AccInterface | jcls.AccAbstract, nil},
+	"java/lang/Thread":        {"java/lang/Object", jcls.AccPublic, []string{"interrupted Z"}},
+	"java/lang/ThreadGroup":   {"java/lang/Object", jcls.AccPublic, nil},
+	"java/lang/Throwable":     {"java/lang/Object", jcls.AccPublic, []string{"backtrace Ljava/lang/Object;", "detailMessage Ljava/lang/String;"}},
+	"java/lang/ref/Finalizer": {"java/lang/Object", jcls.AccFinal, nil},
+	"java/lang/reflect/Constructor": {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{
+		"<init>(Ljava/lang/Class;[Ljava/lang/Class;[Ljava/lang/Class;IILjava/lang/String;[B[B)V",
+	}},
+	"java/lang/reflect/Field": {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{
+		"<init>(Ljava/lang/Class;Ljava/lang/String;Ljava/lang/Class;IZILjava/lang/String;[B)V",
+	}},
+	"java/lang/reflect/Method": {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{
+		"<init>(Ljava/lang/Class;Ljava/lang/String;[Ljava/lang/Class;Ljava/lang/Class;[Ljava/lang/Class;IILjava/lang/String;[B[B[B)V",
+		"clazz Ljava/lang/Class;", "modifiers I",
+	}},
+	"java/lang/invoke/MethodHandles$Lookup": {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"lookupClass Ljava/lang/Class;", "allowedModes I"}},
+	"java/lang/invoke/MethodHandle": {"java/lang/Object", jcls.AccPublic | jcls.AccAbstract, []string{
+		"type Ljava/lang/invoke/MethodType;", "form Ljava/lang/invoke/LambdaForm;",
+		"asType(Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/MethodHandle;",
+	}},
+	"java/lang/invoke/LambdaForm": {"java/lang/Object", 0, []string{"vmentry Ljava/lang/invoke/MemberName;"}},
+	"java/lang/invoke/MethodHandleNatives": {"java/lang/Object", 0, []string{
+		"linkMethod(Ljava/lang/Class;ILjava/lang/Class;Ljava/lang/String;Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/invoke/MemberName;",
+	}},
+	"java/lang/invoke/DirectMethodHandle": {"java/lang/invoke/MethodHandle", 0, []string{
+		"make(Ljava/lang/invoke/MemberName;)Ljava/lang/invoke/DirectMethodHandle;", "member Ljava/lang/invoke/MemberName;",
+	}},
+	"java/lang/invoke/CallSite":         {"java/lang/Object", jcls.AccPublic | jcls.AccAbstract, []string{"getTarget()Ljava/lang/invoke/MethodHandle;"}},
+	"java/lang/invoke/ConstantCallSite": {"java/lang/invoke/CallSite", jcls.AccPublic, nil},
+	"java/lang/invoke/MethodType":       {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"rtype Ljava/lang/Class;", "ptypes [Ljava/lang/Class;"}},
+	"java/lang/invoke/MemberName": {"java/lang/Object", jcls.AccFinal, []string{
+		"<init>(Ljava/lang/reflect/Method;Z)V", "<init>(Ljava/lang/reflect/Constructor;)V", "<init>(Ljava/lang/reflect/Field;Z)V",
+		"flags I", "method Ljava/lang/invoke/ResolvedMethodName;",
+	}},
+	"java/lang/invoke/ResolvedMethodName": {"java/lang/Object", jcls.AccFinal, nil},
+	"jdk/internal/reflect/ConstantPool":   {"java/lang/Object", jcls.AccPublic, nil},
+	"java/io/FileDescriptor":              {"java/lang/Object", jcls.AccPublic | jcls.AccFinal, []string{"fd I"}},
+}
+
+// NewClass returns the stub class of the name, or nil if there is no such stub
+func NewClass(name string) *jcls.Class {
+	s, ok := stubs[name]
+	if !ok {
+		return nil
+	}
+	return newClass(s.flags, name, s.super, s.members)
+}
+
+// Class creates a public class with the members, which are fields like "fd I" or native methods like "close0()V".
+// The class has no super class if super is empty. It panics if a member is malformed.
+func Class(name, super string, members ...string) *jcls.Class {
+	return newClass(jcls.AccPublic, name, super, members)
+}
+
+func newClass(flags jcls.AccessFlag, name, super string, members []string) *jcls.Class {
+	var (
+		fields  []*jcls.Field
+		methods []*jcls.Method
+	)
+	for _, m := range members {
+		if i := strings.IndexByte(m, '('); i >= 0 {
+			md, err := desc.ParseMethodDesc(m[i:])
+			if err != nil {
+				panic(err)
+			}
+			methods = append(methods, jcls.NewMethod(jcls.AccPublic|jcls.AccNative, m[:i], md, nil))
+			continue
+		}
+		fname, typ, _ := strings.Cut(m, " ")
+		dc, err := desc.ParseDesc(typ)
+		if err != nil {
+			panic(err)
+		}
+		fields = append(fields, jcls.NewField(jcls.AccPublic, fname, dc, nil))
+	}
+	cls := jcls.NewClass(flags, name, super, nil, fields, methods, nil)
+	if super == "" {
+		cls.SuperSym = nil
+	}
+	return cls
+}
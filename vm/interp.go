@@ -0,0 +1,536 @@
+package vm
+
+import (
+	"sync/atomic"
+
+	"github.com/LiterMC/wasm-jdk/ir"
+)
+
+// instOp is the dispatch code of a decoded instruction.
+// Instructions which are not listed here are executed through their IC.
+type instOp uint8
+
+const (
+	instGeneric instOp = iota
+	instNop
+	instConst
+	instConst64
+	instAconstNull
+	instLoad
+	instLoad64
+	instLoadRef
+	instStore
+	instStore64
+	instStoreRef
+	instIinc
+	instPop
+	instPop2
+	instDup
+	instIadd
+	instIsub
+	instImul
+	instIneg
+	instIand
+	instIor
+	instIxor
+	instIshl
+	instIshr
+	instIushr
+	instLadd
+	instLsub
+	instLmul
+	instLcmp
+	instI2l
+	instL2i
+	instI2b
+	instI2c
+	instI2s
+	instGoto
+	instIfeq
+	instIfne
+	instIflt
+	instIfge
+	instIfgt
+	instIfle
+	instIfIcmpeq
+	instIfIcmpne
+	instIfIcmplt
+	instIfIcmpge
+	instIfIcmpgt
+	instIfIcmple
+	instIfAcmpeq
+	instIfAcmpne
+	instIfnull
+	instIfnonnull
+
+	// the following instructions are executed by execQuickened, and fall back to their IC
+	instGetfield
+	instPutfield
+	instGetstatic
+	instPutstatic
+	instInvokestatic
+	instInvokespecial
+	instInvokevirtual
+	instInvokeinterface
+)
+
+// inst is a pre-decoded instruction with its operands resolved
+type inst struct {
+	op instOp
+	// a is the local variable index, the constant, the iinc increment or the constant pool index
+	a int32
+	// b is the high bits of a 64 bit constant, or the iinc local variable index
+	b int32
+	// target is the instruction index of the jump target
+	target int32
+	node   *ir.ICNode
+	// quick is the resolved member of a field access or invoke instruction
+	quick atomic.Pointer[quickInst]
+}
+
+// decodeInsts numbers the method's instructions and decodes them into an array indexed by ICNode.Index
+func decodeInsts(entry *ir.ICNode) []inst {
+	n := 0
+	for node := entry; node != nil; node = node.Next {
+		node.Index = (int32)(n)
+		n++
+	}
+	insts := make([]inst, n)
+	for node := entry; node != nil; node = node.Next {
+		decodeInst(&insts[node.Index], node)
+	}
+	return insts
+}
+
+func decodeInst(in *inst, node *ir.ICNode) {
+	in.op, in.node = instGeneric, node
+	set := func(op instOp, a int32) {
+		in.op, in.a = op, a
+	}
+	jump := func(op instOp, target *ir.ICNode) {
+		in.op, in.target = op, target.Index
+	}
+	switch ic := node.IC.(type) {
+	case *ir.ICnop:
+		in.op = instNop
+	case *ir.ICaconst_null:
+		in.op = instAconstNull
+	case *ir.ICiconst_m1:
+		set(instConst, -1)
+	case *ir.ICiconst_0:
+		set(instConst, 0)
+	case *ir.ICiconst_1:
+		set(instConst, 1)
+	case *ir.ICiconst_2:
+		set(instConst, 2)
+	case *ir.ICiconst_3:
+		set(instConst, 3)
+	case *ir.ICiconst_4:
+		set(instConst, 4)
+	case *ir.ICiconst_5:
+		set(instConst, 5)
+	case *ir.ICbipush:
+		set(instConst, (int32)(ic.Value))
+	case *ir.ICsipush:
+		set(instConst, (int32)(ic.Value))
+	case *ir.IClconst_0:
+		set(instConst64, 0)
+	case *ir.IClconst_1:
+		set(instConst64, 1)
+
+	case *ir.ICiload:
+		set(instLoad, (int32)(ic.Index))
+	case *ir.ICiload_0, *ir.ICfload_0:
+		set(instLoad, 0)
+	case *ir.ICiload_1, *ir.ICfload_1:
+		set(instLoad, 1)
+	case *ir.ICiload_2, *ir.ICfload_2:
+		set(instLoad, 2)
+	case *ir.ICiload_3, *ir.ICfload_3:
+		set(instLoad, 3)
+	case *ir.ICfload:
+		set(instLoad, (int32)(ic.Index))
+	case *ir.IClload:
+		set(instLoad64, (int32)(ic.Index))
+	case *ir.ICdload:
+		set(instLoad64, (int32)(ic.Index))
+	case *ir.IClload_0, *ir.ICdload_0:
+		set(instLoad64, 0)
+	case *ir.IClload_1, *ir.ICdload_1:
+		set(instLoad64, 1)
+	case *ir.IClload_2, *ir.ICdload_2:
+		set(instLoad64, 2)
+	case *ir.IClload_3, *ir.ICdload_3:
+		set(instLoad64, 3)
+	case *ir.ICaload:
+		set(instLoadRef, (int32)(ic.Index))
+	case *ir.ICaload_0:
+		set(instLoadRef, 0)
+	case *ir.ICaload_1:
+		set(instLoadRef, 1)
+	case *ir.ICaload_2:
+		set(instLoadRef, 2)
+	case *ir.ICaload_3:
+		set(instLoadRef, 3)
+
+	case *ir.ICistore:
+		set(instStore, (int32)(ic.Index))
+	case *ir.ICfstore:
+		set(instStore, (int32)(ic.Index))
+	case *ir.ICistore_0, *ir.ICfstore_0:
+		set(instStore, 0)
+	case *ir.ICistore_1, *ir.ICfstore_1:
+		set(instStore, 1)
+	case *ir.ICistore_2, *ir.ICfstore_2:
+		set(instStore, 2)
+	case *ir.ICistore_3, *ir.ICfstore_3:
+		set(instStore, 3)
+	case *ir.IClstore:
+		set(instStore64, (int32)(ic.Index))
+	case *ir.ICdstore:
+		set(instStore64, (int32)(ic.Index))
+	case *ir.IClstore_0, *ir.ICdstore_0:
+		set(instStore64, 0)
+	case *ir.IClstore_1, *ir.ICdstore_1:
+		set(instStore64, 1)
+	case *ir.IClstore_2, *ir.ICdstore_2:
+		set(instStore64, 2)
+	case *ir.IClstore_3, *ir.ICdstore_3:
+		set(instStore64, 3)
+	case *ir.ICastore:
+		set(instStoreRef, (int32)(ic.Index))
+	case *ir.ICastore_0:
+		set(instStoreRef, 0)
+	case *ir.ICastore_1:
+		set(instStoreRef, 1)
+	case *ir.ICastore_2:
+		set(instStoreRef, 2)
+	case *ir.ICastore_3:
+		set(instStoreRef, 3)
+	case *ir.ICiinc:
+		in.b = (int32)(ic.Index)
+		set(instIinc, (int32)(ic.Const))
+
+	case *ir.ICpop:
+		in.op = instPop
+	case *ir.ICpop2:
+		in.op = instPop2
+	case *ir.ICdup:
+		in.op = instDup
+	case *ir.ICiadd:
+		in.op = instIadd
+	case *ir.ICisub:
+		in.op = instIsub
+	case *ir.ICimul:
+		in.op = instImul
+	case *ir.ICineg:
+		in.op = instIneg
+	case *ir.ICiand:
+		in.op = instIand
+	case *ir.ICior:
+		in.op = instIor
+	case *ir.ICixor:
+		in.op = instIxor
+	case *ir.ICishl:
+		in.op = instIshl
+	case *ir.ICishr:
+		in.op = instIshr
+	case *ir.ICiushr:
+		in.op = instIushr
+	case *ir.ICladd:
+		in.op = instLadd
+	case *ir.IClsub:
+		in.op = instLsub
+	case *ir.IClmul:
+		in.op = instLmul
+	case *ir.IClcmp:
+		in.op = instLcmp
+	case *ir.ICi2l:
+		in.op = instI2l
+	case *ir.ICl2i:
+		in.op = instL2i
+	case *ir.ICi2b:
+		in.op = instI2b
+	case *ir.ICi2c:
+		in.op = instI2c
+	case *ir.ICi2s:
+		in.op = instI2s
+
+	case *ir.ICgoto:
+		jump(instGoto, ic.Node)
+	case *ir.ICgoto_w:
+		jump(instGoto, ic.Node)
+	case *ir.ICifeq:
+		jump(instIfeq, ic.Node)
+	case *ir.ICifne:
+		jump(instIfne, ic.Node)
+	case *ir.ICiflt:
+		jump(instIflt, ic.Node)
+	case *ir.ICifge:
+		jump(instIfge, ic.Node)
+	case *ir.ICifgt:
+		jump(instIfgt, ic.Node)
+	case *ir.ICifle:
+		jump(instIfle, ic.Node)
+	case *ir.ICif_icmpeq:
+		jump(instIfIcmpeq, ic.Node)
+	case *ir.ICif_icmpne:
+		jump(instIfIcmpne, ic.Node)
+	case *ir.ICif_icmplt:
+		jump(instIfIcmplt, ic.Node)
+	case *ir.ICif_icmpge:
+		jump(instIfIcmpge, ic.Node)
+	case *ir.ICif_icmpgt:
+		jump(instIfIcmpgt, ic.Node)
+	case *ir.ICif_icmple:
+		jump(instIfIcmple, ic.Node)
+	case *ir.ICif_acmpeq:
+		jump(instIfAcmpeq, ic.Node)
+	case *ir.ICif_acmpne:
+		jump(instIfAcmpne, ic.Node)
+	case *ir.ICifnull:
+		jump(instIfnull, ic.Node)
+	case *ir.ICifnonnull:
+		jump(instIfnonnull, ic.Node)
+
+	case *ir.ICgetfield:
+		set(instGetfield, (int32)(ic.Field))
+	case *ir.ICputfield:
+		set(instPutfield, (int32)(ic.Field))
+	case *ir.ICgetstatic:
+		set(instGetstatic, (int32)(ic.Field))
+	case *ir.ICputstatic:
+		set(instPutstatic, (int32)(ic.Field))
+	case *ir.ICinvokestatic:
+		set(instInvokestatic, (int32)(ic.Method))
+	case *ir.ICinvokespecial:
+		set(instInvokespecial, (int32)(ic.Method))
+	case *ir.ICinvokevirtual:
+		set(instInvokevirtual, (int32)(ic.Method))
+	case *ir.ICinvokeinterface:
+		set(instInvokeinterface, (int32)(ic.Method))
+	}
+}
+
+// run executes the thread until the stack prev is reached.
+// Natives and traced steps go through Step, while bytecode runs in runFrame.
+// The thread polls the safepoint whenever it enters or returns to a frame.
+func (vm *VM) run(prev *Stack) error {
+	vm.attachThread()
+	defer vm.detachThread()
+	for vm.stack != prev {
+		vm.pollSafepoint()
+		var err error
+		if vm.nextNative != nil || vm.tracing() {
+			err = vm.Step()
+		} else {
+			err = vm.runFrame()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// runFrame executes the current frame's bytecode until the frame changes or an error occurs.
+// Compiled blocks of a hot method run first where available.
+// The decoded instructions are dispatched in place, field accesses and invokes are quickened,
+// others are executed through IC.Execute with vm.nextPc set as Step does.
+func (vm *VM) runFrame() error {
+	s := vm.stack
+	m := s.method.(*Method)
+	code := m.decodedInsts()
+	blocks := m.hotBlocks(vm, s)
+	pc := vm.nextPc.Index
+	// next is the index after the last executed instruction, a smaller pc means a back edge is taken
+	next := pc
+	for {
+		if blocks != nil {
+			pc = m.runBlocks(vm, s, blocks, code, pc)
+		}
+		if pc < next {
+			vm.pollSafepoint()
+		}
+		in := &code[pc]
+		s.pc = in.node
+		pc++
+		next = pc
+		switch in.op {
+		case instNop:
+		case instConst:
+			s.Push((uint32)(in.a))
+		case instConst64:
+			s.Push64((uint64)(in.b)<<32 | (uint64)((uint32)(in.a)))
+		case instAconstNull:
+			s.Push(0)
+		case instLoad:
+			s.Push(s.vars[in.a])
+		case instLoad64:
+			s.Push64((uint64)(s.vars[in.a])<<32 | (uint64)(s.vars[in.a+1]))
+		case instLoadRef:
+			i := len(s.stack)
+			s.Push(0)
+			s.stackRefs[i] = s.varRefs[in.a]
+		case instStore:
+			s.SetVar((uint16)(in.a), s.Pop())
+		case instStore64:
+			s.SetVar64((uint16)(in.a), s.Pop64())
+		case instStoreRef:
+			r := s.stackRefs[len(s.stack)-1]
+			s.Pop()
+			s.SetVar((uint16)(in.a), 0)
+			s.varRefs[in.a] = r
+		case instIinc:
+			s.vars[in.b] = (uint32)((int32)(s.vars[in.b]) + in.a)
+		case instPop:
+			s.Pop()
+		case instPop2:
+			s.Pop()
+			s.Pop()
+		case instDup:
+			i := len(s.stack) - 1
+			s.stack = append(s.stack, s.stack[i])
+			s.stackRefs = append(s.stackRefs, s.stackRefs[i])
+		case instIadd:
+			b := s.PopInt32()
+			s.stack[len(s.stack)-1] = (uint32)((int32)(s.stack[len(s.stack)-1]) + b)
+		case instIsub:
+			b := s.PopInt32()
+			s.stack[len(s.stack)-1] = (uint32)((int32)(s.stack[len(s.stack)-1]) - b)
+		case instImul:
+			b := s.PopInt32()
+			s.stack[len(s.stack)-1] = (uint32)((int32)(s.stack[len(s.stack)-1]) * b)
+		case instIneg:
+			s.stack[len(s.stack)-1] = (uint32)(-(int32)(s.stack[len(s.stack)-1]))
+		case instIand:
+			b := s.Pop()
+			s.stack[len(s.stack)-1] &= b
+		case instIor:
+			b := s.Pop()
+			s.stack[len(s.stack)-1] |= b
+		case instIxor:
+			b := s.Pop()
+			s.stack[len(s.stack)-1] ^= b
+		case instIshl:
+			b := s.Pop() & 0x1f
+			s.stack[len(s.stack)-1] <<= b
+		case instIshr:
+			b := s.Pop() & 0x1f
+			s.stack[len(s.stack)-1] = (uint32)((int32)(s.stack[len(s.stack)-1]) >> b)
+		case instIushr:
+			b := s.Pop() & 0x1f
+			s.stack[len(s.stack)-1] >>= b
+		case instLadd:
+			b := s.PopInt64()
+			s.PushInt64(s.PopInt64() + b)
+		case instLsub:
+			b := s.PopInt64()
+			s.PushInt64(s.PopInt64() - b)
+		case instLmul:
+			b := s.PopInt64()
+			s.PushInt64(s.PopInt64() * b)
+		case instLcmp:
+			b := s.PopInt64()
+			a := s.PopInt64()
+			switch {
+			case a > b:
+				s.PushInt32(1)
+			case a < b:
+				s.PushInt32(-1)
+			default:
+				s.PushInt32(0)
+			}
+		case instI2l:
+			s.PushInt64((int64)(s.PopInt32()))
+		case instL2i:
+			s.PushInt32((int32)(s.PopInt64()))
+		case instI2b:
+			s.stack[len(s.stack)-1] = (uint32)((int32)((int8)(s.stack[len(s.stack)-1])))
+		case instI2c:
+			s.stack[len(s.stack)-1] = (uint32)((uint16)(s.stack[len(s.stack)-1]))
+		case instI2s:
+			s.stack[len(s.stack)-1] = (uint32)((int32)((int16)(s.stack[len(s.stack)-1])))
+		case instGoto:
+			pc = in.target
+		case instIfeq:
+			if s.PopInt32() == 0 {
+				pc = in.target
+			}
+		case instIfne:
+			if s.PopInt32() != 0 {
+				pc = in.target
+			}
+		case instIflt:
+			if s.PopInt32() < 0 {
+				pc = in.target
+			}
+		case instIfge:
+			if s.PopInt32() >= 0 {
+				pc = in.target
+			}
+		case instIfgt:
+			if s.PopInt32() > 0 {
+				pc = in.target
+			}
+		case instIfle:
+			if s.PopInt32() <= 0 {
+				pc = in.target
+			}
+		case instIfIcmpeq:
+			if b, a := s.PopInt32(), s.PopInt32(); a == b {
+				pc = in.target
+			}
+		case instIfIcmpne:
+			if b, a := s.PopInt32(), s.PopInt32(); a != b {
+				pc = in.target
+			}
+		case instIfIcmplt:
+			if b, a := s.PopInt32(), s.PopInt32(); a < b {
+				pc = in.target
+			}
+		case instIfIcmpge:
+			if b, a := s.PopInt32(), s.PopInt32(); a >= b {
+				pc = in.target
+			}
+		case instIfIcmpgt:
+			if b, a := s.PopInt32(), s.PopInt32(); a > b {
+				pc = in.target
+			}
+		case instIfIcmple:
+			if b, a := s.PopInt32(), s.PopInt32(); a <= b {
+				pc = in.target
+			}
+		case instIfAcmpeq:
+			if b, a := s.PopPointer(), s.PopPointer(); a == b {
+				pc = in.target
+			}
+		case instIfAcmpne:
+			if b, a := s.PopPointer(), s.PopPointer(); a != b {
+				pc = in.target
+			}
+		case instIfnull:
+			if s.PopPointer() == nil {
+				pc = in.target
+			}
+		case instIfnonnull:
+			if s.PopPointer() != nil {
+				pc = in.target
+			}
+		default:
+			vm.nextPc = in.node.Next
+			vm.step++
+			var err error
+			if in.op == instGeneric {
+				err = in.node.IC.Execute(vm)
+			} else {
+				err = vm.execQuickened(s, in)
+			}
+			if err != nil || vm.stack != s || vm.nextNative != nil || vm.throwing != nil {
+				return vm.afterStep(err)
+			}
+			// the instruction may jump, or an exception may be caught in the same frame
+			pc = vm.nextPc.Index
+		}
+	}
+}
@@ -0,0 +1,356 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/ir/parser"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// codeMethod creates a method which runs the bytecode
+func codeMethod(tb testing.TB, flags jcls.AccessFlag, name, typ string, maxLocals uint16, code []byte) *jcls.Method {
+	tb.Helper()
+	insts, err := parser.ParseInsts(code)
+	if err != nil {
+		tb.Fatalf("%s: cannot parse code: %v", name, err)
+	}
+	md, err := desc.ParseMethodDesc(typ)
+	if err != nil {
+		tb.Fatalf("%s: %v", name, err)
+	}
+	m := jcls.NewMethod(flags, name, md, nil)
+	m.Code = &jcls.AttrCode{MaxStack: 8, MaxLocals: maxLocals, Code: &insts[0], Insts: insts}
+	return m
+}
+
+// defineCodeClass defines the class with the given members and constant pool
+func defineCodeClass(l *testLoader, class, super string, fields []*jcls.Field, methods []*jcls.Method, consts []jcls.ConstantInfo) *Class {
+	cls := jcls.NewClass(jcls.AccPublic, class, super, nil, fields, methods, nil)
+	cls.Major = 52
+	cls.ConstPool = consts
+	c := LoadClass(cls, l)
+	l.DefineClass(c)
+	return c
+}
+
+// defineCodeMethod defines the class with a single static method which runs the bytecode
+func defineCodeMethod(tb testing.TB, l *testLoader, class, name, typ string, maxLocals uint16, code []byte, consts []jcls.ConstantInfo) *Method {
+	tb.Helper()
+	m := codeMethod(tb, jcls.AccPublic|jcls.AccStatic, name, typ, maxLocals, code)
+	c := defineCodeClass(l, class, "java/lang/Object", nil, []*jcls.Method{m}, consts)
+	return c.GetMethodByDesc(name, m.Desc()).(*Method)
+}
+
+// testTier selects how invokeTestMethod executes the bytecode
+type testTier int
+
+const (
+	tierStep testTier = iota
+	tierInterp
+	tierCompiled
+)
+
+func (t testTier) String() string {
+	return [...]string{"step", "interp", "compiled"}[t]
+}
+
+// invokeTestMethod invokes the static method with int arguments,
+// either by calling Step repeatedly, through the interpreter loop, or with the method compiled at the first invocation.
+func invokeTestMethod(m *Method, tier testTier, args ...int32) (uint64, error) {
+	vm := new(VM)
+	switch tier {
+	case tierInterp:
+		vm.opts = &Options{Verify: VerifyNone, CompileThreshold: -1}
+	case tierCompiled:
+		vm.opts = &Options{Verify: VerifyNone, CompileThreshold: 1}
+	}
+	vm.stack = &Stack{}
+	for _, a := range args {
+		vm.stack.PushInt32(a)
+	}
+	vm.InvokeStatic(m)
+	if tier == tierStep {
+		for vm.stack.prev != nil {
+			if err := vm.Step(); err != nil {
+				return 0, err
+			}
+		}
+	} else if err := vm.RunStack(); err != nil {
+		return 0, err
+	}
+	if m.Desc().Output.Type().Slot() == 2 {
+		return vm.stack.Pop64(), nil
+	}
+	return (uint64)(vm.stack.Pop()), nil
+}
+
+var (
+	// static int sum(int n) { int s = 0; for (int i = 0; i < n; i++) { s += i; } return s; }
+	sumCode = []byte{
+		0x03, 0x3c, // iconst_0; istore_1
+		0x03, 0x3d, // iconst_0; istore_2
+		0x1c, 0x1a, 0xa2, 0x00, 0x0d, // iload_2; iload_0; if_icmpge +13
+		0x1b, 0x1c, 0x60, 0x3c, // iload_1; iload_2; iadd; istore_1
+		0x84, 0x02, 0x01, // iinc 2 1
+		0xa7, 0xff, 0xf4, // goto -12
+		0x1b, 0xac, // iload_1; ireturn
+	}
+	// static int fib(int n) { return n < 2 ? n : fib(n - 1) + fib(n - 2); }
+	fibCode = []byte{
+		0x1a, 0x05, 0xa2, 0x00, 0x05, // iload_0; iconst_2; if_icmpge +5
+		0x1a, 0xac, // iload_0; ireturn
+		0x1a, 0x04, 0x64, 0xb8, 0x00, 0x01, // iload_0; iconst_1; isub; invokestatic #1
+		0x1a, 0x05, 0x64, 0xb8, 0x00, 0x01, // iload_0; iconst_2; isub; invokestatic #1
+		0x60, 0xac, // iadd; ireturn
+	}
+	fibConsts = []jcls.ConstantInfo{
+		&jcls.ConstantRef{
+			ConstTag:    jcls.TagMethodref,
+			Class:       &jcls.ConstantClass{Name: "Fib"},
+			NameAndType: &jcls.ConstantNameAndType{Name: "fib", Desc: "(I)I"},
+		},
+	}
+)
+
+func TestRunFrame(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	datas := []struct {
+		name      string
+		typ       string
+		maxLocals uint16
+		code      []byte
+		args      []int32
+		want      uint64
+	}{
+		{"sum", "(I)I", 3, sumCode, []int32{100}, 4950},
+		{"shift", "(II)I", 2, []byte{0x1a, 0x1b, 0x7a, 0xac}, []int32{-16, 34}, 0xfffffffc},                                // iload_0; iload_1; ishr; ireturn
+		{"ushift", "(II)I", 2, []byte{0x1a, 0x1b, 0x7c, 0xac}, []int32{-16, 28}, 0xf},                                      // iload_0; iload_1; iushr; ireturn
+		{"i2b", "(I)I", 1, []byte{0x1a, 0x91, 0xac}, []int32{0x1ff}, 0xffffffff},                                           // iload_0; i2b; ireturn
+		{"i2c", "(I)I", 1, []byte{0x1a, 0x92, 0xac}, []int32{-1}, 0xffff},                                                  // iload_0; i2c; ireturn
+		{"long", "(II)J", 4, []byte{0x1a, 0x85, 0x1b, 0x85, 0x69, 0x0a, 0x61, 0xad}, []int32{1 << 20, 1 << 20}, 1<<40 + 1}, // iload_0; i2l; iload_1; i2l; lmul; lconst_1; ladd; lreturn
+		{"lcmp", "(II)I", 2, []byte{0x1a, 0x85, 0x1b, 0x85, 0x94, 0xac}, []int32{1, 2}, 0xffffffff},                        // iload_0; i2l; iload_1; i2l; lcmp; ireturn
+		{"null", "()I", 1, []byte{0x01, 0x4b, 0x2a, 0xc6, 0x00, 0x05, 0x03, 0xac, 0x04, 0xac}, nil, 1},                     // aconst_null; astore_0; aload_0; ifnull +5; iconst_0; ireturn; iconst_1; ireturn
+		{"switch", "(I)I", 1, []byte{
+			0x1a, 0xaa, 0x00, 0x00, // iload_0; tableswitch
+			0x00, 0x00, 0x00, 0x1b, 0x00, 0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x02, // default +27, low 1, high 2
+			0x00, 0x00, 0x00, 0x17, 0x00, 0x00, 0x00, 0x19, // 1: +23, 2: +25
+			0x04, 0xac, 0x05, 0xac, 0x02, 0xac, // iconst_1; ireturn; iconst_2; ireturn; iconst_m1; ireturn
+		}, []int32{2}, 2},
+	}
+	for i, d := range datas {
+		m := defineCodeMethod(t, l, "T", d.name, d.typ, d.maxLocals, d.code, nil)
+		for _, tier := range []testTier{tierStep, tierInterp, tierCompiled} {
+			got, err := invokeTestMethod(m, tier, d.args...)
+			if err != nil {
+				t.Errorf("#%d %s (%v): unexpected error: %v", i, d.name, tier, err)
+			} else if got != d.want {
+				t.Errorf("#%d %s (%v): got 0x%x, want 0x%x", i, d.name, tier, got, d.want)
+			}
+		}
+	}
+
+	fib := defineCodeMethod(t, l, "Fib", "fib", "(I)I", 1, fibCode, fibConsts)
+	for _, tier := range []testTier{tierInterp, tierCompiled} {
+		if got, err := invokeTestMethod(fib, tier, 15); err != nil || got != 610 {
+			t.Errorf("fib(15) (%v): got %d, %v, want 610", tier, got, err)
+		}
+	}
+}
+
+func TestCompileBlock(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	m := defineCodeMethod(t, l, "T", "sum", "(I)I", 3, sumCode, nil)
+	if _, err := invokeTestMethod(m, tierCompiled, 10); err != nil {
+		t.Fatal(err)
+	}
+	blocks := m.blocks.Load()
+	if blocks == nil {
+		t.Fatal("method is not compiled")
+	}
+	// the blocks start at the method entry, the loop condition and the loop body
+	datas := []struct {
+		pc        int
+		depth     int
+		exitDepth int
+	}{
+		{0, 0, 0},
+		{4, 0, 0},
+		{7, 0, 0},
+	}
+	for _, d := range datas {
+		b := (*blocks)[d.pc].Load()
+		if b == nil || b.run == nil {
+			t.Errorf("block at %d is not compiled", d.pc)
+			continue
+		}
+		if b.depth != d.depth || b.exitDepth != d.exitDepth {
+			t.Errorf("block at %d: got depth %d..%d, want %d..%d", d.pc, b.depth, b.exitDepth, d.depth, d.exitDepth)
+		}
+	}
+}
+
+func TestCompileSmallStack(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	// static int g() { return 5; }
+	g := codeMethod(t, jcls.AccPublic|jcls.AccStatic, "g", "()I", 0, []byte{0x08, 0xac}) // iconst_5; ireturn
+	// static int f() { return g() + 1; }
+	f := codeMethod(t, jcls.AccPublic|jcls.AccStatic, "f", "()I", 0, []byte{0xb8, 0x00, 0x01, 0x04, 0x60, 0xac}) // invokestatic #1; iconst_1; iadd; ireturn
+	g.Code.MaxStack = 1
+	f.Code.MaxStack = 2
+	c := defineCodeClass(l, "G", "java/lang/Object", nil, []*jcls.Method{g, f}, []jcls.ConstantInfo{
+		&jcls.ConstantRef{
+			ConstTag:    jcls.TagMethodref,
+			Class:       &jcls.ConstantClass{Name: "G"},
+			NameAndType: &jcls.ConstantNameAndType{Name: "g", Desc: "()I"},
+		},
+	})
+	m := c.GetMethodByDesc("f", f.Desc()).(*Method)
+	// the block after the call starts with the returned value on the operand stack
+	for range 3 {
+		if got, err := invokeTestMethod(m, tierCompiled); err != nil || got != 6 {
+			t.Fatalf("f(): got %d, %v, want 6", got, err)
+		}
+	}
+}
+
+var (
+	cellConsts = []jcls.ConstantInfo{
+		&jcls.ConstantRef{
+			ConstTag:    jcls.TagFieldref,
+			Class:       &jcls.ConstantClass{Name: "Cell"},
+			NameAndType: &jcls.ConstantNameAndType{Name: "v", Desc: "I"},
+		},
+		&jcls.ConstantRef{
+			ConstTag:    jcls.TagMethodref,
+			Class:       &jcls.ConstantClass{Name: "Cell"},
+			NameAndType: &jcls.ConstantNameAndType{Name: "get", Desc: "()I"},
+		},
+	}
+	// static int setGet(Cell c, int v) { c.v = v; return c.get(); }
+	setGetCode = []byte{
+		0x2a, 0x1b, 0xb5, 0x00, 0x01, // aload_0; iload_1; putfield #1
+		0x2a, 0xb6, 0x00, 0x02, 0xac, // aload_0; invokevirtual #2; ireturn
+	}
+)
+
+// defineCells defines Cell with an int field v and a getter, and NegCell which overrides the getter to negate v
+func defineCells(tb testing.TB, l *testLoader) (cell, negCell *Class, setGet *Method) {
+	v, err := desc.ParseDesc("I")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	cell = defineCodeClass(l, "Cell", "java/lang/Object",
+		[]*jcls.Field{jcls.NewField(jcls.AccPublic, "v", v, nil)},
+		[]*jcls.Method{codeMethod(tb, jcls.AccPublic, "get", "()I", 1, []byte{0x2a, 0xb4, 0x00, 0x01, 0xac})}, // aload_0; getfield #1; ireturn
+		cellConsts)
+	negCell = defineCodeClass(l, "NegCell", "Cell", nil,
+		[]*jcls.Method{codeMethod(tb, jcls.AccPublic, "get", "()I", 1, []byte{0x2a, 0xb4, 0x00, 0x01, 0x74, 0xac})}, // aload_0; getfield #1; ineg; ireturn
+		cellConsts)
+	setGet = defineCodeMethod(tb, l, "T", "setGet", "(LCell;I)I", 2, setGetCode, cellConsts)
+	return
+}
+
+func invokeSetGet(m *Method, receiver *Ref, v int32) (int32, error) {
+	vm := new(VM)
+	vm.stack = &Stack{}
+	vm.stack.PushRef(receiver)
+	vm.stack.PushInt32(v)
+	vm.InvokeStatic(m)
+	if err := vm.RunStack(); err != nil {
+		return 0, err
+	}
+	return vm.stack.PopInt32(), nil
+}
+
+func TestQuickening(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	cell, negCell, setGet := defineCells(t, l)
+
+	a, b := newObjectRef(cell), newObjectRef(negCell)
+	datas := []struct {
+		receiver *Ref
+		v        int32
+		want     int32
+	}{
+		{a, 1, 1},
+		{a, 2, 2},
+		{b, 3, -3},
+		{b, 4, -4},
+		{a, 5, 5},
+		{b, 6, -6},
+	}
+	for i, d := range datas {
+		got, err := invokeSetGet(setGet, d.receiver, d.v)
+		if err != nil {
+			t.Fatalf("#%d: unexpected error: %v", i, err)
+		}
+		if got != d.want {
+			t.Errorf("#%d: got %d, want %d", i, got, d.want)
+		}
+		if v := *(*int32)(d.receiver.Data()); v != d.v {
+			t.Errorf("#%d: field v is %d, want %d", i, v, d.v)
+		}
+		q := setGet.decodedInsts()[4].quick.Load()
+		if q == nil {
+			t.Fatalf("#%d: invokevirtual is not quickened", i)
+		}
+		if c := q.cache.Load(); c == nil || c.class != d.receiver.class {
+			t.Errorf("#%d: inline cache is not updated for %s", i, d.receiver.class.Name())
+		}
+	}
+	if setGet.decodedInsts()[2].quick.Load() == nil {
+		t.Errorf("putfield is not quickened")
+	}
+}
+
+func benchmarkMethod(b *testing.B, m *Method, tier testTier, arg int32) {
+	for range b.N {
+		if _, err := invokeTestMethod(m, tier, arg); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoop(b *testing.B) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	m := defineCodeMethod(b, l, "T", "sum", "(I)I", 3, sumCode, nil)
+	b.Run("Step", func(b *testing.B) { benchmarkMethod(b, m, tierStep, 1000) })
+	b.Run("Run", func(b *testing.B) { benchmarkMethod(b, m, tierInterp, 1000) })
+	b.Run("Compiled", func(b *testing.B) { benchmarkMethod(b, m, tierCompiled, 1000) })
+}
+
+func BenchmarkInvoke(b *testing.B) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	m := defineCodeMethod(b, l, "Fib", "fib", "(I)I", 1, fibCode, fibConsts)
+	b.Run("Step", func(b *testing.B) { benchmarkMethod(b, m, tierStep, 15) })
+	b.Run("Run", func(b *testing.B) { benchmarkMethod(b, m, tierInterp, 15) })
+	b.Run("Compiled", func(b *testing.B) { benchmarkMethod(b, m, tierCompiled, 15) })
+}
+
+func BenchmarkInvokeVirtual(b *testing.B) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	cell, negCell, setGet := defineCells(b, l)
+	receivers := []*Ref{newObjectRef(cell), newObjectRef(negCell)}
+	b.Run("Monomorphic", func(b *testing.B) {
+		for range b.N {
+			if _, err := invokeSetGet(setGet, receivers[0], 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("Polymorphic", func(b *testing.B) {
+		for i := range b.N {
+			if _, err := invokeSetGet(setGet, receivers[i%2], 1); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,425 @@
+package vm
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/mutf8"
+)
+
+// linkIntrinsic links the call site in Go if the bootstrap method is a well known one.
+// It returns nil if the call site should be linked by invoking the bootstrap method in Java,
+// which is also the case for unusual bootstrap arguments, so the Java implementation can report the errors.
+func (vm *VM) linkIntrinsic(caller *Class, info *dynamicInfo) (func(*VM) error, error) {
+	handle := info.bootstrap.Method
+	if handle.Kind != jcls.RefInvokeStatic {
+		return nil, nil
+	}
+	switch handle.Ref.Class.Name + "." + handle.Ref.NameAndType.Name {
+	case "java/lang/invoke/StringConcatFactory.makeConcatWithConstants":
+		return vm.linkStringConcat(info, true)
+	case "java/lang/invoke/StringConcatFactory.makeConcat":
+		return vm.linkStringConcat(info, false)
+	case "java/lang/invoke/LambdaMetafactory.metafactory":
+		return vm.linkLambda(caller, info, false)
+	case "java/lang/invoke/LambdaMetafactory.altMetafactory":
+		return vm.linkLambda(caller, info, true)
+	}
+	return nil, nil
+}
+
+const (
+	concatTagArg   = '\x01'
+	concatTagConst = '\x02'
+
+	// maxConcatSlots is the maximum argument slots of StringConcatFactory
+	maxConcatSlots = 200
+)
+
+// concatPiece is either a constant text, or an argument of the string concatenation
+type concatPiece struct {
+	text string
+	// arg is the index of the argument, or -1 if the piece is a constant text
+	arg int
+}
+
+// parseConcatRecipe parses the recipe of StringConcatFactory.makeConcatWithConstants.
+// It reports false if the recipe does not match the constants or the argument count.
+func parseConcatRecipe(recipe string, constants []string, argc int) ([]concatPiece, bool) {
+	var (
+		pieces   []concatPiece
+		sb       strings.Builder
+		arg, cst int
+	)
+	flush := func() {
+		if sb.Len() > 0 {
+			pieces = append(pieces, concatPiece{text: sb.String(), arg: -1})
+			sb.Reset()
+		}
+	}
+	for i := 0; i < len(recipe); i++ {
+		switch recipe[i] {
+		case concatTagArg:
+			if arg >= argc {
+				return nil, false
+			}
+			flush()
+			pieces = append(pieces, concatPiece{arg: arg})
+			arg++
+		case concatTagConst:
+			if cst >= len(constants) {
+				return nil, false
+			}
+			sb.WriteString(constants[cst])
+			cst++
+		default:
+			sb.WriteByte(recipe[i])
+		}
+	}
+	flush()
+	if arg != argc || cst != len(constants) {
+		return nil, false
+	}
+	return pieces, true
+}
+
+func (vm *VM) linkStringConcat(info *dynamicInfo, withConstants bool) (func(*VM) error, error) {
+	typ := info.typ
+	if out := typ.Output; out.Type() != desc.Class || out.Class != "java/lang/String" {
+		return nil, nil
+	}
+	if typ.InputSlots() > maxConcatSlots {
+		return nil, nil
+	}
+	args := info.bootstrap.Args
+	var pieces []concatPiece
+	if withConstants {
+		if len(args) == 0 {
+			return nil, nil
+		}
+		recipe, ok := args[0].(*jcls.ConstantString)
+		if !ok {
+			return nil, nil
+		}
+		constants := make([]string, len(args)-1)
+		for i, arg := range args[1:] {
+			if constants[i], ok = constantString(arg); !ok {
+				return nil, nil
+			}
+		}
+		if pieces, ok = parseConcatRecipe(recipe.Utf8, constants, len(typ.Inputs)); !ok {
+			return nil, nil
+		}
+	} else {
+		if len(args) != 0 {
+			return nil, nil
+		}
+		pieces = make([]concatPiece, len(typ.Inputs))
+		for i := range pieces {
+			pieces[i].arg = i
+		}
+	}
+	return func(vm *VM) error {
+		return vm.concatStrings(pieces, typ.Inputs)
+	}, nil
+}
+
+// constantString returns the string form of a constant which can be inlined into a concatenation recipe
+func constantString(v jcls.ConstantInfo) (string, bool) {
+	switch v := v.(type) {
+	case *jcls.ConstantString:
+		return v.Utf8, true
+	case *jcls.ConstantInteger:
+		return strconv.Itoa((int)((int32)(v.Value))), true
+	case *jcls.ConstantLong:
+		return strconv.FormatInt((int64)(v.Value), 10), true
+	case *jcls.ConstantFloat:
+		return formatJavaFloat((float64)(math.Float32frombits(v.Value)), 32), true
+	case *jcls.ConstantDouble:
+		return formatJavaFloat(math.Float64frombits(v.Value), 64), true
+	}
+	return "", false
+}
+
+// concatStrings pops the arguments and pushes the concatenated string.
+// The chars are joined in UTF-16, so the surrogates from char arguments are kept as is.
+func (vm *VM) concatStrings(pieces []concatPiece, inputs []*desc.Desc) error {
+	args := vm.stack.popValues(inputs)
+	var (
+		units []uint16
+		err   error
+	)
+	for _, p := range pieces {
+		if p.arg < 0 {
+			units = append(units, mutf8.ToUTF16(p.text)...)
+			continue
+		}
+		if units, err = vm.appendStringOf(units, args[p.arg]); err != nil {
+			return err
+		}
+	}
+	vm.stack.PushRef(vm.newStringUTF16(units))
+	return nil
+}
+
+// appendStringOf appends the UTF-16 chars of the value converted as String.valueOf does
+func (vm *VM) appendStringOf(units []uint16, v jvalue) ([]uint16, error) {
+	var s string
+	switch v.typ {
+	case desc.Boolean:
+		if v.val != 0 {
+			s = "true"
+		} else {
+			s = "false"
+		}
+	case desc.Char:
+		return append(units, (uint16)(v.val)), nil
+	case desc.Byte, desc.Short, desc.Int:
+		s = strconv.Itoa((int)((int32)(v.val)))
+	case desc.Long:
+		s = strconv.FormatInt((int64)(v.val), 10)
+	case desc.Float:
+		s = formatJavaFloat((float64)(math.Float32frombits((uint32)(v.val))), 32)
+	case desc.Double:
+		s = formatJavaFloat(math.Float64frombits(v.val), 64)
+	default:
+		ref := v.ref
+		if ref != nil && ref.class != vm.javaLangString {
+			vm.stack.PushRef(ref)
+			if err := vm.InvokeVirtual(vm.javaLangObject_toString); err != nil {
+				return units, err
+			}
+			if err := vm.RunStack(); err != nil {
+				return units, err
+			}
+			ref, _ = vm.stack.PopRef().(*Ref)
+		}
+		if ref == nil {
+			s = "null"
+			break
+		}
+		value := *(**Ref)(vm.javaLangString_value.GetPointer(ref))
+		coder := *(*int8)(vm.javaLangString_coder.GetPointer(ref))
+		return appendJavaString(units, value, coder), nil
+	}
+	for i := 0; i < len(s); i++ {
+		// the other values are formatted in ASCII
+		units = append(units, (uint16)(s[i]))
+	}
+	return units, nil
+}
+
+// formatJavaFloat formats the float as Float.toString or Double.toString does
+func formatJavaFloat(f float64, bitSize int) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "Infinity"
+	case math.IsInf(f, -1):
+		return "-Infinity"
+	case f == 0:
+		if math.Signbit(f) {
+			return "-0.0"
+		}
+		return "0.0"
+	}
+	if abs := math.Abs(f); abs >= 1e-3 && abs < 1e7 {
+		s := strconv.FormatFloat(f, 'f', -1, bitSize)
+		if !strings.Contains(s, ".") {
+			s += ".0"
+		}
+		return s
+	}
+	s := strconv.FormatFloat(f, 'e', -1, bitSize)
+	mantissa, exp, _ := strings.Cut(s, "e")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
+
+const (
+	lambdaFlagSerializable = 1 << 0
+	lambdaFlagMarkers      = 1 << 1
+	lambdaFlagBridges      = 1 << 2
+)
+
+var lambdaCounter atomic.Int64
+
+// lambdaIntrinsic is a functional interface implementation backed by Go,
+// its instances store the captured arguments in the userData
+type lambdaIntrinsic struct {
+	class    *Class
+	captured []*desc.Desc
+	impl     *Method
+	implKind jcls.MethodKind
+	// instance is the shared instance of non-capturing lambdas
+	instance *Ref
+}
+
+func (vm *VM) linkLambda(caller *Class, info *dynamicInfo, alt bool) (func(*VM) error, error) {
+	args := info.bootstrap.Args
+	if len(args) < 3 {
+		return nil, nil
+	}
+	samType, ok1 := args[0].(*jcls.ConstantMethodType)
+	implHandle, ok2 := args[1].(*jcls.ConstantMethodHandle)
+	_, ok3 := args[2].(*jcls.ConstantMethodType)
+	if !ok1 || !ok2 || !ok3 {
+		return nil, nil
+	}
+	out := info.typ.Output
+	if out.Type() != desc.Class {
+		return nil, nil
+	}
+	interfaces := []string{out.Class}
+	methodTypes := []string{samType.Desc}
+	if alt {
+		rest := args[3:]
+		if len(rest) == 0 {
+			return nil, nil
+		}
+		flags, ok := rest[0].(*jcls.ConstantInteger)
+		if !ok {
+			return nil, nil
+		}
+		if flags.Value&lambdaFlagSerializable != 0 {
+			// serializable lambdas need writeReplace and $deserializeLambda$ support,
+			// which only the Java metafactory provides
+			return nil, nil
+		}
+		rest = rest[1:]
+		if flags.Value&lambdaFlagMarkers != 0 {
+			if len(rest) == 0 {
+				return nil, nil
+			}
+			count, ok := rest[0].(*jcls.ConstantInteger)
+			if !ok || (int)(count.Value) > len(rest)-1 {
+				return nil, nil
+			}
+			for _, c := range rest[1 : 1+count.Value] {
+				marker, ok := c.(*jcls.ConstantClass)
+				if !ok {
+					return nil, nil
+				}
+				interfaces = append(interfaces, marker.Name)
+			}
+			rest = rest[1+count.Value:]
+		}
+		if flags.Value&lambdaFlagBridges != 0 {
+			if len(rest) == 0 {
+				return nil, nil
+			}
+			count, ok := rest[0].(*jcls.ConstantInteger)
+			if !ok || (int)(count.Value) > len(rest)-1 {
+				return nil, nil
+			}
+			for _, c := range rest[1 : 1+count.Value] {
+				bridge, ok := c.(*jcls.ConstantMethodType)
+				if !ok {
+					return nil, nil
+				}
+				methodTypes = append(methodTypes, bridge.Desc)
+			}
+			rest = rest[1+count.Value:]
+		}
+		if len(rest) != 0 {
+			return nil, nil
+		}
+	}
+	for _, name := range interfaces {
+		itf, err := caller.loader.LoadClass(name)
+		if err != nil {
+			return nil, err
+		}
+		if !itf.IsInterface() {
+			return nil, nil
+		}
+	}
+
+	impl, err := caller.resolveMethodHandleMethod(implHandle)
+	if err != nil {
+		return nil, err
+	}
+	implArity := len(impl.Desc().Inputs)
+	if implHandle.Kind != jcls.RefInvokeStatic && implHandle.Kind != jcls.RefNewInvokeSpecial {
+		implArity++
+	}
+	captured := info.typ.Inputs
+	methods := make([]*jcls.Method, 0, len(methodTypes))
+	seen := make(map[string]struct{}, len(methodTypes))
+	for _, typ := range methodTypes {
+		if _, ok := seen[typ]; ok {
+			continue
+		}
+		seen[typ] = struct{}{}
+		md, err := desc.ParseMethodDesc(typ)
+		if err != nil {
+			return nil, err
+		}
+		if len(captured)+len(md.Inputs) != implArity {
+			return nil, nil
+		}
+		methods = append(methods, jcls.NewMethod(jcls.AccPublic|jcls.AccFinal|jcls.AccNative|jcls.AccSynthetic, info.info.NameAndType.Name, md, nil))
+	}
+
+	name := fmt.Sprintf("%s$$Lambda$%d", caller.Name(), lambdaCounter.Add(1))
+	cls := jcls.NewClass(jcls.AccPublic|jcls.AccFinal|jcls.AccSynthetic, name, "java/lang/Object", interfaces, nil, methods, nil)
+	l := &lambdaIntrinsic{
+		class:    LoadClass(cls, caller.loader),
+		captured: captured,
+		impl:     impl,
+		implKind: implHandle.Kind,
+	}
+	for i := range l.class.Methods {
+		vm.LoadNativeMethod(&l.class.Methods[i], l.invoke)
+	}
+	if len(captured) == 0 {
+		l.instance = vm.New(l.class).(*Ref)
+		l.instance.userData = ([]jvalue)(nil)
+	}
+	return l.new, nil
+}
+
+// new pops the captured arguments, and pushes the functional interface instance
+func (l *lambdaIntrinsic) new(vm *VM) error {
+	if l.instance != nil {
+		vm.stack.PushRef(l.instance)
+		return nil
+	}
+	ref := vm.New(l.class).(*Ref)
+	ref.userData = vm.stack.popValues(l.captured)
+	vm.stack.PushRef(ref)
+	return nil
+}
+
+// invoke is the native implementation of the functional interface method,
+// it invokes the implementation method with the captured arguments followed by the method arguments
+func (l *lambdaIntrinsic) invoke(v ir.VM) error {
+	vm := v.(*VM)
+	stack := vm.stack
+	md := stack.method.Desc()
+	frame := stack.frameArgs()
+	args := append(slices.Clone(frame[0].ref.userData.([]jvalue)), frame[1:]...)
+	result, err := vm.invokeMethodKind(l.impl, l.implKind, args)
+	if err != nil {
+		return err
+	}
+	if md.Output.Type() == desc.Void {
+		return nil
+	}
+	if result, err = vm.convertArg(result, md.Output); err != nil {
+		return err
+	}
+	result.push(stack)
+	return nil
+}
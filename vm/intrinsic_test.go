@@ -0,0 +1,388 @@
+package vm
+
+import (
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestFormatJavaFloat(t *testing.T) {
+	var datas = []struct {
+		Value   float64
+		BitSize int
+		Want    string
+	}{
+		{0, 64, "0.0"},
+		{math.Copysign(0, -1), 64, "-0.0"},
+		{math.NaN(), 64, "NaN"},
+		{math.Inf(-1), 32, "-Infinity"},
+		{1, 64, "1.0"},
+		{-12.5, 64, "-12.5"},
+		{0.001, 64, "0.001"},
+		{0.0001, 64, "1.0E-4"},
+		{1e7, 64, "1.0E7"},
+		{1.25e21, 64, "1.25E21"},
+		{(float64)((float32)(0.1)), 32, "0.1"},
+		{(float64)((float32)(3.4e38)), 32, "3.4E38"},
+	}
+	for _, d := range datas {
+		if s := formatJavaFloat(d.Value, d.BitSize); s != d.Want {
+			t.Errorf("format %v: got %q, want %q", d.Value, s, d.Want)
+		}
+	}
+}
+
+func TestParseConcatRecipe(t *testing.T) {
+	var datas = []struct {
+		Recipe    string
+		Constants []string
+		Argc      int
+		Want      []concatPiece
+	}{
+		{"", nil, 0, nil},
+		{"a=\x01", nil, 1, []concatPiece{{"a=", -1}, {"", 0}}},
+		{"\x01\x02-\x01", []string{"x"}, 2, []concatPiece{{"", 0}, {"x-", -1}, {"", 1}}},
+		{"\x01", nil, 0, nil},
+		{"\x01", nil, 2, nil},
+		{"\x02", nil, 0, nil},
+	}
+	for _, d := range datas {
+		pieces, ok := parseConcatRecipe(d.Recipe, d.Constants, d.Argc)
+		if d.Want == nil {
+			if ok && len(pieces) != 0 {
+				t.Errorf("parse %q: got %v, want failure", d.Recipe, pieces)
+			}
+			continue
+		}
+		if !ok || len(pieces) != len(d.Want) {
+			t.Errorf("parse %q: got %v, %v; want %v", d.Recipe, pieces, ok, d.Want)
+			continue
+		}
+		for i, p := range pieces {
+			if p != d.Want[i] {
+				t.Errorf("parse %q: piece %d got %v, want %v", d.Recipe, i, p, d.Want[i])
+			}
+		}
+	}
+}
+
+func TestAppendStringOfChar(t *testing.T) {
+	vm := new(VM)
+	var units []uint16
+	for _, v := range []jvalue{
+		{typ: desc.Char, val: 0xd83d},
+		{typ: desc.Char, val: 0xde00},
+		{typ: desc.Int, val: (uint64)(0xffffffff)},
+		{typ: desc.Char, val: 0xdc00},
+	} {
+		var err error
+		if units, err = vm.appendStringOf(units, v); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []uint16{0xd83d, 0xde00, '-', '1', 0xdc00}; !slices.Equal(units, want) {
+		t.Errorf("got chars %#04x, want %#04x", units, want)
+	}
+}
+
+const (
+	lookupDesc      = "Ljava/lang/invoke/MethodHandles$Lookup;Ljava/lang/String;Ljava/lang/invoke/MethodType;"
+	metafactoryDesc = "(" + lookupDesc + "Ljava/lang/invoke/MethodType;Ljava/lang/invoke/MethodHandle;Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/CallSite;"
+)
+
+var (
+	// static String concat(int n) { return "n=" + n + "!"; }
+	// static int lambda(int a, int b) { Fn f = x -> a + x; return f.apply(b); }
+	// static int serial(int a, int b) { Fn f = (Fn & Serializable)(x -> a + x); return f.apply(b); }
+	indyConcatCode = []byte{
+		0x1a,                         // iload_0
+		0xba, 0x00, 0x01, 0x00, 0x00, // invokedynamic #1
+		0xb0, // areturn
+	}
+	indyLambdaCode = []byte{
+		0x1a,                         // iload_0
+		0xba, 0x00, 0x02, 0x00, 0x00, // invokedynamic #2
+		0x1b,                         // iload_1
+		0xb9, 0x00, 0x03, 0x02, 0x00, // invokeinterface #3 2
+		0xac, // ireturn
+	}
+	indySerialCode = []byte{
+		0x1a,                         // iload_0
+		0xba, 0x00, 0x04, 0x00, 0x00, // invokedynamic #4
+		0x1b,                         // iload_1
+		0xb9, 0x00, 0x03, 0x02, 0x00, // invokeinterface #3 2
+		0xac, // ireturn
+	}
+	// private static int lambda$0(int a, int x) { return a + x; }
+	indyImplCode = []byte{0x1a, 0x1b, 0x60, 0xac}
+)
+
+// defineIndyCaller defines the class T which has a string concatenation site, a lambda site and a serializable lambda site
+func defineIndyCaller(tb testing.TB, l *testLoader) *Class {
+	samType := &jcls.ConstantMethodType{Desc: "(I)I"}
+	implHandle := testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "T", "lambda$0", "(II)I")
+	consts := []jcls.ConstantInfo{
+		&jcls.ConstantDynamics{ConstTag: jcls.TagInvokeDynamic, BootstrapMethod: 0, NameAndType: &jcls.ConstantNameAndType{Name: "makeConcatWithConstants", Desc: "(I)Ljava/lang/String;"}},
+		&jcls.ConstantDynamics{ConstTag: jcls.TagInvokeDynamic, BootstrapMethod: 1, NameAndType: &jcls.ConstantNameAndType{Name: "apply", Desc: "(I)LFn;"}},
+		&jcls.ConstantRef{ConstTag: jcls.TagInterfaceMethodref, Class: &jcls.ConstantClass{Name: "Fn"}, NameAndType: &jcls.ConstantNameAndType{Name: "apply", Desc: "(I)I"}},
+		&jcls.ConstantDynamics{ConstTag: jcls.TagInvokeDynamic, BootstrapMethod: 2, NameAndType: &jcls.ConstantNameAndType{Name: "apply", Desc: "(I)LFn;"}},
+		samType,
+		implHandle,
+	}
+	bootstraps := &jcls.AttrBootstrapMethods{Methods: []*jcls.BootstrapMethod{
+		{
+			Method: testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "java/lang/invoke/StringConcatFactory", "makeConcatWithConstants", "("+lookupDesc+"Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"),
+			Args:   []jcls.ConstantInfo{&jcls.ConstantString{Utf8: "n=\x01!"}},
+		},
+		{
+			Method: testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "java/lang/invoke/LambdaMetafactory", "metafactory", metafactoryDesc),
+			Args:   []jcls.ConstantInfo{samType, implHandle, samType},
+		},
+		{
+			Method: testMethodHandle(jcls.RefInvokeStatic, jcls.TagMethodref, "java/lang/invoke/LambdaMetafactory", "altMetafactory", "("+lookupDesc+"[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"),
+			Args:   []jcls.ConstantInfo{samType, implHandle, samType, &jcls.ConstantInteger{Value: lambdaFlagSerializable}},
+		},
+	}}
+	methods := []*jcls.Method{
+		codeMethod(tb, jcls.AccStatic, "concat", "(I)Ljava/lang/String;", 1, indyConcatCode),
+		codeMethod(tb, jcls.AccStatic, "lambda", "(II)I", 2, indyLambdaCode),
+		codeMethod(tb, jcls.AccStatic, "serial", "(II)I", 2, indySerialCode),
+		codeMethod(tb, jcls.AccPrivate|jcls.AccStatic|jcls.AccSynthetic, "lambda$0", "(II)I", 2, indyImplCode),
+	}
+	cls := jcls.NewClass(jcls.AccPublic, "T", "java/lang/Object", nil, nil, methods, []ir.Attribute{bootstraps})
+	cls.Major = 52
+	cls.ConstPool = consts
+	c := LoadClass(cls, l)
+	l.DefineClass(c)
+	return c
+}
+
+// resolveMemberName resolves the MemberName to the method as MethodHandleNatives.init does
+func resolveMemberName(vm *VM, member ir.Ref, m *Method, kind jcls.MethodKind) {
+	resolved := vm.New(vm.javaLangInvokeResolvedMethodName).(*Ref)
+	resolved.userData = &ResolvedMethodNameData{VMTarget: m, VMHolder: m.class}
+	*(*int32)(vm.javaLangInvokeMemberName_flags.GetPointer(member)) = (int32)(kind) << memberNameReferenceKindShift
+	*(**Ref)(vm.javaLangInvokeMemberName_method.GetPointer(member)) = resolved
+}
+
+// newDirectMethodHandle creates a DirectMethodHandle of the method with the type
+func newDirectMethodHandle(vm *VM, m *Method, kind jcls.MethodKind, mt *Ref) *Ref {
+	member := vm.New(vm.javaLangInvokeMemberName)
+	resolveMemberName(vm, member, m, kind)
+	mh := vm.New(vm.javaLangInvokeDirectMethodHandle)
+	*(**Ref)(vm.javaLangInvokeDirectMethodHandle_member.GetPointer(mh)) = member.(*Ref)
+	*(**Ref)(vm.javaLangInvokeMethodHandle_type.GetPointer(mh)) = mt
+	return mh.(*Ref)
+}
+
+// indyTestVM runs T on the stub classes, with the bootstrap methods implemented in Go as the Java ones.
+// The Java bootstraps link the call sites to DirectMethodHandles of the natives in Boot and Lambda.
+type indyTestVM struct {
+	*VM
+	caller *Class
+	// bootstraps counts the invocations of the Java bootstrap methods
+	bootstraps map[string]int
+}
+
+func newIndyTestVM(t *testing.T, disableIntrinsics bool) *indyTestVM {
+	l := &testLoader{classes: make(map[string]ir.Class), stubs: true}
+	vm := NewVM(&Options{Loader: l, Verify: VerifyNone, DisableBootstrapIntrinsics: disableIntrinsics})
+	v := &indyTestVM{VM: vm, bootstraps: make(map[string]int)}
+
+	// the natives which resolve the implementation method handle constants
+	vm.LoadNativeMethod(vm.javaLangReflectMethod_init, func(ir.VM) error { return nil })
+	vm.LoadNativeMethod(vm.javaLangInvokeMemberName_initMethod, func(ir.VM) error {
+		m := vm.stack.GetVarRef(1).(*Ref).userData.(*Method)
+		kind := jcls.RefInvokeVirtual
+		if m.AccessFlags.Has(jcls.AccStatic) {
+			kind = jcls.RefInvokeStatic
+		}
+		resolveMemberName(vm, vm.stack.GetVarRef(0), m, kind)
+		return nil
+	})
+	vm.LoadNativeMethod(vm.javaLangInvokeDirectMethodHandle_make, func(ir.VM) error {
+		member := vm.stack.GetVarRef(0).(*Ref)
+		m, kind, ok := vm.memberNameTarget(member)
+		if !ok {
+			t.Fatal("MemberName is not resolved")
+		}
+		mt, err := vm.NewMethodType(m.Desc().String())
+		if err != nil {
+			return err
+		}
+		vm.stack.PushRef(newDirectMethodHandle(vm, m, kind, mt.(*Ref)))
+		return nil
+	})
+	vm.LoadNativeMethod(vm.javaLangInvokeCallSite_getTarget, func(ir.VM) error {
+		vm.stack.PushRef(vm.stack.GetVarRef(0).(*Ref).userData.(*Ref))
+		return nil
+	})
+
+	integer := l.define(jcls.AccPublic|jcls.AccFinal, "java/lang/Integer", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic|jcls.AccNative, "valueOf", "(I)Ljava/lang/Integer;"),
+	)
+	vm.LoadNativeMethod(mustMethod(t, integer, "valueOf", "(I)Ljava/lang/Integer;"), func(ir.VM) error {
+		ref := vm.New(integer).(*Ref)
+		ref.userData = vm.stack.GetVarInt32(0)
+		vm.stack.PushRef(ref)
+		return nil
+	})
+	l.define(jcls.AccPublic|jcls.AccInterface|jcls.AccAbstract, "Fn", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccAbstract, "apply", "(I)I"),
+	)
+
+	// Boot.concat is the target of the Java string concatenation
+	var recipe string
+	boot := l.define(jcls.AccPublic, "Boot", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic|jcls.AccNative, "concat", "(I)Ljava/lang/String;"),
+	)
+	concat := mustMethod(t, boot, "concat", "(I)Ljava/lang/String;")
+	vm.LoadNativeMethod(concat, func(ir.VM) error {
+		s := strings.ReplaceAll(recipe, "\x01", strconv.Itoa((int)(vm.stack.GetVarInt32(0))))
+		vm.stack.PushRef(vm.NewString(s))
+		return nil
+	})
+	scf := l.define(jcls.AccPublic|jcls.AccFinal, "java/lang/invoke/StringConcatFactory", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic|jcls.AccNative|jcls.AccVarargs, "makeConcatWithConstants", "("+lookupDesc+"Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"),
+	)
+	vm.LoadNativeMethod(mustMethod(t, scf, "makeConcatWithConstants", "("+lookupDesc+"Ljava/lang/String;[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"), func(ir.VM) error {
+		v.bootstraps["makeConcatWithConstants"]++
+		recipe = vm.GetString(vm.stack.GetVarRef(3))
+		v.pushConstantCallSite(newDirectMethodHandle(vm, concat, jcls.RefInvokeStatic, vm.stack.GetVarRef(2).(*Ref)))
+		return nil
+	})
+
+	// Lambda is the functional interface implementation of the Java metafactory,
+	// it invokes the implementation method handle with the captured argument
+	var impl *Ref
+	lambda := l.define(jcls.AccFinal, "Lambda", "java/lang/Object", []string{"Fn"},
+		testMethod(jcls.AccStatic|jcls.AccNative, "make", "(I)LFn;"),
+		testMethod(jcls.AccPublic|jcls.AccNative, "apply", "(I)I"),
+	)
+	factory := mustMethod(t, lambda, "make", "(I)LFn;")
+	vm.LoadNativeMethod(factory, func(ir.VM) error {
+		ref := vm.New(lambda).(*Ref)
+		ref.userData = vm.stack.GetVarInt32(0)
+		vm.stack.PushRef(ref)
+		return nil
+	})
+	implType, err := desc.ParseMethodDesc("(II)I")
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm.LoadNativeMethod(mustMethod(t, lambda, "apply", "(I)I"), func(ir.VM) error {
+		stack := vm.stack
+		stack.PushInt32(stack.GetVarRef(0).(*Ref).userData.(int32))
+		stack.PushInt32(stack.GetVarInt32(1))
+		if err := vm.invokeMethodHandle(impl, implType); err != nil {
+			return err
+		}
+		return vm.RunStack()
+	})
+	lmf := l.define(jcls.AccPublic|jcls.AccFinal, "java/lang/invoke/LambdaMetafactory", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccStatic|jcls.AccNative, "metafactory", metafactoryDesc),
+		testMethod(jcls.AccPublic|jcls.AccStatic|jcls.AccNative|jcls.AccVarargs, "altMetafactory", "("+lookupDesc+"[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"),
+	)
+	vm.LoadNativeMethod(mustMethod(t, lmf, "metafactory", metafactoryDesc), func(ir.VM) error {
+		v.bootstraps["metafactory"]++
+		impl = vm.stack.GetVarRef(4).(*Ref)
+		v.pushConstantCallSite(newDirectMethodHandle(vm, factory, jcls.RefInvokeStatic, vm.stack.GetVarRef(2).(*Ref)))
+		return nil
+	})
+	vm.LoadNativeMethod(mustMethod(t, lmf, "altMetafactory", "("+lookupDesc+"[Ljava/lang/Object;)Ljava/lang/invoke/CallSite;"), func(ir.VM) error {
+		v.bootstraps["altMetafactory"]++
+		args := vm.stack.GetVarRef(3).GetRefArr()
+		if len(args) != 4 {
+			t.Fatalf("altMetafactory got %d arguments, want 4", len(args))
+		}
+		if flags := (*Ref)(args[3]).userData.(int32); flags != lambdaFlagSerializable {
+			t.Errorf("altMetafactory got flags %d, want FLAG_SERIALIZABLE", flags)
+		}
+		impl = (*Ref)(args[1])
+		v.pushConstantCallSite(newDirectMethodHandle(vm, factory, jcls.RefInvokeStatic, vm.stack.GetVarRef(2).(*Ref)))
+		return nil
+	})
+
+	v.caller = defineIndyCaller(t, l)
+	if err := vm.InitClass(v.caller); err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func (v *indyTestVM) pushConstantCallSite(target *Ref) {
+	site := v.New(v.javaLangInvokeConstantCallSite).(*Ref)
+	site.userData = target
+	v.stack.PushRef(site)
+}
+
+// invoke invokes the static method of T with int arguments, and returns its result
+func (v *indyTestVM) invoke(t *testing.T, name, typ string, args ...int32) jvalue {
+	t.Helper()
+	m := mustMethod(t, v.caller, name, typ)
+	for _, a := range args {
+		v.stack.PushInt32(a)
+	}
+	v.InvokeStatic(m)
+	if err := v.RunStack(); err != nil {
+		t.Fatalf("%s: %v", name, err)
+	}
+	if m.Desc().Output.Type().IsRef() {
+		return jvalue{typ: desc.Class, ref: v.stack.PopRef().(*Ref)}
+	}
+	return jvalue{typ: desc.Int, val: (uint64)(v.stack.Pop())}
+}
+
+func TestInvokeDynamicIntrinsics(t *testing.T) {
+	for _, disabled := range []bool{false, true} {
+		v := newIndyTestVM(t, disabled)
+		for i := range 2 {
+			if s := v.GetString(v.invoke(t, "concat", "(I)Ljava/lang/String;", (int32)(i)).ref); s != "n="+strconv.Itoa(i)+"!" {
+				t.Errorf("disabled=%v: concat(%d) = %q, want %q", disabled, i, s, "n="+strconv.Itoa(i)+"!")
+			}
+			if r := (int32)(v.invoke(t, "lambda", "(II)I", 3, (int32)(i)).val); r != 3+(int32)(i) {
+				t.Errorf("disabled=%v: lambda(3, %d) = %d, want %d", disabled, i, r, 3+i)
+			}
+			if r := (int32)(v.invoke(t, "serial", "(II)I", -3, (int32)(i)).val); r != -3+(int32)(i) {
+				t.Errorf("disabled=%v: serial(-3, %d) = %d, want %d", disabled, i, r, -3+i)
+			}
+		}
+
+		// the call sites are linked once, either in Go or through the Java bootstrap methods,
+		// and serializable lambdas always go to Java
+		want := map[string]int{"makeConcatWithConstants": 1, "metafactory": 1, "altMetafactory": 1}
+		if !disabled {
+			want = map[string]int{"altMetafactory": 1}
+		}
+		if !maps.Equal(v.bootstraps, want) {
+			t.Errorf("disabled=%v: Java bootstrap methods are invoked %v, want %v", disabled, v.bootstraps, want)
+		}
+		for _, d := range []struct {
+			Ind       uint16
+			Intrinsic bool
+		}{
+			{1, !disabled},
+			{2, !disabled},
+			{4, false},
+		} {
+			linkage := v.caller.loadedDynamics[d.Ind].linkage.Load()
+			if linkage == nil || linkage.err != nil {
+				t.Errorf("disabled=%v: call site #%d is not linked: %v", disabled, d.Ind, linkage)
+				continue
+			}
+			if intrinsic := linkage.intrinsic != nil; intrinsic != d.Intrinsic {
+				t.Errorf("disabled=%v: call site #%d is intrinsic: %v, want %v", disabled, d.Ind, intrinsic, d.Intrinsic)
+			}
+			if !d.Intrinsic && linkage.target == nil {
+				t.Errorf("disabled=%v: the target of ConstantCallSite #%d is not cached", disabled, d.Ind)
+			}
+		}
+	}
+}
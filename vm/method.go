@@ -3,10 +3,11 @@ package vm
 import (
 	"fmt"
 	"slices"
+	"sync"
 	"sync/atomic"
-	"unsafe"
 
 	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/jcls"
 )
@@ -17,8 +18,20 @@ type Method struct {
 	*jcls.Method
 	class  *Class
 	native NativeMethodCallback
+	// compiled is the ahead-of-time compiled code of the method
+	compiled NativeMethodCallback
+	// vtableIndex is the index in the vtable, or in the declaring interface's method table.
+	// It is -1 if the method is not virtual.
+	vtableIndex int
 
 	methodRef atomic.Pointer[Ref]
+
+	instsOnce sync.Once
+	insts     []inst
+
+	// calls counts the invocations in the interpreter until the method is hot
+	calls  atomic.Int32
+	blocks atomic.Pointer[compiledBlocks]
 }
 
 var _ ir.Method = (*Method)(nil)
@@ -31,6 +44,14 @@ func (m *Method) Location() string {
 	return m.class.Name() + "." + m.Name() + m.Desc().String()
 }
 
+// decodedInsts returns the method's decoded instructions for the interpreter loop
+func (m *Method) decodedInsts() []inst {
+	m.instsOnce.Do(func() {
+		m.insts = decodeInsts(m.Code.Code)
+	})
+	return m.insts
+}
+
 func (vm *VM) LoadNativeMethod(method ir.Method, native NativeMethodCallback) {
 	m := method.(*Method)
 	if !m.AccessFlags.Has(jcls.AccNative) {
@@ -44,21 +65,13 @@ func (vm *VM) LoadNativeMethod(method ir.Method, native NativeMethodCallback) {
 
 func (vm *VM) Invoke(method ir.Method) {
 	m := method.(*Method)
-	if vm.creator == nil {
+	if vm.tracing() {
 		fmt.Println("\n==> invoking", m.Location())
 		defer fmt.Println("   post invoke", m.Location())
 	}
 	prev := vm.stack
-	prev.pc = vm.nextPc
-	isNative := m.AccessFlags.Has(jcls.AccNative)
-	if isNative {
-		if m.native == nil {
-			panic("native method " + m.Location() + " is not loaded")
-		}
-		vm.nextNative = m.native
-	} else {
-		vm.nextPc = m.Code.Code
-	}
+	prev.nextPc = vm.nextPc
+	vm.enterMethod(m)
 	vm.stack = &Stack{
 		prev:   prev,
 		class:  m.class,
@@ -86,20 +99,13 @@ func (vm *VM) Invoke(method ir.Method) {
 
 func (vm *VM) InvokeStatic(method ir.Method) {
 	m := method.(*Method)
-	if vm.creator == nil {
+	if vm.tracing() {
 		fmt.Println("\n==> invoking static " + m.Location())
 		defer fmt.Println("   post invoke static " + m.Location())
 	}
 	prev := vm.stack
-	prev.pc = vm.nextPc
-	if m.AccessFlags.Has(jcls.AccNative) {
-		if m.native == nil {
-			panic("native method " + m.Location() + " is not loaded")
-		}
-		vm.nextNative = m.native
-	} else {
-		vm.nextPc = m.Code.Code
-	}
+	prev.nextPc = vm.nextPc
+	vm.enterMethod(m)
 	vm.stack = &Stack{
 		prev:   prev,
 		class:  m.class,
@@ -123,14 +129,14 @@ func (vm *VM) InvokeStatic(method ir.Method) {
 	}
 }
 
-func (vm *VM) InvokeVirtual(method ir.Method) {
+func (vm *VM) InvokeVirtual(method ir.Method) error {
 	m := method.(*Method)
-	if vm.creator == nil {
+	if vm.tracing() {
 		fmt.Println("\n==> invoking virtual " + m.Location())
 		defer fmt.Println("   post invoke virtual " + m.Location())
 	}
 	prev := vm.stack
-	prev.pc = vm.nextPc
+	prev.nextPc = vm.nextPc
 	newStack := &Stack{
 		prev: prev,
 	}
@@ -150,105 +156,61 @@ func (vm *VM) InvokeVirtual(method ir.Method) {
 			panic("vm: unknown MethodDesc.Input.Type")
 		}
 	}
-	this := prev.PopRef().(*Ref)
+	this0 := prev.PopRef()
+	if this0 == nil {
+		return errs.NullPointerException
+	}
+	this := this0.(*Ref)
 	newStack.SetVarRef(0, this)
-	m2 := this.class.GetMethodByDesc(method.Name(), method.Desc()).(*Method)
+	m2, err := vm.selectVirtual(this.class, m)
+	if err != nil {
+		return err
+	}
 	newStack.class = m2.class
 	newStack.method = m2
 	vm.stack = newStack
-	if m2.AccessFlags.Has(jcls.AccNative) {
-		if m2.native == nil {
-			panic("native method " + m2.Location() + " is not loaded")
-		}
-		vm.nextNative = m2.native
-	} else {
-		vm.nextPc = m2.Code.Code
+	vm.enterMethod(m2)
+	return nil
+}
+
+// selectVirtual selects the method which is invoked by invokevirtual or invokeinterface on the receiver class
+func (vm *VM) selectVirtual(receiver *Class, m *Method) (*Method, error) {
+	if receiver.arrayDim == 0 {
+		return receiver.selectMethod(m)
+	}
+	if name := m.Name(); name == "getClass" || name == "clone" {
+		return getArrayMethod(receiver, name), nil
 	}
+	return vm.javaLangObject.selectMethod(m)
 }
 
 func (vm *VM) InvokeDynamic(ind uint16) error {
 	info := vm.stack.class.loadedDynamics[ind]
-	bootMe := info.bootstrap.Method
-	if bootMe.Kind != jcls.RefInvokeStatic {
-		panic("TODO: bootstrap " + bootMe.Kind.String())
+	linkage := vm.linkCallSite(info)
+	if linkage.err != nil {
+		return linkage.err
 	}
-	bootCls, err := vm.GetClassLoader().LoadClass(bootMe.Ref.Class.Name)
-	if err != nil {
-		return err
+	if linkage.intrinsic != nil {
+		return linkage.intrinsic(vm)
 	}
-	bootCls.(*Class).InitBeforeUse(vm)
-	bootMethod0 := bootCls.GetMethodByNameAndType(bootMe.Ref.NameAndType.Name, bootMe.Ref.NameAndType.Desc)
-	if bootMethod0 == nil {
-		panic("bootstrap method " + bootMe.String() + " is nil")
-	}
-	if vm.creator == nil {
-		fmt.Println("\n==> invoking dynamic " + bootMe.String())
-		defer fmt.Println("   post invoke dynamic " + bootMe.String())
-	}
-	bootMethod := bootMethod0.(*Method)
-
-	hasVarargs := bootMethod.AccessFlags.Has(jcls.AccVarargs)
-	inputs := bootMethod.Desc().Inputs
-	inputsLen := len(inputs)
-	var (
-		args    = make([]unsafe.Pointer, inputsLen)
-		varargs []unsafe.Pointer
-	)
-
-	args[0] = vm.RefToPtr(vm.NewLookup())
-	args[1] = vm.RefToPtr(vm.GetStringInternOrNew(info.info.NameAndType.Name))
-	args[2] = vm.RefToPtr(vm.NewMethodType(info.info.NameAndType.Desc))
-	fmt.Println("info.bootstrap.Args:", info.bootstrap.Args, hasVarargs)
-	for i, arg := range info.bootstrap.Args {
-		fmt.Printf("arg: %d %#v\n", i, arg)
-		var ref ir.Ref
-		switch arg := arg.(type) {
-		case *jcls.ConstantString:
-			ref = vm.GetStringInternOrNew(arg.Utf8)
-		case *jcls.ConstantMethodHandle:
-			ref = vm.NewMethodHandle(arg)
-		case *jcls.ConstantMethodType:
-			ref = vm.NewMethodType(arg.Desc)
-		default:
-			panic(fmt.Errorf("vm.invokedynamic: unknown argument type %T", arg))
-		}
-		if hasVarargs && i+4 >= inputsLen {
-			varargs = append(varargs, vm.RefToPtr(ref))
-		} else {
-			args[i+3] = vm.RefToPtr(ref)
+	target := linkage.target
+	if target == nil {
+		var err error
+		if target, err = vm.callSiteTarget(linkage.callSite); err != nil {
+			return err
 		}
 	}
-
-	fmt.Println("invoke dynamic prepare done: ", vm.stack.GoString())
-	if hasVarargs {
-		varargsRef := vm.NewArray(inputs[inputsLen-1], (int32)(len(varargs)))
-		copy(varargsRef.GetRefArr(), varargs)
-		vm.stack.SetVarRef((uint16)(inputsLen-1), varargsRef)
-	}
-
-	prev := vm.stack
-	prev.pc = vm.nextPc
-	vm.stack = &Stack{
-		prev:   prev,
-		class:  bootCls.(*Class),
-		method: bootMethod,
-	}
-	vm.nextPc = bootMethod.Code.Code
-
-	for i, v := range args {
-		vm.stack.SetVarPointer((uint16)(i), v)
-	}
-
-	return nil
+	return vm.invokeMethodHandle(target, info.typ)
 }
 
-// RunStack steps the VM until the current stack pops
+// RunStack steps the VM until the current stack pops.
+// Throwables which are not caught before reaching the previous stack are returned as *ThrowableError
 func (vm *VM) RunStack() error {
 	prev := vm.stack.prev
-	for vm.stack != prev {
-		if err := vm.Step(); err != nil {
-			return err
-		}
-	}
-	return nil
+	boundary := vm.boundary
+	vm.boundary = prev
+	defer func() {
+		vm.boundary = boundary
+	}()
+	return vm.run(prev)
 }
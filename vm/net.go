@@ -0,0 +1,17 @@
+package vm
+
+import (
+	"github.com/LiterMC/wasm-jdk/vnet"
+)
+
+func newNetwork(opts *Options) vnet.Network {
+	if opts != nil && opts.Network != nil {
+		return opts.Network
+	}
+	return vnet.NewHostNetwork()
+}
+
+// Network returns the network of the VM, see Options.Network
+func (vm *VM) Network() vnet.Network {
+	return vm.network
+}
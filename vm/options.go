@@ -1,7 +1,11 @@
 package vm
 
 import (
+	"io"
+
 	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	"github.com/LiterMC/wasm-jdk/vnet"
 )
 
 // VM Options
@@ -10,4 +14,37 @@ type Options struct {
 	EntryClass  string
 	EntryMethod string
 	EntryArgs   []string
+
+	// DisableBootstrapIntrinsics makes invokedynamic always invoke the bootstrap methods in Java,
+	// instead of linking StringConcatFactory and LambdaMetafactory call sites in Go
+	DisableBootstrapIntrinsics bool
+	// Verify selects the classes which are checked by the bytecode verifier before initialization.
+	// The default VerifyRemote verifies all classes except the ones loaded by the boot loader.
+	// The application classes are not verified by it if they share the boot loader with the JDK, as in gova,
+	// so such a setup needs VerifyAll to check them.
+	Verify VerifyMode
+	// RejectUnverifiable makes the verified classes older than version 50 fail to link with VerifyError if they have code.
+	// They do not have StackMapTable and the type inference verifier is not implemented, so they are accepted unverified by default.
+	RejectUnverifiable bool
+	// Trace prints every invocation and executed instruction of the main thread for debugging.
+	// Traced instructions are executed one by one through Step instead of the interpreter loop.
+	Trace bool
+	// CompileThreshold is the number of invocations before a method is compiled into Go closures.
+	// Zero uses the default threshold, and a negative value disables the compiled tier.
+	CompileThreshold int
+	// DisableAOT interprets the methods which have code compiled by gova aot
+	DisableAOT bool
+
+	// Stdin, Stdout and Stderr are the standard streams of the Java program, i.e. System.in, System.out and System.err.
+	// The ones of the process are used if they are nil.
+	// Closing them in Java does not close them.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// FS is the filesystem which the Java file natives access.
+	// The host filesystem is used if it is nil, see the vfs package for the other ones.
+	FS vfs.FS
+	// Network is the transport of the Java sockets and the name resolution.
+	// The host network is used if it is nil, a wasm host or a test can supply its own one.
+	Network vnet.Network
 }
@@ -0,0 +1,287 @@
+package vm
+
+import (
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// signaturePolymorphicMethod returns the method declared by the class which is signature polymorphic, see JVMS 2.9.3
+func (c *Class) signaturePolymorphicMethod(name string) *Method {
+	if cname := c.Name(); cname != "java/lang/invoke/MethodHandle" && cname != "java/lang/invoke/VarHandle" {
+		return nil
+	}
+	var found *Method
+	for i := range c.Methods {
+		m := &c.Methods[i]
+		if m.Name() != name {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = m
+	}
+	if found == nil || !found.AccessFlags.Has(jcls.AccNative) || !found.AccessFlags.Has(jcls.AccVarargs) {
+		return nil
+	}
+	if inputs := found.Desc().Inputs; len(inputs) != 1 || inputs[0].String() != desc.DescObjectArray.String() {
+		return nil
+	}
+	return found
+}
+
+// polymorphicMethod returns a copy of the signature polymorphic method,
+// which takes the arguments and returns the result as the descriptor of the call site
+func (m *Method) polymorphicMethod(typ string) (*Method, error) {
+	md, err := desc.ParseMethodDesc(typ)
+	if err != nil {
+		return nil, err
+	}
+	pm := &Method{
+		Method:      jcls.NewMethod(m.AccessFlags&^jcls.AccVarargs, m.Name(), md, nil),
+		class:       m.class,
+		vtableIndex: -1,
+	}
+	pm.native = pm.polymorphicNative()
+	return pm, nil
+}
+
+func (m *Method) polymorphicNative() NativeMethodCallback {
+	if m.class.Name() == "java/lang/invoke/VarHandle" {
+		link := OnceApply2(func(vm ir.VM) (*polymorphicLinkage, error) {
+			return vm.(*VM).linkPolymorphicMethod(m)
+		})
+		return func(vm ir.VM) error {
+			linkage, err := link(vm)
+			if err != nil {
+				return err
+			}
+			return vm.(*VM).invokeLinkage(linkage)
+		}
+	}
+	switch m.Name() {
+	case "invokeExact":
+		return invokeExactNative
+	case "invoke":
+		return invokeNative
+	case "invokeBasic":
+		return invokeBasicNative
+	case "linkToStatic":
+		return linkToNative(jcls.RefInvokeStatic)
+	case "linkToSpecial":
+		return linkToNative(jcls.RefInvokeSpecial)
+	case "linkToVirtual":
+		return linkToNative(jcls.RefInvokeVirtual)
+	case "linkToInterface":
+		return linkToNative(jcls.RefInvokeInterface)
+	}
+	return func(ir.VM) error {
+		return errs.Throwf("java/lang/UnsupportedOperationException", "%s is not supported", m.Location())
+	}
+}
+
+// frameArgs returns the arguments of the current frame, including the receiver of instance methods
+func (s *Stack) frameArgs() []jvalue {
+	m := s.method.(*Method)
+	inputs := m.Desc().Inputs
+	args := make([]jvalue, 0, len(inputs)+1)
+	i := (uint16)(0)
+	if !m.IsStatic() {
+		args = append(args, jvalue{typ: desc.Class, ref: s.varRefs[0]})
+		i++
+	}
+	for _, in := range inputs {
+		v := jvalue{typ: in.Type()}
+		switch v.typ {
+		case desc.Class, desc.Array:
+			v.ref = s.varRefs[i]
+		case desc.Long, desc.Double:
+			v.val = s.GetVar64(i)
+		default:
+			v.val = (uint64)(s.GetVar(i))
+		}
+		i += (uint16)(v.typ.Slot())
+		args = append(args, v)
+	}
+	return args
+}
+
+// exitNative leaves the current native frame without returning a value,
+// so the native method can tail call another method with the arguments pushed to the caller's stack
+func (vm *VM) exitNative() {
+	vm.stack = vm.stack.prev
+	vm.nextPc = vm.stack.nextPc
+}
+
+// MethodHandle.invokeExact
+func invokeExactNative(v ir.VM) error {
+	vm := v.(*VM)
+	typ := vm.stack.method.Desc()
+	args := vm.stack.frameArgs()
+	mh := args[0].ref
+	if mh == nil {
+		return errs.NullPointerException
+	}
+	if !vm.methodTypeMatches(*(**Ref)(vm.javaLangInvokeMethodHandle_type.GetPointer(mh)), typ) {
+		return errs.Throwf("java/lang/invoke/WrongMethodTypeException", "handle's method type does not match %s", typ)
+	}
+	vm.exitNative()
+	for _, a := range args[1:] {
+		a.push(vm.stack)
+	}
+	return vm.invokeMethodHandle(mh, typ)
+}
+
+// MethodHandle.invoke
+func invokeNative(v ir.VM) error {
+	vm := v.(*VM)
+	typ := vm.stack.method.Desc()
+	args := vm.stack.frameArgs()
+	mh := args[0].ref
+	if mh == nil {
+		return errs.NullPointerException
+	}
+	if !vm.methodTypeMatches(*(**Ref)(vm.javaLangInvokeMethodHandle_type.GetPointer(mh)), typ) {
+		mt, err := vm.NewMethodType(typ.String())
+		if err != nil {
+			return err
+		}
+		vm.stack.PushRef(mh)
+		vm.stack.PushRef(mt)
+		if err := vm.InvokeVirtual(vm.javaLangInvokeMethodHandle_asType); err != nil {
+			return err
+		}
+		if err := vm.RunStack(); err != nil {
+			return err
+		}
+		mh = vm.stack.PopRef().(*Ref)
+	}
+	vm.exitNative()
+	for _, a := range args[1:] {
+		a.push(vm.stack)
+	}
+	return vm.invokeMethodHandle(mh, typ)
+}
+
+// MethodHandle.invokeBasic
+func invokeBasicNative(v ir.VM) error {
+	vm := v.(*VM)
+	typ := vm.stack.method.Desc()
+	args := vm.stack.frameArgs()
+	mh := args[0].ref
+	if mh == nil {
+		return errs.NullPointerException
+	}
+	vm.exitNative()
+	for _, a := range args[1:] {
+		a.push(vm.stack)
+	}
+	return vm.invokeBasic(mh, typ)
+}
+
+// MethodHandle.linkTo* invokes the method referenced by the trailing MemberName argument
+func linkToNative(kind jcls.MethodKind) NativeMethodCallback {
+	return func(v ir.VM) error {
+		vm := v.(*VM)
+		args := vm.stack.frameArgs()
+		n := len(args) - 1
+		if n < 0 {
+			return errs.Throwf("java/lang/invoke/WrongMethodTypeException", "missing MemberName argument")
+		}
+		m, _, ok := vm.memberNameTarget(args[n].ref)
+		if !ok {
+			return errs.Throwf("java/lang/InternalError", "MemberName is not resolved")
+		}
+		vm.exitNative()
+		for _, a := range args[:n] {
+			a.push(vm.stack)
+		}
+		switch kind {
+		case jcls.RefInvokeStatic:
+			if err := vm.InitClass(m.class); err != nil {
+				return err
+			}
+			vm.InvokeStatic(m)
+		case jcls.RefInvokeSpecial:
+			if n == 0 || args[0].ref == nil {
+				return errs.NullPointerException
+			}
+			vm.Invoke(m)
+		default:
+			return vm.InvokeVirtual(m)
+		}
+		return nil
+	}
+}
+
+// invokeBasic invokes the LambdaForm of the method handle with the arguments on the stack.
+// The arguments must match the method handle's type.
+func (vm *VM) invokeBasic(mh *Ref, typ *desc.MethodDesc) error {
+	form := *(**Ref)(vm.javaLangInvokeMethodHandle_form.GetPointer(mh))
+	if form == nil {
+		return errs.NullPointerException
+	}
+	entry, _, ok := vm.memberNameTarget(*(**Ref)(vm.javaLangInvokeLambdaForm_vmentry.GetPointer(form)))
+	if !ok {
+		return errs.Throwf("java/lang/InternalError", "LambdaForm of %s is not compiled", mh.class.Name())
+	}
+	vm.stack.insertRef((int)(typ.InputSlots()), mh)
+	vm.InvokeStatic(entry)
+	return nil
+}
+
+// polymorphicLinkage is the invoker method and the appendix argument
+// which are returned by MethodHandleNatives.linkMethod
+type polymorphicLinkage struct {
+	invoker  *Method
+	appendix *Ref
+}
+
+// linkPolymorphicMethod links the signature polymorphic method through MethodHandleNatives.linkMethod
+func (vm *VM) linkPolymorphicMethod(m *Method) (*polymorphicLinkage, error) {
+	if err := vm.InitClass(vm.javaLangInvokeMethodHandleNatives); err != nil {
+		return nil, err
+	}
+	mt, err := vm.NewMethodType(m.Desc().String())
+	if err != nil {
+		return nil, err
+	}
+	caller := vm.stack.prev.class
+	appendix := vm.NewArray(desc.DescObjectArray, 1)
+	stack := vm.stack
+	stack.PushRef(caller.AsRef(vm))
+	stack.PushInt32((int32)(jcls.RefInvokeVirtual))
+	stack.PushRef(m.class.AsRef(vm))
+	stack.PushRef(vm.GetStringInternOrNew(m.Name()))
+	stack.PushRef(mt)
+	stack.PushRef(appendix)
+	vm.InvokeStatic(vm.javaLangInvokeMethodHandleNatives_linkMethod)
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	member, _ := stack.PopRef().(*Ref)
+	invoker, _, ok := vm.memberNameTarget(member)
+	if !ok {
+		return nil, errs.Throwf("java/lang/LinkageError", "cannot link %s%s", m.Location(), m.Desc())
+	}
+	return &polymorphicLinkage{
+		invoker:  invoker,
+		appendix: (*Ref)(appendix.GetRefArr()[0]),
+	}, nil
+}
+
+// invokeLinkage tail calls the invoker with the arguments of the current frame followed by the appendix
+func (vm *VM) invokeLinkage(linkage *polymorphicLinkage) error {
+	args := vm.stack.frameArgs()
+	vm.exitNative()
+	for _, a := range args {
+		a.push(vm.stack)
+	}
+	if len(linkage.invoker.Desc().Inputs) > len(args) {
+		vm.stack.PushRef(linkage.appendix)
+	}
+	vm.InvokeStatic(linkage.invoker)
+	return nil
+}
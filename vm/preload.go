@@ -14,6 +14,7 @@ type preloadClasses struct {
 
 	javaLangString       *Class
 	javaLangString_value ir.Field
+	javaLangString_coder ir.Field
 
 	javaLangClass               *Class
 	javaLangClass_classData     ir.Field
@@ -51,15 +52,38 @@ type preloadClasses struct {
 	javaLangInvokeMethodHandlesLookup_lookupClass  ir.Field
 	javaLangInvokeMethodHandlesLookup_allowedModes ir.Field
 
-	javaLangInvokeDirectMethodHandle      *Class
-	javaLangInvokeDirectMethodHandle_make ir.Method
+	javaLangInvokeMethodHandle        *Class
+	javaLangInvokeMethodHandle_type   ir.Field
+	javaLangInvokeMethodHandle_form   ir.Field
+	javaLangInvokeMethodHandle_asType ir.Method
+
+	javaLangInvokeLambdaForm         *Class
+	javaLangInvokeLambdaForm_vmentry ir.Field
+
+	javaLangInvokeMethodHandleNatives            *Class
+	javaLangInvokeMethodHandleNatives_linkMethod ir.Method
+
+	javaLangInvokeDirectMethodHandle        *Class
+	javaLangInvokeDirectMethodHandle_make   ir.Method
+	javaLangInvokeDirectMethodHandle_member ir.Field
+
+	javaLangInvokeCallSite           *Class
+	javaLangInvokeCallSite_getTarget ir.Method
+
+	javaLangInvokeConstantCallSite *Class
 
 	javaLangInvokeMethodType        *Class
 	javaLangInvokeMethodType_rtype  ir.Field
 	javaLangInvokeMethodType_ptypes ir.Field
 
-	javaLangInvokeMemberName      *Class
-	javaLangInvokeMemberName_init ir.Method
+	javaLangInvokeMemberName                 *Class
+	javaLangInvokeMemberName_initMethod      ir.Method
+	javaLangInvokeMemberName_initConstructor ir.Method
+	javaLangInvokeMemberName_initField       ir.Method
+	javaLangInvokeMemberName_flags           ir.Field
+	javaLangInvokeMemberName_method          ir.Field
+
+	javaLangInvokeResolvedMethodName *Class
 
 	jdkInternalReflectConstantPool *Class
 }
@@ -75,6 +99,7 @@ func (p *preloadClasses) load(vm *VM) {
 		panic(err)
 	}
 	p.javaLangString_value = assertNotNil(p.javaLangString.GetFieldByName("value"))
+	p.javaLangString_coder = assertNotNil(p.javaLangString.GetFieldByName("coder"))
 
 	if p.javaLangSystem, err = vm.loadClass("java/lang/System"); err != nil {
 		panic(err)
@@ -140,10 +165,37 @@ func (p *preloadClasses) load(vm *VM) {
 	p.javaLangInvokeMethodHandlesLookup_lookupClass = assertNotNil(p.javaLangInvokeMethodHandlesLookup.GetFieldByName("lookupClass"))
 	p.javaLangInvokeMethodHandlesLookup_allowedModes = assertNotNil(p.javaLangInvokeMethodHandlesLookup.GetFieldByName("allowedModes"))
 
+	if p.javaLangInvokeMethodHandle, err = vm.loadClass("java/lang/invoke/MethodHandle"); err != nil {
+		panic(err)
+	}
+	p.javaLangInvokeMethodHandle_type = assertNotNil(p.javaLangInvokeMethodHandle.GetFieldByName("type"))
+	p.javaLangInvokeMethodHandle_form = assertNotNil(p.javaLangInvokeMethodHandle.GetFieldByName("form"))
+	p.javaLangInvokeMethodHandle_asType = assertNotNil(p.javaLangInvokeMethodHandle.GetMethodByNameAndType("asType", "(Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/MethodHandle;"))
+
+	if p.javaLangInvokeLambdaForm, err = vm.loadClass("java/lang/invoke/LambdaForm"); err != nil {
+		panic(err)
+	}
+	p.javaLangInvokeLambdaForm_vmentry = assertNotNil(p.javaLangInvokeLambdaForm.GetFieldByName("vmentry"))
+
+	if p.javaLangInvokeMethodHandleNatives, err = vm.loadClass("java/lang/invoke/MethodHandleNatives"); err != nil {
+		panic(err)
+	}
+	p.javaLangInvokeMethodHandleNatives_linkMethod = assertNotNil(p.javaLangInvokeMethodHandleNatives.GetMethodByNameAndType("linkMethod", "(Ljava/lang/Class;ILjava/lang/Class;Ljava/lang/String;Ljava/lang/Object;[Ljava/lang/Object;)Ljava/lang/invoke/MemberName;"))
+
 	if p.javaLangInvokeDirectMethodHandle, err = vm.loadClass("java/lang/invoke/DirectMethodHandle"); err != nil {
 		panic(err)
 	}
 	p.javaLangInvokeDirectMethodHandle_make = assertNotNil(p.javaLangInvokeDirectMethodHandle.GetMethodByNameAndType("make", "(Ljava/lang/invoke/MemberName;)Ljava/lang/invoke/DirectMethodHandle;"))
+	p.javaLangInvokeDirectMethodHandle_member = assertNotNil(p.javaLangInvokeDirectMethodHandle.GetFieldByName("member"))
+
+	if p.javaLangInvokeCallSite, err = vm.loadClass("java/lang/invoke/CallSite"); err != nil {
+		panic(err)
+	}
+	p.javaLangInvokeCallSite_getTarget = assertNotNil(p.javaLangInvokeCallSite.GetMethodByNameAndType("getTarget", "()Ljava/lang/invoke/MethodHandle;"))
+
+	if p.javaLangInvokeConstantCallSite, err = vm.loadClass("java/lang/invoke/ConstantCallSite"); err != nil {
+		panic(err)
+	}
 
 	if p.javaLangInvokeMethodType, err = vm.loadClass("java/lang/invoke/MethodType"); err != nil {
 		panic(err)
@@ -154,7 +206,15 @@ func (p *preloadClasses) load(vm *VM) {
 	if p.javaLangInvokeMemberName, err = vm.loadClass("java/lang/invoke/MemberName"); err != nil {
 		panic(err)
 	}
-	p.javaLangInvokeMemberName_init = assertNotNil(p.javaLangInvokeMemberName.GetMethodByNameAndType("<init>", "(Ljava/lang/reflect/Method;)V"))
+	p.javaLangInvokeMemberName_initMethod = assertNotNil(p.javaLangInvokeMemberName.GetMethodByNameAndType("<init>", "(Ljava/lang/reflect/Method;Z)V"))
+	p.javaLangInvokeMemberName_initConstructor = assertNotNil(p.javaLangInvokeMemberName.GetMethodByNameAndType("<init>", "(Ljava/lang/reflect/Constructor;)V"))
+	p.javaLangInvokeMemberName_initField = assertNotNil(p.javaLangInvokeMemberName.GetMethodByNameAndType("<init>", "(Ljava/lang/reflect/Field;Z)V"))
+	p.javaLangInvokeMemberName_flags = assertNotNil(p.javaLangInvokeMemberName.GetFieldByName("flags"))
+	p.javaLangInvokeMemberName_method = assertNotNil(p.javaLangInvokeMemberName.GetFieldByName("method"))
+
+	if p.javaLangInvokeResolvedMethodName, err = vm.loadClass("java/lang/invoke/ResolvedMethodName"); err != nil {
+		panic(err)
+	}
 
 	if p.jdkInternalReflectConstantPool, err = vm.loadClass("jdk/internal/reflect/ConstantPool"); err != nil {
 		panic(err)
@@ -173,6 +233,10 @@ func (p *preloadClasses) JClass_javaLangCloneable() ir.Class {
 	return p.javaLangCloneable
 }
 
+func (p *preloadClasses) JClass_javaLangInvokeResolvedMethodName() ir.Class {
+	return p.javaLangInvokeResolvedMethodName
+}
+
 func (p *preloadClasses) JClass_javaLangReflectMethod() ir.Class {
 	return p.javaLangReflectMethod
 }
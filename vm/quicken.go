@@ -0,0 +1,201 @@
+package vm
+
+import (
+	"sync/atomic"
+	"unsafe"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+)
+
+// quickInst is the resolved form of a field access or invoke instruction.
+// It is built by the first execution which passes all checks of the generic IC,
+// so the following executions can skip the constant pool lookup.
+type quickInst struct {
+	field *Field
+	// ptr is the address of a static field
+	ptr  unsafe.Pointer
+	kind fieldKind
+
+	method *Method
+	// cache is the monomorphic inline cache of invokevirtual and invokeinterface
+	cache atomic.Pointer[inlineCache]
+}
+
+type fieldKind uint8
+
+const (
+	fieldRef fieldKind = iota
+	field32
+	field64
+)
+
+// inlineCache remembers the method selected for the last receiver class
+type inlineCache struct {
+	class  *Class
+	target *Method
+}
+
+// quicken resolves the instruction's member for the current class.
+// It returns nil if the instruction cannot be quickened yet,
+// e.g. resolution failed, the declaring class is not initialized,
+// or the access requires a receiver check on every execution.
+// Errors are not reported here; the generic IC will report them.
+func (vm *VM) quicken(current *Class, in *inst) *quickInst {
+	q := new(quickInst)
+	switch in.op {
+	case instGetfield, instPutfield, instGetstatic, instPutstatic:
+		f, err := current.GetField(vm, (uint16)(in.a))
+		if err != nil {
+			return nil
+		}
+		field := f.(*Field)
+		static := in.op == instGetstatic || in.op == instPutstatic
+		if field.IsStatic() != static {
+			return nil
+		}
+		if static {
+			if field.class.ShouldInit() {
+				return nil
+			}
+			q.ptr = unsafe.Add(field.class.staticData, field.offset)
+		} else if field.IsProtected() {
+			return nil
+		}
+		q.field = field
+		switch field.Desc.Type() {
+		case desc.Class, desc.Array:
+			q.kind = fieldRef
+		case desc.Long, desc.Double:
+			q.kind = field64
+		default:
+			q.kind = field32
+		}
+	case instInvokestatic, instInvokespecial, instInvokevirtual, instInvokeinterface:
+		m, err := current.GetMethod(vm, (uint16)(in.a))
+		if err != nil {
+			return nil
+		}
+		method := m.(*Method)
+		if method.IsStatic() != (in.op == instInvokestatic) {
+			return nil
+		}
+		switch in.op {
+		case instInvokestatic:
+			if method.class.ShouldInit() {
+				return nil
+			}
+		case instInvokespecial:
+			if method.IsProtected() && !method.IsConstructor() {
+				return nil
+			}
+		case instInvokevirtual:
+			if method.IsProtected() {
+				return nil
+			}
+		}
+		q.method = method
+	default:
+		return nil
+	}
+	// Concurrent threads resolve to the same member, so whichever store wins is fine.
+	in.quick.Store(q)
+	return q
+}
+
+// execQuickened executes a field access or invoke instruction with its resolved member,
+// and falls back to the generic IC if the instruction cannot be quickened.
+func (vm *VM) execQuickened(s *Stack, in *inst) error {
+	q := in.quick.Load()
+	if q == nil {
+		if q = vm.quicken(s.class, in); q == nil {
+			return in.node.IC.Execute(vm)
+		}
+	}
+	switch in.op {
+	case instGetfield:
+		r := (*Ref)(s.PopPointer())
+		if r == nil {
+			return errs.NullPointerException
+		}
+		q.push(s, unsafe.Add(r.data, q.field.offset))
+	case instGetstatic:
+		q.push(s, q.ptr)
+	case instPutfield:
+		return q.popAndSet(s, true)
+	case instPutstatic:
+		return q.popAndSet(s, false)
+	case instInvokestatic:
+		vm.InvokeStatic(q.method)
+	case instInvokespecial:
+		vm.Invoke(q.method)
+	case instInvokevirtual, instInvokeinterface:
+		return vm.invokeVirtualCached(s, q)
+	}
+	return nil
+}
+
+func (q *quickInst) push(s *Stack, ptr unsafe.Pointer) {
+	switch q.kind {
+	case fieldRef:
+		s.PushPointer(atomic.LoadPointer((*unsafe.Pointer)(ptr)))
+	case field32:
+		s.Push(atomic.LoadUint32((*uint32)(ptr)))
+	case field64:
+		s.Push64(atomic.LoadUint64((*uint64)(ptr)))
+	}
+}
+
+func (q *quickInst) popAndSet(s *Stack, instance bool) error {
+	var (
+		v   uint64
+		ref unsafe.Pointer
+	)
+	switch q.kind {
+	case fieldRef:
+		ref = s.PopPointer()
+	case field32:
+		v = (uint64)(s.Pop())
+	case field64:
+		v = s.Pop64()
+	}
+	ptr := q.ptr
+	if instance {
+		r := (*Ref)(s.PopPointer())
+		if r == nil {
+			return errs.NullPointerException
+		}
+		ptr = unsafe.Add(r.data, q.field.offset)
+	}
+	switch q.kind {
+	case fieldRef:
+		atomic.StorePointer((*unsafe.Pointer)(ptr), ref)
+	case field32:
+		atomic.StoreUint32((*uint32)(ptr), (uint32)(v))
+	case field64:
+		atomic.StoreUint64((*uint64)(ptr), v)
+	}
+	return nil
+}
+
+// invokeVirtualCached invokes the resolved method on the receiver's class.
+// The selected method is cached for the receiver class,
+// and a cache miss selects the method again and replaces the cache.
+func (vm *VM) invokeVirtualCached(s *Stack, q *quickInst) error {
+	receiver := s.PeekRefAt(q.method.Desc().InputSlots())
+	if receiver == nil {
+		return vm.InvokeVirtual(q.method)
+	}
+	class := receiver.(*Ref).class
+	if c := q.cache.Load(); c != nil && c.class == class {
+		vm.Invoke(c.target)
+		return nil
+	}
+	target, err := vm.selectVirtual(class, q.method)
+	if err != nil {
+		return err
+	}
+	q.cache.Store(&inlineCache{class: class, target: target})
+	vm.Invoke(target)
+	return nil
+}
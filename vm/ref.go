@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math/rand"
 	"reflect"
-	"runtime"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -46,10 +45,6 @@ func newRefBase(cls *Class, data unsafe.Pointer) *Ref {
 	}
 	nch := make(chan struct{}, 0)
 	r.notifyAll.Store(&nch)
-	fmt.Printf("New Ref: %#v\n", r)
-	runtime.SetFinalizer(r, func(r *Ref) {
-		fmt.Printf("Finalizing: %p %#v\n", r, r)
-	})
 	return r
 }
 
@@ -179,7 +174,12 @@ func (r *Ref) Lock(vm ir.VM) int {
 
 func (r *Ref) Lock0(vm *VM) int {
 	if r.locked.Load() != vm {
-		r.lock.Lock()
+		if !r.lock.TryLock() {
+			// the thread may block for long, so it must not hold stop-the-world operations
+			vm.EnterSafeRegion()
+			r.lock.Lock()
+			vm.LeaveSafeRegion()
+		}
 		r.locked.Store(vm)
 	}
 	// if r.locked == vm, it is impossible to unlock concurrently
@@ -234,6 +234,8 @@ func (r *Ref) Wait0(vm *VM, dur time.Duration) error {
 		return errs.IllegalMonitorStateException
 	}
 	r.lock.Unlock()
+	vm.EnterSafeRegion()
+	defer vm.LeaveSafeRegion()
 	select {
 	case <-vm.interruptNotifier:
 		if vm.GetAndClearInterrupt() {
@@ -199,6 +199,29 @@ func (c *Class) AsRef(vm0 ir.VM) ir.Ref {
 	return ref0
 }
 
+// StaticBase returns the object whose data is the static fields of the class,
+// it is the base of static fields for Unsafe accesses
+func (c *Class) StaticBase() ir.Ref {
+	ref0 := c.staticBase.Load()
+	if ref0 == nil {
+		c.staticBase.CompareAndSwap(nil, newRefBase(c, c.staticData))
+		ref0 = c.staticBase.Load()
+	}
+	return ref0
+}
+
+// ReflectionTarget returns the VM object of a reflection object.
+// Copied reflection objects are resolved through their root.
+func ReflectionTarget(ref ir.Ref) any {
+	for {
+		if target := *ref.UserData(); target != nil {
+			return target
+		}
+		root := ref.Class().GetFieldByName("root")
+		ref = *(**Ref)(root.GetPointer(ref))
+	}
+}
+
 func (vm *VM) NewLookup() ir.Ref {
 	ref := vm.New(vm.javaLangInvokeMethodHandlesLookup)
 	lookupClassPtr := (**Ref)(vm.javaLangInvokeMethodHandlesLookup_lookupClass.GetPointer(ref))
@@ -311,57 +334,103 @@ func (m *Method) AsRef(vm0 ir.VM) ir.Ref {
 	return ref0
 }
 
-func (vm *VM) NewMethodHandle(method *jcls.ConstantMethodHandle) ir.Ref {
-	class, err := vm.GetClassByName(method.Ref.Class.Name)
-	if err != nil {
-		panic(err)
-	}
-	method0 := class.GetMethodByNameAndType(method.Ref.NameAndType.Name, method.Ref.NameAndType.Desc)
-	methodRef := method0.AsRef(vm)
+// ResolvedMethodNameData is the user data of java/lang/invoke/ResolvedMethodName
+type ResolvedMethodNameData struct {
+	VMTarget *Method
+	VMHolder *Class
+}
 
+// NewMethodHandle resolves the method handle constant in the caller class as JVMS 5.4.3.5 defined
+func (vm *VM) NewMethodHandle(caller ir.Class, handle *jcls.ConstantMethodHandle) (ir.Ref, error) {
+	c := caller.(*Class)
 	memberNameRef := vm.New(vm.javaLangInvokeMemberName)
-	vm.stack.PushRef(memberNameRef)
-	vm.stack.PushRef(methodRef)
-	vm.Invoke(vm.javaLangInvokeMemberName_init)
+	switch handle.Kind {
+	case jcls.RefGetField, jcls.RefGetStatic, jcls.RefPutField, jcls.RefPutStatic:
+		field, err := c.resolveMethodHandleField(handle)
+		if err != nil {
+			return nil, err
+		}
+		vm.stack.PushRef(memberNameRef)
+		vm.stack.PushRef(field.AsRef(vm))
+		if handle.Kind == jcls.RefPutField || handle.Kind == jcls.RefPutStatic {
+			vm.stack.PushInt32(1)
+		} else {
+			vm.stack.PushInt32(0)
+		}
+		vm.Invoke(vm.javaLangInvokeMemberName_initField)
+	default:
+		method, err := c.resolveMethodHandleMethod(handle)
+		if err != nil {
+			return nil, err
+		}
+		vm.stack.PushRef(memberNameRef)
+		vm.stack.PushRef(method.AsRef(vm))
+		if method.IsConstructor() {
+			vm.Invoke(vm.javaLangInvokeMemberName_initConstructor)
+		} else {
+			if handle.Kind == jcls.RefInvokeSpecial {
+				vm.stack.PushInt32(1)
+			} else {
+				vm.stack.PushInt32(0)
+			}
+			vm.Invoke(vm.javaLangInvokeMemberName_initMethod)
+		}
+	}
 	if err := vm.RunStack(); err != nil {
-		panic(err)
+		return nil, err
+	}
+	if handle.Kind == jcls.RefNewInvokeSpecial {
+		// constructors are resolved as invokespecial, and the handle should allocate the instance
+		flagsPtr := (*int32)(vm.javaLangInvokeMemberName_flags.GetPointer(memberNameRef))
+		*flagsPtr = *flagsPtr&^(0xf<<memberNameReferenceKindShift) | (int32)(jcls.RefNewInvokeSpecial)<<memberNameReferenceKindShift
 	}
 
 	vm.stack.PushRef(memberNameRef)
 	vm.InvokeStatic(vm.javaLangInvokeDirectMethodHandle_make)
 	if err := vm.RunStack(); err != nil {
-		panic(err)
+		return nil, err
 	}
-	ref := vm.stack.PopRef()
-
-	return ref
+	return vm.stack.PopRef(), nil
 }
 
-func (vm *VM) NewMethodType(dc string) ir.Ref {
+func (vm *VM) NewMethodType(dc string) (ir.Ref, error) {
 	md, err := desc.ParseMethodDesc(dc)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	ref := vm.New(vm.javaLangInvokeMethodType)
-	rtypePtr := (*unsafe.Pointer)(vm.javaLangInvokeMethodType_rtype.GetPointer(ref))
-	ptypesPtr := (*unsafe.Pointer)(vm.javaLangInvokeMethodType_ptypes.GetPointer(ref))
-
 	outCls, err := vm.GetClassFromDesc(md.Output)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	*rtypePtr = vm.RefToPtr(outCls.AsRef(vm))
 	ptypesRef := vm.NewArray(desc.DescClassArray, (int32)(len(md.Inputs)))
 	ptypesArr := ptypesRef.GetRefArr()
 	for i, in := range md.Inputs {
 		inCls, err := vm.GetClassFromDesc(in)
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 		ptypesArr[i] = vm.RefToPtr(inCls.AsRef(vm))
 	}
+	ref := vm.New(vm.javaLangInvokeMethodType)
+	rtypePtr := (*unsafe.Pointer)(vm.javaLangInvokeMethodType_rtype.GetPointer(ref))
+	ptypesPtr := (*unsafe.Pointer)(vm.javaLangInvokeMethodType_ptypes.GetPointer(ref))
+	*rtypePtr = vm.RefToPtr(outCls.AsRef(vm))
 	*ptypesPtr = vm.RefToPtr(ptypesRef)
-	return ref
+	return ref, nil
+}
+
+// MethodTypeDesc returns the method descriptor of the MethodType object
+func (vm *VM) MethodTypeDesc(mt ir.Ref) *desc.MethodDesc {
+	rtype := *(**Ref)(vm.javaLangInvokeMethodType_rtype.GetPointer(mt))
+	ptypes := (*(**Ref)(vm.javaLangInvokeMethodType_ptypes.GetPointer(mt))).GetRefArr()
+	md := &desc.MethodDesc{
+		Inputs: make([]*desc.Desc, len(ptypes)),
+		Output: rtype.userData.(*Class).Desc(),
+	}
+	for i, p := range ptypes {
+		md.Inputs[i] = (*Ref)(p).userData.(*Class).Desc()
+	}
+	return md
 }
 
 func (vm *VM) FillThrowableStackTrace(throwable ir.Ref) {
@@ -0,0 +1,131 @@
+package vm
+
+import (
+	"slices"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// resolveMethod resolves a method reference of the class as JVMS 5.4.3.3 defined
+func (c *Class) resolveMethod(name string, typ string) (*Method, error) {
+	if c.IsInterface() {
+		return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Found interface %s, but class was expected", c.Name())
+	}
+	if m := c.signaturePolymorphicMethod(name); m != nil {
+		return m.polymorphicMethod(typ)
+	}
+	key := name + typ
+	if m, ok := c.methodTable[key]; ok {
+		return m, nil
+	}
+	if m := selectMaximallySpecificMethod(maximallySpecificMethods(key, c.allInterfaces())); m != nil {
+		return m, nil
+	}
+	return nil, errs.Throwf("java/lang/NoSuchMethodError", "'%s'", c.Name()+"."+key)
+}
+
+// ResolveMethod resolves a method of the class or the interface, it is used by the MethodHandle natives
+func (c *Class) ResolveMethod(name string, typ string) (*Method, error) {
+	if c.IsInterface() {
+		return c.resolveInterfaceMethod(name, typ)
+	}
+	return c.resolveMethod(name, typ)
+}
+
+// resolveField resolves a field reference of the class as JVMS 5.4.3.2 defined
+func (c *Class) resolveField(name string, typ string) (*Field, error) {
+	if f := c.lookupField(name, typ); f != nil {
+		return f, nil
+	}
+	return nil, errs.Throwf("java/lang/NoSuchFieldError", "%s", name)
+}
+
+// lookupField finds the field by name and descriptor in the class, then its direct superinterfaces recursively,
+// and then its superclass recursively.
+// fieldTable is not used since it is keyed by name only, and it does not include the interface fields.
+func (c *Class) lookupField(name string, typ string) *Field {
+	for i := range c.Fields {
+		if f := &c.Fields[i]; f.Name() == name && f.Desc.String() == typ {
+			return f
+		}
+	}
+	for _, in := range c.interfaces {
+		if f := in.(*Class).lookupField(name, typ); f != nil {
+			return f
+		}
+	}
+	if super, ok := c.super.(*Class); ok {
+		return super.lookupField(name, typ)
+	}
+	return nil
+}
+
+// resolveInterfaceMethod resolves a interface method reference of the class as JVMS 5.4.3.4 defined
+func (c *Class) resolveInterfaceMethod(name string, typ string) (*Method, error) {
+	if !c.IsInterface() {
+		return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Found class %s, but interface was expected", c.Name())
+	}
+	key := name + typ
+	if m, ok := c.methodTable[key]; ok {
+		// the method is either declared in the interface, or inherited from java/lang/Object
+		if m.class == c || (m.AccessFlags.Has(jcls.AccPublic) && !m.IsStatic()) {
+			return m, nil
+		}
+	}
+	if m := selectMaximallySpecificMethod(maximallySpecificMethods(key, c.allInterfaces())); m != nil {
+		return m, nil
+	}
+	return nil, errs.Throwf("java/lang/NoSuchMethodError", "'%s'", c.Name()+"."+key)
+}
+
+// maximallySpecificMethods returns the non-private and non-static methods declared in the interfaces,
+// which are not overridden by another method declared in a subinterface.
+func maximallySpecificMethods(key string, interfaces []*Class) []*Method {
+	var candidates []*Method
+	for _, in := range interfaces {
+		if m, ok := in.methodTable[key]; ok && m.class == in && !m.AccessFlags.Has(jcls.AccPrivate|jcls.AccStatic) {
+			candidates = append(candidates, m)
+		}
+	}
+	if len(candidates) <= 1 {
+		return candidates
+	}
+	methods := make([]*Method, 0, len(candidates))
+	for _, m := range candidates {
+		overridden := slices.ContainsFunc(candidates, func(o *Method) bool {
+			return o != m && m.class.IsAssignableFrom(o.class)
+		})
+		if !overridden {
+			methods = append(methods, m)
+		}
+	}
+	return methods
+}
+
+// selectMaximallySpecificMethod returns the only non-abstract method in the maximally-specific methods.
+// Otherwise, it returns an arbitrary one or nil if there is none.
+func selectMaximallySpecificMethod(methods []*Method) *Method {
+	if m := defaultMethodOf(methods); m != nil {
+		return m
+	}
+	if len(methods) == 0 {
+		return nil
+	}
+	return methods[0]
+}
+
+// defaultMethodOf returns the only non-abstract method in the maximally-specific methods,
+// or nil if there is none or more than one.
+func defaultMethodOf(methods []*Method) *Method {
+	var found *Method
+	for _, m := range methods {
+		if !m.AccessFlags.Has(jcls.AccAbstract) {
+			if found != nil {
+				return nil
+			}
+			found = m
+		}
+	}
+	return found
+}
@@ -0,0 +1,158 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestMethodResolution(t *testing.T) {
+	l := newTestLoader()
+	object := l.define(jcls.AccPublic, "java/lang/Object", "", nil,
+		testMethod(jcls.AccPublic, "toString", "()Ljava/lang/String;"),
+		testMethod(jcls.AccProtected, "clone", "()Ljava/lang/Object;"),
+	)
+	const iflags = jcls.AccPublic | jcls.AccInterface | jcls.AccAbstract
+	i := l.define(iflags, "I", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic, "run", "()V"),
+		testMethod(jcls.AccPublic|jcls.AccAbstract, "size", "()I"),
+	)
+	j := l.define(iflags, "J", "java/lang/Object", []string{"I"},
+		testMethod(jcls.AccPublic, "run", "()V"),
+	)
+	k := l.define(iflags, "K", "java/lang/Object", []string{"J"})
+	other := l.define(iflags, "Other", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic, "run", "()V"),
+	)
+	a := l.define(jcls.AccPublic|jcls.AccAbstract, "A", "java/lang/Object", []string{"K"})
+	b := l.define(jcls.AccPublic, "B", "A", nil)
+	conflict := l.define(jcls.AccPublic, "Conflict", "java/lang/Object", []string{"I", "J", "Other"})
+
+	if !i.IsAssignableFrom(b) || !i.IsAssignableFrom(k) || !j.IsAssignableFrom(a) {
+		t.Errorf("superinterfaces should be assignable from the subclasses")
+	}
+	if other.IsAssignableFrom(b) {
+		t.Errorf("%s should not be assignable from %s", other.Name(), b.Name())
+	}
+
+	jRun := mustMethod(t, j, "run", "()V")
+	iSize := mustMethod(t, i, "size", "()I")
+
+	// default method inherited through the super class's superinterfaces
+	if m, err := b.resolveMethod("run", "()V"); err != nil || m != jRun {
+		t.Errorf("resolve B.run: got %v, %v; want %v", m, err, jRun)
+	}
+	if m, err := k.resolveInterfaceMethod("run", "()V"); err != nil || m != jRun {
+		t.Errorf("resolve K.run: got %v, %v; want %v", m, err, jRun)
+	}
+	if m, err := k.resolveInterfaceMethod("toString", "()Ljava/lang/String;"); err != nil || m.class != object {
+		t.Errorf("resolve K.toString: got %v, %v; want java/lang/Object.toString", m, err)
+	}
+	if _, err := k.resolveInterfaceMethod("clone", "()Ljava/lang/Object;"); err == nil {
+		t.Errorf("resolve K.clone: expect NoSuchMethodError")
+	}
+	if _, err := k.resolveMethod("run", "()V"); err == nil || err.(*errs.ThrowError).Class != "java/lang/IncompatibleClassChangeError" {
+		t.Errorf("resolve K.run as class method: got %v, want IncompatibleClassChangeError", err)
+	}
+	if _, err := b.resolveInterfaceMethod("run", "()V"); err == nil || err.(*errs.ThrowError).Class != "java/lang/IncompatibleClassChangeError" {
+		t.Errorf("resolve B.run as interface method: got %v, want IncompatibleClassChangeError", err)
+	}
+	if _, err := b.resolveMethod("missing", "()V"); err == nil || err.(*errs.ThrowError).Class != "java/lang/NoSuchMethodError" {
+		t.Errorf("resolve B.missing: got %v, want NoSuchMethodError", err)
+	}
+
+	if m, err := b.selectMethod(mustMethod(t, i, "run", "()V")); err != nil || m != jRun {
+		t.Errorf("select I.run on B: got %v, %v; want %v", m, err, jRun)
+	}
+	if _, err := b.selectMethod(iSize); err == nil || err.(*errs.ThrowError).Class != "java/lang/AbstractMethodError" {
+		t.Errorf("select I.size on B: got %v, want AbstractMethodError", err)
+	}
+	for _, m := range []*Method{jRun, mustMethod(t, other, "run", "()V")} {
+		if _, err := conflict.selectMethod(m); err == nil || err.(*errs.ThrowError).Class != "java/lang/IncompatibleClassChangeError" {
+			t.Errorf("select %s on Conflict: got %v, want IncompatibleClassChangeError", m.Location(), err)
+		}
+	}
+}
+
+func TestSignaturePolymorphicResolution(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	const polymorphic = jcls.AccPublic | jcls.AccFinal | jcls.AccNative | jcls.AccVarargs
+	mh := l.define(jcls.AccPublic|jcls.AccAbstract, "java/lang/invoke/MethodHandle", "java/lang/Object", nil,
+		testMethod(polymorphic, "invokeExact", "([Ljava/lang/Object;)Ljava/lang/Object;"),
+		testMethod(jcls.AccPublic, "asType", "(Ljava/lang/invoke/MethodType;)Ljava/lang/invoke/MethodHandle;"),
+	)
+	other := l.define(jcls.AccPublic, "Other", "java/lang/Object", nil,
+		testMethod(polymorphic, "invokeExact", "([Ljava/lang/Object;)Ljava/lang/Object;"),
+	)
+
+	m, err := mh.resolveMethod("invokeExact", "(ILjava/lang/String;)J")
+	if err != nil {
+		t.Fatalf("resolve MethodHandle.invokeExact: unexpected error %v", err)
+	}
+	if m.Desc().String() != "(ILjava/lang/String;)J" || m.vtableIndex != -1 || m.native == nil {
+		t.Errorf("resolve MethodHandle.invokeExact: got %s with vtable index %d, want a linked copy", m.Location(), m.vtableIndex)
+	}
+	if m2, _ := mh.resolveMethod("invokeExact", "(ILjava/lang/String;)J"); m2 == m {
+		t.Errorf("polymorphic methods should be linked per call site")
+	}
+	if _, err := mh.resolveMethod("asType", "()V"); err == nil || err.(*errs.ThrowError).Class != "java/lang/NoSuchMethodError" {
+		t.Errorf("resolve MethodHandle.asType: got %v, want NoSuchMethodError", err)
+	}
+	if _, err := other.resolveMethod("invokeExact", "(I)J"); err == nil || err.(*errs.ThrowError).Class != "java/lang/NoSuchMethodError" {
+		t.Errorf("resolve Other.invokeExact: got %v, want NoSuchMethodError", err)
+	}
+}
+
+func TestFieldResolution(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	field := func(flags jcls.AccessFlag, name, typ string) *jcls.Field {
+		dc, err := desc.ParseDesc(typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return jcls.NewField(flags, name, dc, nil)
+	}
+	define := func(flags jcls.AccessFlag, name, super string, interfaces []string, fields ...*jcls.Field) *Class {
+		c := LoadClass(jcls.NewClass(flags, name, super, interfaces, fields, nil, nil), l)
+		l.DefineClass(c)
+		return c
+	}
+	i := define(jcls.AccPublic|jcls.AccInterface|jcls.AccAbstract, "I", "java/lang/Object", nil,
+		field(jcls.AccPublic|jcls.AccStatic|jcls.AccFinal, "x", "I"),
+	)
+	a := define(jcls.AccPublic, "A", "java/lang/Object", nil,
+		field(jcls.AccPublic, "x", "I"),
+		field(jcls.AccPublic, "y", "J"),
+	)
+	b := define(jcls.AccPublic, "B", "A", []string{"I"},
+		field(jcls.AccPublic, "y", "I"),
+	)
+
+	var datas = []struct {
+		Name, Desc string
+		Class      *Class
+	}{
+		// the superinterfaces are searched before the superclass
+		{"x", "I", i},
+		{"y", "I", b},
+		// the shadowed field is still resolved by its descriptor
+		{"y", "J", a},
+		{"x", "J", nil},
+	}
+	for _, d := range datas {
+		f, err := b.resolveField(d.Name, d.Desc)
+		if d.Class == nil {
+			if err == nil || err.(*errs.ThrowError).Class != "java/lang/NoSuchFieldError" {
+				t.Errorf("resolve B.%s:%s: got %v, want NoSuchFieldError", d.Name, d.Desc, err)
+			}
+			continue
+		}
+		if err != nil || f.class != d.Class {
+			t.Errorf("resolve B.%s:%s: got %v, %v; want the field of %s", d.Name, d.Desc, f, err, d.Class.Name())
+		}
+	}
+}
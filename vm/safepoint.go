@@ -0,0 +1,224 @@
+package vm
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+)
+
+// safepoints coordinates the threads of a VM to stop at consistent points.
+// It is shared by the VM and all of its sub VMs.
+//
+// A thread is attached while it is executing in VM.run.
+// Attached threads poll pending at method entries and loop back edges,
+// and park there until the stop-the-world operation finishes.
+// Threads which block in natives, e.g. Object.wait or contended monitors,
+// enter a safe region, where they are considered parked.
+type safepoints struct {
+	// pending is set while a stop-the-world operation is waiting for the threads or running
+	pending atomic.Bool
+
+	// opMux serializes the stop-the-world operations
+	opMux sync.Mutex
+
+	mux  sync.Mutex
+	cond sync.Cond
+	// threads are the attached threads
+	threads map[*VM]struct{}
+	// running counts the attached threads which are neither parked nor in a safe region
+	running int
+}
+
+func newSafepoints() *safepoints {
+	sp := &safepoints{
+		threads: make(map[*VM]struct{}),
+	}
+	sp.cond.L = &sp.mux
+	return sp
+}
+
+// counted reports whether the thread is counted in safepoints.running
+func (vm *VM) counted() bool {
+	return vm.runDepth > 0 && vm.safeDepth == 0
+}
+
+// resume counts the thread as running, after the pending operation finishes
+func (sp *safepoints) resume() {
+	for sp.pending.Load() {
+		sp.cond.Wait()
+	}
+	sp.running++
+}
+
+// suspend stops counting the thread as running, and wakes the operation which waits for it
+func (sp *safepoints) suspend() {
+	sp.running--
+	if sp.pending.Load() {
+		sp.cond.Broadcast()
+	}
+}
+
+// attachThread is called when the thread enters VM.run
+func (vm *VM) attachThread() {
+	sp := vm.safepoints
+	if sp == nil {
+		return
+	}
+	if vm.runDepth == 0 {
+		sp.mux.Lock()
+		sp.threads[vm] = struct{}{}
+		if vm.safeDepth == 0 {
+			sp.resume()
+		}
+		sp.mux.Unlock()
+	}
+	vm.runDepth++
+}
+
+// detachThread is called when the thread leaves VM.run
+func (vm *VM) detachThread() {
+	sp := vm.safepoints
+	if sp == nil {
+		return
+	}
+	vm.runDepth--
+	if vm.runDepth == 0 {
+		sp.mux.Lock()
+		delete(sp.threads, vm)
+		if vm.safeDepth == 0 {
+			sp.suspend()
+		}
+		sp.mux.Unlock()
+	}
+}
+
+// safepointPending reports whether a stop-the-world operation is waiting for the threads
+func (vm *VM) safepointPending() bool {
+	sp := vm.safepoints
+	return sp != nil && sp.pending.Load()
+}
+
+// pollSafepoint parks the thread if a stop-the-world operation is pending.
+// It is cheap enough to be called at every method entry and loop back edge.
+func (vm *VM) pollSafepoint() {
+	if vm.safepointPending() {
+		vm.parkAtSafepoint()
+	}
+}
+
+func (vm *VM) parkAtSafepoint() {
+	if !vm.counted() {
+		return
+	}
+	sp := vm.safepoints
+	sp.mux.Lock()
+	sp.suspend()
+	sp.resume()
+	sp.mux.Unlock()
+}
+
+// Safepoint polls the safepoint for code which runs outside of the interpreter,
+// such as the loops of methods compiled by gova aot.
+func Safepoint(vm ir.VM) {
+	vm.(*VM).pollSafepoint()
+}
+
+// EnterSafeRegion marks the thread as parked until LeaveSafeRegion is called,
+// so stop-the-world operations do not wait for it.
+// Code in a safe region must not access the Java stacks or objects,
+// it should only block, e.g. in I/O or on locks.
+func (vm *VM) EnterSafeRegion() {
+	sp := vm.safepoints
+	if sp == nil {
+		return
+	}
+	if vm.counted() {
+		sp.mux.Lock()
+		sp.suspend()
+		sp.mux.Unlock()
+	}
+	vm.safeDepth++
+}
+
+// LeaveSafeRegion leaves the safe region, it waits for the pending stop-the-world operation to finish
+func (vm *VM) LeaveSafeRegion() {
+	sp := vm.safepoints
+	if sp == nil {
+		return
+	}
+	vm.safeDepth--
+	if vm.counted() {
+		sp.mux.Lock()
+		sp.resume()
+		sp.mux.Unlock()
+	}
+}
+
+// StopTheWorld parks all threads of the VM at safepoints, runs op, and then resumes them.
+// threads are all the attached threads including the current one, they must not be used after op returns.
+// It must be called on the thread of the VM, e.g. by a native, since the thread is marked as parked while it waits.
+// The goroutines which are not threads of the VM use StopTheWorldExternal instead.
+func (vm *VM) StopTheWorld(op func(threads []*VM)) {
+	// the current thread must not be waited for, and must not block other operations while waiting for its turn
+	vm.EnterSafeRegion()
+	defer vm.LeaveSafeRegion()
+	vm.StopTheWorldExternal(op)
+}
+
+// StopTheWorldExternal is StopTheWorld for the goroutines which are not threads of the VM,
+// e.g. a debugger or the embedding program. It does not change the state of the receiver,
+// which only selects the VM whose threads are stopped.
+func (vm *VM) StopTheWorldExternal(op func(threads []*VM)) {
+	sp := vm.safepoints
+	if sp == nil {
+		op(nil)
+		return
+	}
+	sp.opMux.Lock()
+	defer sp.opMux.Unlock()
+
+	sp.mux.Lock()
+	sp.pending.Store(true)
+	for sp.running > 0 {
+		sp.cond.Wait()
+	}
+	threads := make([]*VM, 0, len(sp.threads))
+	for t := range sp.threads {
+		threads = append(threads, t)
+	}
+	sp.mux.Unlock()
+
+	defer func() {
+		sp.mux.Lock()
+		sp.pending.Store(false)
+		sp.cond.Broadcast()
+		sp.mux.Unlock()
+	}()
+	op(threads)
+}
+
+// Sleep blocks the thread in a safe region for the duration.
+// It returns InterruptedException if the thread is interrupted before or while sleeping.
+func (vm *VM) Sleep(dur time.Duration) error {
+	if vm.GetAndClearInterrupt() {
+		vm.ClearInterrupt()
+		return errs.InterruptedException
+	}
+	vm.EnterSafeRegion()
+	defer vm.LeaveSafeRegion()
+	timer := time.NewTimer(dur)
+	defer timer.Stop()
+	for {
+		select {
+		case <-vm.interruptNotifier:
+			if vm.GetAndClearInterrupt() {
+				return errs.InterruptedException
+			}
+		case <-timer.C:
+			return nil
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package vm
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestStopTheWorld(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	m := defineCodeMethod(t, l, "T", "sum", "(I)I", 3, sumCode, nil)
+
+	sp := newSafepoints()
+	done := make(chan error, 2)
+	for _, threshold := range []int{-1, 1} {
+		vm := &VM{
+			opts:       &Options{Verify: VerifyNone, CompileThreshold: threshold},
+			safepoints: sp,
+			stack:      &Stack{},
+		}
+		vm.stack.PushInt32(math.MaxInt32)
+		vm.InvokeStatic(m)
+		go func() {
+			done <- vm.RunStack()
+		}()
+	}
+	// a thread blocking in a safe region does not hold the operation
+	blocked := &VM{safepoints: sp}
+	blocked.attachThread()
+	blocked.EnterSafeRegion()
+
+	for {
+		sp.mux.Lock()
+		n := len(sp.threads)
+		sp.mux.Unlock()
+		if n == 3 {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// the test goroutine is not a thread of the VM
+	parked := 0
+	blocked.StopTheWorldExternal(func(threads []*VM) {
+		if len(threads) != 3 {
+			t.Errorf("got %d threads, want 3", len(threads))
+		}
+		for _, thread := range threads {
+			if thread == blocked {
+				continue
+			}
+			s := thread.stack
+			if s.method != m {
+				t.Errorf("thread is parked in %v, want %v", s.method, m)
+				continue
+			}
+			parked++
+			i := s.GetVarInt32(2)
+			time.Sleep(time.Millisecond * 10)
+			if s.GetVarInt32(2) != i {
+				t.Errorf("thread is running while the world is stopped")
+			}
+			// end the loop
+			s.SetVarInt32(2, math.MaxInt32)
+		}
+	})
+	if parked != 2 {
+		t.Errorf("%d threads are parked, want 2", parked)
+	}
+	for range 2 {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Error(err)
+			}
+		case <-time.After(time.Second * 10):
+			t.Fatal("threads are not resumed")
+		}
+	}
+
+	blocked.LeaveSafeRegion()
+	// a thread of the VM does not wait for itself
+	blocked.StopTheWorld(func(threads []*VM) {
+		if len(threads) != 1 || threads[0] != blocked {
+			t.Errorf("got threads %v, want only the current thread", threads)
+		}
+		if sp.running != 0 {
+			t.Errorf("%d threads are running while the world is stopped", sp.running)
+		}
+	})
+	if sp.running != 1 {
+		t.Errorf("%d threads are running after the current thread stopped the world, want 1", sp.running)
+	}
+	blocked.detachThread()
+	if sp.running != 0 || len(sp.threads) != 0 {
+		t.Errorf("%d threads are running and %d are attached after all threads exit", sp.running, len(sp.threads))
+	}
+}
@@ -3,9 +3,11 @@ package vm
 import (
 	"fmt"
 	"math"
+	"slices"
 	"strings"
 	"unsafe"
 
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/jcls"
 )
@@ -15,6 +17,7 @@ type Stack struct {
 	class     *Class
 	method    ir.Method
 	pc        *ir.ICNode
+	nextPc    *ir.ICNode
 	vars      []uint32
 	varRefs   []*Ref
 	stack     []uint32
@@ -180,6 +183,18 @@ func (s *Stack) PeekRef() ir.Ref {
 	return v
 }
 
+func (s *Stack) PeekRefAt(n uint16) ir.Ref {
+	i := len(s.stack) - 1 - (int)(n)
+	if i >= len(s.stackRefs) {
+		return nil
+	}
+	v := s.stackRefs[i]
+	if v == nil {
+		return nil
+	}
+	return v
+}
+
 func (s *Stack) PeekPointer() unsafe.Pointer {
 	return (unsafe.Pointer)(s.stackRefs[len(s.stack)-1])
 }
@@ -302,6 +317,48 @@ func (s *Stack) IsRef() bool {
 	return len(s.stackRefs) > i && s.stackRefs[i] != nil
 }
 
+// insertRef inserts the reference under the top n slots of the operand stack
+func (s *Stack) insertRef(n int, r *Ref) {
+	i := len(s.stack) - n
+	s.stack = slices.Insert(s.stack, i, 0)
+	s.stackRefs = slices.Insert(s.stackRefs, i, r)
+}
+
+// findExceptionHandler returns the handler in the exception table
+// which covers the current pc and catches the throwable, or nil if there is none.
+// If a catch type cannot be loaded, the throwable is replaced by NoClassDefFoundError
+// and the search goes on with the rest of the table, so the returned throwable is the one to be thrown.
+func (s *Stack) findExceptionHandler(vm *VM, r *Ref) (*ir.ICNode, *Ref) {
+	m, ok := s.method.(*Method)
+	if !ok || s.pc == nil || m.AccessFlags.Has(jcls.AccNative) {
+		return nil, r
+	}
+	offset := s.pc.Offset
+	for _, e := range m.Code.Exceptions {
+		if offset < (int32)(e.Start) || offset >= (int32)(e.End) {
+			continue
+		}
+		catchType, err := s.class.loader.LoadClass(e.Class)
+		if err != nil {
+			if te, ok := vm.toThrowableError(errs.Throw("java/lang/NoClassDefFoundError", e.Class)).(*ThrowableError); ok {
+				r = te.Throwable
+			}
+			continue
+		}
+		if catchType.IsAssignableFrom(r.class) {
+			return e.Node, r
+		}
+	}
+	return nil, r
+}
+
+// clearStack drops all values on the operand stack
+func (s *Stack) clearStack() {
+	clear(s.stackRefs)
+	s.stack = s.stack[:0]
+	s.stackRefs = s.stackRefs[:0]
+}
+
 func (s *Stack) GoString() string {
 	var sb strings.Builder
 	sb.WriteString("Stack {\n")
@@ -39,3 +39,19 @@ func OnceApply[T1, T2 any](f func(T1) T2) func(T1) T2 {
 		return result
 	}
 }
+
+// OnceApply2 is OnceApply for functions which return two values
+func OnceApply2[T1, T2, T3 any](f func(T1) (T2, T3)) func(T1) (T2, T3) {
+	type result struct {
+		a T2
+		b T3
+	}
+	g := OnceApply(func(input T1) result {
+		a, b := f(input)
+		return result{a, b}
+	})
+	return func(input T1) (T2, T3) {
+		r := g(input)
+		return r.a, r.b
+	}
+}
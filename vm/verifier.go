@@ -0,0 +1,1320 @@
+package vm
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/ir/cfg"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/ops"
+)
+
+// VerifyMode selects the classes which are verified before initialization
+type VerifyMode int8
+
+const (
+	// VerifyRemote verifies the classes which are not loaded by the boot loader
+	VerifyRemote VerifyMode = iota
+	// VerifyAll verifies all classes
+	VerifyAll
+	// VerifyNone disables the verifier
+	VerifyNone
+)
+
+// vkind is the kind of a verification type, see JVMS 4.10.1.2
+type vkind uint8
+
+const (
+	vTop vkind = iota
+	vInt
+	vFloat
+	vLong
+	vDouble
+	vNull
+	vUninitThis
+	vUninit
+	vRef
+)
+
+// vtype is a verification type.
+// Long and double take two slots, and the second slot is vTop.
+type vtype struct {
+	kind vkind
+	// name is the class name or the array descriptor of vRef
+	name string
+	// offset is the offset of the new instruction which created the vUninit value
+	offset int32
+}
+
+var (
+	vtTop        = vtype{kind: vTop}
+	vtInt        = vtype{kind: vInt}
+	vtFloat      = vtype{kind: vFloat}
+	vtLong       = vtype{kind: vLong}
+	vtDouble     = vtype{kind: vDouble}
+	vtNull       = vtype{kind: vNull}
+	vtUninitThis = vtype{kind: vUninitThis}
+	vtObject     = refType("java/lang/Object")
+	vtThrowable  = refType("java/lang/Throwable")
+)
+
+func refType(name string) vtype {
+	return vtype{kind: vRef, name: name}
+}
+
+func vtypeOfDesc(d *desc.Desc) vtype {
+	if d.ArrDim > 0 {
+		return refType(d.String())
+	}
+	switch d.EndType {
+	case desc.Boolean, desc.Byte, desc.Char, desc.Short, desc.Int:
+		return vtInt
+	case desc.Float:
+		return vtFloat
+	case desc.Long:
+		return vtLong
+	case desc.Double:
+		return vtDouble
+	case desc.Class:
+		return refType(d.Class)
+	}
+	return vtTop
+}
+
+func (t vtype) isCat2() bool {
+	return t.kind == vLong || t.kind == vDouble
+}
+
+// isRef reports whether the type is a reference, including the uninitialized ones
+func (t vtype) isRef() bool {
+	return t.kind >= vNull
+}
+
+func (t vtype) String() string {
+	switch t.kind {
+	case vTop:
+		return "top"
+	case vInt:
+		return "integer"
+	case vFloat:
+		return "float"
+	case vLong:
+		return "long"
+	case vDouble:
+		return "double"
+	case vNull:
+		return "null"
+	case vUninitThis:
+		return "uninitializedThis"
+	case vUninit:
+		return fmt.Sprintf("uninitialized(%d)", t.offset)
+	}
+	return "'" + t.name + "'"
+}
+
+// arrayComponent returns the component type of the array descriptor
+func arrayComponent(name string) vtype {
+	d, err := desc.ParseDesc(name)
+	if err != nil || d.ArrDim == 0 {
+		return vtTop
+	}
+	return vtypeOfDesc(d.Elem())
+}
+
+type verifyFrame struct {
+	locals []vtype
+	stack  []vtype
+}
+
+type verifier struct {
+	class  *Class
+	method *Method
+	code   *jcls.AttrCode
+	nodes  map[int32]*ir.ICNode
+	frames map[int32]*verifyFrame
+
+	node   *ir.ICNode
+	locals []vtype
+	stack  []vtype
+}
+
+// Verify checks the bytecode of the class with the type checking verifier driven by StackMapTable, see JVMS 4.10.1.
+// Class files older than version 50 do not have StackMapTable and are not verified,
+// since the type inference verifier is not implemented, see Options.RejectUnverifiable.
+// The result is cached, and VerifyError is returned if any method cannot pass the verification.
+func (c *Class) Verify() error {
+	c.verifyOnce.Do(func() {
+		if c.Major < 50 {
+			return
+		}
+		for i := range c.Methods {
+			m := &c.Methods[i]
+			if m.Code == nil {
+				continue
+			}
+			if c.verifyErr = c.verifyMethod(m); c.verifyErr != nil {
+				return
+			}
+		}
+	})
+	return c.verifyErr
+}
+
+// verify verifies the class if the options select it.
+// The class files older than version 50 which have code are rejected if Options.RejectUnverifiable is set.
+func (vm *VM) verify(c *Class) error {
+	if !vm.shouldVerify(c) {
+		return nil
+	}
+	if c.Major < 50 && vm.opts.RejectUnverifiable {
+		for i := range c.Methods {
+			if m := &c.Methods[i]; m.Code != nil {
+				return errs.Throwf("java/lang/VerifyError", "%s: Class file version %d.%d cannot be verified without StackMapTable", m.Location(), c.Major, c.Minor)
+			}
+		}
+	}
+	return c.Verify()
+}
+
+// shouldVerify reports whether the class should be verified before initialization
+func (vm *VM) shouldVerify(c *Class) bool {
+	if vm.opts == nil {
+		return false
+	}
+	switch vm.opts.Verify {
+	case VerifyAll:
+		return true
+	case VerifyRemote:
+		return c.loader != vm.GetBootLoader()
+	}
+	return false
+}
+
+func (c *Class) verifyMethod(m *Method) error {
+	v := &verifier{
+		class:  c,
+		method: m,
+		code:   m.Code,
+		nodes:  make(map[int32]*ir.ICNode),
+	}
+	for node := v.code.Code; node != nil; node = node.Next {
+		v.nodes[node.Offset] = node
+	}
+	graph, err := cfg.New(v.code)
+	if err != nil {
+		return v.errorf("%v", err)
+	}
+	locals := v.initialLocals()
+	initial, err := v.expandFrame(locals, nil)
+	if err != nil {
+		return err
+	}
+	if err := v.loadStackMap(locals); err != nil {
+		return err
+	}
+	for _, h := range v.code.Exceptions {
+		if _, ok := v.frames[(int32)(h.Handler)]; !ok {
+			return v.errorf("Expecting a stackmap frame at exception handler %d", h.Handler)
+		}
+		if ok, err := v.isAssignable(refType(h.Class), vtThrowable); err != nil {
+			return err
+		} else if !ok {
+			return v.errorf("Catch type %s is not a subclass of Throwable", h.Class)
+		}
+	}
+
+	v.locals, v.stack = initial.locals, initial.stack
+	fallThrough := true
+	for node := v.code.Code; node != nil; node = node.Next {
+		v.node = node
+		if frame, ok := v.frames[node.Offset]; ok {
+			if fallThrough {
+				if err := v.checkFrame(frame, "Current frame is not assignable to stack map frame"); err != nil {
+					return err
+				}
+			}
+			v.locals, v.stack = slices.Clone(frame.locals), slices.Clone(frame.stack)
+		} else if !fallThrough {
+			return v.errorf("Expecting a stackmap frame at branch target %d", node.Offset)
+		}
+		if err := v.checkHandlers(); err != nil {
+			return err
+		}
+		if err := v.execute(); err != nil {
+			return err
+		}
+		if err := v.checkHandlers(); err != nil {
+			return err
+		}
+		block := graph.BlockOf(node)
+		if node != block.Last() {
+			fallThrough = true
+			continue
+		}
+		// falling off the end of the code is already rejected by the control flow graph
+		fallThrough = false
+		for _, e := range block.Succs {
+			switch e.Kind {
+			case cfg.EdgeFallthrough:
+				fallThrough = true
+			case cfg.EdgeException:
+			default:
+				target := e.To.First().Offset
+				frame, ok := v.frames[target]
+				if !ok {
+					return v.errorf("Expecting a stackmap frame at branch target %d", target)
+				}
+				if err := v.checkFrame(frame, "Type is not assignable to the stack map frame of branch target"); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (v *verifier) errorf(format string, args ...any) error {
+	if v.node == nil {
+		return errs.Throwf("java/lang/VerifyError", "%s: "+format, append([]any{v.method.Location()}, args...)...)
+	}
+	return errs.Throwf("java/lang/VerifyError", "%s @%d: %s: "+format, append([]any{v.method.Location(), v.node.Offset, v.op()}, args...)...)
+}
+
+func (v *verifier) op() ops.Op {
+	if w, ok := v.node.IC.(*ir.ICwide); ok {
+		return w.OpCode
+	}
+	return v.node.Op()
+}
+
+// initialLocals returns the locals in the method's implicit initial frame, see JVMS 4.10.1.6
+func (v *verifier) initialLocals() []vtype {
+	inputs := v.method.Desc().Inputs
+	locals := make([]vtype, 0, len(inputs)+1)
+	if !v.method.IsStatic() {
+		if v.method.Name() == "<init>" && v.class.Name() != "java/lang/Object" {
+			locals = append(locals, vtUninitThis)
+		} else {
+			locals = append(locals, refType(v.class.Name()))
+		}
+	}
+	for _, in := range inputs {
+		locals = append(locals, vtypeOfDesc(in))
+	}
+	return locals
+}
+
+// expandFrame expands the category 2 types to two slots, and fills the rest locals with top
+func (v *verifier) expandFrame(locals, stack []vtype) (*verifyFrame, error) {
+	frame := &verifyFrame{
+		locals: make([]vtype, 0, v.code.MaxLocals),
+		stack:  make([]vtype, 0, len(stack)),
+	}
+	for _, t := range locals {
+		frame.locals = append(frame.locals, t)
+		if t.isCat2() {
+			frame.locals = append(frame.locals, vtTop)
+		}
+	}
+	if len(frame.locals) > (int)(v.code.MaxLocals) {
+		return nil, v.errorf("Locals size %d exceeds max locals %d", len(frame.locals), v.code.MaxLocals)
+	}
+	for len(frame.locals) < (int)(v.code.MaxLocals) {
+		frame.locals = append(frame.locals, vtTop)
+	}
+	for _, t := range stack {
+		frame.stack = append(frame.stack, t)
+		if t.isCat2() {
+			frame.stack = append(frame.stack, vtTop)
+		}
+	}
+	if len(frame.stack) > (int)(v.code.MaxStack) {
+		return nil, v.errorf("Stack size %d exceeds max stack %d", len(frame.stack), v.code.MaxStack)
+	}
+	return frame, nil
+}
+
+// loadStackMap decodes the StackMapTable attribute to the frames at the absolute offsets
+func (v *verifier) loadStackMap(locals []vtype) error {
+	v.frames = make(map[int32]*verifyFrame)
+	if v.code.StackMap == nil {
+		return nil
+	}
+	var stack []vtype
+	offset := (int32)(-1)
+	for _, f := range v.code.StackMap.Frames {
+		offset += (int32)(f.OffsetDelta) + 1
+		switch f.Kind {
+		case jcls.FrameSame:
+			stack = nil
+		case jcls.FrameSameLocals1StackItem:
+			t, err := v.frameType(f.Stack[0])
+			if err != nil {
+				return err
+			}
+			stack = []vtype{t}
+		case jcls.FrameChop:
+			if (int)(f.Chop) > len(locals) {
+				return v.errorf("StackMapTable error: chop %d locals from %d locals at %d", f.Chop, len(locals), offset)
+			}
+			locals = locals[:len(locals)-(int)(f.Chop)]
+			stack = nil
+		case jcls.FrameAppend:
+			locals = slices.Clip(locals)
+			for _, t := range f.Locals {
+				vt, err := v.frameType(t)
+				if err != nil {
+					return err
+				}
+				locals = append(locals, vt)
+			}
+			stack = nil
+		case jcls.FrameFull:
+			locals = make([]vtype, len(f.Locals))
+			for i, t := range f.Locals {
+				vt, err := v.frameType(t)
+				if err != nil {
+					return err
+				}
+				locals[i] = vt
+			}
+			stack = make([]vtype, len(f.Stack))
+			for i, t := range f.Stack {
+				vt, err := v.frameType(t)
+				if err != nil {
+					return err
+				}
+				stack[i] = vt
+			}
+		}
+		if _, ok := v.nodes[offset]; !ok {
+			return v.errorf("StackMapTable error: bad offset %d", offset)
+		}
+		frame, err := v.expandFrame(locals, stack)
+		if err != nil {
+			return err
+		}
+		v.frames[offset] = frame
+	}
+	return nil
+}
+
+func (v *verifier) frameType(t jcls.VerificationType) (vtype, error) {
+	switch t.Tag {
+	case jcls.VerifyTop:
+		return vtTop, nil
+	case jcls.VerifyInteger:
+		return vtInt, nil
+	case jcls.VerifyFloat:
+		return vtFloat, nil
+	case jcls.VerifyLong:
+		return vtLong, nil
+	case jcls.VerifyDouble:
+		return vtDouble, nil
+	case jcls.VerifyNull:
+		return vtNull, nil
+	case jcls.VerifyUninitializedThis:
+		return vtUninitThis, nil
+	case jcls.VerifyObject:
+		return refType(t.Class), nil
+	case jcls.VerifyUninitialized:
+		if node, ok := v.nodes[(int32)(t.Offset)]; !ok || node.Op() != ops.New {
+			return vtTop, v.errorf("StackMapTable error: uninitialized(%d) does not refer to a new instruction", t.Offset)
+		}
+		return vtype{kind: vUninit, offset: (int32)(t.Offset)}, nil
+	}
+	return vtTop, v.errorf("StackMapTable error: unknown verification type %d", t.Tag)
+}
+
+// isAssignable reports whether the verification type from is assignable to the type to, see JVMS 4.10.1.2
+func (v *verifier) isAssignable(from, to vtype) (bool, error) {
+	if to.kind == vTop {
+		return true, nil
+	}
+	if from.kind != to.kind {
+		return from.kind == vNull && to.kind == vRef, nil
+	}
+	switch from.kind {
+	case vUninit:
+		return from.offset == to.offset, nil
+	case vRef:
+		return v.isRefAssignable(from.name, to.name)
+	}
+	return true, nil
+}
+
+func (v *verifier) isRefAssignable(from, to string) (bool, error) {
+	if from == to || to == "java/lang/Object" {
+		return true, nil
+	}
+	if to[0] == '[' {
+		if from[0] != '[' {
+			return false, nil
+		}
+		fc, tc := arrayComponent(from), arrayComponent(to)
+		if fc.kind != vRef || tc.kind != vRef {
+			return false, nil
+		}
+		return v.isRefAssignable(fc.name, tc.name)
+	}
+	if from[0] == '[' {
+		return to == "java/lang/Cloneable" || to == "java/io/Serializable", nil
+	}
+	toClass, err := v.class.loader.LoadClass(to)
+	if err != nil {
+		return false, err
+	}
+	// interfaces are treated as java/lang/Object by the verifier
+	if toClass.IsInterface() {
+		return true, nil
+	}
+	fromClass, err := v.class.loader.LoadClass(from)
+	if err != nil {
+		return false, err
+	}
+	return toClass.IsAssignableFrom(fromClass), nil
+}
+
+func (v *verifier) checkFrame(frame *verifyFrame, reason string) error {
+	if len(v.stack) != len(frame.stack) {
+		return v.errorf("%s: stack size %d, expected %d", reason, len(v.stack), len(frame.stack))
+	}
+	for i, t := range v.stack {
+		if ok, err := v.isAssignable(t, frame.stack[i]); err != nil {
+			return err
+		} else if !ok {
+			return v.errorf("%s: stack slot %d is %s, expected %s", reason, i, t, frame.stack[i])
+		}
+	}
+	for i, t := range v.locals {
+		if ok, err := v.isAssignable(t, frame.locals[i]); err != nil {
+			return err
+		} else if !ok {
+			return v.errorf("%s: local %d is %s, expected %s", reason, i, t, frame.locals[i])
+		}
+	}
+	return nil
+}
+
+// checkHandlers checks the current locals against the frames of the exception handlers which cover the instruction
+func (v *verifier) checkHandlers() error {
+	offset := v.node.Offset
+	for _, h := range v.code.Exceptions {
+		if offset < (int32)(h.Start) || offset >= (int32)(h.End) {
+			continue
+		}
+		frame := v.frames[(int32)(h.Handler)]
+		if len(frame.stack) != 1 {
+			return v.errorf("Exception handler %d expects stack size 1, got %d", h.Handler, len(frame.stack))
+		}
+		if ok, err := v.isAssignable(refType(h.Class), frame.stack[0]); err != nil {
+			return err
+		} else if !ok {
+			return v.errorf("Catch type %s is not assignable to %s at exception handler %d", h.Class, frame.stack[0], h.Handler)
+		}
+		for i, t := range v.locals {
+			if ok, err := v.isAssignable(t, frame.locals[i]); err != nil {
+				return err
+			} else if !ok {
+				return v.errorf("Local %d is %s, but exception handler %d expects %s", i, t, h.Handler, frame.locals[i])
+			}
+		}
+	}
+	return nil
+}
+
+func (v *verifier) push(t vtype) error {
+	v.stack = append(v.stack, t)
+	if t.isCat2() {
+		v.stack = append(v.stack, vtTop)
+	}
+	if len(v.stack) > (int)(v.code.MaxStack) {
+		return v.errorf("Exceeded max stack size %d", v.code.MaxStack)
+	}
+	return nil
+}
+
+// pop pops a value which is assignable to the type t
+func (v *verifier) pop(t vtype) (vtype, error) {
+	n := 1
+	if t.isCat2() {
+		n = 2
+	}
+	if len(v.stack) < n {
+		return vtTop, v.errorf("Attempt to pop empty stack")
+	}
+	i := len(v.stack) - n
+	got := v.stack[i]
+	if t.isCat2() && v.stack[i+1].kind != vTop {
+		return vtTop, v.errorf("Bad type on operand stack: expected %s", t)
+	}
+	if ok, err := v.isAssignable(got, t); err != nil {
+		return vtTop, err
+	} else if !ok || (got.kind == vTop && t.kind != vTop) {
+		return vtTop, v.errorf("Bad type on operand stack: %s is not assignable to %s", got, t)
+	}
+	v.stack = v.stack[:i]
+	return got, nil
+}
+
+// popRef pops a reference, including the uninitialized ones
+func (v *verifier) popRef() (vtype, error) {
+	if len(v.stack) == 0 {
+		return vtTop, v.errorf("Attempt to pop empty stack")
+	}
+	got := v.stack[len(v.stack)-1]
+	if !got.isRef() {
+		return vtTop, v.errorf("Bad type on operand stack: %s is not a reference", got)
+	}
+	v.stack = v.stack[:len(v.stack)-1]
+	return got, nil
+}
+
+// popSlots pops n slots without splitting category 2 values
+func (v *verifier) popSlots(n int) ([]vtype, error) {
+	if len(v.stack) < n {
+		return nil, v.errorf("Attempt to pop empty stack")
+	}
+	i := len(v.stack) - n
+	if v.stack[i].kind == vTop {
+		return nil, v.errorf("Bad type on operand stack: cannot split a category 2 value")
+	}
+	slots := slices.Clone(v.stack[i:])
+	v.stack = v.stack[:i]
+	return slots, nil
+}
+
+func (v *verifier) pushSlots(slots ...[]vtype) error {
+	for _, s := range slots {
+		v.stack = append(v.stack, s...)
+	}
+	if len(v.stack) > (int)(v.code.MaxStack) {
+		return v.errorf("Exceeded max stack size %d", v.code.MaxStack)
+	}
+	return nil
+}
+
+func (v *verifier) popPush(push vtype, pops ...vtype) error {
+	for _, t := range pops {
+		if _, err := v.pop(t); err != nil {
+			return err
+		}
+	}
+	if push.kind == vTop {
+		return nil
+	}
+	return v.push(push)
+}
+
+func (v *verifier) getLocal(i uint16, t vtype) (vtype, error) {
+	n := (int)(i)
+	if t.isCat2() {
+		n++
+	}
+	if n >= len(v.locals) {
+		return vtTop, v.errorf("Illegal local variable number %d", i)
+	}
+	got := v.locals[i]
+	if t.kind == vRef {
+		if !got.isRef() {
+			return vtTop, v.errorf("Bad local variable type: local %d is %s, expected reference", i, got)
+		}
+		return got, nil
+	}
+	if got.kind != t.kind {
+		return vtTop, v.errorf("Bad local variable type: local %d is %s, expected %s", i, got, t)
+	}
+	return got, nil
+}
+
+func (v *verifier) setLocal(i uint16, t vtype) error {
+	n := (int)(i)
+	if t.isCat2() {
+		n++
+	}
+	if n >= len(v.locals) {
+		return v.errorf("Illegal local variable number %d", i)
+	}
+	if i > 0 && v.locals[i-1].isCat2() {
+		v.locals[i-1] = vtTop
+	}
+	v.locals[i] = t
+	if t.isCat2() {
+		v.locals[i+1] = vtTop
+	}
+	return nil
+}
+
+func (v *verifier) load(i uint16, t vtype) error {
+	got, err := v.getLocal(i, t)
+	if err != nil {
+		return err
+	}
+	return v.push(got)
+}
+
+func (v *verifier) store(i uint16, t vtype) error {
+	var (
+		got vtype
+		err error
+	)
+	if t.kind == vRef {
+		got, err = v.popRef()
+	} else {
+		got, err = v.pop(t)
+	}
+	if err != nil {
+		return err
+	}
+	return v.setLocal(i, got)
+}
+
+// popArray pops an array whose descriptor is one of the names, or null
+func (v *verifier) popArray(names ...string) (vtype, error) {
+	got, err := v.popRef()
+	if err != nil {
+		return vtTop, err
+	}
+	if got.kind == vNull || (got.kind == vRef && slices.Contains(names, got.name)) {
+		return got, nil
+	}
+	return vtTop, v.errorf("Bad type on operand stack: %s is not an array of %v", got, names)
+}
+
+// popRefArray pops an array of references, or null
+func (v *verifier) popRefArray() (vtype, error) {
+	got, err := v.popRef()
+	if err != nil {
+		return vtTop, err
+	}
+	if got.kind == vNull {
+		return got, nil
+	}
+	if got.kind == vRef && len(got.name) > 1 && got.name[0] == '[' && (got.name[1] == 'L' || got.name[1] == '[') {
+		return got, nil
+	}
+	return vtTop, v.errorf("Bad type on operand stack: %s is not an array of references", got)
+}
+
+func (v *verifier) constant(i uint16) (jcls.ConstantInfo, error) {
+	if i == 0 || (int)(i) > len(v.class.ConstPool) {
+		return nil, v.errorf("Illegal constant pool index %d", i)
+	}
+	return v.class.ConstPool[i-1], nil
+}
+
+func (v *verifier) constantClass(i uint16) (string, error) {
+	c, err := v.constant(i)
+	if err != nil {
+		return "", err
+	}
+	class, ok := c.(*jcls.ConstantClass)
+	if !ok {
+		return "", v.errorf("Constant %d is not a class", i)
+	}
+	return class.Name, nil
+}
+
+func (v *verifier) constantRef(i uint16, tags ...jcls.ConstTag) (*jcls.ConstantRef, error) {
+	c, err := v.constant(i)
+	if err != nil {
+		return nil, err
+	}
+	ref, ok := c.(*jcls.ConstantRef)
+	if !ok || !slices.Contains(tags, ref.ConstTag) {
+		return nil, v.errorf("Constant %d is not a %v reference", i, tags)
+	}
+	return ref, nil
+}
+
+func (v *verifier) ldc(i uint16, wide bool) error {
+	c, err := v.constant(i)
+	if err != nil {
+		return err
+	}
+	var t vtype
+	switch c := c.(type) {
+	case *jcls.ConstantInteger:
+		t = vtInt
+	case *jcls.ConstantFloat:
+		t = vtFloat
+	case *jcls.ConstantLong:
+		t = vtLong
+	case *jcls.ConstantDouble:
+		t = vtDouble
+	case *jcls.ConstantString:
+		t = refType("java/lang/String")
+	case *jcls.ConstantClass:
+		t = refType("java/lang/Class")
+	case *jcls.ConstantMethodType:
+		t = refType("java/lang/invoke/MethodType")
+	case *jcls.ConstantMethodHandle:
+		t = refType("java/lang/invoke/MethodHandle")
+	case *jcls.ConstantDynamics:
+		if c.ConstTag != jcls.TagDynamic {
+			return v.errorf("Constant %d is not loadable", i)
+		}
+		d, err := desc.ParseDesc(c.NameAndType.Desc)
+		if err != nil {
+			return v.errorf("Illegal dynamic constant descriptor %s", c.NameAndType.Desc)
+		}
+		t = vtypeOfDesc(d)
+	default:
+		return v.errorf("Constant %d is not loadable", i)
+	}
+	if t.isCat2() != wide {
+		return v.errorf("Constant %d has wrong category", i)
+	}
+	return v.push(t)
+}
+
+func (v *verifier) field(i uint16, static, put bool) error {
+	ref, err := v.constantRef(i, jcls.TagFieldref)
+	if err != nil {
+		return err
+	}
+	d, err := desc.ParseDesc(ref.NameAndType.Desc)
+	if err != nil {
+		return v.errorf("Illegal field descriptor %s", ref.NameAndType.Desc)
+	}
+	t := vtypeOfDesc(d)
+	if put {
+		if _, err := v.pop(t); err != nil {
+			return err
+		}
+	}
+	if !static {
+		owner := refType(ref.Class.Name)
+		recv, err := v.popRef()
+		if err != nil {
+			return err
+		}
+		// constructors can set the fields declared by the class itself before calling super()
+		if !(put && recv.kind == vUninitThis && ref.Class.Name == v.class.Name()) {
+			if ok, err := v.isAssignable(recv, owner); err != nil {
+				return err
+			} else if !ok {
+				return v.errorf("Bad type on operand stack: %s is not assignable to %s", recv, owner)
+			}
+		}
+	}
+	if put {
+		return nil
+	}
+	return v.push(t)
+}
+
+func (v *verifier) invoke(op ops.Op, i uint16) error {
+	var (
+		name, typ string
+		owner     string
+	)
+	if op == ops.Invokedynamic {
+		c, err := v.constant(i)
+		if err != nil {
+			return err
+		}
+		info, ok := c.(*jcls.ConstantDynamics)
+		if !ok || info.ConstTag != jcls.TagInvokeDynamic {
+			return v.errorf("Constant %d is not an invokedynamic", i)
+		}
+		name, typ = info.NameAndType.Name, info.NameAndType.Desc
+	} else {
+		var (
+			ref *jcls.ConstantRef
+			err error
+		)
+		switch op {
+		case ops.Invokevirtual:
+			ref, err = v.constantRef(i, jcls.TagMethodref)
+		case ops.Invokeinterface:
+			ref, err = v.constantRef(i, jcls.TagInterfaceMethodref)
+		default:
+			ref, err = v.constantRef(i, jcls.TagMethodref, jcls.TagInterfaceMethodref)
+		}
+		if err != nil {
+			return err
+		}
+		name, typ, owner = ref.NameAndType.Name, ref.NameAndType.Desc, ref.Class.Name
+	}
+	md, err := desc.ParseMethodDesc(typ)
+	if err != nil {
+		return v.errorf("Illegal method descriptor %s", typ)
+	}
+	isInit := name == "<init>"
+	if name == "<clinit>" || (isInit && (op != ops.Invokespecial || md.Output.EndType != desc.Void || md.Output.ArrDim != 0)) {
+		return v.errorf("Illegal call to %s", name)
+	}
+	for j := len(md.Inputs) - 1; j >= 0; j-- {
+		if _, err := v.pop(vtypeOfDesc(md.Inputs[j])); err != nil {
+			return err
+		}
+	}
+	if op != ops.Invokestatic && op != ops.Invokedynamic {
+		recv, err := v.popRef()
+		if err != nil {
+			return err
+		}
+		if isInit {
+			if err := v.initialize(recv, owner); err != nil {
+				return err
+			}
+		} else {
+			want := refType(owner)
+			if op == ops.Invokespecial {
+				want = refType(v.class.Name())
+			}
+			if ok, err := v.isAssignable(recv, want); err != nil {
+				return err
+			} else if !ok {
+				return v.errorf("Bad type on operand stack: %s is not assignable to %s", recv, want)
+			}
+		}
+	}
+	if md.Output.EndType == desc.Void && md.Output.ArrDim == 0 {
+		return nil
+	}
+	return v.push(vtypeOfDesc(md.Output))
+}
+
+// initialize replaces the uninitialized type with the initialized class after calling <init>
+func (v *verifier) initialize(recv vtype, owner string) error {
+	var inited vtype
+	switch recv.kind {
+	case vUninitThis:
+		super := ""
+		if s := v.class.Super(); s != nil {
+			super = s.Name()
+		}
+		if owner != v.class.Name() && owner != super {
+			return v.errorf("Bad <init> method call: %s is neither current class nor super class", owner)
+		}
+		inited = refType(v.class.Name())
+	case vUninit:
+		name, err := v.constantClass(v.nodes[recv.offset].IC.(*ir.ICnew).Class)
+		if err != nil {
+			return err
+		}
+		if name != owner {
+			return v.errorf("Bad <init> method call: %s is not %s", recv, owner)
+		}
+		inited = refType(name)
+	default:
+		return v.errorf("Bad <init> method call on %s", recv)
+	}
+	for i, t := range v.locals {
+		if t == recv {
+			v.locals[i] = inited
+		}
+	}
+	for i, t := range v.stack {
+		if t == recv {
+			v.stack[i] = inited
+		}
+	}
+	return nil
+}
+
+func (v *verifier) returns(t vtype) error {
+	out := v.method.Desc().Output
+	if t.kind == vTop {
+		if out.EndType != desc.Void || out.ArrDim != 0 {
+			return v.errorf("Method expects a return value")
+		}
+		if v.method.Name() == "<init>" && slices.Contains(v.locals, vtUninitThis) {
+			return v.errorf("Constructor must call super() or this() before return")
+		}
+		return nil
+	}
+	want := vtypeOfDesc(out)
+	if want.kind != t.kind && !(t.kind == vRef && want.kind == vRef) {
+		return v.errorf("Method expects a return value of %s", want)
+	}
+	_, err := v.pop(want)
+	return err
+}
+
+// localIndex returns the local variable index of the load or store instruction
+func localIndex(ic ir.IC, op0 ops.Op) uint16 {
+	switch ic := ic.(type) {
+	case *ir.ICwide:
+		return ic.Index
+	case *ir.ICiload:
+		return ic.Index
+	case *ir.IClload:
+		return ic.Index
+	case *ir.ICfload:
+		return ic.Index
+	case *ir.ICdload:
+		return ic.Index
+	case *ir.ICaload:
+		return ic.Index
+	case *ir.ICistore:
+		return ic.Index
+	case *ir.IClstore:
+		return ic.Index
+	case *ir.ICfstore:
+		return ic.Index
+	case *ir.ICdstore:
+		return ic.Index
+	case *ir.ICastore:
+		return ic.Index
+	}
+	return (uint16)(ic.Op() - op0)
+}
+
+// execute applies the current instruction to the frame
+func (v *verifier) execute() error {
+	ic := v.node.IC
+	op := v.op()
+	if _, ok := ic.(*ir.ICwide); ok {
+		switch op {
+		case ops.Iload, ops.Lload, ops.Fload, ops.Dload, ops.Aload,
+			ops.Istore, ops.Lstore, ops.Fstore, ops.Dstore, ops.Astore, ops.Iinc:
+		default:
+			return v.errorf("Bad wide instruction")
+		}
+	}
+	var err error
+	switch op {
+	case ops.Nop:
+	case ops.Aconst_null:
+		err = v.push(vtNull)
+	case ops.Iconst_m1, ops.Iconst_0, ops.Iconst_1, ops.Iconst_2, ops.Iconst_3, ops.Iconst_4, ops.Iconst_5, ops.Bipush, ops.Sipush:
+		err = v.push(vtInt)
+	case ops.Lconst_0, ops.Lconst_1:
+		err = v.push(vtLong)
+	case ops.Fconst_0, ops.Fconst_1, ops.Fconst_2:
+		err = v.push(vtFloat)
+	case ops.Dconst_0, ops.Dconst_1:
+		err = v.push(vtDouble)
+	case ops.Ldc:
+		err = v.ldc((uint16)(ic.(*ir.ICldc).Index), false)
+	case ops.Ldc_w:
+		err = v.ldc(ic.(*ir.ICldc_w).Index, false)
+	case ops.Ldc2_w:
+		err = v.ldc(ic.(*ir.ICldc2_w).Index, true)
+
+	case ops.Iload, ops.Iload_0, ops.Iload_1, ops.Iload_2, ops.Iload_3:
+		err = v.load(localIndex(ic, ops.Iload_0), vtInt)
+	case ops.Lload, ops.Lload_0, ops.Lload_1, ops.Lload_2, ops.Lload_3:
+		err = v.load(localIndex(ic, ops.Lload_0), vtLong)
+	case ops.Fload, ops.Fload_0, ops.Fload_1, ops.Fload_2, ops.Fload_3:
+		err = v.load(localIndex(ic, ops.Fload_0), vtFloat)
+	case ops.Dload, ops.Dload_0, ops.Dload_1, ops.Dload_2, ops.Dload_3:
+		err = v.load(localIndex(ic, ops.Dload_0), vtDouble)
+	case ops.Aload, ops.Aload_0, ops.Aload_1, ops.Aload_2, ops.Aload_3:
+		err = v.load(localIndex(ic, ops.Aload_0), vtObject)
+	case ops.Istore, ops.Istore_0, ops.Istore_1, ops.Istore_2, ops.Istore_3:
+		err = v.store(localIndex(ic, ops.Istore_0), vtInt)
+	case ops.Lstore, ops.Lstore_0, ops.Lstore_1, ops.Lstore_2, ops.Lstore_3:
+		err = v.store(localIndex(ic, ops.Lstore_0), vtLong)
+	case ops.Fstore, ops.Fstore_0, ops.Fstore_1, ops.Fstore_2, ops.Fstore_3:
+		err = v.store(localIndex(ic, ops.Fstore_0), vtFloat)
+	case ops.Dstore, ops.Dstore_0, ops.Dstore_1, ops.Dstore_2, ops.Dstore_3:
+		err = v.store(localIndex(ic, ops.Dstore_0), vtDouble)
+	case ops.Astore, ops.Astore_0, ops.Astore_1, ops.Astore_2, ops.Astore_3:
+		err = v.store(localIndex(ic, ops.Astore_0), vtObject)
+	case ops.Iinc:
+		var i uint16
+		if w, ok := ic.(*ir.ICwide); ok {
+			i = w.Index
+		} else {
+			i = ic.(*ir.ICiinc).Index
+		}
+		_, err = v.getLocal(i, vtInt)
+
+	case ops.Iaload, ops.Baload, ops.Caload, ops.Saload, ops.Laload, ops.Faload, ops.Daload:
+		var (
+			names []string
+			t     vtype
+		)
+		switch op {
+		case ops.Iaload:
+			names, t = []string{"[I"}, vtInt
+		case ops.Baload:
+			names, t = []string{"[B", "[Z"}, vtInt
+		case ops.Caload:
+			names, t = []string{"[C"}, vtInt
+		case ops.Saload:
+			names, t = []string{"[S"}, vtInt
+		case ops.Laload:
+			names, t = []string{"[J"}, vtLong
+		case ops.Faload:
+			names, t = []string{"[F"}, vtFloat
+		case ops.Daload:
+			names, t = []string{"[D"}, vtDouble
+		}
+		if _, err = v.pop(vtInt); err == nil {
+			if _, err = v.popArray(names...); err == nil {
+				err = v.push(t)
+			}
+		}
+	case ops.Aaload:
+		var arr vtype
+		if _, err = v.pop(vtInt); err == nil {
+			if arr, err = v.popRefArray(); err == nil {
+				if arr.kind == vNull {
+					err = v.push(vtNull)
+				} else {
+					err = v.push(arrayComponent(arr.name))
+				}
+			}
+		}
+	case ops.Iastore, ops.Bastore, ops.Castore, ops.Sastore, ops.Lastore, ops.Fastore, ops.Dastore:
+		var (
+			names []string
+			t     vtype
+		)
+		switch op {
+		case ops.Iastore:
+			names, t = []string{"[I"}, vtInt
+		case ops.Bastore:
+			names, t = []string{"[B", "[Z"}, vtInt
+		case ops.Castore:
+			names, t = []string{"[C"}, vtInt
+		case ops.Sastore:
+			names, t = []string{"[S"}, vtInt
+		case ops.Lastore:
+			names, t = []string{"[J"}, vtLong
+		case ops.Fastore:
+			names, t = []string{"[F"}, vtFloat
+		case ops.Dastore:
+			names, t = []string{"[D"}, vtDouble
+		}
+		if _, err = v.pop(t); err == nil {
+			if _, err = v.pop(vtInt); err == nil {
+				_, err = v.popArray(names...)
+			}
+		}
+	case ops.Aastore:
+		if _, err = v.pop(vtObject); err == nil {
+			if _, err = v.pop(vtInt); err == nil {
+				_, err = v.popRefArray()
+			}
+		}
+
+	case ops.Pop:
+		_, err = v.popSlots(1)
+	case ops.Pop2:
+		_, err = v.popSlots(2)
+	case ops.Dup:
+		var s []vtype
+		if s, err = v.popSlots(1); err == nil {
+			err = v.pushSlots(s, s)
+		}
+	case ops.Dup_x1:
+		var s1, s2 []vtype
+		if s1, err = v.popSlots(1); err == nil {
+			if s2, err = v.popSlots(1); err == nil {
+				err = v.pushSlots(s1, s2, s1)
+			}
+		}
+	case ops.Dup_x2:
+		var s1, s2 []vtype
+		if s1, err = v.popSlots(1); err == nil {
+			if s2, err = v.popSlots(2); err == nil {
+				err = v.pushSlots(s1, s2, s1)
+			}
+		}
+	case ops.Dup2:
+		var s []vtype
+		if s, err = v.popSlots(2); err == nil {
+			err = v.pushSlots(s, s)
+		}
+	case ops.Dup2_x1:
+		var s1, s2 []vtype
+		if s1, err = v.popSlots(2); err == nil {
+			if s2, err = v.popSlots(1); err == nil {
+				err = v.pushSlots(s1, s2, s1)
+			}
+		}
+	case ops.Dup2_x2:
+		var s1, s2 []vtype
+		if s1, err = v.popSlots(2); err == nil {
+			if s2, err = v.popSlots(2); err == nil {
+				err = v.pushSlots(s1, s2, s1)
+			}
+		}
+	case ops.Swap:
+		var s1, s2 []vtype
+		if s1, err = v.popSlots(1); err == nil {
+			if s2, err = v.popSlots(1); err == nil {
+				err = v.pushSlots(s1, s2)
+			}
+		}
+
+	case ops.Iadd, ops.Isub, ops.Imul, ops.Idiv, ops.Irem, ops.Ishl, ops.Ishr, ops.Iushr, ops.Iand, ops.Ior, ops.Ixor:
+		err = v.popPush(vtInt, vtInt, vtInt)
+	case ops.Ladd, ops.Lsub, ops.Lmul, ops.Ldiv, ops.Lrem, ops.Land, ops.Lor, ops.Lxor:
+		err = v.popPush(vtLong, vtLong, vtLong)
+	case ops.Lshl, ops.Lshr, ops.Lushr:
+		err = v.popPush(vtLong, vtInt, vtLong)
+	case ops.Fadd, ops.Fsub, ops.Fmul, ops.Fdiv, ops.Frem:
+		err = v.popPush(vtFloat, vtFloat, vtFloat)
+	case ops.Dadd, ops.Dsub, ops.Dmul, ops.Ddiv, ops.Drem:
+		err = v.popPush(vtDouble, vtDouble, vtDouble)
+	case ops.Ineg, ops.I2b, ops.I2c, ops.I2s:
+		err = v.popPush(vtInt, vtInt)
+	case ops.Lneg:
+		err = v.popPush(vtLong, vtLong)
+	case ops.Fneg:
+		err = v.popPush(vtFloat, vtFloat)
+	case ops.Dneg:
+		err = v.popPush(vtDouble, vtDouble)
+	case ops.I2l:
+		err = v.popPush(vtLong, vtInt)
+	case ops.I2f:
+		err = v.popPush(vtFloat, vtInt)
+	case ops.I2d:
+		err = v.popPush(vtDouble, vtInt)
+	case ops.L2i:
+		err = v.popPush(vtInt, vtLong)
+	case ops.L2f:
+		err = v.popPush(vtFloat, vtLong)
+	case ops.L2d:
+		err = v.popPush(vtDouble, vtLong)
+	case ops.F2i:
+		err = v.popPush(vtInt, vtFloat)
+	case ops.F2l:
+		err = v.popPush(vtLong, vtFloat)
+	case ops.F2d:
+		err = v.popPush(vtDouble, vtFloat)
+	case ops.D2i:
+		err = v.popPush(vtInt, vtDouble)
+	case ops.D2l:
+		err = v.popPush(vtLong, vtDouble)
+	case ops.D2f:
+		err = v.popPush(vtFloat, vtDouble)
+	case ops.Lcmp:
+		err = v.popPush(vtInt, vtLong, vtLong)
+	case ops.Fcmpl, ops.Fcmpg:
+		err = v.popPush(vtInt, vtFloat, vtFloat)
+	case ops.Dcmpl, ops.Dcmpg:
+		err = v.popPush(vtInt, vtDouble, vtDouble)
+
+	case ops.Ifeq, ops.Ifne, ops.Iflt, ops.Ifge, ops.Ifgt, ops.Ifle:
+		err = v.popPush(vtTop, vtInt)
+	case ops.If_icmpeq, ops.If_icmpne, ops.If_icmplt, ops.If_icmpge, ops.If_icmpgt, ops.If_icmple:
+		err = v.popPush(vtTop, vtInt, vtInt)
+	case ops.If_acmpeq, ops.If_acmpne:
+		// the operands are any reference, including the uninitialized ones, see JVMS 4.10.1.9
+		if _, err = v.popRef(); err == nil {
+			_, err = v.popRef()
+		}
+	case ops.Ifnull, ops.Ifnonnull:
+		_, err = v.popRef()
+	case ops.Goto, ops.Goto_w:
+	case ops.Tableswitch, ops.Lookupswitch:
+		err = v.popPush(vtTop, vtInt)
+	case ops.Jsr, ops.Jsr_w, ops.Ret:
+		return v.errorf("Illegal instruction in class file version %d", v.class.Major)
+
+	case ops.Ireturn:
+		err = v.returns(vtInt)
+	case ops.Lreturn:
+		err = v.returns(vtLong)
+	case ops.Freturn:
+		err = v.returns(vtFloat)
+	case ops.Dreturn:
+		err = v.returns(vtDouble)
+	case ops.Areturn:
+		err = v.returns(vtObject)
+	case ops.Return:
+		err = v.returns(vtTop)
+
+	case ops.Getstatic:
+		err = v.field(ic.(*ir.ICgetstatic).Field, true, false)
+	case ops.Putstatic:
+		err = v.field(ic.(*ir.ICputstatic).Field, true, true)
+	case ops.Getfield:
+		err = v.field(ic.(*ir.ICgetfield).Field, false, false)
+	case ops.Putfield:
+		err = v.field(ic.(*ir.ICputfield).Field, false, true)
+	case ops.Invokevirtual:
+		err = v.invoke(op, ic.(*ir.ICinvokevirtual).Method)
+	case ops.Invokespecial:
+		err = v.invoke(op, ic.(*ir.ICinvokespecial).Method)
+	case ops.Invokestatic:
+		err = v.invoke(op, ic.(*ir.ICinvokestatic).Method)
+	case ops.Invokeinterface:
+		err = v.invoke(op, ic.(*ir.ICinvokeinterface).Method)
+	case ops.Invokedynamic:
+		err = v.invoke(op, ic.(*ir.ICinvokedynamic).Method)
+
+	case ops.New:
+		var name string
+		if name, err = v.constantClass(ic.(*ir.ICnew).Class); err == nil {
+			if name[0] == '[' {
+				err = v.errorf("Illegal use of new on array class %s", name)
+			} else {
+				err = v.push(vtype{kind: vUninit, offset: v.node.Offset})
+			}
+		}
+	case ops.Newarray:
+		names := [...]string{4: "[Z", 5: "[C", 6: "[F", 7: "[D", 8: "[B", 9: "[S", 10: "[I", 11: "[J"}
+		atype := ic.(*ir.ICnewarray).Atype
+		if (int)(atype) >= len(names) || names[atype] == "" {
+			err = v.errorf("Illegal newarray type %d", atype)
+		} else {
+			err = v.popPush(refType(names[atype]), vtInt)
+		}
+	case ops.Anewarray:
+		var name string
+		if name, err = v.constantClass(ic.(*ir.ICanewarray).Class); err == nil {
+			if name[0] != '[' {
+				name = "L" + name + ";"
+			}
+			err = v.popPush(refType("["+name), vtInt)
+		}
+	case ops.Multianewarray:
+		mic := ic.(*ir.ICmultianewarray)
+		var name string
+		if name, err = v.constantClass(mic.ArrClass); err == nil {
+			dim := 0
+			for dim < len(name) && name[dim] == '[' {
+				dim++
+			}
+			if mic.Dimensions == 0 || (int)(mic.Dimensions) > dim {
+				err = v.errorf("Illegal dimensions %d for %s", mic.Dimensions, name)
+			}
+			for range mic.Dimensions {
+				if err != nil {
+					break
+				}
+				_, err = v.pop(vtInt)
+			}
+			if err == nil {
+				err = v.push(refType(name))
+			}
+		}
+	case ops.Arraylength:
+		var arr vtype
+		if arr, err = v.popRef(); err == nil {
+			if arr.kind != vNull && (arr.kind != vRef || arr.name[0] != '[') {
+				err = v.errorf("Bad type on operand stack: %s is not an array", arr)
+			} else {
+				err = v.push(vtInt)
+			}
+		}
+	case ops.Athrow:
+		err = v.popPush(vtTop, vtThrowable)
+	case ops.Checkcast:
+		var name string
+		if name, err = v.constantClass(ic.(*ir.ICcheckcast).Class); err == nil {
+			err = v.popPush(refType(name), vtObject)
+		}
+	case ops.Instanceof:
+		if _, err = v.constantClass(ic.(*ir.ICinstanceof).Class); err == nil {
+			err = v.popPush(vtInt, vtObject)
+		}
+	case ops.Monitorenter, ops.Monitorexit:
+		_, err = v.popRef()
+	default:
+		return v.errorf("Illegal instruction")
+	}
+	return err
+}
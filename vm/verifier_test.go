@@ -0,0 +1,106 @@
+package vm
+
+import (
+	"os"
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir/parser"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+func TestVerifyCompiledClass(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	fd, err := os.Open("../jcls/testdata/Test.class")
+	if err != nil {
+		t.Fatalf("Cannot open file: %v", err)
+	}
+	defer fd.Close()
+	cls, err := jcls.ParseClass(fd)
+	if err != nil {
+		t.Fatalf("Cannot ParseClass: %v", err)
+	}
+	if err := LoadClass(cls, l).Verify(); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerifyMethod(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil)
+	datas := []struct {
+		name      string
+		flags     jcls.AccessFlag
+		typ       string
+		maxLocals uint16
+		code      []byte
+		ok        bool
+	}{
+		{"identity", jcls.AccStatic, "(I)I", 1, []byte{0x1a, 0xac}, true},                               // iload_0; ireturn
+		{"addLong", jcls.AccStatic, "(JI)J", 3, []byte{0x1e, 0x1c, 0x85, 0x61, 0xad}, true},             // lload_0; iload_2; i2l; ladd; lreturn
+		{"self", 0, "()Ljava/lang/Object;", 1, []byte{0x2a, 0xb0}, true},                                // aload_0; areturn
+		{"intAsRef", jcls.AccStatic, "()Ljava/lang/Object;", 0, []byte{0x03, 0xb0}, false},              // iconst_0; areturn
+		{"refAsInt", 0, "()I", 1, []byte{0x1a, 0xac}, false},                                            // iload_0; ireturn
+		{"noLocal", jcls.AccStatic, "()V", 0, []byte{0x2a, 0x57, 0xb1}, false},                          // aload_0; pop; return
+		{"splitLong", jcls.AccStatic, "()V", 0, []byte{0x09, 0x57, 0x57, 0xb1}, false},                  // lconst_0; pop; pop; return
+		{"overflow", jcls.AccStatic, "()V", 0, []byte{0x03, 0x03, 0x03, 0x03, 0x03, 0xb1}, false},       // iconst_0 x5; return
+		{"fallOff", jcls.AccStatic, "()V", 0, []byte{0x00}, false},                                      // nop
+		{"branchNoFrame", jcls.AccStatic, "(I)V", 1, []byte{0x1a, 0x99, 0x00, 0x04, 0x00, 0xb1}, false}, // iload_0; ifeq +4; nop; return
+		{"returnValue", jcls.AccStatic, "()I", 0, []byte{0xb1}, false},                                  // return
+		{"empty", 0, "()V", 1, []byte{0xb1}, true},                                                      // return
+		{"<init>", 0, "()V", 1, []byte{0xb1}, false},                                                    // return without super()
+		{"<init>", 0, "()V", 1, []byte{0x2a, 0xb7, 0x00, 0x01, 0xb1}, true},                             // aload_0; invokespecial Object.<init>; return
+		{"<init>", 0, "()V", 1, []byte{0x2a, 0xb6, 0x00, 0x01, 0xb1}, false},                            // aload_0; invokevirtual Object.<init>; return
+		{"<init>", 0, "()V", 1, []byte{0x2a, 0xc2, 0x2a, 0xb7, 0x00, 0x01, 0xb1}, true},                 // aload_0; monitorenter; aload_0; invokespecial Object.<init>; return
+	}
+	consts := []jcls.ConstantInfo{
+		&jcls.ConstantRef{
+			ConstTag:    jcls.TagMethodref,
+			Class:       &jcls.ConstantClass{Name: "java/lang/Object"},
+			NameAndType: &jcls.ConstantNameAndType{Name: "<init>", Desc: "()V"},
+		},
+	}
+	for i, d := range datas {
+		insts, err := parser.ParseInsts(d.code)
+		if err != nil {
+			t.Fatalf("%s: cannot parse code: %v", d.name, err)
+		}
+		md, err := desc.ParseMethodDesc(d.typ)
+		if err != nil {
+			t.Fatalf("%s: %v", d.name, err)
+		}
+		m := jcls.NewMethod(d.flags, d.name, md, nil)
+		m.Code = &jcls.AttrCode{MaxStack: 4, MaxLocals: d.maxLocals, Code: &insts[0], Insts: insts}
+		cls := jcls.NewClass(jcls.AccPublic, "V", "java/lang/Object", nil, nil, []*jcls.Method{m}, nil)
+		cls.Major = 52
+		cls.ConstPool = consts
+		err = LoadClass(cls, l).Verify()
+		if d.ok {
+			if err != nil {
+				t.Errorf("#%d %s%s: unexpected error: %v", i, d.name, d.typ, err)
+			}
+		} else if te, ok := err.(*errs.ThrowError); !ok || te.Class != "java/lang/VerifyError" {
+			t.Errorf("#%d %s%s: got %v, want VerifyError", i, d.name, d.typ, err)
+		}
+	}
+
+	// the class files without StackMapTable are accepted unchecked unless RejectUnverifiable is set
+	insts, err := parser.ParseInsts([]byte{0xb1}) // return
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := jcls.NewMethod(jcls.AccStatic, "old", &desc.MethodDesc{Output: desc.DescVoid}, nil)
+	m.Code = &jcls.AttrCode{MaxStack: 4, Code: &insts[0], Insts: insts}
+	cls := jcls.NewClass(jcls.AccPublic, "Old", "java/lang/Object", nil, nil, []*jcls.Method{m}, nil)
+	cls.Major = 49
+	old := LoadClass(cls, l)
+	if err := (&VM{opts: &Options{Verify: VerifyAll}}).verify(old); err != nil {
+		t.Errorf("version 49 class: %v", err)
+	}
+	vm := &VM{opts: &Options{Verify: VerifyAll, RejectUnverifiable: true}}
+	if te, ok := vm.verify(old).(*errs.ThrowError); !ok || te.Class != "java/lang/VerifyError" {
+		t.Errorf("version 49 class with RejectUnverifiable: got %v, want VerifyError", te)
+	}
+}
@@ -7,15 +7,21 @@ import (
 	"unsafe"
 
 	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
 	"github.com/LiterMC/wasm-jdk/ir"
 	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/mutf8"
 	"github.com/LiterMC/wasm-jdk/native/helper"
+	"github.com/LiterMC/wasm-jdk/vfs"
+	"github.com/LiterMC/wasm-jdk/vnet"
 )
 
 type VM struct {
 	stack      *Stack
 	nextPc     *ir.ICNode
 	nextNative NativeMethodCallback
+	// boundary is the stack where the uncaught throwable stops unwinding
+	boundary *Stack
 
 	opts       *Options
 	loader     ir.ClassLoader
@@ -29,6 +35,14 @@ type VM struct {
 	interruptNotifier chan struct{}
 	throwing          ir.Ref
 
+	safepoints *safepoints
+	files      *FileTable
+	fs         vfs.FS
+	network    vnet.Network
+	// runDepth is the nesting depth of run, and safeDepth is the nesting depth of safe regions, see safepoints
+	runDepth  int
+	safeDepth int
+
 	stringPool sync.Map
 
 	*preloadClasses
@@ -43,6 +57,10 @@ func NewVM(opts *Options) *VM {
 		loader:            opts.Loader,
 		created:           make(map[*VM]struct{}),
 		interruptNotifier: make(chan struct{}, 1),
+		safepoints:        newSafepoints(),
+		files:             newFileTable(opts),
+		fs:                newFS(opts),
+		network:           newNetwork(opts),
 		preloadClasses:    new(preloadClasses),
 	}
 	vm.stack = &Stack{}
@@ -91,6 +109,10 @@ func (vm *VM) NewSubVM(thread0 ir.Ref) ir.VM {
 		creator:           vm,
 		created:           make(map[*VM]struct{}),
 		interruptNotifier: make(chan struct{}, 1),
+		safepoints:        vm.safepoints,
+		files:             vm.files,
+		fs:                vm.fs,
+		network:           vm.network,
 		preloadClasses:    vm.preloadClasses,
 	}
 	thread := thread0.(*Ref)
@@ -179,9 +201,12 @@ func debugFormatIC(ic ir.IC) string {
 
 func (vm *VM) Step() error {
 	m, pc := vm.stack.method.(*Method), vm.nextPc
-	printStack := func() { // early stage debug only
+	// printStack dumps the frame and the code of the method when tracing
+	printStack := func() {
 		fmt.Println("current method:", m.class.Name()+":", m)
-		fmt.Println(NewStackInfo(vm, vm.stack, -1).String())
+		if vm.stack != nil {
+			fmt.Println(NewStackInfo(vm, vm.stack, -1).String())
+		}
 		if m.AccessFlags.Has(jcls.AccNative) {
 			return
 		}
@@ -200,7 +225,7 @@ func (vm *VM) Step() error {
 			return
 		}
 		if err := recover(); err != nil {
-			if vm.creator == nil {
+			if vm.tracing() {
 				printStack()
 			}
 			vm.throwing = (*Ref)(nil)
@@ -212,31 +237,73 @@ func (vm *VM) Step() error {
 	if vm.nextNative != nil {
 		nn := vm.nextNative
 		vm.nextNative = nil
-		err = nn(vm)
-		if err == nil {
+		frame := vm.stack
+		err = vm.callNative(nn, frame)
+		if err == nil && vm.stack == frame {
 			vm.Return()
 		}
 	} else {
 		vm.stack.pc = vm.nextPc
 		vm.nextPc = vm.stack.pc.Next
 		vm.step++
-		if vm.creator == nil {
+		if vm.tracing() {
 			fmt.Println(vm.stack.GoString())
 			fmt.Printf(" == step: %04x: %06d: %s --> %#v\n", vm.stack.pc.Offset, vm.step, debugFormatIC(vm.stack.pc.IC), vm.stack.pc.Next)
 		}
 		err = vm.stack.pc.IC.Execute(vm)
-		if vm.stack == nil && vm.creator != nil {
-			vm.creator.createdMux.Lock()
-			delete(vm.creator.created, vm)
-			vm.creator.createdMux.Unlock()
-		}
 	}
-	if err != nil {
+	if err = vm.afterStep(err); err != nil && vm.tracing() {
 		printStack()
 	}
 	return err
 }
 
+// callNative calls the native method of the frame.
+// The class initialization failure raised by InitBeforeUse is returned as the error,
+// so it will be thrown to the caller instead of crashing the VM.
+func (vm *VM) callNative(nn NativeMethodCallback, frame *Stack) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			ie, ok := r.(*initError)
+			if !ok {
+				panic(r)
+			}
+			vm.stack = frame
+			vm.nextNative = nil
+			err = ie.err
+		}
+	}()
+	return nn(vm)
+}
+
+// afterStep throws the error returned by the instruction,
+// and returns the throwable which is not caught before the boundary as *ThrowableError
+func (vm *VM) afterStep(err error) error {
+	if err != nil {
+		err = vm.throwError(err)
+	}
+	if err == nil && vm.throwing != nil {
+		err = vm.newThrowableError(vm.throwing.(*Ref))
+		vm.throwing = nil
+	}
+	if vm.stack == nil && vm.creator != nil {
+		vm.creator.createdMux.Lock()
+		delete(vm.creator.created, vm)
+		vm.creator.createdMux.Unlock()
+	}
+	return err
+}
+
+// Run executes the thread until its stack is empty
+func (vm *VM) Run() error {
+	return vm.run(nil)
+}
+
+// tracing reports whether the execution should be printed for debugging
+func (vm *VM) tracing() bool {
+	return vm.creator == nil && vm.opts != nil && vm.opts.Trace
+}
+
 func (vm *VM) GetStack() ir.Stack {
 	return vm.stack
 }
@@ -249,6 +316,10 @@ func (vm *VM) Root() *VM {
 	return v
 }
 
+func (vm *VM) InitClass(cls ir.Class) error {
+	return cls.(*Class).Initialize(vm)
+}
+
 func (vm *VM) New(cls ir.Class) ir.Ref {
 	class := cls.(*Class)
 	class.InitBeforeUse(vm)
@@ -256,19 +327,85 @@ func (vm *VM) New(cls ir.Class) ir.Ref {
 	return ref
 }
 
+// The coders of java/lang/String's value, see String.LATIN1 and String.UTF16
+const (
+	stringLatin1 int8 = 0
+	stringUTF16  int8 = 1
+)
+
 func (vm *VM) NewString(str string) ir.Ref {
-	ref := vm.New(vm.javaLangString)
-	byteArr := (**Ref)(vm.javaLangString_value.GetPointer(ref))
-	var arr *Ref
-	if len(str) > 0 {
-		arr = newRefArrayWithData(ByteArrayClass, (int32)(len(str)), (unsafe.Pointer)(unsafe.StringData(str)))
-	} else {
-		arr = newRefArray(ByteArrayClass, 0)
-	}
-	*byteArr = arr
+	return vm.newStringUTF16(mutf8.ToUTF16(str))
+}
+
+// newStringUTF16 creates a string from the UTF-16 chars, which may contain unpaired surrogates
+func (vm *VM) newStringUTF16(units []uint16) *Ref {
+	ref := vm.New(vm.javaLangString).(*Ref)
+	value, coder := encodeJavaUTF16(units)
+	*(**Ref)(vm.javaLangString_value.GetPointer(ref)) = value
+	*(*int8)(vm.javaLangString_coder.GetPointer(ref)) = coder
 	return ref
 }
 
+func encodeJavaString(str string) (*Ref, int8) {
+	return encodeJavaUTF16(mutf8.ToUTF16(str))
+}
+
+// encodeJavaUTF16 chooses the compact LATIN1 coder if every char of the string fits in a byte,
+// otherwise it stores the UTF-16 chars in native byte order, which is what StringUTF16 expects.
+func encodeJavaUTF16(units []uint16) (*Ref, int8) {
+	latin1 := true
+	for _, u := range units {
+		if u > 0xff {
+			latin1 = false
+			break
+		}
+	}
+	if latin1 {
+		arr := newRefArray(ByteArrayClass, (int32)(len(units)))
+		bytes := arr.GetByteArr()
+		for i, u := range units {
+			bytes[i] = (byte)(u)
+		}
+		return arr, stringLatin1
+	}
+	arr := newRefArray(ByteArrayClass, (int32)(len(units)*2))
+	copy(unsafe.Slice((*uint16)(arr.Data()), len(units)), units)
+	return arr, stringUTF16
+}
+
+// appendJavaString appends the UTF-16 chars of the string's value to units
+func appendJavaString(units []uint16, value *Ref, coder int8) []uint16 {
+	if coder == stringLatin1 {
+		for _, b := range unsafe.Slice((*byte)(value.Data()), value.Len()) {
+			units = append(units, (uint16)(b))
+		}
+		return units
+	}
+	return append(units, unsafe.Slice((*uint16)(value.Data()), value.Len()/2)...)
+}
+
+func decodeJavaString(value *Ref, coder int8) string {
+	if coder == stringLatin1 {
+		bytes := unsafe.Slice((*byte)(value.Data()), value.Len())
+		ascii := true
+		for _, b := range bytes {
+			if b >= 0x80 {
+				ascii = false
+				break
+			}
+		}
+		if ascii {
+			return (string)(bytes)
+		}
+		units := make([]uint16, len(bytes))
+		for i, b := range bytes {
+			units[i] = (uint16)(b)
+		}
+		return mutf8.FromUTF16(units)
+	}
+	return mutf8.FromUTF16(unsafe.Slice((*uint16)(value.Data()), value.Len()/2))
+}
+
 // Alloc an array with the descriptor as the array's type
 func (vm *VM) NewArray(dc *desc.Desc, length int32) ir.Ref {
 	class, err := vm.GetClassFromDesc(dc)
@@ -298,14 +435,12 @@ func (vm *VM) NewArrayMultiDimWithClass(cls ir.Class, lengths []int32) ir.Ref {
 // Alloc an array with the class as the elements' type
 func (vm *VM) NewObjectArray(cls ir.Class, length int32) ir.Ref {
 	class := cls.(*Class)
-	class.InitBeforeUse(vm)
 	return newRefArray(class.NewArrayClass(1), length)
 }
 
 // Alloc an array with the class as the elements' type
 func (vm *VM) NewObjectMultiDimArray(cls ir.Class, lengths []int32) ir.Ref {
 	class := cls.(*Class)
-	class.InitBeforeUse(vm)
 	return newMultiDimArray(class.NewArrayClass(len(lengths)), lengths)
 }
 
@@ -341,8 +476,9 @@ func (vm *VM) GetString(ref ir.Ref) string {
 	if !vm.javaLangString.IsInstance(ref) {
 		panic("ref is not a java/lang/String")
 	}
-	byteArr := *(**Ref)(vm.javaLangString_value.GetPointer(ref))
-	return unsafe.String((*byte)(byteArr.Data()), byteArr.Len())
+	value := *(**Ref)(vm.javaLangString_value.GetPointer(ref))
+	coder := *(*int8)(vm.javaLangString_coder.GetPointer(ref))
+	return decodeJavaString(value, coder)
 }
 
 func (vm *VM) GetStringIntern(ref ir.Ref) ir.Ref {
@@ -408,7 +544,7 @@ func (vm *VM) GetCurrentMethod() ir.Method {
 
 func (vm *VM) Return() {
 	returned := vm.stack
-	if vm.creator == nil {
+	if vm.tracing() {
 		fmt.Println("<== returning", returned.class.Name()+"."+returned.method.Name()+returned.method.Desc().String())
 		fmt.Println()
 	}
@@ -416,7 +552,7 @@ func (vm *VM) Return() {
 	if vm.stack == nil {
 		return
 	}
-	vm.nextPc = vm.stack.pc
+	vm.nextPc = vm.stack.nextPc
 	switch returned.method.Desc().Output.Type() {
 	case desc.Void:
 	case desc.Class, desc.Array:
@@ -430,23 +566,111 @@ func (vm *VM) Return() {
 	}
 }
 
+// Throw unwinds the stacks until a exception handler which can catch the throwable is found.
+// If there is no such handler, the throwable will be returned by Step as *ThrowableError
 func (vm *VM) Throw(r ir.Ref) {
-	// TODO: try/catch logic
-	vm.throwing = r
-	message := vm.GetString(*(**Ref)(vm.javaLangThrowable_detailMessage.GetPointer(r)))
-	backtrace := *(**Ref)(vm.javaLangThrowable_backtrace.GetPointer(r))
-	fmt.Printf("Throwing: %s: %s\n", r.Class().Name(), message)
-	if backtrace != nil {
-		stackInfo := backtrace.userData.(*StackInfo)
-		fmt.Println(stackInfo.String())
+	ref := r.(*Ref)
+	vm.nextNative = nil
+	if vm.tracing() {
+		fmt.Printf("Throwing: %s: %s\n", ref.class.Name(), vm.getThrowableMessage(ref))
+	}
+	for vm.stack != vm.boundary {
+		// throwing is set after the search, since loading the catch types may run Java code
+		handler, thrown := vm.stack.findExceptionHandler(vm, ref)
+		ref = thrown
+		if handler != nil {
+			vm.stack.clearStack()
+			vm.stack.PushRef(ref)
+			vm.nextPc = handler
+			vm.throwing = nil
+			return
+		}
+		vm.stack = vm.stack.prev
+	}
+	vm.throwing = ref
+	if vm.stack != nil {
+		vm.nextPc = vm.stack.nextPc
 	}
-	panic(r)
 }
 
 func (vm *VM) Throwing() ir.Ref {
 	return vm.throwing
 }
 
+// NewThrowable creates a throwable with the message and fills its stack trace.
+// An empty message will be passed to the constructor as null.
+func (vm *VM) NewThrowable(cls ir.Class, message string) (ir.Ref, error) {
+	ref := vm.New(cls)
+	vm.stack.PushRef(ref)
+	if message == "" {
+		vm.stack.PushRef(nil)
+	} else {
+		vm.stack.PushRef(vm.NewString(message))
+	}
+	init := cls.GetMethodByNameAndType("<init>", "(Ljava/lang/String;)V")
+	if init == nil {
+		return nil, fmt.Errorf("vm: %s does not have constructor (Ljava/lang/String;)V", cls.Name())
+	}
+	vm.Invoke(init)
+	if err := vm.RunStack(); err != nil {
+		return nil, err
+	}
+	return ref, nil
+}
+
+// throwError throws the equivalent Java throwable of the error.
+// It returns the error back if the error cannot be represented in Java.
+func (vm *VM) throwError(err error) error {
+	err = vm.toThrowableError(err)
+	if te, ok := err.(*ThrowableError); ok {
+		vm.Throw(te.Throwable)
+		return nil
+	}
+	return err
+}
+
+// toThrowableError creates the equivalent Java throwable of the error without throwing it.
+// It returns the error back if the error cannot be represented in Java.
+func (vm *VM) toThrowableError(err error) error {
+	if _, ok := err.(*ThrowableError); ok {
+		return err
+	}
+	e, ok := errs.AsThrowError(err)
+	if !ok {
+		return err
+	}
+	cls, err2 := vm.loadClass(e.Class)
+	if err2 != nil {
+		return fmt.Errorf("vm: cannot load %s: %w", e.Class, err2)
+	}
+	ref, err2 := vm.NewThrowable(cls, e.Message)
+	if err2 != nil {
+		return err2
+	}
+	return vm.newThrowableError(ref.(*Ref))
+}
+
+func (vm *VM) getThrowableMessage(r *Ref) string {
+	return vm.GetString(*(**Ref)(vm.javaLangThrowable_detailMessage.GetPointer(r)))
+}
+
+func (vm *VM) newThrowableError(r *Ref) *ThrowableError {
+	return &ThrowableError{
+		Throwable: r,
+		Message:   vm.getThrowableMessage(r),
+	}
+}
+
+// ThrowableError represents a throwable which is not caught by any Java code
+type ThrowableError struct {
+	Throwable *Ref
+	Message   string
+}
+
+func (e *ThrowableError) Error() string {
+	return e.Throwable.class.Name() + ": " + e.Message
+}
+
 func (vm *VM) Goto(n *ir.ICNode) {
 	vm.nextPc = n
 }
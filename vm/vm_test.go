@@ -0,0 +1,34 @@
+package vm
+
+import (
+	"testing"
+)
+
+func TestJavaStringCoder(t *testing.T) {
+	var datas = []struct {
+		Str    string
+		Coder  int8
+		Length int32
+	}{
+		{"", stringLatin1, 0},
+		{"hello", stringLatin1, 5},
+		{"café ÿ", stringLatin1, 6},
+		{"a\x00b", stringLatin1, 3},
+		{"中文", stringUTF16, 2},
+		{"😀", stringUTF16, 2},
+		{"\xed\xa0\xbdx", stringUTF16, 2},
+	}
+	for _, d := range datas {
+		value, coder := encodeJavaString(d.Str)
+		length := value.Len()
+		if coder == stringUTF16 {
+			length /= 2
+		}
+		if coder != d.Coder || length != d.Length {
+			t.Errorf("encode %q: got coder %d and length %d, want %d and %d", d.Str, coder, length, d.Coder, d.Length)
+		}
+		if s := decodeJavaString(value, coder); s != d.Str {
+			t.Errorf("decode %q: got %q", d.Str, s)
+		}
+	}
+}
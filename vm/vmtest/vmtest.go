@@ -0,0 +1,121 @@
+// Package vmtest creates VMs for the tests of the natives without the class libraries.
+//
+// The classes of the JDK which the VM preloads are replaced by stubs, which only declare the members the VM looks up,
+// and the tests define the other classes the natives use with Loader.Define.
+// Code cannot be run on the VM, the tests set the arguments on its stack and call the natives directly.
+package vmtest
+
+import (
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/native/helper"
+	jvm "github.com/LiterMC/wasm-jdk/vm"
+	"github.com/LiterMC/wasm-jdk/vm/internal/stubs"
+)
+
+// Loader is the class loader of the stub classes and the classes defined by the tests
+type Loader struct {
+	mux     sync.Mutex
+	classes map[string]ir.Class
+}
+
+var _ ir.ClassLoader = (*Loader)(nil)
+
+func NewLoader() *Loader {
+	return &Loader{
+		classes: make(map[string]ir.Class),
+	}
+}
+
+func (l *Loader) DefineClass(class ir.Class) {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	l.classes[class.Name()] = class
+}
+
+func (l *Loader) LoadClass(name string) (ir.Class, error) {
+	if c := l.LoadedClass(name); c != nil {
+		return c, nil
+	}
+	cls := stubs.NewClass(name)
+	if cls == nil {
+		return nil, &errs.ClassNotFoundException{Class: name}
+	}
+	return l.load(cls), nil
+}
+
+func (l *Loader) LoadedClass(name string) ir.Class {
+	l.mux.Lock()
+	defer l.mux.Unlock()
+	return l.classes[name]
+}
+
+func (l *Loader) AvaliablePackages() []string        { return nil }
+func (l *Loader) PackageLocation(name string) string { return "" }
+
+// Define defines a public class with the members, which are fields like "fd I" or native methods like "close0()V".
+// It panics if a member is malformed.
+func (l *Loader) Define(name, super string, members ...string) ir.Class {
+	return l.load(stubs.Class(name, super, members...))
+}
+
+func (l *Loader) load(cls *jcls.Class) ir.Class {
+	c := jvm.LoadClass(cls, l)
+	l.DefineClass(c)
+	return c
+}
+
+// NewVM creates a VM with the options, whose loader is a new Loader if it is nil
+func NewVM(opts jvm.Options) *jvm.VM {
+	if opts.Loader == nil {
+		opts.Loader = NewLoader()
+	}
+	return jvm.NewVM(&opts)
+}
+
+// NewFD adds the file to the descriptor table of the VM, and returns a java.io.FileDescriptor of it
+func NewFD(vm *jvm.VM, f io.Closer) ir.Ref {
+	cls, err := vm.GetBootLoader().LoadClass("java/io/FileDescriptor")
+	if err != nil {
+		panic(err)
+	}
+	fdObj := vm.New(cls)
+	helper.SetFD(fdObj, vm.Files().Add(f))
+	return fdObj
+}
+
+// Call sets the arguments to the local variables from 0 and calls the native.
+// The arguments are ir.Ref, int32, int64 or bool, which is passed as an int like the JVM does,
+// and the result is left on the stack.
+func Call(vm *jvm.VM, fn func(ir.VM) error, args ...any) error {
+	stack := vm.GetStack()
+	var i uint16
+	for _, a := range args {
+		switch a := a.(type) {
+		case ir.Ref:
+			stack.SetVarRef(i, a)
+		case nil:
+			stack.SetVarRef(i, nil)
+		case int32:
+			stack.SetVarInt32(i, a)
+		case int64:
+			stack.SetVarInt64(i, a)
+			i++
+		case bool:
+			if a {
+				stack.SetVarInt32(i, 1)
+			} else {
+				stack.SetVarInt32(i, 0)
+			}
+		default:
+			panic(fmt.Errorf("vmtest: unsupported argument type %T", a))
+		}
+		i++
+	}
+	return fn(vm)
+}
@@ -0,0 +1,193 @@
+package vm
+
+import (
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/jcls"
+)
+
+// methodKey returns the key of a method in the method tables
+func methodKey(name string, dc *desc.MethodDesc) string {
+	return name + dc.String()
+}
+
+// isVirtual reports whether the method is dispatched through vtable or itable
+func (m *Method) isVirtual() bool {
+	return !m.AccessFlags.Has(jcls.AccStatic|jcls.AccPrivate) && m.Name() != "<init>" && m.Name() != "<clinit>"
+}
+
+// packageName returns the package part of the class's internal name
+func (c *Class) packageName() string {
+	name := c.Name()
+	i := strings.LastIndexByte(name, '/')
+	if i < 0 {
+		return ""
+	}
+	return name[:i]
+}
+
+// isSameRuntimePackage reports whether two classes are defined by the same loader in the same package
+func (c *Class) isSameRuntimePackage(k *Class) bool {
+	return c.loader == k.loader && c.packageName() == k.packageName()
+}
+
+// canOverride reports whether m can override the inherited method sm as JVMS 5.4.5 defined
+func (m *Method) canOverride(sm *Method) bool {
+	if sm.AccessFlags.Has(jcls.AccPublic | jcls.AccProtected) {
+		return true
+	}
+	return m.class.isSameRuntimePackage(sm.class)
+}
+
+// link builds the lookup tables, vtable and itable of the class.
+// The super class and interfaces must already be linked.
+func (c *Class) link() {
+	var super *Class
+	if c.super != nil {
+		super = c.super.(*Class)
+	}
+
+	c.fieldTable = make(map[string]*Field, len(c.Fields))
+	c.methodTable = make(map[string]*Method, len(c.Methods))
+	if super != nil {
+		maps.Copy(c.fieldTable, super.fieldTable)
+		maps.Copy(c.methodTable, super.methodTable)
+	}
+	for i := range c.Fields {
+		f := &c.Fields[i]
+		c.fieldTable[f.Name()] = f
+	}
+	for i := range c.Methods {
+		m := &c.Methods[i]
+		c.methodTable[methodKey(m.Name(), m.Desc())] = m
+	}
+
+	if c.IsInterface() {
+		// interface's vtable only contains its own methods, which is used to index the itables
+		for i := range c.Methods {
+			m := &c.Methods[i]
+			m.vtableIndex = -1
+			if m.isVirtual() {
+				m.vtableIndex = len(c.vtable)
+				c.vtable = append(c.vtable, m)
+			}
+		}
+		return
+	}
+
+	// slots maps method keys to the indexes in vtable
+	slots := make(map[string][]int)
+	if super != nil {
+		c.vtable = slices.Clone(super.vtable)
+		for i, m := range c.vtable {
+			key := methodKey(m.Name(), m.Desc())
+			slots[key] = append(slots[key], i)
+		}
+	}
+	for i := range c.Methods {
+		m := &c.Methods[i]
+		m.vtableIndex = -1
+		if !m.isVirtual() {
+			continue
+		}
+		key := methodKey(m.Name(), m.Desc())
+		for _, j := range slots[key] {
+			if m.canOverride(c.vtable[j]) {
+				m.vtableIndex = j
+				c.vtable[j] = m
+			}
+		}
+		if m.vtableIndex == -1 {
+			m.vtableIndex = len(c.vtable)
+			c.vtable = append(c.vtable, m)
+			slots[key] = append(slots[key], m.vtableIndex)
+		}
+	}
+
+	// itable entries are nil if there are conflicting default methods
+	interfaces := c.allInterfaces()
+	c.itable = make(map[*Class][]*Method, len(interfaces))
+	for _, in := range interfaces {
+		table := make([]*Method, len(in.vtable))
+		for i, im := range in.vtable {
+			key := methodKey(im.Name(), im.Desc())
+			if j := slots[key]; len(j) > 0 {
+				table[i] = c.vtable[j[len(j)-1]]
+				continue
+			}
+			methods := maximallySpecificMethods(key, interfaces)
+			if m := defaultMethodOf(methods); m != nil {
+				table[i] = m
+			} else if !hasMultipleDefaultMethods(methods) {
+				table[i] = im
+			}
+		}
+		c.itable[in] = table
+	}
+}
+
+func hasMultipleDefaultMethods(methods []*Method) bool {
+	n := 0
+	for _, m := range methods {
+		if !m.AccessFlags.Has(jcls.AccAbstract) {
+			n++
+		}
+	}
+	return n > 1
+}
+
+// allInterfaces returns all superinterfaces of the class, including the ones inherited from super classes.
+// Direct superinterfaces come first.
+func (c *Class) allInterfaces() []*Class {
+	var result []*Class
+	seen := make(map[*Class]struct{})
+	push := func(in *Class) {
+		if _, ok := seen[in]; !ok {
+			seen[in] = struct{}{}
+			result = append(result, in)
+		}
+	}
+	for x := c; ; {
+		for _, in := range x.interfaces {
+			push(in.(*Class))
+		}
+		if x.super == nil {
+			break
+		}
+		x = x.super.(*Class)
+	}
+	for i := 0; i < len(result); i++ {
+		for _, in := range result[i].interfaces {
+			push(in.(*Class))
+		}
+	}
+	return result
+}
+
+// selectMethod returns the implementation of the resolved method for the instances of the class as JVMS 5.4.6 defined
+func (c *Class) selectMethod(m *Method) (*Method, error) {
+	if m.vtableIndex < 0 {
+		return m, nil
+	}
+	var selected *Method
+	if m.class.IsInterface() {
+		table, ok := c.itable[m.class]
+		if !ok {
+			return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Class %s does not implement the requested interface %s", c.Name(), m.class.Name())
+		}
+		selected = table[m.vtableIndex]
+		if selected == nil {
+			return nil, errs.Throwf("java/lang/IncompatibleClassChangeError", "Conflicting default methods: %s", m.Location())
+		}
+	} else {
+		selected = c.vtable[m.vtableIndex]
+	}
+	if selected.AccessFlags.Has(jcls.AccAbstract) {
+		return nil, errs.Throwf("java/lang/AbstractMethodError", "Receiver class %s does not define or inherit an implementation of the resolved method '%s'", c.Name(), m.Location())
+	}
+	return selected, nil
+}
@@ -0,0 +1,170 @@
+package vm
+
+import (
+	"testing"
+
+	"github.com/LiterMC/wasm-jdk/desc"
+	"github.com/LiterMC/wasm-jdk/errs"
+	"github.com/LiterMC/wasm-jdk/ir"
+	"github.com/LiterMC/wasm-jdk/jcls"
+	"github.com/LiterMC/wasm-jdk/vm/internal/stubs"
+)
+
+type testLoader struct {
+	classes map[string]ir.Class
+	// stubs makes the loader define the stub classes of the JDK on demand, so NewVM can preload them
+	stubs bool
+}
+
+func newTestLoader() *testLoader {
+	return &testLoader{classes: make(map[string]ir.Class)}
+}
+
+func (l *testLoader) DefineClass(class ir.Class) { l.classes[class.Name()] = class }
+func (l *testLoader) LoadClass(name string) (ir.Class, error) {
+	if c, ok := l.classes[name]; ok {
+		return c, nil
+	}
+	if l.stubs {
+		if cls := stubs.NewClass(name); cls != nil {
+			c := LoadClass(cls, l)
+			l.DefineClass(c)
+			return c, nil
+		}
+	}
+	return nil, &errs.ClassNotFoundException{Class: name}
+}
+func (l *testLoader) LoadedClass(name string) ir.Class   { return l.classes[name] }
+func (l *testLoader) AvaliablePackages() []string        { return nil }
+func (l *testLoader) PackageLocation(name string) string { return "" }
+
+func (l *testLoader) define(flags jcls.AccessFlag, name, super string, interfaces []string, methods ...*jcls.Method) *Class {
+	cls := jcls.NewClass(flags, name, super, interfaces, nil, methods, nil)
+	if super == "" {
+		cls.SuperSym = nil
+	}
+	c := LoadClass(cls, l)
+	l.DefineClass(c)
+	return c
+}
+
+func testMethod(flags jcls.AccessFlag, name, typ string) *jcls.Method {
+	dc, err := desc.ParseMethodDesc(typ)
+	if err != nil {
+		panic(err)
+	}
+	return jcls.NewMethod(flags, name, dc, nil)
+}
+
+func mustMethod(t *testing.T, c *Class, name, typ string) *Method {
+	t.Helper()
+	m := c.GetMethodByNameAndType(name, typ)
+	if m == nil {
+		t.Fatalf("method %s.%s%s is not found", c.Name(), name, typ)
+	}
+	return m.(*Method)
+}
+
+func TestVirtualDispatch(t *testing.T) {
+	l := newTestLoader()
+	object := l.define(jcls.AccPublic, "java/lang/Object", "", nil,
+		testMethod(jcls.AccPublic, "<init>", "()V"),
+		testMethod(jcls.AccPublic, "toString", "()Ljava/lang/String;"),
+		testMethod(jcls.AccPublic|jcls.AccNative, "hashCode", "()I"),
+	)
+	a := l.define(jcls.AccPublic, "p/A", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic, "toString", "()Ljava/lang/String;"),
+		testMethod(0, "pkg", "()V"),
+		testMethod(jcls.AccPrivate, "priv", "()V"),
+	)
+	b := l.define(jcls.AccPublic, "q/B", "p/A", nil,
+		testMethod(0, "pkg", "()V"),
+		testMethod(jcls.AccPrivate, "priv", "()V"),
+	)
+	c := l.define(jcls.AccPublic, "p/C", "q/B", nil,
+		testMethod(0, "pkg", "()V"),
+	)
+
+	toString := mustMethod(t, object, "toString", "()Ljava/lang/String;")
+	hashCode := mustMethod(t, object, "hashCode", "()I")
+	aToString := mustMethod(t, a, "toString", "()Ljava/lang/String;")
+	aPkg := mustMethod(t, a, "pkg", "()V")
+	bPkg := mustMethod(t, b, "pkg", "()V")
+	cPkg := mustMethod(t, c, "pkg", "()V")
+	aPriv := mustMethod(t, a, "priv", "()V")
+
+	var datas = []struct {
+		Class    *Class
+		Resolved *Method
+		Want     *Method
+	}{
+		{object, toString, toString},
+		{a, toString, aToString},
+		{c, toString, aToString},
+		{c, hashCode, hashCode},
+		{b, aPkg, aPkg},
+		{b, bPkg, bPkg},
+		{c, aPkg, cPkg},
+		{c, bPkg, bPkg},
+		{b, aPriv, aPriv},
+	}
+	for _, d := range datas {
+		if got, err := d.Class.selectMethod(d.Resolved); err != nil {
+			t.Errorf("select %s on %s: unexpected error %v", d.Resolved.Location(), d.Class.Name(), err)
+		} else if got != d.Want {
+			t.Errorf("select %s on %s: got %s, want %s", d.Resolved.Location(), d.Class.Name(), got.Location(), d.Want.Location())
+		}
+	}
+	if m := c.GetMethodByNameAndType("toString", "()Ljava/lang/String;"); m != aToString {
+		t.Errorf("GetMethodByNameAndType returned %v, want %v", m, aToString)
+	}
+	if m := c.GetMethodByNameAndType("missing", "()V"); m != nil {
+		t.Errorf("GetMethodByNameAndType returned %v for missing method", m)
+	}
+}
+
+func TestInterfaceDispatch(t *testing.T) {
+	l := newTestLoader()
+	l.define(jcls.AccPublic, "java/lang/Object", "", nil,
+		testMethod(jcls.AccPublic, "toString", "()Ljava/lang/String;"),
+	)
+	i := l.define(jcls.AccPublic|jcls.AccInterface|jcls.AccAbstract, "I", "java/lang/Object", nil,
+		testMethod(jcls.AccPublic|jcls.AccAbstract, "run", "()V"),
+		testMethod(jcls.AccPublic|jcls.AccAbstract, "name", "()Ljava/lang/String;"),
+	)
+	j := l.define(jcls.AccPublic|jcls.AccInterface|jcls.AccAbstract, "J", "java/lang/Object", []string{"I"},
+		testMethod(jcls.AccPublic, "run", "()V"),
+	)
+	b := l.define(jcls.AccPublic, "B", "java/lang/Object", []string{"J"},
+		testMethod(jcls.AccPublic, "name", "()Ljava/lang/String;"),
+	)
+	c := l.define(jcls.AccPublic, "C", "B", nil,
+		testMethod(jcls.AccPublic, "run", "()V"),
+	)
+
+	iRun := mustMethod(t, i, "run", "()V")
+	iName := mustMethod(t, i, "name", "()Ljava/lang/String;")
+	jRun := mustMethod(t, j, "run", "()V")
+	bName := mustMethod(t, b, "name", "()Ljava/lang/String;")
+	cRun := mustMethod(t, c, "run", "()V")
+
+	var datas = []struct {
+		Class    *Class
+		Resolved *Method
+		Want     *Method
+	}{
+		{b, iRun, jRun},
+		{b, jRun, jRun},
+		{b, iName, bName},
+		{c, iRun, cRun},
+		{c, jRun, cRun},
+		{c, iName, bName},
+	}
+	for _, d := range datas {
+		if got, err := d.Class.selectMethod(d.Resolved); err != nil {
+			t.Errorf("select %s on %s: unexpected error %v", d.Resolved.Location(), d.Class.Name(), err)
+		} else if got != d.Want {
+			t.Errorf("select %s on %s: got %s, want %s", d.Resolved.Location(), d.Class.Name(), got.Location(), d.Want.Location())
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package vnet
+
+import (
+	"sync"
+	"syscall"
+	"time"
+)
+
+// PollEvent is a ready file of Poller.Wait
+type PollEvent struct {
+	FD     int32
+	Events uint32
+}
+
+// ONESHOT disables the descriptor of Poller after an event is reported, until it is modified like EPOLLONESHOT
+const ONESHOT = 1 << 30
+
+type pollEntry struct {
+	file     Pollable
+	events   uint32
+	disabled bool
+}
+
+// Poller is a level-triggered poller like epoll(7), the files are identified by the descriptors of the caller.
+// POLLERR and POLLHUP are always reported like epoll.
+type Poller struct {
+	mux     sync.Mutex
+	entries map[int32]*pollEntry
+	// wake is notified by the files
+	wake   chan struct{}
+	closed bool
+}
+
+func NewPoller() *Poller {
+	return &Poller{
+		entries: make(map[int32]*pollEntry),
+		wake:    make(chan struct{}, 1),
+	}
+}
+
+// Add registers the file for the events, it returns EEXIST if the descriptor is registered
+func (p *Poller) Add(fd int32, f Pollable, events uint32) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	if p.closed {
+		return syscall.EBADF
+	}
+	if _, ok := p.entries[fd]; ok {
+		return syscall.EEXIST
+	}
+	p.entries[fd] = &pollEntry{file: f, events: events}
+	f.Watch(p.wake)
+	p.notify()
+	return nil
+}
+
+// Modify changes the events of the descriptor, it returns ENOENT if the descriptor is not registered
+func (p *Poller) Modify(fd int32, events uint32) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	e, ok := p.entries[fd]
+	if !ok {
+		return syscall.ENOENT
+	}
+	e.events = events
+	e.disabled = false
+	p.notify()
+	return nil
+}
+
+// Delete unregisters the descriptor, it returns ENOENT if the descriptor is not registered
+func (p *Poller) Delete(fd int32) error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	e, ok := p.entries[fd]
+	if !ok {
+		return syscall.ENOENT
+	}
+	delete(p.entries, fd)
+	e.file.Unwatch(p.wake)
+	return nil
+}
+
+func (p *Poller) notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Wait waits until there are ready files or the timeout expires, and fills the events.
+// A negative timeout waits forever, and zero returns immediately.
+func (p *Poller) Wait(events []PollEvent, timeout time.Duration) int {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		if n := p.collect(events); n > 0 || timeout == 0 || len(events) == 0 {
+			return n
+		}
+		select {
+		case <-p.wake:
+		case <-expired:
+			return p.collect(events)
+		}
+	}
+}
+
+func (p *Poller) collect(events []PollEvent) int {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	n := 0
+	for fd, e := range p.entries {
+		if n >= len(events) {
+			// the rest are reported by the next wait, since the readiness is level-triggered
+			p.notify()
+			break
+		}
+		if e.disabled {
+			continue
+		}
+		if ready := e.file.Poll() & (e.events | POLLERR | POLLHUP); ready != 0 {
+			events[n] = PollEvent{FD: fd, Events: ready}
+			n++
+			e.disabled = e.events&ONESHOT != 0
+		}
+	}
+	return n
+}
+
+// Close unregisters all the files
+func (p *Poller) Close() error {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+	for _, e := range p.entries {
+		e.file.Unwatch(p.wake)
+	}
+	p.entries = nil
+	p.closed = true
+	return nil
+}
+
+// Wait waits until the file is ready for the events like poll(2), and returns the ready events,
+// which are zero if the timeout expires. A negative timeout waits forever.
+func Wait(f Pollable, events uint32, timeout time.Duration) uint32 {
+	events |= POLLERR | POLLHUP | POLLNVAL
+	if ready := f.Poll() & events; ready != 0 || timeout == 0 {
+		return ready
+	}
+	wake := make(chan struct{}, 1)
+	f.Watch(wake)
+	defer f.Unwatch(wake)
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		if ready := f.Poll() & events; ready != 0 {
+			return ready
+		}
+		select {
+		case <-wake:
+		case <-expired:
+			return f.Poll() & events
+		}
+	}
+}
@@ -0,0 +1,780 @@
+package vnet
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"sync"
+	"syscall"
+)
+
+// Option is a socket option of Socket
+type Option int
+
+const (
+	OptReuseAddr Option = iota
+	OptReusePort
+	OptKeepAlive
+	OptNoDelay
+	// OptLinger is the timeout in seconds, or -1 if lingering is disabled
+	OptLinger
+	OptRecvBuffer
+	OptSendBuffer
+	OptBroadcast
+	OptOOBInline
+	OptTOS
+	OptMulticastTTL
+	OptMulticastLoop
+	numOptions
+)
+
+// maxDatagrams is the number of the datagrams buffered for receiving, the others are dropped like UDP does
+const maxDatagrams = 64
+
+type datagram struct {
+	data []byte
+	from netip.AddrPort
+}
+
+type socketState int
+
+const (
+	// stateOpen is the state of the created or bound sockets
+	stateOpen socketState = iota
+	stateListening
+	stateConnecting
+	stateConnected
+	stateClosed
+)
+
+// Socket emulates a BSD socket of TCP or UDP over a Network.
+//
+// The input is received into a buffer in the background, so the socket can be non-blocking and polled,
+// while the output is written through to the connection, which blocks even in non-blocking mode.
+// The methods return syscall.Errno errors like the syscalls, e.g. EAGAIN in non-blocking mode.
+type Socket struct {
+	Notifier
+
+	network Network
+	stream  bool
+	ipv6    bool
+
+	mux      sync.Mutex
+	cond     sync.Cond
+	state    socketState
+	nonblock bool
+	laddr    netip.AddrPort
+	raddr    netip.AddrPort
+	opts     [numOptions]int
+	optSet   [numOptions]bool
+	// ctx is canceled when the socket is closed, which stops connecting
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// listener reserves the address of a bound TCP socket, and accepts the connections after listen
+	listener  net.Listener
+	accepted  []net.Conn
+	acceptErr error
+
+	conn net.Conn
+	// connErr is the error of the non-blocking connect, which is reported by FinishConnect
+	connErr error
+	rbuf    []byte
+	// rerr is io.EOF or the error which stopped receiving
+	rerr   error
+	rdShut bool
+	wrShut bool
+
+	pconn     net.PacketConn
+	datagrams []datagram
+}
+
+var _ Pollable = (*Socket)(nil)
+
+// NewSocket creates a TCP socket if stream is true, otherwise a UDP socket.
+// The IPv6 sockets are dual stack, they can use IPv4 addresses too.
+func NewSocket(network Network, stream bool, ipv6 bool) *Socket {
+	s := &Socket{
+		network: network,
+		stream:  stream,
+		ipv6:    ipv6,
+	}
+	s.cond.L = &s.mux
+	s.ctx, s.cancel = context.WithCancel(context.Background())
+	s.opts[OptLinger] = -1
+	s.opts[OptRecvBuffer] = 64 * 1024
+	s.opts[OptSendBuffer] = 64 * 1024
+	s.opts[OptMulticastTTL] = 1
+	s.opts[OptMulticastLoop] = 1
+	return s
+}
+
+// Stream reports whether the socket is a TCP socket
+func (s *Socket) Stream() bool {
+	return s.stream
+}
+
+// IPv6 reports whether the socket is an IPv6 socket
+func (s *Socket) IPv6() bool {
+	return s.ipv6
+}
+
+// changed wakes the blocked operations and the watchers, the caller holds mux
+func (s *Socket) changed() {
+	s.cond.Broadcast()
+	s.Notify()
+}
+
+func (s *Socket) wildcard() netip.Addr {
+	if s.ipv6 {
+		return netip.IPv6Unspecified()
+	}
+	return netip.IPv4Unspecified()
+}
+
+// addrPortOf converts the address of the network, the IPv4-mapped IPv6 addresses are converted to IPv4
+func addrPortOf(addr net.Addr) netip.AddrPort {
+	var ap netip.AddrPort
+	switch a := addr.(type) {
+	case nil:
+		return ap
+	case *net.TCPAddr:
+		ap = a.AddrPort()
+	case *net.UDPAddr:
+		ap = a.AddrPort()
+	default:
+		ap, _ = netip.ParseAddrPort(addr.String())
+	}
+	return netip.AddrPortFrom(ap.Addr().Unmap(), ap.Port())
+}
+
+// convertError converts the errors of the closed connections to EBADF
+func convertError(err error) error {
+	if errors.Is(err, net.ErrClosed) {
+		return syscall.EBADF
+	}
+	return err
+}
+
+func (s *Socket) SetNonblock(nonblock bool) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.nonblock = nonblock
+}
+
+// Bind binds the socket to the address, an invalid address means the unspecified one
+func (s *Socket) Bind(addr netip.AddrPort) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return syscall.EBADF
+	}
+	if s.state != stateOpen || s.laddr.IsValid() {
+		return syscall.EINVAL
+	}
+	return s.bindLocked(addr)
+}
+
+func (s *Socket) bindLocked(addr netip.AddrPort) error {
+	if !addr.Addr().IsValid() {
+		addr = netip.AddrPortFrom(s.wildcard(), addr.Port())
+	}
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	if !s.ipv6 && addr.Addr().Is6() {
+		return syscall.EAFNOSUPPORT
+	}
+	if s.stream {
+		l, err := s.network.ListenTCP(addr)
+		if err != nil {
+			return err
+		}
+		s.listener = l
+		s.laddr = addrPortOf(l.Addr())
+		return nil
+	}
+	pc, err := s.network.ListenUDP(addr)
+	if err != nil {
+		return err
+	}
+	s.pconn = pc
+	s.laddr = addrPortOf(pc.LocalAddr())
+	go s.receiveLoop(pc)
+	return nil
+}
+
+// Listen starts accepting the connections, the socket is bound to a free port if it is not bound
+func (s *Socket) Listen(backlog int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return syscall.EBADF
+	}
+	if !s.stream {
+		return syscall.EOPNOTSUPP
+	}
+	if s.state == stateListening {
+		return nil
+	}
+	if s.state != stateOpen {
+		return syscall.EINVAL
+	}
+	if s.listener == nil {
+		if err := s.bindLocked(netip.AddrPort{}); err != nil {
+			return err
+		}
+	}
+	s.state = stateListening
+	go s.acceptLoop(s.listener)
+	return nil
+}
+
+func (s *Socket) acceptLoop(l net.Listener) {
+	for {
+		c, err := l.Accept()
+		s.mux.Lock()
+		if s.state == stateClosed {
+			s.mux.Unlock()
+			if c != nil {
+				c.Close()
+			}
+			return
+		}
+		if err != nil {
+			s.acceptErr = convertError(err)
+			s.changed()
+			s.mux.Unlock()
+			return
+		}
+		s.accepted = append(s.accepted, c)
+		s.changed()
+		s.mux.Unlock()
+	}
+}
+
+// Accept returns the next connection, which is a blocking socket with the options of the listening socket
+func (s *Socket) Accept() (*Socket, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	for {
+		if s.state == stateClosed {
+			return nil, syscall.EBADF
+		}
+		if s.state != stateListening {
+			return nil, syscall.EINVAL
+		}
+		if len(s.accepted) > 0 {
+			c := s.accepted[0]
+			s.accepted = s.accepted[1:]
+			ns := NewSocket(s.network, true, s.ipv6)
+			ns.opts = s.opts
+			ns.optSet = s.optSet
+			ns.mux.Lock()
+			ns.setConnected(c)
+			ns.mux.Unlock()
+			return ns, nil
+		}
+		if s.acceptErr != nil {
+			return nil, s.acceptErr
+		}
+		if s.nonblock {
+			return nil, syscall.EAGAIN
+		}
+		s.cond.Wait()
+	}
+}
+
+// Connect connects the TCP socket, or sets the peer of the UDP socket.
+// The non-blocking TCP sockets return EINPROGRESS, and FinishConnect reports the result.
+func (s *Socket) Connect(addr netip.AddrPort) error {
+	addr = netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())
+	s.mux.Lock()
+	switch s.state {
+	case stateClosed:
+		s.mux.Unlock()
+		return syscall.EBADF
+	case stateListening:
+		s.mux.Unlock()
+		return syscall.EINVAL
+	case stateConnecting:
+		s.mux.Unlock()
+		return syscall.EALREADY
+	case stateConnected:
+		s.mux.Unlock()
+		return syscall.EISCONN
+	}
+	if !s.stream {
+		defer s.mux.Unlock()
+		if s.pconn == nil {
+			if err := s.bindLocked(netip.AddrPort{}); err != nil {
+				return err
+			}
+		}
+		s.raddr = addr
+		// the datagrams from the other addresses are not received after connecting
+		kept := s.datagrams[:0]
+		for _, d := range s.datagrams {
+			if d.from == addr {
+				kept = append(kept, d)
+			}
+		}
+		s.datagrams = kept
+		return nil
+	}
+	var laddr netip.AddrPort
+	if s.listener != nil {
+		// the reserved address is released for the connection
+		laddr = s.laddr
+		s.listener.Close()
+		s.listener = nil
+	}
+	s.state = stateConnecting
+	s.raddr = addr
+	s.connErr = nil
+	if s.nonblock {
+		s.mux.Unlock()
+		go s.dial(laddr, addr)
+		return syscall.EINPROGRESS
+	}
+	s.mux.Unlock()
+	s.dial(laddr, addr)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch s.state {
+	case stateConnected:
+		return nil
+	case stateClosed:
+		return syscall.EBADF
+	}
+	err := s.connErr
+	s.connErr = nil
+	return err
+}
+
+func (s *Socket) dial(laddr, raddr netip.AddrPort) {
+	c, err := s.network.DialTCP(s.ctx, laddr, raddr)
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state != stateConnecting {
+		if c != nil {
+			c.Close()
+		}
+		return
+	}
+	if err != nil {
+		s.state = stateOpen
+		s.raddr = netip.AddrPort{}
+		s.connErr = err
+		s.changed()
+		return
+	}
+	s.setConnected(c)
+	s.changed()
+}
+
+// setConnected starts receiving from the connection, the caller holds mux
+func (s *Socket) setConnected(c net.Conn) {
+	s.state = stateConnected
+	s.conn = c
+	s.laddr = addrPortOf(c.LocalAddr())
+	s.raddr = addrPortOf(c.RemoteAddr())
+	s.applyOptions()
+	go s.readLoop(c)
+}
+
+// FinishConnect reports whether the connection is established,
+// it returns the error of the non-blocking connect if it failed.
+func (s *Socket) FinishConnect() (bool, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	switch s.state {
+	case stateConnected:
+		return true, nil
+	case stateConnecting:
+		return false, nil
+	case stateClosed:
+		return false, syscall.EBADF
+	}
+	if err := s.connErr; err != nil {
+		s.connErr = nil
+		return false, err
+	}
+	return false, syscall.ENOTCONN
+}
+
+// Disconnect removes the peer of the UDP socket
+func (s *Socket) Disconnect() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return syscall.EBADF
+	}
+	if s.stream {
+		return syscall.EOPNOTSUPP
+	}
+	s.raddr = netip.AddrPort{}
+	return nil
+}
+
+func (s *Socket) readLoop(c net.Conn) {
+	buf := make([]byte, 16*1024)
+	for {
+		s.mux.Lock()
+		// stop receiving while the buffer is full, so the peer is blocked by the flow control
+		for len(s.rbuf) >= max(s.opts[OptRecvBuffer], len(buf)) && s.state != stateClosed {
+			s.cond.Wait()
+		}
+		closed := s.state == stateClosed
+		s.mux.Unlock()
+		if closed {
+			return
+		}
+		n, err := c.Read(buf)
+		s.mux.Lock()
+		if n > 0 && !s.rdShut {
+			s.rbuf = append(s.rbuf, buf[:n]...)
+		}
+		if err != nil {
+			s.rerr = convertError(err)
+		}
+		s.changed()
+		s.mux.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Socket) receiveLoop(pc net.PacketConn) {
+	buf := make([]byte, 64*1024)
+	for {
+		n, from, err := pc.ReadFrom(buf)
+		s.mux.Lock()
+		if s.state == stateClosed {
+			s.mux.Unlock()
+			return
+		}
+		if err != nil {
+			s.rerr = convertError(err)
+			s.changed()
+			s.mux.Unlock()
+			return
+		}
+		addr := addrPortOf(from)
+		if (!s.raddr.IsValid() || addr == s.raddr) && !s.rdShut && len(s.datagrams) < maxDatagrams {
+			s.datagrams = append(s.datagrams, datagram{data: bytes.Clone(buf[:n]), from: addr})
+			s.changed()
+		}
+		s.mux.Unlock()
+	}
+}
+
+// Read reads from the TCP connection, or receives a datagram from the peer of the UDP socket.
+// It returns io.EOF at the end of the stream.
+func (s *Socket) Read(b []byte) (int, error) {
+	if !s.stream {
+		n, _, err := s.ReadFrom(b)
+		return n, err
+	}
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if len(b) == 0 {
+		return 0, nil
+	}
+	for {
+		if s.state == stateClosed {
+			return 0, syscall.EBADF
+		}
+		if s.state != stateConnected {
+			return 0, syscall.ENOTCONN
+		}
+		if len(s.rbuf) > 0 {
+			n := copy(b, s.rbuf)
+			s.rbuf = s.rbuf[n:]
+			if len(s.rbuf) == 0 {
+				s.rbuf = nil
+			}
+			// readLoop may wait for the space
+			s.cond.Broadcast()
+			return n, nil
+		}
+		if s.rdShut {
+			return 0, io.EOF
+		}
+		if s.rerr != nil {
+			return 0, s.rerr
+		}
+		if s.nonblock {
+			return 0, syscall.EAGAIN
+		}
+		s.cond.Wait()
+	}
+}
+
+// Write writes to the TCP connection, or sends a datagram to the peer of the UDP socket
+func (s *Socket) Write(b []byte) (int, error) {
+	s.mux.Lock()
+	if s.state == stateClosed {
+		s.mux.Unlock()
+		return 0, syscall.EBADF
+	}
+	if !s.stream {
+		raddr := s.raddr
+		s.mux.Unlock()
+		if !raddr.IsValid() {
+			return 0, syscall.EDESTADDRREQ
+		}
+		return s.WriteTo(b, raddr)
+	}
+	if s.state != stateConnected {
+		s.mux.Unlock()
+		return 0, syscall.ENOTCONN
+	}
+	if s.wrShut {
+		s.mux.Unlock()
+		return 0, syscall.EPIPE
+	}
+	c := s.conn
+	s.mux.Unlock()
+	n, err := c.Write(b)
+	return n, convertError(err)
+}
+
+// ReadFrom receives a datagram, the rest of the datagram which does not fit in b is discarded
+func (s *Socket) ReadFrom(b []byte) (int, netip.AddrPort, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.stream {
+		return 0, netip.AddrPort{}, syscall.EOPNOTSUPP
+	}
+	for {
+		if s.state == stateClosed {
+			return 0, netip.AddrPort{}, syscall.EBADF
+		}
+		if s.pconn == nil {
+			if err := s.bindLocked(netip.AddrPort{}); err != nil {
+				return 0, netip.AddrPort{}, err
+			}
+		}
+		if len(s.datagrams) > 0 {
+			d := s.datagrams[0]
+			s.datagrams = s.datagrams[1:]
+			return copy(b, d.data), d.from, nil
+		}
+		if s.rdShut {
+			return 0, netip.AddrPort{}, io.EOF
+		}
+		if s.rerr != nil {
+			return 0, netip.AddrPort{}, s.rerr
+		}
+		if s.nonblock {
+			return 0, netip.AddrPort{}, syscall.EAGAIN
+		}
+		s.cond.Wait()
+	}
+}
+
+// WriteTo sends a datagram, the socket is bound to a free port if it is not bound
+func (s *Socket) WriteTo(b []byte, addr netip.AddrPort) (int, error) {
+	s.mux.Lock()
+	if s.stream {
+		s.mux.Unlock()
+		return 0, syscall.EOPNOTSUPP
+	}
+	if s.state == stateClosed {
+		s.mux.Unlock()
+		return 0, syscall.EBADF
+	}
+	if s.pconn == nil {
+		if err := s.bindLocked(netip.AddrPort{}); err != nil {
+			s.mux.Unlock()
+			return 0, err
+		}
+	}
+	pc := s.pconn
+	s.mux.Unlock()
+	if !addr.IsValid() {
+		return 0, syscall.EDESTADDRREQ
+	}
+	n, err := pc.WriteTo(b, net.UDPAddrFromAddrPort(netip.AddrPortFrom(addr.Addr().Unmap(), addr.Port())))
+	return n, convertError(err)
+}
+
+// Shutdown shuts down the receiving or sending side of the connection
+func (s *Socket) Shutdown(read, write bool) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return syscall.EBADF
+	}
+	if s.stream && s.state != stateConnected {
+		return syscall.ENOTCONN
+	}
+	if read {
+		s.rdShut = true
+		s.rbuf = nil
+		s.datagrams = nil
+	}
+	if write && !s.wrShut {
+		s.wrShut = true
+		if cw, ok := s.conn.(interface{ CloseWrite() error }); ok {
+			cw.CloseWrite()
+		}
+	}
+	s.changed()
+	return nil
+}
+
+// Close closes the socket, the blocked operations return EBADF
+func (s *Socket) Close() error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return nil
+	}
+	s.state = stateClosed
+	s.cancel()
+	var err error
+	if s.listener != nil {
+		err = s.listener.Close()
+	}
+	for _, c := range s.accepted {
+		c.Close()
+	}
+	s.accepted = nil
+	if s.conn != nil {
+		err = s.conn.Close()
+	}
+	if s.pconn != nil {
+		err = s.pconn.Close()
+	}
+	s.rbuf = nil
+	s.datagrams = nil
+	s.changed()
+	return err
+}
+
+// LocalAddr returns the bound address, it is the unspecified address with port 0 if the socket is not bound
+func (s *Socket) LocalAddr() netip.AddrPort {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.laddr.IsValid() {
+		return netip.AddrPortFrom(s.wildcard(), 0)
+	}
+	return s.laddr
+}
+
+// RemoteAddr returns the peer, it is the zero AddrPort if the socket is not connected
+func (s *Socket) RemoteAddr() netip.AddrPort {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	return s.raddr
+}
+
+// Available returns the number of bytes which can be read without blocking
+func (s *Socket) Available() int {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if !s.stream {
+		if len(s.datagrams) == 0 {
+			return 0
+		}
+		return len(s.datagrams[0].data)
+	}
+	return len(s.rbuf)
+}
+
+// Poll returns the ready events like poll(2) on Linux
+func (s *Socket) Poll() uint32 {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	var events uint32
+	switch {
+	case s.state == stateClosed:
+		return POLLNVAL
+	case !s.stream:
+		events = POLLOUT
+		if len(s.datagrams) > 0 || s.rdShut || s.rerr != nil {
+			events |= POLLIN
+		}
+		if s.rerr != nil {
+			events |= POLLERR
+		}
+	case s.state == stateListening:
+		if len(s.accepted) > 0 || s.acceptErr != nil {
+			events = POLLIN
+		}
+	case s.state == stateConnecting:
+	case s.state == stateConnected:
+		events = POLLOUT
+		if len(s.rbuf) > 0 || s.rdShut || s.rerr != nil {
+			events |= POLLIN
+		}
+		if s.rerr != nil && s.rerr != io.EOF {
+			events |= POLLERR
+		}
+		if (s.rdShut || s.rerr != nil) && s.wrShut {
+			events |= POLLHUP
+		}
+	case s.connErr != nil:
+		events = POLLOUT | POLLERR | POLLHUP
+	default:
+		// the unconnected TCP sockets are hung up
+		events = POLLOUT | POLLHUP
+	}
+	return events
+}
+
+// SetOption sets the option, and applies it to the connection if the network supports it
+func (s *Socket) SetOption(opt Option, value int) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return syscall.EBADF
+	}
+	if opt < 0 || opt >= numOptions {
+		return syscall.ENOPROTOOPT
+	}
+	s.opts[opt] = value
+	s.optSet[opt] = true
+	s.applyOptions()
+	return nil
+}
+
+func (s *Socket) GetOption(opt Option) (int, error) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.state == stateClosed {
+		return 0, syscall.EBADF
+	}
+	if opt < 0 || opt >= numOptions {
+		return 0, syscall.ENOPROTOOPT
+	}
+	return s.opts[opt], nil
+}
+
+// applyOptions applies the options to the connection, the caller holds mux.
+// TCP_NODELAY is always applied since it is disabled by default on the sockets but not on the connections of net.
+func (s *Socket) applyOptions() {
+	c := s.conn
+	if c == nil {
+		return
+	}
+	if tc, ok := c.(interface{ SetNoDelay(bool) error }); ok {
+		tc.SetNoDelay(s.opts[OptNoDelay] != 0)
+	}
+	if tc, ok := c.(interface{ SetKeepAlive(bool) error }); ok && s.optSet[OptKeepAlive] {
+		tc.SetKeepAlive(s.opts[OptKeepAlive] != 0)
+	}
+	if tc, ok := c.(interface{ SetLinger(int) error }); ok && s.optSet[OptLinger] {
+		tc.SetLinger(s.opts[OptLinger])
+	}
+	if tc, ok := c.(interface{ SetReadBuffer(int) error }); ok && s.optSet[OptRecvBuffer] {
+		tc.SetReadBuffer(s.opts[OptRecvBuffer])
+	}
+	if tc, ok := c.(interface{ SetWriteBuffer(int) error }); ok && s.optSet[OptSendBuffer] {
+		tc.SetWriteBuffer(s.opts[OptSendBuffer])
+	}
+}
@@ -0,0 +1,273 @@
+package vnet
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/netip"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var loopback = netip.AddrPortFrom(netip.MustParseAddr("127.0.0.1"), 0)
+
+func expectErrno(t *testing.T, what string, err error, errno syscall.Errno) {
+	t.Helper()
+	if !errors.Is(err, errno) {
+		t.Errorf("%s: got error %v, want %v", what, err, errno)
+	}
+}
+
+func listenTCP(t *testing.T, network Network) *Socket {
+	t.Helper()
+	s := NewSocket(network, true, false)
+	t.Cleanup(func() { s.Close() })
+	if err := s.Bind(loopback); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Listen(16); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestTCP(t *testing.T) {
+	network := NewHostNetwork()
+	server := listenTCP(t, network)
+
+	client := NewSocket(network, true, false)
+	defer client.Close()
+	if err := client.Connect(server.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if conn.RemoteAddr() != client.LocalAddr() {
+		t.Errorf("remote address of the accepted socket is %v, want %v", conn.RemoteAddr(), client.LocalAddr())
+	}
+
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, err := io.ReadAtLeast(conn, buf, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "hello" {
+		t.Errorf("read %q, want %q", got, "hello")
+	}
+
+	conn.SetNonblock(true)
+	_, err = conn.Read(buf)
+	expectErrno(t, "read of the empty socket", err, syscall.EAGAIN)
+
+	if err := client.Shutdown(false, true); err != nil {
+		t.Fatal(err)
+	}
+	if ready := Wait(conn, POLLIN, time.Second); ready&POLLIN == 0 {
+		t.Fatalf("poll after shutdown returned %#x", ready)
+	}
+	if _, err := conn.Read(buf); err != io.EOF {
+		t.Errorf("read after shutdown: got error %v, want EOF", err)
+	}
+}
+
+func TestTCPNonblocking(t *testing.T) {
+	network := NewHostNetwork()
+	server := listenTCP(t, network)
+	server.SetNonblock(true)
+
+	p := NewPoller()
+	defer p.Close()
+	if err := p.Add(3, server, POLLIN); err != nil {
+		t.Fatal(err)
+	}
+	expectErrno(t, "second add", p.Add(3, server, POLLIN), syscall.EEXIST)
+	events := make([]PollEvent, 4)
+	if n := p.Wait(events, 0); n != 0 {
+		t.Errorf("listening socket is ready before any connection: %v", events[:n])
+	}
+	_, err := server.Accept()
+	expectErrno(t, "accept without connections", err, syscall.EAGAIN)
+
+	client := NewSocket(network, true, false)
+	defer client.Close()
+	client.SetNonblock(true)
+	if err := client.Connect(server.LocalAddr()); err != syscall.EINPROGRESS {
+		t.Fatalf("non-blocking connect returned %v, want EINPROGRESS", err)
+	}
+	if err := p.Add(4, client, POLLOUT); err != nil {
+		t.Fatal(err)
+	}
+
+	ready := make(map[int32]uint32)
+	deadline := time.Now().Add(5 * time.Second)
+	for len(ready) < 2 && time.Now().Before(deadline) {
+		n := p.Wait(events, time.Second)
+		for _, e := range events[:n] {
+			ready[e.FD] |= e.Events
+		}
+	}
+	if ready[3]&POLLIN == 0 || ready[4]&POLLOUT == 0 {
+		t.Fatalf("ready events are %v", ready)
+	}
+	if ok, err := client.FinishConnect(); !ok || err != nil {
+		t.Fatalf("FinishConnect returned %v, %v", ok, err)
+	}
+	conn, err := server.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	if err := p.Delete(4); err != nil {
+		t.Fatal(err)
+	}
+	expectErrno(t, "second delete", p.Delete(4), syscall.ENOENT)
+}
+
+func TestTCPConnectRefused(t *testing.T) {
+	network := NewHostNetwork()
+	// reserve a port and release it, so nothing listens on it
+	s := NewSocket(network, true, false)
+	if err := s.Bind(loopback); err != nil {
+		t.Fatal(err)
+	}
+	addr := s.LocalAddr()
+	s.Close()
+
+	client := NewSocket(network, true, false)
+	defer client.Close()
+	expectErrno(t, "connect", client.Connect(addr), syscall.ECONNREFUSED)
+	if ready := client.Poll(); ready&POLLHUP == 0 {
+		t.Errorf("poll of the unconnected socket returned %#x", ready)
+	}
+}
+
+func TestUDP(t *testing.T) {
+	network := NewHostNetwork()
+	a := NewSocket(network, false, false)
+	defer a.Close()
+	if err := a.Bind(loopback); err != nil {
+		t.Fatal(err)
+	}
+	b := NewSocket(network, false, false)
+	defer b.Close()
+	if err := b.Bind(loopback); err != nil {
+		t.Fatal(err)
+	}
+	c := NewSocket(network, false, false)
+	defer c.Close()
+
+	if _, err := c.WriteTo([]byte("from c"), a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.WriteTo([]byte("from b"), a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 16)
+	n, from, err := a.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// c is bound to the unspecified address by sending
+	if got := string(buf[:n]); got != "from c" || from.Port() != c.LocalAddr().Port() {
+		t.Errorf("received %q from %v, want %q from port %d", got, from, "from c", c.LocalAddr().Port())
+	}
+	n, from, err = a.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "from b" || from != b.LocalAddr() {
+		t.Errorf("received %q from %v, want %q from %v", got, from, "from b", b.LocalAddr())
+	}
+
+	// the connected socket only receives from the peer
+	if err := a.Connect(b.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.WriteTo([]byte("dropped"), a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := b.WriteTo([]byte("kept"), a.LocalAddr()); err != nil {
+		t.Fatal(err)
+	}
+	if ready := Wait(a, POLLIN, 5*time.Second); ready&POLLIN == 0 {
+		t.Fatalf("poll returned %#x", ready)
+	}
+	n, err = a.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "kept" {
+		t.Errorf("received %q, want %q", got, "kept")
+	}
+	a.SetNonblock(true)
+	_, err = a.Read(buf)
+	expectErrno(t, "read of the empty socket", err, syscall.EAGAIN)
+}
+
+func TestCloseWakesBlocked(t *testing.T) {
+	network := NewHostNetwork()
+	server := listenTCP(t, network)
+	done := make(chan error, 1)
+	go func() {
+		_, err := server.Accept()
+		done <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	server.Close()
+	select {
+	case err := <-done:
+		expectErrno(t, "accept", err, syscall.EBADF)
+	case <-time.After(5 * time.Second):
+		t.Fatal("accept is not woken by close")
+	}
+	if ready := server.Poll(); ready != POLLNVAL {
+		t.Errorf("poll of the closed socket returned %#x", ready)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	addrs, err := NewHostNetwork().LookupNetIP(context.Background(), "ip4", "localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if !addr.IsLoopback() {
+			t.Errorf("localhost resolved to %v", addr)
+		}
+	}
+}
+
+func TestPollerOneshot(t *testing.T) {
+	network := NewHostNetwork()
+	s := NewSocket(network, false, false)
+	defer s.Close()
+	if err := s.Bind(loopback); err != nil {
+		t.Fatal(err)
+	}
+	p := NewPoller()
+	defer p.Close()
+	if err := p.Add(5, s, POLLOUT|ONESHOT); err != nil {
+		t.Fatal(err)
+	}
+	events := make([]PollEvent, 1)
+	if n := p.Wait(events, 0); n != 1 || events[0] != (PollEvent{FD: 5, Events: POLLOUT}) {
+		t.Fatalf("first wait returned %v", events[:n])
+	}
+	if n := p.Wait(events, 0); n != 0 {
+		t.Fatalf("the disabled descriptor is reported: %v", events[:n])
+	}
+	if err := p.Modify(5, POLLOUT|ONESHOT); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Wait(events, 0); n != 1 {
+		t.Fatal("the rearmed descriptor is not reported")
+	}
+}
@@ -0,0 +1,173 @@
+// Package vnet is the network layer between the Java socket natives and the transport.
+//
+// The natives emulate BSD sockets with Socket over the Network in vm.Options,
+// so the deployments without host sockets, e.g. wasm, or tests can supply their own transport.
+// Readiness is reported with the poll(2) events of Linux, which the Java class library is built with.
+package vnet
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"os"
+	"sync"
+)
+
+// Network is the transport of the sockets.
+//
+// Errors should wrap a syscall.Errno, e.g. ECONNREFUSED or EADDRINUSE, so the natives can throw the matching exception.
+type Network interface {
+	// DialTCP connects to raddr, laddr is the zero AddrPort if the socket is not bound
+	DialTCP(ctx context.Context, laddr, raddr netip.AddrPort) (net.Conn, error)
+	// ListenTCP listens at laddr, an unspecified address listens on all the interfaces,
+	// and port 0 chooses a free port
+	ListenTCP(laddr netip.AddrPort) (net.Listener, error)
+	ListenUDP(laddr netip.AddrPort) (net.PacketConn, error)
+	// LookupNetIP resolves the host name, network is "ip", "ip4" or "ip6"
+	LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error)
+	// LookupAddr returns the names of the address
+	LookupAddr(ctx context.Context, addr netip.Addr) ([]string, error)
+	Hostname() (string, error)
+}
+
+// hostNetwork is the network of the host
+type hostNetwork struct {
+	dialer   net.Dialer
+	listener net.ListenConfig
+	resolver *net.Resolver
+}
+
+// NewHostNetwork returns the network of the host with the net package
+func NewHostNetwork() Network {
+	return &hostNetwork{
+		resolver: net.DefaultResolver,
+	}
+}
+
+// tcpNetwork returns the network which listens on both IPv4 and IPv6 for the unspecified IPv6 address, like the dual stack sockets
+func tcpNetwork(addr netip.Addr) string {
+	switch {
+	case addr.Is4():
+		return "tcp4"
+	case addr.IsUnspecified():
+		return "tcp"
+	default:
+		return "tcp6"
+	}
+}
+
+func udpNetwork(addr netip.Addr) string {
+	switch {
+	case addr.Is4():
+		return "udp4"
+	case addr.IsUnspecified():
+		return "udp"
+	default:
+		return "udp6"
+	}
+}
+
+func (h *hostNetwork) DialTCP(ctx context.Context, laddr, raddr netip.AddrPort) (net.Conn, error) {
+	d := h.dialer
+	if laddr.IsValid() {
+		d.LocalAddr = net.TCPAddrFromAddrPort(laddr)
+	}
+	return d.DialContext(ctx, tcpNetwork(raddr.Addr()), raddr.String())
+}
+
+func (h *hostNetwork) ListenTCP(laddr netip.AddrPort) (net.Listener, error) {
+	return h.listener.Listen(context.Background(), tcpNetwork(laddr.Addr()), laddr.String())
+}
+
+func (h *hostNetwork) ListenUDP(laddr netip.AddrPort) (net.PacketConn, error) {
+	return h.listener.ListenPacket(context.Background(), udpNetwork(laddr.Addr()), laddr.String())
+}
+
+func (h *hostNetwork) LookupNetIP(ctx context.Context, network, host string) ([]netip.Addr, error) {
+	return h.resolver.LookupNetIP(ctx, network, host)
+}
+
+func (h *hostNetwork) LookupAddr(ctx context.Context, addr netip.Addr) ([]string, error) {
+	return h.resolver.LookupAddr(ctx, addr.String())
+}
+
+func (h *hostNetwork) Hostname() (string, error) {
+	return os.Hostname()
+}
+
+// ipv6Available probes the host once
+var ipv6Available = sync.OnceValue(func() bool {
+	l, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		return false
+	}
+	l.Close()
+	return true
+})
+
+func (h *hostNetwork) IPv6Available() bool {
+	return ipv6Available()
+}
+
+// IPv6Available reports whether the network supports IPv6.
+// The networks which do not implement IPv6Available() bool are assumed to support it.
+func IPv6Available(n Network) bool {
+	if v, ok := n.(interface{ IPv6Available() bool }); ok {
+		return v.IPv6Available()
+	}
+	return true
+}
+
+// The poll(2) events of Linux
+const (
+	POLLIN   = 0x001
+	POLLOUT  = 0x004
+	POLLERR  = 0x008
+	POLLHUP  = 0x010
+	POLLNVAL = 0x020
+)
+
+// Pollable is a file whose readiness can be polled, e.g. Socket
+type Pollable interface {
+	// Poll returns the ready events
+	Poll() uint32
+	// Watch registers the channel which is notified when the readiness may have changed,
+	// the notifications do not block, so the channel should be buffered.
+	Watch(ch chan<- struct{})
+	Unwatch(ch chan<- struct{})
+}
+
+// Notifier implements Watch and Unwatch of Pollable
+type Notifier struct {
+	mux     sync.Mutex
+	watches map[chan<- struct{}]int
+}
+
+func (n *Notifier) Watch(ch chan<- struct{}) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.watches == nil {
+		n.watches = make(map[chan<- struct{}]int)
+	}
+	n.watches[ch]++
+}
+
+func (n *Notifier) Unwatch(ch chan<- struct{}) {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	if n.watches[ch]--; n.watches[ch] <= 0 {
+		delete(n.watches, ch)
+	}
+}
+
+// Notify notifies the watching channels without blocking
+func (n *Notifier) Notify() {
+	n.mux.Lock()
+	defer n.mux.Unlock()
+	for ch := range n.watches {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}